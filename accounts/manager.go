@@ -25,6 +25,10 @@ import (
 	"github.com/acent/go-acent/event"
 )
 
+// managerReplayLimit is the maximum number of wallet events buffered by the
+// manager while nobody is subscribed, to be replayed to the first subscriber.
+const managerReplayLimit = 1024
+
 // Config contains the settings of the global account manager.
 //
 // TODO(rjl493456442, karalabe, holiman): Get rid of this when account management
@@ -41,8 +45,11 @@ type Manager struct {
 	updaters []event.Subscription       // Wallet update subscriptions for all backends
 	updates  chan WalletEvent           // Subscription sink for backend wallet changes
 	wallets  []Wallet                   // Cache of all wallets from all registered backends
+	opened   map[URL]bool               // Wallets that reported being opened since arrival
 
-	feed event.Feed // Wallet feed notifying of arrivals/departures
+	feed    event.Feed    // Wallet feed notifying of arrivals/departures
+	pending []WalletEvent // Wallet events that happened while nobody was subscribed
+	feedMu  sync.Mutex    // Lock serializing feed delivery with new subscriptions
 
 	quit chan chan error
 	lock sync.RWMutex
@@ -70,8 +77,14 @@ func NewManager(config *Config, backends ...Backend) *Manager {
 		updaters: subs,
 		updates:  updates,
 		wallets:  wallets,
+		opened:   make(map[URL]bool),
 		quit:     make(chan chan error),
 	}
+	// Wallets discovered during construction were never announced to anyone,
+	// queue them up so the first subscriber learns about them too.
+	for _, wallet := range wallets {
+		am.pending = append(am.pending, WalletEvent{Wallet: wallet, Kind: WalletArrived})
+	}
 	for _, backend := range backends {
 		kind := reflect.TypeOf(backend)
 		am.backends[kind] = append(am.backends[kind], backend)
@@ -88,6 +101,29 @@ func (am *Manager) Close() error {
 	return <-errc
 }
 
+// AddBackend registers an additional backend with a running account manager,
+// announcing its current wallets and tracking its future wallet updates.
+func (am *Manager) AddBackend(backend Backend) {
+	wallets := backend.Wallets()
+	sub := backend.Subscribe(am.updates)
+
+	am.lock.Lock()
+	kind := reflect.TypeOf(backend)
+	am.backends[kind] = append(am.backends[kind], backend)
+	am.updaters = append(am.updaters, sub)
+	am.wallets = merge(am.wallets, wallets...)
+	am.lock.Unlock()
+
+	am.feedMu.Lock()
+	for _, wallet := range wallets {
+		event := WalletEvent{Wallet: wallet, Kind: WalletArrived}
+		if am.feed.Send(event) == 0 && len(am.pending) < managerReplayLimit {
+			am.pending = append(am.pending, event)
+		}
+	}
+	am.feedMu.Unlock()
+}
+
 // Config returns the configuration of account manager.
 func (am *Manager) Config() *Config {
 	return am.config
@@ -115,13 +151,20 @@ func (am *Manager) update() {
 			switch event.Kind {
 			case WalletArrived:
 				am.wallets = merge(am.wallets, event.Wallet)
+			case WalletOpened:
+				am.opened[event.Wallet.URL()] = true
 			case WalletDropped:
 				am.wallets = drop(am.wallets, event.Wallet)
+				delete(am.opened, event.Wallet.URL())
 			}
 			am.lock.Unlock()
 
-			// Notify any listeners of the event
-			am.feed.Send(event)
+			// Notify any listeners of the event, buffering it if nobody's listening
+			am.feedMu.Lock()
+			if am.feed.Send(event) == 0 && len(am.pending) < managerReplayLimit {
+				am.pending = append(am.pending, event)
+			}
+			am.feedMu.Unlock()
 
 		case errc := <-am.quit:
 			// Manager terminating, return
@@ -133,6 +176,9 @@ func (am *Manager) update() {
 
 // Backends retrieves the backend(s) with the given type from the account manager.
 func (am *Manager) Backends(kind reflect.Type) []Backend {
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+
 	return am.backends[kind]
 }
 
@@ -197,10 +243,99 @@ func (am *Manager) Find(account Account) (Wallet, error) {
 	return nil, ErrUnknownAccount
 }
 
+// WalletStatus is a point-in-time summary of a wallet tracked by the manager.
+type WalletStatus struct {
+	URL      URL       // Canonical URL of the wallet
+	Status   string    // Textual status reported by the wallet itself
+	Failure  error     // Failure reported by the wallet, if any
+	Opened   bool      // Whether the wallet announced being opened
+	Accounts []Account // Accounts currently pinned or derived by the wallet
+}
+
+// WalletStatuses returns the current status of all wallets registered under the
+// account manager, sorted by URL. Apart from the wallet's self-reported status,
+// the result also reports whether it's been opened and the accounts derived so
+// far, allowing user interfaces to render the full picture without racing with
+// the event subscription.
+func (am *Manager) WalletStatuses() []WalletStatus {
+	// Snapshot the wallets under the lock, querying them may block on device I/O
+	am.lock.RLock()
+	wallets := make([]Wallet, len(am.wallets))
+	copy(wallets, am.wallets)
+	opened := make([]bool, len(wallets))
+	for i, wallet := range wallets {
+		opened[i] = am.opened[wallet.URL()]
+	}
+	am.lock.RUnlock()
+
+	statuses := make([]WalletStatus, 0, len(wallets))
+	for i, wallet := range wallets {
+		status, failure := wallet.Status()
+		statuses = append(statuses, WalletStatus{
+			URL:      wallet.URL(),
+			Status:   status,
+			Failure:  failure,
+			Opened:   opened[i],
+			Accounts: wallet.Accounts(),
+		})
+	}
+	return statuses
+}
+
 // Subscribe creates an async subscription to receive notifications when the
 // manager detects the arrival or departure of a wallet from any of its backends.
+//
+// Events that happened before anyone subscribed (including the wallets detected
+// during manager construction) are replayed to the first subscriber ahead of any
+// live events. Replayed events are ordered by wallet URL, retaining the original
+// order of events belonging to the same wallet.
 func (am *Manager) Subscribe(sink chan<- WalletEvent) event.Subscription {
-	return am.feed.Subscribe(sink)
+	am.feedMu.Lock()
+	defer am.feedMu.Unlock()
+
+	if len(am.pending) == 0 {
+		return am.feed.Subscribe(sink)
+	}
+	replay := am.pending
+	am.pending = nil
+
+	sort.SliceStable(replay, func(i, j int) bool {
+		return replay[i].Wallet.URL().Cmp(replay[j].Wallet.URL()) < 0
+	})
+	// Subscribe to the live feed while still holding the feed lock, so no event
+	// can slip in between the replay and the live stream.
+	live := make(chan WalletEvent, cap(am.updates))
+	sub := am.feed.Subscribe(live)
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		for _, ev := range replay {
+			select {
+			case sink <- ev:
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+		for {
+			select {
+			case ev := <-live:
+				select {
+				case sink <- ev:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
 }
 
 // merge is a sorted analogue of append for wallets, where the ordering of the
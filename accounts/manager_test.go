@@ -0,0 +1,180 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/event"
+)
+
+// testWallet is a stub wallet only implementing the methods needed by the manager.
+type testWallet struct {
+	Wallet
+	url URL
+}
+
+func newTestWallet(path string) *testWallet {
+	return &testWallet{url: URL{Scheme: "test", Path: path}}
+}
+
+func (w *testWallet) URL() URL                { return w.url }
+func (w *testWallet) Status() (string, error) { return "ok", nil }
+func (w *testWallet) Accounts() []Account     { return nil }
+
+// testBackend is a stub backend with a fixed set of initial wallets.
+type testBackend struct {
+	wallets []Wallet
+	feed    event.Feed
+}
+
+func (b *testBackend) Wallets() []Wallet { return b.wallets }
+
+func (b *testBackend) Subscribe(sink chan<- WalletEvent) event.Subscription {
+	return b.feed.Subscribe(sink)
+}
+
+// Tests that wallet events happening before anyone subscribes are replayed to
+// the first subscriber, sorted by wallet URL.
+func TestManagerEventReplay(t *testing.T) {
+	backend := &testBackend{wallets: []Wallet{newTestWallet("b")}}
+	am := NewManager(&Config{}, backend)
+	defer am.Close()
+
+	// Fire a few events with nobody listening and wait until they are processed
+	c, a := newTestWallet("c"), newTestWallet("a")
+	for backend.feed.Send(WalletEvent{Wallet: c, Kind: WalletArrived}) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	backend.feed.Send(WalletEvent{Wallet: a, Kind: WalletArrived})
+	backend.feed.Send(WalletEvent{Wallet: a, Kind: WalletOpened})
+
+	for len(am.Wallets()) != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	for statuses := am.WalletStatuses(); !statuses[0].Opened; statuses = am.WalletStatuses() {
+		time.Sleep(time.Millisecond)
+	}
+	// Subscribe and ensure all events are delivered in a deterministic order
+	sink := make(chan WalletEvent)
+	sub := am.Subscribe(sink)
+	defer sub.Unsubscribe()
+
+	want := []struct {
+		path string
+		kind WalletEventType
+	}{
+		{"a", WalletArrived}, {"a", WalletOpened}, {"b", WalletArrived}, {"c", WalletArrived},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-sink:
+			if ev.Wallet.URL().Path != w.path || ev.Kind != w.kind {
+				t.Fatalf("event %d: have %s/%d, want %s/%d", i, ev.Wallet.URL().Path, ev.Kind, w.path, w.kind)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: timeout", i)
+		}
+	}
+	// Live events should follow the replay
+	d := newTestWallet("d")
+	backend.feed.Send(WalletEvent{Wallet: d, Kind: WalletArrived})
+	select {
+	case ev := <-sink:
+		if ev.Wallet != d {
+			t.Fatalf("live event mismatch: have %s, want %s", ev.Wallet.URL(), d.URL())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("live event timeout")
+	}
+}
+
+// Tests that backends added to a running manager have their wallets announced
+// and their later wallet events tracked.
+func TestManagerAddBackend(t *testing.T) {
+	am := NewManager(&Config{}, &testBackend{wallets: []Wallet{newTestWallet("a")}})
+	defer am.Close()
+
+	sink := make(chan WalletEvent, 8)
+	sub := am.Subscribe(sink)
+	defer sub.Unsubscribe()
+
+	backend := &testBackend{wallets: []Wallet{newTestWallet("b")}}
+	am.AddBackend(backend)
+
+	if wallets := am.Wallets(); len(wallets) != 2 || wallets[1].URL().Path != "b" {
+		t.Fatalf("added wallets missing: %v", wallets)
+	}
+	c := newTestWallet("c")
+	backend.feed.Send(WalletEvent{Wallet: c, Kind: WalletArrived})
+
+	for i, path := range []string{"a", "b", "c"} {
+		select {
+		case ev := <-sink:
+			if ev.Wallet.URL().Path != path || ev.Kind != WalletArrived {
+				t.Fatalf("event %d: have %s/%d, want %s/%d", i, ev.Wallet.URL().Path, ev.Kind, path, WalletArrived)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: timeout", i)
+		}
+	}
+}
+
+// blockingWallet is a stub wallet whose status queries block until released.
+type blockingWallet struct {
+	*testWallet
+	queried chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWallet) Status() (string, error) {
+	w.queried <- struct{}{}
+	<-w.release
+	return "ok", nil
+}
+
+// Tests that a wallet blocking on its status query does not stall the manager.
+func TestManagerWalletStatusesUnlocked(t *testing.T) {
+	wallet := &blockingWallet{testWallet: newTestWallet("a"), queried: make(chan struct{}), release: make(chan struct{})}
+	am := NewManager(&Config{}, &testBackend{wallets: []Wallet{wallet}})
+	defer am.Close()
+
+	done := make(chan []WalletStatus)
+	go func() { done <- am.WalletStatuses() }()
+
+	// Wait for the status query to block, so it overlaps the next lock
+	select {
+	case <-wallet.queried:
+	case <-time.After(time.Second):
+		t.Fatalf("wallet status query timeout")
+	}
+	am.AddBackend(&testBackend{wallets: []Wallet{newTestWallet("b")}})
+	if wallets := am.Wallets(); len(wallets) != 2 {
+		t.Fatalf("wallet count mismatch: have %d, want 2", len(wallets))
+	}
+	close(wallet.release)
+
+	select {
+	case statuses := <-done:
+		if len(statuses) != 1 || statuses[0].Status != "ok" {
+			t.Fatalf("statuses mismatch: %v", statuses)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wallet statuses timeout")
+	}
+}
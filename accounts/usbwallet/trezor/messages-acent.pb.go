@@ -640,17 +640,19 @@ func (m *AcentVerifyMessage) GetAddressHex() string {
 	return ""
 }
 
+// The messages are registered by the names of the embedded file descriptor,
+// which newer protobuf runtimes verify on registration.
 func init() {
-	proto.RegisterType((*AcentGetPublicKey)(nil), "hw.trezor.messages.acent.AcentGetPublicKey")
-	proto.RegisterType((*AcentPublicKey)(nil), "hw.trezor.messages.acent.AcentPublicKey")
-	proto.RegisterType((*AcentGetAddress)(nil), "hw.trezor.messages.acent.AcentGetAddress")
-	proto.RegisterType((*AcentAddress)(nil), "hw.trezor.messages.acent.AcentAddress")
-	proto.RegisterType((*AcentSignTx)(nil), "hw.trezor.messages.acent.AcentSignTx")
-	proto.RegisterType((*AcentTxRequest)(nil), "hw.trezor.messages.acent.AcentTxRequest")
-	proto.RegisterType((*AcentTxAck)(nil), "hw.trezor.messages.acent.AcentTxAck")
-	proto.RegisterType((*AcentSignMessage)(nil), "hw.trezor.messages.acent.AcentSignMessage")
-	proto.RegisterType((*AcentMessageSignature)(nil), "hw.trezor.messages.acent.AcentMessageSignature")
-	proto.RegisterType((*AcentVerifyMessage)(nil), "hw.trezor.messages.acent.AcentVerifyMessage")
+	proto.RegisterType((*AcentGetPublicKey)(nil), "hw.trezor.messages.ethereum.EthereumGetPublicKey")
+	proto.RegisterType((*AcentPublicKey)(nil), "hw.trezor.messages.ethereum.EthereumPublicKey")
+	proto.RegisterType((*AcentGetAddress)(nil), "hw.trezor.messages.ethereum.EthereumGetAddress")
+	proto.RegisterType((*AcentAddress)(nil), "hw.trezor.messages.ethereum.EthereumAddress")
+	proto.RegisterType((*AcentSignTx)(nil), "hw.trezor.messages.ethereum.EthereumSignTx")
+	proto.RegisterType((*AcentTxRequest)(nil), "hw.trezor.messages.ethereum.EthereumTxRequest")
+	proto.RegisterType((*AcentTxAck)(nil), "hw.trezor.messages.ethereum.EthereumTxAck")
+	proto.RegisterType((*AcentSignMessage)(nil), "hw.trezor.messages.ethereum.EthereumSignMessage")
+	proto.RegisterType((*AcentMessageSignature)(nil), "hw.trezor.messages.ethereum.EthereumMessageSignature")
+	proto.RegisterType((*AcentVerifyMessage)(nil), "hw.trezor.messages.ethereum.EthereumVerifyMessage")
 }
 
 func init() { proto.RegisterFile("messages-acent.proto", fileDescriptor_cb33f46ba915f15c) }
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -28,13 +29,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
 	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/internal/ethapi"
+	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/rpc"
 	"github.com/acent/go-acent/trie"
@@ -138,6 +144,61 @@ func (api *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	return true
 }
 
+// MinerPayload is the JSON representation of the best block built by the miner.
+type MinerPayload struct {
+	SealHash   common.Hash    `json:"sealHash"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Number     hexutil.Uint64 `json:"number"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	TxCount    hexutil.Uint   `json:"txCount"`
+	Fees       *hexutil.Big   `json:"fees"`
+	CreatedAt  hexutil.Uint64 `json:"createdAt"`
+	Block      hexutil.Bytes  `json:"block,omitempty"` // RLP encoded block, only returned by GetPayload
+}
+
+// GetWork returns a summary of the most profitable block built on the current
+// head, so external sealers can decide whether to switch to it.
+func (api *PrivateMinerAPI) GetWork() (*MinerPayload, error) {
+	return api.payload(false)
+}
+
+// GetPayload returns the most profitable block built on the current head along
+// with its RLP encoding, ready to be sealed by an external sealer.
+func (api *PrivateMinerAPI) GetPayload() (*MinerPayload, error) {
+	return api.payload(true)
+}
+
+func (api *PrivateMinerAPI) payload(full bool) (*MinerPayload, error) {
+	payload := api.e.Miner().BestPayload()
+	if payload == nil {
+		return nil, errors.New("no payload available")
+	}
+	block := payload.Block
+	result := &MinerPayload{
+		SealHash:   api.e.Engine().SealHash(block.Header()),
+		ParentHash: block.ParentHash(),
+		Number:     hexutil.Uint64(block.NumberU64()),
+		GasUsed:    hexutil.Uint64(block.GasUsed()),
+		TxCount:    hexutil.Uint(len(block.Transactions())),
+		Fees:       (*hexutil.Big)(payload.Fees),
+		CreatedAt:  hexutil.Uint64(payload.Created.Unix()),
+	}
+	if full {
+		enc, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			return nil, err
+		}
+		result.Block = enc
+	}
+	return result, nil
+}
+
+// SetOrdering sets the transaction ordering strategy of the miner (price, fifo
+// or fair).
+func (api *PrivateMinerAPI) SetOrdering(ordering string) error {
+	return api.e.Miner().SetOrdering(ordering)
+}
+
 // SetEtherbase sets the etherbase of the miner
 func (api *PrivateMinerAPI) SetEtherbase(etherbase common.Address) bool {
 	api.e.SetEtherbase(etherbase)
@@ -154,6 +215,46 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
 }
 
+// PrivateTxPoolAPI provides private RPC methods to control the transaction pool.
+type PrivateTxPoolAPI struct {
+	e *Acent
+}
+
+// NewPrivateTxPoolAPI creates a new RPC service which controls the transaction
+// pool of this node.
+func NewPrivateTxPoolAPI(e *Acent) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{e: e}
+}
+
+// TxPoolPolicy is the policy of the transaction pool for replacing a pooled
+// transaction with another one of the same nonce.
+type TxPoolPolicy struct {
+	PriceBump    *uint64 `json:"priceBump,omitempty"`    // Minimum gas price bump percentage of replacements
+	ReplaceByTip *bool   `json:"replaceByTip,omitempty"` // Whether any higher gas price suffices instead
+}
+
+// Policy returns the replacement policy of the transaction pool.
+func (api *PrivateTxPoolAPI) Policy() *TxPoolPolicy {
+	bump, byTip := api.e.txPool.ReplacementPolicy()
+	return &TxPoolPolicy{PriceBump: &bump, ReplaceByTip: &byTip}
+}
+
+// SetPolicy updates the replacement policy of the transaction pool, leaving the
+// omitted fields unchanged, and returns the resulting policy.
+func (api *PrivateTxPoolAPI) SetPolicy(policy TxPoolPolicy) (*TxPoolPolicy, error) {
+	bump, byTip := api.e.txPool.ReplacementPolicy()
+	if policy.PriceBump != nil {
+		bump = *policy.PriceBump
+	}
+	if policy.ReplaceByTip != nil {
+		byTip = *policy.ReplaceByTip
+	}
+	if err := api.e.txPool.SetReplacementPolicy(bump, byTip); err != nil {
+		return nil, err
+	}
+	return &TxPoolPolicy{PriceBump: &bump, ReplaceByTip: &byTip}, nil
+}
+
 // PrivateAdminAPI is the collection of Acent full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -264,6 +365,24 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// SetTrustedHead schedules the header chain to be backfilled from the given
+// trusted head on the next sync cycle, before syncing as usual.
+func (api *PrivateAdminAPI) SetTrustedHead(number hexutil.Uint64, hash common.Hash) (bool, error) {
+	if err := api.eth.Downloader().SetTrustedHead(uint64(number), hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClearTrustedHead abandons the header backfill scheduled by SetTrustedHead or
+// the trusted head flag, dropping the headers retrieved so far.
+func (api *PrivateAdminAPI) ClearTrustedHead() (bool, error) {
+	if err := api.eth.Downloader().ClearTrustedHead(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PublicDebugAPI is the collection of Acent full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -301,6 +420,70 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	return stateDb.RawDump(false, false, true), nil
 }
 
+// errCallIndexDisabled is returned if the call index is queried without being
+// enabled.
+var errCallIndexDisabled = errors.New("call index not enabled")
+
+// GetTransactionsByCallee returns the hashes of the transactions within the given
+// block range which called the given contract, either directly or internally.
+// The range must be covered by the call index, maintained in the background by
+// tracing the blocks if enabled.
+func (api *PublicDebugAPI) GetTransactionsByCallee(address common.Address, fromBlock, toBlock rpc.BlockNumber) ([]common.Hash, error) {
+	if api.eth.callIndexer == nil {
+		return nil, errCallIndexDisabled
+	}
+	sections, _, _ := api.eth.callIndexer.Sections()
+	if sections == 0 {
+		return nil, errors.New("call index not yet available")
+	}
+	// Resolve the block range, special blocks meaning the last indexed one
+	indexed := sections*core.CallIndexBlocks - 1
+	from, to := uint64(fromBlock), uint64(toBlock)
+	if fromBlock < 0 {
+		from = indexed
+	}
+	if toBlock < 0 {
+		to = indexed
+	}
+	if to > indexed {
+		return nil, fmt.Errorf("call index only available up to block %d", indexed)
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if tail := rawdb.ReadCallIndexTail(api.eth.chainDb); from < tail {
+		return nil, &core.HistoryUnavailableError{Kind: "call index", Number: from, Tail: tail}
+	}
+	// Collect the calling transactions from all the sections of the range
+	var (
+		hashes []common.Hash
+		limit  = api.eth.config.RPCLogsCap
+		block  *types.Block
+	)
+	for section := from / core.CallIndexBlocks; section <= to/core.CallIndexBlocks; section++ {
+		head := rawdb.ReadCanonicalHash(api.eth.chainDb, (section+1)*core.CallIndexBlocks-1)
+		for _, entry := range rawdb.ReadCallIndex(api.eth.chainDb, address, section, head) {
+			if entry.Number < from || entry.Number > to {
+				continue
+			}
+			if block == nil || block.NumberU64() != entry.Number {
+				if block = api.eth.blockchain.GetBlockByNumber(entry.Number); block == nil {
+					return nil, fmt.Errorf("block #%d not found", entry.Number)
+				}
+			}
+			txs := block.Transactions()
+			if entry.Index >= uint64(len(txs)) {
+				return nil, fmt.Errorf("invalid call index entry: tx %d of block #%d", entry.Index, entry.Number)
+			}
+			hashes = append(hashes, txs[entry.Index].Hash())
+			if limit > 0 && uint64(len(hashes)) > limit {
+				return nil, fmt.Errorf("query returned more than %d transactions", limit)
+			}
+		}
+	}
+	return hashes, nil
+}
+
 // PrivateDebugAPI is the collection of Acent full node APIs exposed over
 // the private debugging endpoint.
 type PrivateDebugAPI struct {
@@ -313,6 +496,12 @@ func NewPrivateDebugAPI(eth *Acent) *PrivateDebugAPI {
 	return &PrivateDebugAPI{eth: eth}
 }
 
+// Compact compacts the whole chain database right away, one key range at a time
+// like the idle background compaction does.
+func (api *PrivateDebugAPI) Compact() error {
+	return api.eth.compactor.Compact()
+}
+
 // Preimage is a debug API function that returns the preimage for a sha3 hash, if known.
 func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	if preimage := rawdb.ReadPreimage(api.eth.ChainDb(), hash); preimage != nil {
@@ -321,6 +510,146 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return nil, errors.New("unknown preimage")
 }
 
+// TxPropagation reports when the local node first saw a transaction, from which
+// peer, and the peers it was exchanged with afterwards. Only the most recent
+// gossip events are retained, so the report of older transactions may be partial
+// or missing altogether.
+func (api *PrivateDebugAPI) TxPropagation(hash common.Hash) (*TxPropagation, error) {
+	if propagation := api.eth.handler.txGossip.propagation(hash); propagation != nil {
+		return propagation, nil
+	}
+	return nil, errors.New("no gossip recorded for transaction")
+}
+
+// errSnapshotDisabled is returned if snapshot generation controls are invoked on
+// a node running without state snapshots.
+var errSnapshotDisabled = errors.New("snapshots are disabled")
+
+// SnapshotProgress is the JSON representation of the background snapshot
+// generation progress.
+type SnapshotProgress struct {
+	Generating bool           `json:"generating"`
+	Paused     bool           `json:"paused"`
+	Marker     hexutil.Bytes  `json:"marker"`
+	Accounts   hexutil.Uint64 `json:"accounts"`
+	Slots      hexutil.Uint64 `json:"slots"`
+	Storage    hexutil.Uint64 `json:"storage"`
+	Elapsed    string         `json:"elapsed"`
+	RateLimit  hexutil.Uint64 `json:"rateLimit"`
+	CPUShare   float64        `json:"cpuShare"`
+}
+
+// SnapshotProgress reports the progress of the background snapshot generation.
+func (api *PrivateDebugAPI) SnapshotProgress() (*SnapshotProgress, error) {
+	snaps := api.eth.BlockChain().Snapshots()
+	if snaps == nil {
+		return nil, errSnapshotDisabled
+	}
+	progress, err := snaps.GenerationProgress()
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotProgress{
+		Generating: progress.Generating,
+		Paused:     progress.Paused,
+		Marker:     progress.Marker,
+		Accounts:   hexutil.Uint64(progress.Accounts),
+		Slots:      hexutil.Uint64(progress.Slots),
+		Storage:    hexutil.Uint64(progress.Storage),
+		Elapsed:    progress.Elapsed.String(),
+		RateLimit:  hexutil.Uint64(progress.RateLimit),
+		CPUShare:   progress.CPUShare,
+	}, nil
+}
+
+// PauseSnapshotGeneration suspends the background snapshot generation.
+func (api *PrivateDebugAPI) PauseSnapshotGeneration() error {
+	snaps := api.eth.BlockChain().Snapshots()
+	if snaps == nil {
+		return errSnapshotDisabled
+	}
+	snaps.PauseGeneration()
+	return nil
+}
+
+// ResumeSnapshotGeneration continues a paused background snapshot generation.
+func (api *PrivateDebugAPI) ResumeSnapshotGeneration() error {
+	snaps := api.eth.BlockChain().Snapshots()
+	if snaps == nil {
+		return errSnapshotDisabled
+	}
+	snaps.ResumeGeneration()
+	return nil
+}
+
+// SetSnapshotGenerationLimits throttles the background snapshot generation to
+// the given number of bytes written per second (zero for unlimited) and to the
+// given fraction of busy time (one for unlimited).
+func (api *PrivateDebugAPI) SetSnapshotGenerationLimits(rate hexutil.Uint64, cpuShare float64) error {
+	snaps := api.eth.BlockChain().Snapshots()
+	if snaps == nil {
+		return errSnapshotDisabled
+	}
+	return snaps.SetGenerationLimits(uint64(rate), cpuShare)
+}
+
+// defaultWitnessReexec is the number of blocks the witness generator is willing
+// to go back and reexecute to produce the pre-state of the requested block.
+const defaultWitnessReexec = uint64(128)
+
+// BlockWitnessResult is the JSON representation of a stateless block witness.
+type BlockWitnessResult struct {
+	Root  common.Hash     `json:"root"`
+	Nodes []hexutil.Bytes `json:"nodes"`
+	Codes []hexutil.Bytes `json:"codes"`
+}
+
+// GetBlockWitness re-executes the requested block on top of its parent state and
+// returns every trie node and contract code touched during execution, allowing
+// a stateless client to verify the block with no other state at hand.
+func (api *PrivateDebugAPI) GetBlockWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*BlockWitnessResult, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executed")
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, release, err := api.eth.stateAtBlock(parent, defaultWitnessReexec)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	witness := statedb.StartWitness()
+	if _, _, _, err := api.eth.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, fmt.Errorf("failed to process block %d: %v", block.NumberU64(), err)
+	}
+	blockWitness, err := witness.Build(statedb.Database())
+	if err != nil {
+		return nil, err
+	}
+	result := &BlockWitnessResult{
+		Root:  blockWitness.Root,
+		Nodes: make([]hexutil.Bytes, len(blockWitness.Nodes)),
+		Codes: make([]hexutil.Bytes, len(blockWitness.Codes)),
+	}
+	for i, node := range blockWitness.Nodes {
+		result.Nodes[i] = node
+	}
+	for i, code := range blockWitness.Codes {
+		result.Codes[i] = code
+	}
+	return result, nil
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash  common.Hash            `json:"hash"`
@@ -358,14 +687,45 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 	return results, nil
 }
 
-// AccountRangeMaxResults is the maximum number of results to be returned per call
-const AccountRangeMaxResults = 256
+const (
+	// AccountRangeMaxResults is the maximum number of results to be returned per call
+	AccountRangeMaxResults = 256
 
-// AccountRange enumerates all accounts in the given block and start point in paging request
-func (api *PublicDebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start []byte, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
-	var stateDb *state.StateDB
-	var err error
+	// StorageRangeMaxResults is the maximum number of storage slots to be returned
+	// per call.
+	StorageRangeMaxResults = 1024
 
+	// StateRangeMaxBytes is the approximate maximum size of a state range
+	// response. Iteration stops after the item crossing the limit.
+	StateRangeMaxBytes = 4 * 1024 * 1024
+)
+
+// stateRangeTimeout is the time allowance of a state range request, after which
+// the results gathered so far are returned along with the cursor to continue.
+const stateRangeTimeout = 5 * time.Second
+
+// AccountRangeResult is the result of a debug_accountRange API call.
+type AccountRangeResult struct {
+	state.IteratorDump
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`   // Block to pin follow-up requests to, nil for the pending state
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"` // Number of the above block
+}
+
+// AccountRange enumerates all accounts in the given block and start point in paging request.
+//
+// The accounts are iterated in the order of their hashes, so the returned next
+// key is a stable cursor: requesting it at the returned block hash continues
+// the enumeration of the same state. The iteration stops early once the
+// response grows too large or takes too long, in which case a partial result is
+// returned with the cursor set. The storage of each account is also capped at
+// StorageRangeMaxResults slots, the rest can be retrieved with
+// debug_storageRangeAt starting at storageNext.
+func (api *PublicDebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start []byte, maxResults int, nocode, nostorage, incompletes bool) (AccountRangeResult, error) {
+	var (
+		stateDb *state.StateDB
+		block   *types.Block
+		err     error
+	)
 	if number, ok := blockNrOrHash.Number(); ok {
 		if number == rpc.PendingBlockNumber {
 			// If we're dumping the pending state, we need to request
@@ -373,39 +733,234 @@ func (api *PublicDebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, sta
 			// the miner and operate on those
 			_, stateDb = api.eth.miner.Pending()
 		} else {
-			var block *types.Block
 			if number == rpc.LatestBlockNumber {
 				block = api.eth.blockchain.CurrentBlock()
 			} else {
 				block = api.eth.blockchain.GetBlockByNumber(uint64(number))
 			}
 			if block == nil {
-				return state.IteratorDump{}, fmt.Errorf("block #%d not found", number)
-			}
-			stateDb, err = api.eth.BlockChain().StateAt(block.Root())
-			if err != nil {
-				return state.IteratorDump{}, err
+				return AccountRangeResult{}, fmt.Errorf("block #%d not found", number)
 			}
 		}
 	} else if hash, ok := blockNrOrHash.Hash(); ok {
-		block := api.eth.blockchain.GetBlockByHash(hash)
+		block = api.eth.blockchain.GetBlockByHash(hash)
 		if block == nil {
-			return state.IteratorDump{}, fmt.Errorf("block %s not found", hash.Hex())
-		}
-		stateDb, err = api.eth.BlockChain().StateAt(block.Root())
-		if err != nil {
-			return state.IteratorDump{}, err
+			return AccountRangeResult{}, fmt.Errorf("block %s not found", hash.Hex())
 		}
 	} else {
-		return state.IteratorDump{}, errors.New("either block number or block hash must be specified")
+		return AccountRangeResult{}, errors.New("either block number or block hash must be specified")
 	}
 
 	if maxResults > AccountRangeMaxResults || maxResults <= 0 {
 		maxResults = AccountRangeMaxResults
 	}
-	return stateDb.IteratorDump(nocode, nostorage, incompletes, start, maxResults), nil
+	if block == nil {
+		dump, err := accountRangeTrie(stateDb, start, maxResults, nocode, nostorage, incompletes)
+		if err != nil {
+			return AccountRangeResult{}, err
+		}
+		return AccountRangeResult{IteratorDump: dump}, nil
+	}
+	var (
+		hash   = block.Hash()
+		number = hexutil.Uint64(block.NumberU64())
+		result = AccountRangeResult{BlockHash: &hash, BlockNumber: &number}
+	)
+	// Serve the range from the snapshot if it covers the requested state
+	if snaps := api.eth.blockchain.Snapshots(); snaps != nil && snaps.Snapshot(block.Root()) != nil {
+		result.IteratorDump, err = accountRangeSnapshot(api.eth.blockchain.StateCache(), snaps, block.Root(), start, maxResults, nocode, nostorage, incompletes)
+		if err == nil {
+			return result, nil
+		}
+		log.Debug("Failed to iterate snapshot accounts, falling back to trie", "root", block.Root(), "err", err)
+	}
+	stateDb, err = api.eth.BlockChain().StateAt(block.Root())
+	if err != nil {
+		return AccountRangeResult{}, err
+	}
+	result.IteratorDump, err = accountRangeTrie(stateDb, start, maxResults, nocode, nostorage, incompletes)
+	if err != nil {
+		return AccountRangeResult{}, err
+	}
+	return result, nil
+}
+
+// accountRangeSnapshot enumerates the accounts of the given state from the
+// snapshot, within the limits of iterateAccountRange.
+func accountRangeSnapshot(db state.Database, snaps *snapshot.Tree, root common.Hash, start []byte, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	var seek common.Hash
+	copy(seek[:], start)
+
+	it, err := snaps.AccountIterator(root, seek)
+	if err != nil {
+		return state.IteratorDump{}, err
+	}
+	defer it.Release()
+
+	storage := func(hash common.Hash, account snapshot.Account) (storageIterator, func(), error) {
+		sit, err := snaps.StorageIterator(root, hash, common.Hash{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return sit, sit.Release, nil
+	}
+	result, err := iterateAccountRange(snapshotAccountIterator{it}, db, tr.GetKey, storage, maxResults, nocode, nostorage, incompletes)
+	result.Root = fmt.Sprintf("%x", root)
+	return result, err
+}
+
+// accountRangeTrie enumerates the accounts of the given state from its tries,
+// within the limits of iterateAccountRange. Storage not committed yet is taken
+// from the state itself if the preimage of the account hash is known.
+func accountRangeTrie(statedb *state.StateDB, start []byte, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+	var (
+		db = statedb.Database()
+		tr = statedb.AccountTrie()
+	)
+	storage := func(hash common.Hash, account snapshot.Account) (storageIterator, func(), error) {
+		var st state.Trie
+		if preimage := tr.GetKey(hash.Bytes()); preimage != nil {
+			st = statedb.StorageTrie(common.BytesToAddress(preimage))
+		}
+		if st == nil {
+			var err error
+			if st, err = db.OpenStorageTrie(hash, common.BytesToHash(account.Root)); err != nil {
+				return nil, nil, err
+			}
+		}
+		return trieStorageIterator{trie.NewIterator(st.NodeIterator(nil))}, func() {}, nil
+	}
+	result, err := iterateAccountRange(trieAccountIterator{trie.NewIterator(tr.NodeIterator(start))}, db, tr.GetKey, storage, maxResults, nocode, nostorage, incompletes)
+	result.Root = fmt.Sprintf("%x", tr.Hash())
+	return result, err
+}
+
+// accountIterator is an iterator over the accounts of a state, ordered by their
+// hashes.
+type accountIterator interface {
+	Next() bool
+	Hash() common.Hash
+	Account() (snapshot.Account, error)
+	Error() error
+}
+
+// snapshotAccountIterator adapts a snapshot account iterator to the
+// accountIterator interface.
+type snapshotAccountIterator struct {
+	snapshot.AccountIterator
+}
+
+func (it snapshotAccountIterator) Account() (snapshot.Account, error) {
+	return snapshot.FullAccount(it.AccountIterator.Account())
+}
+
+// trieAccountIterator adapts an account trie iterator to the accountIterator
+// interface.
+type trieAccountIterator struct {
+	*trie.Iterator
+}
+
+func (it trieAccountIterator) Hash() common.Hash { return common.BytesToHash(it.Key) }
+func (it trieAccountIterator) Error() error      { return it.Err }
+
+func (it trieAccountIterator) Account() (snapshot.Account, error) {
+	var account snapshot.Account
+	err := rlp.DecodeBytes(it.Value, &account)
+	return account, err
+}
+
+// iterateAccountRange collects at most maxResults accounts from the iterator,
+// stopping early with a cursor if the response grows too large or the request
+// takes too long. The storage of an account is truncated after
+// StorageRangeMaxResults slots, with the hash of the next one returned to
+// continue from.
+func iterateAccountRange(it accountIterator, db state.Database, getKey func([]byte) []byte, storage func(common.Hash, snapshot.Account) (storageIterator, func(), error), maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+	var (
+		result   = state.IteratorDump{Accounts: make(map[common.Address]state.DumpAccount)}
+		deadline = time.Now().Add(stateRangeTimeout)
+		size     int
+	)
+	for it.Next() {
+		// Stop with a cursor if any of the limits were reached
+		if len(result.Accounts) >= maxResults || size >= StateRangeMaxBytes || time.Now().After(deadline) {
+			result.Next = common.CopyBytes(it.Hash().Bytes())
+			break
+		}
+		data, err := it.Account()
+		if err != nil {
+			return state.IteratorDump{}, err
+		}
+		account := state.DumpAccount{
+			Balance:  data.Balance.String(),
+			Nonce:    data.Nonce,
+			Root:     common.Bytes2Hex(data.Root),
+			CodeHash: common.Bytes2Hex(data.CodeHash),
+		}
+		addrBytes := getKey(it.Hash().Bytes())
+		if addrBytes == nil {
+			if incompletes {
+				continue
+			}
+			account.SecureKey = common.CopyBytes(it.Hash().Bytes())
+		}
+		if !nocode && !bytes.Equal(data.CodeHash, emptyCodeHash) {
+			code, err := db.ContractCode(it.Hash(), common.BytesToHash(data.CodeHash))
+			if err != nil {
+				return state.IteratorDump{}, err
+			}
+			account.Code = common.Bytes2Hex(code)
+			size += len(account.Code)
+		}
+		if !nostorage {
+			account.Storage = make(map[common.Hash]string)
+			storageIt, release, err := storage(it.Hash(), data)
+			if err != nil {
+				return state.IteratorDump{}, err
+			}
+			for storageIt.Next() {
+				if len(account.Storage) >= StorageRangeMaxResults {
+					next := storageIt.Hash()
+					account.StorageNext = &next
+					break
+				}
+				_, content, _, err := rlp.Split(storageIt.Slot())
+				if err != nil {
+					release()
+					return state.IteratorDump{}, err
+				}
+				key := storageIt.Hash()
+				if preimage := getKey(key.Bytes()); preimage != nil {
+					key = common.BytesToHash(preimage)
+				}
+				account.Storage[key] = common.Bytes2Hex(content)
+			}
+			err = storageIt.Error()
+			release()
+			if err != nil {
+				return state.IteratorDump{}, err
+			}
+			size += len(account.Storage) * 2 * common.HashLength * 2
+		}
+		result.Accounts[common.BytesToAddress(addrBytes)] = account
+		size += accountDumpSize
+	}
+	if err := it.Error(); err != nil {
+		return state.IteratorDump{}, err
+	}
+	return result, nil
 }
 
+// accountDumpSize is the approximate JSON size of an account dump without its
+// code and storage.
+const accountDumpSize = 256
+
+// emptyCodeHash is the code hash of accounts without code.
+var emptyCodeHash = crypto.Keccak256(nil)
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -420,12 +975,26 @@ type storageEntry struct {
 }
 
 // StorageRangeAt returns the storage at the given block height and transaction index.
+//
+// The slots are iterated in the order of their hashes and at most StorageRangeMaxResults
+// are returned. If the request takes too long, the slots gathered so far are
+// returned along with the key to continue from.
 func (api *PrivateDebugAPI) StorageRangeAt(blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, maxResult int) (StorageRangeResult, error) {
+	if maxResult > StorageRangeMaxResults {
+		maxResult = StorageRangeMaxResults
+	}
 	// Retrieve the block
 	block := api.eth.blockchain.GetBlockByHash(blockHash)
 	if block == nil {
 		return StorageRangeResult{}, fmt.Errorf("block %#x not found", blockHash)
 	}
+	// The state before the first transaction is the parent state, which can be
+	// served from the snapshot if it's covered without replaying anything.
+	if txIndex == 0 && block.NumberU64() > 0 {
+		if result, err := api.storageRangeSnapshot(block.ParentHash(), contractAddress, keyStart, maxResult); err == nil {
+			return result, nil
+		}
+	}
 	_, _, statedb, release, err := api.eth.stateAtTransaction(block, txIndex, 0)
 	if err != nil {
 		return StorageRangeResult{}, err
@@ -438,27 +1007,107 @@ func (api *PrivateDebugAPI) StorageRangeAt(blockHash common.Hash, txIndex int, c
 	return storageRangeAt(st, keyStart, maxResult)
 }
 
+// storageRangeSnapshot iterates the storage of an account in the post state of
+// the given block from the snapshot. An error is returned if the state is not
+// covered by the snapshot.
+func (api *PrivateDebugAPI) storageRangeSnapshot(blockHash common.Hash, address common.Address, start []byte, maxResult int) (StorageRangeResult, error) {
+	snaps := api.eth.blockchain.Snapshots()
+	if snaps == nil {
+		return StorageRangeResult{}, errSnapshotDisabled
+	}
+	header := api.eth.blockchain.GetHeaderByHash(blockHash)
+	if header == nil {
+		return StorageRangeResult{}, fmt.Errorf("block %#x not found", blockHash)
+	}
+	snap := snaps.Snapshot(header.Root)
+	if snap == nil {
+		return StorageRangeResult{}, fmt.Errorf("state %#x not covered by snapshot", header.Root)
+	}
+	accountHash := crypto.Keccak256Hash(address.Bytes())
+	account, err := snap.Account(accountHash)
+	if err != nil {
+		return StorageRangeResult{}, snapshotError(snaps, err)
+	}
+	if account == nil {
+		return StorageRangeResult{}, fmt.Errorf("account %x doesn't exist", address)
+	}
+	tr, err := api.eth.blockchain.StateCache().OpenTrie(header.Root)
+	if err != nil {
+		return StorageRangeResult{}, err
+	}
+	var seek common.Hash
+	copy(seek[:], start)
+
+	it, err := snaps.StorageIterator(header.Root, accountHash, seek)
+	if err != nil {
+		return StorageRangeResult{}, snapshotError(snaps, err)
+	}
+	defer it.Release()
+
+	return iterateStorageRange(it, tr.GetKey, maxResult)
+}
+
+// snapshotError reports a snapshot read failing on data not generated yet as
+// acent.ErrPaused if the generation was paused by the operator, as the data
+// won't become available until it's resumed.
+func snapshotError(snaps *snapshot.Tree, err error) error {
+	if err != snapshot.ErrNotCoveredYet {
+		return err
+	}
+	if progress, perr := snaps.GenerationProgress(); perr == nil && progress.Paused {
+		return acent.ErrPaused.Errorf("snapshot %v, generation paused", err)
+	}
+	return err
+}
+
 func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeResult, error) {
-	it := trie.NewIterator(st.NodeIterator(start))
-	result := StorageRangeResult{Storage: storageMap{}}
-	for i := 0; i < maxResult && it.Next(); i++ {
-		_, content, _, err := rlp.Split(it.Value)
+	return iterateStorageRange(trieStorageIterator{trie.NewIterator(st.NodeIterator(start))}, st.GetKey, maxResult)
+}
+
+// storageIterator is an iterator over the storage slots of an account, ordered
+// by their hashes.
+type storageIterator interface {
+	Next() bool
+	Hash() common.Hash
+	Slot() []byte
+	Error() error
+}
+
+// trieStorageIterator adapts a storage trie iterator to the storageIterator
+// interface.
+type trieStorageIterator struct {
+	*trie.Iterator
+}
+
+func (it trieStorageIterator) Hash() common.Hash { return common.BytesToHash(it.Key) }
+func (it trieStorageIterator) Slot() []byte      { return it.Value }
+func (it trieStorageIterator) Error() error      { return it.Err }
+
+// iterateStorageRange collects at most maxResult slots from the iterator, or
+// less if the request runs out of its time allowance.
+func iterateStorageRange(it storageIterator, getKey func([]byte) []byte, maxResult int) (StorageRangeResult, error) {
+	var (
+		result   = StorageRangeResult{Storage: storageMap{}}
+		deadline = time.Now().Add(stateRangeTimeout)
+	)
+	for i := 0; i < maxResult && time.Now().Before(deadline) && it.Next(); i++ {
+		_, content, _, err := rlp.Split(it.Slot())
 		if err != nil {
 			return StorageRangeResult{}, err
 		}
 		e := storageEntry{Value: common.BytesToHash(content)}
-		if preimage := st.GetKey(it.Key); preimage != nil {
+		if preimage := getKey(it.Hash().Bytes()); preimage != nil {
 			preimage := common.BytesToHash(preimage)
 			e.Key = &preimage
 		}
-		result.Storage[common.BytesToHash(it.Key)] = e
+		result.Storage[it.Hash()] = e
 	}
 	// Add the 'next key' so clients can continue downloading.
 	if it.Next() {
-		next := common.BytesToHash(it.Key)
+		next := it.Hash()
 		result.NextKey = &next
 	}
-	return result, nil
+	return result, it.Error()
 }
 
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
@@ -21,13 +21,16 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/accounts"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/bitutil"
 	"github.com/acent/go-acent/consensus"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/bloombits"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/core/vm"
 	"github.com/acent/go-acent/eth/downloader"
@@ -37,6 +40,7 @@ import (
 	"github.com/acent/go-acent/miner"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/rpc"
+	"github.com/acent/go-acent/trie"
 )
 
 // EthAPIBackend implements ethapi.Backend for full nodes
@@ -45,6 +49,7 @@ type EthAPIBackend struct {
 	allowUnprotectedTxs bool
 	eth                 *Acent
 	gpo                 *gasprice.Oracle
+	finality            *core.Finality
 }
 
 // ChainConfig returns the active chain configuration.
@@ -68,8 +73,13 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 		return block.Header(), nil
 	}
 	// Otherwise resolve and return the block
-	if number == rpc.LatestBlockNumber {
+	switch number {
+	case rpc.LatestBlockNumber:
 		return b.eth.blockchain.CurrentBlock().Header(), nil
+	case rpc.SafeBlockNumber:
+		return b.finality.SafeHeader(), nil
+	case rpc.FinalizedBlockNumber:
+		return b.finality.FinalizedHeader(), nil
 	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
@@ -81,7 +91,7 @@ func (b *EthAPIBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash
 	if hash, ok := blockNrOrHash.Hash(); ok {
 		header := b.eth.blockchain.GetHeaderByHash(hash)
 		if header == nil {
-			return nil, errors.New("header for hash not found")
+			return nil, acent.ErrBlockNotFound.Errorf("header for hash not found")
 		}
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, errors.New("hash is not currently canonical")
@@ -102,14 +112,33 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 		return block, nil
 	}
 	// Otherwise resolve and return the block
-	if number == rpc.LatestBlockNumber {
+	switch number {
+	case rpc.LatestBlockNumber:
 		return b.eth.blockchain.CurrentBlock(), nil
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		header, _ := b.HeaderByNumber(ctx, number)
+		if header == nil {
+			return nil, nil
+		}
+		return b.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
 	}
-	return b.eth.blockchain.GetBlockByNumber(uint64(number)), nil
+	return b.checkBlock(b.eth.blockchain.GetBlockByNumber(uint64(number)))
 }
 
 func (b *EthAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
-	return b.eth.blockchain.GetBlockByHash(hash), nil
+	return b.checkBlock(b.eth.blockchain.GetBlockByHash(hash))
+}
+
+// checkBlock returns an error instead of the block if its body was skipped by
+// the sync, rather than returning it without transactions.
+func (b *EthAPIBackend) checkBlock(block *types.Block) (*types.Block, error) {
+	if block == nil {
+		return nil, nil
+	}
+	if err := b.eth.blockchain.CheckHistory(block.NumberU64(), false); err != nil {
+		return nil, err
+	}
+	return block, nil
 }
 
 func (b *EthAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
@@ -119,11 +148,14 @@ func (b *EthAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash r
 	if hash, ok := blockNrOrHash.Hash(); ok {
 		header := b.eth.blockchain.GetHeaderByHash(hash)
 		if header == nil {
-			return nil, errors.New("header for hash not found")
+			return nil, acent.ErrBlockNotFound.Errorf("header for hash not found")
 		}
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, errors.New("hash is not currently canonical")
 		}
+		if err := b.eth.blockchain.CheckHistory(header.Number.Uint64(), false); err != nil {
+			return nil, err
+		}
 		block := b.eth.blockchain.GetBlock(hash, header.Number.Uint64())
 		if block == nil {
 			return nil, errors.New("header found, but block body is missing")
@@ -145,9 +177,9 @@ func (b *EthAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.B
 		return nil, nil, err
 	}
 	if header == nil {
-		return nil, nil, errors.New("header not found")
+		return nil, nil, acent.ErrBlockNotFound
 	}
-	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(header)
 	return stateDb, header, err
 }
 
@@ -161,22 +193,44 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 			return nil, nil, err
 		}
 		if header == nil {
-			return nil, nil, errors.New("header for hash not found")
+			return nil, nil, acent.ErrBlockNotFound.Errorf("header for hash not found")
 		}
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
 		}
-		stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+		stateDb, err := b.stateAt(header)
 		return stateDb, header, err
 	}
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
+// stateAt returns the state of a block, reconstructing it from the reverse state
+// diffs if the block's state was already pruned. Missing state is reported as
+// acent.ErrStatePruned.
+func (b *EthAPIBackend) stateAt(header *types.Header) (*state.StateDB, error) {
+	statedb, err := b.eth.BlockChain().StateAt(header.Root)
+	if err != nil && b.eth.config.StateHistory > 0 {
+		if historic, herr := b.eth.BlockChain().HistoricState(header); herr == nil {
+			return historic, nil
+		}
+	}
+	if _, ok := err.(*trie.MissingNodeError); ok {
+		return nil, acent.ErrStatePruned.Errorf("%v", err)
+	}
+	return statedb, err
+}
+
 func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	if err := b.checkReceipts(hash); err != nil {
+		return nil, err
+	}
 	return b.eth.blockchain.GetReceiptsByHash(hash), nil
 }
 
 func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	if err := b.checkReceipts(hash); err != nil {
+		return nil, err
+	}
 	receipts := b.eth.blockchain.GetReceiptsByHash(hash)
 	if receipts == nil {
 		return nil, nil
@@ -188,6 +242,15 @@ func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*typ
 	return logs, nil
 }
 
+// checkReceipts returns an error if the receipts of the given block were skipped
+// by the sync.
+func (b *EthAPIBackend) checkReceipts(hash common.Hash) error {
+	if number := rawdb.ReadHeaderNumber(b.eth.chainDb, hash); number != nil {
+		return b.eth.blockchain.CheckHistory(*number, true)
+	}
+	return nil
+}
+
 func (b *EthAPIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(hash)
 }
@@ -204,6 +267,10 @@ func (b *EthAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEven
 	return b.eth.BlockChain().SubscribeRemovedLogsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeReorgEvent(ch)
+}
+
 func (b *EthAPIBackend) SubscribePendingLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return b.eth.miner.SubscribePendingLogs(ch)
 }
@@ -225,9 +292,26 @@ func (b *EthAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 }
 
 func (b *EthAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
+	if b.eth.config.ReadOnly {
+		return errReadOnly
+	}
 	return b.eth.txPool.AddLocal(signedTx)
 }
 
+func (b *EthAPIBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction) error {
+	if b.eth.config.ReadOnly {
+		return errReadOnly
+	}
+	return b.eth.txPool.AddPrivate(signedTx)
+}
+
+func (b *EthAPIBackend) SendConditionalTx(ctx context.Context, signedTx *types.Transaction, conditions *core.TxConditions) error {
+	if b.eth.config.ReadOnly {
+		return errReadOnly
+	}
+	return b.eth.txPool.AddConditional(signedTx, conditions)
+}
+
 func (b *EthAPIBackend) GetPoolTransactions() (types.Transactions, error) {
 	pending, err := b.eth.txPool.Pending()
 	if err != nil {
@@ -244,6 +328,14 @@ func (b *EthAPIBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.eth.txPool.Get(hash)
 }
 
+// GetPoolTransactionStatus returns the pool status of a transaction, along with
+// the number of peers known to have it. The count is approximate, the known
+// transactions of a peer are a bounded set evicting the oldest entries.
+func (b *EthAPIBackend) GetPoolTransactionStatus(hash common.Hash) (core.TxStatus, int) {
+	status := b.eth.txPool.Status([]common.Hash{hash})[0]
+	return status, len(b.eth.handler.peers.peersWithTransaction(hash))
+}
+
 func (b *EthAPIBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.eth.ChainDb(), txHash)
 	return tx, blockHash, blockNumber, index, nil
@@ -261,6 +353,10 @@ func (b *EthAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.eth.TxPool().Content()
 }
 
+func (b *EthAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.eth.TxPool().ContentFrom(addr)
+}
+
 func (b *EthAPIBackend) TxPool() *core.TxPool {
 	return b.eth.TxPool()
 }
@@ -269,6 +365,14 @@ func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.S
 	return b.eth.TxPool().SubscribeNewTxsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return b.eth.TxPool().SubscribeDropTxsEvent(ch)
+}
+
+func (b *EthAPIBackend) SubscribeReplaceTxEvent(ch chan<- core.ReplaceTxEvent) event.Subscription {
+	return b.eth.TxPool().SubscribeReplaceTxEvent(ch)
+}
+
 func (b *EthAPIBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -305,9 +409,29 @@ func (b *EthAPIBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *EthAPIBackend) RPCReturnDataCap() uint64 {
+	return b.eth.config.RPCReturnDataCap
+}
+
+func (b *EthAPIBackend) RPCLogsCap() uint64 {
+	return b.eth.config.RPCLogsCap
+}
+
+func (b *EthAPIBackend) RPCLogsPageSize() uint64 {
+	return b.eth.config.RPCLogsPageSize
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.eth.bloomIndexer.Sections()
-	return params.BloomBitsBlocks, sections
+	return b.eth.config.BloomBitsBlocks, sections
+}
+
+func (b *EthAPIBackend) BloomExtent() (uint64, uint64) {
+	first, count := rawdb.ReadBloomBitsExtent(b.eth.chainDb)
+	if sections, _, _ := b.eth.bloomIndexer.Sections(); first+count <= sections {
+		return 0, 0 // Caught up by the indexer
+	}
+	return first, count
 }
 
 func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
@@ -316,6 +440,23 @@ func (b *EthAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.Ma
 	}
 }
 
+func (b *EthAPIBackend) LogIndexStatus() (uint64, uint64) {
+	if b.eth.logIndexer == nil {
+		return 0, 0
+	}
+	sections, _, _ := b.eth.logIndexer.Sections()
+	return params.BloomBitsBlocks, sections
+}
+
+func (b *EthAPIBackend) LogIndex(ctx context.Context, address common.Address, section uint64) ([]byte, error) {
+	head := rawdb.ReadCanonicalHash(b.eth.chainDb, (section+1)*params.BloomBitsBlocks-1)
+	compVector := rawdb.ReadLogIndex(b.eth.chainDb, address, section, head)
+	if compVector == nil {
+		return nil, nil
+	}
+	return bitutil.DecompressBytes(compVector, int(params.BloomBitsBlocks/8))
+}
+
 func (b *EthAPIBackend) Engine() consensus.Engine {
 	return b.eth.engine
 }
@@ -336,6 +477,14 @@ func (b *EthAPIBackend) StateAtBlock(ctx context.Context, block *types.Block, re
 	return b.eth.stateAtBlock(block, reexec)
 }
 
+func (b *EthAPIBackend) StateProver(ctx context.Context, header *types.Header) (*snapshot.Prover, error) {
+	snaps := b.eth.blockchain.Snapshots()
+	if snaps == nil {
+		return nil, errors.New("snapshots disabled")
+	}
+	return snaps.Prover(header.Root, b.eth.blockchain.StateCache().TrieDB())
+}
+
 func (b *EthAPIBackend) StatesInRange(ctx context.Context, fromBlock *types.Block, toBlock *types.Block, reexec uint64) ([]*state.StateDB, func(), error) {
 	return b.eth.statesInRange(fromBlock, toBlock, reexec)
 }
@@ -28,13 +28,17 @@ import (
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
 	"github.com/acent/go-acent/crypto"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
 
 func accountRangeTest(t *testing.T, trie *state.Trie, statedb *state.StateDB, start common.Hash, requestedNum int, expectedNum int) state.IteratorDump {
-	result := statedb.IteratorDump(true, true, false, start.Bytes(), requestedNum)
+	result, err := accountRangeTrie(statedb, start.Bytes(), requestedNum, true, true, false)
+	if err != nil {
+		t.Fatalf("failed to iterate accounts: %v", err)
+	}
 
 	if len(result.Accounts) != expectedNum {
 		t.Fatalf("expected %d results, got %d", expectedNum, len(result.Accounts))
@@ -207,3 +211,86 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the storage of accounts served from the snapshot is truncated with
+// a cursor to continue from, so a single large contract can't blow up a response.
+func TestAccountRangeSnapshotStorageLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = state.NewDatabase(rawdb.NewMemoryDatabase())
+		contract = common.Address{0x01}
+	)
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	statedb.SetNonce(contract, 1)
+	for i := 0; i < StorageRangeMaxResults+10; i++ {
+		statedb.SetState(contract, common.BigToHash(big.NewInt(int64(i+1))), common.Hash{0x01})
+	}
+	root, _ := statedb.Commit(false)
+	db.TrieDB().Commit(root, false, nil)
+
+	snaps, err := snapshot.New(db.TrieDB().DiskDB(), db.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	result, err := accountRangeSnapshot(db, snaps, root, nil, AccountRangeMaxResults, true, false, false)
+	if err != nil {
+		t.Fatalf("failed to iterate accounts: %v", err)
+	}
+	if len(result.Accounts) != 1 {
+		t.Fatalf("account count mismatch: have %d, want 1", len(result.Accounts))
+	}
+	for _, account := range result.Accounts {
+		if len(account.Storage) != StorageRangeMaxResults {
+			t.Errorf("storage size mismatch: have %d, want %d", len(account.Storage), StorageRangeMaxResults)
+		}
+		if account.StorageNext == nil {
+			t.Fatal("storage cursor missing")
+		}
+		// The cursor must continue right after the returned slots
+		it, err := snaps.StorageIterator(root, crypto.Keccak256Hash(contract.Bytes()), *account.StorageNext)
+		if err != nil {
+			t.Fatalf("failed to iterate storage: %v", err)
+		}
+		var rest int
+		for it.Next() {
+			rest++
+		}
+		it.Release()
+		if rest != 10 {
+			t.Errorf("remaining slot count mismatch: have %d, want 10", rest)
+		}
+	}
+}
+
+// Tests that the storage of accounts served from the tries is truncated just
+// like from the snapshot, including storage that isn't committed yet.
+func TestAccountRangeTrieStorageLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = state.NewDatabase(rawdb.NewMemoryDatabase())
+		contract = common.Address{0x01}
+	)
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	statedb.SetNonce(contract, 1)
+	for i := 0; i < StorageRangeMaxResults+10; i++ {
+		statedb.SetState(contract, common.BigToHash(big.NewInt(int64(i+1))), common.Hash{0x01})
+	}
+	statedb.IntermediateRoot(false)
+
+	result, err := accountRangeTrie(statedb, nil, AccountRangeMaxResults, true, false, false)
+	if err != nil {
+		t.Fatalf("failed to iterate accounts: %v", err)
+	}
+	account, ok := result.Accounts[contract]
+	if len(result.Accounts) != 1 || !ok {
+		t.Fatalf("account mismatch: have %v, want %x", result.Accounts, contract)
+	}
+	if len(account.Storage) != StorageRangeMaxResults {
+		t.Errorf("storage size mismatch: have %d, want %d", len(account.Storage), StorageRangeMaxResults)
+	}
+	if account.StorageNext == nil {
+		t.Fatal("storage cursor missing")
+	}
+}
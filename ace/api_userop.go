@@ -0,0 +1,58 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+)
+
+// PublicUserOpAPI provides the ERC-4337 bundler API, accepting user operations
+// into the user operation pool of the node.
+type PublicUserOpAPI struct {
+	pool *core.UserOpPool
+}
+
+// NewPublicUserOpAPI creates a new ERC-4337 bundler API.
+func NewPublicUserOpAPI(pool *core.UserOpPool) *PublicUserOpAPI {
+	return &PublicUserOpAPI{pool}
+}
+
+// SendUserOperation validates a user operation against the given entry point and
+// adds it to the pool, returning its hash.
+func (api *PublicUserOpAPI) SendUserOperation(op types.UserOperation, entryPoint common.Address) (common.Hash, error) {
+	return api.pool.Add(&op, entryPoint)
+}
+
+// SupportedEntryPoints returns the entry points user operations are accepted for.
+func (api *PublicUserOpAPI) SupportedEntryPoints() []common.Address {
+	return api.pool.EntryPoints()
+}
+
+// GetUserOperationByHash returns a pooled user operation along with its entry
+// point, or nil if the operation is not in the pool.
+func (api *PublicUserOpAPI) GetUserOperationByHash(hash common.Hash) map[string]interface{} {
+	op, entryPoint := api.pool.Get(hash)
+	if op == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"userOperation": op,
+		"entryPoint":    entryPoint,
+	}
+}
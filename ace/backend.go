@@ -29,6 +29,7 @@ import (
 	"github.com/acent/go-acent/accounts"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/consensus"
 	"github.com/acent/go-acent/consensus/clique"
 	"github.com/acent/go-acent/core"
@@ -43,7 +44,9 @@ import (
 	"github.com/acent/go-acent/eth/gasprice"
 	"github.com/acent/go-acent/eth/protocols/eth"
 	"github.com/acent/go-acent/eth/protocols/snap"
+	"github.com/acent/go-acent/eth/rpcapi"
 	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/ethdb/leveldb"
 	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/internal/ethapi"
 	"github.com/acent/go-acent/log"
@@ -56,6 +59,10 @@ import (
 	"github.com/acent/go-acent/rpc"
 )
 
+// errReadOnly is returned if mining or transaction submission is attempted on a
+// node running read-only.
+var errReadOnly = errors.New("node is read-only")
+
 // Config contains the configuration options of the ETH protocol.
 // Deprecated: use ethconfig.Config instead.
 type Config = ethconfig.Config
@@ -66,13 +73,16 @@ type Acent struct {
 
 	// Handlers
 	txPool             *core.TxPool
+	userOpPool         *core.UserOpPool // ERC-4337 user operation pool, nil if disabled
 	blockchain         *core.BlockChain
 	handler            *handler
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
 
 	// DB interfaces
-	chainDb ethdb.Database // Block chain database
+	chainDb       ethdb.Database               // Block chain database
+	compactor     *leveldb.CompactionScheduler // Compaction scheduler of the chain database
+	compactionSub event.Subscription           // Block import subscription postponing idle compactions
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -81,6 +91,8 @@ type Acent struct {
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
+	logIndexer        *core.ChainIndexer // Contract log indexer operating during block imports, nil if disabled
+	callIndexer       *core.ChainIndexer // Contract call indexer tracing imported blocks, nil if disabled
 
 	APIBackend *EthAPIBackend
 
@@ -106,6 +118,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	if config.Observer && config.SyncMode == downloader.SnapSync {
+		return nil, errors.New("can't snap sync in observer mode, the snap protocol is disabled")
+	}
 	if config.Miner.GasPrice == nil || config.Miner.GasPrice.Cmp(common.Big0) <= 0 {
 		log.Warn("Sanitizing invalid miner gas price", "provided", config.Miner.GasPrice, "updated", ethconfig.Defaults.Miner.GasPrice)
 		config.Miner.GasPrice = new(big.Int).Set(ethconfig.Defaults.Miner.GasPrice)
@@ -122,22 +137,46 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Acent object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", config.ReadOnly)
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideBerlin)
+	var (
+		chainConfig *params.ChainConfig
+		genesisHash common.Hash
+		genesisErr  error
+	)
+	if config.ReadOnly {
+		log.Warn("Running read-only, chain import, mining and peering are disabled")
+		chainConfig, genesisHash, genesisErr = loadChainConfig(chainDb)
+	} else {
+		chainConfig, genesisHash, genesisErr = core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.ForkOverrides)
+	}
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
-	if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
-		log.Error("Failed to recover state", "error", err)
+	if !config.ReadOnly {
+		if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
+			log.Error("Failed to recover state", "error", err)
+		}
+	}
+	if config.BloomBitsBlocks == 0 {
+		config.BloomBitsBlocks = params.BloomBitsBlocks
+	}
+	if config.BloomBitsBlocks%8 != 0 {
+		return nil, fmt.Errorf("invalid bloom bits section size %d, must be a multiple of 8", config.BloomBitsBlocks)
+	}
+	if !config.ReadOnly {
+		core.SetBloomBitsSize(chainDb, config.BloomBitsBlocks)
+	} else if size := rawdb.ReadBloomBitsSize(chainDb); size != 0 {
+		config.BloomBitsBlocks = size // Can't drop the index, use it as built
 	}
 	eth := &Acent{
 		config:            config,
 		chainDb:           chainDb,
+		compactor:         leveldb.NewCompactionScheduler(chainDb, leveldb.DefaultCompactionConfig),
 		eventMux:          stack.EventMux(),
 		accountManager:    stack.AccountManager(),
 		engine:            ethconfig.CreateConsensusEngine(stack, chainConfig, &config.Ethash, config.Miner.Notify, config.Miner.Noverify, chainDb),
@@ -146,10 +185,13 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 		gasPrice:          config.Miner.GasPrice,
 		etherbase:         config.Miner.Etherbase,
 		bloomRequests:     make(chan chan *bloombits.Retrieval),
-		bloomIndexer:      core.NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms),
+		bloomIndexer:      core.NewBloomIndexer(chainDb, config.BloomBitsBlocks, params.BloomConfirms),
 		p2pServer:         stack.Server(),
 	}
 
+	if config.LogIndex {
+		eth.logIndexer = core.NewLogIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+	}
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
 	var dbVer = "<nil>"
 	if bcVersion != nil {
@@ -161,8 +203,12 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 		if bcVersion != nil && *bcVersion > core.BlockChainVersion {
 			return nil, fmt.Errorf("database version is v%d, Geth %s only supports v%d", *bcVersion, params.VersionWithMeta, core.BlockChainVersion)
 		} else if bcVersion == nil || *bcVersion < core.BlockChainVersion {
-			log.Warn("Upgrade blockchain database version", "from", dbVer, "to", core.BlockChainVersion)
-			rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
+			if config.ReadOnly {
+				log.Warn("Outdated blockchain database version", "version", dbVer, "current", core.BlockChainVersion)
+			} else {
+				log.Warn("Upgrade blockchain database version", "from", dbVer, "to", core.BlockChainVersion)
+				rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
+			}
 		}
 	}
 	var (
@@ -180,25 +226,55 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
+			StateHistory:        config.StateHistory,
 			Preimages:           config.Preimages,
+			ReadOnly:            config.ReadOnly,
 		}
 	)
+	// Transactions can't be indexed beyond the downloaded history
+	if config.TransactionHistory > 0 && (config.TxLookupLimit == 0 || config.TxLookupLimit > config.TransactionHistory) {
+		log.Warn("Limiting transaction index to the transaction history", "provided", config.TxLookupLimit, "updated", config.TransactionHistory)
+		config.TxLookupLimit = config.TransactionHistory
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
 	}
+	var budget *membudget.Manager
+	if config.CacheBudget > 0 {
+		budget = membudget.NewManager(uint64(config.CacheBudget) * 1024 * 1024)
+		eth.blockchain.RegisterMemoryBudget(budget)
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
 		eth.blockchain.SetHead(compat.RewindTo)
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
-	eth.bloomIndexer.Start(eth.blockchain)
-
+	if config.CallIndex {
+		eth.callIndexer = core.NewCallIndexer(eth.blockchain, chainDb)
+	}
+	if !config.ReadOnly {
+		eth.bloomIndexer.Start(eth.blockchain)
+		if eth.logIndexer != nil {
+			eth.logIndexer.Start(eth.blockchain)
+		}
+		if eth.callIndexer != nil {
+			eth.callIndexer.Start(eth.blockchain)
+		}
+	} else {
+		config.TxPool.Journal = ""
+	}
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
+	if budget != nil {
+		eth.txPool.RegisterMemoryBudget(budget)
+	}
+	if len(config.UserOpPool.EntryPoints) > 0 {
+		eth.userOpPool = core.NewUserOpPool(config.UserOpPool, chainConfig, eth.blockchain)
+	}
 
 	// Permit the downloader to use the trie cache allowance during fast sync
 	cacheLimit := cacheConfig.TrieCleanLimit + cacheConfig.TrieDirtyLimit + cacheConfig.SnapshotLimit
@@ -216,13 +292,29 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 		EventMux:   eth.eventMux,
 		Checkpoint: checkpoint,
 		Whitelist:  config.Whitelist,
+		Budget:     budget,
+
+		TransactionHistory: config.TransactionHistory,
+		LogHistory:         config.LogHistory,
+
+		Watchdog:         config.HeadWatchdog,
+		WatchdogRotation: config.HeadWatchdogRotation,
+
+		Observer:          config.Observer,
+		ObserverServeRate: config.ObserverServeRate,
 	}); err != nil {
 		return nil, err
 	}
+	if head := config.TrustedHead; head != nil {
+		if err := eth.handler.downloader.SetTrustedHead(head.Number, head.Hash); err != nil {
+			return nil, err
+		}
+	}
 	eth.miner = miner.New(eth, &config.Miner, chainConfig, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
-	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil}
+	finality := core.NewFinality(eth.blockchain, eth.engine, config.RPCSafeDepth, config.RPCFinalizedDepth)
+	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil, finality}
 	if eth.APIBackend.allowUnprotectedTxs {
 		log.Info("Unprotected transactions allowed")
 	}
@@ -245,9 +337,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 
 	// Register the backend on the node
 	stack.RegisterAPIs(eth.APIs())
-	stack.RegisterProtocols(eth.Protocols())
+	if !config.ReadOnly {
+		stack.RegisterProtocols(eth.Protocols())
+	}
 	stack.RegisterLifecycle(eth)
 	// Check for unclean shutdown
+	if config.ReadOnly {
+		return eth, nil
+	}
 	if uncleanShutdowns, discards, err := rawdb.PushUncleanShutdownMarker(chainDb); err != nil {
 		log.Error("Could not update unclean-shutdown-marker list", "error", err)
 	} else {
@@ -263,6 +360,23 @@ func New(stack *node.Node, config *ethconfig.Config) (*Acent, error) {
 	return eth, nil
 }
 
+// loadChainConfig retrieves the genesis hash and chain configuration stored in
+// the database, without initialising or upgrading them as read-only nodes can't.
+func loadChainConfig(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+	genesis := rawdb.ReadCanonicalHash(db, 0)
+	if genesis == (common.Hash{}) {
+		return nil, common.Hash{}, core.ErrNoGenesis
+	}
+	config := rawdb.ReadChainConfig(db, genesis)
+	if config == nil {
+		return nil, genesis, errors.New("chain configuration not found")
+	}
+	if err := vm.ValidateEVMForks(config); err != nil {
+		return nil, genesis, err
+	}
+	return config, genesis, nil
+}
+
 func makeExtraData(extra []byte) []byte {
 	if len(extra) == 0 {
 		// create default extradata
@@ -283,11 +397,20 @@ func makeExtraData(extra []byte) []byte {
 // APIs return the collection of RPC services the acent package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Acent) APIs() []rpc.API {
-	apis := ethapi.GetAPIs(s.APIBackend)
+	apis := rpcapi.GetAPIs(s.APIBackend)
 
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the user operation API if user operations are pooled
+	if s.userOpPool != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicUserOpAPI(s.userOpPool),
+			Public:    true,
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -310,6 +433,11 @@ func (s *Acent) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateMinerAPI(s),
 			Public:    false,
+		}, {
+			Namespace: "txpool",
+			Version:   "1.0",
+			Service:   NewPrivateTxPoolAPI(s),
+			Public:    false,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -431,6 +559,9 @@ func (s *Acent) SetEtherbase(etherbase common.Address) {
 // is already running, this method adjust the number of threads allowed to use
 // and updates the minimum price required by the transaction pool.
 func (s *Acent) StartMining(threads int) error {
+	if s.config.ReadOnly {
+		return errReadOnly
+	}
 	// Update the thread count within the consensus engine
 	type threaded interface {
 		SetThreads(threads int)
@@ -493,6 +624,7 @@ func (s *Acent) Miner() *miner.Miner { return s.miner }
 func (s *Acent) AccountManager() *accounts.Manager  { return s.accountManager }
 func (s *Acent) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Acent) TxPool() *core.TxPool               { return s.txPool }
+func (s *Acent) UserOpPool() *core.UserOpPool       { return s.userOpPool }
 func (s *Acent) EventMux() *event.TypeMux           { return s.eventMux }
 func (s *Acent) Engine() consensus.Engine           { return s.engine }
 func (s *Acent) ChainDb() ethdb.Database            { return s.chainDb }
@@ -501,12 +633,26 @@ func (s *Acent) Downloader() *downloader.Downloader { return s.handler.downloade
 func (s *Acent) Synced() bool                       { return atomic.LoadUint32(&s.handler.acceptTxs) == 1 }
 func (s *Acent) ArchiveMode() bool                  { return s.config.NoPruning }
 func (s *Acent) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
+func (s *Acent) Finality() *core.Finality           { return s.APIBackend.finality }
+
+// SubscribeHeadStallEvent registers a subscription for the alerts of the head
+// watchdog, posted when the chain head stalls and peers are rotated.
+func (s *Acent) SubscribeHeadStallEvent(ch chan<- HeadStallEvent) event.Subscription {
+	return s.handler.stallFeed.Subscribe(ch)
+}
+
+// ForceSync requests the chain syncer to synchronise with the best peer right
+// away, even if there are fewer peers than normally required, instead of waiting
+// for the periodic force timer.
+func (s *Acent) ForceSync() {
+	s.handler.chainSync.forceSync()
+}
 
 // Protocols returns all the currently configured
 // network protocols to start.
 func (s *Acent) Protocols() []p2p.Protocol {
 	protos := eth.MakeProtocols((*ethHandler)(s.handler), s.networkID, s.ethDialCandidates)
-	if s.config.SnapshotCache > 0 {
+	if s.config.SnapshotCache > 0 && !s.config.Observer {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
 	return protos
@@ -515,10 +661,12 @@ func (s *Acent) Protocols() []p2p.Protocol {
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Acent protocol implementation.
 func (s *Acent) Start() error {
-	eth.StartENRUpdater(s.blockchain, s.p2pServer.LocalNode())
+	if err := eth.StartENRUpdater(s.blockchain, s.p2pServer.LocalNode()); err != nil {
+		return err
+	}
 
 	// Start the bloom bits servicing goroutines
-	s.startBloomHandlers(params.BloomBitsBlocks)
+	s.startBloomHandlers(s.config.BloomBitsBlocks)
 
 	// Figure out a max peers count based on the server limits
 	maxPeers := s.p2pServer.MaxPeers
@@ -530,9 +678,39 @@ func (s *Acent) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Compact the database whenever the node is idle, if requested
+	if s.config.DatabaseCompaction {
+		events := make(chan core.ChainEvent, 16)
+		s.compactionSub = s.blockchain.SubscribeChainEvent(events)
+		s.compactor.Start()
+		go s.compactionLoop(events)
+	}
 	return nil
 }
 
+// compactionLoop reports block imports and served RPC calls to the compaction
+// scheduler, postponing idle compactions while the node is in use.
+func (s *Acent) compactionLoop(events chan core.ChainEvent) {
+	recheck := time.NewTicker(leveldb.DefaultCompactionConfig.Recheck)
+	defer recheck.Stop()
+
+	calls := rpc.ServedCalls()
+	for {
+		select {
+		case <-events:
+			s.compactor.Touch()
+		case <-recheck.C:
+			if served := rpc.ServedCalls(); served != calls {
+				calls = served
+				s.compactor.Touch()
+			}
+		case <-s.compactionSub.Err():
+			return
+		}
+	}
+}
+
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // Acent protocol.
 func (s *Acent) Stop() error {
@@ -541,12 +719,27 @@ func (s *Acent) Stop() error {
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
+	if s.callIndexer != nil {
+		s.callIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
+	if s.userOpPool != nil {
+		s.userOpPool.Stop()
+	}
 	s.miner.Stop()
 	s.blockchain.Stop()
 	s.engine.Close()
-	rawdb.PopUncleanShutdownMarker(s.chainDb)
+	if s.config.DatabaseCompaction {
+		s.compactionSub.Unsubscribe()
+		s.compactor.Stop()
+	}
+	if !s.config.ReadOnly {
+		rawdb.PopUncleanShutdownMarker(s.chainDb)
+	}
 	s.chainDb.Close()
 	s.eventMux.Stop()
 
@@ -0,0 +1,52 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that the chain config loaded for read-only nodes is validated just like
+// the one set up for writable nodes.
+func TestLoadChainConfig(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	if _, _, err := loadChainConfig(db); err != core.ErrNoGenesis {
+		t.Fatalf("missing genesis error mismatch: have %v, want %v", err, core.ErrNoGenesis)
+	}
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+
+	config, hash, err := loadChainConfig(db)
+	if err != nil {
+		t.Fatalf("failed to load chain config: %v", err)
+	}
+	if hash != genesis.Hash() || config.ChainID.Cmp(params.TestChainConfig.ChainID) != 0 {
+		t.Fatalf("loaded chain config mismatch: have %x %v", hash, config)
+	}
+	// Store a config with evm forks that can't be applied
+	invalid := *params.TestChainConfig
+	invalid.EVMForks = []*params.EVMFork{{Name: "bad", Block: big.NewInt(0), Disable: []string{"NOSUCHOP"}}}
+	rawdb.WriteChainConfig(db, genesis.Hash(), &invalid)
+
+	if _, _, err := loadChainConfig(db); err == nil {
+		t.Fatal("invalid evm forks accepted")
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package catalyst implements the engine API, letting an external consensus
+// client drive the Acent execution layer.
+package catalyst
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/eth"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/node"
+	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rpc"
+	"github.com/acent/go-acent/trie"
+)
+
+// maxTrackedPayloads is the number of recently built payloads kept around for
+// the consensus client to retrieve.
+const maxTrackedPayloads = 10
+
+// Register adds the engine API to the RPC APIs of the node. It is only exposed
+// on the JWT authenticated endpoint.
+func Register(stack *node.Node, backend *eth.Acent) error {
+	log.Warn("Engine API enabled, the chain head is chosen by the consensus client")
+	stack.RegisterAPIs([]rpc.API{
+		{
+			Namespace:     "engine",
+			Version:       "1.0",
+			Service:       NewConsensusAPI(backend),
+			Public:        true,
+			Authenticated: true,
+		},
+	})
+	return nil
+}
+
+// payload is a block built for the consensus client.
+type payload struct {
+	id   PayloadID
+	data *ExecutableData
+}
+
+// ConsensusAPI is the engine API, through which the consensus client imports
+// blocks, chooses the chain head and requests new blocks to be built.
+type ConsensusAPI struct {
+	eth *eth.Acent
+
+	lock     sync.Mutex
+	payloads []payload // Recently built payloads, oldest first
+}
+
+// NewConsensusAPI creates the engine API of the given Acent backend.
+func NewConsensusAPI(eth *eth.Acent) *ConsensusAPI {
+	return &ConsensusAPI{eth: eth}
+}
+
+// NewPayload validates and imports a block proposed by the consensus client.
+// The import follows the local fork choice rule, the head chosen by the consensus
+// client is only enforced by the following fork choice update.
+func (api *ConsensusAPI) NewPayload(data ExecutableData) (PayloadStatus, error) {
+	block, err := executableDataToBlock(data)
+	if err != nil {
+		return invalidStatus(StatusInvalidBlockHash, nil, err), nil
+	}
+	chain := api.eth.BlockChain()
+	if chain.HasBlock(block.Hash(), block.NumberU64()) {
+		return validStatus(block.Hash()), nil
+	}
+	parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return PayloadStatus{Status: StatusSyncing}, nil
+	}
+	if !chain.HasBlockAndState(parent.Hash(), parent.NumberU64()) {
+		return PayloadStatus{Status: StatusAccepted}, nil
+	}
+	if _, err := chain.InsertBlockWithoutSealVerification(block); err != nil {
+		log.Warn("Invalid payload", "number", block.Number(), "hash", block.Hash(), "err", err)
+		hash := parent.Hash()
+		return invalidStatus(StatusInvalid, &hash, err), nil
+	}
+	return validStatus(block.Hash()), nil
+}
+
+// ForkchoiceUpdated makes the given head block the head of the canonical chain
+// and, if attributes are given, starts building a payload on top of it.
+func (api *ConsensusAPI) ForkchoiceUpdated(state ForkchoiceState, attrs *PayloadAttributes) (ForkChoiceResponse, error) {
+	chain := api.eth.BlockChain()
+
+	head := chain.GetBlockByHash(state.HeadBlockHash)
+	if head == nil {
+		return ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: StatusSyncing}}, nil
+	}
+	// Choosing an ancestor of the current head is a no-op
+	if chain.GetCanonicalHash(head.NumberU64()) != head.Hash() {
+		if err := chain.SetCanonical(head); err != nil {
+			return ForkChoiceResponse{}, errInvalidForkchoiceState.withReason("%v", err)
+		}
+	}
+	// The safe and finalized blocks must be canonical ancestors of the head
+	var markers [2]*types.Header
+	for i, hash := range []common.Hash{state.SafeBlockHash, state.FinalizedBlockHash} {
+		if hash == (common.Hash{}) {
+			continue
+		}
+		block := chain.GetBlockByHash(hash)
+		if block == nil || block.NumberU64() > head.NumberU64() || chain.GetCanonicalHash(block.NumberU64()) != hash {
+			return ForkChoiceResponse{}, errInvalidForkchoiceState.withReason("block %x is not a canonical ancestor of the head", hash)
+		}
+		markers[i] = block.Header()
+	}
+	api.eth.Finality().SetMarkers(markers[0], markers[1])
+
+	resp := ForkChoiceResponse{PayloadStatus: validStatus(head.Hash())}
+	if attrs == nil {
+		return resp, nil
+	}
+	if uint64(attrs.Timestamp) <= head.Time() {
+		return ForkChoiceResponse{}, errInvalidPayloadAttributes.withReason("timestamp %d not after head timestamp %d", attrs.Timestamp, head.Time())
+	}
+	block, err := api.assembleBlock(head, attrs)
+	if err != nil {
+		return ForkChoiceResponse{}, err
+	}
+	id := computePayloadID(head.Hash(), attrs)
+	api.trackPayload(id, blockToExecutableData(block))
+
+	resp.PayloadID = &id
+	return resp, nil
+}
+
+// GetPayload returns a payload previously built by a fork choice update.
+func (api *ConsensusAPI) GetPayload(id PayloadID) (*ExecutableData, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	for _, payload := range api.payloads {
+		if payload.id == id {
+			return payload.data, nil
+		}
+	}
+	return nil, errUnknownPayload
+}
+
+// trackPayload stores a built payload, dropping the oldest one if too many are
+// tracked already.
+func (api *ConsensusAPI) trackPayload(id PayloadID, data *ExecutableData) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	for i, payload := range api.payloads {
+		if payload.id == id {
+			api.payloads[i].data = data
+			return
+		}
+	}
+	if len(api.payloads) >= maxTrackedPayloads {
+		api.payloads = api.payloads[1:]
+	}
+	api.payloads = append(api.payloads, payload{id: id, data: data})
+}
+
+// assembleBlock builds a block on top of the given parent with the pending
+// transactions of the pool.
+func (api *ConsensusAPI) assembleBlock(parent *types.Block, attrs *PayloadAttributes) (*types.Block, error) {
+	var (
+		chain  = api.eth.BlockChain()
+		config = chain.Config()
+	)
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Coinbase:   attrs.SuggestedFeeRecipient,
+		GasLimit:   core.CalcGasLimit(parent, parent.GasLimit(), parent.GasLimit()),
+		Time:       uint64(attrs.Timestamp),
+		MixDigest:  attrs.Random,
+	}
+	if err := api.eth.Engine().Prepare(chain, header); err != nil {
+		return nil, fmt.Errorf("failed to prepare header: %v", err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve parent state: %v", err)
+	}
+	pending, err := api.eth.TxPool().Pending()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer   = types.MakeSigner(config, header.Number)
+		txs      = types.NewTransactionsByPriceAndNonce(signer, pending)
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+		included types.Transactions
+		receipts []*types.Receipt
+	)
+	for {
+		tx := txs.Peek()
+		if tx == nil || gasPool.Gas() < params.TxGas {
+			break
+		}
+		if tx.Protected() && !config.IsEIP155(header.Number) {
+			txs.Pop()
+			continue
+		}
+		snap := statedb.Snapshot()
+		statedb.Prepare(tx.Hash(), common.Hash{}, len(included))
+
+		receipt, err := core.ApplyTransaction(config, chain, &header.Coinbase, gasPool, statedb, header, tx, &header.GasUsed, *chain.GetVMConfig())
+		switch {
+		case errors.Is(err, core.ErrNonceTooLow):
+			statedb.RevertToSnapshot(snap)
+			txs.Shift()
+		case err != nil:
+			// Skip the rest of the sender's transactions, they depend on this one
+			statedb.RevertToSnapshot(snap)
+			txs.Pop()
+		default:
+			included = append(included, tx)
+			receipts = append(receipts, receipt)
+			txs.Shift()
+		}
+	}
+	return api.eth.Engine().FinalizeAndAssemble(chain, header, statedb, included, nil, receipts)
+}
+
+// computePayloadID derives the identifier of the payload built with the given
+// attributes on top of the given head.
+func computePayloadID(head common.Hash, attrs *PayloadAttributes) PayloadID {
+	hasher := sha256.New()
+	hasher.Write(head[:])
+	binary.Write(hasher, binary.BigEndian, uint64(attrs.Timestamp))
+	hasher.Write(attrs.Random[:])
+	hasher.Write(attrs.SuggestedFeeRecipient[:])
+
+	var id PayloadID
+	copy(id[:], hasher.Sum(nil))
+	return id
+}
+
+// executableDataToBlock reconstructs a block from a payload, verifying that it
+// matches the hash claimed by the consensus client.
+func executableDataToBlock(data ExecutableData) (*types.Block, error) {
+	txs := make([]*types.Transaction, len(data.Transactions))
+	for i, enc := range data.Transactions {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	if len(data.LogsBloom) != types.BloomByteLength {
+		return nil, fmt.Errorf("invalid logs bloom length %d", len(data.LogsBloom))
+	}
+	if data.Difficulty == nil {
+		return nil, errors.New("missing difficulty")
+	}
+	header := &types.Header{
+		ParentHash:  data.ParentHash,
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    data.FeeRecipient,
+		Root:        data.StateRoot,
+		TxHash:      types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil)),
+		ReceiptHash: data.ReceiptsRoot,
+		Bloom:       types.BytesToBloom(data.LogsBloom),
+		Difficulty:  (*big.Int)(data.Difficulty),
+		Number:      new(big.Int).SetUint64(uint64(data.Number)),
+		GasLimit:    uint64(data.GasLimit),
+		GasUsed:     uint64(data.GasUsed),
+		Time:        uint64(data.Timestamp),
+		Extra:       data.ExtraData,
+		MixDigest:   data.Random,
+	}
+	block := types.NewBlockWithHeader(header).WithBody(txs, nil)
+	if block.Hash() != data.BlockHash {
+		return nil, fmt.Errorf("blockhash mismatch, want %x, got %x", data.BlockHash, block.Hash())
+	}
+	return block, nil
+}
+
+// blockToExecutableData converts a block into the payload sent to the consensus
+// client.
+func blockToExecutableData(block *types.Block) *ExecutableData {
+	txs := make([]hexutil.Bytes, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		enc, _ := tx.MarshalBinary()
+		txs = append(txs, enc)
+	}
+	return &ExecutableData{
+		ParentHash:   block.ParentHash(),
+		FeeRecipient: block.Coinbase(),
+		StateRoot:    block.Root(),
+		ReceiptsRoot: block.ReceiptHash(),
+		LogsBloom:    block.Bloom().Bytes(),
+		Random:       block.MixDigest(),
+		Number:       hexutil.Uint64(block.NumberU64()),
+		GasLimit:     hexutil.Uint64(block.GasLimit()),
+		GasUsed:      hexutil.Uint64(block.GasUsed()),
+		Timestamp:    hexutil.Uint64(block.Time()),
+		ExtraData:    block.Extra(),
+		Difficulty:   (*hexutil.Big)(block.Difficulty()),
+		BlockHash:    block.Hash(),
+		Transactions: txs,
+	}
+}
+
+// validStatus returns the status of a valid payload or fork choice.
+func validStatus(hash common.Hash) PayloadStatus {
+	return PayloadStatus{Status: StatusValid, LatestValidHash: &hash}
+}
+
+// invalidStatus returns the status of a payload failing validation.
+func invalidStatus(status string, latestValid *common.Hash, err error) PayloadStatus {
+	msg := err.Error()
+	return PayloadStatus{Status: status, LatestValidHash: latestValid, ValidationError: &msg}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/eth"
+	"github.com/acent/go-acent/eth/ethconfig"
+	"github.com/acent/go-acent/node"
+	"github.com/acent/go-acent/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(2e18)
+)
+
+func generateTestChain(n int) (*core.Genesis, []*types.Block) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := &core.Genesis{
+		Config:    params.AllEthashProtocolChanges,
+		Alloc:     core.GenesisAlloc{testAddr: {Balance: testBalance}},
+		ExtraData: []byte("test genesis"),
+		Timestamp: 9000,
+	}
+	gblock := genesis.ToBlock(db)
+	blocks, _ := core.GenerateChain(genesis.Config, gblock, ethash.NewFaker(), db, n, nil)
+	return genesis, blocks
+}
+
+func startEthService(t *testing.T, genesis *core.Genesis, blocks []*types.Block) (*node.Node, *eth.Acent) {
+	t.Helper()
+
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create node: %v", err)
+	}
+	config := &ethconfig.Config{Genesis: genesis}
+	config.Ethash.PowMode = ethash.ModeFake
+	ethservice, err := eth.New(n, config)
+	if err != nil {
+		t.Fatalf("can't create acent service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start node: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		n.Close()
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	return n, ethservice
+}
+
+func TestEngineAPI(t *testing.T) {
+	genesis, blocks := generateTestChain(12)
+	n, ethservice := startEthService(t, genesis, blocks[:10])
+	defer n.Close()
+
+	var (
+		api   = NewConsensusAPI(ethservice)
+		chain = ethservice.BlockChain()
+		head  = chain.CurrentBlock()
+	)
+	// Build a payload including a pooled transaction on top of the head
+	signer := types.LatestSigner(genesis.Config)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(params.GWei), nil), signer, testKey)
+	if err := ethservice.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	attrs := &PayloadAttributes{Timestamp: hexutil.Uint64(head.Time() + 5), SuggestedFeeRecipient: common.Address{0xfe}}
+	resp, err := api.ForkchoiceUpdated(ForkchoiceState{HeadBlockHash: head.Hash()}, attrs)
+	if err != nil {
+		t.Fatalf("failed to update fork choice: %v", err)
+	}
+	if resp.PayloadStatus.Status != StatusValid || resp.PayloadID == nil {
+		t.Fatalf("unexpected fork choice response: %+v", resp)
+	}
+	payload, err := api.GetPayload(*resp.PayloadID)
+	if err != nil {
+		t.Fatalf("failed to retrieve payload: %v", err)
+	}
+	if len(payload.Transactions) != 1 || payload.FeeRecipient != attrs.SuggestedFeeRecipient || uint64(payload.Number) != head.NumberU64()+1 {
+		t.Fatalf("unexpected payload: %d txs, recipient %x, number %d", len(payload.Transactions), payload.FeeRecipient, payload.Number)
+	}
+	if _, err := api.GetPayload(PayloadID{0x01}); err != errUnknownPayload {
+		t.Errorf("unknown payload: have error %v, want %v", err, errUnknownPayload)
+	}
+	// Tampered payloads must be rejected, valid ones imported
+	tampered := *payload
+	tampered.GasUsed++
+	if status, _ := api.NewPayload(tampered); status.Status != StatusInvalidBlockHash {
+		t.Errorf("tampered payload: have status %s, want %s", status.Status, StatusInvalidBlockHash)
+	}
+	if status, err := api.NewPayload(*payload); err != nil || status.Status != StatusValid {
+		t.Fatalf("failed to import payload: status %+v, err %v", status, err)
+	}
+	// Blocks with unknown ancestry require syncing
+	if status, _ := api.NewPayload(*blockToExecutableData(blocks[11])); status.Status != StatusSyncing {
+		t.Errorf("orphan payload: have status %s, want %s", status.Status, StatusSyncing)
+	}
+	// Fork choice is enforced regardless of the total difficulty: build a side
+	// block on an older ancestor and make it the head
+	ancestor := blocks[4]
+	attrs = &PayloadAttributes{Timestamp: hexutil.Uint64(ancestor.Time() + 1)}
+	if _, err := api.ForkchoiceUpdated(ForkchoiceState{HeadBlockHash: ancestor.Hash()}, attrs); err != nil {
+		t.Fatalf("failed to update fork choice to ancestor: %v", err)
+	}
+	if chain.CurrentBlock().Hash() != payload.BlockHash {
+		t.Fatalf("ancestor fork choice moved the head")
+	}
+	resp, err = api.ForkchoiceUpdated(ForkchoiceState{HeadBlockHash: ancestor.Hash()}, attrs)
+	if err != nil {
+		t.Fatalf("failed to update fork choice: %v", err)
+	}
+	side, _ := api.GetPayload(*resp.PayloadID)
+	if status, err := api.NewPayload(*side); err != nil || status.Status != StatusValid {
+		t.Fatalf("failed to import side payload: status %+v, err %v", status, err)
+	}
+	state := ForkchoiceState{HeadBlockHash: side.BlockHash, SafeBlockHash: blocks[3].Hash(), FinalizedBlockHash: blocks[2].Hash()}
+	if _, err := api.ForkchoiceUpdated(state, nil); err != nil {
+		t.Fatalf("failed to reorg to side payload: %v", err)
+	}
+	if chain.CurrentBlock().Hash() != side.BlockHash {
+		t.Fatalf("head not updated: have %x, want %x", chain.CurrentBlock().Hash(), side.BlockHash)
+	}
+	finality := ethservice.Finality()
+	if safe := finality.SafeHeader(); safe == nil || safe.Hash() != blocks[3].Hash() {
+		t.Errorf("safe block not recorded: have %v, want %x", safe, blocks[3].Hash())
+	}
+	if finalized := finality.FinalizedHeader(); finalized == nil || finalized.Hash() != blocks[2].Hash() {
+		t.Errorf("finalized block not recorded: have %v, want %x", finalized, blocks[2].Hash())
+	}
+	// Finalized blocks must be canonical and payloads must advance time
+	state.FinalizedBlockHash = payload.BlockHash
+	if _, err := api.ForkchoiceUpdated(state, nil); err == nil {
+		t.Error("non-canonical finalized block accepted")
+	}
+	state.FinalizedBlockHash = common.Hash{}
+	attrs.Timestamp = hexutil.Uint64(ancestor.Time())
+	if _, err := api.ForkchoiceUpdated(state, attrs); err == nil {
+		t.Error("payload attributes with stale timestamp accepted")
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+)
+
+// Payload statuses reported to the consensus client.
+const (
+	StatusValid            = "VALID"              // Payload was fully validated
+	StatusInvalid          = "INVALID"            // Payload failed validation
+	StatusSyncing          = "SYNCING"            // Ancestors of the payload are missing
+	StatusAccepted         = "ACCEPTED"           // Payload is well formed, but its parent state is missing
+	StatusInvalidBlockHash = "INVALID_BLOCK_HASH" // Payload hash doesn't match its contents
+)
+
+// PayloadAttributes are the attributes of a payload the consensus client asks
+// to be built on top of the new head.
+type PayloadAttributes struct {
+	Timestamp             hexutil.Uint64 `json:"timestamp"`
+	Random                common.Hash    `json:"random"`
+	SuggestedFeeRecipient common.Address `json:"suggestedFeeRecipient"`
+}
+
+// ExecutableData is a block as exchanged with the consensus client.
+type ExecutableData struct {
+	ParentHash   common.Hash     `json:"parentHash"`
+	FeeRecipient common.Address  `json:"feeRecipient"`
+	StateRoot    common.Hash     `json:"stateRoot"`
+	ReceiptsRoot common.Hash     `json:"receiptsRoot"`
+	LogsBloom    hexutil.Bytes   `json:"logsBloom"`
+	Random       common.Hash     `json:"random"`
+	Number       hexutil.Uint64  `json:"blockNumber"`
+	GasLimit     hexutil.Uint64  `json:"gasLimit"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Timestamp    hexutil.Uint64  `json:"timestamp"`
+	ExtraData    hexutil.Bytes   `json:"extraData"`
+	Difficulty   *hexutil.Big    `json:"difficulty"` // Required by the local engine to verify the header
+	BlockHash    common.Hash     `json:"blockHash"`
+	Transactions []hexutil.Bytes `json:"transactions"`
+}
+
+// ForkchoiceState is the head, safe and finalized block chosen by the consensus
+// client. The safe and finalized hashes may be zero if not yet known.
+type ForkchoiceState struct {
+	HeadBlockHash      common.Hash `json:"headBlockHash"`
+	SafeBlockHash      common.Hash `json:"safeBlockHash"`
+	FinalizedBlockHash common.Hash `json:"finalizedBlockHash"`
+}
+
+// PayloadStatus is the result of validating a payload or a fork choice.
+type PayloadStatus struct {
+	Status          string       `json:"status"`
+	LatestValidHash *common.Hash `json:"latestValidHash"`
+	ValidationError *string      `json:"validationError"`
+}
+
+// ForkChoiceResponse is the result of a fork choice update, identifying the
+// payload being built if requested.
+type ForkChoiceResponse struct {
+	PayloadStatus PayloadStatus `json:"payloadStatus"`
+	PayloadID     *PayloadID    `json:"payloadId"`
+}
+
+// PayloadID identifies a payload being built for the consensus client.
+type PayloadID [8]byte
+
+// String implements fmt.Stringer.
+func (id PayloadID) String() string {
+	return hexutil.Encode(id[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id PayloadID) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(id[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *PayloadID) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("PayloadID", input, id[:])
+}
+
+// engineError is an RPC error with one of the codes defined by the engine API.
+type engineError struct {
+	code    int
+	message string
+}
+
+func (e *engineError) Error() string  { return e.message }
+func (e *engineError) ErrorCode() int { return e.code }
+
+var (
+	errUnknownPayload           = &engineError{code: -38001, message: "Unknown payload"}
+	errInvalidForkchoiceState   = &engineError{code: -38002, message: "Invalid forkchoice state"}
+	errInvalidPayloadAttributes = &engineError{code: -38003, message: "Invalid payload attributes"}
+)
+
+// withReason returns a copy of the error carrying the detailed reason.
+func (e *engineError) withReason(format string, args ...interface{}) *engineError {
+	return &engineError{code: e.code, message: e.message + ": " + fmt.Sprintf(format, args...)}
+}
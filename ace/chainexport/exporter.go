@@ -0,0 +1,328 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package chainexport writes the chain data to Parquet files for analytics.
+//
+// The blocks, transactions, logs and optionally the call traces of the chain
+// are exported into a dataset directory each, as files covering consecutive
+// block ranges. Files never straddle the boundaries of the configured
+// partition size, so a dataset can be reprocessed partition by partition. The
+// progress of the export is checkpointed after every file set, allowing an
+// interrupted or extended export to resume where it stopped:
+//
+//	<dir>/v1/checkpoint.json
+//	<dir>/v1/blocks/000000000000-000000009999.parquet
+//	<dir>/v1/transactions/000000000000-000000009999.parquet
+//	<dir>/v1/logs/000000000000-000000009999.parquet
+//	<dir>/v1/traces/000000000000-000000009999.parquet
+package chainexport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/params"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// DefaultPartitionSize is the default number of blocks per partition.
+const DefaultPartitionSize = 10000
+
+// schemaVersionKey is the key of the schema version in the metadata of the
+// exported files.
+const schemaVersionKey = "acent.schema.version"
+
+var (
+	errConfigMismatch = errors.New("export configuration differs from the checkpoint")
+	errExportGap      = errors.New("export would leave a gap after the checkpoint")
+)
+
+// Chain is the chain data source of the exporter, satisfied by core.BlockChain.
+// The state access is only needed to export traces.
+type Chain interface {
+	core.ChainContext
+
+	Config() *params.ChainConfig
+	GetBlockByNumber(number uint64) *types.Block
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// Config are the settings of the exporter.
+type Config struct {
+	Dir           string // Root directory of the datasets
+	PartitionSize uint64 // Number of blocks per partition
+	Traces        bool   // Whether to export call traces, re-executing the blocks
+}
+
+// checkpoint is the export progress persisted in the schema directory.
+type checkpoint struct {
+	Version       int    `json:"version"`
+	PartitionSize uint64 `json:"partitionSize"`
+	Traces        bool   `json:"traces"`
+	Next          uint64 `json:"next"` // First block not exported yet
+}
+
+// Exporter writes chain segments to the Parquet datasets.
+type Exporter struct {
+	chain  Chain
+	config Config
+	dir    string      // Directory of the current schema version
+	cp     *checkpoint // Export progress, nil if nothing was exported yet
+}
+
+// New creates an exporter into the configured directory, loading the
+// checkpoint of an earlier export if there is one.
+func New(chain Chain, config Config) (*Exporter, error) {
+	if config.PartitionSize == 0 {
+		config.PartitionSize = DefaultPartitionSize
+	}
+	e := &Exporter{
+		chain:  chain,
+		config: config,
+		dir:    filepath.Join(config.Dir, fmt.Sprintf("v%d", SchemaVersion)),
+	}
+	blob, err := ioutil.ReadFile(e.checkpointPath())
+	switch {
+	case os.IsNotExist(err):
+		return e, nil
+	case err != nil:
+		return nil, err
+	}
+	cp := new(checkpoint)
+	if err := json.Unmarshal(blob, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	if cp.Version != SchemaVersion || cp.PartitionSize != config.PartitionSize || cp.Traces != config.Traces {
+		return nil, fmt.Errorf("%w: version %d, partition size %d, traces %v", errConfigMismatch, cp.Version, cp.PartitionSize, cp.Traces)
+	}
+	e.cp = cp
+	return e, nil
+}
+
+// Next returns the first block not exported yet, and whether there's a
+// checkpoint at all.
+func (e *Exporter) Next() (uint64, bool) {
+	if e.cp == nil {
+		return 0, false
+	}
+	return e.cp.Next, true
+}
+
+// Export writes the blocks up to and including last, starting at first or
+// resuming after the checkpoint if there is one. Blocks before the checkpoint
+// are never exported twice.
+func (e *Exporter) Export(first, last uint64) error {
+	if e.cp != nil {
+		if first > e.cp.Next {
+			return fmt.Errorf("%w: first block %d, checkpoint %d", errExportGap, first, e.cp.Next)
+		}
+		first = e.cp.Next
+	}
+	if first > last {
+		log.Info("Nothing to export", "next", first, "last", last)
+		return nil
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+	)
+	for from := first; from <= last; {
+		to := (from/e.config.PartitionSize+1)*e.config.PartitionSize - 1
+		if to > last {
+			to = last
+		}
+		if err := e.exportRange(from, to); err != nil {
+			return err
+		}
+		if time.Since(reported) >= 8*time.Second || to == last {
+			log.Info("Exporting chain data", "exported", to-first+1, "next", to+1, "last", last, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+		from = to + 1
+	}
+	return nil
+}
+
+// exportRange writes the files of a block range, then moves the checkpoint
+// past it.
+func (e *Exporter) exportRange(from, to uint64) error {
+	datasets := []string{blocksDataset, transactionsDataset, logsDataset}
+	if e.config.Traces {
+		datasets = append(datasets, tracesDataset)
+	}
+	rows := map[string]interface{}{
+		blocksDataset:       new(blockRow),
+		transactionsDataset: new(transactionRow),
+		logsDataset:         new(logRow),
+		tracesDataset:       new(traceRow),
+	}
+	files := make(map[string]*rangeFile)
+	defer func() {
+		for _, f := range files {
+			f.abort()
+		}
+	}()
+	for _, name := range datasets {
+		// Remove any file left over by an interrupted export of the range
+		dir := filepath.Join(e.dir, name)
+		stale, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%012d-*.parquet", from)))
+		for _, path := range stale {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		f, err := createRangeFile(dir, from, to, rows[name])
+		if err != nil {
+			return err
+		}
+		files[name] = f
+	}
+	for number := from; number <= to; number++ {
+		block := e.chain.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block %d not found", number)
+		}
+		receipts := e.chain.GetReceiptsByHash(block.Hash())
+
+		if err := files[blocksDataset].write(newBlockRow(block)); err != nil {
+			return err
+		}
+		txs, err := newTransactionRows(e.chain.Config(), block, receipts)
+		if err != nil {
+			return err
+		}
+		for _, row := range txs {
+			if err := files[transactionsDataset].write(row); err != nil {
+				return err
+			}
+		}
+		for _, row := range newLogRows(block, receipts) {
+			if err := files[logsDataset].write(row); err != nil {
+				return err
+			}
+		}
+		if e.config.Traces {
+			traces, err := traceBlock(e.chain, block)
+			if err != nil {
+				return err
+			}
+			for _, row := range traces {
+				if err := files[tracesDataset].write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, name := range datasets {
+		if err := files[name].commit(); err != nil {
+			return err
+		}
+		delete(files, name)
+	}
+	return e.writeCheckpoint(to + 1)
+}
+
+func (e *Exporter) checkpointPath() string {
+	return filepath.Join(e.dir, "checkpoint.json")
+}
+
+// writeCheckpoint atomically persists the export progress.
+func (e *Exporter) writeCheckpoint(next uint64) error {
+	cp := &checkpoint{
+		Version:       SchemaVersion,
+		PartitionSize: e.config.PartitionSize,
+		Traces:        e.config.Traces,
+		Next:          next,
+	}
+	blob, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.checkpointPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, blob, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, e.checkpointPath()); err != nil {
+		return err
+	}
+	e.cp = cp
+	return nil
+}
+
+// rangeFile is a Parquet file of a block range being written. It's created
+// under a temporary name and only renamed once complete.
+type rangeFile struct {
+	path string
+	file *os.File
+	pw   *writer.ParquetWriter
+}
+
+func createRangeFile(dir string, from, to uint64, row interface{}) (*rangeFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%012d-%012d.parquet", from, to))
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	pw, err := writer.NewParquetWriterFromWriter(file, row, 1)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	version := strconv.Itoa(SchemaVersion)
+	pw.Footer.KeyValueMetadata = []*parquet.KeyValue{{Key: schemaVersionKey, Value: &version}}
+	return &rangeFile{path: path, file: file, pw: pw}, nil
+}
+
+func (f *rangeFile) write(row interface{}) error {
+	return f.pw.Write(row)
+}
+
+// commit finishes the file and moves it to its final name.
+func (f *rangeFile) commit() error {
+	if err := f.pw.WriteStop(); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.file.Name(), f.path)
+}
+
+// abort drops the incomplete file.
+func (f *rangeFile) abort() {
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
@@ -0,0 +1,238 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package chainexport
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testFunds   = big.NewInt(1000000000000000000)
+
+	// logInitCode is a contract creation code emitting an empty log and
+	// deploying nothing: PUSH1 0 PUSH1 0 LOG0 STOP.
+	logInitCode = common.FromHex("60006000a000")
+)
+
+// newTestChain creates an archive chain of n blocks, each holding a value
+// transfer and a contract creation emitting a log.
+func newTestChain(t *testing.T, n int) *core.BlockChain {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{testAddress: {Balance: testFunds}}}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	genesis := gspec.MustCommit(db)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, n, func(i int, b *core.BlockGen) {
+		transfer, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		b.AddTx(transfer)
+		create, _ := types.SignTx(types.NewContractCreation(b.TxNonce(testAddress), new(big.Int), 100000, big.NewInt(1), logInitCode), signer, testKey)
+		b.AddTx(create)
+	})
+	chaindb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(chaindb)
+	chain, err := core.NewBlockChain(chaindb, &core.CacheConfig{TrieDirtyDisabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	return chain
+}
+
+// datasetFiles returns the names of the files of a dataset.
+func datasetFiles(t *testing.T, dir, dataset string) []string {
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "v1", dataset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// countRows returns the total number of rows in the files of a dataset.
+func countRows(t *testing.T, dir, dataset string, row interface{}) int64 {
+	var rows int64
+	for _, name := range datasetFiles(t, dir, dataset) {
+		pr := openDatasetFile(t, filepath.Join(dir, "v1", dataset, name), row)
+		rows += pr.GetNumRows()
+		pr.ReadStop()
+		pr.PFile.Close()
+	}
+	return rows
+}
+
+func openDatasetFile(t *testing.T, path string, row interface{}) *reader.ParquetReader {
+	file, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, row, 1)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return pr
+}
+
+func TestExportResume(t *testing.T) {
+	chain := newTestChain(t, 9)
+	defer chain.Stop()
+
+	dir, err := ioutil.TempDir("", "chainexport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Export part of the chain, then resume up to the head in a new exporter
+	config := Config{Dir: dir, PartitionSize: 4, Traces: true}
+	exporter, err := New(chain, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Export(0, 5); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if exporter, err = New(chain, config); err != nil {
+		t.Fatal(err)
+	}
+	if next, ok := exporter.Next(); !ok || next != 6 {
+		t.Fatalf("wrong checkpoint: have %d (%v), want 6", next, ok)
+	}
+	if err := exporter.Export(0, 9); err != nil {
+		t.Fatalf("resumed export failed: %v", err)
+	}
+	want := []string{
+		"000000000000-000000000003.parquet",
+		"000000000004-000000000005.parquet",
+		"000000000006-000000000007.parquet",
+		"000000000008-000000000009.parquet",
+	}
+	for _, dataset := range []string{blocksDataset, transactionsDataset, logsDataset, tracesDataset} {
+		if have := datasetFiles(t, dir, dataset); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: wrong files: have %v, want %v", dataset, have, want)
+		}
+	}
+	// Every block but the genesis holds two transactions, a log and two calls
+	if rows := countRows(t, dir, blocksDataset, new(blockRow)); rows != 10 {
+		t.Errorf("wrong number of blocks: have %d, want 10", rows)
+	}
+	pr := openDatasetFile(t, filepath.Join(dir, "v1", blocksDataset, want[1]), new(blockRow))
+	blocks := make([]blockRow, pr.GetNumRows())
+	if err := pr.Read(&blocks); err != nil {
+		t.Fatalf("failed to read blocks: %v", err)
+	}
+	pr.ReadStop()
+	pr.PFile.Close()
+	for i, block := range blocks {
+		if hash := chain.GetBlockByNumber(uint64(4 + i)).Hash().Hex(); block.Number != int64(4+i) || block.Hash != hash {
+			t.Errorf("wrong block row %d: have %d %s, want %d %s", i, block.Number, block.Hash, 4+i, hash)
+		}
+	}
+	if rows := countRows(t, dir, transactionsDataset, new(transactionRow)); rows != 18 {
+		t.Errorf("wrong number of transactions: have %d, want 18", rows)
+	}
+	if rows := countRows(t, dir, logsDataset, new(logRow)); rows != 9 {
+		t.Errorf("wrong number of logs: have %d, want 9", rows)
+	}
+	if rows := countRows(t, dir, tracesDataset, new(traceRow)); rows != 18 {
+		t.Errorf("wrong number of traces: have %d, want 18", rows)
+	}
+}
+
+func TestExportCheckpointMismatch(t *testing.T) {
+	chain := newTestChain(t, 2)
+	defer chain.Stop()
+
+	dir, err := ioutil.TempDir("", "chainexport-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exporter, err := New(chain, Config{Dir: dir, PartitionSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Export(0, 2); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if _, err := New(chain, Config{Dir: dir, PartitionSize: 8}); !errors.Is(err, errConfigMismatch) {
+		t.Errorf("wrong error for changed partition size: %v", err)
+	}
+	if _, err := New(chain, Config{Dir: dir, PartitionSize: 4, Traces: true}); !errors.Is(err, errConfigMismatch) {
+		t.Errorf("wrong error for enabled traces: %v", err)
+	}
+	if err := exporter.Export(5, 5); !errors.Is(err, errExportGap) {
+		t.Errorf("wrong error for gap after checkpoint: %v", err)
+	}
+}
+
+func TestFlattenCalls(t *testing.T) {
+	frame := &callFrame{
+		Type: "CALL",
+		Calls: []callFrame{
+			{Type: "STATICCALL", Calls: []callFrame{{Type: "CALL", Error: "out of gas"}}},
+			{Type: "DELEGATECALL"},
+		},
+	}
+	rows := flattenCalls(nil, frame, nil, traceRow{BlockNumber: 1})
+
+	want := []struct {
+		address, callType string
+		depth             int32
+	}{
+		{"", "call", 0},
+		{"0", "staticcall", 1},
+		{"0.0", "call", 2},
+		{"1", "delegatecall", 1},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("wrong number of rows: have %d, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row.TraceAddress != want[i].address || row.CallType != want[i].callType || row.Depth != want[i].depth || row.BlockNumber != 1 {
+			t.Errorf("row %d: have %+v, want %+v", i, row, want[i])
+		}
+	}
+	if rows[2].Error == nil || *rows[2].Error != "out of gas" {
+		t.Errorf("error not exported: %+v", rows[2])
+	}
+}
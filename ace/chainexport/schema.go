@@ -0,0 +1,254 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package chainexport
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/params"
+)
+
+// SchemaVersion is the version of the exported table schemas. It must be bumped
+// on every incompatible change of the row types below, so that the files of
+// different schemas are never mixed in the same dataset.
+const SchemaVersion = 1
+
+// Names of the exported datasets, each stored in its own directory.
+const (
+	blocksDataset       = "blocks"
+	transactionsDataset = "transactions"
+	logsDataset         = "logs"
+	tracesDataset       = "traces"
+)
+
+// Hashes, addresses and byte blobs are exported as 0x-prefixed hex strings and
+// big integers as decimal strings, which every analytics engine can cast.
+
+// blockRow is the row type of the blocks dataset.
+type blockRow struct {
+	Number           int64  `parquet:"name=number, type=INT64, convertedtype=UINT_64"`
+	Hash             string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ParentHash       string `parquet:"name=parent_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Timestamp        int64  `parquet:"name=timestamp, type=INT64, convertedtype=UINT_64"`
+	Miner            string `parquet:"name=miner, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Difficulty       string `parquet:"name=difficulty, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GasLimit         int64  `parquet:"name=gas_limit, type=INT64, convertedtype=UINT_64"`
+	GasUsed          int64  `parquet:"name=gas_used, type=INT64, convertedtype=UINT_64"`
+	ExtraData        string `parquet:"name=extra_data, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Size             int64  `parquet:"name=size, type=INT64, convertedtype=UINT_64"`
+	StateRoot        string `parquet:"name=state_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TransactionsRoot string `parquet:"name=transactions_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ReceiptsRoot     string `parquet:"name=receipts_root, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TransactionCount int32  `parquet:"name=transaction_count, type=INT32, convertedtype=UINT_32"`
+	UncleCount       int32  `parquet:"name=uncle_count, type=INT32, convertedtype=UINT_32"`
+}
+
+// transactionRow is the row type of the transactions dataset, merging each
+// transaction with its receipt.
+type transactionRow struct {
+	BlockNumber       int64   `parquet:"name=block_number, type=INT64, convertedtype=UINT_64"`
+	BlockHash         string  `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Index             int32   `parquet:"name=transaction_index, type=INT32, convertedtype=UINT_32"`
+	Hash              string  `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type              int32   `parquet:"name=type, type=INT32, convertedtype=UINT_8"`
+	From              string  `parquet:"name=from_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	To                *string `parquet:"name=to_address, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Value             string  `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Nonce             int64   `parquet:"name=nonce, type=INT64, convertedtype=UINT_64"`
+	Gas               int64   `parquet:"name=gas, type=INT64, convertedtype=UINT_64"`
+	GasPrice          string  `parquet:"name=gas_price, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Input             string  `parquet:"name=input, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status            int64   `parquet:"name=status, type=INT64, convertedtype=UINT_64"`
+	GasUsed           int64   `parquet:"name=gas_used, type=INT64, convertedtype=UINT_64"`
+	CumulativeGasUsed int64   `parquet:"name=cumulative_gas_used, type=INT64, convertedtype=UINT_64"`
+	ContractAddress   *string `parquet:"name=contract_address, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	LogCount          int32   `parquet:"name=log_count, type=INT32, convertedtype=UINT_32"`
+}
+
+// logRow is the row type of the logs dataset.
+type logRow struct {
+	BlockNumber      int64    `parquet:"name=block_number, type=INT64, convertedtype=UINT_64"`
+	BlockHash        string   `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TransactionIndex int32    `parquet:"name=transaction_index, type=INT32, convertedtype=UINT_32"`
+	TransactionHash  string   `parquet:"name=transaction_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Index            int32    `parquet:"name=log_index, type=INT32, convertedtype=UINT_32"`
+	Address          string   `parquet:"name=address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Topics           []string `parquet:"name=topics, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Data             string   `parquet:"name=data, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// traceRow is the row type of the traces dataset, holding one call frame of a
+// transaction. The trace address is the dot separated path of the frame in
+// the call tree, empty for the top level call.
+type traceRow struct {
+	BlockNumber      int64   `parquet:"name=block_number, type=INT64, convertedtype=UINT_64"`
+	BlockHash        string  `parquet:"name=block_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TransactionIndex int32   `parquet:"name=transaction_index, type=INT32, convertedtype=UINT_32"`
+	TransactionHash  string  `parquet:"name=transaction_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TraceAddress     string  `parquet:"name=trace_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Depth            int32   `parquet:"name=depth, type=INT32, convertedtype=UINT_32"`
+	CallType         string  `parquet:"name=call_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	From             string  `parquet:"name=from_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	To               string  `parquet:"name=to_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Value            string  `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Gas              int64   `parquet:"name=gas, type=INT64, convertedtype=UINT_64"`
+	GasUsed          int64   `parquet:"name=gas_used, type=INT64, convertedtype=UINT_64"`
+	Input            string  `parquet:"name=input, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Output           string  `parquet:"name=output, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Error            *string `parquet:"name=error, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+// newBlockRow flattens a block into its row.
+func newBlockRow(block *types.Block) *blockRow {
+	return &blockRow{
+		Number:           int64(block.NumberU64()),
+		Hash:             block.Hash().Hex(),
+		ParentHash:       block.ParentHash().Hex(),
+		Timestamp:        int64(block.Time()),
+		Miner:            block.Coinbase().Hex(),
+		Difficulty:       block.Difficulty().String(),
+		GasLimit:         int64(block.GasLimit()),
+		GasUsed:          int64(block.GasUsed()),
+		ExtraData:        hexutil.Encode(block.Extra()),
+		Size:             int64(block.Size()),
+		StateRoot:        block.Root().Hex(),
+		TransactionsRoot: block.TxHash().Hex(),
+		ReceiptsRoot:     block.ReceiptHash().Hex(),
+		TransactionCount: int32(len(block.Transactions())),
+		UncleCount:       int32(len(block.Uncles())),
+	}
+}
+
+// newTransactionRows flattens the transactions of a block and their receipts
+// into rows.
+func newTransactionRows(config *params.ChainConfig, block *types.Block, receipts types.Receipts) ([]*transactionRow, error) {
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("block %d: %d receipts for %d transactions", block.NumberU64(), len(receipts), len(txs))
+	}
+	var (
+		signer = types.MakeSigner(config, block.Number())
+		rows   = make([]*transactionRow, len(txs))
+	)
+	for i, tx := range txs {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, fmt.Errorf("block %d, transaction %d: %v", block.NumberU64(), i, err)
+		}
+		receipt := receipts[i]
+		row := &transactionRow{
+			BlockNumber:       int64(block.NumberU64()),
+			BlockHash:         block.Hash().Hex(),
+			Index:             int32(i),
+			Hash:              tx.Hash().Hex(),
+			Type:              int32(tx.Type()),
+			From:              from.Hex(),
+			Value:             tx.Value().String(),
+			Nonce:             int64(tx.Nonce()),
+			Gas:               int64(tx.Gas()),
+			GasPrice:          tx.GasPrice().String(),
+			Input:             hexutil.Encode(tx.Data()),
+			Status:            int64(receipt.Status),
+			GasUsed:           int64(receipt.GasUsed),
+			CumulativeGasUsed: int64(receipt.CumulativeGasUsed),
+			LogCount:          int32(len(receipt.Logs)),
+		}
+		if to := tx.To(); to != nil {
+			row.To = optional(to.Hex())
+		}
+		if receipt.ContractAddress != (common.Address{}) {
+			row.ContractAddress = optional(receipt.ContractAddress.Hex())
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// newLogRows flattens the logs of the receipts of a block into rows.
+func newLogRows(block *types.Block, receipts types.Receipts) []*logRow {
+	var rows []*logRow
+	for i, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			topics := make([]string, len(log.Topics))
+			for j, topic := range log.Topics {
+				topics[j] = topic.Hex()
+			}
+			rows = append(rows, &logRow{
+				BlockNumber:      int64(block.NumberU64()),
+				BlockHash:        block.Hash().Hex(),
+				TransactionIndex: int32(i),
+				TransactionHash:  receipt.TxHash.Hex(),
+				Index:            int32(log.Index),
+				Address:          log.Address.Hex(),
+				Topics:           topics,
+				Data:             hexutil.Encode(log.Data),
+			})
+		}
+	}
+	return rows
+}
+
+// callFrame is a call of the call tracer result.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Value   *hexutil.Big   `json:"value"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   string         `json:"input"`
+	Output  string         `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []callFrame    `json:"calls"`
+}
+
+// flattenCalls appends the rows of a call frame and its nested calls in depth
+// first order, tagging each with its trace address.
+func flattenCalls(rows []*traceRow, frame *callFrame, address []string, template traceRow) []*traceRow {
+	row := template
+	row.TraceAddress = strings.Join(address, ".")
+	row.Depth = int32(len(address))
+	row.CallType = strings.ToLower(frame.Type)
+	row.From = frame.From
+	row.To = frame.To
+	row.Value = "0"
+	if frame.Value != nil {
+		row.Value = frame.Value.ToInt().String()
+	}
+	row.Gas = int64(frame.Gas)
+	row.GasUsed = int64(frame.GasUsed)
+	row.Input = frame.Input
+	row.Output = frame.Output
+	if frame.Error != "" {
+		row.Error = optional(frame.Error)
+	}
+	rows = append(rows, &row)
+
+	for i := range frame.Calls {
+		rows = flattenCalls(rows, &frame.Calls[i], append(address[:len(address):len(address)], fmt.Sprint(i)), template)
+	}
+	return rows
+}
+
+// optional returns a pointer to the string, for optional columns.
+func optional(s string) *string {
+	return &s
+}
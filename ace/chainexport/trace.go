@@ -0,0 +1,85 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package chainexport
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/eth/tracers"
+)
+
+// traceBlock re-executes the transactions of a block on the state of its
+// parent with the call tracer, flattening the call trees into rows. The
+// parent state must be available, which for old blocks requires an archive
+// node.
+func traceBlock(chain Chain, block *types.Block) ([]*traceRow, error) {
+	if len(block.Transactions()) == 0 {
+		return nil, nil
+	}
+	parent := chain.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent of block %d not found", block.NumberU64())
+	}
+	statedb, err := chain.StateAt(parent.Root)
+	if err != nil {
+		return nil, fmt.Errorf("state of block %d unavailable: %v", parent.Number, err)
+	}
+	var (
+		config   = chain.Config()
+		signer   = types.MakeSigner(config, block.Number())
+		blockCtx = core.NewEVMBlockContext(block.Header(), chain, nil)
+		rows     []*traceRow
+	)
+	for i, tx := range block.Transactions() {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, fmt.Errorf("block %d, transaction %d: %v", block.NumberU64(), i, err)
+		}
+		txCtx := core.NewEVMTxContext(msg)
+		tracer, err := tracers.New("callTracer", txCtx)
+		if err != nil {
+			return nil, err
+		}
+		vmenv := vm.NewEVM(blockCtx, txCtx, statedb, config, vm.Config{Debug: true, Tracer: tracer})
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			return nil, fmt.Errorf("block %d, transaction %d: tracing failed: %v", block.NumberU64(), i, err)
+		}
+		statedb.Finalise(config.IsEIP158(block.Number()))
+
+		result, err := tracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		var frame callFrame
+		if err := json.Unmarshal(result, &frame); err != nil {
+			return nil, fmt.Errorf("block %d, transaction %d: invalid trace: %v", block.NumberU64(), i, err)
+		}
+		template := traceRow{
+			BlockNumber:      int64(block.NumberU64()),
+			BlockHash:        block.Hash().Hex(),
+			TransactionIndex: int32(i),
+			TransactionHash:  tx.Hash().Hex(),
+		}
+		rows = flattenCalls(rows, &frame, nil, template)
+	}
+	return rows, nil
+}
@@ -119,6 +119,12 @@ func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (*rpc.Subscription,
 	return rpcSub, nil
 }
 
+// SyncStages returns the progress of the stages of the running sync along with
+// their estimated remaining time, or nil if the node isn't syncing.
+func (api *PublicDownloaderAPI) SyncStages() []StageProgress {
+	return api.d.StageProgress()
+}
+
 // SyncingResult provides information about the current synchronisation status for this node.
 type SyncingResult struct {
 	Syncing bool                  `json:"syncing"`
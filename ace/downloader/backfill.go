@@ -0,0 +1,276 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+)
+
+// TrustedHead is a chain head whose hash is trusted without verification, from
+// which the header chain can be synced backwards.
+type TrustedHead struct {
+	Number uint64      // Block number of the trusted head
+	Hash   common.Hash // Block hash of the trusted head
+}
+
+// backfillStatus is the progress of a backwards header sync, persisted across
+// restarts so that an interrupted backfill resumes where it left off.
+type backfillStatus struct {
+	Head uint64      // Number of the trusted head being backfilled from
+	Hash common.Hash // Hash of the trusted head being backfilled from
+	Tail uint64      // Number of the lowest header retrieved so far (Head+1 if none yet)
+	Next common.Hash // Hash of the header below the tail, the next one to retrieve
+}
+
+// SetTrustedHead schedules a backwards header sync from the given trusted head.
+// The next sync cycle retrieves the headers below it until they link up with
+// the local chain, imports them, and then continues with a regular sync. A
+// backfill scheduled earlier towards a different head is abandoned.
+func (d *Downloader) SetTrustedHead(number uint64, hash common.Hash) error {
+	// Make sure no sync cycle is using the backfill status while it's replaced
+	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		return errBusy
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	status := readBackfillStatus(d.stateDB)
+	if status != nil {
+		if status.Head == number && status.Hash == hash {
+			return nil
+		}
+		log.Info("Abandoning header backfill", "number", status.Head, "hash", status.Hash)
+		deleteBackfill(d.stateDB, status)
+	}
+	if d.lightchain.HasHeader(hash, number) {
+		return nil
+	}
+	writeBackfillStatus(d.stateDB, &backfillStatus{Head: number, Hash: hash, Tail: number + 1, Next: hash})
+	log.Info("Scheduled header backfill from trusted head", "number", number, "hash", hash)
+	return nil
+}
+
+// ClearTrustedHead abandons the scheduled backwards header sync, if any, dropping
+// the headers it retrieved so far.
+func (d *Downloader) ClearTrustedHead() error {
+	// Make sure no sync cycle is using the backfill status while it's removed
+	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		return errBusy
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	if status := readBackfillStatus(d.stateDB); status != nil {
+		log.Info("Abandoning header backfill", "number", status.Head, "hash", status.Hash)
+		deleteBackfill(d.stateDB, status)
+	}
+	return nil
+}
+
+// backfill retrieves the headers below a scheduled trusted head from the given
+// peer until they link up with the local chain, and then imports them.
+func (d *Downloader) backfill(p *peerConnection) error {
+	status := readBackfillStatus(d.stateDB)
+	if status == nil {
+		return nil
+	}
+	atomic.StoreInt32(&d.backfilling, 1)
+	defer atomic.StoreInt32(&d.backfilling, 0)
+
+	d.syncStatsLock.Lock()
+	d.syncStatsBackfill = *status
+	d.syncStages[stageBackfill] = stageMeter{start: status.Head + 1 - status.Tail, started: time.Now()}
+	d.syncStatsLock.Unlock()
+
+	p.log.Info("Backfilling headers from trusted head", "number", status.Head, "hash", status.Hash, "tail", status.Tail)
+	for !d.backfillLinked(status) {
+		if status.Tail == 0 {
+			// The trusted chain reached the genesis without linking up, so it's from
+			// a different network. The peer only served what was asked, keep it.
+			log.Error("Trusted head doesn't link to the local genesis", "number", status.Head, "hash", status.Hash)
+			deleteBackfill(d.stateDB, status)
+			return errBackfillGenesis
+		}
+		headers, err := d.fetchBackfillHeaders(p, status.Next)
+		if err != nil {
+			return err
+		}
+		batch := d.stateDB.NewBatch()
+		for _, header := range headers {
+			number := header.Number.Uint64()
+			if number+1 != status.Tail || header.Hash() != status.Next {
+				return fmt.Errorf("%w: backfill header mismatch: have %d [%x], want %d [%x]", errInvalidChain, number, header.Hash().Bytes()[:4], status.Tail-1, status.Next[:4])
+			}
+			rawdb.WriteBackfillHeader(batch, header)
+			status.Tail, status.Next = number, header.ParentHash
+
+			if status.Tail == 0 || d.backfillLinked(status) {
+				break
+			}
+		}
+		writeBackfillStatus(batch, status)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		d.syncStatsLock.Lock()
+		d.syncStatsBackfill = *status
+		d.syncStatsLock.Unlock()
+	}
+	return d.importBackfill(status)
+}
+
+// backfillLinked reports whether the backfilled headers link up with the local
+// chain, i.e. the header below the tail is known locally.
+func (d *Downloader) backfillLinked(status *backfillStatus) bool {
+	return status.Tail > 0 && d.lightchain.HasHeader(status.Next, status.Tail-1)
+}
+
+// fetchBackfillHeaders retrieves a batch of headers from a remote peer, going
+// backwards from the one with the given hash.
+func (d *Downloader) fetchBackfillHeaders(p *peerConnection, hash common.Hash) ([]*types.Header, error) {
+	go p.peer.RequestHeadersByHash(hash, MaxHeaderFetch, 0, true)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCanceled
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			headers := packet.(*headerPack).headers
+			if len(headers) == 0 {
+				return nil, errBackfillUnavailable
+			}
+			if len(headers) > MaxHeaderFetch {
+				return nil, fmt.Errorf("%w: returned headers %d > requested %d", errBadPeer, len(headers), MaxHeaderFetch)
+			}
+			return headers, nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for backfill headers timed out", "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// importBackfill inserts the backfilled headers into the local chain, starting
+// from the tail that links up with it.
+func (d *Downloader) importBackfill(status *backfillStatus) error {
+	var (
+		start    = time.Now()
+		imported = status.Head + 1 - status.Tail
+	)
+	for status.Tail <= status.Head {
+		select {
+		case <-d.cancelCh:
+			return errCanceled
+		default:
+		}
+		headers := make([]*types.Header, 0, MaxHeaderFetch)
+		for number := status.Tail; number <= status.Head && len(headers) < MaxHeaderFetch; number++ {
+			header := rawdb.ReadBackfillHeader(d.stateDB, number)
+			if header == nil {
+				log.Error("Backfilled header missing", "number", number)
+				deleteBackfill(d.stateDB, status)
+				return errBackfillMissing
+			}
+			headers = append(headers, header)
+		}
+		// The headers are linked by hash to the trusted head, so the seals only need
+		// to be spot checked like during fast sync
+		if n, err := d.lightchain.InsertHeaderChain(headers, fsHeaderCheckFrequency); err != nil {
+			log.Error("Backfilled header chain rejected", "number", headers[n].Number, "hash", headers[n].Hash(), "err", err)
+			deleteBackfill(d.stateDB, status)
+			return fmt.Errorf("invalid trusted chain: %v", err)
+		}
+		batch := d.stateDB.NewBatch()
+		for _, header := range headers {
+			rawdb.DeleteBackfillHeader(batch, header.Number.Uint64())
+		}
+		status.Tail, status.Next = status.Tail+uint64(len(headers)), headers[len(headers)-1].Hash()
+		if status.Tail > status.Head {
+			rawdb.DeleteBackfillStatus(batch)
+		} else {
+			writeBackfillStatus(batch, status)
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	log.Info("Backfilled headers to trusted head", "count", imported, "number", status.Head, "hash", status.Hash, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// readBackfillStatus retrieves the status of the scheduled backwards header sync,
+// or nil if there is none.
+func readBackfillStatus(db ethdb.KeyValueReader) *backfillStatus {
+	blob := rawdb.ReadBackfillStatus(db)
+	if len(blob) == 0 {
+		return nil
+	}
+	status := new(backfillStatus)
+	if err := rlp.DecodeBytes(blob, status); err != nil {
+		log.Error("Failed to decode backfill status", "err", err)
+		return nil
+	}
+	return status
+}
+
+// writeBackfillStatus stores the status of the scheduled backwards header sync.
+func writeBackfillStatus(db ethdb.KeyValueWriter, status *backfillStatus) {
+	blob, err := rlp.EncodeToBytes(status)
+	if err != nil {
+		log.Crit("Failed to encode backfill status", "err", err)
+	}
+	rawdb.WriteBackfillStatus(db, blob)
+}
+
+// deleteBackfill removes the status of a backwards header sync along with the
+// headers it retrieved.
+func deleteBackfill(db ethdb.KeyValueStore, status *backfillStatus) {
+	batch := db.NewBatch()
+	for number := status.Tail; number <= status.Head; number++ {
+		rawdb.DeleteBackfillHeader(batch, number)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete backfill headers", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	rawdb.DeleteBackfillStatus(batch)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete backfill status", "err", err)
+	}
+}
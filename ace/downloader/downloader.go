@@ -18,6 +18,7 @@
 package downloader
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -27,6 +28,8 @@ import (
 
 	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/membudget"
+	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/eth/protocols/snap"
@@ -34,8 +37,13 @@ import (
 	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/metrics"
+	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/trie"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -50,6 +58,9 @@ var (
 	rttMinConfidence = 0.1              // Worse confidence factor in our estimated RTT value
 	ttlScaling       = 3                // Constant scaling factor for RTT -> TTL conversion
 	ttlLimit         = time.Minute      // Maximum TTL allowance to prevent reaching crazy timeouts
+	stragglerScaling = 3                // Constant scaling factor for expected delivery time -> straggler deadline conversion
+	minStripeFetch   = 16               // Minimum number of items to request from a peer when striping tasks across peers
+	slowPeerRatio    = 0.1              // Fraction of the median peer throughput below which a peer only gets minimal batches
 
 	qosTuningPeers   = 5    // Number of peers to tune based on (best peers)
 	qosConfidenceCap = 10   // Number of peers above which not to modify RTT confidence
@@ -72,6 +83,10 @@ var (
 )
 
 var (
+	// tracer creates the spans of the sync cycles. It does nothing unless tracing
+	// is set up by the application.
+	tracer = otel.Tracer("github.com/acent/go-acent/eth/downloader")
+
 	errBusy                    = errors.New("busy")
 	errUnknownPeer             = errors.New("peer is unknown or unhealthy")
 	errBadPeer                 = errors.New("action from bad peer ignored")
@@ -91,6 +106,9 @@ var (
 	errNoSyncActive            = errors.New("no sync active")
 	errTooOld                  = errors.New("peer's protocol version too old")
 	errNoAncestorFound         = errors.New("no common ancestor found")
+	errBackfillUnavailable     = errors.New("trusted chain unavailable from peer")
+	errBackfillGenesis         = errors.New("trusted chain doesn't link to the local genesis")
+	errBackfillMissing         = errors.New("backfilled header missing")
 )
 
 type Downloader struct {
@@ -115,22 +133,32 @@ type Downloader struct {
 	// Statistics
 	syncStatsChainOrigin uint64 // Origin block number where syncing started at
 	syncStatsChainHeight uint64 // Highest block number known when syncing started
+	syncStatsHeaders     uint64 // Highest block number whose header was processed
 	syncStatsState       stateSyncStats
-	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
+	syncStatsBackfill    backfillStatus         // Progress of the running backwards header sync
+	syncStages           [stageCount]stageMeter // Starting points of the stages of the running sync
+	syncStatsLock        sync.RWMutex           // Lock protecting the sync stats fields
 
 	lightchain LightChain
 	blockchain BlockChain
 
 	// Callbacks
-	dropPeer peerDropFn // Drops a peer for misbehaving
+	dropPeer     peerDropFn    // Drops a peer for misbehaving
+	penalizePeer peerPenaltyFn // Reports the misbehaviour of a peer (optional)
 
 	// Status
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
 	synchronising   int32
+	backfilling     int32 // Whether the sync cycle is backfilling headers from a trusted head
 	notified        int32
 	committed       int32
 	ancientLimit    uint64 // The maximum block number which can be regarded as ancient data.
 
+	bodyHistory    uint64 // Number of blocks below the pivot whose bodies are fast synced (0 = all)
+	receiptHistory uint64 // Number of blocks below the pivot whose receipts are fast synced (0 = all)
+
+	traceCtx context.Context // Context carrying the trace span of the current sync cycle
+
 	// Channels
 	headerCh      chan dataPack        // Channel receiving inbound block headers
 	bodyCh        chan dataPack        // Channel receiving inbound block bodies
@@ -213,6 +241,15 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts, uint64) (int, error)
+
+	// Config retrieves the chain's fork configuration.
+	Config() *params.ChainConfig
+
+	// HistoryTails retrieves the oldest blocks whose bodies and receipts are available.
+	HistoryTails() (uint64, uint64)
+
+	// SetHistoryTails updates the oldest blocks whose bodies and receipts are available.
+	SetHistoryTails(uint64, uint64)
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -246,12 +283,81 @@ func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom,
 			processed: rawdb.ReadFastTrieProgress(stateDb),
 		},
 		trackStateReq: make(chan *stateReq),
+		traceCtx:      context.Background(),
+	}
+	if chain != nil {
+		dl.queue.bodyHook = dl.cacheSenders
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
 	return dl
 }
 
+// cacheSenders starts recovering the transaction senders of freshly delivered
+// block bodies in the background during full sync, so the signatures are already
+// verified by the time the blocks get imported.
+func (d *Downloader) cacheSenders(headers []*types.Header, txs [][]*types.Transaction) {
+	if d.getMode() != FullSync {
+		return
+	}
+	config := d.blockchain.Config()
+	go func() {
+		for i, header := range headers {
+			core.CacheSenders(types.MakeSigner(config, header.Number), txs[i])
+		}
+	}()
+}
+
+// RegisterMemoryBudget hands the sizing of the download result cache over to a
+// global memory budget, favouring it heavily while the node is syncing.
+func (d *Downloader) RegisterMemoryBudget(budget *membudget.Manager) {
+	budget.Register("downloader", membudget.Weights{Syncing: 3, Serving: 0}, 16*1024*1024, d.queue.SetMemoryLimit)
+}
+
+// SetPenalizer sets the callback reporting the misbehaviour of the peers
+// dropped by the downloader to their reputation tracking.
+func (d *Downloader) SetPenalizer(penalize func(id string, offence p2p.Offence)) {
+	d.penalizePeer = penalize
+}
+
+// SetHistoryLimits sets the number of blocks below the pivot whose bodies and
+// receipts are downloaded by fast sync, zero meaning the entire history. Older
+// bodies and receipts are skipped, for nodes not needing to serve them.
+func (d *Downloader) SetHistoryLimits(bodies uint64, receipts uint64) {
+	d.bodyHistory, d.receiptHistory = bodies, receipts
+}
+
+// historyTails calculates the oldest blocks whose bodies and receipts are to be
+// fast synced below the given pivot, persisting them so that the missing data
+// is reported consistently. Tails never move backwards, since a previous sync
+// cycle may have already skipped blocks.
+func (d *Downloader) historyTails(pivot uint64) (bodies uint64, receipts uint64) {
+	stored, storedReceipts := d.blockchain.HistoryTails()
+
+	bodies, receipts = stored, storedReceipts
+	if d.bodyHistory > 0 && pivot > d.bodyHistory && pivot-d.bodyHistory > bodies {
+		bodies = pivot - d.bodyHistory
+	}
+	if d.receiptHistory > 0 && pivot > d.receiptHistory && pivot-d.receiptHistory > receipts {
+		receipts = pivot - d.receiptHistory
+	}
+	// Receipts can't be served without the transactions of their block
+	if receipts < bodies {
+		receipts = bodies
+	}
+	if bodies != stored || receipts != storedReceipts {
+		d.blockchain.SetHistoryTails(bodies, receipts)
+	}
+	return bodies, receipts
+}
+
+// penalize reports the misbehaviour of a peer, if a penalizer was set.
+func (d *Downloader) penalize(id string, offence p2p.Offence) {
+	if d.penalizePeer != nil {
+		d.penalizePeer(id, offence)
+	}
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -285,6 +391,81 @@ func (d *Downloader) Progress() acent.SyncProgress {
 	}
 }
 
+// StageProgress reports the progress of the stages of the running sync, along
+// with estimates of the time they need to complete. Nil is returned if no sync
+// is running.
+func (d *Downloader) StageProgress() []StageProgress {
+	if !d.Synchronising() {
+		return nil
+	}
+	d.syncStatsLock.RLock()
+	defer d.syncStatsLock.RUnlock()
+
+	now := time.Now()
+	if atomic.LoadInt32(&d.backfilling) == 1 {
+		// Headers are retrieved backwards towards the local head, where the
+		// backfilled chain is most likely to link up
+		var (
+			status = d.syncStatsBackfill
+			target uint64
+		)
+		if local := d.lightchain.CurrentHeader().Number.Uint64(); status.Head > local {
+			target = status.Head - local
+		}
+		return []StageProgress{d.syncStages[stageBackfill].progress(stageBackfill, status.Head+1-status.Tail, target, now)}
+	}
+	var (
+		mode   = d.getMode()
+		stages = []StageProgress{d.syncStages[stageHeaders].progress(stageHeaders, d.syncStatsHeaders, d.syncStatsChainHeight, now)}
+	)
+	if mode == LightSync || d.blockchain == nil {
+		return stages
+	}
+	bodies, receipts := d.chainPositions()
+	stages = append(stages, d.syncStages[stageBodies].progress(stageBodies, bodies, d.syncStatsChainHeight, now))
+	if mode == FastSync {
+		var pivot uint64
+		d.pivotLock.RLock()
+		if d.pivotHeader != nil {
+			pivot = d.pivotHeader.Number.Uint64()
+		}
+		d.pivotLock.RUnlock()
+
+		state := d.syncStatsState.processed
+		stages = append(stages,
+			d.syncStages[stageReceipts].progress(stageReceipts, receipts, pivot, now),
+			d.syncStages[stageState].progress(stageState, state, state+d.syncStatsState.pending, now),
+		)
+	}
+	return stages
+}
+
+// startStages marks the starting points of the sync stages. The sync stats lock
+// must be held by the caller.
+func (d *Downloader) startStages(origin uint64) {
+	now := time.Now()
+	bodies, receipts := d.chainPositions()
+
+	d.syncStages[stageHeaders] = stageMeter{start: origin, started: now}
+	d.syncStages[stageBodies] = stageMeter{start: bodies, started: now}
+	d.syncStages[stageReceipts] = stageMeter{start: receipts, started: now}
+	d.syncStages[stageState] = stageMeter{start: d.syncStatsState.processed, started: now}
+}
+
+// chainPositions returns the highest blocks whose bodies and whose receipts are
+// present in the local chain.
+func (d *Downloader) chainPositions() (bodies uint64, receipts uint64) {
+	if d.blockchain == nil {
+		return 0, 0
+	}
+	bodies = d.blockchain.CurrentBlock().NumberU64()
+	receipts = d.blockchain.CurrentFastBlock().NumberU64()
+	if receipts > bodies {
+		bodies = receipts
+	}
+	return bodies, receipts
+}
+
 // Synchronising returns whether the downloader is currently retrieving blocks.
 func (d *Downloader) Synchronising() bool {
 	return atomic.LoadInt32(&d.synchronising) > 0
@@ -355,6 +536,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 			log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", id)
 		} else {
+			d.penalize(id, syncOffence(err))
 			d.dropPeer(id)
 		}
 		return err
@@ -363,6 +545,19 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 	return err
 }
 
+// syncOffence classifies the error a synchronisation with a peer failed with
+// as an offence of the peer.
+func syncOffence(err error) p2p.Offence {
+	switch {
+	case errors.Is(err, errTimeout), errors.Is(err, errStallingPeer):
+		return p2p.OffenceTimeout
+	case errors.Is(err, errInvalidChain), errors.Is(err, errBadPeer), errors.Is(err, errInvalidAncestor), errors.Is(err, errEmptyHeaderSet):
+		return p2p.OffenceInvalid
+	default:
+		return p2p.OffenceUseless
+	}
+}
+
 // synchronise will select the peer and use it for synchronising. If an empty string is given
 // it will use the best peer possible and synchronize if its TD is higher than our own. If any of the
 // checks fail an error will be returned. This method is synchronous
@@ -439,7 +634,30 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if p == nil {
 		return errUnknownPeer
 	}
-	return d.syncWithPeer(p, hash, td)
+	ctx, span := tracer.Start(context.Background(), "downloader.synchronise", trace.WithAttributes(
+		attribute.String("peer", id),
+		attribute.String("mode", mode.String()),
+		attribute.String("head", hash.Hex()),
+	))
+	defer span.End()
+	d.traceCtx = ctx
+
+	// A failed backfill is retried on the next cycle, it mustn't hold up the
+	// forward sync in the meantime. Report it only if the sync succeeds, so a
+	// misbehaving peer is still dropped.
+	err := d.backfill(p)
+	if !errors.Is(err, errCanceled) {
+		if err != nil {
+			p.log.Warn("Header backfill failed, syncing forward", "err", err)
+		}
+		if serr := d.syncWithPeer(p, hash, td); serr != nil || err == nil {
+			err = serr
+		}
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
 
 func (d *Downloader) getMode() SyncMode {
@@ -492,6 +710,8 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		d.syncStatsChainOrigin = origin
 	}
 	d.syncStatsChainHeight = height
+	d.syncStatsHeaders = origin
+	d.startStages(origin)
 	d.syncStatsLock.Unlock()
 
 	// Ensure our origin point is below any fast sync pivot point
@@ -553,6 +773,12 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	// Initiate the sync using a concurrent header and content retrieval algorithm
 	d.queue.Prepare(origin+1, mode)
+	if mode == FastSync {
+		if bodies, receipts := d.historyTails(pivot.Number.Uint64()); bodies > 0 || receipts > 0 {
+			d.queue.SetHistoryTails(bodies, receipts)
+			log.Info("Skipping old chain history", "bodies", bodies, "receipts", receipts)
+		}
+	}
 	if d.syncInitHook != nil {
 		d.syncInitHook(origin, height)
 	}
@@ -1215,6 +1441,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
+			d.penalize(p.id, p2p.OffenceTimeout)
 			d.dropPeer(p.id)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
@@ -1261,9 +1488,9 @@ func (d *Downloader) fillHeaderSkeleton(from uint64, skeleton []*types.Header) (
 			p.SetHeadersIdle(accepted, deliveryTime)
 		}
 	)
-	err := d.fetchParts(d.headerCh, deliver, d.queue.headerContCh, expire,
+	err := d.fetchParts(d.headerCh, deliver, d.queue.headerContCh, expire, nil,
 		d.queue.PendingHeaders, d.queue.InFlightHeaders, reserve,
-		nil, fetch, d.queue.CancelHeaders, capacity, d.peers.HeaderIdlePeers, setIdle, "headers")
+		nil, fetch, d.queue.CancelHeaders, capacity, (*peerConnection).HeaderThroughput, nil, d.peers.HeaderIdlePeers, setIdle, "headers")
 
 	log.Debug("Skeleton fill terminated", "err", err)
 
@@ -1285,11 +1512,14 @@ func (d *Downloader) fetchBodies(from uint64) error {
 		expire   = func() map[string]int { return d.queue.ExpireBodies(d.requestTTL()) }
 		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchBodies(req) }
 		capacity = func(p *peerConnection) int { return p.BlockCapacity(d.requestRTT()) }
-		setIdle  = func(p *peerConnection, accepted int, deliveryTime time.Time) { p.SetBodiesIdle(accepted, deliveryTime) }
+		deadline = func(p *peerConnection, items int) time.Duration {
+			return p.BlockDeadline(items, d.requestRTT(), d.requestTTL())
+		}
+		setIdle = func(p *peerConnection, accepted int, deliveryTime time.Time) { p.SetBodiesIdle(accepted, deliveryTime) }
 	)
-	err := d.fetchParts(d.bodyCh, deliver, d.bodyWakeCh, expire,
+	err := d.fetchParts(d.bodyCh, deliver, d.bodyWakeCh, expire, d.queue.StraggleBodies,
 		d.queue.PendingBlocks, d.queue.InFlightBlocks, d.queue.ReserveBodies,
-		d.bodyFetchHook, fetch, d.queue.CancelBodies, capacity, d.peers.BodyIdlePeers, setIdle, "bodies")
+		d.bodyFetchHook, fetch, d.queue.CancelBodies, capacity, (*peerConnection).BlockThroughput, deadline, d.peers.BodyIdlePeers, setIdle, "bodies")
 
 	log.Debug("Block body download terminated", "err", err)
 	return err
@@ -1309,13 +1539,16 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 		expire   = func() map[string]int { return d.queue.ExpireReceipts(d.requestTTL()) }
 		fetch    = func(p *peerConnection, req *fetchRequest) error { return p.FetchReceipts(req) }
 		capacity = func(p *peerConnection) int { return p.ReceiptCapacity(d.requestRTT()) }
-		setIdle  = func(p *peerConnection, accepted int, deliveryTime time.Time) {
+		deadline = func(p *peerConnection, items int) time.Duration {
+			return p.ReceiptDeadline(items, d.requestRTT(), d.requestTTL())
+		}
+		setIdle = func(p *peerConnection, accepted int, deliveryTime time.Time) {
 			p.SetReceiptsIdle(accepted, deliveryTime)
 		}
 	)
-	err := d.fetchParts(d.receiptCh, deliver, d.receiptWakeCh, expire,
+	err := d.fetchParts(d.receiptCh, deliver, d.receiptWakeCh, expire, d.queue.StraggleReceipts,
 		d.queue.PendingReceipts, d.queue.InFlightReceipts, d.queue.ReserveReceipts,
-		d.receiptFetchHook, fetch, d.queue.CancelReceipts, capacity, d.peers.ReceiptIdlePeers, setIdle, "receipts")
+		d.receiptFetchHook, fetch, d.queue.CancelReceipts, capacity, (*peerConnection).ReceiptThroughput, deadline, d.peers.ReceiptIdlePeers, setIdle, "receipts")
 
 	log.Debug("Transaction receipt download terminated", "err", err)
 	return err
@@ -1335,6 +1568,7 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 //  - deliver:     processing callback to deliver data packets into type specific download queues (usually within `queue`)
 //  - wakeCh:      notification channel for waking the fetcher when new tasks are available (or sync completed)
 //  - expire:      task callback method to abort requests that took too long and return the faulty peers (traffic shaping)
+//  - straggle:    task callback method to reassign the tasks of requests past their deadline to other peers (tail latency)
 //  - pending:     task callback for the number of requests still needing download (detect completion/non-completability)
 //  - inFlight:    task callback for the number of in-progress requests (wait for all active downloads to finish)
 //  - throttle:    task callback to check if the processing queue is full and activate throttling (bound memory use)
@@ -1343,13 +1577,15 @@ func (d *Downloader) fetchReceipts(from uint64) error {
 //  - fetch:       network callback to actually send a particular download request to a physical remote peer
 //  - cancel:      task callback to abort an in-flight download request and allow rescheduling it (in case of lost peer)
 //  - capacity:    network callback to retrieve the estimated type-specific bandwidth capacity of a peer (traffic shaping)
+//  - throughput:  network callback to retrieve the measured type-specific throughput of a peer (slow peer detection)
+//  - deadline:    network callback to retrieve the time after which a request of a peer is considered straggling (tail latency)
 //  - idle:        network callback to retrieve the currently (type specific) idle peers that can be assigned tasks
 //  - setIdle:     network callback to set a peer back to idle and update its estimated capacity (traffic shaping)
 //  - kind:        textual label of the type being downloaded to display in log messages
 func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack) (int, error), wakeCh chan bool,
-	expire func() map[string]int, pending func() int, inFlight func() bool, reserve func(*peerConnection, int) (*fetchRequest, bool, bool),
+	expire func() map[string]int, straggle func() map[string]int, pending func() int, inFlight func() bool, reserve func(*peerConnection, int) (*fetchRequest, bool, bool),
 	fetchHook func([]*types.Header), fetch func(*peerConnection, *fetchRequest) error, cancel func(*fetchRequest), capacity func(*peerConnection) int,
-	idle func() ([]*peerConnection, int), setIdle func(*peerConnection, int, time.Time), kind string) error {
+	throughput func(*peerConnection) float64, deadline func(*peerConnection, int) time.Duration, idle func() ([]*peerConnection, int), setIdle func(*peerConnection, int, time.Time), kind string) error {
 
 	// Create a ticker to detect expired retrieval tasks
 	ticker := time.NewTicker(100 * time.Millisecond)
@@ -1440,6 +1676,7 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 							// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 							peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pid)
 						} else {
+							d.penalize(pid, p2p.OffenceTimeout)
 							d.dropPeer(pid)
 
 							// If this peer was the master peer, abort sync immediately
@@ -1455,6 +1692,15 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 					}
 				}
 			}
+			// Reassign the tasks of straggling requests, leaving them running in case
+			// they still arrive first
+			if straggle != nil {
+				for pid, count := range straggle() {
+					if peer := d.peers.Peer(pid); peer != nil {
+						peer.log.Trace("Reassigning straggling request", "type", kind, "count", count)
+					}
+				}
+			}
 			// If there's nothing more to fetch, wait or terminate
 			if pending() == 0 {
 				if !inFlight() && finished {
@@ -1467,6 +1713,17 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 			progressed, throttled, running := false, false, inFlight()
 			idles, total := idle()
 			pendCount := pending()
+
+			// Measure the peers against the typical throughput, so that a slow one
+			// can't stall the sync by holding up a large batch
+			typical := d.peers.medianThroughput(throughput)
+
+			// Stripe the pending tasks across all the idle peers instead of letting
+			// the first ones take them all, limiting the tasks held up by any peer
+			stripe := minStripeFetch
+			if len(idles) > 0 && (pendCount+len(idles)-1)/len(idles) > stripe {
+				stripe = (pendCount + len(idles) - 1) / len(idles)
+			}
 			for _, peer := range idles {
 				// Short circuit if throttling activated
 				if throttled {
@@ -1479,7 +1736,16 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 				// Reserve a chunk of fetches for a peer. A nil can mean either that
 				// no more headers are available, or that the peer is known not to
 				// have them.
-				request, progress, throttle := reserve(peer, capacity(peer))
+				limit := capacity(peer)
+				if limit > stripe {
+					limit = stripe
+				}
+				// Slow peers only get minimal batches, which keeps them measured without
+				// the sync having to wait for them to deliver a lot of items
+				if slowPeer(throughput(peer), typical) {
+					limit = 1
+				}
+				request, progress, throttle := reserve(peer, limit)
 				if progress {
 					progressed = true
 				}
@@ -1490,6 +1756,9 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 				if request == nil {
 					continue
 				}
+				if deadline != nil {
+					request.Deadline = request.Time.Add(deadline(peer, len(request.Headers)))
+				}
 				if request.From > 0 {
 					peer.log.Trace("Requesting new batch of data", "type", kind, "from", request.From)
 				} else {
@@ -1682,6 +1951,9 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 			if d.syncStatsChainHeight < origin {
 				d.syncStatsChainHeight = origin - 1
 			}
+			if d.syncStatsHeaders < origin {
+				d.syncStatsHeaders = origin - 1
+			}
 			d.syncStatsLock.Unlock()
 
 			// Signal the content downloaders of the availablility of new tasks
@@ -1727,11 +1999,19 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		"firstnum", first.Number, "firsthash", first.Hash(),
 		"lastnum", last.Number, "lasthash", last.Hash(),
 	)
+	_, span := tracer.Start(d.traceCtx, "downloader.importBlockResults", trace.WithAttributes(
+		attribute.Int("blocks", len(results)),
+		attribute.Int64("first", first.Number.Int64()),
+		attribute.Int64("last", last.Number.Int64()),
+	))
+	defer span.End()
+
 	blocks := make([]*types.Block, len(results))
 	for i, result := range results {
 		blocks[i] = types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 	}
 	if index, err := d.blockchain.InsertChain(blocks); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		if index < len(results) {
 			log.Debug("Downloaded item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 		} else {
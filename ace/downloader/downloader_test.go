@@ -29,9 +29,11 @@ import (
 	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/event"
+	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/trie"
 )
 
@@ -63,6 +65,9 @@ type downloadTester struct {
 	ancientReceipts map[common.Hash]types.Receipts // Ancient receipts belonging to the tester
 	ancientChainTd  map[common.Hash]*big.Int       // Ancient total difficulties of the blocks in the local chain
 
+	bodyTail    uint64 // Oldest block whose body is available
+	receiptTail uint64 // Oldest block whose receipts are available
+
 	lock sync.RWMutex
 }
 
@@ -341,6 +346,27 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 	return len(blocks), nil
 }
 
+// Config retrieves the chain configuration of the tester.
+func (dl *downloadTester) Config() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+// HistoryTails retrieves the oldest blocks whose bodies and receipts are available.
+func (dl *downloadTester) HistoryTails() (uint64, uint64) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.bodyTail, dl.receiptTail
+}
+
+// SetHistoryTails updates the oldest blocks whose bodies and receipts are available.
+func (dl *downloadTester) SetHistoryTails(bodies uint64, receipts uint64) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.bodyTail, dl.receiptTail = bodies, receipts
+}
+
 // SetHead rewinds the local chain to a new head.
 func (dl *downloadTester) SetHead(head uint64) error {
 	dl.lock.Lock()
@@ -965,6 +991,132 @@ func testEmptyShortCircuit(t *testing.T, protocol uint, mode SyncMode) {
 	}
 }
 
+// Tests that fast sync skips the bodies and receipts of the blocks older than the
+// configured history limits, recording from where on they are available.
+func TestHistoryLimits(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase
+	tester.newPeer("peer", 66, chain)
+	tester.downloader.SetHistoryLimits(200, 100)
+
+	bodiesHave, receiptsHave := int32(0), int32(0)
+	tester.downloader.bodyFetchHook = func(headers []*types.Header) {
+		atomic.AddInt32(&bodiesHave, int32(len(headers)))
+	}
+	tester.downloader.receiptFetchHook = func(headers []*types.Header) {
+		atomic.AddInt32(&receiptsHave, int32(len(headers)))
+	}
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, chain.len())
+
+	// The tails must be set relative to the pivot, and only the data above them
+	// must have been retrieved
+	bodyTail, receiptTail := tester.HistoryTails()
+	pivot := uint64(chain.len()) - 1 - uint64(fsMinFullBlocks)
+	if bodyTail != pivot-200 || receiptTail != pivot-100 {
+		t.Fatalf("history tails mismatch: have %d/%d, want %d/%d", bodyTail, receiptTail, pivot-200, pivot-100)
+	}
+	bodiesNeeded, receiptsNeeded := 0, 0
+	for _, block := range chain.blockm {
+		if block.NumberU64() >= bodyTail && (len(block.Transactions()) > 0 || len(block.Uncles()) > 0) {
+			bodiesNeeded++
+		}
+	}
+	for hash, receipt := range chain.receiptm {
+		if chain.blockm[hash].NumberU64() >= receiptTail && len(receipt) > 0 {
+			receiptsNeeded++
+		}
+	}
+	if int(bodiesHave) != bodiesNeeded {
+		t.Errorf("body retrieval count mismatch: have %v, want %v", bodiesHave, bodiesNeeded)
+	}
+	if int(receiptsHave) != receiptsNeeded {
+		t.Errorf("receipt retrieval count mismatch: have %v, want %v", receiptsHave, receiptsNeeded)
+	}
+}
+
+// Tests that the headers below a trusted head are backfilled before the regular
+// sync continues, and that an interrupted backfill resumes where it left off.
+func TestBackfillSync(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	head := chain.headBlock()
+	if err := tester.downloader.SetTrustedHead(head.NumberU64(), head.Hash()); err != nil {
+		t.Fatalf("failed to set trusted head: %v", err)
+	}
+	// Backfill from a peer missing a header, which breaks the hash chain
+	brokenChain := chain.shorten(chain.len())
+	missing := brokenChain.len() / 4
+	delete(brokenChain.headerm, brokenChain.chain[missing])
+	tester.newPeer("faulty", 66, brokenChain)
+
+	if err := tester.sync("faulty", nil, FullSync); !errors.Is(err, errInvalidChain) {
+		t.Fatalf("faulty backfill error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	status := readBackfillStatus(tester.stateDb)
+	if status == nil || status.Tail > head.NumberU64() || status.Tail <= uint64(missing) {
+		t.Fatalf("backfill progress mismatch: have %+v, want tail in (%d, %d]", status, missing, head.NumberU64())
+	}
+	if header := rawdb.ReadBackfillHeader(tester.stateDb, status.Tail); header == nil || header.Hash() != chain.chain[status.Tail] {
+		t.Fatalf("backfilled tail header mismatch: have %v, want %x", header, chain.chain[status.Tail])
+	}
+	// Resume the backfill from a good peer and sync the rest of the chain
+	tester.newPeer("valid", 66, chain)
+	if err := tester.sync("valid", nil, FullSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, chain.len())
+
+	if status := readBackfillStatus(tester.stateDb); status != nil {
+		t.Errorf("backfill status not cleaned up: %+v", status)
+	}
+	for number := uint64(0); number <= head.NumberU64(); number++ {
+		if rawdb.ReadBackfillHeader(tester.stateDb, number) != nil {
+			t.Fatalf("backfilled header %d not cleaned up", number)
+		}
+	}
+}
+
+// Tests that a failed backfill doesn't prevent the regular sync, and that the
+// scheduled backfill can be abandoned.
+func TestBackfillFailureSync(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Schedule a backfill from a head the peer can't serve
+	chain := testChainBase.shorten(blockCacheMaxItems - 15)
+	if err := tester.downloader.SetTrustedHead(uint64(chain.len()), common.Hash{0x01}); err != nil {
+		t.Fatalf("failed to set trusted head: %v", err)
+	}
+	tester.newPeer("peer", 66, chain)
+	if err := tester.sync("peer", nil, FullSync); !errors.Is(err, errInvalidChain) {
+		t.Fatalf("backfill error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	assertOwnChain(t, tester, chain.len())
+
+	if readBackfillStatus(tester.stateDb) == nil {
+		t.Fatalf("failed backfill not retained for retrying")
+	}
+	if err := tester.downloader.ClearTrustedHead(); err != nil {
+		t.Fatalf("failed to clear trusted head: %v", err)
+	}
+	if status := readBackfillStatus(tester.stateDb); status != nil {
+		t.Fatalf("abandoned backfill not removed: %+v", status)
+	}
+}
+
 // Tests that headers are enqueued continuously, preventing malicious nodes from
 // stalling the downloader by feeding gapped header chains.
 func TestMissingHeaderAttack64Full(t *testing.T) { testMissingHeaderAttack(t, 64, FullSync) }
@@ -1736,3 +1888,44 @@ func testCheckpointEnforcement(t *testing.T, protocol uint, mode SyncMode) {
 		assertOwnChain(t, tester, chain.len())
 	}
 }
+
+// Tests that individual state entries missing from the local database can be
+// healed from a remote peer, independently of any chain synchronisation.
+func TestHealState65(t *testing.T) { testHealState(t, 65) }
+func TestHealState66(t *testing.T) { testHealState(t, 66) }
+
+func testHealState(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	tester.newPeer("peer", protocol, testChainBase)
+
+	// Drop the placeholder genesis state and heal it from the peer
+	root := testGenesis.Root()
+	tester.stateDb.Delete(root.Bytes())
+
+	sched := state.NewStateSync(root, tester.stateDb, nil)
+	if sched.Pending() == 0 {
+		t.Fatalf("no state entries scheduled for healing")
+	}
+	if err := tester.downloader.HealState(sched, nil); err != nil {
+		t.Fatalf("failed to heal state: %v", err)
+	}
+	statedb, err := state.New(root, state.NewDatabase(tester.stateDb), nil)
+	if err != nil {
+		t.Fatalf("failed to open healed state: %v", err)
+	}
+	if balance := statedb.GetBalance(testAddress); balance.Cmp(big.NewInt(1000000000)) != 0 {
+		t.Fatalf("healed balance mismatch: have %v, want %v", balance, 1000000000)
+	}
+	// Healing must wait for a running chain sync to be torn down
+	atomic.StoreInt32(&tester.downloader.synchronising, 1)
+
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := tester.downloader.HealState(sched, cancel); err != errCancelStateFetch {
+		t.Fatalf("healing during sync error mismatch: have %v, want %v", err, errCancelStateFetch)
+	}
+}
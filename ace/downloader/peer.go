@@ -265,6 +265,42 @@ func (p *peerConnection) setIdle(elapsed time.Duration, delivered int, throughpu
 		"miss", len(p.lacking), "rtt", p.rtt)
 }
 
+// HeaderThroughput retrieves the number of headers the peer is measured to
+// deliver per second.
+func (p *peerConnection) HeaderThroughput() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.headerThroughput
+}
+
+// BlockThroughput retrieves the number of block bodies the peer is measured to
+// deliver per second.
+func (p *peerConnection) BlockThroughput() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.blockThroughput
+}
+
+// ReceiptThroughput retrieves the number of receipts the peer is measured to
+// deliver per second.
+func (p *peerConnection) ReceiptThroughput() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.receiptThroughput
+}
+
+// StateThroughput retrieves the number of state entries the peer is measured to
+// deliver per second.
+func (p *peerConnection) StateThroughput() float64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.stateThroughput
+}
+
 // HeaderCapacity retrieves the peers header download allowance based on its
 // previously discovered throughput.
 func (p *peerConnection) HeaderCapacity(targetRTT time.Duration) int {
@@ -292,6 +328,44 @@ func (p *peerConnection) ReceiptCapacity(targetRTT time.Duration) int {
 	return int(math.Min(1+math.Max(1, p.receiptThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxReceiptFetch)))
 }
 
+// BlockDeadline retrieves the time the peer is expected to deliver the given number
+// of block bodies in based on its previously discovered throughput, after which
+// the request is considered straggling.
+func (p *peerConnection) BlockDeadline(items int, targetRTT time.Duration, ttl time.Duration) time.Duration {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return stragglerDeadline(items, p.blockThroughput, targetRTT, ttl)
+}
+
+// ReceiptDeadline retrieves the time the peer is expected to deliver the given
+// number of receipts in based on its previously discovered throughput, after
+// which the request is considered straggling.
+func (p *peerConnection) ReceiptDeadline(items int, targetRTT time.Duration, ttl time.Duration) time.Duration {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return stragglerDeadline(items, p.receiptThroughput, targetRTT, ttl)
+}
+
+// stragglerDeadline scales the expected delivery time of a number of items at the
+// given throughput into a straggler deadline, bounded by the target RTT from below
+// and the request timeout from above. Peers of unknown throughput only straggle
+// when their requests time out.
+func stragglerDeadline(items int, throughput float64, targetRTT time.Duration, ttl time.Duration) time.Duration {
+	if throughput <= 0 {
+		return ttl
+	}
+	expected := time.Duration(float64(items) / throughput * float64(time.Second))
+	if expected < targetRTT {
+		expected = targetRTT
+	}
+	if deadline := time.Duration(stragglerScaling) * expected; deadline < ttl {
+		return deadline
+	}
+	return ttl
+}
+
 // NodeDataCapacity retrieves the peers state download allowance based on its
 // previously discovered throughput.
 func (p *peerConnection) NodeDataCapacity(targetRTT time.Duration) int {
@@ -453,12 +527,7 @@ func (ps *peerSet) HeaderIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
 		return atomic.LoadInt32(&p.headerIdle) == 0
 	}
-	throughput := func(p *peerConnection) float64 {
-		p.lock.RLock()
-		defer p.lock.RUnlock()
-		return p.headerThroughput
-	}
-	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, (*peerConnection).HeaderThroughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
@@ -467,12 +536,7 @@ func (ps *peerSet) BodyIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
 		return atomic.LoadInt32(&p.blockIdle) == 0
 	}
-	throughput := func(p *peerConnection) float64 {
-		p.lock.RLock()
-		defer p.lock.RUnlock()
-		return p.blockThroughput
-	}
-	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, (*peerConnection).BlockThroughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
@@ -481,12 +545,7 @@ func (ps *peerSet) ReceiptIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
 		return atomic.LoadInt32(&p.receiptIdle) == 0
 	}
-	throughput := func(p *peerConnection) float64 {
-		p.lock.RLock()
-		defer p.lock.RUnlock()
-		return p.receiptThroughput
-	}
-	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, (*peerConnection).ReceiptThroughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
@@ -495,12 +554,7 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 	idle := func(p *peerConnection) bool {
 		return atomic.LoadInt32(&p.stateIdle) == 0
 	}
-	throughput := func(p *peerConnection) float64 {
-		p.lock.RLock()
-		defer p.lock.RUnlock()
-		return p.stateThroughput
-	}
-	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH64, eth.ETH66, idle, (*peerConnection).StateThroughput)
 }
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
@@ -527,6 +581,29 @@ func (ps *peerSet) idlePeers(minProtocol, maxProtocol uint, idleCheck func(*peer
 	return sortPeers.p, total
 }
 
+// medianThroughput returns the median of the throughputs of all the peers, as
+// retrieved by the given function.
+func (ps *peerSet) medianThroughput(throughput func(*peerConnection) float64) float64 {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	if len(ps.peers) == 0 {
+		return 0
+	}
+	tps := make([]float64, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		tps = append(tps, throughput(p))
+	}
+	sort.Float64s(tps)
+	return tps[len(tps)/2]
+}
+
+// slowPeer reports whether a peer of the given throughput is too slow compared
+// to the typical throughput of the peers to be trusted with full batches.
+func slowPeer(throughput, typical float64) bool {
+	return typical > 0 && throughput < typical*slowPeerRatio
+}
+
 // medianRTT returns the median RTT of the peerset, considering only the tuning
 // peers if there are more peers available.
 func (ps *peerSet) medianRTT() time.Duration {
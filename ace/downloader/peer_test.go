@@ -17,8 +17,10 @@
 package downloader
 
 import (
+	"fmt"
 	"sort"
 	"testing"
+	"time"
 )
 
 func TestPeerThroughputSorting(t *testing.T) {
@@ -51,3 +53,41 @@ func TestPeerThroughputSorting(t *testing.T) {
 	}
 
 }
+
+func TestSlowPeerDetection(t *testing.T) {
+	ps := newPeerSet()
+	for i, tp := range []float64{5, 100, 120, 80, 150} {
+		ps.peers[fmt.Sprintf("%d", i)] = &peerConnection{id: fmt.Sprintf("%d", i), headerThroughput: tp}
+	}
+	typical := ps.medianThroughput((*peerConnection).HeaderThroughput)
+	if typical != 100 {
+		t.Fatalf("median throughput mismatch: have %v, want %v", typical, 100)
+	}
+	if !slowPeer(5, typical) {
+		t.Errorf("peer at 5 items/s not detected as slow")
+	}
+	if slowPeer(80, typical) {
+		t.Errorf("peer at 80 items/s detected as slow")
+	}
+	if slowPeer(0, 0) {
+		t.Errorf("peer detected as slow without measurements")
+	}
+}
+
+func TestStragglerDeadline(t *testing.T) {
+	tests := []struct {
+		items      int
+		throughput float64
+		deadline   time.Duration
+	}{
+		{100, 0, time.Minute},           // Unknown throughput, straggles when timing out
+		{400, 100, 3 * 4 * time.Second}, // Expected delivery in four seconds
+		{10, 100, 3 * 2 * time.Second},  // Expected delivery bounded by the target RTT
+		{100, 1, time.Minute},           // Straggler deadline bounded by the timeout
+	}
+	for i, test := range tests {
+		if deadline := stragglerDeadline(test.items, test.throughput, 2*time.Second, time.Minute); deadline != test.deadline {
+			t.Errorf("test %d: deadline mismatch: have %v, want %v", i, deadline, test.deadline)
+		}
+	}
+}
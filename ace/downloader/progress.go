@@ -0,0 +1,68 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"time"
+)
+
+// Sync stages reported by StageProgress.
+const (
+	stageHeaders = iota
+	stageBodies
+	stageReceipts
+	stageState
+	stageBackfill
+	stageCount
+)
+
+var stageNames = [stageCount]string{"headers", "bodies", "receipts", "state", "backfill"}
+
+// StageProgress is the progress of a stage of the running sync.
+type StageProgress struct {
+	Stage     string  `json:"stage"`
+	Done      uint64  `json:"done"`      // Number of items completed since the sync started
+	Remaining uint64  `json:"remaining"` // Number of items known to be left
+	Rate      float64 `json:"rate"`      // Average number of items completed per second
+	ETA       uint64  `json:"eta"`       // Estimated number of seconds until completion, 0 if done or unknown
+}
+
+// stageMeter measures the progress of a sync stage from the position it started
+// at when the sync began.
+type stageMeter struct {
+	start   uint64    // Position of the stage when the sync started
+	started time.Time // Time the sync started
+}
+
+// progress reports the progress of the stage at the given position towards the
+// given target.
+func (m *stageMeter) progress(stage int, position, target uint64, now time.Time) StageProgress {
+	p := StageProgress{Stage: stageNames[stage]}
+	if position > m.start {
+		p.Done = position - m.start
+	}
+	if target > position {
+		p.Remaining = target - position
+	}
+	if elapsed := now.Sub(m.started).Seconds(); elapsed > 0 {
+		p.Rate = float64(p.Done) / elapsed
+	}
+	if p.Remaining > 0 && p.Rate > 0 {
+		p.ETA = uint64(float64(p.Remaining)/p.Rate + 0.5)
+	}
+	return p
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStageMeterProgress(t *testing.T) {
+	started := time.Now()
+	meter := stageMeter{start: 100, started: started}
+
+	tests := []struct {
+		position, target uint64
+		elapsed          time.Duration
+		want             StageProgress
+	}{
+		{100, 1100, 0, StageProgress{Stage: "bodies", Remaining: 1000}},                                             // Nothing done yet
+		{200, 1100, 10 * time.Second, StageProgress{Stage: "bodies", Done: 100, Remaining: 900, Rate: 10, ETA: 90}}, // Linear estimate
+		{1100, 1100, 20 * time.Second, StageProgress{Stage: "bodies", Done: 1000, Rate: 50}},                        // Stage completed
+		{50, 0, 10 * time.Second, StageProgress{Stage: "bodies"}},                                                   // Rewound below the start, target unknown
+	}
+	for i, tt := range tests {
+		if have := meter.progress(stageBodies, tt.position, tt.target, started.Add(tt.elapsed)); have != tt.want {
+			t.Errorf("test %d: progress mismatch: have %+v, want %+v", i, have, tt.want)
+		}
+	}
+}
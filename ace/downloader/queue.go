@@ -57,6 +57,9 @@ type fetchRequest struct {
 	From    uint64          // [eth/62] Requested chain element index (used for skeleton fills only)
 	Headers []*types.Header // [eth/62] Requested headers, sorted by request order
 	Time    time.Time       // Time when the request was made
+
+	Deadline  time.Time // Time after which the request is considered straggling (zero if never)
+	Straggled bool      // Whether the tasks of the request were reassigned to other peers
 }
 
 // fetchResult is a struct collecting partial results from data fetchers until
@@ -70,14 +73,14 @@ type fetchResult struct {
 	Receipts     types.Receipts
 }
 
-func newFetchResult(header *types.Header, fastSync bool) *fetchResult {
+func newFetchResult(header *types.Header, fetchBody bool, fetchReceipts bool) *fetchResult {
 	item := &fetchResult{
 		Header: header,
 	}
-	if !header.EmptyBody() {
+	if fetchBody {
 		item.pending |= (1 << bodyType)
 	}
-	if fastSync && !header.EmptyReceipts() {
+	if fetchReceipts {
 		item.pending |= (1 << receiptType)
 	}
 	return item
@@ -112,6 +115,9 @@ func (f *fetchResult) Done(kind uint) bool {
 type queue struct {
 	mode SyncMode // Synchronisation mode to decide on the block parts to schedule for fetching
 
+	bodyTail    uint64 // Oldest block whose body is to be fetched
+	receiptTail uint64 // Oldest block whose receipts are to be fetched
+
 	// Headers are "special", they download in batches, supported by a skeleton chain
 	headerHead      common.Hash                    // Hash of the last queued header to verify order
 	headerTaskPool  map[uint64]*types.Header       // Pending header retrieval tasks, mapping starting indexes to skeleton headers
@@ -132,8 +138,11 @@ type queue struct {
 	receiptTaskQueue *prque.Prque                  // Priority queue of the headers to fetch the receipts for
 	receiptPendPool  map[string]*fetchRequest      // Currently pending receipt retrieval operations
 
-	resultCache *resultStore       // Downloaded but not yet delivered fetch results
-	resultSize  common.StorageSize // Approximate size of a block (exponential moving average)
+	resultCache  *resultStore       // Downloaded but not yet delivered fetch results
+	resultSize   common.StorageSize // Approximate size of a block (exponential moving average)
+	resultMemory uint64             // Maximum amount of memory to use for block caching, accessed atomically
+
+	bodyHook func([]*types.Header, [][]*types.Transaction) // Method to call upon accepting block bodies
 
 	lock   *sync.RWMutex
 	active *sync.Cond
@@ -151,11 +160,18 @@ func newQueue(blockCacheLimit int, thresholdInitialSize int) *queue {
 		receiptTaskQueue: prque.New(nil),
 		active:           sync.NewCond(lock),
 		lock:             lock,
+		resultMemory:     uint64(blockCacheMemory),
 	}
 	q.Reset(blockCacheLimit, thresholdInitialSize)
 	return q
 }
 
+// SetMemoryLimit changes the amount of memory the queue may use for caching
+// downloaded results. The new limit takes effect on the next throttle update.
+func (q *queue) SetMemoryLimit(limit uint64) {
+	atomic.StoreUint64(&q.resultMemory, limit)
+}
+
 // Reset clears out the queue contents.
 func (q *queue) Reset(blockCacheLimit int, thresholdInitialSize int) {
 	q.lock.Lock()
@@ -163,6 +179,7 @@ func (q *queue) Reset(blockCacheLimit int, thresholdInitialSize int) {
 
 	q.closed = false
 	q.mode = FullSync
+	q.bodyTail, q.receiptTail = 0, 0
 
 	q.headerHead = common.Hash{}
 	q.headerPendPool = make(map[string]*fetchRequest)
@@ -318,7 +335,7 @@ func (q *queue) Schedule(headers []*types.Header, from uint64) []*types.Header {
 			q.blockTaskQueue.Push(header, -int64(header.Number.Uint64()))
 		}
 		// Queue for receipt retrieval
-		if q.mode == FastSync && !header.EmptyReceipts() {
+		if _, fetchReceipts := q.fetchParts(header); fetchReceipts {
 			if _, ok := q.receiptTaskPool[hash]; ok {
 				log.Warn("Header already scheduled for receipt fetch", "number", header.Number, "hash", hash)
 			} else {
@@ -380,7 +397,8 @@ func (q *queue) Results(block bool) []*fetchResult {
 	}
 	// Using the newly calibrated resultsize, figure out the new throttle limit
 	// on the result cache
-	throttleThreshold := uint64((common.StorageSize(blockCacheMemory) + q.resultSize - 1) / q.resultSize)
+	memory := common.StorageSize(atomic.LoadUint64(&q.resultMemory))
+	throttleThreshold := uint64((memory + q.resultSize - 1) / q.resultSize)
 	throttleThreshold = q.resultCache.SetThrottleThreshold(throttleThreshold)
 
 	// Log some info at certain times
@@ -503,15 +521,19 @@ func (q *queue) reserveHeaders(p *peerConnection, count int, taskPool map[common
 		// we can ask the resultcache if this header is within the
 		// "prioritized" segment of blocks. If it is not, we need to throttle
 
-		stale, throttle, item, err := q.resultCache.AddFetch(header, q.mode == FastSync)
+		fetchBody, fetchReceipts := q.fetchParts(header)
+		stale, throttle, item, err := q.resultCache.AddFetch(header, fetchBody, fetchReceipts)
 		if stale {
 			// Don't put back in the task queue, this item has already been
-			// delivered upstream
+			// delivered upstream. Tasks re-queued when their request straggled
+			// are expected to turn up here once fulfilled, anything else isn't.
 			taskQueue.PopItem()
 			progress = true
-			delete(taskPool, header.Hash())
+			if _, ok := taskPool[header.Hash()]; ok {
+				log.Error("Fetch reservation already delivered", "number", header.Number.Uint64())
+				delete(taskPool, header.Hash())
+			}
 			proc = proc - 1
-			log.Error("Fetch reservation already delivered", "number", header.Number.Uint64())
 			continue
 		}
 		if throttle {
@@ -594,8 +616,10 @@ func (q *queue) cancel(request *fetchRequest, taskQueue *prque.Prque, pendPool m
 	if request.From > 0 {
 		taskQueue.Push(request.From, -int64(request.From))
 	}
-	for _, header := range request.Headers {
-		taskQueue.Push(header, -int64(header.Number.Uint64()))
+	if !request.Straggled {
+		for _, header := range request.Headers {
+			taskQueue.Push(header, -int64(header.Number.Uint64()))
+		}
 	}
 	delete(pendPool, request.Peer.id)
 }
@@ -608,14 +632,18 @@ func (q *queue) Revoke(peerID string) {
 	defer q.lock.Unlock()
 
 	if request, ok := q.blockPendPool[peerID]; ok {
-		for _, header := range request.Headers {
-			q.blockTaskQueue.Push(header, -int64(header.Number.Uint64()))
+		if !request.Straggled {
+			for _, header := range request.Headers {
+				q.blockTaskQueue.Push(header, -int64(header.Number.Uint64()))
+			}
 		}
 		delete(q.blockPendPool, peerID)
 	}
 	if request, ok := q.receiptPendPool[peerID]; ok {
-		for _, header := range request.Headers {
-			q.receiptTaskQueue.Push(header, -int64(header.Number.Uint64()))
+		if !request.Straggled {
+			for _, header := range request.Headers {
+				q.receiptTaskQueue.Push(header, -int64(header.Number.Uint64()))
+			}
 		}
 		delete(q.receiptPendPool, peerID)
 	}
@@ -662,12 +690,15 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 			// Update the metrics with the timeout
 			timeoutMeter.Mark(1)
 
-			// Return any non satisfied requests to the pool
+			// Return any non satisfied requests to the pool, unless already returned
+			// when the request was found straggling
 			if request.From > 0 {
 				taskQueue.Push(request.From, -int64(request.From))
 			}
-			for _, header := range request.Headers {
-				taskQueue.Push(header, -int64(header.Number.Uint64()))
+			if !request.Straggled {
+				for _, header := range request.Headers {
+					taskQueue.Push(header, -int64(header.Number.Uint64()))
+				}
 			}
 			// Add the peer to the expiry report along the number of failed requests
 			expiries[id] = len(request.Headers)
@@ -679,6 +710,52 @@ func (q *queue) expire(timeout time.Duration, pendPool map[string]*fetchRequest,
 	return expiries
 }
 
+// StraggleBodies checks for in flight block body requests that exceeded their
+// deadline, returning their tasks to the queue for other peers to pick up. The
+// straggling deliveries are still accepted, whichever comes first fulfilling the
+// tasks. The straggling peers are returned along with the reassigned task counts.
+func (q *queue) StraggleBodies() map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.straggle(q.blockPendPool, q.blockTaskQueue, bodyStragglerMeter)
+}
+
+// StraggleReceipts checks for in flight receipt requests that exceeded their
+// deadline, returning their tasks to the queue for other peers to pick up. The
+// straggling deliveries are still accepted, whichever comes first fulfilling the
+// tasks. The straggling peers are returned along with the reassigned task counts.
+func (q *queue) StraggleReceipts() map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.straggle(q.receiptPendPool, q.receiptTaskQueue, receiptStragglerMeter)
+}
+
+// straggle is the generic check that returns the tasks of the requests past their
+// deadline into the task queue, leaving the requests themselves pending.
+//
+// Note, this method expects the queue lock to be already held.
+func (q *queue) straggle(pendPool map[string]*fetchRequest, taskQueue *prque.Prque, stragglerMeter metrics.Meter) map[string]int {
+	var (
+		now        = time.Now()
+		stragglers = make(map[string]int)
+	)
+	for id, request := range pendPool {
+		if request.Straggled || request.Deadline.IsZero() || now.Before(request.Deadline) {
+			continue
+		}
+		for _, header := range request.Headers {
+			taskQueue.Push(header, -int64(header.Number.Uint64()))
+		}
+		request.Straggled = true
+		stragglerMeter.Mark(int64(len(request.Headers)))
+
+		stragglers[id] = len(request.Headers)
+	}
+	return stragglers
+}
+
 // DeliverHeaders injects a header retrieval response into the header results
 // cache. This method either accepts all headers it received, or none of them
 // if they do not map correctly to the skeleton.
@@ -793,13 +870,24 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLi
 		return nil
 	}
 
+	var (
+		headers []*types.Header
+		txs     [][]*types.Transaction
+	)
 	reconstruct := func(index int, result *fetchResult) {
 		result.Transactions = txLists[index]
 		result.Uncles = uncleLists[index]
 		result.SetBodyDone()
+
+		headers = append(headers, result.Header)
+		txs = append(txs, txLists[index])
 	}
-	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool,
+	accepted, err := q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool,
 		bodyReqTimer, len(txLists), validate, reconstruct)
+	if q.bodyHook != nil && len(headers) > 0 {
+		q.bodyHook(headers, txs)
+	}
+	return accepted, err
 }
 
 // DeliverReceipts injects a receipt retrieval response into the results queue.
@@ -868,6 +956,12 @@ func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header,
 	}
 
 	for _, header := range request.Headers[:i] {
+		// Skip the tasks already fulfilled by another peer, if they were reassigned
+		// from a straggling request or vice versa
+		if _, ok := taskPool[hashes[accepted]]; !ok {
+			accepted++
+			continue
+		}
 		if res, stale, err := q.resultCache.GetDeliverySlot(header.Number.Uint64()); err == nil {
 			reconstruct(accepted, res)
 		} else {
@@ -881,9 +975,12 @@ func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header,
 		delete(taskPool, hashes[accepted])
 		accepted++
 	}
-	// Return all failed or missing fetches to the queue
-	for _, header := range request.Headers[accepted:] {
-		taskQueue.Push(header, -int64(header.Number.Uint64()))
+	// Return all failed or missing fetches to the queue, unless already returned
+	// when the request was found straggling
+	if !request.Straggled {
+		for _, header := range request.Headers[accepted:] {
+			taskQueue.Push(header, -int64(header.Number.Uint64()))
+		}
 	}
 	// Wake up Results
 	if accepted > 0 {
@@ -899,6 +996,24 @@ func (q *queue) deliver(id string, taskPool map[common.Hash]*types.Header,
 	return accepted, fmt.Errorf("%w: %v", failure, errStaleDelivery)
 }
 
+// SetHistoryTails configures the oldest blocks whose bodies and receipts are to
+// be fetched, older ones being delivered without them.
+func (q *queue) SetHistoryTails(bodies uint64, receipts uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.bodyTail, q.receiptTail = bodies, receipts
+}
+
+// fetchParts returns whether the body and the receipts of a block are to be
+// fetched. The caller must hold the lock.
+func (q *queue) fetchParts(header *types.Header) (body bool, receipts bool) {
+	number := header.Number.Uint64()
+	body = !header.EmptyBody() && number >= q.bodyTail
+	receipts = q.mode == FastSync && !header.EmptyReceipts() && number >= q.receiptTail
+	return body, receipts
+}
+
 // Prepare configures the result cache to allow accepting and caching inbound
 // fetch results.
 func (q *queue) Prepare(offset uint64, mode SyncMode) {
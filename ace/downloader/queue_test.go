@@ -255,6 +255,119 @@ func TestEmptyBlocks(t *testing.T) {
 	}
 }
 
+// bodiesOf returns the block bodies of the headers of a fetch request.
+func bodiesOf(req *fetchRequest) ([][]*types.Transaction, [][]*types.Header) {
+	var (
+		txs    [][]*types.Transaction
+		uncles [][]*types.Header
+	)
+	for _, header := range req.Headers {
+		block := chain.blocks[header.Number.Uint64()-1]
+		txs = append(txs, block.Transactions())
+		uncles = append(uncles, block.Uncles())
+	}
+	return txs, uncles
+}
+
+// TestStragglers tests that the tasks of straggling requests are reassigned to
+// other peers, and whichever delivery comes first fulfills them.
+func TestStragglers(t *testing.T) {
+	q := newQueue(10, 10)
+	q.Prepare(1, FastSync)
+	q.Schedule(chain.headers(), 1)
+
+	// Reserve a batch for the first peer and reassign it once past its deadline
+	slow := dummyPeer("slow")
+	req, _, _ := q.ReserveBodies(slow, 50)
+	if req == nil {
+		t.Fatal("no body fetch tasks reserved")
+	}
+	pending := q.PendingBlocks()
+	if stragglers := q.StraggleBodies(); len(stragglers) != 0 {
+		t.Fatalf("requests without deadline straggling: %v", stragglers)
+	}
+	req.Deadline = time.Now().Add(-time.Second)
+	if stragglers := q.StraggleBodies(); stragglers[slow.id] != len(req.Headers) {
+		t.Fatalf("straggler mismatch: have %v, want %d tasks of %s", stragglers, len(req.Headers), slow.id)
+	}
+	if stragglers := q.StraggleBodies(); len(stragglers) != 0 {
+		t.Fatalf("straggling request reassigned twice: %v", stragglers)
+	}
+	if have, want := q.PendingBlocks(), pending+len(req.Headers); have != want {
+		t.Fatalf("pending block count mismatch: have %d, want %d", have, want)
+	}
+	// Another peer must pick up the reassigned tasks and deliver them
+	fast := dummyPeer("fast")
+	reassigned, _, _ := q.ReserveBodies(fast, 50)
+	if reassigned == nil || reassigned.Headers[0] != req.Headers[0] {
+		t.Fatal("straggling tasks not reassigned")
+	}
+	txs, uncles := bodiesOf(reassigned)
+	if accepted, err := q.DeliverBodies(fast.id, txs, uncles); err != nil || accepted != len(reassigned.Headers) {
+		t.Fatalf("reassigned delivery failed: accepted %d, err %v", accepted, err)
+	}
+	// The late delivery of the straggler must be accepted without requeueing anything
+	pending = q.PendingBlocks()
+	txs, uncles = bodiesOf(req)
+	if accepted, err := q.DeliverBodies(slow.id, txs, uncles); err != nil || accepted != len(req.Headers) {
+		t.Fatalf("straggling delivery failed: accepted %d, err %v", accepted, err)
+	}
+	if have := q.PendingBlocks(); have != pending {
+		t.Fatalf("pending block count mismatch: have %d, want %d", have, pending)
+	}
+	if q.InFlightBlocks() {
+		t.Fatal("requests still in flight")
+	}
+}
+
+// Tests that the tasks of a straggling request, which got delivered upstream by
+// the original peer meanwhile, are silently dropped when reserved again.
+func TestStraggledTasksDelivered(t *testing.T) {
+	q := newQueue(10, 10)
+	q.Prepare(1, FullSync)
+	q.Schedule(chain.headers(), 1)
+
+	slow := dummyPeer("slow")
+	req, _, _ := q.ReserveBodies(slow, 50)
+	if req == nil {
+		t.Fatal("no body fetch tasks reserved")
+	}
+	req.Deadline = time.Now().Add(-time.Second)
+	if stragglers := q.StraggleBodies(); stragglers[slow.id] != len(req.Headers) {
+		t.Fatalf("straggler mismatch: have %v, want %d tasks of %s", stragglers, len(req.Headers), slow.id)
+	}
+	// Deliver the straggling request and pass its results upstream
+	txs, uncles := bodiesOf(req)
+	if accepted, err := q.DeliverBodies(slow.id, txs, uncles); err != nil || accepted != len(req.Headers) {
+		t.Fatalf("straggling delivery failed: accepted %d, err %v", accepted, err)
+	}
+	if results := q.Results(false); len(results) < len(req.Headers) {
+		t.Fatalf("result count mismatch: have %d, want at least %d", len(results), len(req.Headers))
+	}
+	// The re-queued tasks must be skipped without complaints
+	var errors int
+	handler := log.Root().GetHandler()
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		if r.Lvl <= log.LvlError {
+			errors++
+		}
+		return nil
+	}))
+	defer log.Root().SetHandler(handler)
+
+	fast := dummyPeer("fast")
+	next, _, _ := q.ReserveBodies(fast, 50)
+	if next == nil {
+		t.Fatal("no body fetch tasks reserved")
+	}
+	if have, last := next.Headers[0].Number.Uint64(), req.Headers[len(req.Headers)-1].Number.Uint64(); have <= last {
+		t.Fatalf("delivered task #%d reserved again, last delivered #%d", have, last)
+	}
+	if errors != 0 {
+		t.Fatalf("errors logged for delivered straggling tasks: %d", errors)
+	}
+}
+
 // XTestDelivery does some more extensive testing of events that happen,
 // blocks that become known and peers that make reservations and deliveries.
 // disabled since it's not really a unit-test, but can be executed to test
@@ -19,14 +19,17 @@ package downloader
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/trie"
 	"golang.org/x/crypto/sha3"
 )
@@ -76,6 +79,51 @@ func (d *Downloader) syncState(root common.Hash) *stateSync {
 	return s
 }
 
+// HealState retrieves the trie nodes and contract codes scheduled in the given
+// trie sync from the connected peers and writes them into the local database.
+// It is meant to repair individual state entries that went missing or got
+// corrupted on disk. Healing takes precedence over chain synchronisation: any
+// running sync is aborted and new ones are rejected until healing finishes.
+func (d *Downloader) HealState(sched *trie.Sync, cancel <-chan struct{}) error {
+	for !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		d.Cancel()
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-cancel:
+			return errCancelStateFetch
+		case <-d.quitCh:
+			return errCancelStateFetch
+		}
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	// Create a fresh cancel channel, the previous sync cycle closed its own
+	d.cancelLock.Lock()
+	d.cancelCh = make(chan struct{})
+	d.cancelPeer = ""
+	d.cancelLock.Unlock()
+
+	defer d.Cancel()
+
+	// Start a state sync on the empty root and swap in the requested tasks
+	s := newStateSync(d, types.EmptyRootHash)
+	s.sched = sched
+	s.heal = true
+
+	select {
+	case d.stateSyncStart <- s:
+		<-s.started
+	case <-d.quitCh:
+		return errCancelStateFetch
+	}
+	select {
+	case <-s.done:
+		return s.Wait()
+	case <-cancel:
+		return s.Cancel()
+	}
+}
+
 // stateFetcher manages the active state sync and accepts requests
 // on its behalf.
 func (d *Downloader) stateFetcher() {
@@ -262,6 +310,7 @@ type stateSync struct {
 
 	root   common.Hash        // State root currently being synced
 	sched  *trie.Sync         // State trie sync scheduler defining the tasks
+	heal   bool               // Whether individual entries are healed instead of a full root
 	keccak crypto.KeccakState // Keccak256 hasher to verify deliveries with
 
 	trieTasks map[common.Hash]*trieTask // Set of trie node tasks currently queued for retrieval
@@ -314,7 +363,7 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // finish.
 func (s *stateSync) run() {
 	close(s.started)
-	if s.d.snapSync {
+	if s.d.snapSync && !s.heal {
 		s.err = s.d.SnapSyncer.Sync(s.root, s.cancel)
 	} else {
 		s.err = s.loop()
@@ -383,6 +432,7 @@ func (s *stateSync) loop() (err error) {
 					// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 					req.peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", req.peer.id)
 				} else {
+					s.d.penalize(req.peer.id, p2p.OffenceTimeout)
 					s.d.dropPeer(req.peer.id)
 
 					// If this peer was the master peer, abort sync immediately
@@ -20,11 +20,16 @@ import (
 	"fmt"
 
 	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/p2p"
 )
 
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id string)
 
+// peerPenaltyFn is a callback type for reporting the misbehaviour of a peer to
+// its reputation tracking.
+type peerPenaltyFn func(id string, offence p2p.Offence)
+
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() string
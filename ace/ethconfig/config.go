@@ -67,6 +67,9 @@ var Defaults = Config{
 	},
 	NetworkId:               1,
 	TxLookupLimit:           2350000,
+	BloomBitsBlocks:         params.BloomBitsBlocks,
+	HeadWatchdogRotation:    25,
+	ObserverServeRate:       10,
 	LightPeers:              100,
 	UltraLightFraction:      75,
 	DatabaseCache:           512,
@@ -81,11 +84,16 @@ var Defaults = Config{
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+		Ordering: miner.OrderingPrice,
 	},
-	TxPool:      core.DefaultTxPoolConfig,
-	RPCGasCap:   25000000,
-	GPO:         FullNodeGPO,
-	RPCTxFeeCap: 1, // 1 ether
+	TxPool:            core.DefaultTxPoolConfig,
+	UserOpPool:        core.DefaultUserOpPoolConfig,
+	RPCGasCap:         25000000,
+	GPO:               FullNodeGPO,
+	RPCTxFeeCap:       1, // 1 ether
+	RPCLogsPageSize:   10000,
+	RPCSafeDepth:      12,
+	RPCFinalizedDepth: 64,
 }
 
 func init() {
@@ -129,11 +137,34 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
-	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	TxLookupLimit   uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	LogIndex        bool   `toml:",omitempty"` // Whether to maintain the per-contract log index for address-constrained log queries
+	CallIndex       bool   `toml:",omitempty"` // Whether to trace blocks to maintain the per-contract index of calling transactions
+	BloomBitsBlocks uint64 `toml:",omitempty"` // Number of blocks per bloombits index section (changing it drops the index)
+
+	// History limits of fast/snap sync. Bodies and receipts of the blocks older
+	// than the limits are not downloaded, zero meaning the entire history.
+	TransactionHistory uint64 `toml:",omitempty"` // Number of recent blocks whose bodies (transactions) are downloaded
+	LogHistory         uint64 `toml:",omitempty"` // Number of recent blocks whose receipts (logs) are downloaded
+
+	// Number of recent blocks whose state can be reconstructed from reverse state
+	// diffs when not running an archive node, zero meaning disabled.
+	StateHistory uint64 `toml:",omitempty"`
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
+	// Trusted head to backfill the header chain from before syncing
+	TrustedHead *downloader.TrustedHead `toml:"-"`
+
+	// Head watchdog options
+	HeadWatchdog         time.Duration `toml:",omitempty"` // Time without head progress after which peers are rotated (0 = disabled)
+	HeadWatchdogRotation int           `toml:",omitempty"` // Percentage of peers to drop when the head stalls
+
+	// Observer mode options
+	Observer          bool `toml:",omitempty"` // Whether to never announce or forward blocks and transactions
+	ObserverServeRate int  `toml:",omitempty"` // Maximum number of eth and les requests served per second in observer mode
+
 	// Light client options
 	LightServ          int  `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightIngress       int  `toml:",omitempty"` // Incoming bandwidth limit for light servers
@@ -147,12 +178,15 @@ type Config struct {
 	UltraLightServers      []string `toml:",omitempty"` // List of trusted ultra light servers
 	UltraLightFraction     int      `toml:",omitempty"` // Percentage of trusted servers to accept an announcement
 	UltraLightOnlyAnnounce bool     `toml:",omitempty"` // Whether to only announce headers, or also serve them
+	UltraLightConfigFile   string   `toml:",omitempty"` // JSON file with the trusted servers and fraction, reloaded on change
 
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
 	DatabaseFreezer    string
+	ReadOnly           bool `toml:",omitempty"` // Open the database read-only and disable all chain mutations
+	DatabaseCompaction bool `toml:",omitempty"` // Compact the database in the background while the node is idle
 
 	TrieCleanCache          int
 	TrieCleanCacheJournal   string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
@@ -160,6 +194,7 @@ type Config struct {
 	TrieDirtyCache          int
 	TrieTimeout             time.Duration
 	SnapshotCache           int
+	CacheBudget             int `toml:",omitempty"` // Memory (MB) dynamically apportioned between resizable caches (0 = static)
 	Preimages               bool
 
 	// Mining options
@@ -171,6 +206,9 @@ type Config struct {
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
+	// User operation pool options, for serving ERC-4337 user operations
+	UserOpPool core.UserOpPoolConfig
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -193,13 +231,40 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64 `toml:",omitempty"`
 
+	// RPCReturnDataCap is the global cap on the size of the data returned by
+	// eth_call, in bytes. Larger results are reported as truncated.
+	RPCReturnDataCap uint64 `toml:",omitempty"`
+
+	// RPCLogsCap is the global cap on the number of logs returned by a single
+	// log query. Larger results are reported as truncated.
+	RPCLogsCap uint64 `toml:",omitempty"`
+
+	// RPCLogsPageSize is the maximum number of logs returned by a page of a
+	// paginated log query, falling back to RPCLogsCap if zero.
+	RPCLogsPageSize uint64 `toml:",omitempty"`
+
+	// RPCSafeDepth and RPCFinalizedDepth are the number of confirmations after
+	// which blocks are reported as safe and finalized, unless the consensus
+	// engine provides finality markers itself.
+	RPCSafeDepth      uint64 `toml:",omitempty"`
+	RPCFinalizedDepth uint64 `toml:",omitempty"`
+
+	// EngineAPI enables the engine API on the authenticated RPC endpoint, letting
+	// an external consensus client choose the chain head and build blocks.
+	EngineAPI bool `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
 	// CheckpointOracle is the configuration for checkpoint oracle.
 	CheckpointOracle *params.CheckpointOracleConfig `toml:",omitempty"`
 
-	// Berlin block override (TODO: remove after the fork)
+	// ForkOverrides reschedules upcoming forks of the chain config, keyed by
+	// fork name (e.g. "berlin").
+	ForkOverrides params.ForkOverrides `toml:",omitempty"`
+
+	// Deprecated: use ForkOverrides instead. The value is moved into
+	// ForkOverrides["berlin"] when the node configuration is assembled.
 	OverrideBerlin *big.Int `toml:",omitempty"`
 }
 
@@ -3,6 +3,7 @@
 package ethconfig
 
 import (
+	"math/big"
 	"time"
 
 	"github.com/acent/go-acent/common"
@@ -24,32 +25,48 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs       []string
 		NoPruning               bool
 		NoPrefetch              bool
-		TxLookupLimit           uint64                 `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               int                    `toml:",omitempty"`
-		LightIngress            int                    `toml:",omitempty"`
-		LightEgress             int                    `toml:",omitempty"`
-		LightPeers              int                    `toml:",omitempty"`
-		LightNoPrune            bool                   `toml:",omitempty"`
-		LightNoSyncServe        bool                   `toml:",omitempty"`
-		SyncFromCheckpoint      bool                   `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      int                    `toml:",omitempty"`
-		UltraLightOnlyAnnounce  bool                   `toml:",omitempty"`
-		SkipBcVersionCheck      bool                   `toml:"-"`
-		DatabaseHandles         int                    `toml:"-"`
+		TxLookupLimit           uint64                  `toml:",omitempty"`
+		LogIndex                bool                    `toml:",omitempty"`
+		CallIndex               bool                    `toml:",omitempty"`
+		BloomBitsBlocks         uint64                  `toml:",omitempty"`
+		TransactionHistory      uint64                  `toml:",omitempty"`
+		LogHistory              uint64                  `toml:",omitempty"`
+		StateHistory            uint64                  `toml:",omitempty"`
+		Whitelist               map[uint64]common.Hash  `toml:"-"`
+		TrustedHead             *downloader.TrustedHead `toml:"-"`
+		HeadWatchdog            time.Duration           `toml:",omitempty"`
+		HeadWatchdogRotation    int                     `toml:",omitempty"`
+		Observer                bool                    `toml:",omitempty"`
+		ObserverServeRate       int                     `toml:",omitempty"`
+		LightServ               int                     `toml:",omitempty"`
+		LightIngress            int                     `toml:",omitempty"`
+		LightEgress             int                     `toml:",omitempty"`
+		LightPeers              int                     `toml:",omitempty"`
+		LightNoPrune            bool                    `toml:",omitempty"`
+		LightNoSyncServe        bool                    `toml:",omitempty"`
+		SyncFromCheckpoint      bool                    `toml:",omitempty"`
+		UltraLightServers       []string                `toml:",omitempty"`
+		UltraLightFraction      int                     `toml:",omitempty"`
+		UltraLightOnlyAnnounce  bool                    `toml:",omitempty"`
+		UltraLightConfigFile    string                  `toml:",omitempty"`
+		SkipBcVersionCheck      bool                    `toml:"-"`
+		DatabaseHandles         int                     `toml:"-"`
 		DatabaseCache           int
 		DatabaseFreezer         string
+		ReadOnly                bool `toml:",omitempty"`
+		DatabaseCompaction      bool `toml:",omitempty"`
 		TrieCleanCache          int
 		TrieCleanCacheJournal   string        `toml:",omitempty"`
 		TrieCleanCacheRejournal time.Duration `toml:",omitempty"`
 		TrieDirtyCache          int
 		TrieTimeout             time.Duration
 		SnapshotCache           int
+		CacheBudget             int `toml:",omitempty"`
 		Preimages               bool
 		Miner                   miner.Config
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
+		UserOpPool              core.UserOpPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
@@ -57,8 +74,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		EVMInterpreter          string
 		RPCGasCap               uint64                         `toml:",omitempty"`
 		RPCTxFeeCap             float64                        `toml:",omitempty"`
+		RPCReturnDataCap        uint64                         `toml:",omitempty"`
+		RPCLogsCap              uint64                         `toml:",omitempty"`
+		RPCLogsPageSize         uint64                         `toml:",omitempty"`
+		RPCSafeDepth            uint64                         `toml:",omitempty"`
+		RPCFinalizedDepth       uint64                         `toml:",omitempty"`
+		EngineAPI               bool                           `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		ForkOverrides           params.ForkOverrides           `toml:",omitempty"`
+		OverrideBerlin          *big.Int                       `toml:",omitempty"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -69,7 +94,18 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.LogIndex = c.LogIndex
+	enc.CallIndex = c.CallIndex
+	enc.BloomBitsBlocks = c.BloomBitsBlocks
+	enc.TransactionHistory = c.TransactionHistory
+	enc.LogHistory = c.LogHistory
+	enc.StateHistory = c.StateHistory
 	enc.Whitelist = c.Whitelist
+	enc.TrustedHead = c.TrustedHead
+	enc.HeadWatchdog = c.HeadWatchdog
+	enc.HeadWatchdogRotation = c.HeadWatchdogRotation
+	enc.Observer = c.Observer
+	enc.ObserverServeRate = c.ObserverServeRate
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
 	enc.LightEgress = c.LightEgress
@@ -80,20 +116,25 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.UltraLightServers = c.UltraLightServers
 	enc.UltraLightFraction = c.UltraLightFraction
 	enc.UltraLightOnlyAnnounce = c.UltraLightOnlyAnnounce
+	enc.UltraLightConfigFile = c.UltraLightConfigFile
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
 	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.ReadOnly = c.ReadOnly
+	enc.DatabaseCompaction = c.DatabaseCompaction
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieCleanCacheJournal = c.TrieCleanCacheJournal
 	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
+	enc.CacheBudget = c.CacheBudget
 	enc.Preimages = c.Preimages
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
+	enc.UserOpPool = c.UserOpPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
@@ -101,8 +142,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.EVMInterpreter = c.EVMInterpreter
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCReturnDataCap = c.RPCReturnDataCap
+	enc.RPCLogsCap = c.RPCLogsCap
+	enc.RPCLogsPageSize = c.RPCLogsPageSize
+	enc.RPCSafeDepth = c.RPCSafeDepth
+	enc.RPCFinalizedDepth = c.RPCFinalizedDepth
+	enc.EngineAPI = c.EngineAPI
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
+	enc.ForkOverrides = c.ForkOverrides
+	enc.OverrideBerlin = c.OverrideBerlin
 	return &enc, nil
 }
 
@@ -116,32 +165,48 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs       []string
 		NoPruning               *bool
 		NoPrefetch              *bool
-		TxLookupLimit           *uint64                `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               *int                   `toml:",omitempty"`
-		LightIngress            *int                   `toml:",omitempty"`
-		LightEgress             *int                   `toml:",omitempty"`
-		LightPeers              *int                   `toml:",omitempty"`
-		LightNoPrune            *bool                  `toml:",omitempty"`
-		LightNoSyncServe        *bool                  `toml:",omitempty"`
-		SyncFromCheckpoint      *bool                  `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      *int                   `toml:",omitempty"`
-		UltraLightOnlyAnnounce  *bool                  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool                  `toml:"-"`
-		DatabaseHandles         *int                   `toml:"-"`
+		TxLookupLimit           *uint64                 `toml:",omitempty"`
+		LogIndex                *bool                   `toml:",omitempty"`
+		CallIndex               *bool                   `toml:",omitempty"`
+		BloomBitsBlocks         *uint64                 `toml:",omitempty"`
+		TransactionHistory      *uint64                 `toml:",omitempty"`
+		LogHistory              *uint64                 `toml:",omitempty"`
+		StateHistory            *uint64                 `toml:",omitempty"`
+		Whitelist               map[uint64]common.Hash  `toml:"-"`
+		TrustedHead             *downloader.TrustedHead `toml:"-"`
+		HeadWatchdog            *time.Duration          `toml:",omitempty"`
+		HeadWatchdogRotation    *int                    `toml:",omitempty"`
+		Observer                *bool                   `toml:",omitempty"`
+		ObserverServeRate       *int                    `toml:",omitempty"`
+		LightServ               *int                    `toml:",omitempty"`
+		LightIngress            *int                    `toml:",omitempty"`
+		LightEgress             *int                    `toml:",omitempty"`
+		LightPeers              *int                    `toml:",omitempty"`
+		LightNoPrune            *bool                   `toml:",omitempty"`
+		LightNoSyncServe        *bool                   `toml:",omitempty"`
+		SyncFromCheckpoint      *bool                   `toml:",omitempty"`
+		UltraLightServers       []string                `toml:",omitempty"`
+		UltraLightFraction      *int                    `toml:",omitempty"`
+		UltraLightOnlyAnnounce  *bool                   `toml:",omitempty"`
+		UltraLightConfigFile    *string                 `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                   `toml:"-"`
+		DatabaseHandles         *int                    `toml:"-"`
 		DatabaseCache           *int
 		DatabaseFreezer         *string
+		ReadOnly                *bool `toml:",omitempty"`
+		DatabaseCompaction      *bool `toml:",omitempty"`
 		TrieCleanCache          *int
 		TrieCleanCacheJournal   *string        `toml:",omitempty"`
 		TrieCleanCacheRejournal *time.Duration `toml:",omitempty"`
 		TrieDirtyCache          *int
 		TrieTimeout             *time.Duration
 		SnapshotCache           *int
+		CacheBudget             *int `toml:",omitempty"`
 		Preimages               *bool
 		Miner                   *miner.Config
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
+		UserOpPool              *core.UserOpPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
@@ -149,8 +214,16 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		EVMInterpreter          *string
 		RPCGasCap               *uint64                        `toml:",omitempty"`
 		RPCTxFeeCap             *float64                       `toml:",omitempty"`
+		RPCReturnDataCap        *uint64                        `toml:",omitempty"`
+		RPCLogsCap              *uint64                        `toml:",omitempty"`
+		RPCLogsPageSize         *uint64                        `toml:",omitempty"`
+		RPCSafeDepth            *uint64                        `toml:",omitempty"`
+		RPCFinalizedDepth       *uint64                        `toml:",omitempty"`
+		EngineAPI               *bool                          `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		ForkOverrides           params.ForkOverrides           `toml:",omitempty"`
+		OverrideBerlin          *big.Int                       `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -180,9 +253,42 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
+	if dec.CallIndex != nil {
+		c.CallIndex = *dec.CallIndex
+	}
+	if dec.BloomBitsBlocks != nil {
+		c.BloomBitsBlocks = *dec.BloomBitsBlocks
+	}
+	if dec.TransactionHistory != nil {
+		c.TransactionHistory = *dec.TransactionHistory
+	}
+	if dec.LogHistory != nil {
+		c.LogHistory = *dec.LogHistory
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
 	if dec.Whitelist != nil {
 		c.Whitelist = dec.Whitelist
 	}
+	if dec.TrustedHead != nil {
+		c.TrustedHead = dec.TrustedHead
+	}
+	if dec.HeadWatchdog != nil {
+		c.HeadWatchdog = *dec.HeadWatchdog
+	}
+	if dec.HeadWatchdogRotation != nil {
+		c.HeadWatchdogRotation = *dec.HeadWatchdogRotation
+	}
+	if dec.Observer != nil {
+		c.Observer = *dec.Observer
+	}
+	if dec.ObserverServeRate != nil {
+		c.ObserverServeRate = *dec.ObserverServeRate
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -213,6 +319,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.UltraLightOnlyAnnounce != nil {
 		c.UltraLightOnlyAnnounce = *dec.UltraLightOnlyAnnounce
 	}
+	if dec.UltraLightConfigFile != nil {
+		c.UltraLightConfigFile = *dec.UltraLightConfigFile
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -225,6 +334,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseFreezer != nil {
 		c.DatabaseFreezer = *dec.DatabaseFreezer
 	}
+	if dec.ReadOnly != nil {
+		c.ReadOnly = *dec.ReadOnly
+	}
+	if dec.DatabaseCompaction != nil {
+		c.DatabaseCompaction = *dec.DatabaseCompaction
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -243,6 +358,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SnapshotCache != nil {
 		c.SnapshotCache = *dec.SnapshotCache
 	}
+	if dec.CacheBudget != nil {
+		c.CacheBudget = *dec.CacheBudget
+	}
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
@@ -255,6 +373,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxPool != nil {
 		c.TxPool = *dec.TxPool
 	}
+	if dec.UserOpPool != nil {
+		c.UserOpPool = *dec.UserOpPool
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
@@ -276,11 +397,35 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCReturnDataCap != nil {
+		c.RPCReturnDataCap = *dec.RPCReturnDataCap
+	}
+	if dec.RPCLogsCap != nil {
+		c.RPCLogsCap = *dec.RPCLogsCap
+	}
+	if dec.RPCLogsPageSize != nil {
+		c.RPCLogsPageSize = *dec.RPCLogsPageSize
+	}
+	if dec.RPCSafeDepth != nil {
+		c.RPCSafeDepth = *dec.RPCSafeDepth
+	}
+	if dec.RPCFinalizedDepth != nil {
+		c.RPCFinalizedDepth = *dec.RPCFinalizedDepth
+	}
+	if dec.EngineAPI != nil {
+		c.EngineAPI = *dec.EngineAPI
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
 	if dec.CheckpointOracle != nil {
 		c.CheckpointOracle = dec.CheckpointOracle
 	}
+	if dec.ForkOverrides != nil {
+		c.ForkOverrides = dec.ForkOverrides
+	}
+	if dec.OverrideBerlin != nil {
+		c.OverrideBerlin = dec.OverrideBerlin
+	}
 	return nil
 }
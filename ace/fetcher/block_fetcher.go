@@ -46,10 +46,11 @@ const (
 )
 
 var (
-	blockAnnounceInMeter   = metrics.NewRegisteredMeter("eth/fetcher/block/announces/in", nil)
-	blockAnnounceOutTimer  = metrics.NewRegisteredTimer("eth/fetcher/block/announces/out", nil)
-	blockAnnounceDropMeter = metrics.NewRegisteredMeter("eth/fetcher/block/announces/drop", nil)
-	blockAnnounceDOSMeter  = metrics.NewRegisteredMeter("eth/fetcher/block/announces/dos", nil)
+	blockAnnounceInMeter     = metrics.NewRegisteredMeter("eth/fetcher/block/announces/in", nil)
+	blockAnnounceOutTimer    = metrics.NewRegisteredTimer("eth/fetcher/block/announces/out", nil)
+	blockAnnounceDropMeter   = metrics.NewRegisteredMeter("eth/fetcher/block/announces/drop", nil)
+	blockAnnounceDOSMeter    = metrics.NewRegisteredMeter("eth/fetcher/block/announces/dos", nil)
+	blockAnnounceRepeatMeter = metrics.NewRegisteredMeter("eth/fetcher/block/announces/repeat", nil)
 
 	blockBroadcastInMeter   = metrics.NewRegisteredMeter("eth/fetcher/block/broadcasts/in", nil)
 	blockBroadcastOutTimer  = metrics.NewRegisteredTimer("eth/fetcher/block/broadcasts/out", nil)
@@ -105,7 +106,8 @@ type blockAnnounce struct {
 	header *types.Header // Header of the block partially reassembled (new protocol)
 	time   time.Time     // Timestamp of the announcement
 
-	origin string // Identifier of the peer originating the notification
+	origin    string    // Identifier of the peer originating the notification
+	requested time.Time // Timestamp of the last retrieval request sent to the origin
 
 	fetchHeader headerRequesterFn // Fetcher function to retrieve the header of an announced block
 	fetchBodies bodyRequesterFn   // Fetcher function to retrieve the body of an announced block
@@ -172,6 +174,7 @@ type BlockFetcher struct {
 	fetching   map[common.Hash]*blockAnnounce   // Announced blocks, currently fetching
 	fetched    map[common.Hash][]*blockAnnounce // Blocks with headers fetched, scheduled for body retrieval
 	completing map[common.Hash]*blockAnnounce   // Blocks with headers, currently body-completing
+	latencies  peerLatencies                    // Response times of the announcers, to fetch from the fastest
 
 	// Block cache
 	queue  *prque.Prque                         // Queue containing the import operations (block number sorted)
@@ -211,6 +214,7 @@ func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetr
 		fetching:       make(map[common.Hash]*blockAnnounce),
 		fetched:        make(map[common.Hash][]*blockAnnounce),
 		completing:     make(map[common.Hash]*blockAnnounce),
+		latencies:      make(peerLatencies),
 		queue:          prque.New(nil),
 		queues:         make(map[string]int),
 		queued:         make(map[common.Hash]*blockOrHeaderInject),
@@ -340,6 +344,7 @@ func (f *BlockFetcher) loop() {
 		// Clean up any expired block fetches
 		for hash, announce := range f.fetching {
 			if time.Since(announce.time) > fetchTimeout {
+				f.latencies.update(announce.origin, fetchTimeout)
 				f.forgetHash(hash)
 			}
 		}
@@ -381,6 +386,19 @@ func (f *BlockFetcher) loop() {
 			// A block was announced, make sure the peer isn't DOSing us
 			blockAnnounceInMeter.Mark(1)
 
+			// Announcements are aggregated until the block is fetched, skip the
+			// ones the peer already made so they don't use up its allowance
+			repeated := false
+			for _, announce := range f.announced[notification.hash] {
+				if announce.origin == notification.origin {
+					repeated = true
+					break
+				}
+			}
+			if repeated {
+				blockAnnounceRepeatMeter.Mark(1)
+				break
+			}
 			count := f.announces[notification.origin] + 1
 			if count > hashLimit {
 				log.Debug("Peer exceeded outstanding announces", "peer", notification.origin, "limit", hashLimit)
@@ -439,13 +457,14 @@ func (f *BlockFetcher) loop() {
 					timeout = 0
 				}
 				if time.Since(announces[0].time) > timeout {
-					// Pick a random peer to retrieve from, reset all others
-					announce := announces[rand.Intn(len(announces))]
+					// Pick the fastest peer to retrieve from, reset all others
+					announce := f.fastestAnnounce(announces)
 					f.forgetHash(hash)
 
 					// If the block still didn't arrive, queue for fetching
 					if (f.light && f.getHeader(hash) == nil) || (!f.light && f.getBlock(hash) == nil) {
 						request[announce.origin] = append(request[announce.origin], hash)
+						announce.requested = time.Now()
 						f.fetching[hash] = announce
 					}
 				}
@@ -474,13 +493,14 @@ func (f *BlockFetcher) loop() {
 			request := make(map[string][]common.Hash)
 
 			for hash, announces := range f.fetched {
-				// Pick a random peer to retrieve from, reset all others
-				announce := announces[rand.Intn(len(announces))]
+				// Pick the fastest peer to retrieve from, reset all others
+				announce := f.fastestAnnounce(announces)
 				f.forgetHash(hash)
 
 				// If the block still didn't arrive, queue for completion
 				if f.getBlock(hash) == nil {
 					request[announce.origin] = append(request[announce.origin], hash)
+					announce.requested = time.Now()
 					f.completing[hash] = announce
 				}
 			}
@@ -518,6 +538,8 @@ func (f *BlockFetcher) loop() {
 
 				// Filter fetcher-requested headers from other synchronisation algorithms
 				if announce := f.fetching[hash]; announce != nil && announce.origin == task.peer && f.fetched[hash] == nil && f.completing[hash] == nil && f.queued[hash] == nil {
+					f.latencies.update(task.peer, task.time.Sub(announce.requested))
+
 					// If the delivered header does not match the promised number, drop the announcer
 					if header.Number.Uint64() != announce.number {
 						log.Trace("Invalid block number fetched", "peer", announce.origin, "hash", header.Hash(), "announced", announce.number, "provided", header.Number)
@@ -627,6 +649,8 @@ func (f *BlockFetcher) loop() {
 						}
 						// Mark the body matched, reassemble if still unknown
 						matched = true
+						f.latencies.update(task.peer, task.time.Sub(announce.requested))
+
 						if f.getBlock(hash) == nil {
 							block := types.NewBlockWithHeader(announce.header).WithBody(task.transactions[i], task.uncles[i])
 							block.ReceivedAt = task.time
@@ -825,6 +849,21 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 	}()
 }
 
+// fastestAnnounce picks the announcement of the peer expected to deliver the
+// announced block the fastest, choosing randomly among equally fast ones.
+func (f *BlockFetcher) fastestAnnounce(announces []*blockAnnounce) *blockAnnounce {
+	var fastest []*blockAnnounce
+	for _, announce := range announces {
+		switch {
+		case len(fastest) == 0 || f.latencies.faster(announce.origin, fastest[0].origin):
+			fastest = append(fastest[:0], announce)
+		case !f.latencies.faster(fastest[0].origin, announce.origin):
+			fastest = append(fastest, announce)
+		}
+	}
+	return fastest[rand.Intn(len(fastest))]
+}
+
 // forgetHash removes all traces of a block announcement from the fetcher's
 // internal state.
 func (f *BlockFetcher) forgetHash(hash common.Hash) {
@@ -879,3 +879,46 @@ func TestBlockMemoryExhaustionAttack(t *testing.T) {
 	}
 	verifyImportDone(t, imported)
 }
+
+// Tests that announced blocks are retrieved from the announcer measured to be
+// the fastest, preferring the ones not measured yet.
+func TestFastestAnnouncerSelection(t *testing.T) {
+	fetcher := NewBlockFetcher(false, nil, nil, nil, nil, nil, nil, nil, nil)
+	fetcher.latencies.update("slow", time.Second)
+	fetcher.latencies.update("fast", 100*time.Millisecond)
+	fetcher.latencies.update("fast", 500*time.Millisecond)
+
+	if have, want := fetcher.latencies["fast"], 200*time.Millisecond; have != want {
+		t.Fatalf("latency mismatch: have %v, want %v", have, want)
+	}
+	announces := []*blockAnnounce{{origin: "slow"}, {origin: "fast"}, {origin: "slow"}}
+	for i := 0; i < 10; i++ {
+		if origin := fetcher.fastestAnnounce(announces).origin; origin != "fast" {
+			t.Fatalf("attempt %d: announcer mismatch: have %s, want %s", i, origin, "fast")
+		}
+	}
+	announces = append(announces, &blockAnnounce{origin: "unknown"})
+	if origin := fetcher.fastestAnnounce(announces).origin; origin != "unknown" {
+		t.Fatalf("announcer mismatch: have %s, want %s", origin, "unknown")
+	}
+}
+
+// Tests that repeated announcements of a block by the same peer are aggregated
+// into one, not using up the announcement allowance of the peer.
+func TestRepeatedAnnouncements(t *testing.T) {
+	tester := newTester(false)
+
+	imported := make(chan interface{})
+	tester.fetcher.importedHook = func(header *types.Header, block *types.Block) { imported <- block }
+
+	hashes, blocks := makeChain(2, 0, genesis)
+	headerFetcher := tester.makeHeaderFetcher("valid", blocks, -gatherSlack)
+	bodyFetcher := tester.makeBodyFetcher("valid", blocks, 0)
+
+	for i := 0; i < hashLimit+1; i++ {
+		tester.fetcher.Notify("valid", hashes[1], 1, time.Now(), headerFetcher, bodyFetcher)
+	}
+	tester.fetcher.Notify("valid", hashes[0], 2, time.Now(), headerFetcher, bodyFetcher)
+	verifyImportCount(t, imported, 2)
+	verifyImportDone(t, imported)
+}
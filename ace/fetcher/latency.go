@@ -0,0 +1,69 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import "time"
+
+const (
+	// latencyImpact is the impact a single measurement has on the estimated
+	// response time of a peer.
+	latencyImpact = 0.25
+
+	// maxLatencyPeers is the maximum number of peers whose response times are
+	// tracked, to bound the memory used by short lived connections.
+	maxLatencyPeers = 1024
+)
+
+// peerLatencies tracks the smoothed response times of peers to the requests of
+// a fetcher, so that items announced by multiple peers are retrieved from the
+// one expected to deliver them the fastest. It is only ever accessed from the
+// loop of the fetcher, so it needs no locking.
+type peerLatencies map[string]time.Duration
+
+// update adds a new response time measurement of a peer.
+func (l peerLatencies) update(peer string, elapsed time.Duration) {
+	old, ok := l[peer]
+	if !ok {
+		if len(l) >= maxLatencyPeers {
+			for evict := range l {
+				delete(l, evict)
+				break
+			}
+		}
+		l[peer] = elapsed
+		return
+	}
+	l[peer] = time.Duration((1-latencyImpact)*float64(old) + latencyImpact*float64(elapsed))
+}
+
+// updateRequest adds the response time measurement of a request, of which only
+// some of the requested items may have been delivered. The missing items are
+// rated as if they timed out, so peers answering fast with nothing don't end up
+// being preferred.
+func (l peerLatencies) updateRequest(peer string, elapsed time.Duration, delivered, missing int, timeout time.Duration) {
+	if delivered+missing == 0 {
+		return
+	}
+	l.update(peer, (time.Duration(delivered)*elapsed+time.Duration(missing)*timeout)/time.Duration(delivered+missing))
+}
+
+// faster reports whether peer a is expected to respond faster than peer b. Peers
+// not yet measured are preferred, so that all announcers get a chance to prove
+// themselves.
+func (l peerLatencies) faster(a, b string) bool {
+	return l[a] < l[b]
+}
@@ -72,6 +72,7 @@ var (
 	txAnnounceKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/known", nil)
 	txAnnounceUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/underpriced", nil)
 	txAnnounceDOSMeter         = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/dos", nil)
+	txAnnounceRepeatMeter      = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/repeat", nil)
 
 	txBroadcastInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/in", nil)
 	txBroadcastKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/known", nil)
@@ -129,10 +130,15 @@ type txDrop struct {
 // The fetcher operates in 3 stages:
 //   - Transactions that are newly discovered are moved into a wait list.
 //   - After ~500ms passes, transactions from the wait list that have not been
-//     broadcast to us in whole are moved into a queueing area.
+//     broadcast to us in whole are moved into a queueing area. The wait list
+//     acts as the aggregation window of the announcements: every peer that
+//     announced a transaction meanwhile is tracked against the same entry, and
+//     repeated announcements of a peer are dropped.
 //   - When a connected peer doesn't have in-flight retrieval requests, any
 //     transaction queued up (and announced by the peer) are allocated to the
 //     peer and moved into a fetching status until it's fulfilled or fails.
+//     Idle peers are served fastest first, so transactions announced by many
+//     peers are retrieved from the one measured to respond the quickest.
 //
 // The invariants of the fetcher are:
 //   - Each tracked transaction (hash) must only be present in one of the
@@ -166,6 +172,7 @@ type TxFetcher struct {
 	fetching   map[common.Hash]string              // Transaction set currently being retrieved
 	requests   map[string]*txRequest               // In-flight transaction retrievals
 	alternates map[common.Hash]map[string]struct{} // In-flight transaction alternate origins if retrieval fails
+	latencies  peerLatencies                       // Response times of the announcers, to fetch from the fastest
 
 	// Callbacks
 	hasTx    func(common.Hash) bool             // Retrieves a tx from the local txpool
@@ -201,6 +208,7 @@ func NewTxFetcherForTests(
 		fetching:    make(map[common.Hash]string),
 		requests:    make(map[string]*txRequest),
 		alternates:  make(map[common.Hash]map[string]struct{}),
+		latencies:   make(peerLatencies),
 		underpriced: mapset.NewSet(),
 		hasTx:       hasTx,
 		addTxs:      addTxs,
@@ -223,9 +231,17 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 	// loop, so anything caught here is time saved internally.
 	var (
 		unknowns               = make([]common.Hash, 0, len(hashes))
+		seen                   = make(map[common.Hash]struct{}, len(hashes))
 		duplicate, underpriced int64
+		repeated               int64
 	)
 	for _, hash := range hashes {
+		if _, ok := seen[hash]; ok {
+			repeated++
+			continue
+		}
+		seen[hash] = struct{}{}
+
 		switch {
 		case f.hasTx(hash):
 			duplicate++
@@ -239,6 +255,7 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 	}
 	txAnnounceKnownMeter.Mark(duplicate)
 	txAnnounceUnderpricedMeter.Mark(underpriced)
+	txAnnounceRepeatMeter.Mark(repeated)
 
 	// If anything's left to announce, push it into the internal loop
 	if len(unknowns) == 0 {
@@ -354,6 +371,22 @@ func (f *TxFetcher) loop() {
 	for {
 		select {
 		case ann := <-f.notify:
+			// Skip the transactions the peer already announced, they are tracked
+			// already and shouldn't use up its announcement allowance again
+			fresh := ann.hashes[:0]
+			for _, hash := range ann.hashes {
+				if _, ok := f.waitslots[ann.origin][hash]; ok {
+					continue
+				}
+				if _, ok := f.announces[ann.origin][hash]; ok {
+					continue
+				}
+				fresh = append(fresh, hash)
+			}
+			txAnnounceRepeatMeter.Mark(int64(len(ann.hashes) - len(fresh)))
+			if ann.hashes = fresh; len(ann.hashes) == 0 {
+				break
+			}
 			// Drop part of the new announcements if there are too many accumulated.
 			// Note, we could but do not filter already known transactions here as
 			// the probability of something arriving between this call and the pre-
@@ -481,6 +514,7 @@ func (f *TxFetcher) loop() {
 			for peer, req := range f.requests {
 				if time.Duration(f.clock.Now()-req.time)+txGatherSlack > txFetchTimeout {
 					txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+					f.latencies.update(peer, txFetchTimeout)
 
 					// Reschedule all the not-yet-delivered fetches to alternate peers
 					for _, hash := range req.hashes {
@@ -576,6 +610,20 @@ func (f *TxFetcher) loop() {
 				for _, hash := range delivery.hashes {
 					delivered[hash] = struct{}{}
 				}
+				// Rate the peer by the requested transactions it actually returned,
+				// ignoring the ones delivered by someone else meanwhile
+				var returned, missing int
+				for _, hash := range req.hashes {
+					if _, ok := req.stolen[hash]; ok {
+						continue
+					}
+					if _, ok := delivered[hash]; ok {
+						returned++
+					} else {
+						missing++
+					}
+				}
+				f.latencies.updateRequest(delivery.origin, time.Duration(f.clock.Now()-req.time), returned, missing, txFetchTimeout)
 				cutoff := len(req.hashes) // If nothing is delivered, assume everything is missing, don't retry!!!
 				for i, hash := range req.hashes {
 					if _, ok := delivered[hash]; ok {
@@ -659,6 +707,8 @@ func (f *TxFetcher) loop() {
 				}
 				delete(f.announces, drop.peer)
 			}
+			delete(f.latencies, drop.peer)
+
 			// If a request was cancelled, check if anything needs to be rescheduled
 			if request != nil {
 				f.scheduleFetches(timeoutTimer, timeoutTrigger, nil)
@@ -760,15 +810,24 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 	if len(actives) == 0 {
 		return
 	}
+	// Order the active peers fastest first, so they get the first pick of the
+	// transactions announced by multiple peers
+	peers := make([]string, 0, len(actives))
+	f.forEachPeer(actives, func(peer string) {
+		peers = append(peers, peer)
+	})
+	sort.SliceStable(peers, func(i, j int) bool {
+		return f.latencies.faster(peers[i], peers[j])
+	})
 	// For each active peer, try to schedule some transaction fetches
 	idle := len(f.requests) == 0
 
-	f.forEachPeer(actives, func(peer string) {
+	for _, peer := range peers {
 		if f.requests[peer] != nil {
-			return // continue in the for-each
+			continue
 		}
 		if len(f.announces[peer]) == 0 {
-			return // continue in the for-each
+			continue
 		}
 		hashes := make([]common.Hash, 0, maxTxRetrievals)
 		f.forEachHash(f.announces[peer], func(hash common.Hash) bool {
@@ -804,7 +863,7 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 				}
 			}(peer, hashes)
 		}
-	})
+	}
 	// If a new request was fired, schedule a timeout timer
 	if idle && len(f.requests) > 0 {
 		f.rescheduleTimeout(timer, timeout)
@@ -495,6 +495,134 @@ func TestTransactionFetcherMissingRescheduling(t *testing.T) {
 	})
 }
 
+// Tests that transactions announced by multiple peers are retrieved from the one
+// measured to respond the fastest.
+func TestTransactionFetcherFastestPeer(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+			)
+		},
+		steps: []interface{}{
+			// Retrieve a transaction from each peer, with B responding faster
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[0]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[1]}},
+			doWait{time: txArriveTimeout, step: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"A": {testTxsHashes[0]},
+					"B": {testTxsHashes[1]},
+				},
+				fetching: map[string][]common.Hash{
+					"A": {testTxsHashes[0]},
+					"B": {testTxsHashes[1]},
+				},
+			},
+			doWait{time: 50 * time.Millisecond, step: false},
+			doTxEnqueue{peer: "B", txs: []*types.Transaction{testTxs[1]}, direct: true},
+			doWait{time: time.Second, step: false},
+			doTxEnqueue{peer: "A", txs: []*types.Transaction{testTxs[0]}, direct: true},
+			isScheduled{nil, nil, nil},
+
+			// Announce the same transactions from both peers, the faster should be
+			// the one retrieving them
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doWait{time: txArriveTimeout, step: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"A": {testTxsHashes[2], testTxsHashes[3]},
+					"B": {testTxsHashes[2], testTxsHashes[3]},
+				},
+				fetching: map[string][]common.Hash{
+					"B": {testTxsHashes[2], testTxsHashes[3]},
+				},
+			},
+		},
+	})
+}
+
+// Tests that peers answering fast with nothing are not preferred over peers
+// actually delivering the requested transactions.
+func TestTransactionFetcherEmptyDeliveryLatency(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+			)
+		},
+		steps: []interface{}{
+			// Request a transaction from each peer, with A answering instantly
+			// without the transaction and B slowly delivering it
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[0]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[1]}},
+			doWait{time: txArriveTimeout, step: true},
+			doTxEnqueue{peer: "A", txs: nil, direct: true},
+			doWait{time: time.Second, step: false},
+			doTxEnqueue{peer: "B", txs: []*types.Transaction{testTxs[1]}, direct: true},
+			isScheduled{nil, nil, nil},
+
+			// Announce the same transactions from both peers, the one delivering
+			// should be retrieving them
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doWait{time: txArriveTimeout, step: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"A": {testTxsHashes[2], testTxsHashes[3]},
+					"B": {testTxsHashes[2], testTxsHashes[3]},
+				},
+				fetching: map[string][]common.Hash{
+					"B": {testTxsHashes[2], testTxsHashes[3]},
+				},
+			},
+		},
+	})
+}
+
+// Tests that repeated announcements of a peer are dropped before they are
+// accounted against its announcement allowance.
+func TestTransactionFetcherRepeatedAnnouncements(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				nil,
+				func(string, []common.Hash) error { return nil },
+			)
+		},
+		steps: []interface{}{
+			doTxNotify{peer: "A", hashes: []common.Hash{{0x01}, {0x01}, {0x02}}},
+			doTxNotify{peer: "A", hashes: []common.Hash{{0x02}, {0x01}}},
+			isWaiting(map[string][]common.Hash{
+				"A": {{0x01}, {0x02}},
+			}),
+			doWait{time: txArriveTimeout, step: true},
+			doTxNotify{peer: "A", hashes: []common.Hash{{0x01}, {0x03}}},
+			isWaiting(map[string][]common.Hash{
+				"A": {{0x03}},
+			}),
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					"A": {{0x01}, {0x02}},
+				},
+				fetching: map[string][]common.Hash{
+					"A": {{0x01}, {0x02}},
+				},
+			},
+		},
+	})
+}
+
 // Tests that out of two transactions, if one is missing and the last is
 // delivered, the peer gets properly cleaned out from the internal state.
 func TestTransactionFetcherMissingCleanup(t *testing.T) {
@@ -18,9 +18,11 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"time"
@@ -28,6 +30,7 @@ import (
 	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/event"
@@ -240,6 +243,78 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// reorgBlock identifies a block in a reorg notification.
+type reorgBlock struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+}
+
+// reorgNotification is the payload of a reorgs subscription notification.
+type reorgNotification struct {
+	CommonAncestor reorgBlock    `json:"commonAncestor"`
+	Dropped        []reorgBlock  `json:"dropped"`
+	Added          []reorgBlock  `json:"added"`
+	DroppedTxs     []common.Hash `json:"droppedTransactions"`
+	AddedTxs       []common.Hash `json:"addedTransactions"`
+}
+
+func newReorgNotification(ev core.ReorgEvent) *reorgNotification {
+	blocks := func(list types.Blocks) []reorgBlock {
+		res := make([]reorgBlock, len(list))
+		for i, block := range list {
+			res[i] = reorgBlock{Number: hexutil.Uint64(block.NumberU64()), Hash: block.Hash()}
+		}
+		return res
+	}
+	hashes := func(txs types.Transactions) []common.Hash {
+		res := make([]common.Hash, len(txs))
+		for i, tx := range txs {
+			res[i] = tx.Hash()
+		}
+		return res
+	}
+	return &reorgNotification{
+		CommonAncestor: reorgBlock{Number: hexutil.Uint64(ev.CommonAncestor.NumberU64()), Hash: ev.CommonAncestor.Hash()},
+		Dropped:        blocks(ev.Dropped),
+		Added:          blocks(ev.Added),
+		DroppedTxs:     hashes(ev.DroppedTxs),
+		AddedTxs:       hashes(ev.AddedTxs),
+	}
+}
+
+// Reorgs creates a subscription that fires each time the canonical chain is
+// reorganised, reporting the common ancestor, the dropped and added blocks and
+// the transactions which left or entered the canonical chain. This lets clients
+// track the finality of transactions without polling for receipts.
+func (api *PublicFilterAPI) Reorgs(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		reorgs := make(chan core.ReorgEvent)
+		reorgsSub := api.events.SubscribeReorgs(reorgs)
+
+		for {
+			select {
+			case ev := <-reorgs:
+				notifier.Notify(rpcSub.ID, newReorgNotification(ev))
+			case <-rpcSub.Err():
+				reorgsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				reorgsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -327,10 +402,53 @@ func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	return logsSub.ID, nil
 }
 
+// LogsPageArgs requests a page of the results of a log query.
+type LogsPageArgs struct {
+	Cursor *hexutil.Bytes  `json:"cursor"` // Continuation token of the previous page, nil for the first
+	Limit  *hexutil.Uint64 `json:"limit"`  // Maximum number of logs of the page, capped by the server
+}
+
+// LogsPage is a page of the results of a log query.
+type LogsPage struct {
+	Logs   []*types.Log  `json:"logs"`
+	Cursor hexutil.Bytes `json:"cursor,omitempty"` // Continuation token of the next page, omitted if complete
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
+// If page arguments are given, a page of the logs is returned along with the token
+// to continue the query with, passing the same criteria.
 //
 // https://eth.wiki/json-rpc/API#eth_getlogs
-func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria, page *LogsPageArgs) (interface{}, error) {
+	if len(crit.BlockHashes) > 0 {
+		if page != nil {
+			return nil, errors.New("block hash lists can't be paginated")
+		}
+		return api.blockHashesLogs(ctx, crit)
+	}
+	var cursor *logsCursor
+	if page != nil && page.Cursor != nil {
+		c, err := decodeLogsCursor(*page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// Reject cursors pointing into a block which is not canonical anymore
+		switch {
+		case crit.BlockHash != nil:
+			if c.hash != *crit.BlockHash {
+				return nil, errors.New("logs cursor of a different block")
+			}
+		case c.skip > 0:
+			header, err := api.backend.HeaderByNumber(ctx, rpc.BlockNumber(c.next))
+			if err != nil {
+				return nil, err
+			}
+			if header == nil || header.Hash() != c.hash {
+				return nil, errLogsCursorReorged
+			}
+		}
+		cursor = c
+	}
 	var filter *Filter
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
@@ -345,15 +463,100 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 		if crit.ToBlock != nil {
 			end = crit.ToBlock.Int64()
 		}
+		// Continue paginated queries where the previous page ended
+		if cursor != nil {
+			begin, end = int64(cursor.next), int64(cursor.end)
+		}
 		// Construct the range filter
 		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	if page == nil {
+		// Run the filter and return all the logs
+		return runLogFilter(ctx, filter)
+	}
+	// Run the filter until the page is filled
+	filter.page = api.backend.RPCLogsPageSize()
+	if filter.page == 0 {
+		filter.page = api.backend.RPCLogsCap()
+	}
+	if page.Limit != nil && *page.Limit > 0 && (filter.page == 0 || uint64(*page.Limit) < filter.page) {
+		filter.page = uint64(*page.Limit)
+	}
+	if cursor != nil {
+		filter.skip = cursor.skip
+	}
+	return runLogPage(ctx, filter)
+}
+
+// maxLogsBlockHashes is the maximum number of blocks a log query may list by hash.
+const maxLogsBlockHashes = 1024
+
+// BlockLogs are the logs of a block listed by hash in a log query, along with
+// whether the block is part of the canonical chain.
+type BlockLogs struct {
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"` // Omitted if the block is unknown
+	Canonical   bool            `json:"canonical"`
+	Logs        []*types.Log    `json:"logs"`
+	Error       string          `json:"error,omitempty"` // Reason the logs are missing, if any
+}
+
+// blockHashesLogs returns the logs matching the criteria of each block listed by
+// hash, in the order of the list. Blocks that are unknown or whose logs can't be
+// retrieved are reported instead of failing the query, while the logs cap applies
+// to all blocks together.
+func (api *PublicFilterAPI) blockHashesLogs(ctx context.Context, crit FilterCriteria) ([]*BlockLogs, error) {
+	if len(crit.BlockHashes) > maxLogsBlockHashes {
+		return nil, fmt.Errorf("too many block hashes: %d > %d", len(crit.BlockHashes), maxLogsBlockHashes)
+	}
+	var (
+		filter  = newFilter(api.backend, crit.Addresses, crit.Topics)
+		limit   = api.backend.RPCLogsCap()
+		total   uint64
+		results = make([]*BlockLogs, len(crit.BlockHashes))
+	)
+	filter.limit = 0 // The cap is checked against the total of all blocks below
+
+	for i, hash := range crit.BlockHashes {
+		result := &BlockLogs{BlockHash: hash, Logs: []*types.Log{}}
+		results[i] = result
+
+		logs, err := api.hashLogs(ctx, filter, result)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = err.Error()
+			continue
+		}
+		if total += uint64(len(logs)); limit > 0 && total > limit {
+			return nil, fmt.Errorf("query returned more than %d logs", limit)
+		}
+		result.Logs = returnLogs(logs)
+	}
+	return results, nil
+}
+
+// hashLogs fills in the number and canonicality of a block listed by hash in a
+// log query, returning its logs matching the filter.
+func (api *PublicFilterAPI) hashLogs(ctx context.Context, filter *Filter, result *BlockLogs) ([]*types.Log, error) {
+	header, err := api.backend.HeaderByHash(ctx, result.BlockHash)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	if header == nil {
+		return nil, errors.New("unknown block")
+	}
+	number := hexutil.Uint64(header.Number.Uint64())
+	result.BlockNumber = &number
+
+	canonical, err := api.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	result.Canonical = canonical != nil && canonical.Hash() == result.BlockHash
+
+	return filter.blockLogs(ctx, header)
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -404,11 +607,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
 	}
 	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), nil
+	return runLogFilter(ctx, filter)
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
@@ -463,14 +662,141 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+// runLogFilter runs a log query. If it was truncated by the logs cap, the logs
+// found until then are returned within the error.
+func runLogFilter(ctx context.Context, filter *Filter) ([]*types.Log, error) {
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		if terr, ok := err.(*logsTruncatedError); ok {
+			terr.logs = returnLogs(logs)
+		}
+		return nil, err
+	}
+	return returnLogs(logs), nil
+}
+
+// runLogPage runs a log query until a page of logs is filled, returning them
+// along with the cursor to continue the query with if it was not complete.
+func runLogPage(ctx context.Context, filter *Filter) (*LogsPage, error) {
+	if filter.page == 0 {
+		filter.page = math.MaxUint64 // Unlimited pages contain everything
+	}
+	logs, err := filter.Logs(ctx)
+	if err == nil {
+		return &LogsPage{Logs: returnLogs(logs)}, nil
+	}
+	perr, ok := err.(*logsPageError)
+	if !ok {
+		return nil, err
+	}
+	cursor := &logsCursor{next: perr.next, skip: perr.skip, end: uint64(filter.end)}
+	if filter.block != (common.Hash{}) {
+		cursor.end, cursor.hash = cursor.next, filter.block
+	} else if cursor.skip > 0 {
+		// Remember the block partially returned to detect it being reorged out
+		header, err := filter.backend.HeaderByNumber(ctx, rpc.BlockNumber(cursor.next))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, fmt.Errorf("block #%d not found", cursor.next)
+		}
+		cursor.hash = header.Hash()
+	}
+	return &LogsPage{Logs: returnLogs(logs), Cursor: cursor.encode()}, nil
+}
+
+// errLogsCursorReorged is returned if a paginated log query is continued from a
+// block that was reorged out since the previous page.
+var errLogsCursorReorged = errors.New("logs cursor invalidated by chain reorg")
+
+// logsPageError is returned by paginated log queries filling a page before the
+// end of the queried range.
+type logsPageError struct {
+	next uint64 // First block whose logs are not all included
+	skip uint64 // Number of matching logs of the next block included
+}
+
+func (e *logsPageError) Error() string {
+	return fmt.Sprintf("logs page filled at block %d", e.next)
+}
+
+// logsCursor is the position a paginated log query continues from, encoded as
+// an opaque token for the API.
+type logsCursor struct {
+	next uint64      // First block whose logs are not all returned
+	skip uint64      // Number of matching logs of the next block returned
+	end  uint64      // Last block of the query, resolved by the first page
+	hash common.Hash // Hash of the next block if partially returned
+}
+
+// logsCursorLength is the length of an encoded logs cursor.
+const logsCursorLength = 3*8 + common.HashLength
+
+// encode serializes the cursor into its token form.
+func (c *logsCursor) encode() hexutil.Bytes {
+	token := make([]byte, logsCursorLength)
+	binary.BigEndian.PutUint64(token[0:], c.next)
+	binary.BigEndian.PutUint64(token[8:], c.skip)
+	binary.BigEndian.PutUint64(token[16:], c.end)
+	copy(token[24:], c.hash[:])
+	return token
+}
+
+// decodeLogsCursor parses the token form of a logs cursor.
+func decodeLogsCursor(token []byte) (*logsCursor, error) {
+	if len(token) != logsCursorLength {
+		return nil, errors.New("invalid logs cursor")
+	}
+	c := &logsCursor{
+		next: binary.BigEndian.Uint64(token[0:]),
+		skip: binary.BigEndian.Uint64(token[8:]),
+		end:  binary.BigEndian.Uint64(token[16:]),
+		hash: common.BytesToHash(token[24:]),
+	}
+	if c.next > c.end || c.end > math.MaxInt64 {
+		return nil, errors.New("invalid logs cursor")
+	}
+	return c, nil
+}
+
+// logsTruncatedError is an API error reporting a log query that matched more
+// logs than the configured cap, with the logs up to the block that hit the cap.
+type logsTruncatedError struct {
+	limit uint64
+	next  uint64 // First block whose logs are not included
+	logs  []*types.Log
+}
+
+func (e *logsTruncatedError) Error() string {
+	return fmt.Sprintf("query returned more than %d logs", e.limit)
+}
+
+// ErrorCode returns the JSON error code for an exceeded limit.
+func (e *logsTruncatedError) ErrorCode() int {
+	return -32005
+}
+
+// ErrorData returns the logs found before the cap was hit and the block to
+// resume the query from.
+func (e *logsTruncatedError) ErrorData() interface{} {
+	return &struct {
+		Truncated bool           `json:"truncated"`
+		Limit     hexutil.Uint64 `json:"limit"`
+		NextBlock hexutil.Uint64 `json:"nextBlock"`
+		Logs      []*types.Log   `json:"logs"`
+	}{true, hexutil.Uint64(e.limit), hexutil.Uint64(e.next), e.logs}
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
-		BlockHash *common.Hash     `json:"blockHash"`
-		FromBlock *rpc.BlockNumber `json:"fromBlock"`
-		ToBlock   *rpc.BlockNumber `json:"toBlock"`
-		Addresses interface{}      `json:"address"`
-		Topics    []interface{}    `json:"topics"`
+		BlockHash   *common.Hash     `json:"blockHash"`
+		BlockHashes []common.Hash    `json:"blockHashes"`
+		FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+		ToBlock     *rpc.BlockNumber `json:"toBlock"`
+		Addresses   interface{}      `json:"address"`
+		Topics      []interface{}    `json:"topics"`
 	}
 
 	var raw input
@@ -478,7 +804,16 @@ func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if raw.BlockHash != nil {
+	if raw.BlockHashes != nil {
+		if raw.BlockHash != nil || raw.FromBlock != nil || raw.ToBlock != nil {
+			// BlockHashes is mutually exclusive with all other block criteria
+			return fmt.Errorf("cannot specify BlockHashes together with BlockHash or FromBlock/ToBlock")
+		}
+		if len(raw.BlockHashes) == 0 {
+			return errors.New("empty BlockHashes")
+		}
+		args.BlockHashes = raw.BlockHashes
+	} else if raw.BlockHash != nil {
 		if raw.FromBlock != nil || raw.ToBlock != nil {
 			// BlockHash is mutually exclusive with FromBlock/ToBlock criteria
 			return fmt.Errorf("cannot specify both BlockHash and FromBlock/ToBlock, choose one or the other")
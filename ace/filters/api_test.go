@@ -182,4 +182,24 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 	if len(test7.Topics[2]) != 0 {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
+
+	// block hash list, exclusive with the other block criteria
+	var test8 FilterCriteria
+	vector = fmt.Sprintf(`{"blockHashes": ["%s", "%s"]}`, topic0.Hex(), topic1.Hex())
+	if err := json.Unmarshal([]byte(vector), &test8); err != nil {
+		t.Fatal(err)
+	}
+	if len(test8.BlockHashes) != 2 || test8.BlockHashes[0] != topic0 || test8.BlockHashes[1] != topic1 {
+		t.Fatalf("invalid block hashes expected [%x,%x], got %x", topic0, topic1, test8.BlockHashes)
+	}
+	for _, vector := range []string{
+		fmt.Sprintf(`{"blockHashes": ["%s"], "fromBlock": "0x1"}`, topic0.Hex()),
+		fmt.Sprintf(`{"blockHashes": ["%s"], "blockHash": "%s"}`, topic0.Hex(), topic1.Hex()),
+		`{"blockHashes": []}`,
+	} {
+		var test FilterCriteria
+		if err := json.Unmarshal([]byte(vector), &test); err == nil {
+			t.Fatalf("invalid block hash list accepted: %s", vector)
+		}
+	}
 }
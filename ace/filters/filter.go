@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/bitutil"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/bloombits"
 	"github.com/acent/go-acent/core/types"
@@ -42,9 +43,23 @@ type Backend interface {
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 	SubscribePendingLogsEvent(ch chan<- []*types.Log) event.Subscription
+	SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	// BloomExtent returns the first and the number of the bloom bits sections
+	// rebuilt beyond the contiguously indexed ones, zero if there are none.
+	BloomExtent() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	// LogIndexStatus returns the section size and the number of sections of the
+	// contract log index, zero if the index is not maintained.
+	LogIndexStatus() (uint64, uint64)
+	// LogIndex returns the bitset of the blocks containing logs emitted by the
+	// contract within an indexed section, nil if there are none.
+	LogIndex(ctx context.Context, address common.Address, section uint64) ([]byte, error)
+
+	RPCLogsCap() uint64      // global cap on the logs returned by a query: DoS protection
+	RPCLogsPageSize() uint64 // maximum number of logs of a page of a paginated query
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -59,6 +74,12 @@ type Filter struct {
 	begin, end int64       // Range interval if filtering multiple blocks
 
 	matcher *bloombits.Matcher
+
+	limit uint64 // Maximum number of logs to return, 0 if unlimited
+	found uint64 // Number of logs found so far
+
+	page uint64 // Maximum number of logs of a page if paginating, 0 otherwise
+	skip uint64 // Number of matching logs of the first block returned by the previous page
 }
 
 // NewRangeFilter creates a new filter which uses a bloom filter on blocks to
@@ -111,6 +132,7 @@ func newFilter(backend Backend, addresses []common.Address, topics [][]common.Ha
 		addresses: addresses,
 		topics:    topics,
 		db:        backend.ChainDb(),
+		limit:     backend.RPCLogsCap(),
 	}
 }
 
@@ -135,29 +157,88 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
-	if f.begin == -1 {
-		f.begin = int64(head)
+	// Resolve the block labels of the range, the safe and finalized blocks being
+	// provided by the backend's finality source
+	resolve := func(number int64) (int64, error) {
+		switch rpc.BlockNumber(number) {
+		case rpc.LatestBlockNumber:
+			return int64(head), nil
+		case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if err != nil {
+				return 0, err
+			}
+			if header == nil {
+				return 0, errors.New("unknown block")
+			}
+			return header.Number.Int64(), nil
+		}
+		return number, nil
+	}
+	begin, err := resolve(f.begin)
+	if err != nil {
+		return nil, err
+	}
+	f.begin = begin
+
+	resolved, err := resolve(f.end)
+	if err != nil {
+		return nil, err
 	}
-	end := uint64(f.end)
-	if f.end == -1 {
-		end = head
+	end := uint64(resolved)
+	f.end = resolved
+	// Gather all indexed logs, and finish with non indexed ones. Address constrained
+	// queries use the contract log index first if available, as it's free of false
+	// positives, falling back to the bloom bits beyond it.
+	var logs []*types.Log
+	if len(f.addresses) > 0 {
+		size, sections := f.backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.addressLogs(ctx, end)
+			} else {
+				logs, err = f.addressLogs(ctx, indexed-1)
+			}
+			if err != nil {
+				return logs, err
+			}
+		}
 	}
-	// Gather all indexed logs, and finish with non indexed ones
-	var (
-		logs []*types.Log
-		err  error
-	)
 	size, sections := f.backend.BloomStatus()
-	if indexed := sections * size; indexed > uint64(f.begin) {
+	if indexed := sections * size; indexed > uint64(f.begin) && uint64(f.begin) <= end {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
 	}
+	// Sections rebuilt beyond the indexed ones (index-blooms) allow skipping over
+	// an unindexed gap, filtering the blocks before them one by one
+	if first, count := f.backend.BloomExtent(); count > 0 {
+		extStart, extEnd := first*size, (first+count)*size-1
+		if uint64(f.begin) <= extEnd && extStart <= end {
+			if uint64(f.begin) < extStart {
+				found, err := f.unindexedLogs(ctx, extStart-1)
+				logs = append(logs, found...)
+				if err != nil || uint64(f.begin) < extStart {
+					return logs, err
+				}
+			}
+			if extEnd > end {
+				extEnd = end
+			}
+			found, err := f.indexedLogs(ctx, extEnd)
+			logs = append(logs, found...)
+			if err != nil {
+				return logs, err
+			}
+		}
+	}
 	rest, err := f.unindexedLogs(ctx, end)
 	logs = append(logs, rest...)
 	return logs, err
@@ -202,7 +283,11 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
+			found, err = f.count(found, number)
 			logs = append(logs, found...)
+			if err != nil {
+				return logs, err
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -210,6 +295,60 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// addressLogs returns the logs matching the filter criteria based on the contract
+// log index, only inspecting the blocks in which the filtered contracts emitted logs.
+func (f *Filter) addressLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	size, _ := f.backend.LogIndexStatus()
+	for section := uint64(f.begin) / size; section*size <= end; section++ {
+		// Merge the block bitsets of all the filtered contracts
+		var bits []byte
+		for _, address := range f.addresses {
+			blob, err := f.backend.LogIndex(ctx, address, section)
+			if err != nil {
+				return logs, err
+			}
+			if blob == nil {
+				continue
+			}
+			if bits == nil {
+				bits = make([]byte, len(blob))
+			}
+			bitutil.ORBytes(bits, bits, blob)
+		}
+		// Pull the matching logs from the blocks flagged in the section
+		for i := uint64(0); i < size && bits != nil; i++ {
+			number := section*size + i
+			if number < uint64(f.begin) || bits[i/8]&(1<<byte(7-i%8)) == 0 {
+				continue
+			}
+			if number > end {
+				break
+			}
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			found, err = f.count(found, number)
+			logs = append(logs, found...)
+			if err != nil {
+				return logs, err
+			}
+			f.begin = int64(number) + 1
+		}
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -221,10 +360,10 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 			return logs, err
 		}
 		found, err := f.blockLogs(ctx, header)
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
 	}
 	return logs, nil
 }
@@ -236,11 +375,50 @@ func (f *Filter) blockLogs(ctx context.Context, header *types.Header) (logs []*t
 		if err != nil {
 			return logs, err
 		}
+		found, err = f.count(found, header.Number.Uint64())
 		logs = append(logs, found...)
+		if err != nil {
+			return logs, err
+		}
 	}
 	return logs, nil
 }
 
+// count adds the logs found in a block to the total, returning the ones to be
+// included in the result. Without pagination, exceeding the cap fails the query
+// and blocks are never returned partially, so a truncated query can be resumed
+// from the block that hit the cap. The first block is always returned whole even
+// if it exceeds the cap by itself, otherwise resuming would never get past it.
+// With pagination, the logs fitting the page
+// are returned along with the position to resume from.
+func (f *Filter) count(found []*types.Log, number uint64) ([]*types.Log, error) {
+	skip := uint64(0)
+	if f.skip > 0 {
+		// Only the first block of a page can have been partially returned
+		if skip = f.skip; skip > uint64(len(found)) {
+			skip = uint64(len(found))
+		}
+		found, f.skip = found[skip:], 0
+	}
+	if f.page > 0 {
+		if f.found+uint64(len(found)) > f.page {
+			n := f.page - f.found
+			f.found = f.page
+			return found[:n], &logsPageError{next: number, skip: skip + n}
+		}
+		f.found += uint64(len(found))
+		return found, nil
+	}
+	if f.limit == 0 {
+		return found, nil
+	}
+	if f.found > 0 && f.found+uint64(len(found)) > f.limit {
+		return nil, &logsTruncatedError{limit: f.limit, next: number}
+	}
+	f.found += uint64(len(found))
+	return found, nil
+}
+
 // checkMatches checks if the receipts belonging to the given header contain any log events that
 // match the filter criteria. This function is called when the bloom filter signals a potential match.
 func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs []*types.Log, err error) {
@@ -18,16 +18,19 @@ package filters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
 	"reflect"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/bitutil"
 	"github.com/acent/go-acent/consensus/ethash"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/bloombits"
@@ -47,11 +50,22 @@ type testBackend struct {
 	mux             *event.TypeMux
 	db              ethdb.Database
 	sections        uint64
+	bloomSize       uint64 // Section size of the bloom bits, the default if zero
+	extFirst        uint64
+	extCount        uint64
 	txFeed          event.Feed
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	reorgFeed       event.Feed
+	logsCap         uint64
+	logsPageSize    uint64
+	logIndexer      *core.ChainIndexer
+	logIndexSize    uint64
+	headerReads     uint64
+	finalized       uint64      // Number of the block reported as safe and finalized
+	brokenLogs      common.Hash // Block whose logs fail to be retrieved
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -70,10 +84,14 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 			return nil, nil
 		}
 		num = *number
+	} else if blockNr == rpc.SafeBlockNumber || blockNr == rpc.FinalizedBlockNumber {
+		num = b.finalized
+		hash = rawdb.ReadCanonicalHash(b.db, num)
 	} else {
 		num = uint64(blockNr)
 		hash = rawdb.ReadCanonicalHash(b.db, num)
 	}
+	atomic.AddUint64(&b.headerReads, 1)
 	return rawdb.ReadHeader(b.db, hash, num), nil
 }
 
@@ -93,6 +111,9 @@ func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.
 }
 
 func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	if hash == b.brokenLogs {
+		return nil, errors.New("logs unavailable")
+	}
 	number := rawdb.ReadHeaderNumber(b.db, hash)
 	if number == nil {
 		return nil, nil
@@ -126,10 +147,29 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.reorgFeed.Subscribe(ch)
+}
+
+func (b *testBackend) RPCLogsCap() uint64 {
+	return b.logsCap
+}
+
+func (b *testBackend) RPCLogsPageSize() uint64 {
+	return b.logsPageSize
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
+	if b.bloomSize != 0 {
+		return b.bloomSize, b.sections
+	}
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) BloomExtent() (uint64, uint64) {
+	return b.extFirst, b.extCount
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -147,8 +187,11 @@ func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.Matc
 				task.Bitsets = make([][]byte, len(task.Sections))
 				for i, section := range task.Sections {
 					if rand.Int()%4 != 0 { // Handle occasional missing deliveries
-						head := rawdb.ReadCanonicalHash(b.db, (section+1)*params.BloomBitsBlocks-1)
-						task.Bitsets[i], _ = rawdb.ReadBloomBits(b.db, task.Bit, section, head)
+						size, _ := b.BloomStatus()
+						head := rawdb.ReadCanonicalHash(b.db, (section+1)*size-1)
+						if compVector, err := rawdb.ReadBloomBits(b.db, task.Bit, section, head); err == nil {
+							task.Bitsets[i], _ = bitutil.DecompressBytes(compVector, int(size/8))
+						}
 					}
 				}
 				request <- task
@@ -157,6 +200,23 @@ func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.Matc
 	}()
 }
 
+func (b *testBackend) LogIndexStatus() (uint64, uint64) {
+	if b.logIndexer == nil {
+		return 0, 0
+	}
+	sections, _, _ := b.logIndexer.Sections()
+	return b.logIndexSize, sections
+}
+
+func (b *testBackend) LogIndex(ctx context.Context, address common.Address, section uint64) ([]byte, error) {
+	head := rawdb.ReadCanonicalHash(b.db, (section+1)*b.logIndexSize-1)
+	compVector := rawdb.ReadLogIndex(b.db, address, section, head)
+	if compVector == nil {
+		return nil, nil
+	}
+	return bitutil.DecompressBytes(compVector, int(b.logIndexSize/8))
+}
+
 // TestBlockSubscription tests if a block subscription returns block hashes for posted chain events.
 // It creates multiple subscriptions:
 // - one at the start and should receive all posted chain events and a second (blockHashes)
@@ -213,6 +273,50 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestReorgSubscription tests that reorg subscriptions receive the posted reorg
+// events and convert them into notifications.
+func TestReorgSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		backend  = &testBackend{db: db}
+		api      = NewPublicFilterAPI(backend, false, deadline)
+		genesis  = new(core.Genesis).MustCommit(db)
+		chain, _ = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, func(i int, gen *core.BlockGen) {})
+		tx       = types.NewTransaction(0, common.Address{}, big.NewInt(1), 0, new(big.Int), nil)
+		ev       = core.ReorgEvent{
+			CommonAncestor: chain[0],
+			Dropped:        chain[1:2],
+			Added:          chain[2:],
+			DroppedTxs:     types.Transactions{tx},
+		}
+	)
+	reorgs := make(chan core.ReorgEvent)
+	sub := api.events.SubscribeReorgs(reorgs)
+	defer sub.Unsubscribe()
+
+	backend.reorgFeed.Send(ev)
+	select {
+	case have := <-reorgs:
+		if !reflect.DeepEqual(have, ev) {
+			t.Fatalf("wrong reorg event: have %+v, want %+v", have, ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reorg event not delivered")
+	}
+	n := newReorgNotification(ev)
+	if n.CommonAncestor.Hash != chain[0].Hash() || uint64(n.CommonAncestor.Number) != 1 {
+		t.Errorf("wrong common ancestor: %+v", n.CommonAncestor)
+	}
+	if len(n.Dropped) != 1 || n.Dropped[0].Hash != chain[1].Hash() || len(n.Added) != 1 || n.Added[0].Hash != chain[2].Hash() {
+		t.Errorf("wrong blocks: dropped %+v, added %+v", n.Dropped, n.Added)
+	}
+	if len(n.DroppedTxs) != 1 || n.DroppedTxs[0] != tx.Hash() || len(n.AddedTxs) != 0 {
+		t.Errorf("wrong transactions: dropped %v, added %v", n.DroppedTxs, n.AddedTxs)
+	}
+}
+
 // TestPendingTxFilter tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
@@ -353,7 +457,7 @@ func TestInvalidGetLogsRequest(t *testing.T) {
 	}
 
 	for i, test := range testCases {
-		if _, err := api.GetLogs(context.Background(), test); err == nil {
+		if _, err := api.GetLogs(context.Background(), test, nil); err == nil {
 			t.Errorf("Expected Logs for case #%d to fail", i)
 		}
 	}
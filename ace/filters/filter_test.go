@@ -18,18 +18,26 @@ package filters
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/consensus/ethash"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -193,6 +201,19 @@ func TestFilters(t *testing.T) {
 		t.Errorf("expected log[0].Topics[0] to be %x, got %x", hash3, logs[0].Topics[0])
 	}
 
+	// Ranges can be bounded by the finalized block.
+	backend.finalized = 999
+	filter = NewRangeFilter(backend, rpc.FinalizedBlockNumber.Int64(), -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 || logs[0].Topics[0] != hash3 {
+		t.Errorf("expected 2 logs from the finalized block, got %d", len(logs))
+	}
+	filter = NewRangeFilter(backend, 0, rpc.SafeBlockNumber.Int64(), []common.Address{addr}, [][]common.Hash{{hash4}})
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 0 {
+		t.Error("expected 0 log up to the safe block, got", len(logs))
+	}
+
 	filter = NewRangeFilter(backend, 1, 10, nil, [][]common.Hash{{hash1, hash2}})
 
 	logs, _ = filter.Logs(context.Background())
@@ -222,4 +243,369 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Queries matching more logs than the cap are truncated at a block boundary.
+	backend.logsCap = 3
+	filter = NewRangeFilter(backend, 0, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+
+	logs, err = runLogFilter(context.Background(), filter)
+	terr, ok := err.(*logsTruncatedError)
+	if !ok {
+		t.Fatalf("expected truncation error, got %v (%d logs)", err, len(logs))
+	}
+	if len(terr.logs) != 3 || terr.next != 1000 {
+		t.Errorf("wrong truncation: %d logs, next block %d", len(terr.logs), terr.next)
+	}
+	filter = NewRangeFilter(backend, 0, 999, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+	if logs, err = runLogFilter(context.Background(), filter); err != nil || len(logs) != 3 {
+		t.Errorf("expected 3 logs within the cap, got %d (err %v)", len(logs), err)
+	}
+	// A block exceeding the cap by itself is returned whole to make progress.
+	filter = &Filter{limit: 1}
+	if found, err := filter.count(make([]*types.Log, 2), 5); err != nil || len(found) != 2 {
+		t.Errorf("expected oversized first block to be returned, got %d logs (err %v)", len(found), err)
+	}
+	if _, err := filter.count(make([]*types.Log, 1), 6); err == nil || err.(*logsTruncatedError).next != 6 {
+		t.Errorf("expected truncation at the next block, got %v", err)
+	}
+}
+
+// testIndexerChain is the chain driving the chain indexers of the tests.
+type testIndexerChain struct {
+	db   ethdb.Database
+	feed event.Feed
+}
+
+func (c *testIndexerChain) CurrentHeader() *types.Header {
+	hash := rawdb.ReadHeadBlockHash(c.db)
+	return rawdb.ReadHeader(c.db, hash, *rawdb.ReadHeaderNumber(c.db, hash))
+}
+
+func (c *testIndexerChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, logIndexSize: 256}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = common.BytesToAddress([]byte("jeff"))
+		addr3   = common.BytesToAddress([]byte("acent"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 1000, func(i int, gen *core.BlockGen) {
+		var addr common.Address
+		switch i {
+		case 1, 998:
+			addr = addr1
+		case 500, 800:
+			addr = addr2
+		case 600:
+			addr = addr3
+		default:
+			return
+		}
+		gen.AddUncheckedReceipt(makeReceipt(addr))
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index the first three sections of the chain
+	backend.logIndexer = core.NewLogIndexer(db, backend.logIndexSize, 0)
+	defer backend.logIndexer.Close()
+	backend.logIndexer.Start(&testIndexerChain{db: db})
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, sections := backend.LogIndexStatus(); sections == 3 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("log index not generated")
+		}
+	}
+	for i, tt := range []struct {
+		begin, end int64
+		addresses  []common.Address
+		blocks     []uint64
+		reads      uint64 // maximum number of headers read
+	}{
+		// Queries within the indexed range only touch the blocks with logs
+		{0, 767, []common.Address{addr1}, []uint64{2}, 2},
+		{0, 767, []common.Address{addr2, addr3}, []uint64{501, 601}, 3},
+		{502, 767, []common.Address{addr2, addr3}, []uint64{601}, 2},
+		{0, 700, []common.Address{addr2}, []uint64{501}, 2},
+		// Queries crossing the head of the index continue unindexed
+		{0, -1, []common.Address{addr1, addr2}, []uint64{2, 501, 801, 999}, 240},
+		{900, -1, []common.Address{addr1}, []uint64{999}, 110},
+	} {
+		atomic.StoreUint64(&backend.headerReads, 0)
+		logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, nil).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: filtering failed: %v", i, err)
+		}
+		var blocks []uint64
+		for _, log := range logs {
+			blocks = append(blocks, log.BlockNumber)
+		}
+		if !reflect.DeepEqual(blocks, tt.blocks) {
+			t.Errorf("test %d: wrong blocks: have %v, want %v", i, blocks, tt.blocks)
+		}
+		if reads := atomic.LoadUint64(&backend.headerReads); reads > tt.reads {
+			t.Errorf("test %d: too many headers read: have %d, want at most %d", i, reads, tt.reads)
+		}
+	}
+}
+
+func TestBloomExtentFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, bloomSize: 256}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2   = common.BytesToAddress([]byte("jeff"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 1000, func(i int, gen *core.BlockGen) {
+		var addr common.Address
+		switch i {
+		case 1, 998:
+			addr = addr1
+		case 600, 800:
+			addr = addr2
+		default:
+			return
+		}
+		gen.AddUncheckedReceipt(makeReceipt(addr))
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Rebuild the bloom bits of the blocks 512-767 only, detached from the index
+	core.SetBloomBitsSize(db, backend.bloomSize)
+	if err := core.RebuildBloomBits(db, backend.bloomSize, 2, 2, nil); err != nil {
+		t.Fatalf("failed to rebuild bloom bits: %v", err)
+	}
+	_, backend.extFirst, backend.extCount = core.BloomBitsStatus(db)
+
+	for i, tt := range []struct {
+		begin, end int64
+		addresses  []common.Address
+		blocks     []uint64
+		reads      uint64 // maximum number of headers read
+	}{
+		// Queries within the extent only touch the matching blocks
+		{512, 767, []common.Address{addr2}, []uint64{601}, 2},
+		{600, 700, []common.Address{addr1, addr2}, []uint64{601}, 2},
+		// Queries crossing the extent filter the blocks around it one by one
+		{0, -1, []common.Address{addr1, addr2}, []uint64{2, 601, 801, 999}, 750},
+		{700, 900, []common.Address{addr2}, []uint64{801}, 140},
+	} {
+		atomic.StoreUint64(&backend.headerReads, 0)
+		logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, nil).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: filtering failed: %v", i, err)
+		}
+		var blocks []uint64
+		for _, log := range logs {
+			blocks = append(blocks, log.BlockNumber)
+		}
+		if !reflect.DeepEqual(blocks, tt.blocks) {
+			t.Errorf("test %d: wrong blocks: have %v, want %v", i, blocks, tt.blocks)
+		}
+		if reads := atomic.LoadUint64(&backend.headerReads); reads > tt.reads {
+			t.Errorf("test %d: too many headers read: have %d, want at most %d", i, reads, tt.reads)
+		}
+	}
+}
+
+func TestLogsPagination(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 100, func(i int, gen *core.BlockGen) {
+		var count int
+		switch i {
+		case 10:
+			count = 3
+		case 50:
+			count = 2
+		case 90:
+			count = 1
+		default:
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		for j := 0; j < count; j++ {
+			receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, Topics: []common.Hash{{byte(j)}}})
+		}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// fetch retrieves all pages of a query, returning the logs of each page.
+	fetch := func(crit FilterCriteria, limit uint64) [][]string {
+		var (
+			pages  [][]string
+			cursor *hexutil.Bytes
+		)
+		for {
+			limit := hexutil.Uint64(limit)
+			res, err := api.GetLogs(context.Background(), crit, &LogsPageArgs{Cursor: cursor, Limit: &limit})
+			if err != nil {
+				t.Fatalf("failed to get logs page %d: %v", len(pages), err)
+			}
+			page := res.(*LogsPage)
+
+			var logs []string
+			for _, log := range page.Logs {
+				logs = append(logs, fmt.Sprintf("%d/%d", log.BlockNumber, log.Topics[0][0]))
+			}
+			pages = append(pages, logs)
+			if page.Cursor == nil {
+				return pages
+			}
+			cursor = &page.Cursor
+		}
+	}
+	crit := FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr}}
+
+	// Pages may split blocks, the last one completing the query
+	want := [][]string{{"11/0", "11/1"}, {"11/2", "51/0"}, {"51/1", "91/0"}}
+	if pages := fetch(crit, 2); !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages mismatch: have %v, want %v", pages, want)
+	}
+	// The server enforced page size caps the requested one
+	backend.logsPageSize = 4
+	want = [][]string{{"11/0", "11/1", "11/2", "51/0"}, {"51/1", "91/0"}}
+	if pages := fetch(crit, 10); !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages mismatch: have %v, want %v", pages, want)
+	}
+	// Block queries are paginated too
+	hash := chain[10].Hash()
+	crit = FilterCriteria{BlockHash: &hash, Addresses: []common.Address{addr}}
+	want = [][]string{{"11/0", "11/1"}, {"11/2"}}
+	if pages := fetch(crit, 2); !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages mismatch: have %v, want %v", pages, want)
+	}
+	// Cursors into blocks reorged out must be rejected
+	cursor := (&logsCursor{next: 11, skip: 1, end: 100, hash: common.Hash{0xff}}).encode()
+	crit = FilterCriteria{FromBlock: big.NewInt(0), Addresses: []common.Address{addr}}
+	if _, err := api.GetLogs(context.Background(), crit, &LogsPageArgs{Cursor: &cursor}); err != errLogsCursorReorged {
+		t.Errorf("reorged cursor error mismatch: have %v, want %v", err, errLogsCursorReorged)
+	}
+}
+
+func TestBlockHashesLogs(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	generate := func(parent *types.Block, n int, counts map[int]int) ([]*types.Block, []types.Receipts) {
+		return core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+			count, ok := counts[i]
+			if !ok {
+				return
+			}
+			receipt := types.NewReceipt(nil, false, 0)
+			for j := 0; j < count; j++ {
+				receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, Topics: []common.Hash{{byte(j)}}})
+			}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+		})
+	}
+	chain, receipts := generate(genesis, 20, map[int]int{10: 3, 15: 2})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Create a block reorged out at the height of a canonical one
+	side, sideReceipts := generate(chain[9], 1, map[int]int{0: 1})
+	rawdb.WriteBlock(db, side[0])
+	rawdb.WriteReceipts(db, side[0].Hash(), side[0].NumberU64(), sideReceipts[0])
+
+	crit := FilterCriteria{
+		BlockHashes: []common.Hash{chain[15].Hash(), side[0].Hash(), {0xff}, chain[10].Hash()},
+		Addresses:   []common.Address{addr},
+	}
+	query := func() []string {
+		res, err := api.GetLogs(context.Background(), crit, nil)
+		if err != nil {
+			t.Fatalf("failed to get logs: %v", err)
+		}
+		var have []string
+		for _, result := range res.([]*BlockLogs) {
+			entry := fmt.Sprintf("%x canonical=%v", result.BlockHash[:4], result.Canonical)
+			if result.BlockNumber != nil {
+				entry += fmt.Sprintf(" #%d", *result.BlockNumber)
+			}
+			for _, log := range result.Logs {
+				entry += fmt.Sprintf(" %d/%d", log.BlockNumber, log.Topics[0][0])
+			}
+			if result.Error != "" {
+				entry += " " + result.Error
+			}
+			have = append(have, entry)
+		}
+		return have
+	}
+	want := []string{
+		fmt.Sprintf("%x canonical=true #16 16/0 16/1", chain[15].Hash().Bytes()[:4]),
+		fmt.Sprintf("%x canonical=false #11 11/0", side[0].Hash().Bytes()[:4]),
+		"ff000000 canonical=false unknown block",
+		fmt.Sprintf("%x canonical=true #11 11/0 11/1 11/2", chain[10].Hash().Bytes()[:4]),
+	}
+	if have := query(); !reflect.DeepEqual(have, want) {
+		t.Errorf("results mismatch:\nhave %q\nwant %q", have, want)
+	}
+	// Failures of single blocks are reported without failing the query
+	backend.brokenLogs = chain[15].Hash()
+	want[0] = fmt.Sprintf("%x canonical=true #16 logs unavailable", chain[15].Hash().Bytes()[:4])
+	if have := query(); !reflect.DeepEqual(have, want) {
+		t.Errorf("results mismatch:\nhave %q\nwant %q", have, want)
+	}
+	backend.brokenLogs = common.Hash{}
+
+	// The logs cap applies to all listed blocks together, none exceeding it alone
+	backend.logsCap = 4
+	if _, err := api.GetLogs(context.Background(), crit, nil); err == nil {
+		t.Errorf("logs cap not enforced")
+	}
+	backend.logsCap = 6
+	if _, err := api.GetLogs(context.Background(), crit, nil); err != nil {
+		t.Errorf("logs within the cap rejected: %v", err)
+	}
+	// Block hash lists can't be paginated or subscribed to
+	if _, err := api.GetLogs(context.Background(), crit, &LogsPageArgs{}); err == nil {
+		t.Errorf("paginated block hash list accepted")
+	}
+	if _, err := api.NewFilter(crit); err == nil {
+		t.Errorf("filter with block hash list created")
+	}
 }
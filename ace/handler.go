@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/forkid"
 	"github.com/acent/go-acent/core/types"
@@ -38,6 +39,7 @@ import (
 	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/trie"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -64,6 +66,10 @@ type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// IsPrivate returns whether the transaction with the given hash was submitted
+	// privately and must not be propagated to the network.
+	IsPrivate(hash common.Hash) bool
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
@@ -85,6 +91,16 @@ type handlerConfig struct {
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	Whitelist  map[uint64]common.Hash    // Hard coded whitelist for sync challenged
+	Budget     *membudget.Manager        // Optional memory budget to size the download caches from
+
+	TransactionHistory uint64 // Number of recent blocks whose bodies are fast synced (0 = all)
+	LogHistory         uint64 // Number of recent blocks whose receipts are fast synced (0 = all)
+
+	Watchdog         time.Duration // Time without head progress after which peers are rotated (0 = disabled)
+	WatchdogRotation int           // Percentage of peers to drop when the head stalls
+
+	Observer          bool // Whether to never announce or forward blocks and transactions
+	ObserverServeRate int  // Maximum number of data requests served per second in observer mode
 }
 
 type handler struct {
@@ -107,6 +123,7 @@ type handler struct {
 	stateBloom   *trie.SyncBloom
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
+	txGossip     *txGossipLog
 	peers        *peerSet
 
 	eventMux      *event.TypeMux
@@ -115,12 +132,18 @@ type handler struct {
 	minedBlockSub *event.TypeMuxSubscription
 
 	whitelist map[uint64]common.Hash
+	budget    *membudget.Manager
+
+	observer     bool          // Flag whether gossip propagation is disabled
+	serveLimiter *rate.Limiter // Limiter of the served data requests in observer mode
 
 	// channels for fetcher, syncer, txsyncLoop
 	txsyncCh chan *txsync
 	quitSync chan struct{}
 
 	chainSync *chainSyncer
+	watchdog  *headWatchdog // Head progress watchdog, nil if disabled
+	stallFeed event.Feed
 	wg        sync.WaitGroup
 	peerWG    sync.WaitGroup
 }
@@ -138,11 +161,18 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		database:   config.Database,
 		txpool:     config.TxPool,
 		chain:      config.Chain,
+		txGossip:   newTxGossipLog(txGossipLogSize),
 		peers:      newPeerSet(),
 		whitelist:  config.Whitelist,
+		budget:     config.Budget,
+		observer:   config.Observer,
 		txsyncCh:   make(chan *txsync),
 		quitSync:   make(chan struct{}),
 	}
+	if h.observer {
+		h.serveLimiter = rate.NewLimiter(rate.Limit(config.ObserverServeRate), config.ObserverServeRate)
+		log.Info("Running in observer mode, gossip propagation disabled", "serverate", config.ObserverServeRate)
+	}
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
@@ -185,6 +215,11 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		h.stateBloom = trie.NewSyncBloom(config.BloomCache, config.Database)
 	}
 	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.removePeer)
+	if h.budget != nil {
+		h.downloader.RegisterMemoryBudget(h.budget)
+	}
+	h.downloader.SetPenalizer(h.penalizePeer)
+	h.downloader.SetHistoryLimits(config.TransactionHistory, config.LogHistory)
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -219,7 +254,11 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	dropInvalid := func(id string) {
+		h.penalizePeer(id, p2p.OffenceInvalid)
+		h.removePeer(id)
+	}
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, dropInvalid)
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -230,6 +269,9 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	}
 	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
 	h.chainSync = newChainSyncer(h)
+	if config.Watchdog > 0 {
+		h.watchdog = newHeadWatchdog(h, config.Watchdog, config.WatchdogRotation)
+	}
 	return h, nil
 }
 
@@ -308,7 +350,9 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 
 	// Propagate existing transactions. new transactions appearing
 	// after this will be sent via broadcasts.
-	h.syncTransactions(peer)
+	if !h.observer {
+		h.syncTransactions(peer)
+	}
 
 	// If we have a trusted CHT, reject all peers below that (avoid fast sync eclipse)
 	if h.checkpointHash != (common.Hash{}) {
@@ -388,6 +432,14 @@ func (h *handler) removePeer(id string) {
 	peer.Peer.Disconnect(p2p.DiscUselessPeer)
 }
 
+// penalizePeer reports a misbehaviour of a peer to the reputation tracking of
+// the p2p server, which may ban it for a while.
+func (h *handler) penalizePeer(id string, offence p2p.Offence) {
+	if peer := h.peers.peer(id); peer != nil {
+		peer.Peer.Penalize(offence)
+	}
+}
+
 func (h *handler) Start(maxPeers int) {
 	h.maxPeers = maxPeers
 
@@ -406,6 +458,12 @@ func (h *handler) Start(maxPeers int) {
 	h.wg.Add(2)
 	go h.chainSync.loop()
 	go h.txsyncLoop64() // TODO(karalabe): Legacy initial tx echange, drop with eth/64.
+
+	// start the head watchdog if enabled
+	if h.watchdog != nil {
+		h.wg.Add(1)
+		go h.watchdog.loop()
+	}
 }
 
 func (h *handler) Stop() {
@@ -430,6 +488,9 @@ func (h *handler) Stop() {
 // BroadcastBlock will either propagate a block to a subset of its peers, or
 // will only announce its availability (depending what's requested).
 func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
+	if h.observer {
+		return
+	}
 	hash := block.Hash()
 	peers := h.peers.peersWithoutBlock(hash)
 
@@ -465,6 +526,9 @@ func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
 // - And, separately, as announcements to all peers which are not known to
 // already have the given transaction.
 func (h *handler) BroadcastTransactions(txs types.Transactions) {
+	if h.observer {
+		return
+	}
 	var (
 		annoCount   int // Count of announcements made
 		annoPeers   int
@@ -477,6 +541,9 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
+		if h.txpool.IsPrivate(tx.Hash()) {
+			continue
+		}
 		peers := h.peers.peersWithoutTransaction(tx.Hash())
 		// Send the tx unconditionally to a subset of our peers
 		numDirect := int(math.Sqrt(float64(len(peers))))
@@ -492,10 +559,12 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		directPeers++
 		directCount += len(hashes)
 		peer.AsyncSendTransactions(hashes)
+		h.txGossip.record(peer.ID(), txGossipSentTo, hashes)
 	}
 	for peer, hashes := range annos {
 		annoPeers++
 		annoCount += len(hashes)
+		h.txGossip.record(peer.ID(), txGossipAnnouncedTo, hashes)
 		if peer.Version() >= eth.ETH65 {
 			peer.AsyncSendPooledTransactionHashes(hashes)
 		} else {
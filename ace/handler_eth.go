@@ -28,6 +28,7 @@ import (
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/eth/protocols/eth"
 	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/trie"
 )
@@ -38,7 +39,21 @@ type ethHandler handler
 
 func (h *ethHandler) Chain() *core.BlockChain     { return h.chain }
 func (h *ethHandler) StateBloom() *trie.SyncBloom { return h.stateBloom }
-func (h *ethHandler) TxPool() eth.TxPool          { return h.txpool }
+func (h *ethHandler) TxPool() eth.TxPool          { return publicTxPool{h.txpool} }
+
+// publicTxPool hides the private transactions of the pool from remote peers.
+type publicTxPool struct {
+	txPool
+}
+
+// Get retrieves the transaction from the pool with the given hash, unless it
+// is a private one.
+func (p publicTxPool) Get(hash common.Hash) *types.Transaction {
+	if p.IsPrivate(hash) {
+		return nil
+	}
+	return p.txPool.Get(hash)
+}
 
 // RunPeer is invoked when a peer joins on the `eth` protocol.
 func (h *ethHandler) RunPeer(peer *eth.Peer, hand eth.Handler) error {
@@ -59,6 +74,16 @@ func (h *ethHandler) AcceptTxs() bool {
 	return atomic.LoadUint32(&h.acceptTxs) == 1
 }
 
+// AllowRequest retrieves whether a data request of the remote peer should be
+// served or silently dropped. Requests are only limited in observer mode.
+func (h *ethHandler) AllowRequest(peer *eth.Peer) bool {
+	if h.serveLimiter == nil || h.serveLimiter.Allow() {
+		return true
+	}
+	peer.Log().Trace("Dropping request over observer serve rate")
+	return false
+}
+
 // Handle is invoked from a peer's message handler when it receives a new remote
 // message that the handler couldn't consume and serve itself.
 func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
@@ -74,12 +99,14 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 	case *eth.NodeDataPacket:
 		if err := h.downloader.DeliverNodeData(peer.ID(), *packet); err != nil {
 			log.Debug("Failed to deliver node state data", "err", err)
+			peer.Penalize(p2p.OffenceUseless)
 		}
 		return nil
 
 	case *eth.ReceiptsPacket:
 		if err := h.downloader.DeliverReceipts(peer.ID(), *packet); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
+			peer.Penalize(p2p.OffenceUseless)
 		}
 		return nil
 
@@ -91,12 +118,15 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.handleBlockBroadcast(peer, packet.Block, packet.TD)
 
 	case *eth.NewPooledTransactionHashesPacket:
+		h.txGossip.record(peer.ID(), txGossipAnnouncedBy, *packet)
 		return h.txFetcher.Notify(peer.ID(), *packet)
 
 	case *eth.TransactionsPacket:
+		h.recordReceivedTxs(peer, *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
 
 	case *eth.PooledTransactionsPacket:
+		h.recordReceivedTxs(peer, *packet)
 		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
 
 	default:
@@ -155,6 +185,7 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 		err := h.downloader.DeliverHeaders(peer.ID(), headers)
 		if err != nil {
 			log.Debug("Failed to deliver headers", "err", err)
+			peer.Penalize(p2p.OffenceUseless)
 		}
 	}
 	return nil
@@ -172,6 +203,7 @@ func (h *ethHandler) handleBodies(peer *eth.Peer, txs [][]*types.Transaction, un
 		err := h.downloader.DeliverBodies(peer.ID(), txs, uncles)
 		if err != nil {
 			log.Debug("Failed to deliver bodies", "err", err)
+			peer.Penalize(p2p.OffenceUseless)
 		}
 	}
 	return nil
@@ -216,3 +248,13 @@ func (h *ethHandler) handleBlockBroadcast(peer *eth.Peer, block *types.Block, td
 	}
 	return nil
 }
+
+// recordReceivedTxs adds the transactions delivered by a remote peer to the
+// gossip log.
+func (h *ethHandler) recordReceivedTxs(peer *eth.Peer, txs []*types.Transaction) {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	h.txGossip.record(peer.ID(), txGossipReceivedFrom, hashes)
+}
@@ -38,12 +38,14 @@ import (
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/trie"
+	"golang.org/x/time/rate"
 )
 
 // testEthHandler is a mock event handler to listen for inbound network requests
 // on the `eth` protocol and convert them into a more easily testable form.
 type testEthHandler struct {
 	blockBroadcasts event.Feed
+	blockHeaders    event.Feed
 	txAnnounces     event.Feed
 	txBroadcasts    event.Feed
 }
@@ -52,6 +54,7 @@ func (h *testEthHandler) Chain() *core.BlockChain              { panic("no backi
 func (h *testEthHandler) StateBloom() *trie.SyncBloom          { panic("no backing state bloom") }
 func (h *testEthHandler) TxPool() eth.TxPool                   { panic("no backing tx pool") }
 func (h *testEthHandler) AcceptTxs() bool                      { return true }
+func (h *testEthHandler) AllowRequest(*eth.Peer) bool          { return true }
 func (h *testEthHandler) RunPeer(*eth.Peer, eth.Handler) error { panic("not used in tests") }
 func (h *testEthHandler) PeerInfo(enode.ID) interface{}        { panic("not used in tests") }
 
@@ -61,6 +64,10 @@ func (h *testEthHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		h.blockBroadcasts.Send(packet.Block)
 		return nil
 
+	case *eth.BlockHeadersPacket:
+		h.blockHeaders.Send(([]*types.Header)(*packet))
+		return nil
+
 	case *eth.NewPooledTransactionHashesPacket:
 		h.txAnnounces.Send(([]common.Hash)(*packet))
 		return nil
@@ -91,6 +98,7 @@ func testForkIDSplit(t *testing.T, protocol uint) {
 
 		configNoFork  = &params.ChainConfig{HomesteadBlock: big.NewInt(1)}
 		configProFork = &params.ChainConfig{
+			ChainID:        big.NewInt(1),
 			HomesteadBlock: big.NewInt(1),
 			EIP150Block:    big.NewInt(2),
 			EIP155Block:    big.NewInt(2),
@@ -291,6 +299,10 @@ func testRecvTransactions(t *testing.T, protocol uint) {
 	case <-time.After(2 * time.Second):
 		t.Errorf("no NewTxsEvent received within 2 seconds")
 	}
+	// The sending peer is counted among the peers knowing the transaction
+	if peers := handler.handler.peers.peersWithTransaction(tx.Hash()); len(peers) != 1 {
+		t.Errorf("peers knowing the transaction mismatch: have %d, want 1", len(peers))
+	}
 }
 
 // This test checks that pending transactions are sent.
@@ -462,6 +474,183 @@ func testTransactionPropagation(t *testing.T, protocol uint) {
 	}
 }
 
+// Tests that private transactions are neither synced to new peers, nor
+// broadcast or served to connected ones.
+func TestPrivateTransactions64(t *testing.T) { testPrivateTransactions(t, 64) }
+func TestPrivateTransactions65(t *testing.T) { testPrivateTransactions(t, 65) }
+
+func testPrivateTransactions(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	// Create a source handler to send transactions from and a sink to receive them
+	source := newTestHandler()
+	defer source.close()
+
+	sink := newTestHandler()
+	defer sink.close()
+	sink.handler.acceptTxs = 1 // mark synced to accept transactions
+
+	// Fill the source pool with public and private transactions before and after
+	// the peers connect to test both the initial sync and the broadcasts
+	txs := make([]*types.Transaction, 128)
+	for nonce := range txs {
+		tx := types.NewTransaction(uint64(nonce), common.Address{}, big.NewInt(0), 100000, big.NewInt(0), nil)
+		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+
+		txs[nonce] = tx
+	}
+	source.txpool.AddRemotes(txs[:32])
+	source.txpool.addPrivate(txs[32:64])
+	time.Sleep(250 * time.Millisecond) // Wait until tx events get out of the system
+
+	sourcePipe, sinkPipe := p2p.MsgPipe()
+	defer sourcePipe.Close()
+	defer sinkPipe.Close()
+
+	sourcePeer := eth.NewPeer(protocol, p2p.NewPeer(enode.ID{1}, "", nil), sourcePipe, source.txpool)
+	sinkPeer := eth.NewPeer(protocol, p2p.NewPeer(enode.ID{0}, "", nil), sinkPipe, sink.txpool)
+	defer sourcePeer.Close()
+	defer sinkPeer.Close()
+
+	go source.handler.runEthPeer(sourcePeer, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(source.handler), peer)
+	})
+	go sink.handler.runEthPeer(sinkPeer, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(sink.handler), peer)
+	})
+	txCh := make(chan core.NewTxsEvent, 1024)
+	sub := sink.txpool.SubscribeNewTxsEvent(txCh)
+	defer sub.Unsubscribe()
+
+	time.Sleep(250 * time.Millisecond) // Wait until the peers are registered
+	source.txpool.addPrivate(txs[64:96])
+	source.txpool.AddRemotes(txs[96:])
+
+	// Ensure the sink only ever receives the public transactions
+	arrived := make(map[common.Hash]struct{})
+	timeout := time.NewTimer(time.Second)
+	defer timeout.Stop()
+	for len(arrived) < 64 {
+		select {
+		case event := <-txCh:
+			for _, tx := range event.Txs {
+				arrived[tx.Hash()] = struct{}{}
+			}
+		case <-timeout.C:
+			t.Fatalf("transaction propagation timed out: have %d, want %d", len(arrived), 64)
+		}
+	}
+	select {
+	case event := <-txCh:
+		for _, tx := range event.Txs {
+			arrived[tx.Hash()] = struct{}{}
+		}
+	case <-time.After(250 * time.Millisecond):
+	}
+	for i, tx := range txs {
+		_, ok := arrived[tx.Hash()]
+		if private := (i >= 32 && i < 96); private && ok {
+			t.Errorf("private transaction %d propagated", i)
+		} else if !private && !ok {
+			t.Errorf("public transaction %d missing", i)
+		}
+	}
+	// Ensure private transactions aren't served on request either
+	if tx := (*ethHandler)(source.handler).TxPool().Get(txs[32].Hash()); tx != nil {
+		t.Errorf("private transaction served to remote peers")
+	}
+}
+
+// Tests that a handler in observer mode neither announces nor forwards any
+// transactions, and stops serving requests above its serve rate.
+func TestObserverMode(t *testing.T) {
+	t.Parallel()
+
+	// Create an observer handler with some transactions already pooled, allowing
+	// a single request to be served
+	handler := newTestHandlerWithBlocks(1)
+	defer handler.close()
+
+	handler.handler.observer = true
+	handler.handler.serveLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+
+	txs := make([]*types.Transaction, 20)
+	for nonce := range txs {
+		tx := types.NewTransaction(uint64(nonce), common.Address{}, big.NewInt(0), 100000, big.NewInt(0), nil)
+		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+
+		txs[nonce] = tx
+	}
+	go handler.txpool.AddRemotes(txs[:10])
+	time.Sleep(250 * time.Millisecond)
+
+	// Connect a sink peer and listen for all inbound gossip and replies
+	p2pSrc, p2pSink := p2p.MsgPipe()
+	defer p2pSrc.Close()
+	defer p2pSink.Close()
+
+	src := eth.NewPeer(eth.ETH65, p2p.NewPeer(enode.ID{1}, "", nil), p2pSrc, handler.txpool)
+	sink := eth.NewPeer(eth.ETH65, p2p.NewPeer(enode.ID{2}, "", nil), p2pSink, handler.txpool)
+	defer src.Close()
+	defer sink.Close()
+
+	go handler.handler.runEthPeer(src, func(peer *eth.Peer) error {
+		return eth.Handle((*ethHandler)(handler.handler), peer)
+	})
+	var (
+		genesis = handler.chain.Genesis()
+		head    = handler.chain.CurrentBlock()
+		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
+	)
+	if err := sink.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+		t.Fatalf("failed to run protocol handshake")
+	}
+	backend := new(testEthHandler)
+
+	anns := make(chan []common.Hash)
+	annSub := backend.txAnnounces.Subscribe(anns)
+	defer annSub.Unsubscribe()
+
+	bcasts := make(chan []*types.Transaction)
+	bcastSub := backend.txBroadcasts.Subscribe(bcasts)
+	defer bcastSub.Unsubscribe()
+
+	headers := make(chan []*types.Header)
+	headerSub := backend.blockHeaders.Subscribe(headers)
+	defer headerSub.Unsubscribe()
+
+	go eth.Handle(backend, sink)
+
+	// Pool some more transactions, none of them should be gossiped
+	handler.txpool.AddRemotes(txs[10:])
+
+	// Request the head header twice, only the first should be served
+	for i := 0; i < 2; i++ {
+		if err := sink.RequestHeadersByNumber(head.NumberU64(), 1, 0, false); err != nil {
+			t.Fatalf("failed to request headers: %v", err)
+		}
+	}
+	timeout := time.NewTimer(500 * time.Millisecond)
+	defer timeout.Stop()
+
+	var served int
+	for done := false; !done; {
+		select {
+		case hashes := <-anns:
+			t.Errorf("observer announced %d transactions", len(hashes))
+		case txs := <-bcasts:
+			t.Errorf("observer broadcast %d transactions", len(txs))
+		case <-headers:
+			served++
+		case <-timeout.C:
+			done = true
+		}
+	}
+	if served != 1 {
+		t.Errorf("served requests mismatch: have %d, want 1", served)
+	}
+}
+
 // Tests that post eth protocol handshake, clients perform a mutual checkpoint
 // challenge to validate each other's chains. Hash mismatches, or missing ones
 // during a fast sync should lead to the peer getting dropped.
@@ -38,7 +38,13 @@ func (e enrEntry) ENRKey() string {
 
 // StartENRUpdater starts the `eth` ENR updater loop, which listens for chain
 // head events and updates the requested node record whenever a fork is passed.
-func StartENRUpdater(chain *core.BlockChain, ln *enode.LocalNode) {
+// The entry is registered as an attribute of the local node, so it can't be
+// overwritten by other services.
+func StartENRUpdater(chain *core.BlockChain, ln *enode.LocalNode) error {
+	attr, err := ln.RegisterAttribute(ProtocolName, currentENREntry(chain))
+	if err != nil {
+		return err
+	}
 	var newHead = make(chan core.ChainHeadEvent, 10)
 	sub := chain.SubscribeChainHeadEvent(newHead)
 
@@ -47,7 +53,7 @@ func StartENRUpdater(chain *core.BlockChain, ln *enode.LocalNode) {
 		for {
 			select {
 			case <-newHead:
-				ln.Set(currentENREntry(chain))
+				attr.Update(currentENREntry(chain))
 			case <-sub.Err():
 				// Would be nice to sync with Stop, but there is no
 				// good way to do that.
@@ -55,6 +61,7 @@ func StartENRUpdater(chain *core.BlockChain, ln *enode.LocalNode) {
 			}
 		}
 	}()
+	return nil
 }
 
 // currentENREntry constructs an `eth` ENR entry based on the current state of the chain.
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -78,6 +79,10 @@ type Backend interface {
 	// or if inbound transactions should simply be dropped.
 	AcceptTxs() bool
 
+	// AllowRequest retrieves whether a data retrieval request of the remote peer
+	// should be served, or silently dropped to limit the serving load.
+	AllowRequest(peer *Peer) bool
+
 	// RunPeer is invoked when a peer joins on the `eth` protocol. The handler
 	// should do any peer maintenance work, handshakes and validations. If all
 	// is passed, control should be given back to the `handler` to process the
@@ -106,9 +111,10 @@ func MakeProtocols(backend Backend, network uint64, dnsdisc enode.Iterator) []p2
 		version := version // Closure
 
 		protocols[i] = p2p.Protocol{
-			Name:    ProtocolName,
-			Version: version,
-			Length:  protocolLengths[version],
+			Name:       ProtocolName,
+			Version:    version,
+			Length:     protocolLengths[version],
+			MaxMsgSize: maxMessageSize,
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 				peer := NewPeer(version, p, rw, backend.TxPool())
 				defer peer.Close()
@@ -159,6 +165,9 @@ func Handle(backend Backend, peer *Peer) error {
 	for {
 		if err := handleMessage(backend, peer); err != nil {
 			peer.Log().Debug("Message handling failed in `eth`", "err", err)
+			if errors.Is(err, errDecode) || errors.Is(err, errInvalidMsgCode) || errors.Is(err, errMsgTooLarge) {
+				peer.Penalize(p2p.OffenceInvalid)
+			}
 			return err
 		}
 	}
@@ -222,6 +231,16 @@ var eth66 = map[uint64]msgHandler{
 	PooledTransactionsMsg:    handlePooledTransactions66,
 }
 
+// requestMsgs are the data retrieval messages, which the backend may decide not
+// to serve.
+var requestMsgs = map[uint64]bool{
+	GetBlockHeadersMsg:       true,
+	GetBlockBodiesMsg:        true,
+	GetNodeDataMsg:           true,
+	GetReceiptsMsg:           true,
+	GetPooledTransactionsMsg: true,
+}
+
 // handleMessage is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
 func handleMessage(backend Backend, peer *Peer) error {
@@ -230,9 +249,6 @@ func handleMessage(backend Backend, peer *Peer) error {
 	if err != nil {
 		return err
 	}
-	if msg.Size > maxMessageSize {
-		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
-	}
 	defer msg.Discard()
 
 	var handlers = eth64
@@ -242,6 +258,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		handlers = eth66
 	}
 
+	if requestMsgs[msg.Code] && !backend.AllowRequest(peer) {
+		return nil
+	}
 	if handler := handlers[msg.Code]; handler != nil {
 		return handler(backend, msg, peer)
 	}
@@ -160,12 +160,17 @@ func answerGetBlockBodiesQuery(backend Backend, query GetBlockBodiesPacket, peer
 	var (
 		bytes  int
 		bodies []rlp.RawValue
+
+		tail, _ = backend.Chain().HistoryTails()
 	)
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(bodies) >= maxBodiesServe ||
 			lookups >= 2*maxBodiesServe {
 			break
 		}
+		if skippedHistory(backend, hash, tail) {
+			continue
+		}
 		if data := backend.Chain().GetBodyRLP(hash); len(data) != 0 {
 			bodies = append(bodies, data)
 			bytes += len(data)
@@ -174,6 +179,16 @@ func answerGetBlockBodiesQuery(backend Backend, query GetBlockBodiesPacket, peer
 	return bodies
 }
 
+// skippedHistory reports whether the block with the given hash is older than the
+// history tail, its data having been skipped by the sync and unavailable to serve.
+func skippedHistory(backend Backend, hash common.Hash, tail uint64) bool {
+	if tail == 0 {
+		return false
+	}
+	header := backend.Chain().GetHeaderByHash(hash)
+	return header != nil && header.Number.Uint64() < tail
+}
+
 func handleGetNodeData(backend Backend, msg Decoder, peer *Peer) error {
 	// Decode the trie node data retrieval message
 	var query GetNodeDataPacket
@@ -248,12 +263,17 @@ func answerGetReceiptsQuery(backend Backend, query GetReceiptsPacket, peer *Peer
 	var (
 		bytes    int
 		receipts []rlp.RawValue
+
+		_, tail = backend.Chain().HistoryTails()
 	)
 	for lookups, hash := range query {
 		if bytes >= softResponseLimit || len(receipts) >= maxReceiptsServe ||
 			lookups >= 2*maxReceiptsServe {
 			break
 		}
+		if skippedHistory(backend, hash, tail) {
+			continue
+		}
 		// Retrieve the requested block's receipts
 		results := backend.Chain().GetReceiptsByHash(hash)
 		if results == nil {
@@ -146,7 +146,7 @@ func (p *Peer) SetHead(hash common.Hash, td *big.Int) {
 	defer p.lock.Unlock()
 
 	copy(p.head[:], hash[:])
-	p.td.Set(td)
+	p.td = new(big.Int).Set(td)
 }
 
 // KnownBlock returns whether peer is known to already have a block.
@@ -47,7 +47,8 @@ var ProtocolVersions = []uint{ETH66, ETH65, ETH64}
 // different protocol versions.
 var protocolLengths = map[uint]uint64{ETH66: 17, ETH65: 17, ETH64: 17}
 
-// maxMessageSize is the maximum cap on the size of a protocol message.
+// maxMessageSize is the default cap on the size of a protocol message, enforced
+// by the p2p layer unless overridden by the node configuration.
 const maxMessageSize = 10 * 1024 * 1024
 
 const (
@@ -82,9 +82,10 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 		version := version // Closure
 
 		protocols[i] = p2p.Protocol{
-			Name:    ProtocolName,
-			Version: version,
-			Length:  protocolLengths[version],
+			Name:       ProtocolName,
+			Version:    version,
+			Length:     protocolLengths[version],
+			MaxMsgSize: maxMessageSize,
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 				return backend.RunPeer(newPeer(version, p, rw), func(peer *Peer) error {
 					return handle(backend, peer)
@@ -123,9 +124,6 @@ func handleMessage(backend Backend, peer *Peer) error {
 	if err != nil {
 		return err
 	}
-	if msg.Size > maxMessageSize {
-		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
-	}
 	defer msg.Discard()
 
 	// Handle the message depending on its contents
@@ -42,7 +42,8 @@ var ProtocolVersions = []uint{snap1}
 // different protocol versions.
 var protocolLengths = map[uint]uint64{snap1: 8}
 
-// maxMessageSize is the maximum cap on the size of a protocol message.
+// maxMessageSize is the default cap on the size of a protocol message, enforced
+// by the p2p layer unless overridden by the node configuration.
 const maxMessageSize = 10 * 1024 * 1024
 
 const (
@@ -57,7 +58,6 @@ const (
 )
 
 var (
-	errMsgTooLarge    = errors.New("message too long")
 	errDecode         = errors.New("invalid message")
 	errInvalidMsgCode = errors.New("invalid message code")
 	errBadRequest     = errors.New("bad request")
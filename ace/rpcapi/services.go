@@ -0,0 +1,134 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rpcapi composes the RPC API services shared by the full and light
+// clients.
+//
+// Chains built on acent can use it to customise the RPC behaviour through hooks,
+// or to replace, drop or add services, without forking the API implementation.
+// The clients register the default services on their node; services created
+// here on top of the client's API backend and registered on the node afterwards
+// override the default methods of the same name.
+package rpcapi
+
+import (
+	"github.com/acent/go-acent/internal/ethapi"
+	"github.com/acent/go-acent/rpc"
+)
+
+type (
+	// Backend is the interface the API services are built on, implemented by
+	// the API backends of the full and light clients.
+	Backend = ethapi.Backend
+
+	// Hooks are the override points of the API services. Any of the hooks may
+	// be nil.
+	Hooks = ethapi.Hooks
+
+	// SendTxArgs are the arguments of a transaction to be filled, signed or
+	// sent, as passed to the transaction validation hook.
+	SendTxArgs = ethapi.SendTxArgs
+)
+
+// Services is the set of API services shared by the full and light clients.
+// Chains built on acent may construct it with their own hooks, then replace,
+// drop or add services before registering the result of APIs. Services added
+// to a namespace after the default ones override their methods of the same
+// name.
+type Services struct {
+	Acent           *ethapi.PublicAcentAPI
+	BlockChain      *ethapi.PublicBlockChainAPI
+	TransactionPool *ethapi.PublicTransactionPoolAPI
+	Bundle          *ethapi.PublicBundleAPI
+	Conditional     *ethapi.PrivateConditionalAPI
+	Private         *ethapi.PrivateTransactionAPI
+	TxPool          *ethapi.PublicTxPoolAPI
+	PublicDebug     *ethapi.PublicDebugAPI
+	PrivateDebug    *ethapi.PrivateDebugAPI
+	Account         *ethapi.PublicAccountAPI
+	PrivateAccount  *ethapi.PrivateAccountAPI
+
+	// Extra are additional APIs registered after the default services.
+	Extra []rpc.API
+}
+
+// NewServices creates the API services on top of the backend, customised by
+// the given hooks, which may be nil.
+func NewServices(b Backend, hooks *Hooks) *Services {
+	nonceLock := new(ethapi.AddrLocker)
+
+	return &Services{
+		Acent:           ethapi.NewPublicAcentAPI(b),
+		BlockChain:      ethapi.NewPublicBlockChainAPI(b, hooks),
+		TransactionPool: ethapi.NewPublicTransactionPoolAPI(b, nonceLock, hooks),
+		Bundle:          ethapi.NewPublicBundleAPI(b),
+		Conditional:     ethapi.NewPrivateConditionalAPI(b),
+		Private:         ethapi.NewPrivateTransactionAPI(b),
+		TxPool:          ethapi.NewPublicTxPoolAPI(b),
+		PublicDebug:     ethapi.NewPublicDebugAPI(b),
+		PrivateDebug:    ethapi.NewPrivateDebugAPI(b),
+		Account:         ethapi.NewPublicAccountAPI(b.AccountManager()),
+		PrivateAccount:  ethapi.NewPrivateAccountAPI(b, nonceLock, hooks),
+	}
+}
+
+// GetAPIs returns the default API services on top of the backend.
+func GetAPIs(b Backend) []rpc.API {
+	return NewServices(b, nil).APIs()
+}
+
+// APIs returns the RPC descriptors of the services, skipping the ones set to
+// nil, followed by the extra APIs.
+func (s *Services) APIs() []rpc.API {
+	var apis []rpc.API
+	add := func(namespace string, service interface{}, public bool) {
+		apis = append(apis, rpc.API{Namespace: namespace, Version: "1.0", Service: service, Public: public})
+	}
+	if s.Acent != nil {
+		add("eth", s.Acent, true)
+	}
+	if s.BlockChain != nil {
+		add("eth", s.BlockChain, true)
+	}
+	if s.TransactionPool != nil {
+		add("eth", s.TransactionPool, true)
+	}
+	if s.Bundle != nil {
+		add("eth", s.Bundle, true)
+	}
+	if s.Conditional != nil {
+		add("relay", s.Conditional, false)
+	}
+	if s.Private != nil {
+		add("eth", s.Private, false)
+	}
+	if s.TxPool != nil {
+		add("txpool", s.TxPool, true)
+	}
+	if s.PublicDebug != nil {
+		add("debug", s.PublicDebug, true)
+	}
+	if s.PrivateDebug != nil {
+		add("debug", s.PrivateDebug, false)
+	}
+	if s.Account != nil {
+		add("eth", s.Account, true)
+	}
+	if s.PrivateAccount != nil {
+		add("personal", s.PrivateAccount, false)
+	}
+	return append(apis, s.Extra...)
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpcapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/accounts"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rpc"
+)
+
+// testBackend is an API backend serving a single header. Calling any method not
+// implemented here panics.
+type testBackend struct {
+	Backend
+	header *types.Header
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager {
+	return accounts.NewManager(&accounts.Config{})
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return b.header, nil
+}
+
+func (b *testBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
+	return big.NewInt(1)
+}
+
+// Tests that the default services are registered in their namespaces, that
+// dropped services are skipped and that extra services come last.
+func TestServicesAPIs(t *testing.T) {
+	services := NewServices(&testBackend{header: &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}}, nil)
+
+	count := func(apis []rpc.API, namespace string) (n int) {
+		for _, api := range apis {
+			if api.Namespace == namespace {
+				n++
+			}
+		}
+		return n
+	}
+	apis := services.APIs()
+	for namespace, want := range map[string]int{"eth": 6, "relay": 1, "txpool": 1, "debug": 2, "personal": 1} {
+		if have := count(apis, namespace); have != want {
+			t.Errorf("namespace %q: service count mismatch: have %d, want %d", namespace, have, want)
+		}
+	}
+	// Drop a few services and add a custom one
+	services.PrivateAccount = nil
+	services.PrivateDebug = nil
+	services.Extra = append(services.Extra, rpc.API{Namespace: "custom", Version: "1.0", Service: new(struct{}), Public: true})
+
+	apis = services.APIs()
+	if have := count(apis, "personal"); have != 0 {
+		t.Errorf("dropped personal service registered")
+	}
+	if have := count(apis, "debug"); have != 1 {
+		t.Errorf("debug service count mismatch: have %d, want 1", have)
+	}
+	if last := apis[len(apis)-1]; last.Namespace != "custom" {
+		t.Errorf("extra service not registered last: %+v", last)
+	}
+}
+
+// Tests that the hooks the services are created with are invoked.
+func TestServicesHooks(t *testing.T) {
+	hooks := &Hooks{
+		MarshalHeader: func(ctx context.Context, header *types.Header, fields map[string]interface{}) {
+			fields["custom"] = header.Number.Uint64() * 2
+		},
+	}
+	backend := &testBackend{header: &types.Header{Number: big.NewInt(21), Difficulty: big.NewInt(1)}}
+
+	header, err := NewServices(backend, hooks).BlockChain.GetHeaderByNumber(context.Background(), 21)
+	if err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	if have, ok := header["custom"]; !ok || have != uint64(42) {
+		t.Errorf("custom header field mismatch: have %v, want %v", have, 42)
+	}
+	// Services created without hooks leave the output alone
+	header, err = NewServices(backend, nil).BlockChain.GetHeaderByNumber(context.Background(), 21)
+	if err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	if _, ok := header["custom"]; ok {
+		t.Errorf("custom header field added without hooks")
+	}
+}
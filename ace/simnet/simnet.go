@@ -0,0 +1,236 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simnet runs networks of full eth nodes within a single process, their
+// p2p connections being in-memory pipes instead of sockets. It allows testing the
+// interplay of the eth subsystems (fetchers, downloader, transaction pool) across
+// nodes without touching the network.
+package simnet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/eth"
+	"github.com/acent/go-acent/eth/ethconfig"
+	"github.com/acent/go-acent/node"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/simulations/adapters"
+)
+
+// serviceName is the name the eth service is registered with in the adapter.
+const serviceName = "eth"
+
+// simPort is the TCP port advertised in the records of the nodes.
+const simPort = 30303
+
+// pollInterval is the frequency at which convergence conditions are checked.
+const pollInterval = 10 * time.Millisecond
+
+// errClosed is returned when adding nodes to a closed network.
+var errClosed = errors.New("network closed")
+
+// Network is a set of full eth nodes connected by in-memory pipes.
+type Network struct {
+	config  *ethconfig.Config
+	adapter *adapters.SimAdapter
+
+	nodes  []*Node
+	closed bool
+	lock   sync.Mutex
+}
+
+// Node is a full eth node running within a simulated network.
+type Node struct {
+	Eth *eth.Acent
+
+	sim *adapters.SimNode
+}
+
+// NewNetwork creates an empty network whose nodes will run with the given eth
+// configuration, which must specify the genesis of the chain.
+func NewNetwork(config *ethconfig.Config) *Network {
+	n := &Network{config: config}
+	n.adapter = adapters.NewSimAdapter(adapters.LifecycleConstructors{
+		serviceName: func(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+			config := *n.config // Every node needs its own, eth mutates it
+			return eth.New(stack, &config)
+		},
+	})
+	return n
+}
+
+// AddNode creates and starts a new node, not connected to any other.
+func (n *Network) AddNode() (*Node, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.closed {
+		return nil, errClosed
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	sim, err := n.adapter.NewNode(&adapters.NodeConfig{
+		ID:         id,
+		PrivateKey: key,
+		Name:       fmt.Sprintf("node%d", len(n.nodes)),
+		Port:       simPort, // Never listened on, but dialing needs an endpoint
+		Lifecycles: []string{serviceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.Start(nil); err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Eth: sim.(*adapters.SimNode).Service(serviceName).(*eth.Acent),
+		sim: sim.(*adapters.SimNode),
+	}
+	n.nodes = append(n.nodes, node)
+	return node, nil
+}
+
+// Nodes returns the nodes of the network, in the order they were added.
+func (n *Network) Nodes() []*Node {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return append([]*Node{}, n.nodes...)
+}
+
+// Connect dials b from a. The connection is established asynchronously.
+func (n *Network) Connect(a, b *Node) {
+	a.sim.Server().AddPeer(b.sim.Node())
+}
+
+// ConnectAll connects every pair of nodes of the network.
+func (n *Network) ConnectAll() {
+	nodes := n.Nodes()
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			n.Connect(nodes[i], nodes[j])
+		}
+	}
+}
+
+// Close stops all the nodes of the network.
+func (n *Network) Close() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for _, node := range n.nodes {
+		node.sim.Close()
+	}
+	n.nodes, n.closed = nil, true
+}
+
+// WaitPeers waits until every node of the network is connected to at least the
+// given number of peers.
+func (n *Network) WaitPeers(peers int, timeout time.Duration) error {
+	return n.wait(timeout, func(nodes []*Node) error {
+		for i, node := range nodes {
+			if have := node.sim.Server().PeerCount(); have < peers {
+				return fmt.Errorf("node %d has %d peers, want %d", i, have, peers)
+			}
+		}
+		return nil
+	})
+}
+
+// Sync kicks the chain syncer of every node of the network, so nodes behind
+// their peers start downloading without waiting for the periodic sync cycle.
+func (n *Network) Sync() {
+	for _, node := range n.Nodes() {
+		node.Eth.ForceSync()
+	}
+}
+
+// WaitHeads waits until all the nodes of the network have the same head block,
+// returning an error listing the heads if they didn't converge in time. Nodes
+// are kicked into syncing while their heads diverge.
+func (n *Network) WaitHeads(timeout time.Duration) error {
+	return n.wait(timeout, func(nodes []*Node) error {
+		heads := make([]string, len(nodes))
+		converged := true
+		for i, node := range nodes {
+			head := node.Eth.BlockChain().CurrentBlock()
+			heads[i] = fmt.Sprintf("#%d [%x…]", head.NumberU64(), head.Hash().Bytes()[:4])
+			converged = converged && heads[i] == heads[0]
+		}
+		if !converged {
+			n.Sync()
+			return fmt.Errorf("heads diverge: %s", strings.Join(heads, ", "))
+		}
+		return nil
+	})
+}
+
+// WaitTxs waits until the transaction pools of all the nodes of the network
+// contain the given transactions. Nodes only accept transactions from the network
+// once they are synchronised.
+func (n *Network) WaitTxs(hashes []common.Hash, timeout time.Duration) error {
+	return n.wait(timeout, func(nodes []*Node) error {
+		for i, node := range nodes {
+			for _, hash := range hashes {
+				if !node.Eth.TxPool().Has(hash) {
+					return fmt.Errorf("node %d misses transaction %x", i, hash)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// wait polls a condition over the nodes of the network until it's met or the
+// timeout expires, returning the last failure in the latter case.
+func (n *Network) wait(timeout time.Duration, cond func(nodes []*Node) error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := cond(n.Nodes())
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// ImportBlocks inserts the blocks into the chain of the node and propagates the
+// new head to its peers, as if the node had mined them.
+func (nd *Node) ImportBlocks(blocks []*types.Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if _, err := nd.Eth.BlockChain().InsertChain(blocks); err != nil {
+		return err
+	}
+	return nd.Eth.EventMux().Post(core.NewMinedBlockEvent{Block: blocks[len(blocks)-1]})
+}
+
+// ID returns the p2p identifier of the node.
+func (nd *Node) ID() enode.ID {
+	return nd.sim.ID
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package simnet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/eth/downloader"
+	"github.com/acent/go-acent/eth/ethconfig"
+	"github.com/acent/go-acent/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// Tests that nodes sync up with a node ahead of them, then follow the blocks it
+// propagates and the transactions it announces.
+func TestNetworkConvergence(t *testing.T) {
+	// Recent timestamps are needed for nodes to consider themselves synced
+	genesis := &core.Genesis{
+		Config:    params.TestChainConfig,
+		Alloc:     core.GenesisAlloc{testAddress: {Balance: big.NewInt(params.Ether)}},
+		Timestamp: uint64(time.Now().Add(-time.Hour).Unix()),
+	}
+	db := rawdb.NewMemoryDatabase()
+	blocks, _ := core.GenerateChain(genesis.Config, genesis.MustCommit(db), ethash.NewFaker(), db, 33, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	config := ethconfig.Defaults
+	config.Genesis = genesis
+	config.SyncMode = downloader.FullSync
+	config.Ethash.PowMode = ethash.ModeFake
+
+	network := NewNetwork(&config)
+	defer network.Close()
+
+	var nodes []*Node
+	for i := 0; i < 3; i++ {
+		node, err := network.AddNode()
+		if err != nil {
+			t.Fatalf("failed to add node %d: %v", i, err)
+		}
+		nodes = append(nodes, node)
+	}
+	// Give the chain to the first node, the others must download it
+	if _, err := nodes[0].Eth.BlockChain().InsertChain(blocks[:32]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	network.ConnectAll()
+	if err := network.WaitPeers(2, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.WaitHeads(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Blocks and transactions of the first node must be propagated
+	if err := nodes[0].ImportBlocks(blocks[32:]); err != nil {
+		t.Fatalf("failed to import block: %v", err)
+	}
+	if err := network.WaitHeads(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if head := nodes[2].Eth.BlockChain().CurrentBlock(); head.Hash() != blocks[32].Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), blocks[32].NumberU64())
+	}
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0x02}, big.NewInt(1), params.TxGas, big.NewInt(params.GWei), nil), types.HomesteadSigner{}, testKey)
+	if err := nodes[0].Eth.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := network.WaitTxs([]common.Hash{tx.Hash()}, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/state"
@@ -61,7 +62,7 @@ func (eth *Acent) stateAtBlock(block *types.Block, reexec uint64) (statedb *stat
 	if err != nil {
 		switch err.(type) {
 		case *trie.MissingNodeError:
-			return nil, nil, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
+			return nil, nil, acent.ErrStatePruned.Errorf("required historical state unavailable (reexec=%d)", reexec)
 		default:
 			return nil, nil, err
 		}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/eth/downloader"
@@ -34,6 +35,7 @@ import (
 const (
 	forceSyncCycle      = 10 * time.Second // Time interval to force syncs, even if few peers are available
 	defaultMinSyncPeers = 5                // Amount of peers desired to start syncing
+	budgetResyncLag     = time.Hour        // Head age after which the memory budget favours syncing again
 
 	// This is the target size for the packs of transactions sent by txsyncLoop64.
 	// A pack can get larger than this if a single transactions exceeds this size.
@@ -56,7 +58,11 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	var txs types.Transactions
 	pending, _ := h.txpool.Pending()
 	for _, batch := range pending {
-		txs = append(txs, batch...)
+		for _, tx := range batch {
+			if !h.txpool.IsPrivate(tx.Hash()) {
+				txs = append(txs, tx)
+			}
+		}
 	}
 	if len(txs) == 0 {
 		return
@@ -159,6 +165,7 @@ type chainSyncer struct {
 	handler     *handler
 	force       *time.Timer
 	forced      bool // true when force timer fired
+	forceCh     chan struct{}
 	peerEventCh chan struct{}
 	doneCh      chan error // non-nil when sync is running
 }
@@ -175,6 +182,7 @@ type chainSyncOp struct {
 func newChainSyncer(handler *handler) *chainSyncer {
 	return &chainSyncer{
 		handler:     handler,
+		forceCh:     make(chan struct{}),
 		peerEventCh: make(chan struct{}),
 	}
 }
@@ -191,6 +199,15 @@ func (cs *chainSyncer) handlePeerEvent(peer *eth.Peer) bool {
 	}
 }
 
+// forceSync requests a sync cycle to be started even if there are few peers,
+// without waiting for the force timer.
+func (cs *chainSyncer) forceSync() {
+	select {
+	case cs.forceCh <- struct{}{}:
+	case <-cs.handler.quitSync:
+	}
+}
+
 // loop runs in its own goroutine and launches the sync when necessary.
 func (cs *chainSyncer) loop() {
 	defer cs.handler.wg.Done()
@@ -219,6 +236,8 @@ func (cs *chainSyncer) loop() {
 			cs.forced = false
 		case <-cs.force.C:
 			cs.forced = true
+		case <-cs.forceCh:
+			cs.forced = true
 
 		case <-cs.handler.quitSync:
 			// Disable all insertion on the blockchain. This needs to happen before
@@ -320,6 +339,12 @@ func (h *handler) doSync(op *chainSyncOp) error {
 			log.Warn("Update txLookup limit", "provided", limit, "updated", *stored)
 		}
 	}
+	// If the node fell behind, hand the memory back to the import caches
+	if h.budget != nil {
+		if head := h.chain.CurrentBlock(); time.Since(time.Unix(int64(head.Time()), 0)) > budgetResyncLag {
+			h.budget.SetPhase(membudget.PhaseSyncing)
+		}
+	}
 	// Run the sync cycle, and disable fast sync if we're past the pivot block
 	err := h.downloader.Synchronise(op.peer.ID(), op.head, op.td, op.mode)
 	if err != nil {
@@ -341,6 +366,9 @@ func (h *handler) doSync(op *chainSyncOp) error {
 		// for non-checkpointed (number = 0) private networks.
 		if head.Time() >= uint64(time.Now().AddDate(0, -1, 0).Unix()) {
 			atomic.StoreUint32(&h.acceptTxs, 1)
+			if h.budget != nil {
+				h.budget.SetPhase(membudget.PhaseServing)
+			}
 		}
 	}
 	if head.NumberU64() > 0 {
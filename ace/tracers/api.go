@@ -28,6 +28,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/consensus"
@@ -42,6 +43,7 @@ import (
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/rpc"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const (
@@ -53,6 +55,10 @@ const (
 	// and reexecute to produce missing historical state necessary to run a specific
 	// trace.
 	defaultTraceReexec = uint64(128)
+
+	// gasProfileTracer is the name of the native tracer reporting the gas spent
+	// per opcode and call frame.
+	gasProfileTracer = "gasProfile"
 )
 
 // Backend interface provides the common API services (that are provided by
@@ -74,12 +80,14 @@ type Backend interface {
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
-	backend Backend
+	backend    Backend
+	traceCache *lru.Cache // Flattened call traces of recently filtered blocks
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Acent service.
 func NewAPI(backend Backend) *API {
-	return &API{backend: backend}
+	traceCache, _ := lru.New(traceFilterCacheLimit)
+	return &API{backend: backend, traceCache: traceCache}
 }
 
 type chainContext struct {
@@ -674,10 +682,13 @@ func containsTx(block *types.Block, hash common.Hash) bool {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
-	_, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	tx, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
+	if tx == nil {
+		return nil, acent.ErrTxNotFound.Errorf("transaction %#x not found", hash)
+	}
 	// It shouldn't happen in practice.
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
@@ -751,6 +762,9 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 		txContext = core.NewEVMTxContext(message)
 	)
 	switch {
+	case config != nil && config.Tracer != nil && *config.Tracer == gasProfileTracer:
+		tracer = vm.NewGasProfiler()
+
 	case config != nil && config.Tracer != nil:
 		// Define a meaningful timeout of a single transaction trace
 		timeout := defaultTraceTimeout
@@ -803,6 +817,9 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
+	case *vm.GasProfiler:
+		return tracer.Profile(), nil
+
 	case *Tracer:
 		return tracer.GetResult()
 
@@ -211,6 +211,7 @@ func TestTraceCall(t *testing.T) {
 		b.AddTx(tx)
 	}))
 
+	gasProfile := gasProfileTracer
 	var testSuite = []struct {
 		blockNumber rpc.BlockNumber
 		call        ethapi.CallArgs
@@ -298,6 +299,21 @@ func TestTraceCall(t *testing.T) {
 				StructLogs:  []ethapi.StructLogRes{},
 			},
 		},
+		// Gas profile upon the head, plain transfer.
+		{
+			blockNumber: rpc.BlockNumber(genBlocks),
+			call: ethapi.CallArgs{
+				From:  &accounts[0].addr,
+				To:    &accounts[1].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			config:    &TraceConfig{Tracer: &gasProfile},
+			expectErr: nil,
+			expect: &vm.GasProfile{
+				Opcodes: []vm.OpcodeGas{},
+				Frames:  []vm.FrameGas{{Type: "CALL", Depth: 1, Address: accounts[1].addr}},
+			},
+		},
 	}
 	for _, testspec := range testSuite {
 		result, err := api.TraceCall(context.Background(), testspec.call, rpc.BlockNumberOrHash{BlockNumber: &testspec.blockNumber}, testspec.config)
@@ -465,6 +481,139 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+// stateCountingBackend is a test backend counting the state regenerations.
+type stateCountingBackend struct {
+	*testBackend
+	ranges, states int
+}
+
+func (b *stateCountingBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64) (*state.StateDB, func(), error) {
+	b.states++
+	return b.testBackend.StateAtBlock(ctx, block, reexec)
+}
+
+func (b *stateCountingBackend) StatesInRange(ctx context.Context, fromBlock *types.Block, toBlock *types.Block, reexec uint64) ([]*state.StateDB, func(), error) {
+	b.ranges++
+	return b.testBackend.StatesInRange(ctx, fromBlock, toBlock, reexec)
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts and a contract forwarding calls to account[2]
+	accounts := newAccounts(3)
+	contract := common.HexToAddress("0xc0de")
+	code := append([]byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH20),
+	}, accounts[2].addr.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		contract:         {Balance: big.NewInt(0), Code: code},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := &stateCountingBackend{testBackend: newTestBackend(t, 10, genesis, func(i int, b *core.BlockGen) {
+		// Even blocks transfer to account[1], odd ones call the contract
+		var tx *types.Transaction
+		if i%2 == 0 {
+			tx, _ = types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, big.NewInt(0), nil), signer, accounts[0].key)
+		} else {
+			tx, _ = types.SignTx(types.NewTransaction(uint64(i), contract, big.NewInt(0), 100000, big.NewInt(0), nil), signer, accounts[0].key)
+		}
+		b.AddTx(tx)
+	})}
+	api := NewAPI(backend)
+
+	number := func(n int64) *rpc.BlockNumber {
+		num := rpc.BlockNumber(n)
+		return &num
+	}
+	uint64p := func(n uint64) *uint64 {
+		return &n
+	}
+	// The states of the whole range are regenerated at once, not block by block
+	if _, err := api.TraceFilter(context.Background(), TraceFilterArgs{FromBlock: number(1), ToBlock: number(10)}); err != nil {
+		t.Fatalf("failed to filter traces: %v", err)
+	}
+	if backend.ranges != 1 || backend.states != 0 {
+		t.Errorf("state regenerations mismatch: have %d ranges and %d states, want 1 range", backend.ranges, backend.states)
+	}
+	var tests = []struct {
+		args   TraceFilterArgs
+		blocks []uint64 // Block numbers of the expected traces
+		err    bool
+	}{
+		{
+			args:   TraceFilterArgs{FromBlock: number(1), ToBlock: number(10), ToAddress: []common.Address{accounts[2].addr}},
+			blocks: []uint64{2, 4, 6, 8, 10},
+		},
+		{
+			args:   TraceFilterArgs{FromBlock: number(0), FromAddress: []common.Address{accounts[0].addr}, ToAddress: []common.Address{accounts[1].addr}},
+			blocks: []uint64{1, 3, 5, 7, 9},
+		},
+		{
+			args:   TraceFilterArgs{FromBlock: number(1), FromAddress: []common.Address{accounts[0].addr}},
+			blocks: []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			args:   TraceFilterArgs{FromBlock: number(1), ToAddress: []common.Address{accounts[2].addr}, After: uint64p(1), Count: uint64p(2)},
+			blocks: []uint64{4, 6},
+		},
+		{
+			args:   TraceFilterArgs{FromBlock: number(2), ToBlock: number(3)},
+			blocks: []uint64{2, 2, 3},
+		},
+		{
+			args:   TraceFilterArgs{},
+			blocks: []uint64{10, 10},
+		},
+		{
+			args: TraceFilterArgs{FromBlock: number(5), ToBlock: number(4)},
+			err:  true,
+		},
+		{
+			args: TraceFilterArgs{FromBlock: number(1), ToBlock: number(11)},
+			err:  true,
+		},
+	}
+	for i, test := range tests {
+		traces, err := api.TraceFilter(context.Background(), test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to filter traces: %v", i, err)
+			continue
+		}
+		var blocks []uint64
+		for _, trace := range traces {
+			blocks = append(blocks, uint64(trace.BlockNumber))
+		}
+		if !reflect.DeepEqual(blocks, test.blocks) {
+			t.Errorf("test %d: trace blocks mismatch: have %v, want %v", i, blocks, test.blocks)
+		}
+	}
+	// Check the shape of a forwarded call
+	traces, err := api.TraceFilter(context.Background(), TraceFilterArgs{FromBlock: number(2), ToBlock: number(2)})
+	if err != nil {
+		t.Fatalf("failed to filter traces: %v", err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(traces), 2)
+	}
+	if outer := traces[0]; outer.Type != "CALL" || outer.To != contract || outer.Subtraces != 1 || len(outer.TraceAddress) != 0 {
+		t.Errorf("outer call mismatch: %+v", outer)
+	}
+	if inner := traces[1]; inner.Type != "CALL" || inner.From != contract || inner.To != accounts[2].addr || !reflect.DeepEqual(inner.TraceAddress, []int{0}) {
+		t.Errorf("inner call mismatch: %+v", inner)
+	}
+}
+
 type Account struct {
 	key  *ecdsa.PrivateKey
 	addr common.Address
@@ -0,0 +1,272 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/rpc"
+)
+
+const (
+	// maxTraceFilterRange is the maximum number of blocks a single trace filter
+	// query may span, as every block in the range may need to be re-executed.
+	maxTraceFilterRange = 1000
+
+	// traceFilterCacheLimit is the number of blocks whose call traces are kept
+	// around for subsequent trace filter queries.
+	traceFilterCacheLimit = 256
+
+	// callTracerName is the JavaScript tracer the call traces are assembled from.
+	callTracerName = "callTracer"
+)
+
+// TraceFilterArgs is the criteria of a trace filter query. Empty address lists
+// match any address, non-empty ones have to match both sides of a call.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Maximum number of traces to return
+	Reexec      *uint64          `json:"reexec"`
+}
+
+// CallTrace is a single call frame of a transaction, flattened out of the call
+// tree together with its position within it.
+type CallTrace struct {
+	Type                string         `json:"type"` // Opcode which created the frame (CALL, CREATE, ...)
+	From                common.Address `json:"from"`
+	To                  common.Address `json:"to"`
+	Value               *hexutil.Big   `json:"value,omitempty"`
+	Gas                 hexutil.Uint64 `json:"gas"`
+	GasUsed             hexutil.Uint64 `json:"gasUsed"`
+	Input               hexutil.Bytes  `json:"input"`
+	Output              hexutil.Bytes  `json:"output,omitempty"`
+	Error               string         `json:"error,omitempty"`
+	Subtraces           int            `json:"subtraces"`    // Number of direct sub-calls
+	TraceAddress        []int          `json:"traceAddress"` // Path of sub-call indices from the outermost frame
+	BlockNumber         hexutil.Uint64 `json:"blockNumber"`
+	BlockHash           common.Hash    `json:"blockHash"`
+	TransactionHash     common.Hash    `json:"transactionHash"`
+	TransactionPosition hexutil.Uint   `json:"transactionPosition"`
+}
+
+// callFrame is a call frame as reported by the JavaScript call tracer.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big   `json:"value"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []callFrame    `json:"calls"`
+}
+
+// TraceFilter returns the call traces of all the transactions within a block
+// range whose caller and callee match the given address filters. Blocks whose
+// traces aren't cached yet are traced sequentially, regenerating the states of
+// the range once if they're not available, and their traces cached.
+func (api *API) TraceFilter(ctx context.Context, args TraceFilterArgs) ([]*CallTrace, error) {
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	first, err := api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	last, err := api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if first.NumberU64() > last.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", last.NumberU64(), first.NumberU64())
+	}
+	if last.NumberU64()-first.NumberU64() >= maxTraceFilterRange {
+		return nil, fmt.Errorf("block range exceeds limit of %d blocks", maxTraceFilterRange)
+	}
+	reexec := defaultTraceReexec
+	if args.Reexec != nil {
+		reexec = *args.Reexec
+	}
+	var (
+		skip    uint64
+		results = []*CallTrace{}
+
+		states  []*state.StateDB // Pre-states of the blocks from base onwards, regenerated on first use
+		base    uint64
+		release = func() {}
+	)
+	defer func() { release() }()
+
+	if args.After != nil {
+		skip = *args.After
+	}
+	for number := first.NumberU64(); number <= last.NumberU64(); number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block := first
+		if number > first.NumberU64() {
+			if block, err = api.blockByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+				return nil, err
+			}
+		}
+		traces, ok := api.cachedCallTraces(block)
+		if !ok {
+			// Regenerate the states of the rest of the range in one go, so that
+			// every block doesn't need to reexecute its own history
+			if states == nil {
+				parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(number-1), block.ParentHash())
+				if err != nil {
+					return nil, err
+				}
+				end, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(last.NumberU64()-1), last.ParentHash())
+				if err != nil {
+					return nil, err
+				}
+				if states, release, err = api.backend.StatesInRange(ctx, parent, end, reexec); err != nil {
+					return nil, err
+				}
+				base = number
+			}
+			if traces, err = api.blockCallTraces(ctx, block, states[number-base]); err != nil {
+				return nil, err
+			}
+		}
+		for _, trace := range traces {
+			if !matchAddress(args.FromAddress, trace.From) || !matchAddress(args.ToAddress, trace.To) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			results = append(results, trace)
+			if args.Count != nil && uint64(len(results)) >= *args.Count {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
+
+// cachedCallTraces returns the flattened call traces of all the transactions in
+// a block if they don't need tracing, either being cached or missing entirely.
+func (api *API) cachedCallTraces(block *types.Block) ([]*CallTrace, bool) {
+	if len(block.Transactions()) == 0 {
+		return nil, true
+	}
+	if traces, ok := api.traceCache.Get(block.Hash()); ok {
+		return traces.([]*CallTrace), true
+	}
+	return nil, false
+}
+
+// blockCallTraces traces all the transactions in a block on top of the given
+// pre-state, caching their flattened call traces. The state is modified.
+func (api *API) blockCallTraces(ctx context.Context, block *types.Block, statedb *state.StateDB) ([]*CallTrace, error) {
+	var (
+		tracer   = callTracerName
+		config   = &TraceConfig{Tracer: &tracer}
+		signer   = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		blockCtx = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+		traces   []*CallTrace
+	)
+	for i, tx := range block.Transactions() {
+		msg, _ := tx.AsMessage(signer)
+		txctx := &txTraceContext{
+			index: i,
+			hash:  tx.Hash(),
+			block: block.Hash(),
+		}
+		res, err := api.traceTx(ctx, msg, txctx, blockCtx, statedb, config)
+		if err != nil {
+			return nil, fmt.Errorf("tracing transaction %#x failed: %v", tx.Hash(), err)
+		}
+		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+		statedb.Finalise(api.backend.ChainConfig().IsEIP158(block.Number()))
+
+		blob, ok := res.(json.RawMessage)
+		if !ok {
+			return nil, fmt.Errorf("unexpected trace result type %T", res)
+		}
+		var frame callFrame
+		if err := json.Unmarshal(blob, &frame); err != nil {
+			return nil, fmt.Errorf("invalid trace of transaction %#x: %v", tx.Hash(), err)
+		}
+		proto := CallTrace{
+			BlockNumber:         hexutil.Uint64(block.NumberU64()),
+			BlockHash:           block.Hash(),
+			TransactionHash:     tx.Hash(),
+			TransactionPosition: hexutil.Uint(i),
+		}
+		traces = flattenCallFrame(traces, &frame, []int{}, &proto)
+	}
+	api.traceCache.Add(block.Hash(), traces)
+	return traces, nil
+}
+
+// flattenCallFrame appends the call frame and all its sub-calls to the traces in
+// depth first order, the proto trace supplying the transaction context.
+func flattenCallFrame(traces []*CallTrace, frame *callFrame, address []int, proto *CallTrace) []*CallTrace {
+	trace := *proto
+	trace.Type = frame.Type
+	trace.From, trace.To = frame.From, frame.To
+	trace.Value = frame.Value
+	trace.Gas, trace.GasUsed = frame.Gas, frame.GasUsed
+	trace.Input, trace.Output = frame.Input, frame.Output
+	trace.Error = frame.Error
+	trace.Subtraces = len(frame.Calls)
+	trace.TraceAddress = address
+
+	traces = append(traces, &trace)
+	for i := range frame.Calls {
+		sub := append(append(make([]int, 0, len(address)+1), address...), i)
+		traces = flattenCallFrame(traces, &frame.Calls[i], sub, proto)
+	}
+	return traces
+}
+
+// matchAddress reports whether the address is contained in the filter list, an
+// empty list matching any address.
+func matchAddress(filter []common.Address, addr common.Address) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, candidate := range filter {
+		if candidate == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common"
+)
+
+// txGossipLogSize is the number of transaction gossip events retained for
+// propagation diagnostics.
+const txGossipLogSize = 16384
+
+// Kinds of transaction gossip events.
+const (
+	txGossipAnnouncedBy  = "announcedBy"  // Peer announced the transaction hash to us
+	txGossipReceivedFrom = "receivedFrom" // Peer sent the transaction to us
+	txGossipSentTo       = "sentTo"       // Transaction was sent to the peer
+	txGossipAnnouncedTo  = "announcedTo"  // Transaction hash was announced to the peer
+)
+
+// TxGossipEvent is a single transaction exchange with a remote peer.
+type TxGossipEvent struct {
+	Time time.Time `json:"time"`
+	Peer string    `json:"peer"`
+	Kind string    `json:"kind"`
+}
+
+// TxPropagation is the gossip path of a transaction through the local node, as
+// far as it's retained by the gossip log.
+type TxPropagation struct {
+	Hash      common.Hash     `json:"hash"`
+	FirstSeen time.Time       `json:"firstSeen"`
+	Source    string          `json:"source"` // Peer the transaction was first heard from, or "local"
+	Events    []TxGossipEvent `json:"events"`
+}
+
+// txGossipEvent is a gossip log entry.
+type txGossipEvent struct {
+	hash common.Hash
+	TxGossipEvent
+}
+
+// txGossipLog is a ring buffer of the most recent transaction gossip events.
+type txGossipLog struct {
+	events []txGossipEvent
+	next   int // Index of the next event to overwrite
+	full   bool
+	lock   sync.Mutex
+}
+
+// newTxGossipLog creates a gossip log retaining the given number of events.
+func newTxGossipLog(size int) *txGossipLog {
+	return &txGossipLog{events: make([]txGossipEvent, size)}
+}
+
+// record adds an event for each of the given transactions, evicting the oldest
+// ones if the log is full.
+func (l *txGossipLog) record(peer string, kind string, hashes []common.Hash) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, hash := range hashes {
+		l.events[l.next] = txGossipEvent{
+			hash:          hash,
+			TxGossipEvent: TxGossipEvent{Time: now, Peer: peer, Kind: kind},
+		}
+		if l.next++; l.next == len(l.events) {
+			l.next, l.full = 0, true
+		}
+	}
+}
+
+// propagation assembles the gossip path of a transaction from the retained
+// events, or returns nil if none were retained.
+func (l *txGossipLog) propagation(hash common.Hash) *TxPropagation {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Iterate from the oldest event to the newest one
+	start, count := 0, l.next
+	if l.full {
+		start, count = l.next, len(l.events)
+	}
+	var result *TxPropagation
+	for i := 0; i < count; i++ {
+		event := l.events[(start+i)%len(l.events)]
+		if event.hash != hash {
+			continue
+		}
+		if result == nil {
+			// Outbound gossip without any inbound one means the transaction was
+			// submitted locally (unless the inbound events were evicted)
+			result = &TxPropagation{Hash: hash, FirstSeen: event.Time, Source: "local"}
+			if event.Kind == txGossipAnnouncedBy || event.Kind == txGossipReceivedFrom {
+				result.Source = event.Peer
+			}
+		}
+		result.Events = append(result.Events, event.TxGossipEvent)
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+
+	"github.com/acent/go-acent/common"
+)
+
+// Tests that the gossip log reports the source and path of transactions and
+// evicts the oldest events once full.
+func TestTxGossipLog(t *testing.T) {
+	var (
+		remote = common.HexToHash("0x01")
+		local  = common.HexToHash("0x02")
+	)
+	gossip := newTxGossipLog(4)
+	gossip.record("a", txGossipAnnouncedBy, []common.Hash{remote})
+	gossip.record("b", txGossipReceivedFrom, []common.Hash{remote})
+	gossip.record("c", txGossipSentTo, []common.Hash{remote, local})
+
+	prop := gossip.propagation(remote)
+	if prop == nil {
+		t.Fatalf("remote transaction missing")
+	}
+	if prop.Source != "a" || len(prop.Events) != 3 {
+		t.Fatalf("remote propagation mismatch: source %q, events %d", prop.Source, len(prop.Events))
+	}
+	for i, kind := range []string{txGossipAnnouncedBy, txGossipReceivedFrom, txGossipSentTo} {
+		if prop.Events[i].Kind != kind {
+			t.Errorf("event %d: kind mismatch: have %s, want %s", i, prop.Events[i].Kind, kind)
+		}
+	}
+	if prop = gossip.propagation(local); prop == nil || prop.Source != "local" {
+		t.Fatalf("local propagation mismatch: %+v", prop)
+	}
+	// Overflow the log, evicting the first two events of the remote transaction
+	gossip.record("d", txGossipAnnouncedTo, []common.Hash{local, local})
+
+	if prop = gossip.propagation(remote); prop == nil || len(prop.Events) != 1 || prop.Events[0].Peer != "c" {
+		t.Fatalf("remote propagation mismatch after eviction: %+v", prop)
+	}
+	if prop = gossip.propagation(local); prop == nil || len(prop.Events) != 3 || prop.Events[2].Peer != "d" {
+		t.Fatalf("local propagation mismatch after eviction: %+v", prop)
+	}
+	if prop = gossip.propagation(common.HexToHash("0x03")); prop != nil {
+		t.Fatalf("unknown transaction reported: %+v", prop)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/metrics"
+)
+
+// minWatchdogCheck is the minimum interval between two head progress checks.
+const minWatchdogCheck = time.Second
+
+var headStallMeter = metrics.NewRegisteredMeter("eth/watchdog/stalls", nil)
+
+// HeadStallEvent is posted when the chain head didn't progress for the configured
+// time despite peers advertising a heavier chain, after a portion of them were
+// rotated.
+type HeadStallEvent struct {
+	Head    *types.Header // Local chain head the node is stuck at
+	Stalled time.Duration // Time since the last head progress
+	Peers   int           // Number of peers connected when the stall was detected
+	Dropped []string      // Identifiers of the peers dropped to make room for new ones
+}
+
+// headProgress is the position of the local chain, any change of which counts
+// as progress (the full, fast or header chain advancing, or a reorg).
+type headProgress struct {
+	block  common.Hash
+	fast   common.Hash
+	header common.Hash
+}
+
+// headWatchdog monitors the progress of the local chain and rotates the least
+// useful peers if it stalls while peers advertise a heavier chain, triggering a
+// new sync cycle with fresh peers.
+type headWatchdog struct {
+	handler *handler
+	timeout time.Duration // Time without progress after which peers are rotated
+	rotate  int           // Percentage of peers to drop on a stall
+
+	last       headProgress // Position of the local chain at the last check
+	progressed time.Time    // Time the local chain last progressed
+}
+
+// newHeadWatchdog creates a head watchdog for the given handler.
+func newHeadWatchdog(handler *handler, timeout time.Duration, rotate int) *headWatchdog {
+	if rotate <= 0 || rotate > 100 {
+		log.Warn("Sanitizing invalid head watchdog rotation", "provided", rotate, "updated", 25)
+		rotate = 25
+	}
+	return &headWatchdog{
+		handler: handler,
+		timeout: timeout,
+		rotate:  rotate,
+	}
+}
+
+// loop runs in its own goroutine and checks the head progress periodically.
+func (w *headWatchdog) loop() {
+	defer w.handler.wg.Done()
+
+	interval := w.timeout / 4
+	if interval < minWatchdogCheck {
+		interval = minWatchdogCheck
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.last, w.progressed = w.progress(), time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			w.check(time.Now())
+
+		case <-w.handler.quitSync:
+			return
+		}
+	}
+}
+
+// check compares the position of the local chain with the one of the last check,
+// and rotates peers if it didn't progress within the timeout although some peers
+// advertise a heavier chain. If none does, the node is in sync with its peers
+// and the network itself isn't progressing, so rotating peers wouldn't help.
+func (w *headWatchdog) check(now time.Time) {
+	if current := w.progress(); current != w.last {
+		w.last, w.progressed = current, now
+		return
+	}
+	stalled := now.Sub(w.progressed)
+	if stalled < w.timeout {
+		return
+	}
+	head := w.handler.chain.CurrentHeader()
+	td := w.handler.chain.GetTd(head.Hash(), head.Number.Uint64())
+
+	drop := rotationPeers(w.handler.peers.all(), td, w.rotate)
+	if len(drop) == 0 {
+		return
+	}
+	w.progressed = now
+	w.rotatePeers(head, stalled, drop)
+}
+
+// progress returns the current position of the local chain.
+func (w *headWatchdog) progress() headProgress {
+	chain := w.handler.chain
+	return headProgress{
+		block:  chain.CurrentBlock().Hash(),
+		fast:   chain.CurrentFastBlock().Hash(),
+		header: chain.CurrentHeader().Hash(),
+	}
+}
+
+// rotationPeers returns the given percentage of the peers to drop on a stall of
+// the local chain with the given total difficulty, starting with the ones having
+// the lowest total difficulty. Nothing is dropped unless a peer advertises a
+// heavier chain than the local one, and the peer with the heaviest chain is never
+// dropped, as it's the one to sync from.
+func rotationPeers(peers []*ethPeer, td *big.Int, rotate int) []*ethPeer {
+	ahead := false
+	for _, peer := range peers {
+		if _, ptd := peer.Head(); ptd.Cmp(td) > 0 {
+			ahead = true
+			break
+		}
+	}
+	if !ahead {
+		return nil
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		_, tdi := peers[i].Head()
+		_, tdj := peers[j].Head()
+		return tdi.Cmp(tdj) < 0
+	})
+	drop := (len(peers)*rotate + 99) / 100
+	if drop >= len(peers) {
+		drop = len(peers) - 1
+	}
+	return peers[:drop]
+}
+
+// rotatePeers drops the given peers, forces a new sync cycle and notifies the
+// subscribers.
+func (w *headWatchdog) rotatePeers(head *types.Header, stalled time.Duration, drop []*ethPeer) {
+	peers := w.handler.peers.len()
+
+	dropped := make([]string, 0, len(drop))
+	for _, peer := range drop {
+		dropped = append(dropped, peer.ID())
+		w.handler.removePeer(peer.ID())
+	}
+	log.Warn("Chain head stalled, rotating peers", "number", head.Number, "hash", head.Hash(),
+		"stalled", common.PrettyDuration(stalled), "peers", peers, "dropped", len(dropped))
+
+	headStallMeter.Mark(1)
+	w.handler.chainSync.forceSync()
+	w.handler.stallFeed.Send(HeadStallEvent{
+		Head:    head,
+		Stalled: stalled,
+		Peers:   peers,
+		Dropped: dropped,
+	})
+}
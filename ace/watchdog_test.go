@@ -0,0 +1,143 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/eth/protocols/eth"
+	"github.com/acent/go-acent/p2p"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/params"
+)
+
+// newWatchdogPeer creates a peer advertising a chain of the given total difficulty.
+func newWatchdogPeer(id byte, td int64) *eth.Peer {
+	peer := eth.NewPeer(eth.ETH66, p2p.NewPeer(enode.ID{id}, "", nil), nil, nil)
+	peer.SetHead(common.Hash{id}, big.NewInt(td))
+	return peer
+}
+
+// Tests that peers are only rotated if one of them advertises a heavier chain,
+// dropping the ones with the lowest total difficulty first but never the best.
+func TestWatchdogRotationPeers(t *testing.T) {
+	tests := []struct {
+		peers  []int64 // Total difficulties of the peers
+		rotate int     // Percentage of peers to drop
+		drop   []int64 // Total difficulties of the peers dropped
+	}{
+		{peers: nil, rotate: 50, drop: nil},
+		{peers: []int64{5, 8, 10}, rotate: 100, drop: nil},
+		{peers: []int64{20, 5, 12, 8}, rotate: 50, drop: []int64{5, 8}},
+		{peers: []int64{20, 5, 12, 8}, rotate: 25, drop: []int64{5}},
+		{peers: []int64{20, 5, 12}, rotate: 50, drop: []int64{5, 12}},
+		{peers: []int64{20}, rotate: 25, drop: nil},
+		{peers: []int64{5, 20}, rotate: 100, drop: []int64{5}},
+	}
+	for i, tt := range tests {
+		var peers []*ethPeer
+		for j, td := range tt.peers {
+			peer := newWatchdogPeer(byte(j+1), td)
+			defer peer.Close()
+			peers = append(peers, &ethPeer{Peer: peer})
+		}
+		drop := rotationPeers(peers, big.NewInt(10), tt.rotate)
+		if len(drop) != len(tt.drop) {
+			t.Errorf("test %d: dropped peer count mismatch: have %d, want %d", i, len(drop), len(tt.drop))
+			continue
+		}
+		for j, peer := range drop {
+			if _, td := peer.Head(); td.Int64() != tt.drop[j] {
+				t.Errorf("test %d: dropped peer %d td mismatch: have %v, want %d", i, j, td, tt.drop[j])
+			}
+		}
+	}
+}
+
+// Tests that a stall of the chain head is detected after the timeout, rotating
+// peers if any advertises a heavier chain, and that progress resets the timer.
+func TestWatchdogStall(t *testing.T) {
+	handler := newTestHandlerWithBlocks(1)
+	defer handler.close()
+
+	events := make(chan HeadStallEvent, 1)
+	sub := handler.handler.stallFeed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	// The local chain has a total difficulty of the genesis plus one block
+	head := handler.chain.CurrentHeader()
+	td := handler.chain.GetTd(head.Hash(), head.Number.Uint64()).Int64()
+
+	for i, ptd := range []int64{td - 1, td} {
+		peer := newWatchdogPeer(byte(i+1), ptd)
+		defer peer.Close()
+		if err := handler.handler.peers.registerPeer(peer, nil); err != nil {
+			t.Fatalf("failed to register peer: %v", err)
+		}
+	}
+	var (
+		watchdog = newHeadWatchdog(handler.handler, time.Minute, 50)
+		start    = time.Now()
+	)
+	watchdog.last, watchdog.progressed = watchdog.progress(), start
+
+	// Stalling without peers ahead must not rotate them
+	watchdog.check(start.Add(2 * time.Minute))
+	if n := handler.handler.peers.len(); n != 2 {
+		t.Fatalf("peers rotated without any ahead: %d left", n)
+	}
+	// Stalling with a peer ahead must rotate the ones behind first
+	ahead := newWatchdogPeer(3, td+1)
+	defer ahead.Close()
+	if err := handler.handler.peers.registerPeer(ahead, nil); err != nil {
+		t.Fatalf("failed to register peer: %v", err)
+	}
+	watchdog.check(start.Add(30 * time.Second))
+	if n := handler.handler.peers.len(); n != 3 {
+		t.Fatalf("peers rotated before the timeout: %d left", n)
+	}
+	watchdog.check(start.Add(2 * time.Minute))
+	select {
+	case ev := <-events:
+		if ev.Peers != 3 || len(ev.Dropped) != 2 {
+			t.Errorf("stall event mismatch: %d peers, %d dropped", ev.Peers, len(ev.Dropped))
+		}
+	default:
+		t.Fatalf("no stall event posted")
+	}
+	if handler.handler.peers.len() != 1 || handler.handler.peers.peer(ahead.ID()) == nil {
+		t.Fatalf("peer ahead rotated instead of the ones behind")
+	}
+	// The timer restarts after a rotation, and on progress of the chain
+	watchdog.check(start.Add(150 * time.Second))
+	if handler.handler.peers.len() != 1 {
+		t.Fatalf("peers rotated again before the timeout")
+	}
+	blocks, _ := core.GenerateChain(params.TestChainConfig, handler.chain.CurrentBlock(), ethash.NewFaker(), handler.db, 1, nil)
+	if _, err := handler.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to extend chain: %v", err)
+	}
+	watchdog.check(start.Add(4 * time.Minute))
+	if !watchdog.progressed.Equal(start.Add(4 * time.Minute)) {
+		t.Fatalf("chain progress not detected")
+	}
+}
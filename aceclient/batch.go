@@ -0,0 +1,176 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/acent/go-acent"
+	"github.com/acent/go-acent/accounts/abi"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/rpc"
+)
+
+// batchCallSize is the maximum number of requests sent in a single batch, as
+// many node providers reject larger batches.
+const batchCallSize = 100
+
+// multicallABI is the interface of the aggregate method of the widely deployed
+// multicall contracts (Multicall, Multicall2 and Multicall3 all support it).
+const multicallABI = `[{"name":"aggregate","type":"function","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}]}],"outputs":[{"name":"blockNumber","type":"uint256"},{"name":"returnData","type":"bytes[]"}]}]`
+
+// multicall is the parsed multicall contract interface.
+var multicall = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(multicallABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Call is a single contract call aggregated into a multicall.
+type Call struct {
+	To   common.Address // Contract to call
+	Data []byte         // Input data of the call
+}
+
+// BalancesAt returns the wei balances of the given accounts, retrieved with
+// batched requests. The block number can be nil, in which case the balances
+// are taken from the latest known block.
+func (ec *Client) BalancesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([]*big.Int, error) {
+	results := make([]hexutil.Big, len(accounts))
+	reqs := make([]rpc.BatchElem, len(accounts))
+	for i, account := range accounts {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getBalance",
+			Args:   []interface{}{account, toBlockNumArg(blockNumber)},
+			Result: &results[i],
+		}
+	}
+	if err := ec.batchCall(ctx, reqs, accounts); err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(accounts))
+	for i := range results {
+		balances[i] = (*big.Int)(&results[i])
+	}
+	return balances, nil
+}
+
+// NoncesAt returns the account nonces of the given accounts, retrieved with
+// batched requests. The block number can be nil, in which case the nonces are
+// taken from the latest known block.
+func (ec *Client) NoncesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([]uint64, error) {
+	results := make([]hexutil.Uint64, len(accounts))
+	reqs := make([]rpc.BatchElem, len(accounts))
+	for i, account := range accounts {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getTransactionCount",
+			Args:   []interface{}{account, toBlockNumArg(blockNumber)},
+			Result: &results[i],
+		}
+	}
+	if err := ec.batchCall(ctx, reqs, accounts); err != nil {
+		return nil, err
+	}
+	nonces := make([]uint64, len(accounts))
+	for i := range results {
+		nonces[i] = uint64(results[i])
+	}
+	return nonces, nil
+}
+
+// CodesAt returns the contract codes of the given accounts, retrieved with
+// batched requests. The block number can be nil, in which case the codes are
+// taken from the latest known block.
+func (ec *Client) CodesAt(ctx context.Context, accounts []common.Address, blockNumber *big.Int) ([][]byte, error) {
+	results := make([]hexutil.Bytes, len(accounts))
+	reqs := make([]rpc.BatchElem, len(accounts))
+	for i, account := range accounts {
+		reqs[i] = rpc.BatchElem{
+			Method: "eth_getCode",
+			Args:   []interface{}{account, toBlockNumArg(blockNumber)},
+			Result: &results[i],
+		}
+	}
+	if err := ec.batchCall(ctx, reqs, accounts); err != nil {
+		return nil, err
+	}
+	codes := make([][]byte, len(accounts))
+	for i := range results {
+		codes[i] = results[i]
+	}
+	return codes, nil
+}
+
+// batchCall sends the requests in batches of limited size, failing on the first
+// request which failed, identified by its account.
+func (ec *Client) batchCall(ctx context.Context, reqs []rpc.BatchElem, accounts []common.Address) error {
+	for start := 0; start < len(reqs); start += batchCallSize {
+		end := start + batchCallSize
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		if err := ec.c.BatchCallContext(ctx, reqs[start:end]); err != nil {
+			return err
+		}
+		for i := start; i < end; i++ {
+			if reqs[i].Error != nil {
+				return fmt.Errorf("account %x: %w", accounts[i], apiError(reqs[i].Error))
+			}
+		}
+	}
+	return nil
+}
+
+// Multicall executes the given contract calls within a single eth_call through
+// the multicall contract deployed at the given address, returning the output of
+// each call. The calls either all succeed or the whole multicall fails.
+//
+// The block number can be nil, in which case the calls are executed on the
+// latest known block.
+func (ec *Client) Multicall(ctx context.Context, contract common.Address, calls []Call, blockNumber *big.Int) ([][]byte, error) {
+	type aggregateCall struct {
+		Target   common.Address
+		CallData []byte
+	}
+	args := make([]aggregateCall, len(calls))
+	for i, call := range calls {
+		args[i] = aggregateCall{Target: call.To, CallData: call.Data}
+	}
+	input, err := multicall.Pack("aggregate", args)
+	if err != nil {
+		return nil, err
+	}
+	output, err := ec.CallContract(ctx, acent.CallMsg{To: &contract, Data: input}, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	results, err := multicall.Unpack("aggregate", output)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multicall output: %v", err)
+	}
+	data, ok := results[1].([][]byte)
+	if !ok || len(data) != len(calls) {
+		return nil, fmt.Errorf("invalid multicall output: %d results for %d calls", len(data), len(calls))
+	}
+	return data, nil
+}
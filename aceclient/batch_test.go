@@ -0,0 +1,96 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/rpc"
+)
+
+// multicallService is an eth_call endpoint emulating a multicall contract, which
+// answers every aggregated call with its target address followed by its input.
+type multicallService struct {
+	contract common.Address
+}
+
+type multicallArgs struct {
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+func (s *multicallService) Call(args multicallArgs, block string) (hexutil.Bytes, error) {
+	if args.To != s.contract {
+		return nil, errors.New("not the multicall contract")
+	}
+	if !bytes.Equal(args.Data[:4], []byte{0x25, 0x2d, 0xba, 0x42}) {
+		return nil, errors.New("not an aggregate call")
+	}
+	inputs, err := multicall.Methods["aggregate"].Inputs.Unpack(args.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	calls := inputs[0].([]struct {
+		Target   common.Address `json:"target"`
+		CallData []byte         `json:"callData"`
+	})
+	results := make([][]byte, len(calls))
+	for i, call := range calls {
+		results[i] = append(call.Target.Bytes(), call.CallData...)
+	}
+	return multicall.Methods["aggregate"].Outputs.Pack(big.NewInt(1), results)
+}
+
+// Tests that multicalls are encoded for and decoded from the aggregate method.
+func TestMulticall(t *testing.T) {
+	contract := common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", &multicallService{contract: contract}); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	client := NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	calls := []Call{
+		{To: common.Address{0x01}, Data: []byte{0xaa, 0xbb}},
+		{To: common.Address{0x02}},
+		{To: common.Address{0x03}, Data: bytes.Repeat([]byte{0xcc}, 100)},
+	}
+	results, err := client.Multicall(context.Background(), contract, calls, nil)
+	if err != nil {
+		t.Fatalf("multicall failed: %v", err)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(calls))
+	}
+	for i, call := range calls {
+		if want := append(call.To.Bytes(), call.Data...); !bytes.Equal(results[i], want) {
+			t.Errorf("call %d: result mismatch: have %x, want %x", i, results[i], want)
+		}
+	}
+	if _, err := client.Multicall(context.Background(), common.Address{}, calls, nil); err == nil {
+		t.Errorf("multicall to wrong contract succeeded")
+	}
+}
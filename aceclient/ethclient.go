@@ -58,12 +58,35 @@ func (ec *Client) Close() {
 	ec.c.Close()
 }
 
+// callContext performs a JSON-RPC call, recreating the typed API errors.
+func (ec *Client) callContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return apiError(ec.c.CallContext(ctx, result, method, args...))
+}
+
+// apiError converts an error received from the RPC API into its typed form if
+// it's one of the typed API errors, making it checkable with errors.Is and As.
+// Other errors are returned as they are.
+func apiError(err error) error {
+	rerr, ok := err.(rpc.Error)
+	if !ok {
+		return err
+	}
+	var data interface{}
+	if derr, ok := err.(rpc.DataError); ok {
+		data = derr.ErrorData()
+	}
+	if typed := acent.ParseError(rerr.ErrorCode(), err.Error(), data); typed != nil {
+		return typed
+	}
+	return err
+}
+
 // Blockchain Access
 
 // ChainId retrieves the current chain ID for transaction replay protection.
 func (ec *Client) ChainID(ctx context.Context) (*big.Int, error) {
 	var result hexutil.Big
-	err := ec.c.CallContext(ctx, &result, "eth_chainId")
+	err := ec.callContext(ctx, &result, "eth_chainId")
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +113,7 @@ func (ec *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Bl
 // BlockNumber returns the most recent block number
 func (ec *Client) BlockNumber(ctx context.Context) (uint64, error) {
 	var result hexutil.Uint64
-	err := ec.c.CallContext(ctx, &result, "eth_blockNumber")
+	err := ec.callContext(ctx, &result, "eth_blockNumber")
 	return uint64(result), err
 }
 
@@ -102,11 +125,11 @@ type rpcBlock struct {
 
 func (ec *Client) getBlock(ctx context.Context, method string, args ...interface{}) (*types.Block, error) {
 	var raw json.RawMessage
-	err := ec.c.CallContext(ctx, &raw, method, args...)
+	err := ec.callContext(ctx, &raw, method, args...)
 	if err != nil {
 		return nil, err
 	} else if len(raw) == 0 {
-		return nil, acent.NotFound
+		return nil, acent.ErrBlockNotFound
 	}
 	// Decode header and transactions.
 	var head *types.Header
@@ -168,9 +191,9 @@ func (ec *Client) getBlock(ctx context.Context, method string, args ...interface
 // HeaderByHash returns the block header with the given hash.
 func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
 	var head *types.Header
-	err := ec.c.CallContext(ctx, &head, "eth_getBlockByHash", hash, false)
+	err := ec.callContext(ctx, &head, "eth_getBlockByHash", hash, false)
 	if err == nil && head == nil {
-		err = acent.NotFound
+		err = acent.ErrBlockNotFound
 	}
 	return head, err
 }
@@ -179,9 +202,9 @@ func (ec *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.He
 // nil, the latest known header is returned.
 func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
 	var head *types.Header
-	err := ec.c.CallContext(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(number), false)
+	err := ec.callContext(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(number), false)
 	if err == nil && head == nil {
-		err = acent.NotFound
+		err = acent.ErrBlockNotFound
 	}
 	return head, err
 }
@@ -207,7 +230,7 @@ func (tx *rpcTransaction) UnmarshalJSON(msg []byte) error {
 // TransactionByHash returns the transaction with the given hash.
 func (ec *Client) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
 	var json *rpcTransaction
-	err = ec.c.CallContext(ctx, &json, "eth_getTransactionByHash", hash)
+	err = ec.callContext(ctx, &json, "eth_getTransactionByHash", hash)
 	if err != nil {
 		return nil, false, err
 	} else if json == nil {
@@ -237,7 +260,7 @@ func (ec *Client) TransactionSender(ctx context.Context, tx *types.Transaction,
 		Hash common.Hash
 		From common.Address
 	}
-	if err = ec.c.CallContext(ctx, &meta, "eth_getTransactionByBlockHashAndIndex", block, hexutil.Uint64(index)); err != nil {
+	if err = ec.callContext(ctx, &meta, "eth_getTransactionByBlockHashAndIndex", block, hexutil.Uint64(index)); err != nil {
 		return common.Address{}, err
 	}
 	if meta.Hash == (common.Hash{}) || meta.Hash != tx.Hash() {
@@ -249,14 +272,14 @@ func (ec *Client) TransactionSender(ctx context.Context, tx *types.Transaction,
 // TransactionCount returns the total number of transactions in the given block.
 func (ec *Client) TransactionCount(ctx context.Context, blockHash common.Hash) (uint, error) {
 	var num hexutil.Uint
-	err := ec.c.CallContext(ctx, &num, "eth_getBlockTransactionCountByHash", blockHash)
+	err := ec.callContext(ctx, &num, "eth_getBlockTransactionCountByHash", blockHash)
 	return uint(num), err
 }
 
 // TransactionInBlock returns a single transaction at index in the given block.
 func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
 	var json *rpcTransaction
-	err := ec.c.CallContext(ctx, &json, "eth_getTransactionByBlockHashAndIndex", blockHash, hexutil.Uint64(index))
+	err := ec.callContext(ctx, &json, "eth_getTransactionByBlockHashAndIndex", blockHash, hexutil.Uint64(index))
 	if err != nil {
 		return nil, err
 	}
@@ -275,7 +298,7 @@ func (ec *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash,
 // Note that the receipt is not available for pending transactions.
 func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
 	var r *types.Receipt
-	err := ec.c.CallContext(ctx, &r, "eth_getTransactionReceipt", txHash)
+	err := ec.callContext(ctx, &r, "eth_getTransactionReceipt", txHash)
 	if err == nil {
 		if r == nil {
 			return nil, acent.NotFound
@@ -307,7 +330,7 @@ type rpcProgress struct {
 // no sync currently running, it returns nil.
 func (ec *Client) SyncProgress(ctx context.Context) (*acent.SyncProgress, error) {
 	var raw json.RawMessage
-	if err := ec.c.CallContext(ctx, &raw, "eth_syncing"); err != nil {
+	if err := ec.callContext(ctx, &raw, "eth_syncing"); err != nil {
 		return nil, err
 	}
 	// Handle the possible response types
@@ -340,7 +363,7 @@ func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 func (ec *Client) NetworkID(ctx context.Context) (*big.Int, error) {
 	version := new(big.Int)
 	var ver string
-	if err := ec.c.CallContext(ctx, &ver, "net_version"); err != nil {
+	if err := ec.callContext(ctx, &ver, "net_version"); err != nil {
 		return nil, err
 	}
 	if _, ok := version.SetString(ver, 10); !ok {
@@ -353,7 +376,7 @@ func (ec *Client) NetworkID(ctx context.Context) (*big.Int, error) {
 // The block number can be nil, in which case the balance is taken from the latest known block.
 func (ec *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
 	var result hexutil.Big
-	err := ec.c.CallContext(ctx, &result, "eth_getBalance", account, toBlockNumArg(blockNumber))
+	err := ec.callContext(ctx, &result, "eth_getBalance", account, toBlockNumArg(blockNumber))
 	return (*big.Int)(&result), err
 }
 
@@ -361,7 +384,7 @@ func (ec *Client) BalanceAt(ctx context.Context, account common.Address, blockNu
 // The block number can be nil, in which case the value is taken from the latest known block.
 func (ec *Client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
 	var result hexutil.Bytes
-	err := ec.c.CallContext(ctx, &result, "eth_getStorageAt", account, key, toBlockNumArg(blockNumber))
+	err := ec.callContext(ctx, &result, "eth_getStorageAt", account, key, toBlockNumArg(blockNumber))
 	return result, err
 }
 
@@ -369,7 +392,7 @@ func (ec *Client) StorageAt(ctx context.Context, account common.Address, key com
 // The block number can be nil, in which case the code is taken from the latest known block.
 func (ec *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
 	var result hexutil.Bytes
-	err := ec.c.CallContext(ctx, &result, "eth_getCode", account, toBlockNumArg(blockNumber))
+	err := ec.callContext(ctx, &result, "eth_getCode", account, toBlockNumArg(blockNumber))
 	return result, err
 }
 
@@ -377,7 +400,7 @@ func (ec *Client) CodeAt(ctx context.Context, account common.Address, blockNumbe
 // The block number can be nil, in which case the nonce is taken from the latest known block.
 func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
 	var result hexutil.Uint64
-	err := ec.c.CallContext(ctx, &result, "eth_getTransactionCount", account, toBlockNumArg(blockNumber))
+	err := ec.callContext(ctx, &result, "eth_getTransactionCount", account, toBlockNumArg(blockNumber))
 	return uint64(result), err
 }
 
@@ -390,10 +413,79 @@ func (ec *Client) FilterLogs(ctx context.Context, q acent.FilterQuery) ([]types.
 	if err != nil {
 		return nil, err
 	}
-	err = ec.c.CallContext(ctx, &result, "eth_getLogs", arg)
+	err = ec.callContext(ctx, &result, "eth_getLogs", arg)
 	return result, err
 }
 
+// FilterLogsPage executes a page of a paginated filter query, continuing from the
+// given cursor (nil for the first page) and returning at most limit logs, or the
+// server's page size if lower or limit is zero. The cursor of the next page is
+// returned, nil if the query is complete.
+func (ec *Client) FilterLogsPage(ctx context.Context, q acent.FilterQuery, cursor []byte, limit uint64) ([]types.Log, []byte, error) {
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	page := map[string]interface{}{}
+	if cursor != nil {
+		page["cursor"] = hexutil.Bytes(cursor)
+	}
+	if limit > 0 {
+		page["limit"] = hexutil.Uint64(limit)
+	}
+	var result struct {
+		Logs   []types.Log   `json:"logs"`
+		Cursor hexutil.Bytes `json:"cursor"`
+	}
+	if err := ec.callContext(ctx, &result, "eth_getLogs", arg, page); err != nil {
+		return nil, nil, err
+	}
+	return result.Logs, result.Cursor, nil
+}
+
+// BlockLogs are the logs of a block listed by hash in a filter query.
+type BlockLogs struct {
+	BlockHash   common.Hash
+	BlockNumber *big.Int // Number of the block, nil if unknown to the node
+	Canonical   bool     // Whether the block is part of the canonical chain
+	Logs        []types.Log
+	Error       string // Reason the logs are missing, if any
+}
+
+// FilterBlockLogs executes a filter query over the blocks listed by hash in the
+// BlockHashes of the query, returning the logs of each block in the same order.
+func (ec *Client) FilterBlockLogs(ctx context.Context, q acent.FilterQuery) ([]BlockLogs, error) {
+	if q.BlockHash != nil || q.FromBlock != nil || q.ToBlock != nil {
+		return nil, fmt.Errorf("cannot specify BlockHashes together with BlockHash or FromBlock/ToBlock")
+	}
+	arg := map[string]interface{}{
+		"blockHashes": q.BlockHashes,
+		"address":     q.Addresses,
+		"topics":      q.Topics,
+	}
+	var results []struct {
+		BlockHash   common.Hash  `json:"blockHash"`
+		BlockNumber *hexutil.Big `json:"blockNumber"`
+		Canonical   bool         `json:"canonical"`
+		Logs        []types.Log  `json:"logs"`
+		Error       string       `json:"error"`
+	}
+	if err := ec.callContext(ctx, &results, "eth_getLogs", arg); err != nil {
+		return nil, err
+	}
+	logs := make([]BlockLogs, len(results))
+	for i, result := range results {
+		logs[i] = BlockLogs{
+			BlockHash:   result.BlockHash,
+			BlockNumber: (*big.Int)(result.BlockNumber),
+			Canonical:   result.Canonical,
+			Logs:        result.Logs,
+			Error:       result.Error,
+		}
+	}
+	return logs, nil
+}
+
 // SubscribeFilterLogs subscribes to the results of a streaming filter query.
 func (ec *Client) SubscribeFilterLogs(ctx context.Context, q acent.FilterQuery, ch chan<- types.Log) (acent.Subscription, error) {
 	arg, err := toFilterArg(q)
@@ -404,6 +496,9 @@ func (ec *Client) SubscribeFilterLogs(ctx context.Context, q acent.FilterQuery,
 }
 
 func toFilterArg(q acent.FilterQuery) (interface{}, error) {
+	if len(q.BlockHashes) > 0 {
+		return nil, fmt.Errorf("block hash lists are only supported by FilterBlockLogs")
+	}
 	arg := map[string]interface{}{
 		"address": q.Addresses,
 		"topics":  q.Topics,
@@ -429,21 +524,21 @@ func toFilterArg(q acent.FilterQuery) (interface{}, error) {
 // PendingBalanceAt returns the wei balance of the given account in the pending state.
 func (ec *Client) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
 	var result hexutil.Big
-	err := ec.c.CallContext(ctx, &result, "eth_getBalance", account, "pending")
+	err := ec.callContext(ctx, &result, "eth_getBalance", account, "pending")
 	return (*big.Int)(&result), err
 }
 
 // PendingStorageAt returns the value of key in the contract storage of the given account in the pending state.
 func (ec *Client) PendingStorageAt(ctx context.Context, account common.Address, key common.Hash) ([]byte, error) {
 	var result hexutil.Bytes
-	err := ec.c.CallContext(ctx, &result, "eth_getStorageAt", account, key, "pending")
+	err := ec.callContext(ctx, &result, "eth_getStorageAt", account, key, "pending")
 	return result, err
 }
 
 // PendingCodeAt returns the contract code of the given account in the pending state.
 func (ec *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
 	var result hexutil.Bytes
-	err := ec.c.CallContext(ctx, &result, "eth_getCode", account, "pending")
+	err := ec.callContext(ctx, &result, "eth_getCode", account, "pending")
 	return result, err
 }
 
@@ -451,14 +546,14 @@ func (ec *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]
 // This is the nonce that should be used for the next transaction.
 func (ec *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
 	var result hexutil.Uint64
-	err := ec.c.CallContext(ctx, &result, "eth_getTransactionCount", account, "pending")
+	err := ec.callContext(ctx, &result, "eth_getTransactionCount", account, "pending")
 	return uint64(result), err
 }
 
 // PendingTransactionCount returns the total number of transactions in the pending state.
 func (ec *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
 	var num hexutil.Uint
-	err := ec.c.CallContext(ctx, &num, "eth_getBlockTransactionCountByNumber", "pending")
+	err := ec.callContext(ctx, &num, "eth_getBlockTransactionCountByNumber", "pending")
 	return uint(num), err
 }
 
@@ -474,7 +569,7 @@ func (ec *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
 // blocks might not be available.
 func (ec *Client) CallContract(ctx context.Context, msg acent.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	var hex hexutil.Bytes
-	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber))
+	err := ec.callContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -485,7 +580,7 @@ func (ec *Client) CallContract(ctx context.Context, msg acent.CallMsg, blockNumb
 // The state seen by the contract call is the pending state.
 func (ec *Client) PendingCallContract(ctx context.Context, msg acent.CallMsg) ([]byte, error) {
 	var hex hexutil.Bytes
-	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), "pending")
+	err := ec.callContext(ctx, &hex, "eth_call", toCallArg(msg), "pending")
 	if err != nil {
 		return nil, err
 	}
@@ -496,7 +591,7 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg acent.CallMsg) ([
 // execution of a transaction.
 func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
 	var hex hexutil.Big
-	if err := ec.c.CallContext(ctx, &hex, "eth_gasPrice"); err != nil {
+	if err := ec.callContext(ctx, &hex, "eth_gasPrice"); err != nil {
 		return nil, err
 	}
 	return (*big.Int)(&hex), nil
@@ -508,7 +603,7 @@ func (ec *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
 // but it should provide a basis for setting a reasonable default.
 func (ec *Client) EstimateGas(ctx context.Context, msg acent.CallMsg) (uint64, error) {
 	var hex hexutil.Uint64
-	err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg))
+	err := ec.callContext(ctx, &hex, "eth_estimateGas", toCallArg(msg))
 	if err != nil {
 		return 0, err
 	}
@@ -524,7 +619,79 @@ func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) er
 	if err != nil {
 		return err
 	}
-	return ec.c.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Encode(data))
+	return ec.callContext(ctx, nil, "eth_sendRawTransaction", hexutil.Encode(data))
+}
+
+// TransactionConditional is the set of conditions a transaction sent with
+// SendTransactionConditional is only accepted under. Unset bounds are ignored.
+type TransactionConditional struct {
+	BlockNumberMin *big.Int // Minimum number of the block including the transaction
+	BlockNumberMax *big.Int // Maximum number of the block including the transaction
+	TimestampMin   uint64   // Minimum timestamp of the block including the transaction
+	TimestampMax   uint64   // Maximum timestamp of the block including the transaction
+}
+
+// SendRawTransaction injects a transaction already signed and encoded in its
+// binary form, e.g. by an external signer, into the pending pool for execution.
+// It returns the hash of the transaction as computed by the node.
+func (ec *Client) SendRawTransaction(ctx context.Context, data []byte) (common.Hash, error) {
+	var hash common.Hash
+	err := ec.callContext(ctx, &hash, "eth_sendRawTransaction", hexutil.Encode(data))
+	return hash, err
+}
+
+// SendTransactionConditional injects a signed transaction into the pending pool
+// for execution like SendTransaction, but only if the block it would be included
+// in next satisfies the conditions. Otherwise acent.ErrConditionsNotMet is returned.
+//
+// The node drops the transaction from the pool once the chain progresses past the
+// maximum block number or timestamp. This requires the private relay API to be
+// enabled on the node.
+func (ec *Client) SendTransactionConditional(ctx context.Context, tx *types.Transaction, conditions TransactionConditional) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = ec.SendRawTransactionConditional(ctx, data, conditions)
+	return err
+}
+
+// SendRawTransactionConditional is like SendTransactionConditional, but for a
+// transaction already signed and encoded in its binary form. It returns the hash
+// of the transaction as computed by the node.
+func (ec *Client) SendRawTransactionConditional(ctx context.Context, data []byte, conditions TransactionConditional) (common.Hash, error) {
+	var hash common.Hash
+	err := ec.callContext(ctx, &hash, "relay_sendRawTransactionConditional", hexutil.Encode(data), toConditionalArg(conditions))
+	return hash, err
+}
+
+// SendPrivateTransaction injects a signed transaction into the pending pool for
+// the node's own miner, without the node ever propagating it to the network.
+//
+// This requires the private transaction API to be enabled on the node.
+func (ec *Client) SendPrivateTransaction(ctx context.Context, tx *types.Transaction) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ec.callContext(ctx, nil, "eth_sendPrivateTransaction", hexutil.Encode(data))
+}
+
+func toConditionalArg(conditions TransactionConditional) interface{} {
+	arg := map[string]interface{}{}
+	if conditions.BlockNumberMin != nil {
+		arg["blockNumberMin"] = (*hexutil.Big)(conditions.BlockNumberMin)
+	}
+	if conditions.BlockNumberMax != nil {
+		arg["blockNumberMax"] = (*hexutil.Big)(conditions.BlockNumberMax)
+	}
+	if conditions.TimestampMin != 0 {
+		arg["timestampMin"] = hexutil.Uint64(conditions.TimestampMin)
+	}
+	if conditions.TimestampMax != 0 {
+		arg["timestampMax"] = hexutil.Uint64(conditions.TimestampMax)
+	}
+	return arg
 }
 
 func toCallArg(msg acent.CallMsg) interface{} {
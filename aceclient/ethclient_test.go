@@ -28,6 +28,7 @@ import (
 
 	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/consensus/ethash"
 	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/rawdb"
@@ -256,6 +257,21 @@ func TestEthClient(t *testing.T) {
 		"TestGetBlock": {
 			func(t *testing.T) { testGetBlock(t, client) },
 		},
+		"TestGetBlockReceipts": {
+			func(t *testing.T) { testGetBlockReceipts(t, chain, client) },
+		},
+		"TestFilterLogsPage": {
+			func(t *testing.T) { testFilterLogsPage(t, client) },
+		},
+		"TestFilterBlockLogs": {
+			func(t *testing.T) { testFilterBlockLogs(t, chain, client) },
+		},
+		"TestBatchAt": {
+			func(t *testing.T) { testBatchAt(t, client) },
+		},
+		"TestTypedErrors": {
+			func(t *testing.T) { testTypedErrors(t, client) },
+		},
 		"TestStatusFunctions": {
 			func(t *testing.T) { testStatusFunctions(t, client) },
 		},
@@ -334,7 +350,7 @@ func testBalanceAt(t *testing.T, client *rpc.Client) {
 			account: testAddr,
 			block:   big.NewInt(1000000000),
 			want:    big.NewInt(0),
-			wantErr: errors.New("header not found"),
+			wantErr: acent.ErrBlockNotFound,
 		},
 	}
 	for name, tt := range tests {
@@ -433,6 +449,179 @@ func testGetBlock(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testGetBlockReceipts(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	// Receipts of existing blocks must be retrievable by number or hash
+	for _, arg := range []interface{}{"latest", "0x1", chain[1].Hash()} {
+		var receipts []map[string]interface{}
+		if err := client.Call(&receipts, "eth_getBlockReceipts", arg); err != nil {
+			t.Fatalf("%v: unexpected error: %v", arg, err)
+		}
+		if receipts == nil || len(receipts) != len(chain[1].Transactions()) {
+			t.Fatalf("%v: receipts mismatch: have %v, want %d", arg, receipts, len(chain[1].Transactions()))
+		}
+	}
+	// Receipts of unknown blocks must be null
+	var receipts []map[string]interface{}
+	if err := client.Call(&receipts, "eth_getBlockReceipts", "0x10"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receipts != nil {
+		t.Fatalf("receipts of unknown block: have %v, want nil", receipts)
+	}
+}
+
+func testFilterLogsPage(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	// The test chain has no logs, the first page must complete the query
+	logs, cursor, err := ec.FilterLogsPage(context.Background(), acent.FilterQuery{FromBlock: big.NewInt(0)}, nil, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(logs) != 0 || cursor != nil {
+		t.Fatalf("page mismatch: have %d logs, cursor %x", len(logs), cursor)
+	}
+	// Malformed cursors must be rejected
+	if _, _, err := ec.FilterLogsPage(context.Background(), acent.FilterQuery{}, []byte{0x01}, 0); err == nil {
+		t.Fatalf("malformed cursor accepted")
+	}
+}
+
+func testFilterBlockLogs(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+
+	query := acent.FilterQuery{BlockHashes: []common.Hash{chain[1].Hash(), {0xff}}}
+	results, err := ec.FilterBlockLogs(context.Background(), query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want 2", len(results))
+	}
+	if r := results[0]; r.BlockHash != chain[1].Hash() || r.BlockNumber.Cmp(chain[1].Number()) != 0 || !r.Canonical || r.Error != "" {
+		t.Errorf("canonical block result mismatch: %+v", r)
+	}
+	if r := results[1]; r.BlockNumber != nil || r.Canonical || r.Error == "" {
+		t.Errorf("unknown block result mismatch: %+v", r)
+	}
+	// Block hash lists are rejected by the other log queries
+	if _, err := ec.FilterLogs(context.Background(), query); err == nil {
+		t.Errorf("block hash list accepted by FilterLogs")
+	}
+}
+
+func testBatchAt(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	// Request more accounts than fit into a single batch
+	accounts := make([]common.Address, batchCallSize+10)
+	accounts[len(accounts)-1] = testAddr
+
+	balances, err := ec.BalancesAt(context.Background(), accounts, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nonces, err := ec.NoncesAt(context.Background(), accounts, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	codes, err := ec.CodesAt(context.Background(), accounts, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, account := range accounts {
+		balance, _ := ec.BalanceAt(context.Background(), account, nil)
+		if balances[i].Cmp(balance) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, balances[i], balance)
+		}
+		nonce, _ := ec.NonceAt(context.Background(), account, nil)
+		if nonces[i] != nonce {
+			t.Errorf("account %d: nonce mismatch: have %d, want %d", i, nonces[i], nonce)
+		}
+		if len(codes[i]) != 0 {
+			t.Errorf("account %d: unexpected code %x", i, codes[i])
+		}
+	}
+	if balances[len(accounts)-1].Cmp(testBalance) != 0 {
+		t.Errorf("test account balance mismatch: have %v, want %v", balances[len(accounts)-1], testBalance)
+	}
+	// Requests failing individually must fail the batch
+	if _, err := ec.BalancesAt(context.Background(), accounts, big.NewInt(1000)); err == nil {
+		t.Errorf("balances of missing block returned")
+	}
+}
+
+func testTypedErrors(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	_, err := ec.BalanceAt(context.Background(), testAddr, big.NewInt(1000))
+	if !errors.Is(err, acent.ErrBlockNotFound) {
+		t.Fatalf("error mismatch: have %v, want %v", err, acent.ErrBlockNotFound)
+	}
+	if errors.Is(err, acent.ErrStatePruned) {
+		t.Fatalf("missing block reported as pruned state")
+	}
+	var apiErr *acent.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != acent.ServerErrorCode {
+		t.Fatalf("error is not a typed API error: %v", err)
+	}
+	// The message and code of the error predating the typed ones are kept
+	if want := "header not found"; err.Error() != want {
+		t.Errorf("message mismatch: have %q, want %q", err.Error(), want)
+	}
+	// Blocks missing from a null result are typed errors too, still matching NotFound
+	for name, get := range map[string]func() error{
+		"HeaderByHash": func() error { _, err := ec.HeaderByHash(context.Background(), common.Hash{1}); return err },
+		"BlockByHash":  func() error { _, err := ec.BlockByHash(context.Background(), common.Hash{1}); return err },
+	} {
+		if err := get(); !errors.Is(err, acent.ErrBlockNotFound) || !errors.Is(err, acent.NotFound) {
+			t.Errorf("%s: error mismatch: have %v, want %v", name, err, acent.ErrBlockNotFound)
+		}
+	}
+	// Errors not known to be typed must be passed through as they are
+	_, err = ec.BalancesAt(context.Background(), []common.Address{testAddr}, big.NewInt(1000))
+	if !errors.Is(err, acent.ErrBlockNotFound) {
+		t.Fatalf("batch error mismatch: have %v, want %v", err, acent.ErrBlockNotFound)
+	}
+}
+
+// failingService is an eth API endpoint failing every request, with a typed error
+// wrapped in context or with a plain one.
+type failingService struct{}
+
+func (failingService) GetBalance(addr common.Address, block string) (*hexutil.Big, error) {
+	return nil, fmt.Errorf("block %s: %w", block, acent.ErrStatePruned)
+}
+
+func (failingService) GetCode(addr common.Address, block string) (hexutil.Bytes, error) {
+	return nil, errors.New("plain failure")
+}
+
+// Tests that typed errors wrapped by the server are recognized by the client,
+// and that other errors are not mistaken for them.
+func TestTypedErrorTransport(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", failingService{}); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	ec := NewClient(rpc.DialInProc(server))
+	defer ec.Close()
+
+	_, err := ec.BalanceAt(context.Background(), testAddr, nil)
+	if !errors.Is(err, acent.ErrStatePruned) || errors.Is(err, acent.ErrBlockNotFound) {
+		t.Fatalf("error mismatch: have %v, want %v", err, acent.ErrStatePruned)
+	}
+	if want := "block latest: state not available"; err.Error() != want {
+		t.Errorf("message mismatch: have %q, want %q", err.Error(), want)
+	}
+	_, err = ec.CodeAt(context.Background(), testAddr, nil)
+	var apiErr *acent.Error
+	if err == nil || errors.As(err, &apiErr) {
+		t.Fatalf("plain error mistaken for typed one: %v", err)
+	}
+}
+
 func testStatusFunctions(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 
@@ -573,3 +762,78 @@ func sendTransaction(ec *Client) error {
 	// Send transaction
 	return ec.SendTransaction(context.Background(), signedTx)
 }
+
+// Tests that conditional transactions are only accepted if the next block meets
+// their conditions.
+func TestSendTransactionConditional(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	client, _ := backend.Attach()
+	defer backend.Close()
+	defer client.Close()
+
+	ec := NewClient(client)
+	chainID, err := ec.ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve chain id: %v", err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{1}, big.NewInt(1), 22000, big.NewInt(1), nil), types.LatestSignerForChainID(chainID), testKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	now := uint64(time.Now().Unix())
+
+	// The head is block #1, so the transaction would be included in block #2
+	rejected := []TransactionConditional{
+		{BlockNumberMin: big.NewInt(3)},
+		{BlockNumberMax: big.NewInt(1)},
+		{TimestampMin: now + 3600},
+		{TimestampMax: now - 3600},
+	}
+	for i, conditions := range rejected {
+		if err := ec.SendTransactionConditional(context.Background(), tx, conditions); !errors.Is(err, acent.ErrConditionsNotMet) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, acent.ErrConditionsNotMet)
+		}
+	}
+	if _, pending, err := ec.TransactionByHash(context.Background(), tx.Hash()); err == nil {
+		t.Fatalf("rejected transaction added to the pool (pending %v)", pending)
+	}
+	conditions := TransactionConditional{
+		BlockNumberMin: big.NewInt(2),
+		BlockNumberMax: big.NewInt(2),
+		TimestampMin:   now - 3600,
+		TimestampMax:   now + 3600,
+	}
+	data, _ := tx.MarshalBinary()
+	if hash, err := ec.SendRawTransactionConditional(context.Background(), data, conditions); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	} else if hash != tx.Hash() {
+		t.Fatalf("transaction hash mismatch: have %x, want %x", hash, tx.Hash())
+	}
+	if _, pending, err := ec.TransactionByHash(context.Background(), tx.Hash()); err != nil || !pending {
+		t.Fatalf("accepted transaction not in the pool: pending %v, err %v", pending, err)
+	}
+}
+
+// Tests that private transactions are added to the pool of the node.
+func TestSendPrivateTransaction(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	client, _ := backend.Attach()
+	defer backend.Close()
+	defer client.Close()
+
+	ec := NewClient(client)
+	chainID, err := ec.ChainID(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve chain id: %v", err)
+	}
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{1}, big.NewInt(1), 22000, big.NewInt(1), nil), types.LatestSignerForChainID(chainID), testKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := ec.SendPrivateTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if _, pending, err := ec.TransactionByHash(context.Background(), tx.Hash()); err != nil || !pending {
+		t.Fatalf("private transaction not in the pool: pending %v, err %v", pending, err)
+	}
+}
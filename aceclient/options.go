@@ -0,0 +1,187 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/acent/go-acent/rpc"
+)
+
+// errHeadersUnsupported is returned if headers or a proxy are requested for a
+// transport which has no notion of them (IPC, stdio).
+var errHeadersUnsupported = errors.New("headers and proxies are only supported for HTTP and websocket endpoints")
+
+// errHTTPClientUnsupported is returned if a custom HTTP client is requested for
+// a transport not using one (websocket, IPC, stdio).
+var errHTTPClientUnsupported = errors.New("custom HTTP clients are only supported for HTTP endpoints")
+
+// Option configures the connection established by DialOptions.
+type Option func(*dialConfig)
+
+// dialConfig is the collection of settings the dial options operate on.
+type dialConfig struct {
+	headers    http.Header
+	headerFns  []rpc.HeaderFunc
+	timeout    time.Duration
+	proxy      func(*http.Request) (*url.URL, error)
+	httpClient *http.Client
+}
+
+// WithHeader adds a static header to every request sent to the endpoint, such
+// as an API key required by a managed node provider. Adding the same header
+// multiple times sends all of its values.
+func WithHeader(key, value string) Option {
+	return func(cfg *dialConfig) {
+		cfg.headers.Add(key, value)
+	}
+}
+
+// WithHeaderFunc adds a callback invoked before every request (or websocket
+// handshake) to set dynamic headers, such as periodically refreshed tokens.
+func WithHeaderFunc(fn rpc.HeaderFunc) Option {
+	return func(cfg *dialConfig) {
+		cfg.headerFns = append(cfg.headerFns, fn)
+	}
+}
+
+// WithBearerToken authenticates all requests with the given bearer token.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithBasicAuth authenticates all requests with the given username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(cfg *dialConfig) {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(username, password)
+		cfg.headers.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithCallTimeout sets the default deadline of calls issued with a context that
+// has no deadline of its own. Calls with an explicit deadline are unaffected.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(cfg *dialConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithProxy routes all traffic to the endpoint through the given proxy.
+func WithProxy(proxy *url.URL) Option {
+	return func(cfg *dialConfig) {
+		cfg.proxy = http.ProxyURL(proxy)
+	}
+}
+
+// WithEnvironmentProxy routes all traffic through the proxy configured by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func WithEnvironmentProxy() Option {
+	return func(cfg *dialConfig) {
+		cfg.proxy = http.ProxyFromEnvironment
+	}
+}
+
+// WithHTTPClient uses the given HTTP client for HTTP endpoints instead of the
+// default one. The proxy options have no effect on custom clients, configure
+// the client's transport instead. Dialing any other kind of endpoint with a
+// custom client fails.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *dialConfig) {
+		cfg.httpClient = client
+	}
+}
+
+// headerFunc merges the static headers and all the header callbacks into a
+// single callback, or returns nil if none were configured.
+func (cfg *dialConfig) headerFunc() rpc.HeaderFunc {
+	if len(cfg.headers) == 0 && len(cfg.headerFns) == 0 {
+		return nil
+	}
+	headers, fns := cfg.headers, cfg.headerFns
+	return func(ctx context.Context, header http.Header) error {
+		for key, values := range headers {
+			header[key] = append([]string(nil), values...)
+		}
+		for _, fn := range fns {
+			if err := fn(ctx, header); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// DialOptions connects a client to the given URL, configured by the given
+// options. Custom headers and proxies are supported for HTTP and websocket
+// endpoints only.
+//
+// The context is used to cancel or time out the initial connection establishment.
+// It does not affect subsequent interactions with the client.
+func DialOptions(ctx context.Context, rawurl string, opts ...Option) (*Client, error) {
+	cfg := &dialConfig{headers: make(http.Header)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	var c *rpc.Client
+	switch u.Scheme {
+	case "http", "https":
+		client := cfg.httpClient
+		if client == nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = cfg.proxy
+			client = &http.Client{Transport: transport}
+		}
+		if c, err = rpc.DialHTTPWithClient(rawurl, client); err != nil {
+			return nil, err
+		}
+		c.SetHeaderFunc(cfg.headerFunc())
+
+	case "ws", "wss":
+		if cfg.httpClient != nil {
+			return nil, fmt.Errorf("%w: %q", errHTTPClientUnsupported, rawurl)
+		}
+		dialer := rpc.DefaultWebsocketDialer()
+		dialer.Proxy = cfg.proxy
+
+		if c, err = rpc.DialWebsocketWithHeaders(ctx, rawurl, "", dialer, cfg.headerFunc()); err != nil {
+			return nil, err
+		}
+
+	default:
+		if len(cfg.headers) > 0 || len(cfg.headerFns) > 0 || cfg.proxy != nil {
+			return nil, fmt.Errorf("%w: %q", errHeadersUnsupported, rawurl)
+		}
+		if cfg.httpClient != nil {
+			return nil, fmt.Errorf("%w: %q", errHTTPClientUnsupported, rawurl)
+		}
+		if c, err = rpc.DialContext(ctx, rawurl); err != nil {
+			return nil, err
+		}
+	}
+	c.SetCallTimeout(cfg.timeout)
+	return NewClient(c), nil
+}
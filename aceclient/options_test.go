@@ -0,0 +1,139 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// chainIDHandler answers every JSON-RPC request with chain id 1, recording the
+// request headers and optionally delaying the response.
+func chainIDHandler(delay time.Duration, headers chan<- http.Header) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if headers != nil {
+			headers <- r.Header.Clone()
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	}
+}
+
+// Tests that static and dynamic headers are attached to every HTTP request.
+func TestDialOptionsHeaders(t *testing.T) {
+	headers := make(chan http.Header, 2)
+	server := httptest.NewServer(chainIDHandler(0, headers))
+	defer server.Close()
+
+	var counter uint32
+	client, err := DialOptions(context.Background(), server.URL,
+		WithHeader("X-Api-Key", "secret"),
+		WithHeader("X-Tag", "a"),
+		WithHeader("X-Tag", "b"),
+		WithBearerToken("token"),
+		WithHeaderFunc(func(ctx context.Context, header http.Header) error {
+			header.Set("X-Request", strconv.Itoa(int(atomic.AddUint32(&counter, 1))))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	for i := 1; i <= 2; i++ {
+		if _, err := client.ChainID(context.Background()); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		header := <-headers
+		if key := header.Get("X-Api-Key"); key != "secret" {
+			t.Errorf("call %d: api key mismatch: have %q, want %q", i, key, "secret")
+		}
+		if tags := header.Values("X-Tag"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+			t.Errorf("call %d: multi-valued header mismatch: have %q, want %q", i, tags, []string{"a", "b"})
+		}
+		if auth := header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("call %d: authorization mismatch: have %q, want %q", i, auth, "Bearer token")
+		}
+		if req := header.Get("X-Request"); req != strconv.Itoa(i) {
+			t.Errorf("call %d: dynamic header mismatch: have %q, want %q", i, req, strconv.Itoa(i))
+		}
+	}
+	// Failing header providers should abort the call
+	fail := errors.New("token expired")
+	client, err = DialOptions(context.Background(), server.URL, WithHeaderFunc(func(context.Context, http.Header) error {
+		return fail
+	}))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ChainID(context.Background()); !errors.Is(err, fail) {
+		t.Fatalf("error mismatch: have %v, want %v", err, fail)
+	}
+}
+
+// Tests that the default call timeout only applies to calls without a deadline.
+func TestDialOptionsCallTimeout(t *testing.T) {
+	server := httptest.NewServer(chainIDHandler(200*time.Millisecond, nil))
+	defer server.Close()
+
+	client, err := DialOptions(context.Background(), server.URL, WithCallTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ChainID(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.ChainID(ctx); err != nil {
+		t.Fatalf("call with explicit deadline failed: %v", err)
+	}
+}
+
+// Tests that headers and custom HTTP clients are rejected for transports which
+// don't support them.
+func TestDialOptionsUnsupported(t *testing.T) {
+	if _, err := DialOptions(context.Background(), "/tmp/nonexistent.ipc", WithBearerToken("token")); !errors.Is(err, errHeadersUnsupported) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errHeadersUnsupported)
+	}
+	for _, endpoint := range []string{"ws://127.0.0.1:1", "/tmp/nonexistent.ipc"} {
+		if _, err := DialOptions(context.Background(), endpoint, WithHTTPClient(new(http.Client))); !errors.Is(err, errHTTPClientUnsupported) {
+			t.Fatalf("%s: error mismatch: have %v, want %v", endpoint, err, errHTTPClientUnsupported)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/acent/go-acent"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/event"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// resubscribeBackoff is the maximum time to wait between two attempts at
+	// re-establishing a failed subscription.
+	resubscribeBackoff = 10 * time.Second
+
+	// maxResubscribeBackfill is the maximum number of blocks whose missed
+	// notifications are fetched after re-establishing a failed subscription. If
+	// more blocks were missed, only the most recent ones are backfilled.
+	maxResubscribeBackfill = 1024
+
+	// resubscribeDedupLimit is the number of recently delivered notifications
+	// remembered to filter out the ones delivered by multiple subscriptions.
+	resubscribeDedupLimit = 4096
+)
+
+// errResubscribeBlockHash is returned when attempting to keep a log subscription
+// of a single block alive.
+var errResubscribeBlockHash = errors.New("log subscriptions can't be restricted to a block hash")
+
+// logKey identifies a log notification for deduplication.
+type logKey struct {
+	block   common.Hash
+	index   uint
+	removed bool
+}
+
+// SubscribeNewHeadsWithResub subscribes to notifications about the current
+// blockchain head like SubscribeNewHead, but keeps re-establishing the
+// subscription whenever it fails, such as when a websocket connection drops.
+// After re-subscribing, the headers of the blocks missed in the meantime are
+// fetched and delivered first. Headers already delivered are never repeated.
+//
+// An error is returned only if the initial subscription can't be established.
+// The returned subscription ends when unsubscribed or the client is closed.
+func (ec *Client) SubscribeNewHeadsWithResub(ctx context.Context, ch chan<- *types.Header) (acent.Subscription, error) {
+	var (
+		seen, _ = lru.New(resubscribeDedupLimit)
+		last    *big.Int // Number of the last delivered header
+	)
+	// The subscriptions run one after the other, so they can share the state
+	// without locking
+	subscribe := func(ctx context.Context, backfill bool) (event.Subscription, error) {
+		headers := make(chan *types.Header)
+		sub, err := ec.SubscribeNewHead(ctx, headers)
+		if err != nil {
+			return nil, err
+		}
+		var missed []*types.Header
+		if backfill && last != nil {
+			if missed, err = ec.missedHeaders(ctx, last.Uint64()); err != nil {
+				sub.Unsubscribe()
+				return nil, err
+			}
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			deliver := func(header *types.Header) bool {
+				if seen.Contains(header.Hash()) {
+					return true
+				}
+				select {
+				case ch <- header:
+					seen.Add(header.Hash(), nil)
+					last = header.Number
+					return true
+				case <-quit:
+					return false
+				}
+			}
+			for _, header := range missed {
+				if !deliver(header) {
+					return nil
+				}
+			}
+			for {
+				select {
+				case header := <-headers:
+					if !deliver(header) {
+						return nil
+					}
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	}
+	return ec.resubscribe(ctx, subscribe)
+}
+
+// missedHeaders fetches the headers of the canonical blocks after the given one,
+// up to and including the current head.
+func (ec *Client) missedHeaders(ctx context.Context, last uint64) ([]*types.Header, error) {
+	head, err := ec.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	number, from := head.Number.Uint64(), last+1
+	if number > last+maxResubscribeBackfill {
+		from = number - maxResubscribeBackfill + 1
+	}
+	var headers []*types.Header
+	for n := from; n < number; n++ {
+		header, err := ec.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+	return append(headers, head), nil
+}
+
+// SubscribeLogsWithResub subscribes to the results of a streaming filter query
+// like SubscribeFilterLogs, but keeps re-establishing the subscription whenever
+// it fails, such as when a websocket connection drops. After re-subscribing, the
+// logs of the blocks missed in the meantime are queried and delivered first. Logs
+// already delivered are never repeated.
+//
+// The block range of the query is ignored, only logs of new blocks are delivered.
+// An error is returned only if the initial subscription can't be established.
+// The returned subscription ends when unsubscribed or the client is closed.
+func (ec *Client) SubscribeLogsWithResub(ctx context.Context, q acent.FilterQuery, ch chan<- types.Log) (acent.Subscription, error) {
+	if q.BlockHash != nil {
+		return nil, errResubscribeBlockHash
+	}
+	q.FromBlock, q.ToBlock = nil, nil
+
+	var (
+		seen, _ = lru.New(resubscribeDedupLimit)
+		next    uint64 // First block whose logs may not have been delivered
+	)
+	// The subscriptions run one after the other, so they can share the state
+	// without locking
+	subscribe := func(ctx context.Context, backfill bool) (event.Subscription, error) {
+		logs := make(chan types.Log)
+		sub, err := ec.SubscribeFilterLogs(ctx, q, logs)
+		if err != nil {
+			return nil, err
+		}
+		head, err := ec.BlockNumber(ctx)
+		if err != nil {
+			sub.Unsubscribe()
+			return nil, err
+		}
+		var missed []types.Log
+		if backfill && next <= head {
+			if head >= next+maxResubscribeBackfill {
+				next = head - maxResubscribeBackfill + 1
+			}
+			query := q
+			query.FromBlock, query.ToBlock = new(big.Int).SetUint64(next), new(big.Int).SetUint64(head)
+			if missed, err = ec.FilterLogs(ctx, query); err != nil {
+				sub.Unsubscribe()
+				return nil, err
+			}
+		}
+		if next <= head {
+			next = head + 1
+		}
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			defer sub.Unsubscribe()
+
+			deliver := func(log types.Log) bool {
+				key := logKey{block: log.BlockHash, index: log.Index, removed: log.Removed}
+				if seen.Contains(key) {
+					return true
+				}
+				select {
+				case ch <- log:
+					seen.Add(key, nil)
+					if log.BlockNumber > next {
+						next = log.BlockNumber // The block may have further logs
+					}
+					return true
+				case <-quit:
+					return false
+				}
+			}
+			for _, log := range missed {
+				if !deliver(log) {
+					return nil
+				}
+			}
+			for {
+				select {
+				case log := <-logs:
+					if !deliver(log) {
+						return nil
+					}
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			}
+		}), nil
+	}
+	return ec.resubscribe(ctx, subscribe)
+}
+
+// resubscribe establishes the initial subscription with the caller's context,
+// then keeps re-establishing it in the background whenever it fails, requesting
+// the missed notifications to be backfilled.
+func (ec *Client) resubscribe(ctx context.Context, subscribe func(context.Context, bool) (event.Subscription, error)) (acent.Subscription, error) {
+	initial, err := subscribe(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return event.ResubscribeErr(resubscribeBackoff, func(ctx context.Context, _ error) (event.Subscription, error) {
+		if initial != nil {
+			sub := initial
+			initial = nil
+			return sub, nil
+		}
+		return subscribe(ctx, true)
+	}), nil
+}
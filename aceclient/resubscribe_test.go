@@ -0,0 +1,194 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/eth"
+	"github.com/acent/go-acent/eth/ethconfig"
+	"github.com/acent/go-acent/node"
+	"github.com/acent/go-acent/params"
+)
+
+// dropListener is a network listener which can drop all its connections and
+// refuse new ones, simulating an unreachable server.
+type dropListener struct {
+	net.Listener
+
+	conns []net.Conn
+	down  bool
+	lock  sync.Mutex
+}
+
+func (l *dropListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		l.lock.Lock()
+		if l.down {
+			l.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		l.conns = append(l.conns, conn)
+		l.lock.Unlock()
+		return conn, nil
+	}
+}
+
+// setDown drops all connections and refuses new ones until brought up again.
+func (l *dropListener) setDown(down bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.down = down
+	if down {
+		for _, conn := range l.conns {
+			conn.Close()
+		}
+		l.conns = nil
+	}
+}
+
+// Tests that head and log subscriptions survive dropped websocket connections,
+// delivering the notifications missed in the meantime exactly once.
+func TestResubscribe(t *testing.T) {
+	var (
+		logger     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		loggerCode = []byte{byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.LOG0), byte(vm.STOP)}
+	)
+	genesis := &core.Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc: core.GenesisAlloc{
+			testAddr: {Balance: testBalance},
+			logger:   {Code: loggerCode, Balance: new(big.Int)},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	blocks, _ := core.GenerateChain(genesis.Config, genesis.MustCommit(db), ethash.NewFaker(), db, 8, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), logger, new(big.Int), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, testKey)
+		b.AddTx(tx)
+	})
+	// Start a node serving the chain over websocket
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	defer n.Close()
+
+	config := &ethconfig.Config{Genesis: genesis}
+	config.Ethash.PowMode = ethash.ModeFake
+	ethservice, err := eth.New(n, config)
+	if err != nil {
+		t.Fatalf("can't create new acent service: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	handler, err := n.RPCHandler()
+	if err != nil {
+		t.Fatalf("can't get rpc handler: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler.WebsocketHandler([]string{"*"}))
+	listener := &dropListener{Listener: server.Listener}
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := DialContext(context.Background(), "ws://"+strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("can't dial test node: %v", err)
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header, len(blocks))
+	headSub, err := client.SubscribeNewHeadsWithResub(context.Background(), heads)
+	if err != nil {
+		t.Fatalf("can't subscribe to heads: %v", err)
+	}
+	defer headSub.Unsubscribe()
+
+	logs := make(chan types.Log, len(blocks))
+	logSub, err := client.SubscribeLogsWithResub(context.Background(), acent.FilterQuery{Addresses: []common.Address{logger}}, logs)
+	if err != nil {
+		t.Fatalf("can't subscribe to logs: %v", err)
+	}
+	defer logSub.Unsubscribe()
+
+	// Import blocks while connected, while disconnected and after reconnecting
+	insert := func(from, to int) {
+		if _, err := ethservice.BlockChain().InsertChain(blocks[from:to]); err != nil {
+			t.Fatalf("can't import test blocks: %v", err)
+		}
+	}
+	check := func(from, to int) {
+		for i := from; i < to; i++ {
+			select {
+			case head := <-heads:
+				if head.Hash() != blocks[i].Hash() {
+					t.Fatalf("head mismatch: have #%d [%x…], want #%d [%x…]", head.Number, head.Hash().Bytes()[:4], blocks[i].Number(), blocks[i].Hash().Bytes()[:4])
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("head #%d not delivered", blocks[i].Number())
+			}
+			select {
+			case log := <-logs:
+				if log.BlockHash != blocks[i].Hash() {
+					t.Fatalf("log mismatch: have #%d [%x…], want #%d [%x…]", log.BlockNumber, log.BlockHash.Bytes()[:4], blocks[i].Number(), blocks[i].Hash().Bytes()[:4])
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("log of block #%d not delivered", blocks[i].Number())
+			}
+		}
+	}
+	insert(0, 2)
+	check(0, 2)
+
+	listener.setDown(true)
+	insert(2, 5)
+	listener.setDown(false)
+	check(2, 5)
+
+	insert(5, 8)
+	check(5, 8)
+
+	// Ensure nothing was delivered twice
+	select {
+	case head := <-heads:
+		t.Fatalf("unexpected head #%d", head.Number)
+	case log := <-logs:
+		t.Fatalf("unexpected log of block #%d", log.BlockNumber)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package leveldb
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+)
+
+// compactionRanges is the number of key ranges the keyspace is split into for
+// compaction, one range per leading key byte.
+const compactionRanges = 256
+
+// CompactionConfig are the settings of the background compaction scheduler.
+type CompactionConfig struct {
+	Recheck    time.Duration // Interval between two checks of the database activity
+	IdlePeriod time.Duration // Time without activity after which the database counts as idle
+	IdleRate   uint64        // Disk traffic in bytes per second still counting as idle
+	Interval   time.Duration // Minimum time between the starts of two full compaction passes
+}
+
+// DefaultCompactionConfig contains the default settings of the background
+// compaction scheduler.
+var DefaultCompactionConfig = CompactionConfig{
+	Recheck:    10 * time.Second,
+	IdlePeriod: 5 * time.Minute,
+	IdleRate:   1024 * 1024,
+	Interval:   24 * time.Hour,
+}
+
+// compactableStore is the subset of the database methods used by the scheduler.
+type compactableStore interface {
+	ethdb.Stater
+	ethdb.Compacter
+}
+
+// CompactionScheduler compacts a database in the background, one key range at a
+// time, whenever the node is idle. Activity is detected from the disk traffic of
+// the database itself, if it reports one, and from the activity reported by the
+// owner of the database through Touch, e.g. block imports and RPC calls.
+type CompactionScheduler struct {
+	db     compactableStore
+	config CompactionConfig
+
+	active int64 // Unix nanoseconds of the last activity, accessed atomically
+
+	lock   sync.Mutex // Serializes the compactions
+	next   int        // Next key range of the current pass to compact
+	passed time.Time  // Start time of the current or last pass
+
+	traffic float64   // Total disk traffic of the database in MB at the last check
+	checked time.Time // Time of the last activity check
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCompactionScheduler creates a compaction scheduler for the given database.
+// Background compaction only runs after the scheduler is started, manual ones
+// can be requested at any time.
+func NewCompactionScheduler(db compactableStore, config CompactionConfig) *CompactionScheduler {
+	s := &CompactionScheduler{
+		db:     db,
+		config: config,
+		quit:   make(chan struct{}),
+	}
+	s.Touch()
+	return s
+}
+
+// Start launches the background compaction.
+func (s *CompactionScheduler) Start() {
+	s.traffic, _ = s.diskTraffic()
+	s.checked = time.Now()
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop terminates the background compaction, waiting for a running range
+// compaction to finish.
+func (s *CompactionScheduler) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// Touch reports activity on the database, postponing background compaction
+// until the database is idle again.
+func (s *CompactionScheduler) Touch() {
+	atomic.StoreInt64(&s.active, time.Now().UnixNano())
+}
+
+// Compact compacts the whole database right away, range by range, and restarts
+// the passes of the background compaction.
+func (s *CompactionScheduler) Compact() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := 0; i < compactionRanges; i++ {
+		start, limit := compactionRange(i)
+		log.Info("Compacting database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", i, i+1))
+		if err := s.db.Compact(start, limit); err != nil {
+			log.Error("Database compaction failed", "err", err)
+			return err
+		}
+	}
+	s.next, s.passed = 0, time.Now()
+	return nil
+}
+
+// loop checks the activity of the database periodically, compacting the next
+// key range whenever it is idle.
+func (s *CompactionScheduler) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Recheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.idle() {
+				s.step()
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// idle samples the disk traffic of the database and reports whether it has been
+// idle long enough to compact, with a compaction pass due.
+func (s *CompactionScheduler) idle() bool {
+	now := time.Now()
+	if traffic, ok := s.diskTraffic(); ok {
+		if elapsed := now.Sub(s.checked).Seconds(); elapsed > 0 {
+			if rate := (traffic - s.traffic) * 1024 * 1024 / elapsed; rate > float64(s.config.IdleRate) {
+				s.Touch()
+			}
+		}
+		s.traffic = traffic
+	}
+	s.checked = now
+
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&s.active))) < s.config.IdlePeriod {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.next > 0 || s.passed.IsZero() || now.Sub(s.passed) >= s.config.Interval
+}
+
+// step compacts the next key range of the current pass.
+func (s *CompactionScheduler) step() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.next == 0 {
+		log.Info("Starting idle database compaction")
+		s.passed = time.Now()
+	}
+	start, limit := compactionRange(s.next)
+	if err := s.db.Compact(start, limit); err != nil {
+		log.Error("Idle database compaction failed", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", s.next, s.next+1), "err", err)
+		return
+	}
+	if s.next++; s.next == compactionRanges {
+		log.Info("Finished idle database compaction", "elapsed", common.PrettyDuration(time.Since(s.passed)))
+		s.next = 0
+	}
+	// Don't mistake the traffic of the compaction for activity
+	s.traffic, _ = s.diskTraffic()
+	s.checked = time.Now()
+}
+
+// diskTraffic returns the total amount of data in MB read from and written to
+// disk by the database, if it reports it.
+func (s *CompactionScheduler) diskTraffic() (float64, bool) {
+	stats, err := s.db.Stat("leveldb.iostats")
+	if err != nil {
+		return 0, false
+	}
+	var read, write float64
+	if n, err := fmt.Sscanf(stats, "Read(MB):%f Write(MB):%f", &read, &write); n != 2 || err != nil {
+		return 0, false
+	}
+	return read + write, true
+}
+
+// compactionRange returns the boundaries of the i-th key range, a nil boundary
+// extending to the beginning or the end of the keyspace.
+func compactionRange(i int) (start, limit []byte) {
+	if i > 0 {
+		start = []byte{byte(i)}
+	}
+	if i < compactionRanges-1 {
+		limit = []byte{byte(i + 1)}
+	}
+	return start, limit
+}
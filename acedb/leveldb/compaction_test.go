@@ -0,0 +1,139 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package leveldb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCompactStore is a mock database recording the compacted ranges and
+// reporting a configurable disk traffic.
+type testCompactStore struct {
+	lock    sync.Mutex
+	ranges  [][2][]byte
+	traffic float64 // Disk traffic in MB reported by the next stat
+	step    float64 // Disk traffic added by every stat
+}
+
+func (db *testCompactStore) Stat(property string) (string, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if property != "leveldb.iostats" {
+		return "", errors.New("unknown property")
+	}
+	db.traffic += db.step
+	return fmt.Sprintf("Read(MB):%.5f Write(MB):%.5f", db.traffic/2, db.traffic/2), nil
+}
+
+func (db *testCompactStore) Compact(start []byte, limit []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.ranges = append(db.ranges, [2][]byte{start, limit})
+	return nil
+}
+
+func (db *testCompactStore) compactions() int {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return len(db.ranges)
+}
+
+var testCompactionConfig = CompactionConfig{
+	Recheck:    time.Millisecond,
+	IdlePeriod: 20 * time.Millisecond,
+	IdleRate:   1024 * 1024,
+	Interval:   time.Hour,
+}
+
+// Tests that an idle database is compacted once per interval, covering the
+// whole keyspace.
+func TestCompactionSchedulerIdle(t *testing.T) {
+	db := new(testCompactStore)
+	s := NewCompactionScheduler(db, testCompactionConfig)
+	s.Start()
+	defer s.Stop()
+
+	for deadline := time.Now().Add(5 * time.Second); db.compactions() < compactionRanges; {
+		if time.Now().After(deadline) {
+			t.Fatalf("compaction pass not finished: %d ranges compacted", db.compactions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// The next pass is only due after the interval
+	time.Sleep(50 * time.Millisecond)
+	if n := db.compactions(); n != compactionRanges {
+		t.Fatalf("compacted ranges mismatch: have %d, want %d", n, compactionRanges)
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.ranges[0][0] != nil || db.ranges[compactionRanges-1][1] != nil {
+		t.Errorf("keyspace boundaries not covered")
+	}
+	for i := 1; i < compactionRanges; i++ {
+		if !bytes.Equal(db.ranges[i][0], db.ranges[i-1][1]) {
+			t.Errorf("range %d: start %x not following limit %x", i, db.ranges[i][0], db.ranges[i-1][1])
+		}
+	}
+}
+
+// Tests that a busy database is not compacted in the background, whether the
+// activity is reported or inferred from the disk traffic.
+func TestCompactionSchedulerBusy(t *testing.T) {
+	// Reported activity
+	db := new(testCompactStore)
+	s := NewCompactionScheduler(db, testCompactionConfig)
+	s.Start()
+	for i := 0; i < 20; i++ {
+		s.Touch()
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Stop()
+	if n := db.compactions(); n != 0 {
+		t.Fatalf("busy database compacted: %d ranges", n)
+	}
+	// Disk traffic of about 1GB/s
+	db = &testCompactStore{step: 1}
+	s = NewCompactionScheduler(db, testCompactionConfig)
+	s.Start()
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+	if n := db.compactions(); n != 0 {
+		t.Fatalf("busy database compacted: %d ranges", n)
+	}
+}
+
+// Tests that manual compactions cover the whole keyspace regardless of the
+// database activity.
+func TestCompactionSchedulerManual(t *testing.T) {
+	db := &testCompactStore{step: 1}
+	s := NewCompactionScheduler(db, testCompactionConfig)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	if n := db.compactions(); n != compactionRanges {
+		t.Fatalf("compacted ranges mismatch: have %d, want %d", n, compactionRanges)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build amd64 arm64
+
+package pebble
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/ethdb/dbtest"
+	"github.com/acent/go-acent/ethdb/leveldb"
+	"github.com/acent/go-acent/ethdb/memorydb"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// newMemoryPebble creates a pebble database backed by an in-memory file system.
+func newMemoryPebble(t *testing.T) *Database {
+	db, err := NewCustom("", "", func(options *pebble.Options) {
+		options.FS = vfs.NewMem()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPebbleDB(t *testing.T) {
+	t.Run("DatabaseSuite", func(t *testing.T) {
+		dbtest.TestDatabaseSuite(t, func() ethdb.KeyValueStore {
+			return newMemoryPebble(t)
+		})
+	})
+	t.Run("Compact", func(t *testing.T) {
+		db := newMemoryPebble(t)
+		defer db.Close()
+
+		// Compacting an empty database should be a noop
+		if err := db.Compact(nil, nil); err != nil {
+			t.Fatalf("failed to compact empty database: %v", err)
+		}
+		for i := byte(0); i < 100; i++ {
+			db.Put([]byte{0xff, i}, []byte{i})
+		}
+		for i := byte(0); i < 100; i += 2 {
+			db.Delete([]byte{0xff, i})
+		}
+		if err := db.Compact(nil, nil); err != nil {
+			t.Fatalf("failed to compact database: %v", err)
+		}
+		for i := byte(0); i < 100; i++ {
+			if has, _ := db.Has([]byte{0xff, i}); has != (i%2 == 1) {
+				t.Errorf("item %d: presence mismatch: have %v, want %v", i, has, i%2 == 1)
+			}
+		}
+	})
+}
+
+// Tests that iterating over a pebble database yields the exact same results as
+// the leveldb and in-memory databases, with random prefixes and start positions.
+func TestIteratorParity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pebble-parity-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ldb, err := leveldb.New(dir, 16, 16, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ldb.Close()
+
+	pdb := newMemoryPebble(t)
+	defer pdb.Close()
+
+	mdb := memorydb.New()
+	dbs := []ethdb.KeyValueStore{mdb, ldb, pdb}
+
+	// Fill the databases with short random keys, making prefix collisions and
+	// 0xff boundaries likely, and delete some of them in a batch
+	rng := rand.New(rand.NewSource(1))
+	randKey := func() []byte {
+		key := make([]byte, 1+rng.Intn(4))
+		for i := range key {
+			key[i] = []byte{0x00, 0x01, 0x7f, 0xfe, 0xff}[rng.Intn(5)]
+		}
+		return key
+	}
+	var keys [][]byte
+	for i := 0; i < 500; i++ {
+		key, val := randKey(), make([]byte, rng.Intn(16))
+		rng.Read(val)
+		for _, db := range dbs {
+			if err := db.Put(key, val); err != nil {
+				t.Fatalf("failed to insert item: %v", err)
+			}
+		}
+		keys = append(keys, key)
+	}
+	for _, db := range dbs {
+		batch := db.NewBatch()
+		for i := 0; i < len(keys); i += 7 {
+			batch.Delete(keys[i])
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("failed to write batch: %v", err)
+		}
+	}
+	// Cross check the iteration over random ranges
+	for i := 0; i < 1000; i++ {
+		var prefix, start []byte
+		if rng.Intn(4) != 0 {
+			prefix = randKey()
+			prefix = prefix[:rng.Intn(len(prefix)+1)]
+		}
+		if rng.Intn(2) != 0 {
+			start = randKey()
+		}
+		want := collect(mdb, prefix, start)
+		for j, db := range dbs[1:] {
+			have := collect(db, prefix, start)
+			if len(have) != len(want) {
+				t.Fatalf("db %d, prefix %x, start %x: item count mismatch: have %d, want %d", j+1, prefix, start, len(have), len(want))
+			}
+			for k := range have {
+				if !bytes.Equal(have[k], want[k]) {
+					t.Fatalf("db %d, prefix %x, start %x: item %d mismatch: have %x, want %x", j+1, prefix, start, k, have[k], want[k])
+				}
+			}
+		}
+	}
+}
+
+// collect iterates over a database range and returns the keys and values seen.
+func collect(db ethdb.KeyValueStore, prefix []byte, start []byte) [][]byte {
+	var items [][]byte
+
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	for it.Next() {
+		items = append(items, append([]byte{}, it.Key()...), append([]byte{}, it.Value()...))
+	}
+	return items
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotefreezer
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/log"
+)
+
+// diskCache is a size bounded local cache of segment objects, evicting the least
+// recently used ones. Objects are content addressed by their checksum, which is
+// verified on every retrieval to detect local corruption.
+type diskCache struct {
+	dir   string
+	limit uint64 // Maximum total size of the cached objects
+
+	used    uint64                        // Total size of the cached objects
+	entries map[common.Hash]*list.Element // Cached objects by checksum
+	order   *list.List                    // Cached objects, most recently used first
+	lock    sync.Mutex
+}
+
+// diskCacheEntry is a cached object.
+type diskCacheEntry struct {
+	hash common.Hash
+	size uint64
+}
+
+// newDiskCache opens a disk cache in the given directory, indexing the objects
+// cached by previous runs.
+func newDiskCache(dir string, limit uint64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Restore the recency order from the modification times, oldest first
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	cache := &diskCache{
+		dir:     dir,
+		limit:   limit,
+		entries: make(map[common.Hash]*list.Element),
+		order:   list.New(),
+	}
+	for _, file := range files {
+		hash := common.HexToHash(file.Name())
+		if file.IsDir() || file.Name() != hash.Hex()[2:] {
+			continue
+		}
+		cache.entries[hash] = cache.order.PushFront(&diskCacheEntry{hash: hash, size: uint64(file.Size())})
+		cache.used += uint64(file.Size())
+	}
+	cache.lock.Lock()
+	cache.evict()
+	cache.lock.Unlock()
+	return cache, nil
+}
+
+// path returns the file path of the cached object with the given checksum.
+func (c *diskCache) path(hash common.Hash) string {
+	return filepath.Join(c.dir, hash.Hex()[2:])
+}
+
+// get retrieves the object with the given checksum, or nil if it's not cached
+// or it got corrupted.
+func (c *diskCache) get(hash common.Hash) []byte {
+	c.lock.Lock()
+	elem, ok := c.entries[hash]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	c.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.path(hash))
+	if err == nil && sha256.Sum256(data) == hash {
+		return data
+	}
+	log.Warn("Dropping corrupted ancient cache entry", "hash", hash, "err", err)
+
+	c.lock.Lock()
+	c.remove(hash)
+	c.lock.Unlock()
+	return nil
+}
+
+// put caches an object, evicting the least recently used ones if the cache grows
+// over its size limit.
+func (c *diskCache) put(hash common.Hash, data []byte) {
+	if uint64(len(data)) > c.limit {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	if err := ioutil.WriteFile(c.path(hash), data, 0644); err != nil {
+		log.Warn("Failed to cache ancient segment", "hash", hash, "err", err)
+		os.Remove(c.path(hash))
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&diskCacheEntry{hash: hash, size: uint64(len(data))})
+	c.used += uint64(len(data))
+	c.evict()
+}
+
+// evict removes the least recently used objects until the cache fits into its
+// size limit. The lock must be held by the caller.
+func (c *diskCache) evict() {
+	for c.used > c.limit {
+		c.remove(c.order.Back().Value.(*diskCacheEntry).hash)
+	}
+}
+
+// remove drops an object from the cache. The lock must be held by the caller.
+func (c *diskCache) remove(hash common.Hash) {
+	elem, ok := c.entries[hash]
+	if !ok {
+		return
+	}
+	entry := c.order.Remove(elem).(*diskCacheEntry)
+	delete(c.entries, hash)
+	c.used -= entry.size
+
+	if err := os.Remove(c.path(hash)); err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to remove ancient cache entry", "hash", hash, "err", err)
+	}
+}
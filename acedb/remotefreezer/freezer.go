@@ -0,0 +1,499 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package remotefreezer implements an ancient chain data store on top of remote
+// object storage, caching the recently accessed chain segments locally.
+package remotefreezer
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/metrics"
+	"github.com/acent/go-acent/rlp"
+	"github.com/golang/snappy"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+var (
+	// errReadOnly is returned if the freezer is opened in read only mode. All the
+	// mutations are disallowed.
+	errReadOnly = errors.New("read only")
+
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	// errOutOfBounds is returned if the item requested is not contained within the
+	// freezer.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errOutOrderInsertion is returned if the user attempts to inject out-of-order
+	// binary blobs into the freezer.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+
+	// errChecksumMismatch is returned if a segment retrieved from the object store
+	// doesn't match the checksum recorded in the manifest.
+	errChecksumMismatch = errors.New("segment checksum mismatch")
+)
+
+// Names of the ancient tables, in the order of the AppendAncient parameters.
+// These must match the table names of the local freezer.
+var tables = []string{"hashes", "headers", "bodies", "receipts", "diffs"}
+
+const (
+	// DefaultSegmentItems is the number of blocks grouped into a single object.
+	DefaultSegmentItems = 2048
+
+	// DefaultCacheSize is the default size limit of the local segment cache.
+	DefaultCacheSize = 4 * 1024 * 1024 * 1024
+
+	// manifestKey is the object key of the freezer manifest.
+	manifestKey = "MANIFEST"
+
+	// segmentCacheItems is the number of decoded segments kept in memory.
+	segmentCacheItems = 32
+)
+
+// Config contains the settings of a remote freezer.
+type Config struct {
+	Store        ObjectStore // Object store to keep the ancient segments in
+	CacheDir     string      // Local directory to cache the segments in
+	CacheSize    uint64      // Maximum size of the local segment cache in bytes
+	SegmentItems uint64      // Number of blocks grouped into an object (only used for new stores)
+	Namespace    string      // Metrics namespace
+	ReadOnly     bool        // Whether to reject all mutations
+}
+
+// segment is the manifest entry of a segment object.
+type segment struct {
+	Items    uint64      `json:"items"`    // Number of items in the segment
+	Size     uint64      `json:"size"`     // Total size of the items, uncompressed
+	Checksum common.Hash `json:"checksum"` // SHA256 hash of the object
+}
+
+// manifest is the index of the stored segments. Segments only become part of
+// the freezer once a manifest referencing them is written, so the manifest is
+// the commit point of all mutations.
+type manifest struct {
+	Items        uint64               `json:"items"`        // Number of blocks stored
+	SegmentItems uint64               `json:"segmentItems"` // Number of blocks per full segment
+	Segments     map[string][]segment `json:"segments"`     // Segments of each table, the last one possibly partial
+}
+
+// Freezer is an ancient store keeping the chain segments as checksummed objects
+// in a remote object store. Blocks are grouped into segments of a fixed number
+// of items, the last, incomplete one of which is kept in memory and uploaded
+// when it fills up or the freezer is synced.
+type Freezer struct {
+	store    ObjectStore
+	readonly bool
+
+	manifest *manifest           // Manifest with the uncommitted changes applied
+	heads    map[string][][]byte // Items of the last, incomplete segment of each table
+	synced   map[string]string   // Object keys of the incomplete segments in the committed manifest
+	stale    []string            // Objects to delete once the next manifest is committed
+	lock     sync.RWMutex
+
+	cache    *diskCache // Local cache of the segment objects
+	segments *lru.Cache // Decoded segments recently read
+
+	readMeter  metrics.Meter // Meter for measuring the effective amount of data read
+	writeMeter metrics.Meter // Meter for measuring the effective amount of data written
+	fetchMeter metrics.Meter // Meter for measuring the segment data fetched remotely
+	missMeter  metrics.Meter // Meter for measuring the segment cache misses
+}
+
+// New opens a remote freezer, creating an empty one if the object store doesn't
+// contain any yet.
+func New(config Config) (*Freezer, error) {
+	if config.CacheSize == 0 {
+		config.CacheSize = DefaultCacheSize
+	}
+	if config.SegmentItems == 0 {
+		config.SegmentItems = DefaultSegmentItems
+	}
+	cache, err := newDiskCache(config.CacheDir, config.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	segments, _ := lru.New(segmentCacheItems)
+
+	f := &Freezer{
+		store:      config.Store,
+		readonly:   config.ReadOnly,
+		heads:      make(map[string][][]byte),
+		synced:     make(map[string]string),
+		cache:      cache,
+		segments:   segments,
+		readMeter:  metrics.NewRegisteredMeter(config.Namespace+"ancient/read", nil),
+		writeMeter: metrics.NewRegisteredMeter(config.Namespace+"ancient/write", nil),
+		fetchMeter: metrics.NewRegisteredMeter(config.Namespace+"ancient/remote/fetch", nil),
+		missMeter:  metrics.NewRegisteredMeter(config.Namespace+"ancient/remote/miss", nil),
+	}
+	// Load the manifest or start a new one if the store is empty
+	blob, err := f.store.Get(manifestKey)
+	switch {
+	case err == ErrNotFound:
+		f.manifest = &manifest{SegmentItems: config.SegmentItems, Segments: make(map[string][]segment)}
+	case err != nil:
+		return nil, err
+	default:
+		f.manifest = new(manifest)
+		if err := json.Unmarshal(blob, f.manifest); err != nil {
+			return nil, fmt.Errorf("invalid ancient manifest: %v", err)
+		}
+		if f.manifest.SegmentItems == 0 {
+			return nil, errors.New("invalid ancient manifest: zero segment size")
+		}
+		if f.manifest.Segments == nil {
+			f.manifest.Segments = make(map[string][]segment)
+		}
+	}
+	// Load the incomplete head segments to continue appending to them
+	for _, kind := range tables {
+		segs := f.manifest.Segments[kind]
+		if len(segs) == 0 || segs[len(segs)-1].Items == f.manifest.SegmentItems {
+			continue
+		}
+		items, err := f.segment(kind, uint64(len(segs)-1))
+		if err != nil {
+			return nil, err
+		}
+		f.manifest.Segments[kind] = segs[:len(segs)-1]
+		f.heads[kind] = items
+		f.synced[kind] = f.segmentKey(kind, uint64(len(segs)-1), segs[len(segs)-1].Items)
+	}
+	log.Info("Opened remote ancient database", "items", f.manifest.Items, "cache", config.CacheDir, "readonly", config.ReadOnly)
+	return f, nil
+}
+
+// segmentKey returns the object key of a segment. Incomplete segments are keyed
+// by their item count too, so growing them never overwrites an object that is
+// referenced by the committed manifest.
+func (f *Freezer) segmentKey(kind string, index uint64, items uint64) string {
+	if items == f.manifest.SegmentItems {
+		return fmt.Sprintf("%s/%08d", kind, index)
+	}
+	return fmt.Sprintf("%s/%08d.%d", kind, index, items)
+}
+
+// Close implements io.Closer. It doesn't persist any unsynced data, similarly
+// to the local freezer.
+func (f *Freezer) Close() error {
+	f.segments.Purge()
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.isTable(kind) && number < f.manifest.Items, nil
+}
+
+// Ancient retrieves an ancient binary blob from the freezer. The segment to
+// read is looked up under the lock, but retrieved and verified outside of it to
+// not stall the writers during a remote fetch.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	f.lock.RLock()
+	if !f.isTable(kind) {
+		f.lock.RUnlock()
+		return nil, errUnknownTable
+	}
+	if number >= f.manifest.Items {
+		f.lock.RUnlock()
+		return nil, errOutOfBounds
+	}
+	var (
+		index  = number / f.manifest.SegmentItems
+		offset = number % f.manifest.SegmentItems
+	)
+	// Serve the item from memory if it's in the incomplete head segment
+	if index == uint64(len(f.manifest.Segments[kind])) {
+		blob := common.CopyBytes(f.heads[kind][offset])
+		f.lock.RUnlock()
+
+		f.readMeter.Mark(int64(len(blob)))
+		return blob, nil
+	}
+	seg, key := f.segmentRef(kind, index)
+	f.lock.RUnlock()
+
+	items, err := f.fetchSegment(kind, index, seg, key)
+	if err != nil {
+		return nil, err
+	}
+	blob := common.CopyBytes(items[offset])
+	f.readMeter.Mark(int64(len(blob)))
+	return blob, nil
+}
+
+// Ancients returns the number of blocks stored in the freezer.
+func (f *Freezer) Ancients() (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.manifest.Items, nil
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *Freezer) AncientSize(kind string) (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if !f.isTable(kind) {
+		return 0, errUnknownTable
+	}
+	var size uint64
+	for _, seg := range f.manifest.Segments[kind] {
+		size += seg.Size
+	}
+	for _, item := range f.heads[kind] {
+		size += uint64(len(item))
+	}
+	return size, nil
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// freezer. Full segments are uploaded right away, but only become persistent
+// once the freezer is synced.
+func (f *Freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.manifest.Items != number {
+		return errOutOrderInsertion
+	}
+	for i, blob := range [][]byte{hash, header, body, receipts, td} {
+		kind := tables[i]
+		f.heads[kind] = append(f.heads[kind], common.CopyBytes(blob))
+		f.writeMeter.Mark(int64(len(blob)))
+	}
+	f.manifest.Items++
+
+	// Seal the head segments if they're full
+	if f.manifest.Items%f.manifest.SegmentItems == 0 {
+		sealed := make([]segment, len(tables))
+		for i, kind := range tables {
+			seg, err := f.upload(kind, uint64(len(f.manifest.Segments[kind])), f.heads[kind])
+			if err != nil {
+				// Roll back the block so the tables remain consistent
+				for _, kind := range tables {
+					f.heads[kind] = f.heads[kind][:len(f.heads[kind])-1]
+				}
+				f.manifest.Items--
+				return err
+			}
+			sealed[i] = seg
+		}
+		for i, kind := range tables {
+			f.manifest.Segments[kind] = append(f.manifest.Segments[kind], sealed[i])
+			f.heads[kind] = nil
+		}
+	}
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number
+// and commits the change right away.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.manifest.Items <= items {
+		return nil
+	}
+	var (
+		index  = items / f.manifest.SegmentItems
+		offset = items % f.manifest.SegmentItems
+	)
+	for _, kind := range tables {
+		segs := f.manifest.Segments[kind]
+		if index < uint64(len(segs)) {
+			// The new head is within a full segment, load it as the head
+			head, err := f.segment(kind, index)
+			if err != nil {
+				return err
+			}
+			for i := index; i < uint64(len(segs)); i++ {
+				f.stale = append(f.stale, f.segmentKey(kind, i, segs[i].Items))
+			}
+			f.manifest.Segments[kind] = segs[:index]
+			f.heads[kind] = head
+		}
+		f.heads[kind] = f.heads[kind][:offset]
+	}
+	f.manifest.Items = items
+	return f.sync()
+}
+
+// Sync uploads the incomplete head segments and commits all the changes by
+// writing a new manifest.
+func (f *Freezer) Sync() error {
+	if f.readonly {
+		return nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.sync()
+}
+
+// sync uploads the incomplete head segments and writes a new manifest. The lock
+// must be held by the caller.
+func (f *Freezer) sync() error {
+	// Assemble the manifest to commit, including the head segments
+	committed := &manifest{
+		Items:        f.manifest.Items,
+		SegmentItems: f.manifest.SegmentItems,
+		Segments:     make(map[string][]segment),
+	}
+	heads := make(map[string]string)
+	for _, kind := range tables {
+		segs := append([]segment{}, f.manifest.Segments[kind]...)
+		if head := f.heads[kind]; len(head) > 0 {
+			seg, err := f.upload(kind, uint64(len(segs)), head)
+			if err != nil {
+				return err
+			}
+			heads[kind] = f.segmentKey(kind, uint64(len(segs)), seg.Items)
+			segs = append(segs, seg)
+		}
+		committed.Segments[kind] = segs
+	}
+	blob, err := json.Marshal(committed)
+	if err != nil {
+		return err
+	}
+	if err := f.store.Put(manifestKey, blob); err != nil {
+		return err
+	}
+	// The previous incomplete segments and truncated ones aren't referenced any
+	// more, delete them
+	for kind, key := range f.synced {
+		if heads[kind] != key {
+			f.stale = append(f.stale, key)
+		}
+	}
+	f.synced = heads
+
+	for _, key := range f.stale {
+		if err := f.store.Delete(key); err != nil {
+			log.Warn("Failed to delete stale ancient segment", "key", key, "err", err)
+		}
+	}
+	f.stale = f.stale[:0]
+	return nil
+}
+
+// upload encodes and stores a segment, returning its manifest entry.
+func (f *Freezer) upload(kind string, index uint64, items [][]byte) (segment, error) {
+	enc, err := rlp.EncodeToBytes(items)
+	if err != nil {
+		return segment{}, err
+	}
+	var (
+		blob = snappy.Encode(nil, enc)
+		seg  = segment{Items: uint64(len(items)), Checksum: sha256.Sum256(blob)}
+	)
+	for _, item := range items {
+		seg.Size += uint64(len(item))
+	}
+	if err := f.store.Put(f.segmentKey(kind, index, seg.Items), blob); err != nil {
+		return segment{}, err
+	}
+	f.cache.put(seg.Checksum, blob)
+	return seg, nil
+}
+
+// segment retrieves the items of a full or committed incomplete segment, from
+// memory, the local cache or the object store, in this order. It must be called
+// with the lock held.
+func (f *Freezer) segment(kind string, index uint64) ([][]byte, error) {
+	seg, key := f.segmentRef(kind, index)
+	return f.fetchSegment(kind, index, seg, key)
+}
+
+// segmentRef returns the manifest entry and the object key of a segment. It
+// must be called with the lock held.
+func (f *Freezer) segmentRef(kind string, index uint64) (segment, string) {
+	seg := f.manifest.Segments[kind][index]
+	return seg, f.segmentKey(kind, index, seg.Items)
+}
+
+// fetchSegment retrieves the items of a segment stored under the given key,
+// verifying it against its manifest entry. It doesn't access the manifest, so
+// it may be called without holding the lock.
+func (f *Freezer) fetchSegment(kind string, index uint64, seg segment, key string) ([][]byte, error) {
+	if items, ok := f.segments.Get(seg.Checksum); ok {
+		return items.([][]byte), nil
+	}
+	blob := f.cache.get(seg.Checksum)
+	if blob == nil {
+		f.missMeter.Mark(1)
+
+		var err error
+		if blob, err = f.store.Get(key); err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s segment %d: %v", kind, index, err)
+		}
+		f.fetchMeter.Mark(int64(len(blob)))
+		if sha256.Sum256(blob) != seg.Checksum {
+			return nil, fmt.Errorf("%s segment %d: %w", kind, index, errChecksumMismatch)
+		}
+		f.cache.put(seg.Checksum, blob)
+	}
+	enc, err := snappy.Decode(nil, blob)
+	if err != nil {
+		return nil, err
+	}
+	var items [][]byte
+	if err := rlp.DecodeBytes(enc, &items); err != nil {
+		return nil, err
+	}
+	if uint64(len(items)) != seg.Items {
+		return nil, fmt.Errorf("%s segment %d: item count mismatch: have %d, want %d", kind, index, len(items), seg.Items)
+	}
+	f.segments.Add(seg.Checksum, items)
+	return items, nil
+}
+
+// isTable reports whether the given kind is a table of the freezer.
+func (f *Freezer) isTable(kind string) bool {
+	for _, table := range tables {
+		if table == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure the remote freezer implements the ancient store interface.
+var _ ethdb.AncientStore = (*Freezer)(nil)
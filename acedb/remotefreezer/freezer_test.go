@@ -0,0 +1,314 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotefreezer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common"
+)
+
+// memoryStore is an in-memory object store for testing.
+type memoryStore struct {
+	objects map[string][]byte
+	lock    sync.Mutex
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: make(map[string][]byte)}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if data, ok := s.objects[key]; ok {
+		return append([]byte{}, data...), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) Put(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.objects[key] = append([]byte{}, data...)
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.objects, key)
+	return nil
+}
+
+// blockingStore is an object store whose retrievals can be held up, simulating
+// slow remote fetches.
+type blockingStore struct {
+	*memoryStore
+	fetching chan struct{} // Signalled when a retrieval is held up, nil to not block
+	release  chan struct{} // Closed to let the held up retrievals proceed
+}
+
+func (s *blockingStore) Get(key string) ([]byte, error) {
+	if s.fetching != nil {
+		s.fetching <- struct{}{}
+		<-s.release
+	}
+	return s.memoryStore.Get(key)
+}
+
+// sha256Hash returns the checksum of a blob.
+func sha256Hash(blob []byte) common.Hash {
+	return sha256.Sum256(blob)
+}
+
+// testBlob returns the test data of a block for the given table.
+func testBlob(kind string, number uint64) []byte {
+	return []byte(fmt.Sprintf("%s-%d", kind, number))
+}
+
+// newTestFreezer opens a remote freezer on the given store with a temporary
+// cache directory.
+func newTestFreezer(t *testing.T, store ObjectStore, cacheSize uint64) (*Freezer, func()) {
+	dir, err := ioutil.TempDir("", "remotefreezer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(Config{Store: store, CacheDir: dir, CacheSize: cacheSize, SegmentItems: 4})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	return f, func() { f.Close(); os.RemoveAll(dir) }
+}
+
+// appendBlocks appends the test data of the given blocks to the freezer.
+func appendBlocks(t *testing.T, f *Freezer, from, to uint64) {
+	for i := from; i < to; i++ {
+		blobs := make([][]byte, len(tables))
+		for j, kind := range tables {
+			blobs[j] = testBlob(kind, i)
+		}
+		if err := f.AppendAncient(i, blobs[0], blobs[1], blobs[2], blobs[3], blobs[4]); err != nil {
+			t.Fatalf("failed to append block %d: %v", i, err)
+		}
+	}
+}
+
+// checkBlocks ensures the freezer contains exactly the given number of blocks.
+func checkBlocks(t *testing.T, f *Freezer, items uint64) {
+	if have, _ := f.Ancients(); have != items {
+		t.Fatalf("ancient count mismatch: have %d, want %d", have, items)
+	}
+	for i := uint64(0); i < items; i++ {
+		for _, kind := range tables {
+			blob, err := f.Ancient(kind, i)
+			if err != nil {
+				t.Fatalf("failed to retrieve %s %d: %v", kind, i, err)
+			}
+			if want := testBlob(kind, i); !bytes.Equal(blob, want) {
+				t.Fatalf("%s %d mismatch: have %s, want %s", kind, i, blob, want)
+			}
+		}
+	}
+	if _, err := f.Ancient("headers", items); err != errOutOfBounds {
+		t.Fatalf("out of bounds error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+}
+
+// Tests that appended blocks can be retrieved, and that only the synced ones
+// survive a reopen.
+func TestFreezerAppendReopen(t *testing.T) {
+	store := newMemoryStore()
+
+	f, cleanup := newTestFreezer(t, store, 1024*1024)
+	appendBlocks(t, f, 0, 10)
+	checkBlocks(t, f, 10)
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync freezer: %v", err)
+	}
+	appendBlocks(t, f, 10, 13)
+	cleanup()
+
+	// Reopen the freezer, the unsynced blocks should be gone
+	f, cleanup = newTestFreezer(t, store, 1024*1024)
+	defer cleanup()
+	checkBlocks(t, f, 10)
+
+	// Continue appending on top of the loaded incomplete segment
+	appendBlocks(t, f, 10, 15)
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync freezer: %v", err)
+	}
+	checkBlocks(t, f, 15)
+
+	// Ensure the superseded incomplete segments were deleted: 3 full and 1
+	// incomplete segment per table, plus the manifest
+	if have, want := len(store.objects), 4*len(tables)+1; have != want {
+		t.Errorf("object count mismatch: have %d, want %d", have, want)
+	}
+	if size, _ := f.AncientSize("headers"); size != 15*uint64(len("headers-x"))+5 {
+		t.Errorf("ancient size mismatch: have %d, want %d", size, 15*uint64(len("headers-x"))+5)
+	}
+}
+
+// Tests that truncation is committed right away and the freezer can be grown
+// again afterwards.
+func TestFreezerTruncate(t *testing.T) {
+	store := newMemoryStore()
+
+	f, cleanup := newTestFreezer(t, store, 1024*1024)
+	appendBlocks(t, f, 0, 14)
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync freezer: %v", err)
+	}
+	if err := f.TruncateAncients(6); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	checkBlocks(t, f, 6)
+	cleanup()
+
+	f, cleanup = newTestFreezer(t, store, 1024*1024)
+	defer cleanup()
+	checkBlocks(t, f, 6)
+
+	appendBlocks(t, f, 6, 9)
+	checkBlocks(t, f, 9)
+}
+
+// Tests that corrupted objects are detected by their checksums, while corrupted
+// cache entries are refetched.
+func TestFreezerIntegrity(t *testing.T) {
+	store := newMemoryStore()
+
+	f, cleanup := newTestFreezer(t, store, 1024*1024)
+	appendBlocks(t, f, 0, 8)
+	if err := f.Sync(); err != nil {
+		t.Fatalf("failed to sync freezer: %v", err)
+	}
+	cleanup()
+
+	// Reopen with an empty cache and corrupt a full segment in the store
+	store.objects["bodies/00000000"] = []byte("corrupted")
+
+	f, cleanup = newTestFreezer(t, store, 1024*1024)
+	defer cleanup()
+
+	if _, err := f.Ancient("bodies", 1); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("corruption error mismatch: have %v, want %v", err, errChecksumMismatch)
+	}
+	if _, err := f.Ancient("bodies", 5); err != nil {
+		t.Fatalf("failed to retrieve intact segment: %v", err)
+	}
+	// Corrupt the cached copy of the intact segment and ensure it's refetched
+	seg := f.manifest.Segments["headers"][1]
+	if blob, err := f.Ancient("headers", 5); err != nil || !bytes.Equal(blob, testBlob("headers", 5)) {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	f.segments.Purge()
+	if err := ioutil.WriteFile(f.cache.path(seg.Checksum), []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if blob, err := f.Ancient("headers", 5); err != nil || !bytes.Equal(blob, testBlob("headers", 5)) {
+		t.Fatalf("failed to refetch corrupted cache entry: %v", err)
+	}
+}
+
+// Tests that the disk cache stays within its size limit.
+func TestDiskCacheEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remotefreezer-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := newDiskCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blobs [][]byte
+	for i := 0; i < 5; i++ {
+		blob := bytes.Repeat([]byte{byte(i)}, 40)
+		blobs = append(blobs, blob)
+		cache.put(sha256Hash(blob), blob)
+	}
+	if cache.used > 100 {
+		t.Fatalf("cache over limit: %d > 100", cache.used)
+	}
+	for i, blob := range blobs {
+		cached := cache.get(sha256Hash(blob)) != nil
+		if want := i >= 3; cached != want {
+			t.Errorf("blob %d: cached mismatch: have %v, want %v", i, cached, want)
+		}
+	}
+	// Reopen the cache and ensure the entries are retained
+	if cache, err = newDiskCache(dir, 100); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 2 || cache.used != 80 {
+		t.Errorf("reopened cache mismatch: have %d entries of %d bytes, want 2 of 80", len(cache.entries), cache.used)
+	}
+}
+
+// Tests that retrieving a segment from the object store doesn't block writers.
+func TestFreezerFetchUnlocked(t *testing.T) {
+	store := &blockingStore{memoryStore: newMemoryStore()}
+	f, cleanup := newTestFreezer(t, store, 1) // Too small to cache any segment locally
+	defer cleanup()
+
+	appendBlocks(t, f, 0, 8)
+
+	store.fetching, store.release = make(chan struct{}), make(chan struct{})
+	fetched := make(chan error, 1)
+	go func() {
+		blob, err := f.Ancient("headers", 0)
+		if err == nil && !bytes.Equal(blob, testBlob("headers", 0)) {
+			err = fmt.Errorf("blob mismatch: have %s, want %s", blob, testBlob("headers", 0))
+		}
+		fetched <- err
+	}()
+	<-store.fetching
+
+	appended := make(chan error, 1)
+	go func() {
+		appended <- f.AppendAncient(8, nil, nil, nil, nil, nil)
+	}()
+	select {
+	case err := <-appended:
+		close(store.release)
+		if err != nil {
+			t.Fatalf("failed to append block: %v", err)
+		}
+	case <-time.After(time.Second):
+		close(store.release)
+		t.Fatal("append blocked by remote fetch")
+	}
+	if err := <-fetched; err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotefreezer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by an object store if the requested object doesn't exist.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore is a flat key-value store of immutable blobs, like the buckets of
+// S3-compatible object storage services.
+type ObjectStore interface {
+	// Get retrieves the object stored under the given key.
+	Get(key string) ([]byte, error)
+
+	// Put stores an object under the given key, replacing any previous one.
+	Put(key string, data []byte) error
+
+	// Delete removes the object stored under the given key, if any.
+	Delete(key string) error
+}
+
+// s3Timeout is the time allowance of a single object store request.
+const s3Timeout = time.Minute
+
+// S3Config contains the settings to access an S3-compatible bucket.
+type S3Config struct {
+	Endpoint  string // Base URL of the service, e.g. https://s3.us-east-1.amazonaws.com
+	Region    string // Region the bucket is located in, used for request signing
+	Bucket    string // Name of the bucket to store the objects in
+	Prefix    string // Key prefix of all the objects, allowing buckets to be shared
+	AccessKey string // Access key identifier of the credentials
+	SecretKey string // Secret key of the credentials
+}
+
+// ParseS3URL parses an object store URL of the form
+//
+//	s3://bucket/prefix?endpoint=https://host:port&region=us-east-1
+//
+// The credentials are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables. The endpoint defaults to the AWS service of the region,
+// which in turn defaults to us-east-1.
+func ParseS3URL(rawurl string) (*S3Config, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("unsupported object store scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("object store bucket missing")
+	}
+	config := &S3Config{
+		Endpoint:  u.Query().Get("endpoint"),
+		Region:    u.Query().Get("region"),
+		Bucket:    u.Host,
+		Prefix:    strings.Trim(u.Path, "/"),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	return config, nil
+}
+
+// IsURL reports whether the given ancient store location refers to a remote
+// object store instead of a local directory.
+func IsURL(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// S3Store is an object store backed by an S3-compatible bucket, accessed with
+// path style requests signed with AWS signature version 4.
+type S3Store struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time // Clock used for signing, replaceable in tests
+}
+
+// NewS3Store creates an object store on top of an S3-compatible bucket.
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid object store endpoint: %v", err)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("object store bucket missing")
+	}
+	return &S3Store{
+		config: config,
+		client: &http.Client{Timeout: s3Timeout},
+		now:    time.Now,
+	}, nil
+}
+
+// Get retrieves the object stored under the given key.
+func (s *S3Store) Get(key string) ([]byte, error) {
+	res, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, s3Error(res)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// Put stores an object under the given key, replacing any previous one.
+func (s *S3Store) Put(key string, data []byte) error {
+	res, err := s.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+// Delete removes the object stored under the given key, if any.
+func (s *S3Store) Delete(key string) error {
+	res, err := s.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error(res)
+	}
+	return nil
+}
+
+// do sends a signed request for the object with the given key.
+func (s *S3Store) do(method string, key string, body []byte) (*http.Response, error) {
+	if s.config.Prefix != "" {
+		key = s.config.Prefix + "/" + key
+	}
+	endpoint := strings.TrimRight(s.config.Endpoint, "/")
+	req, err := http.NewRequest(method, endpoint+"/"+s.config.Bucket+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	signS3Request(req, body, s.config.Region, s.config.AccessKey, s.config.SecretKey, s.now())
+
+	return s.client.Do(req)
+}
+
+// s3Error converts an unexpected object store response into an error.
+func s3Error(res *http.Response) error {
+	body, _ := ioutil.ReadAll(res.Body)
+	if len(body) > 256 {
+		body = body[:256]
+	}
+	return fmt.Errorf("object store request failed: %s: %s", res.Status, bytes.TrimSpace(body))
+}
+
+// signS3Request adds the AWS signature version 4 authorization headers to an
+// object store request. Requests without credentials are left anonymous.
+func signS3Request(req *http.Request, body []byte, region, accessKey, secretKey string, now time.Time) {
+	var (
+		timestamp   = now.UTC().Format("20060102T150405Z")
+		date        = timestamp[:8]
+		payloadHash = sha256.Sum256(body)
+		payload     = hex.EncodeToString(payloadHash[:])
+	)
+	req.Header.Set("x-amz-date", timestamp)
+	req.Header.Set("x-amz-content-sha256", payload)
+	if accessKey == "" {
+		return
+	}
+	// Assemble the canonical request from the signed headers
+	headers := []string{"host"}
+	for name := range req.Header {
+		if name := strings.ToLower(name); name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers = append(headers, name)
+		}
+	}
+	sort.Strings(headers)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := req.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		} else if value == "" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	// Sign the request with the key derived for the date, region and service
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of the given data.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes an object path as mandated by the request signing,
+// escaping everything but the unreserved characters and the path separators.
+func s3EscapePath(path string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~', b == '/':
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
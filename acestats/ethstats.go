@@ -19,6 +19,7 @@ package ethstats
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -92,9 +93,11 @@ type Service struct {
 	pass string // Password to authorize access to the monitoring page
 	host string // Remote address of the monitoring service
 
+	tls     *tls.Config // Client TLS configuration of the authenticated mode, nil for legacy netstats
+	metrics []string    // Metrics pushed in the authenticated mode
+
 	pongCh chan struct{} // Pong notifications are fed into this channel
 	histCh chan []uint64 // History request block numbers are fed into this channel
-
 }
 
 // connWrapper is a wrapper to prevent concurrent-write or concurrent-read on the
@@ -142,13 +145,33 @@ func (w *connWrapper) Close() error {
 	return w.conn.Close()
 }
 
-// New returns a monitoring service ready for stats reporting.
-func New(node *node.Node, backend backend, engine consensus.Engine, url string) error {
+// Config contains the settings of the stats reporting service.
+type Config struct {
+	URL string `toml:",omitempty"` // Reporting URL (nodename:secret@host:port)
+
+	// Client certificate and key enabling the authenticated reporting mode, in
+	// which the whitelisted metrics are pushed over mutual TLS instead of using
+	// the legacy netstats protocol.
+	TLSCert string `toml:",omitempty"`
+	TLSKey  string `toml:",omitempty"`
+
+	// CA certificate to authenticate the stats server with, the system roots
+	// being used if unset.
+	TLSCA string `toml:",omitempty"`
+
+	// Metrics to push in the authenticated mode, all if empty.
+	Metrics []string `toml:",omitempty"`
+}
+
+// New returns a monitoring service ready for stats reporting. The service reports
+// the chain of the given backend, which is served by the given lifecycle: it is
+// declared as required to be started before the reporting starts.
+func New(node *node.Node, service node.Lifecycle, backend backend, engine consensus.Engine, config Config) error {
 	// Parse the netstats connection url
 	re := regexp.MustCompile("([^:@]*)(:([^@]*))?@(.+)")
-	parts := re.FindStringSubmatch(url)
+	parts := re.FindStringSubmatch(config.URL)
 	if len(parts) != 5 {
-		return fmt.Errorf("invalid netstats url: \"%s\", should be nodename:secret@host:port", url)
+		return fmt.Errorf("invalid netstats url: \"%s\", should be nodename:secret@host:port", config.URL)
 	}
 	ethstats := &Service{
 		backend: backend,
@@ -160,8 +183,22 @@ func New(node *node.Node, backend backend, engine consensus.Engine, url string)
 		pongCh:  make(chan struct{}),
 		histCh:  make(chan []uint64, 1),
 	}
+	if config.TLSCert != "" || config.TLSKey != "" {
+		tls, err := makeTLSConfig(config)
+		if err != nil {
+			return err
+		}
+		metrics, err := makeMetricList(config.Metrics)
+		if err != nil {
+			return err
+		}
+		ethstats.tls, ethstats.metrics = tls, metrics
+	} else if len(config.Metrics) > 0 {
+		return errors.New("metrics whitelist requires the authenticated reporting mode")
+	}
 
 	node.RegisterLifecycle(ethstats)
+	node.RequireLifecycle(ethstats, service)
 	return nil
 }
 
@@ -232,6 +269,11 @@ func (s *Service) loop() {
 		close(quitCh)
 	}()
 
+	// In the authenticated mode, push the metrics instead of using netstats
+	if s.tls != nil {
+		s.pushLoop(quitCh, headCh)
+		return
+	}
 	// Resolve the URL, defaulting to TLS, but falling back to none too
 	path := fmt.Sprintf("%s/api", s.host)
 	urls := []string{path}
@@ -439,9 +481,8 @@ type authMsg struct {
 	Secret string   `json:"secret"`
 }
 
-// login tries to authorize the client at the remote server.
-func (s *Service) login(conn *connWrapper) error {
-	// Construct and send the login authentication
+// nodeInfo assembles the meta information about the local node.
+func (s *Service) nodeInfo() nodeInfo {
 	infos := s.server.NodeInfo()
 
 	var protocols []string
@@ -454,20 +495,26 @@ func (s *Service) login(conn *connWrapper) error {
 	} else {
 		network = fmt.Sprintf("%d", infos.Protocols["les"].(*les.NodeInfo).Network)
 	}
+	return nodeInfo{
+		Name:     s.node,
+		Node:     infos.Name,
+		Port:     infos.Ports.Listener,
+		Network:  network,
+		Protocol: strings.Join(protocols, ", "),
+		API:      "No",
+		Os:       runtime.GOOS,
+		OsVer:    runtime.GOARCH,
+		Client:   "0.1.1",
+		History:  true,
+	}
+}
+
+// login tries to authorize the client at the remote server.
+func (s *Service) login(conn *connWrapper) error {
+	// Construct and send the login authentication
 	auth := &authMsg{
-		ID: s.node,
-		Info: nodeInfo{
-			Name:     s.node,
-			Node:     infos.Name,
-			Port:     infos.Ports.Listener,
-			Network:  network,
-			Protocol: strings.Join(protocols, ", "),
-			API:      "No",
-			Os:       runtime.GOOS,
-			OsVer:    runtime.GOARCH,
-			Client:   "0.1.1",
-			History:  true,
-		},
+		ID:     s.node,
+		Info:   s.nodeInfo(),
 		Secret: s.pass,
 	}
 	login := map[string][]interface{}{
@@ -0,0 +1,59 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/node"
+	"github.com/acent/go-acent/p2p"
+)
+
+// testService is a backend service lifecycle doing nothing.
+type testService struct{}
+
+func (s *testService) Start() error { return nil }
+func (s *testService) Stop() error  { return nil }
+
+// Tests that the reporting service requires the service of the reported backend
+// and refuses to start without it.
+func TestStartWithoutBackend(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	stack, err := node.New(&node.Config{P2P: p2p.Config{PrivateKey: key}})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer stack.Close()
+
+	// Register the reporting, but not the service it depends on
+	if err := New(stack, new(testService), nil, nil, Config{URL: "node:secret@127.0.0.1:3000"}); err != nil {
+		t.Fatalf("failed to create stats service: %v", err)
+	}
+	infos := stack.Lifecycles()
+	if len(infos) != 1 || len(infos[0].Requires) != 1 || infos[0].Requires[0] != "*ethstats.testService" {
+		t.Fatalf("backend service not required: %+v", infos)
+	}
+	err = stack.Start()
+	if err == nil {
+		t.Fatal("stats service started without its backend")
+	}
+	if !strings.Contains(err.Error(), "requires unregistered lifecycle") {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+}
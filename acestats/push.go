@@ -0,0 +1,206 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/log"
+	"github.com/gorilla/websocket"
+)
+
+// pushInterval is the interval between two metric pushes in the authenticated
+// reporting mode, unless a new chain head triggers one earlier.
+const pushInterval = 15 * time.Second
+
+// pushMetrics are the metrics that can be pushed in the authenticated reporting
+// mode, along with their collectors.
+var pushMetrics = map[string]func(s *Service) uint64{
+	"txpool.pending": func(s *Service) uint64 {
+		pending, _ := s.backend.Stats()
+		return uint64(pending)
+	},
+	"txpool.queued": func(s *Service) uint64 {
+		_, queued := s.backend.Stats()
+		return uint64(queued)
+	},
+	"peers": func(s *Service) uint64 {
+		return uint64(s.server.PeerCount())
+	},
+	"sync.lag": func(s *Service) uint64 {
+		head := s.backend.CurrentHeader().Number.Uint64()
+		if highest := s.backend.Downloader().Progress().HighestBlock; highest > head {
+			return highest - head
+		}
+		return 0
+	},
+}
+
+// makeTLSConfig creates the client TLS configuration of the authenticated
+// reporting mode from the service configuration.
+func makeTLSConfig(config Config) (*tls.Config, error) {
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return nil, errors.New("authenticated stats reporting requires both a TLS certificate and key")
+	}
+	cert, err := tls.LoadX509KeyPair(config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if config.TLSCA != "" {
+		blob, err := ioutil.ReadFile(config.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stats TLS CA certificate: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(blob) {
+			return nil, fmt.Errorf("no certificates found in %s", config.TLSCA)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	return tlsConfig, nil
+}
+
+// makeMetricList validates the metrics whitelist, returning all the available
+// metrics if it's empty.
+func makeMetricList(whitelist []string) ([]string, error) {
+	if len(whitelist) == 0 {
+		for name := range pushMetrics {
+			whitelist = append(whitelist, name)
+		}
+		sort.Strings(whitelist)
+		return whitelist, nil
+	}
+	for _, name := range whitelist {
+		if _, ok := pushMetrics[name]; !ok {
+			return nil, fmt.Errorf("unknown stats metric %q", name)
+		}
+	}
+	return whitelist, nil
+}
+
+// pushHello is the message introducing the node to the server once connected in
+// the authenticated reporting mode.
+type pushHello struct {
+	Type string   `json:"type"`
+	ID   string   `json:"id"`
+	Info nodeInfo `json:"info"`
+}
+
+// pushReport is a metrics report sent to the server in the authenticated mode.
+type pushReport struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id"`
+	Time    int64             `json:"time"`
+	Metrics map[string]uint64 `json:"metrics"`
+}
+
+// pushLoop keeps trying to connect to the stats server over mutual TLS, pushing
+// the whitelisted metrics periodically and on every new chain head until
+// termination.
+func (s *Service) pushLoop(quitCh chan struct{}, headCh chan *types.Block) {
+	url := s.host + "/metrics"
+	if !strings.Contains(url, "://") {
+		url = "wss://" + url
+	}
+	errTimer := time.NewTimer(0)
+	defer errTimer.Stop()
+
+	for {
+		select {
+		case <-quitCh:
+			return
+		case <-errTimer.C:
+			// Establish an authenticated websocket connection to the server
+			dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second, TLSClientConfig: s.tls}
+			c, _, err := dialer.Dial(url, nil)
+			if err != nil {
+				log.Warn("Stats server unreachable", "err", err)
+				errTimer.Reset(10 * time.Second)
+				continue
+			}
+			conn := newConnectionWrapper(c)
+			if err = conn.WriteJSON(&pushHello{Type: "hello", ID: s.node, Info: s.nodeInfo()}); err != nil {
+				log.Warn("Stats hello failed", "err", err)
+				conn.Close()
+				errTimer.Reset(10 * time.Second)
+				continue
+			}
+			go s.drainLoop(conn)
+
+			// Keep pushing metrics until the connection breaks
+			report := time.NewTicker(pushInterval)
+			for err == nil {
+				if err = s.pushMetrics(conn); err != nil {
+					log.Warn("Stats metrics push failed", "err", err)
+					break
+				}
+				select {
+				case <-quitCh:
+					report.Stop()
+					conn.Close()
+					return
+				case <-report.C:
+				case <-headCh:
+				}
+			}
+			report.Stop()
+
+			// Close the current connection and establish a new one
+			conn.Close()
+			errTimer.Reset(0)
+		}
+	}
+}
+
+// drainLoop discards any messages sent by the server in the authenticated mode,
+// closing the connection once it breaks so that pushes fail fast.
+func (s *Service) drainLoop(conn *connWrapper) {
+	defer conn.Close()
+
+	for {
+		var blob json.RawMessage
+		if err := conn.ReadJSON(&blob); err != nil {
+			return
+		}
+		log.Trace("Discarding stats server message", "msg", string(blob))
+	}
+}
+
+// pushMetrics collects the whitelisted metrics and pushes them to the server.
+func (s *Service) pushMetrics(conn *connWrapper) error {
+	report := &pushReport{
+		Type:    "metrics",
+		ID:      s.node,
+		Time:    time.Now().Unix(),
+		Metrics: make(map[string]uint64, len(s.metrics)),
+	}
+	for _, name := range s.metrics {
+		report.Metrics[name] = pushMetrics[name](s)
+	}
+	log.Trace("Pushing metrics to stats server", "metrics", report.Metrics)
+	return conn.WriteJSON(report)
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethstats
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	ethproto "github.com/acent/go-acent/eth/protocols/eth"
+	"github.com/acent/go-acent/p2p"
+	"github.com/gorilla/websocket"
+)
+
+// pushBackend is a backend reporting fixed transaction pool stats.
+type pushBackend struct {
+	backend
+	pending, queued int
+}
+
+func (b *pushBackend) Stats() (int, int) { return b.pending, b.queued }
+
+// writePEM writes a PEM block of the given type into a file in dir.
+func writePEM(t *testing.T, dir, name, kind string, blob []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: blob}), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// Tests that the authenticated reporting mode connects to the stats server over
+// mutual TLS, introduces the node and pushes the whitelisted metrics on every
+// new chain head.
+func TestPushMetrics(t *testing.T) {
+	// Start a stats server requiring a client certificate, forwarding the
+	// received messages
+	var (
+		upgrader = websocket.Upgrader{}
+		hellos   = make(chan *pushHello, 1)
+		reports  = make(chan *pushReport, 10)
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "unauthenticated", http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		hello := new(pushHello)
+		if err := conn.ReadJSON(hello); err != nil {
+			return
+		}
+		hellos <- hello
+		for {
+			report := new(pushReport)
+			if err := conn.ReadJSON(report); err != nil {
+				return
+			}
+			reports <- report
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Create the client certificate and the configuration trusting the server
+	dir, err := ioutil.TempDir("", "ethstats-push")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+	keyBlob, _ := x509.MarshalECPrivateKey(key)
+
+	config := Config{
+		TLSCert: writePEM(t, dir, "client.crt", "CERTIFICATE", cert),
+		TLSKey:  writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyBlob),
+		TLSCA:   writePEM(t, dir, "ca.crt", "CERTIFICATE", srv.Certificate().Raw),
+	}
+	tlsConfig, err := makeTLSConfig(config)
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	metrics, err := makeMetricList([]string{"txpool.pending", "txpool.queued", "peers"})
+	if err != nil {
+		t.Fatalf("failed to create metric list: %v", err)
+	}
+	// Start a p2p server to report the node infos of
+	nodekey, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  nodekey,
+		NoDiscovery: true,
+		Protocols: []p2p.Protocol{{
+			Name:     "eth",
+			Version:  66,
+			NodeInfo: func() interface{} { return &ethproto.NodeInfo{Network: 1337} },
+		}},
+	}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start p2p server: %v", err)
+	}
+	defer server.Stop()
+
+	backend := &pushBackend{pending: 1, queued: 2}
+	service := &Service{
+		server:  server,
+		backend: backend,
+		node:    "test",
+		host:    srv.Listener.Addr().String(),
+		tls:     tlsConfig,
+		metrics: metrics,
+	}
+	var (
+		quitCh = make(chan struct{})
+		headCh = make(chan *types.Block, 1)
+		done   = make(chan struct{})
+	)
+	go func() {
+		service.pushLoop(quitCh, headCh)
+		close(done)
+	}()
+	defer func() {
+		close(quitCh)
+		<-done
+	}()
+
+	select {
+	case hello := <-hellos:
+		if hello.Type != "hello" || hello.ID != "test" || hello.Info.Network != "1337" {
+			t.Errorf("hello mismatch: have %+v", hello)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("hello not received")
+	}
+	check := func(pending, queued uint64) {
+		t.Helper()
+		select {
+		case report := <-reports:
+			if report.Type != "metrics" || report.ID != "test" {
+				t.Errorf("report header mismatch: have %s from %s", report.Type, report.ID)
+			}
+			want := map[string]uint64{"txpool.pending": pending, "txpool.queued": queued, "peers": 0}
+			if len(report.Metrics) != len(want) {
+				t.Errorf("metrics mismatch: have %v, want %v", report.Metrics, want)
+			}
+			for name, value := range want {
+				if have, ok := report.Metrics[name]; !ok || have != value {
+					t.Errorf("metric %s mismatch: have %d, want %d", name, have, value)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("metrics not pushed")
+		}
+	}
+	// The metrics are pushed once connected, and again on a new chain head
+	check(1, 2)
+
+	backend.pending, backend.queued = 3, 4
+	headCh <- types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	check(3, 4)
+}
+
+// Tests that unknown metrics are rejected, and all of them are pushed if the
+// whitelist is empty.
+func TestMakeMetricList(t *testing.T) {
+	if _, err := makeMetricList([]string{"peers", "unknown"}); err == nil {
+		t.Errorf("unknown metric accepted")
+	}
+	all, err := makeMetricList(nil)
+	if err != nil {
+		t.Fatalf("failed to list all metrics: %v", err)
+	}
+	if len(all) != len(pushMetrics) {
+		t.Errorf("metric count mismatch: have %d, want %d", len(all), len(pushMetrics))
+	}
+}
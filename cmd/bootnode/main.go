@@ -22,7 +22,10 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/acent/go-acent/cmd/utils"
 	"github.com/acent/go-acent/crypto"
@@ -40,9 +43,12 @@ func main() {
 		writeAddr   = flag.Bool("writeaddress", false, "write out the node's public key and quit")
 		nodeKeyFile = flag.String("nodekey", "", "private key filename")
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
-		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
+		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|pcp|extip:<IP>)")
 		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
 		runv5       = flag.Bool("v5", false, "run a v5 topic discovery bootnode")
+		nodeDBPath  = flag.String("nodedb", "", "node database directory, kept in memory if empty")
+		seedNodes   = flag.String("seeds", "", "comma separated enode URLs or records of known good nodes")
+		httpAddr    = flag.String("http", "", "listen address of the read-only node listing endpoint (disabled if empty)")
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-5)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
 
@@ -99,6 +105,15 @@ func main() {
 		}
 	}
 
+	var seeds []*enode.Node
+	for _, url := range utils.SplitAndTrim(*seedNodes) {
+		n, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			utils.Fatalf("-seeds: invalid node %q: %v", url, err)
+		}
+		seeds = append(seeds, n)
+	}
+
 	addr, err := net.ResolveUDPAddr("udp", *listenAddr)
 	if err != nil {
 		utils.Fatalf("-ResolveUDPAddr: %v", err)
@@ -120,23 +135,48 @@ func main() {
 
 	printNotice(&nodeKey.PublicKey, *realaddr)
 
-	db, _ := enode.OpenDB("")
+	db, err := enode.OpenDB(*nodeDBPath)
+	if err != nil {
+		utils.Fatalf("-nodedb: %v", err)
+	}
+	defer db.Close()
+
 	ln := enode.NewLocalNode(db, nodeKey)
 	cfg := discover.Config{
 		PrivateKey:  nodeKey,
 		NetRestrict: restrictList,
+		Bootnodes:   seeds,
 	}
+	var disc discovery
 	if *runv5 {
-		if _, err := discover.ListenV5(conn, ln, cfg); err != nil {
+		if disc, err = discover.ListenV5(conn, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	} else {
-		if _, err := discover.ListenUDP(conn, ln, cfg); err != nil {
+		if disc, err = discover.ListenUDP(conn, ln, cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	}
+	defer disc.Close()
+
+	if *httpAddr != "" {
+		listener, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			utils.Fatalf("-http: %v", err)
+		}
+		defer listener.Close()
+
+		mux := http.NewServeMux()
+		mux.Handle("/nodes", newNodeServer(disc, db, seeds, *runv5))
+		go http.Serve(listener, mux)
+		log.Info("Node listing endpoint opened", "url", fmt.Sprintf("http://%v/nodes", listener.Addr()))
+	}
 
-	select {}
+	// Run until interrupted, closing the node database cleanly.
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Got interrupt, shutting down...")
 }
 
 func printNotice(nodeKey *ecdsa.PublicKey, addr net.UDPAddr) {
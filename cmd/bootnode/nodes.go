@@ -0,0 +1,120 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/acent/go-acent/p2p/enode"
+)
+
+// discovery is the discovery protocol run by the bootnode.
+type discovery interface {
+	Self() *enode.Node
+	AllNodes() []*enode.Node
+	Close()
+}
+
+// nodeList is the response of the node listing endpoint.
+type nodeList struct {
+	Self  string        `json:"self"`  // Record of the bootnode
+	DB    enode.DBStats `json:"db"`    // Summary of the node database
+	Nodes []nodeStats   `json:"nodes"` // Nodes in the discovery table
+}
+
+// nodeStats is the liveness information of a node in the discovery table.
+type nodeStats struct {
+	ID        enode.ID   `json:"id"`
+	URL       string     `json:"url"` // Node record, or enode URL if unsigned
+	IP        string     `json:"ip"`
+	UDP       int        `json:"udp"`
+	Seed      bool       `json:"seed"`               // Whether the node was given as seed
+	LastPing  *time.Time `json:"lastPing,omitempty"` // Last ping received from the node
+	LastPong  *time.Time `json:"lastPong,omitempty"` // Last pong received from the node
+	FindFails int        `json:"findFails"`          // Consecutive findnode failures
+}
+
+// nodeServer is a read-only HTTP endpoint listing the nodes known to the bootnode.
+type nodeServer struct {
+	tab   discovery
+	db    *enode.DB
+	seeds map[enode.ID]bool
+	v5    bool // Whether the table is of discovery v5, tracking its own failures
+}
+
+func newNodeServer(tab discovery, db *enode.DB, seeds []*enode.Node, v5 bool) *nodeServer {
+	s := &nodeServer{tab: tab, db: db, seeds: make(map[enode.ID]bool), v5: v5}
+	for _, n := range seeds {
+		s.seeds[n.ID()] = true
+	}
+	return s
+}
+
+func (s *nodeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s.list())
+}
+
+// list collects the liveness information of the nodes in the table.
+func (s *nodeServer) list() *nodeList {
+	list := &nodeList{
+		Self:  s.tab.Self().String(),
+		DB:    s.db.Stats(),
+		Nodes: make([]nodeStats, 0),
+	}
+	for _, n := range s.tab.AllNodes() {
+		stats := nodeStats{
+			ID:   n.ID(),
+			URL:  n.String(),
+			IP:   n.IP().String(),
+			UDP:  n.UDP(),
+			Seed: s.seeds[n.ID()],
+		}
+		stats.LastPing = dbTime(s.db.LastPingReceived(n.ID(), n.IP()))
+		stats.LastPong = dbTime(s.db.LastPongReceived(n.ID(), n.IP()))
+		if s.v5 {
+			stats.FindFails = s.db.FindFailsV5(n.ID(), n.IP())
+		} else {
+			stats.FindFails = s.db.FindFails(n.ID(), n.IP())
+		}
+		list.Nodes = append(list.Nodes, stats)
+	}
+	sort.Slice(list.Nodes, func(i, j int) bool {
+		return bytes.Compare(list.Nodes[i].ID[:], list.Nodes[j].ID[:]) < 0
+	})
+	return list
+}
+
+// dbTime converts a time stored in the node database, returning nil if it was
+// never stored.
+func dbTime(t time.Time) *time.Time {
+	if t.Unix() <= 0 {
+		return nil
+	}
+	return &t
+}
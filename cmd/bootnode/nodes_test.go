@@ -0,0 +1,104 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/p2p/enode"
+)
+
+type testDiscovery struct {
+	self  *enode.Node
+	nodes []*enode.Node
+}
+
+func (d *testDiscovery) Self() *enode.Node       { return d.self }
+func (d *testDiscovery) AllNodes() []*enode.Node { return d.nodes }
+func (d *testDiscovery) Close()                  {}
+
+func newTestNode(ip net.IP) *enode.Node {
+	key, _ := crypto.GenerateKey()
+	return enode.NewV4(&key.PublicKey, ip, 30303, 30303)
+}
+
+// Tests that the node listing endpoint reports the liveness of the table nodes.
+func TestNodeServer(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+
+	var (
+		seed  = newTestNode(net.IP{10, 0, 0, 1})
+		other = newTestNode(net.IP{10, 0, 0, 2})
+		pong  = time.Unix(time.Now().Unix(), 0)
+	)
+	db.UpdateNode(seed)
+	db.UpdateLastPingReceived(seed.ID(), seed.IP(), pong)
+	db.UpdateLastPongReceived(seed.ID(), seed.IP(), pong)
+	db.UpdateFindFails(other.ID(), other.IP(), 3)
+
+	disc := &testDiscovery{self: newTestNode(net.IP{127, 0, 0, 1}), nodes: []*enode.Node{other, seed}}
+	server := newNodeServer(disc, db, []*enode.Node{seed}, false)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/nodes", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status mismatch: have %d, want %d", rec.Code, http.StatusOK)
+	}
+	var list nodeList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if list.Self != disc.self.String() {
+		t.Errorf("self mismatch: have %s, want %s", list.Self, disc.self.String())
+	}
+	if list.DB.Nodes != 2 || list.DB.Liveness["live"] != 1 {
+		t.Errorf("database stats mismatch: %+v", list.DB)
+	}
+	if len(list.Nodes) != 2 {
+		t.Fatalf("node count mismatch: have %d, want 2", len(list.Nodes))
+	}
+	for _, n := range list.Nodes {
+		switch n.ID {
+		case seed.ID():
+			if !n.Seed || n.IP != "10.0.0.1" || n.UDP != 30303 || n.FindFails != 0 {
+				t.Errorf("seed node mismatch: %+v", n)
+			}
+			if n.LastPing == nil || !n.LastPing.Equal(pong) || n.LastPong == nil || !n.LastPong.Equal(pong) {
+				t.Errorf("seed node liveness mismatch: ping %v, pong %v, want %v", n.LastPing, n.LastPong, pong)
+			}
+		case other.ID():
+			if n.Seed || n.LastPing != nil || n.LastPong != nil || n.FindFails != 3 {
+				t.Errorf("other node mismatch: %+v", n)
+			}
+		default:
+			t.Errorf("unexpected node %v", n.ID)
+		}
+	}
+	// The endpoint is read-only.
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/nodes", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status mismatch: have %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
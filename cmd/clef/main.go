@@ -116,6 +116,14 @@ var (
 		Usage: "File used for writing new 4byte-identifiers submitted via API",
 		Value: "./4byte-custom.json",
 	}
+	importDBFlag = cli.StringFlag{
+		Name:  "4bytedb-import",
+		Usage: "Comma separated list of additional 4byte-identifier files to import (read only)",
+	}
+	importRefreshFlag = cli.DurationFlag{
+		Name:  "4bytedb-refresh",
+		Usage: "Interval to reload modified 4byte-identifier imports (0 = disabled)",
+	}
 	auditLogFlag = cli.StringFlag{
 		Name:  "auditlog",
 		Usage: "File used to emit audit logs. Set to \"\" to disable",
@@ -237,6 +245,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			rpcPortFlag,
 			signerSecretFlag,
 			customDBFlag,
+			importDBFlag,
+			importRefreshFlag,
 			auditLogFlag,
 			ruleFlag,
 			stdiouiFlag,
@@ -266,6 +276,8 @@ func init() {
 		rpcPortFlag,
 		signerSecretFlag,
 		customDBFlag,
+		importDBFlag,
+		importRefreshFlag,
 		auditLogFlag,
 		ruleFlag,
 		stdiouiFlag,
@@ -571,6 +583,19 @@ func signer(c *cli.Context) error {
 	embeds, locals := db.Size()
 	log.Info("Loaded 4byte database", "embeds", embeds, "locals", locals, "local", fourByteLocal)
 
+	if imports := c.GlobalString(importDBFlag.Name); imports != "" {
+		for _, path := range strings.Split(imports, ",") {
+			loaded, skipped, err := db.Import(path)
+			if err != nil {
+				utils.Fatalf("Failed to import 4byte database %s: %v", path, err)
+			}
+			log.Info("Imported 4byte database", "path", path, "selectors", loaded, "skipped", skipped)
+		}
+		if interval := c.GlobalDuration(importRefreshFlag.Name); interval > 0 {
+			defer db.WatchImports(interval)()
+		}
+	}
+
 	var (
 		api       core.ExternalAPI
 		pwStorage storage.Storage = &storage.NoStorage{}
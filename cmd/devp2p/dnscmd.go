@@ -28,6 +28,7 @@ import (
 	"github.com/acent/go-acent/accounts/keystore"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/console/prompt"
+	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p/dnsdisc"
 	"github.com/acent/go-acent/p2p/enode"
 	"gopkg.in/urfave/cli.v1"
@@ -41,6 +42,7 @@ var (
 			dnsSyncCommand,
 			dnsSignCommand,
 			dnsTXTCommand,
+			dnsPublishCommand,
 			dnsCloudflareCommand,
 			dnsRoute53Command,
 		},
@@ -65,6 +67,23 @@ var (
 		ArgsUsage: "<tree-directory> <output-file>",
 		Action:    dnsToTXT,
 	}
+	dnsPublishCommand = cli.Command{
+		Name:      "publish",
+		Usage:     "Deploy a signed DNS discovery tree to a DNS provider",
+		ArgsUsage: "<tree-directory>",
+		Action:    dnsPublish,
+		Flags: []cli.Flag{
+			dnsProviderFlag,
+			dnsForceFlag,
+			dnsTimeoutFlag,
+			cloudflareTokenFlag,
+			cloudflareZoneIDFlag,
+			route53AccessKeyFlag,
+			route53AccessSecretFlag,
+			route53ZoneIDFlag,
+			route53RegionFlag,
+		},
+	}
 	dnsCloudflareCommand = cli.Command{
 		Name:      "to-cloudflare",
 		Usage:     "Deploy DNS TXT records to CloudFlare",
@@ -99,6 +118,14 @@ var (
 		Name:  "seq",
 		Usage: "New sequence number of the tree",
 	}
+	dnsProviderFlag = cli.StringFlag{
+		Name:  "provider",
+		Usage: "DNS provider to deploy to (cloudflare, route53)",
+	}
+	dnsForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Deploy even if the published tree has a higher sequence number",
+	}
 )
 
 const (
@@ -199,6 +226,42 @@ func dnsToTXT(ctx *cli.Context) error {
 	return nil
 }
 
+// dnsPublish performs dnsPublishCommand.
+func dnsPublish(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need tree definition directory as argument")
+	}
+	dir := ctx.Args().Get(0)
+	domain, t, err := loadTreeDefinitionForExport(dir)
+	if err != nil {
+		return err
+	}
+	// Clients ignore roots with a lower sequence number than the one they
+	// know, so don't replace a newer tree by accident.
+	if !ctx.Bool(dnsForceFlag.Name) {
+		url := loadTreeDefinition(dir).Meta.URL
+		seq, err := dnsClient(ctx).PublishedSeq(url)
+		switch {
+		case err != nil:
+			log.Info("No published tree found", "domain", domain, "err", err)
+		case seq > t.Seq():
+			return fmt.Errorf("published tree has seq %d, higher than %d (use --%s to deploy anyway)", seq, t.Seq(), dnsForceFlag.Name)
+		default:
+			log.Info("Replacing published tree", "domain", domain, "seq", seq, "new", t.Seq())
+		}
+	}
+	switch provider := ctx.String(dnsProviderFlag.Name); provider {
+	case "cloudflare":
+		return newCloudflareClient(ctx).deploy(domain, t)
+	case "route53":
+		return newRoute53Client(ctx).deploy(domain, t)
+	case "":
+		return fmt.Errorf("missing --%s", dnsProviderFlag.Name)
+	default:
+		return fmt.Errorf("unknown DNS provider %q", provider)
+	}
+}
+
 // dnsToCloudflare peforms dnsCloudflareCommand.
 func dnsToCloudflare(ctx *cli.Context) error {
 	if ctx.NArg() < 1 {
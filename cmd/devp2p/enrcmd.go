@@ -28,19 +28,46 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/p2p/enr"
 	"github.com/acent/go-acent/rlp"
 	"gopkg.in/urfave/cli.v1"
 )
 
-var enrdumpCommand = cli.Command{
-	Name:   "enrdump",
-	Usage:  "Pretty-prints node records",
-	Action: enrdump,
-	Flags: []cli.Flag{
-		cli.StringFlag{Name: "file"},
-	},
+var (
+	enrdumpCommand = cli.Command{
+		Name:   "enrdump",
+		Usage:  "Pretty-prints node records",
+		Action: enrdump,
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "file"},
+		},
+	}
+	enrCommand = cli.Command{
+		Name:  "enr",
+		Usage: "Operations on node records",
+		Subcommands: []cli.Command{
+			enrSignCommand,
+		},
+	}
+	enrSignCommand = cli.Command{
+		Name:  "sign",
+		Usage: "Creates a node record signed by a node key",
+		Description: `Creates a node record with the given key/value pairs and signs it with the
+node key. Well-known keys (ip, ip6, tcp, tcp6, udp, udp6, quic) take a plain
+address or port number. Values of other keys are stored as strings, unless
+prefixed with 0x, in which case they are hex-encoded RLP.`,
+		ArgsUsage: "keyfile [key=value ...]",
+		Action:    enrSign,
+		Flags:     []cli.Flag{seqFlag},
+	}
+)
+
+var seqFlag = cli.Uint64Flag{
+	Name:  "seq",
+	Usage: "Sequence number of the record",
+	Value: 1,
 }
 
 func enrdump(ctx *cli.Context) error {
@@ -74,6 +101,99 @@ func enrdump(ctx *cli.Context) error {
 	return nil
 }
 
+func enrSign(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need key file as argument")
+	}
+	key, err := crypto.LoadECDSA(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	r, err := makeRecord(ctx.Uint64(seqFlag.Name), ctx.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if err := enode.SignV4(r, key); err != nil {
+		return fmt.Errorf("could not sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return err
+	}
+	fmt.Println(n.String())
+	return nil
+}
+
+// makeRecord creates an unsigned node record from key=value arguments.
+func makeRecord(seq uint64, args []string) (*enr.Record, error) {
+	var (
+		r    enr.Record
+		seen = make(map[string]bool)
+	)
+	for _, arg := range args {
+		eq := strings.IndexByte(arg, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid key/value pair %q", arg)
+		}
+		key, value := arg[:eq], arg[eq+1:]
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+
+		entry, err := parseRecordEntry(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of key %q: %v", key, err)
+		}
+		r.Set(entry)
+	}
+	r.SetSeq(seq)
+	return &r, nil
+}
+
+// parseRecordEntry parses the value of a node record entry from its command line
+// representation.
+func parseRecordEntry(key, value string) (enr.Entry, error) {
+	switch key {
+	case "id", "secp256k1":
+		return nil, fmt.Errorf("key is set by the signature")
+	case "ip", "ip6":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		if key == "ip" {
+			if ip.To4() == nil {
+				return nil, fmt.Errorf("%v is not an IPv4 address", ip)
+			}
+			return enr.IPv4(ip), nil
+		}
+		if ip.To4() != nil {
+			return nil, fmt.Errorf("%v is not an IPv6 address", ip)
+		}
+		return enr.IPv6(ip), nil
+	case "tcp", "tcp6", "udp", "udp6", "quic":
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port number %q", value)
+		}
+		return enr.WithEntry(key, uint16(port)), nil
+	}
+	if strings.HasPrefix(value, "0x") {
+		raw, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return nil, err
+		}
+		if _, _, rest, err := rlp.Split(raw); err != nil {
+			return nil, fmt.Errorf("invalid RLP: %v", err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("invalid RLP: %d trailing bytes", len(rest))
+		}
+		return enr.WithEntry(key, rlp.RawValue(raw)), nil
+	}
+	return enr.WithEntry(key, value), nil
+}
+
 // dumpRecord creates a human-readable description of the given node record.
 func dumpRecord(out io.Writer, r *enr.Record) {
 	n, err := enode.New(enode.ValidSchemes, r)
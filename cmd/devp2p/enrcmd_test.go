@@ -0,0 +1,86 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/enr"
+)
+
+// Tests that node records are created from command line key/value pairs.
+func TestMakeRecord(t *testing.T) {
+	r, err := makeRecord(5, []string{"ip=203.0.113.1", "tcp=30303", "udp=30304", "name=boot", "eth=0xc20102"})
+	if err != nil {
+		t.Fatalf("failed to make record: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	if err := enode.SignV4(r, key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		t.Fatalf("failed to verify record: %v", err)
+	}
+	if n, err = parseNode(n.String()); err != nil {
+		t.Fatalf("failed to parse signed record: %v", err)
+	}
+	if n.Seq() != 5 {
+		t.Errorf("sequence number mismatch: have %d, want 5", n.Seq())
+	}
+	if n.ID() != enode.PubkeyToIDV4(&key.PublicKey) {
+		t.Errorf("node ID mismatch: have %v", n.ID())
+	}
+	if !n.IP().Equal(net.IP{203, 0, 113, 1}) || n.TCP() != 30303 || n.UDP() != 30304 {
+		t.Errorf("endpoint mismatch: %v:%d/%d", n.IP(), n.TCP(), n.UDP())
+	}
+	var name string
+	if err := n.Load(enr.WithEntry("name", &name)); err != nil || name != "boot" {
+		t.Errorf("name mismatch: have %q (%v), want %q", name, err, "boot")
+	}
+	var eth []uint
+	if err := n.Load(enr.WithEntry("eth", &eth)); err != nil || len(eth) != 2 || eth[0] != 1 || eth[1] != 2 {
+		t.Errorf("eth entry mismatch: have %v (%v), want [1 2]", eth, err)
+	}
+}
+
+// Tests that invalid key/value pairs are rejected.
+func TestMakeRecordErrors(t *testing.T) {
+	tests := [][]string{
+		{"ip"},
+		{"=foo"},
+		{"ip=foo"},
+		{"ip=::1"},
+		{"ip6=127.0.0.1"},
+		{"tcp=65536"},
+		{"udp=-1"},
+		{"id=v4"},
+		{"secp256k1=0x00"},
+		{"foo=0xzz"},
+		{"foo=0xc3c201"},
+		{"foo=0x0102"},
+		{"foo=1", "foo=2"},
+	}
+	for _, args := range tests {
+		if _, err := makeRecord(1, args); err == nil {
+			t.Errorf("no error for %q", args)
+		}
+	}
+}
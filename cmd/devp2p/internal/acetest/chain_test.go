@@ -21,6 +21,7 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/eth/protocols/eth"
 	"github.com/acent/go-acent/p2p"
 	"github.com/stretchr/testify/assert"
@@ -199,3 +200,76 @@ func TestChain_GetHeaders(t *testing.T) {
 		})
 	}
 }
+
+// TestLoadBranches tests whether the competing branches of the reorg test fork
+// off the test chain and outweigh it at every height.
+func TestLoadBranches(t *testing.T) {
+	chain, err := loadChain("./testdata/chain.rlp", "./testdata/genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var branches []*Chain
+	for _, file := range reorgBranchFiles {
+		branch, err := loadBranch(chain, reorgForkBlock, filepath.Join("./testdata", file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if branch.Len() <= reorgForkBlock+reorgRounds {
+			t.Fatalf("%s: too short branch: %d blocks", file, branch.Len()-reorgForkBlock-1)
+		}
+		for n := reorgForkBlock + 1; n < branch.Len(); n++ {
+			if branch.TD(n+1).Cmp(chain.TD(n+1)) <= 0 {
+				t.Fatalf("%s: block %d doesn't outweigh the test chain", file, n)
+			}
+		}
+		branches = append(branches, branch)
+	}
+	if branches[0].blocks[reorgForkBlock+1].Hash() == branches[1].blocks[reorgForkBlock+1].Hash() {
+		t.Fatal("reorg branches don't compete")
+	}
+}
+
+// TestServeHeaders tests whether the header requests of the reorg test are
+// answered correctly, including past the ends of the chain.
+func TestServeHeaders(t *testing.T) {
+	chain, err := loadChain("./testdata/chain.rlp", "./testdata/genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := uint64(chain.Len() - 1)
+
+	var tests = []struct {
+		req      GetBlockHeaders
+		expected []uint64
+	}{
+		{
+			req:      GetBlockHeaders{Origin: eth.HashOrNumber{Number: 2}, Amount: 3, Skip: 1},
+			expected: []uint64{2, 4, 6},
+		},
+		{
+			req:      GetBlockHeaders{Origin: eth.HashOrNumber{Number: 4}, Amount: 5, Skip: 1, Reverse: true},
+			expected: []uint64{4, 2, 0},
+		},
+		{
+			req:      GetBlockHeaders{Origin: eth.HashOrNumber{Hash: chain.blocks[head-1].Hash()}, Amount: 3},
+			expected: []uint64{head - 1, head},
+		},
+		{
+			req:      GetBlockHeaders{Origin: eth.HashOrNumber{Number: head + 1}, Amount: 1},
+			expected: []uint64{},
+		},
+		{
+			req:      GetBlockHeaders{Origin: eth.HashOrNumber{Hash: common.Hash{0x01}}, Amount: 1},
+			expected: []uint64{},
+		},
+	}
+	for i, tt := range tests {
+		headers := serveHeaders(chain, &tt.req)
+
+		numbers := []uint64{}
+		for _, header := range *headers {
+			numbers = append(numbers, header.Number.Uint64())
+		}
+		assert.Equal(t, tt.expected, numbers, "test %d", i)
+	}
+}
@@ -55,15 +55,19 @@ func (c *Conn) write66(req eth.Packet, code int) error {
 	if err != nil {
 		return err
 	}
-	_, err = c.Conn.Write(uint64(code), payload)
+	size, err := c.Conn.Write(uint64(code), payload)
+	if err == nil {
+		c.stats.sent(int(size))
+	}
 	return err
 }
 
 func (c *Conn) read66() (uint64, Message) {
-	code, rawData, _, err := c.Conn.Read()
+	code, rawData, wireSize, err := c.Conn.Read()
 	if err != nil {
 		return 0, errorf("could not read from connection: %v", err)
 	}
+	c.stats.received(wireSize)
 
 	var msg Message
 
@@ -0,0 +1,148 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/internal/utesting"
+	"github.com/acent/go-acent/p2p"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/rlpx"
+)
+
+// probedProtocols are the protocols whose supported versions are reported, along
+// with the versions offered in the probing handshake.
+var probedProtocols = []p2p.Cap{
+	{Name: "eth", Version: 64},
+	{Name: "eth", Version: 65},
+	{Name: "eth", Version: 66},
+	{Name: "les", Version: 2},
+	{Name: "les", Version: 3},
+	{Name: "les", Version: 4},
+	{Name: "snap", Version: 1},
+}
+
+// Capabilities is the report of the protocols a node supports, as advertised in
+// its devp2p handshake.
+type Capabilities struct {
+	Name string    // Client identifier of the node
+	Caps []p2p.Cap // Protocol capabilities of the node
+}
+
+// Probe connects to the node and reads its devp2p handshake to discover the
+// protocol versions it supports.
+func Probe(dest *enode.Node) (*Capabilities, error) {
+	fd, err := net.DialTimeout("tcp", fmt.Sprintf("%v:%d", dest.IP(), dest.TCP()), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn := &Conn{Conn: rlpx.NewConn(fd, dest.Pubkey()), caps: probedProtocols}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	conn.ourKey, _ = crypto.GenerateKey()
+	if _, err := conn.Handshake(conn.ourKey); err != nil {
+		return nil, err
+	}
+	if err := conn.Write(&Hello{Version: 5, Caps: conn.caps, ID: crypto.FromECDSAPub(&conn.ourKey.PublicKey)[1:]}); err != nil {
+		return nil, err
+	}
+	switch msg := conn.Read().(type) {
+	case *Hello:
+		return &Capabilities{Name: msg.Name, Caps: msg.Caps}, nil
+	case *Disconnect:
+		return nil, fmt.Errorf("node disconnected: %v", msg.Reason)
+	case *Error:
+		return nil, msg
+	default:
+		return nil, fmt.Errorf("bad handshake: %#v", msg)
+	}
+}
+
+// Versions returns the supported versions of the protocol in ascending order.
+func (c *Capabilities) Versions(name string) []uint {
+	var versions []uint
+	for _, capability := range c.Caps {
+		if capability.Name == name {
+			versions = append(versions, capability.Version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// Supports reports whether the node supports the given protocol version.
+func (c *Capabilities) Supports(name string, version uint) bool {
+	for _, capability := range c.Caps {
+		if capability.Name == name && capability.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the capability report, listing the supported versions of the
+// probed protocols followed by any other capabilities.
+func (c *Capabilities) String() string {
+	var (
+		report = fmt.Sprintf("Client: %s\n", c.Name)
+		probed = make(map[string]bool)
+	)
+	for _, proto := range probedProtocols {
+		if probed[proto.Name] {
+			continue
+		}
+		probed[proto.Name] = true
+
+		versions := c.Versions(proto.Name)
+		if len(versions) == 0 {
+			report += fmt.Sprintf("  %-5s unsupported\n", proto.Name)
+			continue
+		}
+		list := make([]string, len(versions))
+		for i, version := range versions {
+			list[i] = fmt.Sprint(version)
+		}
+		report += fmt.Sprintf("  %-5s %s\n", proto.Name, strings.Join(list, ", "))
+	}
+	for _, capability := range c.Caps {
+		if !probed[capability.Name] {
+			report += fmt.Sprintf("  %-5s %d (untested)\n", capability.Name, capability.Version)
+		}
+	}
+	return report
+}
+
+// TestsFor selects the tests of the suite applicable to a node with the given
+// capabilities. The les and snap protocols have no test suites yet and aren't
+// required.
+func (s *Suite) TestsFor(caps *Capabilities) ([]utesting.Test, error) {
+	switch {
+	case caps.Supports("eth", 66):
+		return s.AllEthTests(), nil
+	case caps.Supports("eth", 65) || caps.Supports("eth", 64):
+		return s.EthTests(), nil
+	default:
+		return nil, fmt.Errorf("node supports no tested eth protocol version (have %v)", caps.Versions("eth"))
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/p2p"
+)
+
+// Tests that the protocol versions supported by a node are discovered, and the
+// tests are selected accordingly.
+func TestProbe(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	protocol := func(name string, version uint) p2p.Protocol {
+		return p2p.Protocol{
+			Name:    name,
+			Version: version,
+			Length:  17,
+			Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return nil
+			},
+		}
+	}
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    10,
+		ListenAddr:  "127.0.0.1:0",
+		NoDiscovery: true,
+		Name:        "test",
+		Protocols: []p2p.Protocol{
+			protocol("eth", 65),
+			protocol("eth", 64),
+			protocol("snap", 1),
+			protocol("foo", 1),
+		},
+	}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start test node: %v", err)
+	}
+	defer server.Stop()
+
+	caps, err := Probe(server.Self())
+	if err != nil {
+		t.Fatalf("failed to probe node: %v", err)
+	}
+	if caps.Name != "test" {
+		t.Errorf("client name mismatch: have %q, want %q", caps.Name, "test")
+	}
+	if versions := caps.Versions("eth"); !reflect.DeepEqual(versions, []uint{64, 65}) {
+		t.Errorf("eth versions mismatch: have %v, want [64 65]", versions)
+	}
+	if versions := caps.Versions("les"); len(versions) != 0 {
+		t.Errorf("les versions mismatch: have %v, want none", versions)
+	}
+	if !caps.Supports("snap", 1) || caps.Supports("eth", 66) {
+		t.Errorf("supported versions mismatch: %v", caps.Caps)
+	}
+	want := "Client: test\n  eth   64, 65\n  les   unsupported\n  snap  1\n  foo   1 (untested)\n"
+	if report := caps.String(); report != want {
+		t.Errorf("report mismatch:\nhave:\n%s\nwant:\n%s", report, want)
+	}
+	// Without eth/66 only the tests of the older versions are applicable
+	suite := new(Suite)
+	tests, err := suite.TestsFor(caps)
+	if err != nil {
+		t.Fatalf("failed to select tests: %v", err)
+	}
+	if len(tests) != len(suite.EthTests()) {
+		t.Errorf("test count mismatch: have %d, want %d", len(tests), len(suite.EthTests()))
+	}
+	if _, err := suite.TestsFor(&Capabilities{Caps: []p2p.Cap{{Name: "les", Version: 4}}}); err == nil {
+		t.Errorf("tests selected for node without eth")
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/eth/protocols/eth"
+	"github.com/acent/go-acent/internal/utesting"
+	"github.com/acent/go-acent/rlp"
+)
+
+const (
+	// reorgForkBlock is the block of the test chain the competing branches of
+	// the reorg test fork off. It's the head of halfchain.rlp.
+	reorgForkBlock = 999
+
+	// reorgRounds is the number of times the reorg test switches the node
+	// between the competing branches, before switching back to the test chain.
+	reorgRounds = 6
+
+	// reorgGoroutineSlack is the number of goroutines the node may run more after
+	// the reorg test than before it, allowing for unrelated background work.
+	reorgGoroutineSlack = 10
+)
+
+// reorgBranchFiles are the files of the competing branches of the reorg test,
+// in the directory of the test chain. Each contains the ethash sealed blocks
+// following reorgForkBlock, mined with one second block times so that every
+// branch block is heavier than the test chain block at the same height.
+var reorgBranchFiles = []string{"reorg_a.rlp", "reorg_b.rlp"}
+
+// loadBranch loads the blocks of the given file as a branch of the chain
+// forking off the given block.
+func loadBranch(chain *Chain, fork int, file string) (*Chain, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	blocks := make([]*types.Block, fork+1)
+	copy(blocks, chain.blocks[:fork+1])
+
+	stream := rlp.NewStream(fh, 0)
+	for i := 0; ; i++ {
+		var b types.Block
+		if err := stream.Decode(&b); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("at block index %d: %v", i, err)
+		}
+		if parent := blocks[len(blocks)-1]; b.ParentHash() != parent.Hash() {
+			return nil, fmt.Errorf("block %d doesn't extend block %d", b.NumberU64(), parent.NumberU64())
+		}
+		blocks = append(blocks, &b)
+	}
+	config := *chain.chainConfig
+	return &Chain{blocks: blocks, chainConfig: &config}, nil
+}
+
+// TestChainReorg announces alternating competing heads from two connections,
+// each round reorging the node onto a branch forking deeper below its head.
+// After every round the node must have converged on the heaviest branch, and
+// the test finally switches it back to the test chain, checking that the node
+// still serves the announcing connections and accepts new ones. If the RPC API
+// of the node is available, the test also checks that the node dropped all its
+// peers and goroutines of the test afterwards.
+func (s *Suite) TestChainReorg(t *utesting.T) {
+	if s.chain.Len() <= reorgForkBlock {
+		t.Fatalf("test chain too short to fork off block %d", reorgForkBlock)
+	}
+	var before *nodeResources
+	if s.RPC != nil {
+		var err error
+		if before, err = s.nodeResources(); err != nil {
+			t.Fatalf("could not query node resources: %v", err)
+		}
+	} else {
+		t.Log("Node RPC not available, skipping leak checks")
+	}
+	var branches []*Chain
+	for _, file := range reorgBranchFiles {
+		branch, err := loadBranch(s.fullChain, reorgForkBlock, filepath.Join(s.dataDir, file))
+		if err != nil {
+			t.Fatalf("could not load reorg branch: %v", err)
+		}
+		branches = append(branches, branch)
+	}
+	conns := []*Conn{s.setupConnection(t), s.setupConnection(t)}
+
+	// Alternately make each branch the heaviest, announcing its blocks from its
+	// own connection, then switch back to the test chain
+	var (
+		announced = []int{reorgForkBlock + 1, reorgForkBlock + 1}
+		head      = s.chain.Len()
+		td        = s.chain.TD(head)
+	)
+	for round := 0; round < reorgRounds; round++ {
+		i := round % 2
+		t.Logf("Reorging to branch %d, abandoning %d blocks", i, head-1-reorgForkBlock)
+
+		announced[i] = s.announceHeavier(t, conns[i], branches[i], announced[i], td)
+		head, td = announced[i], branches[i].TD(announced[i])
+	}
+	t.Logf("Reorging back to the test chain, abandoning %d blocks", head-1-reorgForkBlock)
+	head = s.announceHeavier(t, conns[0], s.fullChain, s.chain.Len(), td)
+	s.chain = s.fullChain.Shorten(head)
+
+	// Make sure the announcing connections are still alive, and that the node
+	// accepts new peers with the test chain as its head
+	for i, conn := range conns {
+		if err := conn.waitForCanonical(s.chain, s.chain.Head()); err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		conn.Close()
+	}
+	s.setupConnection(t).Close()
+
+	if before != nil {
+		s.checkLeaks(t, before)
+	}
+}
+
+// nodeResources are the number of peers and goroutines of the node.
+type nodeResources struct {
+	peers      int
+	goroutines int
+}
+
+// nodeResources queries the peers and goroutines of the node through its admin
+// and debug RPC APIs.
+func (s *Suite) nodeResources() (*nodeResources, error) {
+	var peers []json.RawMessage
+	if err := s.RPC.Call(&peers, "admin_peers"); err != nil {
+		return nil, err
+	}
+	var stacks string
+	if err := s.RPC.Call(&stacks, "debug_stacks"); err != nil {
+		return nil, err
+	}
+	return &nodeResources{
+		peers:      len(peers),
+		goroutines: strings.Count("\n"+stacks, "\ngoroutine "),
+	}, nil
+}
+
+// checkLeaks waits for the peers and goroutines of the node to drop back to the
+// given amounts, failing the test if they don't within the timeout.
+func (s *Suite) checkLeaks(t *utesting.T, before *nodeResources) {
+	var (
+		now      *nodeResources
+		err      error
+		deadline = time.Now().Add(timeout)
+	)
+	for time.Now().Before(deadline) {
+		if now, err = s.nodeResources(); err != nil {
+			t.Fatalf("could not query node resources: %v", err)
+		}
+		if now.peers <= before.peers && now.goroutines <= before.goroutines+reorgGoroutineSlack {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if now.peers > before.peers {
+		t.Fatalf("peers leaked: have %d, had %d before the test", now.peers, before.peers)
+	}
+	t.Fatalf("goroutines leaked: have %d, had %d before the test", now.goroutines, before.goroutines)
+}
+
+// announceHeavier announces the blocks of the chain starting at the given
+// length one by one, until the chain is heavier than the given total
+// difficulty. It then waits for the node to make it canonical, returning the
+// announced length of the chain.
+func (s *Suite) announceHeavier(t *utesting.T, conn *Conn, chain *Chain, length int, td *big.Int) int {
+	for chain.TD(length).Cmp(td) <= 0 {
+		if length >= chain.Len() {
+			t.Fatalf("chain too short to outweigh TD %v", td)
+		}
+		block := chain.blocks[length]
+		length++
+
+		if err := conn.Write(&NewBlock{Block: block, TD: chain.TD(length)}); err != nil {
+			t.Fatalf("could not write to connection: %v", err)
+		}
+		if err := conn.waitForHeader(chain, block); err != nil {
+			t.Fatalf("block %d not imported: %v", block.NumberU64(), err)
+		}
+	}
+	head := chain.Shorten(length)
+	if err := conn.waitForCanonical(head, head.Head()); err != nil {
+		t.Fatalf("node didn't converge on the heaviest chain: %v", err)
+	}
+	return length
+}
+
+// waitForHeader waits until the node serves the header of the given block,
+// serving the requests of the node from the chain meanwhile.
+func (c *Conn) waitForHeader(chain *Chain, block *types.Block) error {
+	req := &GetBlockHeaders{Origin: eth.HashOrNumber{Hash: block.Hash()}, Amount: 1}
+	return c.waitForHeaders(chain, req, func(headers BlockHeaders) bool {
+		return len(headers) == 1
+	})
+}
+
+// waitForCanonical waits until the given block is the head of the canonical
+// chain of the node, serving the requests of the node from the chain meanwhile.
+func (c *Conn) waitForCanonical(chain *Chain, head *types.Block) error {
+	req := &GetBlockHeaders{Origin: eth.HashOrNumber{Number: head.NumberU64()}, Amount: 2}
+	return c.waitForHeaders(chain, req, func(headers BlockHeaders) bool {
+		return len(headers) == 1 && headers[0].Hash() == head.Hash()
+	})
+}
+
+// waitForHeaders repeats the header request until the reply satisfies the given
+// condition, serving the requests of the node from the chain meanwhile.
+func (c *Conn) waitForHeaders(chain *Chain, req *GetBlockHeaders, done func(BlockHeaders) bool) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := c.Write(req); err != nil {
+			return err
+		}
+		switch msg := c.readAndServeChain(chain, time.Until(deadline)).(type) {
+		case *BlockHeaders:
+			if done(*msg) {
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		default:
+			return fmt.Errorf("invalid message: %s", pretty.Sdump(msg))
+		}
+	}
+	return fmt.Errorf("no matching headers within %v", timeout)
+}
+
+// readAndServeChain serves the header and body requests of the node from the
+// chain while waiting on another message, discarding any announcements.
+func (c *Conn) readAndServeChain(chain *Chain, timeout time.Duration) Message {
+	defer c.SetReadDeadline(time.Time{})
+	c.SetReadDeadline(time.Now().Add(timeout))
+
+	for {
+		var err error
+		switch msg := c.Read().(type) {
+		case *Ping:
+			err = c.Write(&Pong{})
+		case *GetBlockHeaders:
+			err = c.Write(serveHeaders(chain, msg))
+		case *GetBlockBodies:
+			err = c.Write(serveBodies(chain, msg))
+		case *NewBlock, *NewBlockHashes, *Transactions, *NewPooledTransactionHashes:
+		default:
+			return msg
+		}
+		if err != nil {
+			return errorf("could not write to connection: %v", err)
+		}
+	}
+}
+
+// serveHeaders answers a header request from the chain, returning fewer headers
+// than requested past the ends of the chain.
+func serveHeaders(chain *Chain, req *GetBlockHeaders) *BlockHeaders {
+	headers := BlockHeaders{}
+
+	number, found := req.Origin.Number, req.Origin.Number < uint64(chain.Len())
+	if req.Origin.Hash != (common.Hash{}) {
+		found = false
+		for _, block := range chain.blocks {
+			if block.Hash() == req.Origin.Hash {
+				number, found = block.NumberU64(), true
+				break
+			}
+		}
+	}
+	step := req.Skip + 1
+	for found && uint64(len(headers)) < req.Amount {
+		headers = append(headers, chain.blocks[number].Header())
+		if req.Reverse {
+			found = number >= step
+			number -= step
+		} else {
+			number += step
+			found = number < uint64(chain.Len())
+		}
+	}
+	return &headers
+}
+
+// serveBodies answers a body request from the chain, skipping unknown blocks.
+func serveBodies(chain *Chain, req *GetBlockBodies) *BlockBodies {
+	bodies := BlockBodies{}
+	for _, hash := range *req {
+		for _, block := range chain.blocks {
+			if block.Hash() == hash {
+				bodies = append(bodies, &eth.BlockBody{Transactions: block.Transactions(), Uncles: block.Uncles()})
+				break
+			}
+		}
+	}
+	return &bodies
+}
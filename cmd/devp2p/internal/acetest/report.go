@@ -0,0 +1,206 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/internal/utesting"
+)
+
+// Report is the timing and traffic report of a test suite run. It's meant to be
+// stored as a JSON artifact, so conformance runs can be compared across client
+// versions.
+type Report struct {
+	Node   string       `json:"node"`             // Node URL of the tested node
+	Client string       `json:"client,omitempty"` // Client identifier of the tested node
+	Caps   []string     `json:"caps,omitempty"`   // Protocol capabilities of the tested node
+	Start  time.Time    `json:"start"`            // Start time of the first test
+	Tests  []TestReport `json:"tests"`
+}
+
+// TestReport is the timing and traffic report of a single test.
+type TestReport struct {
+	Name       string  `json:"name"`
+	Failed     bool    `json:"failed"`
+	DurationMs float64 `json:"durationMs"`
+
+	MsgsSent  int `json:"messagesSent"`
+	MsgsRecv  int `json:"messagesReceived"`
+	BytesSent int `json:"bytesSent"` // Wire size of the sent messages
+	BytesRecv int `json:"bytesReceived"`
+
+	// Round trips are measured from the first message written after a read
+	// until the next message read on the same connection.
+	RoundTrips  int     `json:"roundTrips"`
+	LatencyMin  float64 `json:"latencyMinMs"`
+	LatencyMean float64 `json:"latencyMeanMs"`
+	LatencyMax  float64 `json:"latencyMaxMs"`
+}
+
+// testStats collects the traffic of the connections dialed during a test.
+type testStats struct {
+	lock      sync.Mutex
+	msgsSent  int
+	msgsRecv  int
+	bytesSent int
+	bytesRecv int
+	latencies []time.Duration
+}
+
+// connStats tracks the round trips of a single connection.
+type connStats struct {
+	*testStats
+	lock    sync.Mutex
+	written time.Time // Time of the first write since the last read, zero if none
+}
+
+// sent records a message written to the connection.
+func (c *connStats) sent(size int) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	if c.written.IsZero() {
+		c.written = time.Now()
+	}
+	c.lock.Unlock()
+
+	c.testStats.lock.Lock()
+	defer c.testStats.lock.Unlock()
+	c.msgsSent++
+	c.bytesSent += size
+}
+
+// received records a message read from the connection.
+func (c *connStats) received(size int) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	written := c.written
+	c.written = time.Time{}
+	c.lock.Unlock()
+
+	c.testStats.lock.Lock()
+	defer c.testStats.lock.Unlock()
+	c.msgsRecv++
+	c.bytesRecv += size
+	if !written.IsZero() {
+		c.latencies = append(c.latencies, time.Since(written))
+	}
+}
+
+// report summarises the collected stats into the report of a test.
+func (s *testStats) report(name string, failed bool, duration time.Duration) TestReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := TestReport{
+		Name:       name,
+		Failed:     failed,
+		DurationMs: milliseconds(duration),
+		MsgsSent:   s.msgsSent,
+		MsgsRecv:   s.msgsRecv,
+		BytesSent:  s.bytesSent,
+		BytesRecv:  s.bytesRecv,
+		RoundTrips: len(s.latencies),
+	}
+	if len(s.latencies) > 0 {
+		var total, min, max time.Duration
+		for i, latency := range s.latencies {
+			total += latency
+			if i == 0 || latency < min {
+				min = latency
+			}
+			if latency > max {
+				max = latency
+			}
+		}
+		r.LatencyMin = milliseconds(min)
+		r.LatencyMean = milliseconds(total / time.Duration(len(s.latencies)))
+		r.LatencyMax = milliseconds(max)
+	}
+	return r
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Measure wraps the tests to record their timing and traffic into the report of
+// the suite.
+func (s *Suite) Measure(tests []utesting.Test) []utesting.Test {
+	measured := make([]utesting.Test, len(tests))
+	for i, test := range tests {
+		test := test
+		measured[i] = utesting.Test{Name: test.Name, Fn: func(t *utesting.T) {
+			stats := new(testStats)
+			s.setStats(stats)
+
+			start := time.Now()
+			defer func() {
+				s.setStats(nil)
+				s.addReport(start, stats.report(test.Name, t.Failed(), time.Since(start)))
+			}()
+			test.Fn(t)
+		}}
+	}
+	return measured
+}
+
+// Report returns the report of the measured tests run so far.
+func (s *Suite) Report() *Report {
+	s.reportLock.Lock()
+	defer s.reportLock.Unlock()
+
+	report := &Report{Start: s.reportStart, Tests: make([]TestReport, len(s.reports))}
+	if s.Dest != nil {
+		report.Node = s.Dest.URLv4()
+	}
+	copy(report.Tests, s.reports)
+	return report
+}
+
+func (s *Suite) setStats(stats *testStats) {
+	s.reportLock.Lock()
+	defer s.reportLock.Unlock()
+	s.stats = stats
+}
+
+func (s *Suite) addReport(start time.Time, report TestReport) {
+	s.reportLock.Lock()
+	defer s.reportLock.Unlock()
+
+	if len(s.reports) == 0 {
+		s.reportStart = start
+	}
+	s.reports = append(s.reports, report)
+}
+
+// newConnStats returns the stats tracker for a connection dialed by the running
+// test, or nil if the test isn't measured.
+func (s *Suite) newConnStats() *connStats {
+	s.reportLock.Lock()
+	defer s.reportLock.Unlock()
+
+	if s.stats == nil {
+		return nil
+	}
+	return &connStats{testStats: s.stats}
+}
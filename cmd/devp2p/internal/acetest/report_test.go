@@ -0,0 +1,93 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"testing"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/internal/utesting"
+	"github.com/acent/go-acent/p2p"
+)
+
+// Tests that the measured tests report their outcome, timing and traffic.
+func TestMeasure(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    10,
+		ListenAddr:  "127.0.0.1:0",
+		NoDiscovery: true,
+		Name:        "test",
+	}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start test node: %v", err)
+	}
+	defer server.Stop()
+
+	suite := &Suite{Dest: server.Self()}
+	tests := suite.Measure([]utesting.Test{
+		{Name: "Handshake", Fn: func(t *utesting.T) {
+			conn, err := suite.dial()
+			if err != nil {
+				t.Fatalf("could not dial: %v", err)
+			}
+			defer conn.Close()
+
+			pub0 := crypto.FromECDSAPub(&conn.ourKey.PublicKey)[1:]
+			if err := conn.Write(&Hello{Version: 5, Caps: conn.caps, ID: pub0}); err != nil {
+				t.Fatalf("could not write hello: %v", err)
+			}
+			if msg, ok := conn.Read().(*Hello); !ok {
+				t.Fatalf("unexpected message: %v", msg)
+			}
+		}},
+		{Name: "Fail", Fn: func(t *utesting.T) {
+			t.Fatal("failed")
+		}},
+	})
+	if results := utesting.RunTests(tests, nil); utesting.CountFailures(results) != 1 {
+		t.Fatalf("failure count mismatch: have %d, want 1", utesting.CountFailures(results))
+	}
+	report := suite.Report()
+	if report.Node != server.Self().URLv4() {
+		t.Errorf("node mismatch: have %s, want %s", report.Node, server.Self().URLv4())
+	}
+	if report.Start.IsZero() {
+		t.Errorf("missing start time")
+	}
+	if len(report.Tests) != 2 {
+		t.Fatalf("test report count mismatch: have %d, want 2", len(report.Tests))
+	}
+	handshake := report.Tests[0]
+	if handshake.Name != "Handshake" || handshake.Failed {
+		t.Errorf("handshake outcome mismatch: %+v", handshake)
+	}
+	if handshake.MsgsSent != 1 || handshake.MsgsRecv != 1 || handshake.BytesSent == 0 || handshake.BytesRecv == 0 {
+		t.Errorf("handshake traffic mismatch: %+v", handshake)
+	}
+	if handshake.RoundTrips != 1 || handshake.LatencyMin <= 0 || handshake.LatencyMin != handshake.LatencyMax {
+		t.Errorf("handshake latency mismatch: %+v", handshake)
+	}
+	if handshake.DurationMs < handshake.LatencyMax {
+		t.Errorf("handshake duration %v shorter than its latency %v", handshake.DurationMs, handshake.LatencyMax)
+	}
+	fail := report.Tests[1]
+	if fail.Name != "Fail" || !fail.Failed || fail.MsgsSent != 0 || fail.RoundTrips != 0 {
+		t.Errorf("failed test report mismatch: %+v", fail)
+	}
+}
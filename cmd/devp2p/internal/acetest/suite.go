@@ -19,7 +19,9 @@ package ethtest
 import (
 	"fmt"
 	"net"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -30,6 +32,7 @@ import (
 	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/p2p/rlpx"
+	"github.com/acent/go-acent/rpc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,6 +52,16 @@ type Suite struct {
 
 	chain     *Chain
 	fullChain *Chain
+	dataDir   string // Directory of the chain file, holding the reorg test branches
+
+	// RPC is the optional RPC client of the node, with the admin and debug APIs
+	// enabled. Tests check the node for leaked peers and goroutines through it.
+	RPC *rpc.Client
+
+	stats       *testStats   // Traffic of the running measured test, nil if none
+	reports     []TestReport // Reports of the measured tests run so far
+	reportStart time.Time
+	reportLock  sync.Mutex
 }
 
 // NewSuite creates and returns a new eth-test suite that can
@@ -63,6 +76,7 @@ func NewSuite(dest *enode.Node, chainfile string, genesisfile string) (*Suite, e
 		Dest:      dest,
 		chain:     chain.Shorten(1000),
 		fullChain: chain,
+		dataDir:   filepath.Dir(chainfile),
 	}, nil
 }
 
@@ -97,6 +111,8 @@ func (s *Suite) AllEthTests() []utesting.Test {
 		{Name: "TestTransactions_66", Fn: s.TestTransaction_66},
 		{Name: "TestMaliciousTransactions", Fn: s.TestMaliciousTx},
 		{Name: "TestMaliciousTransactions_66", Fn: s.TestMaliciousTx_66},
+		// chain reorgs
+		{Name: "TestChainReorg", Fn: s.TestChainReorg},
 	}
 }
 
@@ -112,6 +128,7 @@ func (s *Suite) EthTests() []utesting.Test {
 		{Name: "TestMaliciousStatus_66", Fn: s.TestMaliciousStatus},
 		{Name: "TestTransactions", Fn: s.TestTransaction},
 		{Name: "TestMaliciousTransactions", Fn: s.TestMaliciousTx},
+		{Name: "TestChainReorg", Fn: s.TestChainReorg},
 	}
 }
 
@@ -475,6 +492,7 @@ func (s *Suite) dial() (*Conn, error) {
 		return nil, err
 	}
 	conn.Conn = rlpx.NewConn(fd, s.Dest.Pubkey())
+	conn.stats = s.newConnStats()
 	// do encHandshake
 	conn.ourKey, _ = crypto.GenerateKey()
 	_, err = conn.Handshake(conn.ourKey)
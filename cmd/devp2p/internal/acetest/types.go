@@ -127,13 +127,15 @@ type Conn struct {
 	negotiatedProtoVersion uint
 	ourHighestProtoVersion uint
 	caps                   []p2p.Cap
+	stats                  *connStats // Traffic of the measured test, nil if not measured
 }
 
 func (c *Conn) Read() Message {
-	code, rawData, _, err := c.Conn.Read()
+	code, rawData, wireSize, err := c.Conn.Read()
 	if err != nil {
 		return errorf("could not read from connection: %v", err)
 	}
+	c.stats.received(wireSize)
 
 	var msg Message
 	switch int(code) {
@@ -210,7 +212,10 @@ func (c *Conn) Write(msg Message) error {
 	if err != nil {
 		return err
 	}
-	_, err = c.Conn.Write(uint64(msg.Code()), payload)
+	size, err := c.Conn.Write(uint64(msg.Code()), payload)
+	if err == nil {
+		c.stats.sent(int(size))
+	}
 	return err
 }
 
@@ -31,6 +31,7 @@ var (
 		Usage: "Operations on node keys",
 		Subcommands: []cli.Command{
 			keyGenerateCommand,
+			keyInspectCommand,
 			keyToNodeCommand,
 		},
 	}
@@ -40,6 +41,12 @@ var (
 		ArgsUsage: "keyfile",
 		Action:    genkey,
 	}
+	keyInspectCommand = cli.Command{
+		Name:      "inspect",
+		Usage:     "Prints the node ID and public key of a node key file",
+		ArgsUsage: "keyfile",
+		Action:    inspectKey,
+	}
 	keyToNodeCommand = cli.Command{
 		Name:      "to-enode",
 		Usage:     "Creates an enode URL from a node key file",
@@ -80,6 +87,19 @@ func genkey(ctx *cli.Context) error {
 	return crypto.SaveECDSA(file, key)
 }
 
+func inspectKey(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need key file as argument")
+	}
+	key, err := crypto.LoadECDSA(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Node ID:    %v\n", enode.PubkeyToIDV4(&key.PublicKey))
+	fmt.Printf("Public key: %x\n", crypto.FromECDSAPub(&key.PublicKey)[1:])
+	return nil
+}
+
 func keyToURL(ctx *cli.Context) error {
 	if ctx.NArg() != 1 {
 		return fmt.Errorf("need key file as argument")
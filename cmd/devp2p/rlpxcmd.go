@@ -17,8 +17,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 
 	"github.com/acent/go-acent/cmd/devp2p/internal/ethtest"
 	"github.com/acent/go-acent/crypto"
@@ -26,6 +29,7 @@ import (
 	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/p2p/rlpx"
 	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -35,6 +39,7 @@ var (
 		Usage: "RLPx Commands",
 		Subcommands: []cli.Command{
 			rlpxPingCommand,
+			rlpxProbeCommand,
 			rlpxEthTestCommand,
 		},
 	}
@@ -43,6 +48,12 @@ var (
 		Usage:  "ping <node>",
 		Action: rlpxPing,
 	}
+	rlpxProbeCommand = cli.Command{
+		Name:      "probe",
+		Usage:     "Reports the protocol versions supported by a node",
+		ArgsUsage: "<node>",
+		Action:    rlpxProbe,
+	}
 	rlpxEthTestCommand = cli.Command{
 		Name:      "eth-test",
 		Usage:     "Runs tests against a node",
@@ -51,6 +62,8 @@ var (
 		Flags: []cli.Flag{
 			testPatternFlag,
 			testTAPFlag,
+			testReportFlag,
+			testNodeRPCFlag,
 		},
 	}
 )
@@ -90,19 +103,71 @@ func rlpxPing(ctx *cli.Context) error {
 	return nil
 }
 
-// rlpxEthTest runs the eth protocol test suite.
+// rlpxProbe prints the capability report of a node.
+func rlpxProbe(ctx *cli.Context) error {
+	caps, err := ethtest.Probe(getNodeArg(ctx))
+	if err != nil {
+		return err
+	}
+	fmt.Print(caps)
+	return nil
+}
+
+// rlpxEthTest runs the eth protocol test suite, selecting the tests by the
+// protocol versions the node supports.
 func rlpxEthTest(ctx *cli.Context) error {
 	if ctx.NArg() < 3 {
 		exit("missing path to chain.rlp as command-line argument")
 	}
-	suite, err := ethtest.NewSuite(getNodeArg(ctx), ctx.Args()[1], ctx.Args()[2])
+	node := getNodeArg(ctx)
+	suite, err := ethtest.NewSuite(node, ctx.Args()[1], ctx.Args()[2])
 	if err != nil {
 		exit(err)
 	}
-	// check if given node supports eth66, and if so, run eth66 protocol tests as well
-	is66Failed, _ := utesting.Run(utesting.Test{Name: "Is_66", Fn: suite.Is_66})
-	if is66Failed {
-		return runTests(ctx, suite.EthTests())
+	if ctx.IsSet(testNodeRPCFlag.Name) {
+		client, err := rpc.Dial(ctx.String(testNodeRPCFlag.Name))
+		if err != nil {
+			exit(fmt.Errorf("can't connect to node RPC: %v", err))
+		}
+		defer client.Close()
+		suite.RPC = client
+	}
+	caps, err := ethtest.Probe(node)
+	if err != nil {
+		exit(fmt.Errorf("can't probe node: %v", err))
+	}
+	fmt.Fprint(os.Stderr, caps) // Keep stdout clean for TAP output
+
+	tests, err := suite.TestsFor(caps)
+	if err != nil {
+		exit(err)
+	}
+	if !ctx.IsSet(testReportFlag.Name) {
+		return runTests(ctx, tests)
+	}
+	results := executeTests(ctx, suite.Measure(tests))
+
+	report := suite.Report()
+	report.Client = caps.Name
+	for _, cap := range caps.Caps {
+		report.Caps = append(report.Caps, cap.String())
+	}
+	writeReportJSON(ctx.String(testReportFlag.Name), report)
+
+	if utesting.CountFailures(results) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// writeReportJSON writes the eth test report to the given file. Stdout is kept
+// clean for the test output.
+func writeReportJSON(file string, report *ethtest.Report) {
+	reportJSON, err := json.MarshalIndent(report, "", jsonIndent)
+	if err != nil {
+		exit(err)
+	}
+	if err := ioutil.WriteFile(file, reportJSON, 0644); err != nil {
+		exit(err)
 	}
-	return runTests(ctx, suite.AllEthTests())
 }
@@ -34,6 +34,15 @@ var (
 		Name:  "tap",
 		Usage: "Output TAP",
 	}
+	// These two are specific to the eth protocol tests.
+	testReportFlag = cli.StringFlag{
+		Name:  "report",
+		Usage: "Write a JSON report of the test timings and message counts to the given file",
+	}
+	testNodeRPCFlag = cli.StringFlag{
+		Name:  "node-rpc",
+		Usage: "RPC endpoint of the node with the admin and debug APIs, enabling its leak checks",
+	}
 	// These two are specific to the discovery tests.
 	testListen1Flag = cli.StringFlag{
 		Name:  "listen1",
@@ -48,6 +57,16 @@ var (
 )
 
 func runTests(ctx *cli.Context, tests []utesting.Test) error {
+	results := executeTests(ctx, tests)
+	if utesting.CountFailures(results) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// executeTests runs the tests selected by the command line flags and returns
+// their results.
+func executeTests(ctx *cli.Context, tests []utesting.Test) []utesting.Result {
 	// Filter test cases.
 	if ctx.IsSet(testPatternFlag.Name) {
 		tests = utesting.MatchTests(tests, ctx.String(testPatternFlag.Name))
@@ -61,9 +80,5 @@ func runTests(ctx *cli.Context, tests []utesting.Test) error {
 	if ctx.Bool(testTAPFlag.Name) {
 		run = utesting.RunTAP
 	}
-	results := run(tests, os.Stdout)
-	if utesting.CountFailures(results) > 0 {
-		os.Exit(1)
-	}
-	return nil
+	return run(tests, os.Stdout)
 }
@@ -19,9 +19,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -31,8 +34,11 @@ import (
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/internal/era"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/metrics"
+	"github.com/acent/go-acent/params"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -52,6 +58,21 @@ This is a destructive action and changes the network in which you will be
 participating.
 
 It expects the genesis file as argument.`,
+	}
+	validateConfigCommand = cli.Command{
+		Action:    utils.MigrateFlags(validateConfig),
+		Name:      "validate-config",
+		Usage:     "Validate the chain configuration of a genesis file or database",
+		ArgsUsage: "[<genesisPath>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The validate-config command checks the chain configuration of the given genesis
+JSON file, or of the chain in the data directory if no file is given. Every
+misordered fork, inconsistent setting and consensus engine conflict is reported
+along with an error code and a suggested fix.`,
 	}
 	dumpGenesisCommand = cli.Command{
 		Action:    utils.MigrateFlags(dumpGenesis),
@@ -100,7 +121,10 @@ The import command imports blocks from an RLP-encoded form. The form can be one
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used,
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+A directory is imported as a segmented archive written by export --archive, the checksum
+of every segment being verified before importing it.`,
 	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
@@ -111,6 +135,10 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			exportListFlag,
+			exportArchiveFlag,
+			exportArchiveSegmentFlag,
+			exportArchiveGzipFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -118,7 +146,34 @@ Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
-be gzipped.`,
+be gzipped.
+
+With --list, the blocks are written as a single RLP list, streamed
+without holding the chain in memory. The file is truncated in this
+mode. The import command accepts both formats.
+
+With --archive, the first argument is a directory into which the
+blocks are written as a segmented archive: fixed size ranges of
+blocks stored as lists in separate files, optionally gzipped,
+indexed with their checksums in index.json. The segments can be
+distributed and verified independently.`,
+	}
+	exportListFlag = cli.BoolFlag{
+		Name:  "list",
+		Usage: "Export the blocks as a single RLP list instead of a sequence of blocks",
+	}
+	exportArchiveFlag = cli.BoolFlag{
+		Name:  "archive",
+		Usage: "Export the blocks into a directory as a segmented archive",
+	}
+	exportArchiveSegmentFlag = cli.Uint64Flag{
+		Name:  "archive.segment",
+		Usage: "Number of blocks of every segment of the archive",
+		Value: era.DefaultSegmentSize,
+	}
+	exportArchiveGzipFlag = cli.BoolFlag{
+		Name:  "archive.gzip",
+		Usage: "Compress the segments of the archive with gzip",
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -167,6 +222,26 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 The arguments are interpreted as block numbers or hashes.
 Use "acent dump 0" to dump the genesis block.`,
 	}
+	indexBloomsCommand = cli.Command{
+		Action:    utils.MigrateFlags(indexBlooms),
+		Name:      "index-blooms",
+		Usage:     "Rebuild the bloombits log index over a range of blocks",
+		ArgsUsage: "[<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.BloomBitsBlocksFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The index-blooms command regenerates the bloombits index sections fully contained
+in the given block range (the entire chain by default) from the block headers,
+using the section size of --bloombits.blocks. Sections continuing the existing
+index are adopted by it, others are used by log filtering to skip over the blocks
+not yet indexed. Changing the section size drops the existing index first.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -183,8 +258,8 @@ func initGenesis(ctx *cli.Context) error {
 	}
 	defer file.Close()
 
-	genesis := new(core.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+	genesis, err := decodeGenesis(file)
+	if err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
 	// Open and initialise both full and light databases
@@ -206,6 +281,91 @@ func initGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// decodeGenesis parses a genesis JSON, pinpointing the location of syntax and
+// type errors in the input.
+func decodeGenesis(r io.Reader) (*core.Genesis, error) {
+	blob, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(blob, genesis); err != nil {
+		position := func(offset int64) string {
+			line, col := 1, 1
+			for _, c := range blob[:offset] {
+				if c == '\n' {
+					line, col = line+1, 1
+				} else {
+					col++
+				}
+			}
+			return fmt.Sprintf("line %d, column %d", line, col)
+		}
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			return nil, fmt.Errorf("%v at %s", err, position(err.Offset))
+		case *json.UnmarshalTypeError:
+			return nil, fmt.Errorf("%v at %s", err, position(err.Offset))
+		}
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// validateConfig checks the chain config of a genesis file or of the local chain,
+// reporting all problems found.
+func validateConfig(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		utils.Fatalf("This command requires at most one argument.")
+	}
+	var config *params.ChainConfig
+	if path := ctx.Args().First(); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			utils.Fatalf("Failed to read genesis file: %v", err)
+		}
+		genesis, err := decodeGenesis(file)
+		file.Close()
+		if err != nil {
+			utils.Fatalf("Invalid genesis file: %v", err)
+		}
+		if config = genesis.Config; config == nil {
+			utils.Fatalf("Genesis file has no chain config")
+		}
+	} else {
+		stack, _ := makeConfigNode(ctx)
+		defer stack.Close()
+
+		db := utils.MakeChainDatabase(ctx, stack, true)
+		if config = rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0)); config == nil {
+			utils.Fatalf("No chain config found in the database")
+		}
+	}
+	var problems params.ConfigErrors
+	if err := config.Validate(); err != nil {
+		problems = err.(params.ConfigErrors)
+	}
+	if err := vm.ValidateEVMForks(config); err != nil {
+		problems = append(problems, &params.ConfigError{
+			Code:       params.ConfigErrEVMFork,
+			Field:      "evmForks",
+			Message:    err.Error(),
+			Suggestion: "only use known opcodes and activatable EIPs (" + strings.Join(vm.ActivateableEips(), ", ") + ")",
+		})
+	}
+	if len(problems) == 0 {
+		fmt.Println("Chain config is valid")
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Printf("%-16s %s: %s\n", problem.Code, problem.Field, problem.Message)
+		if problem.Suggestion != "" {
+			fmt.Printf("%-16s suggestion: %s\n", "", problem.Suggestion)
+		}
+	}
+	return fmt.Errorf("chain config has %d problem(s)", len(problems))
+}
+
 func dumpGenesis(ctx *cli.Context) error {
 	// TODO(rjl493456442) support loading from the custom datadir
 	genesis := utils.MakeGenesis(ctx)
@@ -254,13 +414,13 @@ func importChain(ctx *cli.Context) error {
 	var importErr error
 
 	if len(ctx.Args()) == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := importPath(chain, ctx.Args().First()); err != nil {
 			importErr = err
 			log.Error("Import error", "err", err)
 		}
 	} else {
 		for _, arg := range ctx.Args() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := importPath(chain, arg); err != nil {
 				importErr = err
 				log.Error("Import error", "file", arg, "err", err)
 			}
@@ -310,8 +470,23 @@ func exportChain(ctx *cli.Context) error {
 
 	var err error
 	fp := ctx.Args().First()
-	if len(ctx.Args()) < 3 {
-		err = utils.ExportChain(chain, fp)
+	if ctx.Bool(exportArchiveFlag.Name) {
+		first, last := uint64(0), chain.CurrentBlock().NumberU64()
+		if len(ctx.Args()) >= 3 {
+			if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+				utils.Fatalf("Export error in parsing parameters: invalid first block: %v\n", err)
+			}
+			if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+				utils.Fatalf("Export error in parsing parameters: invalid last block: %v\n", err)
+			}
+		}
+		err = utils.ExportArchive(chain, fp, first, last, ctx.Uint64(exportArchiveSegmentFlag.Name), ctx.Bool(exportArchiveGzipFlag.Name))
+	} else if len(ctx.Args()) < 3 {
+		if ctx.Bool(exportListFlag.Name) {
+			err = utils.ExportChainList(chain, fp, 0, chain.CurrentBlock().NumberU64())
+		} else {
+			err = utils.ExportChain(chain, fp)
+		}
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
 		first, ferr := strconv.ParseInt(ctx.Args().Get(1), 10, 64)
@@ -325,7 +500,11 @@ func exportChain(ctx *cli.Context) error {
 		if head := chain.CurrentFastBlock(); uint64(last) > head.NumberU64() {
 			utils.Fatalf("Export error: block number %d larger than head block %d\n", uint64(last), head.NumberU64())
 		}
-		err = utils.ExportAppendChain(chain, fp, uint64(first), uint64(last))
+		if ctx.Bool(exportListFlag.Name) {
+			err = utils.ExportChainList(chain, fp, uint64(first), uint64(last))
+		} else {
+			err = utils.ExportAppendChain(chain, fp, uint64(first), uint64(last))
+		}
 	}
 
 	if err != nil {
@@ -335,6 +514,14 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// importPath imports a chain file, or a segmented archive if the path is a directory.
+func importPath(chain *core.BlockChain, path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return utils.ImportArchive(chain, path)
+	}
+	return utils.ImportChain(chain, path)
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -423,3 +610,57 @@ func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
 	return err != nil
 }
+
+// indexBlooms rebuilds the bloombits index sections within a block range.
+func indexBlooms(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 && len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires either no or two arguments.")
+	}
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+
+	size := cfg.Eth.BloomBitsBlocks
+	if size == 0 || size%8 != 0 {
+		utils.Fatalf("Invalid bloom bits section size %d, must be a non-zero multiple of 8", size)
+	}
+	head := rawdb.ReadHeadHeaderHash(db)
+	number := rawdb.ReadHeaderNumber(db, head)
+	if number == nil {
+		utils.Fatalf("Failed to load head header")
+	}
+	first, last := uint64(0), *number
+	if len(ctx.Args()) == 2 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Failed to parse block range: block number not an integer")
+		}
+	}
+	firstSection := (first + size - 1) / size
+	lastSection := (last + 1) / size
+	// Only sections past the reorg confirmation distance are indexed
+	if *number+1 < params.BloomConfirms {
+		lastSection = 0
+	} else if limit := (*number + 1 - params.BloomConfirms) / size; lastSection > limit {
+		lastSection = limit
+	}
+	if lastSection <= firstSection {
+		utils.Fatalf("No complete section within blocks #%d-#%d", first, last)
+	}
+	core.SetBloomBitsSize(db, size)
+
+	start := time.Now()
+	log.Info("Rebuilding bloom bits", "size", size, "first", firstSection, "last", lastSection-1)
+	err := core.RebuildBloomBits(db, size, firstSection, lastSection-1, func(section uint64) {
+		log.Info("Rebuilt bloom bits section", "section", section, "blocks", (section+1)*size, "elapsed", common.PrettyDuration(time.Since(start)))
+	})
+	if err != nil {
+		utils.Fatalf("Failed to rebuild bloom bits: %v", err)
+	}
+	sections, extFirst, extCount := core.BloomBitsStatus(db)
+	log.Info("Rebuilt bloom bits", "indexed", sections, "extentFirst", extFirst, "extentSections", extCount, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
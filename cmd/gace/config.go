@@ -20,7 +20,6 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"math/big"
 	"os"
 	"reflect"
 	"unicode"
@@ -29,6 +28,7 @@ import (
 
 	"github.com/acent/go-acent/cmd/utils"
 	"github.com/acent/go-acent/eth/ethconfig"
+	"github.com/acent/go-acent/ethstats"
 	"github.com/acent/go-acent/internal/ethapi"
 	"github.com/acent/go-acent/metrics"
 	"github.com/acent/go-acent/node"
@@ -70,14 +70,10 @@ var tomlSettings = toml.Config{
 	},
 }
 
-type ethstatsConfig struct {
-	URL string `toml:",omitempty"`
-}
-
 type gethConfig struct {
 	Eth      ethconfig.Config
 	Node     node.Config
-	Ethstats ethstatsConfig
+	Ethstats ethstats.Config
 	Metrics  metrics.Config
 }
 
@@ -132,6 +128,18 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	if ctx.GlobalIsSet(utils.EthStatsURLFlag.Name) {
 		cfg.Ethstats.URL = ctx.GlobalString(utils.EthStatsURLFlag.Name)
 	}
+	if ctx.GlobalIsSet(utils.EthStatsTLSCertFlag.Name) {
+		cfg.Ethstats.TLSCert = ctx.GlobalString(utils.EthStatsTLSCertFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.EthStatsTLSKeyFlag.Name) {
+		cfg.Ethstats.TLSKey = ctx.GlobalString(utils.EthStatsTLSKeyFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.EthStatsTLSCAFlag.Name) {
+		cfg.Ethstats.TLSCA = ctx.GlobalString(utils.EthStatsTLSCAFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.EthStatsMetricsFlag.Name) {
+		cfg.Ethstats.Metrics = utils.SplitAndTrim(ctx.GlobalString(utils.EthStatsMetricsFlag.Name))
+	}
 	applyMetricConfig(ctx, &cfg)
 
 	return stack, cfg
@@ -140,10 +148,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 // makeFullNode loads geth configuration and creates the Acent backend.
 func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 	stack, cfg := makeConfigNode(ctx)
-	if ctx.GlobalIsSet(utils.OverrideBerlinFlag.Name) {
-		cfg.Eth.OverrideBerlin = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideBerlinFlag.Name))
-	}
-	backend := utils.RegisterEthService(stack, &cfg.Eth)
+	backend, service := utils.RegisterEthService(stack, &cfg.Eth)
 
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
@@ -151,7 +156,7 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 	}
 	// Add the Acent Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
-		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
+		utils.RegisterEthStatsService(stack, service, backend, cfg.Ethstats)
 	}
 	return stack, backend
 }
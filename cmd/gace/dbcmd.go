@@ -27,8 +27,14 @@ import (
 	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/console/prompt"
 	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/ethdb/memorydb"
 	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -57,6 +63,7 @@ Remove blockchain and state databases`,
 			dbGetCmd,
 			dbDeleteCmd,
 			dbPutCmd,
+			dbInspectTrieCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -158,6 +165,47 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		Description: `This command sets a given database key to the given value. 
 WARNING: This is a low-level operation which may cause database corruption!`,
 	}
+	inspectTrieHealFlag = cli.BoolFlag{
+		Name:  "heal",
+		Usage: "Retrieve the missing and corrupted state entries from the network",
+	}
+	inspectTrieHealTimeoutFlag = cli.DurationFlag{
+		Name:  "heal.timeout",
+		Usage: "Maximum time to wait for the state entries to be healed",
+		Value: 10 * time.Minute,
+	}
+	dbInspectTrieCmd = cli.Command{
+		Action:    utils.MigrateFlags(inspectTrie),
+		Name:      "inspect-trie",
+		Usage:     "Inspect the state trie for missing or corrupted entries",
+		ArgsUsage: "[<root>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+			utils.NetworkIdFlag,
+			utils.BootnodesFlag,
+			utils.ListenPortFlag,
+			utils.MaxPeersFlag,
+			utils.NATFlag,
+			inspectTrieHealFlag,
+			inspectTrieHealTimeoutFlag,
+		},
+		Description: `This command walks the state trie at the given root (the head block's state
+by default), including all storage tries and contract codes, and reports every
+entry that is missing from the database or does not hash to its reference. The
+walk does not stop at the first damaged entry.
+
+With --heal, the command afterwards starts a node, deletes the corrupted entries
+and retrieves all damaged subtries from the connected peers, then inspects the
+state again. Note, like any node restart, this rewinds the chain if the head
+state itself is damaged.`,
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -380,3 +428,218 @@ func dbPut(ctx *cli.Context) error {
 	}
 	return db.Put(key, value)
 }
+
+// trieInspectStats are the counters collected during a state trie inspection.
+type trieInspectStats struct {
+	nodes    uint64 // Number of trie nodes inspected (account and storage)
+	accounts uint64 // Number of accounts inspected
+	slots    uint64 // Number of storage slots inspected
+	codes    uint64 // Number of contract codes inspected
+}
+
+// trieDefect is a damaged state entry found during a state trie inspection.
+type trieDefect struct {
+	hash    common.Hash   // Hash of the damaged trie node or contract code
+	path    trie.SyncPath // Path of the trie node, two items for storage tries
+	code    bool          // Whether the entry is a contract code
+	corrupt bool          // Whether the entry is present but corrupted
+}
+
+// inspectTrie inspects the state trie at the requested root, optionally healing
+// the damaged entries found from the network.
+func inspectTrie(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return fmt.Errorf("Max 1 argument: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	db := utils.MakeChainDatabase(ctx, stack, true)
+
+	var root common.Hash
+	if ctx.NArg() == 1 {
+		var err error
+		if root, err = parseRoot(ctx.Args().First()); err != nil {
+			db.Close()
+			stack.Close()
+			return fmt.Errorf("failed to resolve state root: %v", err)
+		}
+	} else {
+		head := rawdb.ReadHeadBlock(db)
+		if head == nil {
+			db.Close()
+			stack.Close()
+			return fmt.Errorf("no head block")
+		}
+		root = head.Root()
+	}
+	defects := reportTrie(db, root)
+	db.Close()
+	stack.Close()
+
+	if len(defects) == 0 {
+		return nil
+	}
+	if !ctx.Bool(inspectTrieHealFlag.Name) {
+		return fmt.Errorf("%d damaged state entries", len(defects))
+	}
+	return healTrie(ctx, root, defects)
+}
+
+// reportTrie inspects the state trie at the given root, logging the progress,
+// the damaged entries found and the final results.
+func reportTrie(db ethdb.KeyValueReader, root common.Hash) []trieDefect {
+	log.Info("Inspecting state trie", "root", root)
+
+	var (
+		stats = new(trieInspectStats)
+		start = time.Now()
+	)
+	defects := walkTrie(db, root, stats, func() {
+		log.Info("Inspecting state trie", "nodes", stats.nodes, "accounts", stats.accounts,
+			"slots", stats.slots, "codes", stats.codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	})
+	for _, defect := range defects {
+		ctx := []interface{}{"hash", defect.hash}
+		if len(defect.path) == 2 {
+			ctx = append(ctx, "owner", common.BytesToHash(defect.path[0]))
+		}
+		switch {
+		case defect.code && defect.corrupt:
+			log.Warn("Corrupted contract code", ctx...)
+		case defect.code:
+			log.Warn("Missing contract code", ctx...)
+		case defect.corrupt:
+			log.Warn("Corrupted trie node", ctx...)
+		default:
+			log.Warn("Missing trie node", ctx...)
+		}
+	}
+	log.Info("Inspected state trie", "root", root, "nodes", stats.nodes, "accounts", stats.accounts,
+		"slots", stats.slots, "codes", stats.codes, "damaged", len(defects), "elapsed", common.PrettyDuration(time.Since(start)))
+	return defects
+}
+
+// walkTrie walks the state trie at the given root, including all storage tries
+// and contract codes, and returns every entry missing from the database or not
+// hashing to its reference. The walk is driven by a trie sync scheduler over an
+// empty database fed from the local one, so it continues past damaged entries.
+func walkTrie(db ethdb.KeyValueReader, root common.Hash, stats *trieInspectStats, progress func()) []trieDefect {
+	var (
+		sched   *trie.Sync
+		defects []trieDefect
+		discard = memorydb.New().NewBatch()
+		logged  = time.Now()
+	)
+	onSlot := func(path []byte, leaf []byte, parent common.Hash) error {
+		stats.slots++
+		return nil
+	}
+	onAccount := func(path []byte, leaf []byte, parent common.Hash) error {
+		var acc state.Account
+		if err := rlp.DecodeBytes(leaf, &acc); err != nil {
+			return err
+		}
+		stats.accounts++
+		sched.AddSubTrie(acc.Root, path, parent, onSlot)
+		sched.AddCodeEntry(common.BytesToHash(acc.CodeHash), path, parent)
+		return nil
+	}
+	sched = trie.NewSync(root, memorydb.New(), onAccount, nil)
+
+	for sched.Pending() > 0 {
+		nodes, paths, codes := sched.Missing(1024)
+		if len(nodes)+len(codes) == 0 {
+			break // Only entries depending on damaged ones left
+		}
+		for i, hash := range nodes {
+			stats.nodes++
+			blob := rawdb.ReadTrieNode(db, hash)
+			if len(blob) == 0 {
+				defects = append(defects, trieDefect{hash: hash, path: paths[i]})
+				continue
+			}
+			if crypto.Keccak256Hash(blob) != hash || sched.Process(trie.SyncResult{Hash: hash, Data: blob}) != nil {
+				defects = append(defects, trieDefect{hash: hash, path: paths[i], corrupt: true})
+			}
+		}
+		for _, hash := range codes {
+			stats.codes++
+			blob := rawdb.ReadCode(db, hash)
+			if len(blob) == 0 {
+				defects = append(defects, trieDefect{hash: hash, code: true})
+				continue
+			}
+			if crypto.Keccak256Hash(blob) != hash || sched.Process(trie.SyncResult{Hash: hash, Data: blob}) != nil {
+				defects = append(defects, trieDefect{hash: hash, code: true, corrupt: true})
+			}
+		}
+		// Drop the completed entries, they are only needed for the walk
+		sched.Commit(discard)
+		discard.Reset()
+
+		if time.Since(logged) > 8*time.Second {
+			progress()
+			logged = time.Now()
+		}
+	}
+	return defects
+}
+
+// healTrie starts a full node, removes the corrupted state entries and retrieves
+// all the damaged subtries from the connected peers, then inspects the state at
+// the given root again.
+func healTrie(ctx *cli.Context, root common.Hash, defects []trieDefect) error {
+	stack, backend := makeFullNode(ctx)
+	defer stack.Close()
+
+	db := backend.ChainDb()
+
+	// The trie sync only schedules entries missing from the database, so drop
+	// the corrupted ones first.
+	for _, defect := range defects {
+		if !defect.corrupt {
+			continue
+		}
+		if defect.code {
+			rawdb.DeleteCode(db, defect.hash)
+		} else {
+			rawdb.DeleteTrieNode(db, defect.hash)
+		}
+	}
+	var sched *trie.Sync
+	onAccount := func(path []byte, leaf []byte, parent common.Hash) error {
+		var acc state.Account
+		if err := rlp.DecodeBytes(leaf, &acc); err != nil {
+			return err
+		}
+		sched.AddSubTrie(acc.Root, path, parent, nil)
+		sched.AddCodeEntry(common.BytesToHash(acc.CodeHash), path, parent)
+		return nil
+	}
+	sched = trie.NewSync(types.EmptyRootHash, db, nil, nil)
+	for _, defect := range defects {
+		switch {
+		case defect.code:
+			sched.AddCodeEntry(defect.hash, nil, common.Hash{})
+		case len(defect.path) == 1:
+			sched.AddSubTrie(defect.hash, nil, common.Hash{}, onAccount)
+		default:
+			sched.AddSubTrie(defect.hash, nil, common.Hash{}, nil)
+		}
+	}
+	utils.StartNode(ctx, stack)
+
+	timeout := ctx.Duration(inspectTrieHealTimeoutFlag.Name)
+	log.Info("Healing state trie", "root", root, "damaged", len(defects), "timeout", common.PrettyDuration(timeout))
+
+	cancel := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(cancel) })
+	defer timer.Stop()
+
+	if err := backend.Downloader().HealState(sched, cancel); err != nil {
+		return fmt.Errorf("failed to heal state: %v", err)
+	}
+	if defects = reportTrie(db, root); len(defects) > 0 {
+		return fmt.Errorf("%d damaged state entries left after healing", len(defects))
+	}
+	return nil
+}
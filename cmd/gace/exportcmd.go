@@ -0,0 +1,109 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/acent/go-acent/cmd/utils"
+	"github.com/acent/go-acent/eth/chainexport"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	parquetPartitionFlag = cli.Uint64Flag{
+		Name:  "partition",
+		Usage: "Number of blocks per partition of the exported files",
+		Value: chainexport.DefaultPartitionSize,
+	}
+	parquetTracesFlag = cli.BoolFlag{
+		Name:  "traces",
+		Usage: "Export the call traces of the transactions (requires the historical state)",
+	}
+	exportParquetCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportParquet),
+		Name:      "export-parquet",
+		Usage:     "Export chain data into Parquet files for analytics",
+		ArgsUsage: "<dir> [<blockNumFirst>] [<blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			parquetPartitionFlag,
+			parquetTracesFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-parquet command writes the blocks, transactions (merged with their
+receipts), logs and optionally the call traces of the chain into a Parquet
+dataset each, under a directory of the current schema version. The files cover
+consecutive block ranges and never straddle the partition boundaries.
+
+The export progress is checkpointed after every file, so an interrupted export
+resumes where it stopped, and rerunning the command with a later last block
+extends the datasets. The first block defaults to the genesis block and the
+last one to the current head. Exporting traces re-executes every transaction
+and needs the state of the exported blocks, usually an archive node.`,
+	}
+)
+
+func exportParquet(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 3 {
+		return errors.New("expected the output directory and an optional block range")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	defer chain.Stop()
+
+	var (
+		first uint64
+		last  = chain.CurrentBlock().NumberU64()
+		err   error
+	)
+	if ctx.NArg() > 1 {
+		if first, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64); err != nil {
+			return fmt.Errorf("invalid first block: %v", err)
+		}
+	}
+	if ctx.NArg() > 2 {
+		if last, err = strconv.ParseUint(ctx.Args().Get(2), 10, 64); err != nil {
+			return fmt.Errorf("invalid last block: %v", err)
+		}
+		if head := chain.CurrentBlock().NumberU64(); last > head {
+			return fmt.Errorf("last block %d beyond the head block %d", last, head)
+		}
+	}
+	exporter, err := chainexport.New(chain, chainexport.Config{
+		Dir:           ctx.Args().First(),
+		PartitionSize: ctx.Uint64(parquetPartitionFlag.Name),
+		Traces:        ctx.Bool(parquetTracesFlag.Name),
+	})
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := exporter.Export(first, last); err != nil {
+		return err
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/acent/go-acent/internal/debug"
 	"github.com/acent/go-acent/internal/ethapi"
 	"github.com/acent/go-acent/internal/flags"
+	"github.com/acent/go-acent/internal/tracing"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/metrics"
 	"github.com/acent/go-acent/node"
@@ -63,13 +64,15 @@ var (
 		utils.BootnodesFlag,
 		utils.DataDirFlag,
 		utils.AncientFlag,
+		utils.DBEngineFlag,
+		utils.ReadOnlyFlag,
+		utils.DBIdleCompactionFlag,
 		utils.MinFreeDiskSpaceFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
 		utils.NoUSBFlag,
 		utils.USBFlag,
 		utils.SmartCardDaemonPathFlag,
-		utils.OverrideBerlinFlag,
 		utils.EthashCacheDirFlag,
 		utils.EthashCachesInMemoryFlag,
 		utils.EthashCachesOnDiskFlag,
@@ -79,21 +82,31 @@ var (
 		utils.EthashDatasetsOnDiskFlag,
 		utils.EthashDatasetsLockMmapFlag,
 		utils.TxPoolLocalsFlag,
+		utils.TxPoolPriorityFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
+		utils.TxPoolReplaceByTipFlag,
 		utils.TxPoolAccountSlotsFlag,
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolEntryPointsFlag,
+		utils.TxPoolUserOpsFlag,
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.TransactionHistoryFlag,
+		utils.LogHistoryFlag,
+		utils.StateHistoryFlag,
+		utils.LogIndexFlag,
+		utils.CallIndexFlag,
+		utils.BloomBitsBlocksFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -103,8 +116,14 @@ var (
 		utils.UltraLightServersFlag,
 		utils.UltraLightFractionFlag,
 		utils.UltraLightOnlyAnnounceFlag,
+		utils.UltraLightConfigFileFlag,
 		utils.LightNoSyncServeFlag,
 		utils.WhitelistFlag,
+		utils.TrustedHeadFlag,
+		utils.HeadWatchdogFlag,
+		utils.HeadWatchdogRotationFlag,
+		utils.ObserverFlag,
+		utils.ObserverServeRateFlag,
 		utils.BloomFilterSizeFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
@@ -113,11 +132,20 @@ var (
 		utils.CacheTrieRejournalFlag,
 		utils.CacheGCFlag,
 		utils.CacheSnapshotFlag,
+		utils.CacheDynamicFlag,
 		utils.CacheNoPrefetchFlag,
 		utils.CachePreimagesFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.MaxMsgSizeFlag,
+		utils.PeerBanThresholdFlag,
+		utils.PeerBanDurationFlag,
+		utils.PeerAllowListFlag,
+		utils.PeerDenyListFlag,
+		utils.ZstdProtocolsFlag,
+		utils.ZstdLevelFlag,
+		utils.NodeDBLimitFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerNotifyFlag,
@@ -128,9 +156,12 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerBuildDeadlineFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.PeerExchangeFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -145,6 +176,10 @@ var (
 		utils.VMEnableDebugFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
+		utils.EthStatsTLSCertFlag,
+		utils.EthStatsTLSKeyFlag,
+		utils.EthStatsTLSCAFlag,
+		utils.EthStatsMetricsFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
@@ -170,6 +205,10 @@ var (
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.EngineAPIFlag,
+		utils.AuthListenAddrFlag,
+		utils.AuthPortFlag,
+		utils.JWTSecretFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
 		utils.WSEnabledFlag,
@@ -183,7 +222,16 @@ var (
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCGlobalReturnDataCapFlag,
+		utils.RPCGlobalLogsCapFlag,
+		utils.RPCLogsPageSizeFlag,
+		utils.RPCSafeDepthFlag,
+		utils.RPCFinalizedDepthFlag,
 		utils.AllowUnprotectedTxs,
+		utils.GatewayRateLimitFlag,
+		utils.GatewayRateBurstFlag,
+		utils.GatewayConcurrencyFlag,
+		utils.RPCBatchParallelismFlag,
 	}
 
 	metricsFlags = []cli.Flag{
@@ -197,6 +245,10 @@ var (
 		utils.MetricsInfluxDBUsernameFlag,
 		utils.MetricsInfluxDBPasswordFlag,
 		utils.MetricsInfluxDBTagsFlag,
+		utils.TracingEnabledFlag,
+		utils.TracingEndpointFlag,
+		utils.TracingInsecureFlag,
+		utils.TracingSampleRatioFlag,
 	}
 )
 
@@ -210,11 +262,16 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		exportParquetCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		removedbCommand,
 		dumpCommand,
+		indexBloomsCommand,
 		dumpGenesisCommand,
+		validateConfigCommand,
+		// See verifycmd.go:
+		verifyStateRootCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -240,6 +297,7 @@ func init() {
 	sort.Sort(cli.CommandsByName(app.Commands))
 
 	app.Flags = append(app.Flags, nodeFlags...)
+	app.Flags = append(app.Flags, utils.OverrideForkFlags...)
 	app.Flags = append(app.Flags, rpcFlags...)
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
@@ -250,6 +308,7 @@ func init() {
 	}
 	app.After = func(ctx *cli.Context) error {
 		debug.Exit()
+		tracing.Shutdown()
 		prompt.Stdin.Close() // Resets terminal mode.
 		return nil
 	}
@@ -319,8 +378,9 @@ func prepare(ctx *cli.Context) {
 	log.Debug("Sanitizing Go's GC trigger", "percent", int(gogc))
 	godebug.SetGCPercent(int(gogc))
 
-	// Start metrics export if enabled
+	// Start metrics and trace export if enabled
 	utils.SetupMetrics(ctx)
+	utils.SetupTracing(ctx)
 
 	// Start system runtime metrics collection
 	go metrics.CollectProcessMetrics(3 * time.Second)
@@ -367,13 +427,8 @@ func startNode(ctx *cli.Context, stack *node.Node, backend ethapi.Backend) {
 	ethClient := ethclient.NewClient(rpcClient)
 
 	go func() {
-		// Open any wallets already attached
-		for _, wallet := range stack.AccountManager().Wallets() {
-			if err := wallet.Open(""); err != nil {
-				log.Warn("Failed to open wallet", "url", wallet.URL(), "err", err)
-			}
-		}
-		// Listen for wallet event till termination
+		// Listen for wallet event till termination. The wallets already attached
+		// are announced as arrived to the first subscriber, opening them too.
 		for event := range events {
 			switch event.Kind {
 			case accounts.WalletArrived:
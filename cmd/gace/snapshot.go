@@ -17,17 +17,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/acent/go-acent/cmd/utils"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/state/pruner"
 	"github.com/acent/go-acent/core/state/snapshot"
+	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/trie"
@@ -142,6 +156,53 @@ verification. The default checking target is the HEAD state. It's basically iden
 to traverse-state, but the check granularity is smaller. 
 
 It's also usable without snapshot enabled.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the state snapshot of a recent block into a portable archive",
+				ArgsUsage: "<dir> [<number>]",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.RopstenFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+				},
+				Description: `
+geth snapshot export <dir> [<number>]
+will export the flattened state snapshot of the given block, along with the
+block itself, into the given directory. The directory receives a manifest
+listing the checksums of all the exported files, so that the archive can be
+published and verified on download.
+
+Only the states of the recent blocks covered by the snapshot layers can be
+exported. The default export target is the HEAD state.
+`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a state snapshot archive to bootstrap a node",
+				ArgsUsage: "<dir>",
+				Action:    utils.MigrateFlags(importSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.RopstenFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+				},
+				Description: `
+geth snapshot import <dir>
+will verify the archive created by "geth snapshot export" in the given
+directory against its manifest, import its state snapshot, regenerate the
+state trie from it and set the exported block as the head of the chain.
+
+The database must have been initialized with the same genesis block. The
+blocks before the imported one are not part of the archive.
 `,
 			},
 		},
@@ -423,6 +484,373 @@ func traverseRawState(ctx *cli.Context) error {
 	return nil
 }
 
+const (
+	// snapshotVersion is the version of the snapshot archive format.
+	snapshotVersion = 1
+
+	snapshotManifestFile = "manifest.json"
+	snapshotBlockFile    = "block.rlp"
+	snapshotHeadersFile  = "headers.rlp.gz"
+	snapshotStateFile    = "state.rlp.gz"
+
+	// snapshotHeaderBatch is the number of headers verified and imported at once.
+	snapshotHeaderBatch = 2048
+
+	// snapshotHeaderCheckFrequency is the verification frequency of the header seals
+	// during import, like during fast sync the headers are linked by hash to the
+	// snapshot block, so the seals are only spot checked.
+	snapshotHeaderCheckFrequency = 100
+)
+
+// snapshotManifest describes an exported state snapshot and the files holding it.
+type snapshotManifest struct {
+	Version  int            `json:"version"`
+	Genesis  common.Hash    `json:"genesis"`
+	Number   uint64         `json:"number"`
+	Hash     common.Hash    `json:"hash"`
+	Root     common.Hash    `json:"root"`
+	Accounts uint64         `json:"accounts"`
+	Slots    uint64         `json:"slots"`
+	Codes    uint64         `json:"codes"`
+	Files    []snapshotFile `json:"files"`
+}
+
+// snapshotFile is a checksummed file of an exported state snapshot.
+type snapshotFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"sha256"`
+}
+
+// file returns the manifest entry of the file with the given name.
+func (m *snapshotManifest) file(name string) (*snapshotFile, error) {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("file %s missing from manifest", name)
+}
+
+// verify checks the size and checksum of the file in the given directory.
+func (f *snapshotFile) verify(dir string) error {
+	file, err := os.Open(filepath.Join(dir, f.Name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return err
+	}
+	if size != f.Size {
+		return fmt.Errorf("%s: size mismatch: have %d, want %d", f.Name, size, f.Size)
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != f.Checksum {
+		return fmt.Errorf("%s: checksum mismatch: have %s, want %s", f.Name, sum, f.Checksum)
+	}
+	return nil
+}
+
+// writeSnapshotFile creates a file in the given directory with the content
+// produced by fn, checksumming it on the fly.
+func writeSnapshotFile(dir, name string, fn func(w io.Writer) error) (snapshotFile, error) {
+	file, err := ioutil.TempFile(dir, name+".*.tmp")
+	if err != nil {
+		return snapshotFile{}, err
+	}
+	defer os.Remove(file.Name())
+
+	var (
+		hasher = sha256.New()
+		buffer = bufio.NewWriter(io.MultiWriter(file, hasher))
+	)
+	if err := fn(buffer); err != nil {
+		file.Close()
+		return snapshotFile{}, err
+	}
+	if err := buffer.Flush(); err != nil {
+		file.Close()
+		return snapshotFile{}, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return snapshotFile{}, err
+	}
+	if err := file.Close(); err != nil {
+		return snapshotFile{}, err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return snapshotFile{}, err
+	}
+	if err := os.Rename(file.Name(), filepath.Join(dir, name)); err != nil {
+		return snapshotFile{}, err
+	}
+	return snapshotFile{Name: name, Size: stat.Size(), Checksum: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// readSnapshotFile opens a gzip compressed file in the given directory and passes
+// its decompressed content to fn.
+func readSnapshotFile(dir, name string, fn func(r io.Reader) error) error {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return fn(gz)
+}
+
+// exportSnapshot writes the state snapshot of a recent block, along with the
+// block itself and a manifest, into a directory.
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		log.Error("Invalid arguments given")
+		return errors.New("this command requires an output directory and an optional block number")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	block := headBlock
+	if ctx.NArg() == 2 {
+		number, err := strconv.ParseUint(ctx.Args()[1], 10, 64)
+		if err != nil {
+			log.Error("Failed to parse block number", "error", err)
+			return err
+		}
+		block = rawdb.ReadBlock(chaindb, rawdb.ReadCanonicalHash(chaindb, number), number)
+		if block == nil {
+			log.Error("Failed to load block", "number", number)
+			return fmt.Errorf("block %d not found", number)
+		}
+	}
+	snaptree, err := snapshot.New(chaindb, trie.NewDatabase(chaindb), 256, headBlock.Root(), false, false, false)
+	if err != nil {
+		log.Error("Failed to open snapshot tree", "error", err)
+		return err
+	}
+	if snaptree.Snapshot(block.Root()) == nil {
+		log.Error("State not covered by the snapshot", "number", block.NumberU64(), "root", block.Root())
+		return errors.New("state not in snapshot")
+	}
+	dir := ctx.Args()[0]
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	log.Info("Exporting snapshot archive", "dir", dir, "number", block.NumberU64(), "hash", block.Hash(), "root", block.Root())
+
+	manifest := &snapshotManifest{
+		Version: snapshotVersion,
+		Genesis: rawdb.ReadCanonicalHash(chaindb, 0),
+		Number:  block.NumberU64(),
+		Hash:    block.Hash(),
+		Root:    block.Root(),
+	}
+	file, err := writeSnapshotFile(dir, snapshotBlockFile, func(w io.Writer) error {
+		return block.EncodeRLP(w)
+	})
+	if err != nil {
+		log.Error("Failed to export block", "error", err)
+		return err
+	}
+	manifest.Files = append(manifest.Files, file)
+
+	// Export the headers linking the block to the genesis, the importing node
+	// needs them to verify the block and to keep its chain contiguous
+	file, err = writeSnapshotFile(dir, snapshotHeadersFile, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		for number := uint64(1); number < block.NumberU64(); number++ {
+			header := rawdb.ReadHeaderRLP(chaindb, rawdb.ReadCanonicalHash(chaindb, number), number)
+			if len(header) == 0 {
+				return fmt.Errorf("missing header %d", number)
+			}
+			if _, err := gz.Write(header); err != nil {
+				return err
+			}
+		}
+		return gz.Close()
+	})
+	if err != nil {
+		log.Error("Failed to export headers", "error", err)
+		return err
+	}
+	manifest.Files = append(manifest.Files, file)
+
+	file, err = writeSnapshotFile(dir, snapshotStateFile, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		stats, err := snapshot.Export(snaptree, block.Root(), chaindb, gz)
+		if err != nil {
+			return err
+		}
+		manifest.Accounts, manifest.Slots, manifest.Codes = stats.Accounts, stats.Slots, stats.Codes
+		return gz.Close()
+	})
+	if err != nil {
+		log.Error("Failed to export state snapshot", "error", err)
+		return err
+	}
+	manifest.Files = append(manifest.Files, file)
+
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, snapshotManifestFile), blob, 0644); err != nil {
+		return err
+	}
+	log.Info("Exported snapshot archive", "dir", dir, "number", block.NumberU64(), "accounts", manifest.Accounts, "slots", manifest.Slots, "codes", manifest.Codes)
+	return nil
+}
+
+// importSnapshot verifies and imports an exported state snapshot, setting the
+// exported block as the new chain head.
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		log.Error("Invalid arguments given")
+		return errors.New("this command requires an archive directory")
+	}
+	dir := ctx.Args()[0]
+	blob, err := ioutil.ReadFile(filepath.Join(dir, snapshotManifestFile))
+	if err != nil {
+		return err
+	}
+	var manifest snapshotManifest
+	if err := json.Unmarshal(blob, &manifest); err != nil {
+		return fmt.Errorf("invalid snapshot manifest: %v", err)
+	}
+	if manifest.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", manifest.Version)
+	}
+	blockFile, err := manifest.file(snapshotBlockFile)
+	if err != nil {
+		return err
+	}
+	headersFile, err := manifest.file(snapshotHeadersFile)
+	if err != nil {
+		return err
+	}
+	stateFile, err := manifest.file(snapshotStateFile)
+	if err != nil {
+		return err
+	}
+	for _, file := range []*snapshotFile{blockFile, headersFile, stateFile} {
+		if err := file.verify(dir); err != nil {
+			log.Error("Failed to verify snapshot file", "error", err)
+			return err
+		}
+	}
+	blob, err = ioutil.ReadFile(filepath.Join(dir, snapshotBlockFile))
+	if err != nil {
+		return err
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(blob, block); err != nil {
+		return fmt.Errorf("invalid snapshot block: %v", err)
+	}
+	if block.Hash() != manifest.Hash || block.NumberU64() != manifest.Number || block.Root() != manifest.Root {
+		return fmt.Errorf("snapshot block %d [%x] doesn't match manifest", block.NumberU64(), block.Hash())
+	}
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	if genesis := rawdb.ReadCanonicalHash(chaindb, 0); genesis != manifest.Genesis {
+		log.Error("Genesis mismatch", "database", genesis, "snapshot", manifest.Genesis)
+		return errors.New("snapshot of a different chain, initialize the database with its genesis first")
+	}
+	chainConfig := rawdb.ReadChainConfig(chaindb, manifest.Genesis)
+	if chainConfig == nil {
+		return errors.New("chain config not found, initialize the database with its genesis first")
+	}
+	engine := ethconfig.CreateConsensusEngine(stack, chainConfig, &config.Eth.Ethash, nil, false, chaindb)
+	defer engine.Close()
+
+	headerchain, err := core.NewHeaderChain(chaindb, chainConfig, engine, func() bool { return false })
+	if err != nil {
+		return err
+	}
+	if head := rawdb.ReadHeadBlock(chaindb); head != nil && head.NumberU64() >= block.NumberU64() {
+		log.Error("Snapshot older than the chain head", "head", head.NumberU64(), "snapshot", block.NumberU64())
+		return errors.New("snapshot older than the chain head")
+	}
+	log.Info("Importing snapshot archive", "dir", dir, "number", block.NumberU64(), "hash", block.Hash(), "root", block.Root())
+	err = readSnapshotFile(dir, snapshotStateFile, func(r io.Reader) error {
+		_, err := snapshot.Import(chaindb, r, block.Root())
+		return err
+	})
+	if err != nil {
+		log.Error("Failed to import state snapshot", "error", err)
+		return err
+	}
+	// The state is in place, verify and import the headers linking its block to
+	// the genesis, and make the block the head of the chain
+	var (
+		start   = time.Now()
+		headers = make([]*types.Header, 0, snapshotHeaderBatch)
+	)
+	insert := func() error {
+		if n, err := headerchain.ValidateHeaderChain(headers, snapshotHeaderCheckFrequency); err != nil {
+			return fmt.Errorf("invalid header %d [%x]: %v", headers[n].Number, headers[n].Hash(), err)
+		}
+		if _, err := headerchain.InsertHeaderChain(headers, start); err != nil {
+			return err
+		}
+		headers = headers[:0]
+		return nil
+	}
+	err = readSnapshotFile(dir, snapshotHeadersFile, func(r io.Reader) error {
+		stream := rlp.NewStream(r, 0)
+		for number := uint64(1); ; number++ {
+			header := new(types.Header)
+			if err := stream.Decode(header); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("header %d: %v", number, err)
+			}
+			if headers = append(headers, header); len(headers) == snapshotHeaderBatch {
+				if err := insert(); err != nil {
+					return err
+				}
+			}
+		}
+		// The snapshot block must be linked to the headers, verify it along them
+		headers = append(headers, block.Header())
+		return insert()
+	})
+	if err != nil {
+		log.Error("Failed to import headers", "error", err)
+		return err
+	}
+	if headerchain.CurrentHeader().Hash() != block.Hash() {
+		log.Error("Snapshot block not the head of the headers", "number", block.NumberU64(), "head", headerchain.CurrentHeader().Number)
+		return errors.New("snapshot block not the head of the headers")
+	}
+	batch := chaindb.NewBatch()
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteHeadFastBlockHash(batch, block.Hash())
+	rawdb.WriteHeadBlockHash(batch, block.Hash())
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Imported snapshot archive", "number", block.NumberU64(), "hash", block.Hash())
+	return nil
+}
+
 func parseRoot(input string) (common.Hash, error) {
 	var h common.Hash
 	if err := h.UnmarshalText([]byte(input)); err != nil {
@@ -36,6 +36,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.ReadOnlyFlag,
+			utils.DBIdleCompactionFlag,
 			utils.MinFreeDiskSpaceFlag,
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
@@ -50,10 +53,25 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
+			utils.TransactionHistoryFlag,
+			utils.LogHistoryFlag,
+			utils.StateHistoryFlag,
+			utils.LogIndexFlag,
+			utils.CallIndexFlag,
+			utils.BloomBitsBlocksFlag,
 			utils.EthStatsURLFlag,
+			utils.EthStatsTLSCertFlag,
+			utils.EthStatsTLSKeyFlag,
+			utils.EthStatsTLSCAFlag,
+			utils.EthStatsMetricsFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
 			utils.WhitelistFlag,
+			utils.TrustedHeadFlag,
+			utils.HeadWatchdogFlag,
+			utils.HeadWatchdogRotationFlag,
+			utils.ObserverFlag,
+			utils.ObserverServeRateFlag,
 		},
 	},
 	{
@@ -66,6 +84,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.UltraLightServersFlag,
 			utils.UltraLightFractionFlag,
 			utils.UltraLightOnlyAnnounceFlag,
+			utils.UltraLightConfigFileFlag,
 			utils.LightNoPruneFlag,
 			utils.LightNoSyncServeFlag,
 		},
@@ -94,16 +113,20 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "TRANSACTION POOL",
 		Flags: []cli.Flag{
 			utils.TxPoolLocalsFlag,
+			utils.TxPoolPriorityFlag,
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
+			utils.TxPoolReplaceByTipFlag,
 			utils.TxPoolAccountSlotsFlag,
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolEntryPointsFlag,
+			utils.TxPoolUserOpsFlag,
 		},
 	},
 	{
@@ -116,6 +139,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.CacheTrieRejournalFlag,
 			utils.CacheGCFlag,
 			utils.CacheSnapshotFlag,
+			utils.CacheDynamicFlag,
 			utils.CacheNoPrefetchFlag,
 			utils.CachePreimagesFlag,
 		},
@@ -150,9 +174,22 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
+			utils.EngineAPIFlag,
+			utils.AuthListenAddrFlag,
+			utils.AuthPortFlag,
+			utils.JWTSecretFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCGlobalReturnDataCapFlag,
+			utils.RPCGlobalLogsCapFlag,
+			utils.RPCLogsPageSizeFlag,
+			utils.RPCSafeDepthFlag,
+			utils.RPCFinalizedDepthFlag,
 			utils.AllowUnprotectedTxs,
+			utils.GatewayRateLimitFlag,
+			utils.GatewayRateBurstFlag,
+			utils.GatewayConcurrencyFlag,
+			utils.RPCBatchParallelismFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -166,9 +203,18 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.MaxMsgSizeFlag,
+			utils.PeerBanThresholdFlag,
+			utils.PeerBanDurationFlag,
+			utils.PeerAllowListFlag,
+			utils.PeerDenyListFlag,
+			utils.ZstdProtocolsFlag,
+			utils.ZstdLevelFlag,
+			utils.NodeDBLimitFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.PeerExchangeFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -187,6 +233,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerOrderingFlag,
+			utils.MinerBuildDeadlineFlag,
 		},
 	},
 	{
@@ -0,0 +1,295 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acent/go-acent/cmd/utils"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	verifyWorkersFlag = cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of storage tries to verify concurrently",
+		Value: runtime.NumCPU(),
+	}
+	verifyStateRootCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyStateRoot),
+		Name:      "verify-state-root",
+		Usage:     "Verify the integrity of the full state of a block offline",
+		ArgsUsage: "[<blockHash> | <blockNum>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			verifyWorkersFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify-state-root command walks the entire state trie of the given block
+(the HEAD block by default) and checks that every referenced trie node is present
+and hashes to its reference, every account decodes properly, its storage trie is
+complete and its contract code matches the code hash.
+
+Storage tries are verified concurrently by a configurable number of workers. The
+command is meant to validate a copied datadir before promoting it to production,
+the node must not be running.`,
+	}
+)
+
+// stateVerifyStats are the counters collected during state verification.
+type stateVerifyStats struct {
+	nodes    uint64 // Number of trie nodes verified (account and storage)
+	accounts uint64 // Number of accounts verified
+	slots    uint64 // Number of storage slots verified
+	codes    uint64 // Number of contract codes verified
+}
+
+// verifyStateRoot resolves the requested block and verifies its full state.
+func verifyStateRoot(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return errors.New("too many arguments")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+
+	var header *types.Header
+	if ctx.NArg() == 0 {
+		header = rawdb.ReadHeadHeader(db)
+	} else if arg := ctx.Args().First(); hashish(arg) {
+		hash := common.HexToHash(arg)
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+			header = rawdb.ReadHeader(db, hash, *number)
+		}
+	} else {
+		number, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number %q: %v", arg, err)
+		}
+		if hash := rawdb.ReadCanonicalHash(db, number); hash != (common.Hash{}) {
+			header = rawdb.ReadHeader(db, hash, number)
+		}
+	}
+	if header == nil {
+		return errors.New("block not found")
+	}
+	workers := ctx.Int(verifyWorkersFlag.Name)
+	if workers < 1 {
+		workers = 1
+	}
+	log.Info("Verifying state", "number", header.Number, "hash", header.Hash(), "root", header.Root, "workers", workers)
+
+	var (
+		stats = new(stateVerifyStats)
+		start = time.Now()
+		done  = make(chan struct{})
+	)
+	go func() {
+		ticker := time.NewTicker(8 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				log.Info("Verifying state", "nodes", atomic.LoadUint64(&stats.nodes), "accounts", atomic.LoadUint64(&stats.accounts),
+					"slots", atomic.LoadUint64(&stats.slots), "codes", atomic.LoadUint64(&stats.codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := verifyStateTrie(db, header.Root, workers, stats)
+	close(done)
+
+	if err != nil {
+		log.Error("State verification failed", "root", header.Root, "err", err)
+		return err
+	}
+	log.Info("State is valid", "root", header.Root, "nodes", stats.nodes, "accounts", stats.accounts,
+		"slots", stats.slots, "codes", stats.codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyStateTrie walks the account trie rooted at root, verifying every node and
+// account. Storage tries are handed off to a pool of workers. The first failure
+// aborts the verification.
+func verifyStateTrie(db ethdb.Database, root common.Hash, workers int, stats *stateVerifyStats) error {
+	// An empty state has no root node stored, there's nothing to verify
+	if root == emptyRoot {
+		return nil
+	}
+	triedb := trie.NewDatabase(db)
+	if err := verifyTrieNode(db, root); err != nil {
+		return err
+	}
+	accTrie, err := trie.NewSecure(root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		tasks = make(chan common.Hash, 4*workers)
+		abort = make(chan struct{})
+		fail  error
+		once  sync.Once
+		wg    sync.WaitGroup
+	)
+	failed := func(err error) {
+		once.Do(func() {
+			fail = err
+			close(abort)
+		})
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for root := range tasks {
+				if err := verifyStorage(db, triedb, root, abort, stats); err != nil {
+					failed(err)
+				}
+			}
+		}()
+	}
+	// Walk the account trie, scheduling every storage trie only once
+	scheduled := make(map[common.Hash]struct{})
+
+	it := accTrie.NodeIterator(nil)
+walk:
+	for it.Next(true) {
+		select {
+		case <-abort:
+			break walk
+		default:
+		}
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if err := verifyTrieNode(db, hash); err != nil {
+				failed(fmt.Errorf("account trie: %v", err))
+				break
+			}
+			atomic.AddUint64(&stats.nodes, 1)
+		}
+		if !it.Leaf() {
+			continue
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			failed(fmt.Errorf("invalid account %x: %v", it.LeafKey(), err))
+			break
+		}
+		if acc.Balance == nil || acc.Balance.Sign() < 0 {
+			failed(fmt.Errorf("invalid balance of account %x", it.LeafKey()))
+			break
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCode) {
+			code := rawdb.ReadCode(db, common.BytesToHash(acc.CodeHash))
+			if len(code) == 0 {
+				failed(fmt.Errorf("missing code %x of account %x", acc.CodeHash, it.LeafKey()))
+				break
+			}
+			if hash := crypto.Keccak256(code); !bytes.Equal(hash, acc.CodeHash) {
+				failed(fmt.Errorf("corrupted code %x of account %x: hash %x", acc.CodeHash, it.LeafKey(), hash))
+				break
+			}
+			atomic.AddUint64(&stats.codes, 1)
+		}
+		if _, ok := scheduled[acc.Root]; !ok && acc.Root != emptyRoot {
+			scheduled[acc.Root] = struct{}{}
+			select {
+			case tasks <- acc.Root:
+			case <-abort:
+			}
+		}
+		atomic.AddUint64(&stats.accounts, 1)
+	}
+	close(tasks)
+	wg.Wait()
+
+	if fail != nil {
+		return fail
+	}
+	return it.Error()
+}
+
+// verifyStorage walks a single storage trie, verifying every node and slot.
+func verifyStorage(db ethdb.KeyValueReader, triedb *trie.Database, root common.Hash, abort chan struct{}, stats *stateVerifyStats) error {
+	if err := verifyTrieNode(db, root); err != nil {
+		return fmt.Errorf("storage trie %x: %v", root, err)
+	}
+	t, err := trie.NewSecure(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for it.Next(true) {
+		select {
+		case <-abort:
+			return nil
+		default:
+		}
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if err := verifyTrieNode(db, hash); err != nil {
+				return fmt.Errorf("storage trie %x: %v", root, err)
+			}
+			atomic.AddUint64(&stats.nodes, 1)
+		}
+		if it.Leaf() {
+			if _, _, err := rlp.SplitString(it.LeafBlob()); err != nil {
+				return fmt.Errorf("storage trie %x: invalid slot %x: %v", root, it.LeafKey(), err)
+			}
+			atomic.AddUint64(&stats.slots, 1)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("storage trie %x: %v", root, err)
+	}
+	return nil
+}
+
+// verifyTrieNode checks that the trie node referenced by hash is present in the
+// database and its content hashes to the reference.
+func verifyTrieNode(db ethdb.KeyValueReader, hash common.Hash) error {
+	blob := rawdb.ReadTrieNode(db, hash)
+	if len(blob) == 0 {
+		return fmt.Errorf("missing trie node %x", hash)
+	}
+	if have := crypto.Keccak256Hash(blob); have != hash {
+		return fmt.Errorf("corrupted trie node %x: hash %x", hash, have)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+)
+
+// makeVerifyState creates a state with a mix of plain accounts and contracts
+// with storage, flushed to a fresh in-memory database.
+func makeVerifyState(t *testing.T) (ethdb.Database, common.Hash) {
+	db := rawdb.NewMemoryDatabase()
+	sdb := state.NewDatabase(db)
+	statedb, _ := state.New(common.Hash{}, sdb, nil)
+
+	for i := byte(1); i <= 32; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.SetBalance(addr, big.NewInt(int64(i)))
+		if i%4 == 0 {
+			statedb.SetCode(addr, []byte{i, 0x60, 0x00})
+			for j := byte(1); j <= 8; j++ {
+				statedb.SetState(addr, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := sdb.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	return db, root
+}
+
+// Tests that a complete state passes verification and all items are counted.
+func TestVerifyState(t *testing.T) {
+	db, root := makeVerifyState(t)
+
+	stats := new(stateVerifyStats)
+	if err := verifyStateTrie(db, root, 4, stats); err != nil {
+		t.Fatalf("failed to verify valid state: %v", err)
+	}
+	if stats.accounts != 32 || stats.slots != 64 || stats.codes != 8 {
+		t.Fatalf("stats mismatch: have %d/%d/%d accounts/slots/codes, want %d/%d/%d", stats.accounts, stats.slots, stats.codes, 32, 64, 8)
+	}
+}
+
+// Tests that an empty state passes verification without any stored trie node.
+func TestVerifyEmptyState(t *testing.T) {
+	stats := new(stateVerifyStats)
+	if err := verifyStateTrie(rawdb.NewMemoryDatabase(), emptyRoot, 4, stats); err != nil {
+		t.Fatalf("failed to verify empty state: %v", err)
+	}
+	if stats.nodes != 0 || stats.accounts != 0 {
+		t.Fatalf("stats mismatch: have %d/%d nodes/accounts, want 0/0", stats.nodes, stats.accounts)
+	}
+}
+
+// Tests that missing and corrupted trie nodes and codes are detected.
+func TestVerifyStateCorruption(t *testing.T) {
+	db, root := makeVerifyState(t)
+
+	// Corrupt a single trie node, leaving it present under its old hash
+	var (
+		victim common.Hash
+		blob   []byte
+	)
+	it := db.NewIterator(nil, nil)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength && common.BytesToHash(it.Key()) != root {
+			victim, blob = common.BytesToHash(it.Key()), common.CopyBytes(it.Value())
+			break
+		}
+	}
+	it.Release()
+
+	rawdb.WriteTrieNode(db, victim, append(blob, 0x00))
+	if err := verifyStateTrie(db, root, 4, new(stateVerifyStats)); err == nil || !strings.Contains(err.Error(), "corrupted trie node") {
+		t.Fatalf("corrupted node not detected: %v", err)
+	}
+	rawdb.DeleteTrieNode(db, victim)
+	if err := verifyStateTrie(db, root, 4, new(stateVerifyStats)); err == nil || !strings.Contains(err.Error(), "missing trie node") {
+		t.Fatalf("missing node not detected: %v", err)
+	}
+	rawdb.WriteTrieNode(db, victim, blob)
+
+	// Drop a contract code
+	rawdb.DeleteCode(db, crypto.Keccak256Hash([]byte{4, 0x60, 0x00}))
+	if err := verifyStateTrie(db, root, 4, new(stateVerifyStats)); err == nil || !strings.Contains(err.Error(), "missing code") {
+		t.Fatalf("missing code not detected: %v", err)
+	}
+}
@@ -18,11 +18,15 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -36,6 +40,7 @@ import (
 	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/internal/debug"
+	"github.com/acent/go-acent/internal/era"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/node"
 	"github.com/acent/go-acent/rlp"
@@ -155,7 +160,19 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 			return err
 		}
 	}
-	stream := rlp.NewStream(reader, 0)
+	// Blocks are either exported one after the other or as a single list
+	buffered := bufio.NewReader(reader)
+	start, _ := buffered.Peek(3 * 9)
+
+	decode := rlp.NewStream(buffered, 0).Decode
+	if isBlockList(start) {
+		list, err := rlp.NewListReader(buffered)
+		if err != nil {
+			return err
+		}
+		log.Info("Importing list of blocks", "size", common.StorageSize(list.Size()))
+		decode = list.Decode
+	}
 
 	// Run actual the import.
 	blocks := make(types.Blocks, importBatchSize)
@@ -168,7 +185,7 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 		i := 0
 		for ; i < importBatchSize; i++ {
 			var b types.Block
-			if err := stream.Decode(&b); err == io.EOF {
+			if err := decode(&b); err == io.EOF {
 				break
 			} else if err != nil {
 				return fmt.Errorf("at block %d: %v", n, err)
@@ -200,6 +217,23 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	return nil
 }
 
+// isBlockList reports whether the start of an exported chain is the start of a
+// list of blocks rather than of a sequence of blocks. The header of the first block
+// is nested one level deeper in a list, its first element being a list instead of
+// the parent hash.
+func isBlockList(start []byte) bool {
+	// Hide the byte reader from the stream, it would limit the input to the start
+	s := rlp.NewStream(io.MultiReader(bytes.NewReader(start)), 0)
+	if _, err := s.List(); err != nil {
+		return false
+	}
+	if _, err := s.List(); err != nil {
+		return false
+	}
+	kind, _, err := s.Kind()
+	return err == nil && kind == rlp.List
+}
+
 func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block {
 	head := chain.CurrentBlock()
 	for i, block := range blocks {
@@ -244,6 +278,111 @@ func ExportChain(blockchain *core.BlockChain, fn string) error {
 	return nil
 }
 
+// ExportChainList exports a section of the blockchain into the specified file as a
+// single RLP list of blocks, truncating any data already present in the file.
+func ExportChainList(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+	log.Info("Exporting blockchain as a list", "file", fn)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	// Buffer the writes, the blocks are streamed in small pieces
+	buffered := bufio.NewWriter(writer)
+	if err := blockchain.ExportList(buffered, first, last); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain", "file", fn)
+	return nil
+}
+
+// ExportArchive exports a section of the blockchain into the specified directory
+// as a segmented archive, each segment holding segmentSize blocks as a single RLP
+// list. The segments are indexed along with their checksums, such that they can be
+// distributed and verified independently.
+func ExportArchive(blockchain *core.BlockChain, dir string, first uint64, last uint64, segmentSize uint64, compress bool) error {
+	if segmentSize == 0 {
+		return errors.New("zero segment size")
+	}
+	if first > last {
+		return fmt.Errorf("first block %d is greater than last block %d", first, last)
+	}
+	log.Info("Exporting blockchain archive", "dir", dir, "first", first, "last", last, "segment", segmentSize)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	index := &era.Index{Version: era.Version, SegmentSize: segmentSize}
+	for start := first; start <= last; start += segmentSize {
+		end := start + segmentSize - 1
+		if end > last || end < start {
+			end = last
+		}
+		w, err := era.NewSegmentWriter(dir, start, end, compress)
+		if err != nil {
+			return err
+		}
+		buffered := bufio.NewWriter(w)
+		if err := blockchain.ExportList(buffered, start, end); err != nil {
+			w.Abort()
+			return err
+		}
+		if err := buffered.Flush(); err != nil {
+			w.Abort()
+			return err
+		}
+		seg, err := w.Close()
+		if err != nil {
+			return err
+		}
+		seg.FirstHash = blockchain.GetCanonicalHash(start)
+		seg.LastHash = blockchain.GetCanonicalHash(end)
+		index.Segments = append(index.Segments, seg)
+		log.Info("Exported archive segment", "file", seg.File, "size", common.StorageSize(seg.Size))
+
+		if end == last {
+			break
+		}
+	}
+	if err := index.Write(dir); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain archive", "dir", dir, "segments", len(index.Segments))
+	return nil
+}
+
+// ImportArchive imports the segmented archive in the specified directory, verifying
+// the checksum of every segment before importing its blocks.
+func ImportArchive(chain *core.BlockChain, dir string) error {
+	index, err := era.ReadIndex(dir)
+	if err != nil {
+		return err
+	}
+	log.Info("Importing blockchain archive", "dir", dir, "segments", len(index.Segments))
+	for _, seg := range index.Segments {
+		if err := seg.Verify(dir); err != nil {
+			return err
+		}
+		if err := ImportChain(chain, filepath.Join(dir, seg.File)); err != nil {
+			return fmt.Errorf("segment %s: %v", seg.File, err)
+		}
+		if hash := chain.GetCanonicalHash(seg.Last); hash != seg.LastHash {
+			return fmt.Errorf("segment %s: last block hash mismatch: have %x, want %x", seg.File, hash, seg.LastHash)
+		}
+	}
+	return nil
+}
+
 // ExportAppendChain exports a blockchain into the specified file, appending to
 // the file if data already exists in it.
 func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
@@ -0,0 +1,52 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of go-acent.
+//
+// go-acent is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-acent is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-acent. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/rlp"
+)
+
+func TestIsBlockList(t *testing.T) {
+	var (
+		block = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Extra: make([]byte, 100)})
+		plain bytes.Buffer
+		list  bytes.Buffer
+	)
+	for i := 0; i < 2; i++ {
+		if err := block.EncodeRLP(&plain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lw, _ := rlp.NewListWriter(&list, uint64(plain.Len()))
+	if err := lw.WriteRaw(plain.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if isBlockList(plain.Bytes()[:3*9]) {
+		t.Errorf("sequence of blocks detected as a list")
+	}
+	if !isBlockList(list.Bytes()[:3*9]) {
+		t.Errorf("list of blocks not detected")
+	}
+	if isBlockList(nil) {
+		t.Errorf("empty input detected as a list")
+	}
+}
@@ -43,15 +43,18 @@ import (
 	"github.com/acent/go-acent/core/vm"
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/eth"
+	"github.com/acent/go-acent/eth/catalyst"
 	"github.com/acent/go-acent/eth/downloader"
 	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/eth/gasprice"
 	"github.com/acent/go-acent/eth/tracers"
 	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/ethdb/remotefreezer"
 	"github.com/acent/go-acent/ethstats"
 	"github.com/acent/go-acent/graphql"
 	"github.com/acent/go-acent/internal/ethapi"
 	"github.com/acent/go-acent/internal/flags"
+	"github.com/acent/go-acent/internal/tracing"
 	"github.com/acent/go-acent/les"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/metrics"
@@ -63,6 +66,7 @@ import (
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/p2p/nat"
 	"github.com/acent/go-acent/p2p/netutil"
+	"github.com/acent/go-acent/p2p/rlpx"
 	"github.com/acent/go-acent/params"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	"gopkg.in/urfave/cli.v1"
@@ -110,9 +114,21 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString(node.DefaultDataDir()),
 	}
-	AncientFlag = DirectoryFlag{
+	AncientFlag = cli.StringFlag{
 		Name:  "datadir.ancient",
-		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+		Usage: "Data directory or s3:// object store URL for ancient chain segments (default = inside chaindata)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use ('leveldb' or 'pebble', default = existing database or leveldb)",
+	}
+	ReadOnlyFlag = cli.BoolFlag{
+		Name:  "readonly",
+		Usage: "Open the database read-only and serve historical data only, without peering, block import, mining or transaction acceptance",
+	}
+	DBIdleCompactionFlag = cli.BoolFlag{
+		Name:  "db.compaction.idle",
+		Usage: "Compact the database in the background while block imports and database traffic are low",
 	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
@@ -217,6 +233,31 @@ var (
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value: ethconfig.Defaults.TxLookupLimit,
 	}
+	TransactionHistoryFlag = cli.Uint64Flag{
+		Name:  "history.transactions",
+		Usage: "Number of recent blocks whose transactions are downloaded by fast/snap sync (0 = entire chain)",
+	}
+	LogHistoryFlag = cli.Uint64Flag{
+		Name:  "history.logs",
+		Usage: "Number of recent blocks whose receipts and logs are downloaded by fast/snap sync (0 = entire chain)",
+	}
+	StateHistoryFlag = cli.Uint64Flag{
+		Name:  "history.state",
+		Usage: "Number of recent blocks whose state can be reconstructed from reverse diffs without archive mode (0 = disabled)",
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain a per-contract log index to speed up address-constrained log queries",
+	}
+	CallIndexFlag = cli.BoolFlag{
+		Name:  "callindex",
+		Usage: "Trace blocks to maintain a per-contract index of the transactions calling it, including internal calls (full history requires --gcmode=archive)",
+	}
+	BloomBitsBlocksFlag = cli.Uint64Flag{
+		Name:  "bloombits.blocks",
+		Usage: "Number of blocks per bloombits log index section, a multiple of 8 (changing it drops the existing index)",
+		Value: ethconfig.Defaults.BloomBitsBlocks,
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -225,15 +266,38 @@ var (
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
 	}
+	TrustedHeadFlag = cli.StringFlag{
+		Name:  "sync.trustedhead",
+		Usage: "Trusted chain head to backfill the header chain from before syncing (<number>=<hash>)",
+	}
+	HeadWatchdogFlag = cli.DurationFlag{
+		Name:  "watchdog",
+		Usage: "Time without chain head progress while peers advertise a heavier chain after which a portion of them is rotated (0 = disabled)",
+		Value: ethconfig.Defaults.HeadWatchdog,
+	}
+	HeadWatchdogRotationFlag = cli.IntFlag{
+		Name:  "watchdog.rotate",
+		Usage: "Percentage of peers to drop when the chain head stalls",
+		Value: ethconfig.Defaults.HeadWatchdogRotation,
+	}
+	ObserverFlag = cli.BoolFlag{
+		Name:  "observer",
+		Usage: "Receive and validate gossip, but never announce or forward blocks and transactions",
+	}
+	ObserverServeRateFlag = cli.IntFlag{
+		Name:  "observer.serverate",
+		Usage: "Maximum number of data requests served to eth and light peers per second in observer mode",
+		Value: ethconfig.Defaults.ObserverServeRate,
+	}
 	BloomFilterSizeFlag = cli.Uint64Flag{
 		Name:  "bloomfilter.size",
 		Usage: "Megabytes of memory allocated to bloom-filter for pruning",
 		Value: 2048,
 	}
-	OverrideBerlinFlag = cli.Uint64Flag{
-		Name:  "override.berlin",
-		Usage: "Manually specify Berlin fork-block, overriding the bundled setting",
-	}
+	// OverrideForkFlags reschedule the forks of the chain config, one flag per
+	// overridable fork (e.g. --override.berlin).
+	OverrideForkFlags = makeOverrideForkFlags()
+
 	// Light server and client settings
 	LightServeFlag = cli.IntFlag{
 		Name:  "light.serve",
@@ -269,6 +333,10 @@ var (
 		Name:  "ulc.onlyannounce",
 		Usage: "Ultra light server sends announcements only",
 	}
+	UltraLightConfigFileFlag = cli.StringFlag{
+		Name:  "ulc.config",
+		Usage: "JSON file with the trusted ultra-light servers and fraction, reloaded when changed",
+	}
 	LightNoPruneFlag = cli.BoolFlag{
 		Name:  "light.nopruning",
 		Usage: "Disable ancient light chain data pruning",
@@ -320,6 +388,10 @@ var (
 		Name:  "txpool.locals",
 		Usage: "Comma separated accounts to treat as locals (no flush, priority inclusion)",
 	}
+	TxPoolPriorityFlag = cli.StringFlag{
+		Name:  "txpool.priority",
+		Usage: "Comma separated accounts exempt from global pool limits (validated like remotes)",
+	}
 	TxPoolNoLocalsFlag = cli.BoolFlag{
 		Name:  "txpool.nolocals",
 		Usage: "Disables price exemptions for locally submitted transactions",
@@ -344,6 +416,10 @@ var (
 		Usage: "Price bump percentage to replace an already existing transaction",
 		Value: ethconfig.Defaults.TxPool.PriceBump,
 	}
+	TxPoolReplaceByTipFlag = cli.BoolFlag{
+		Name:  "txpool.replacebytip",
+		Usage: "Accept replacement transactions paying any higher gas price, ignoring the price bump",
+	}
 	TxPoolAccountSlotsFlag = cli.Uint64Flag{
 		Name:  "txpool.accountslots",
 		Usage: "Minimum number of executable transaction slots guaranteed per account",
@@ -369,6 +445,16 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: ethconfig.Defaults.TxPool.Lifetime,
 	}
+	TxPoolEntryPointsFlag = cli.StringFlag{
+		Name:  "txpool.entrypoints",
+		Usage: "Comma separated ERC-4337 entry points to pool user operations for (default = user operations disabled)",
+		Value: "",
+	}
+	TxPoolUserOpsFlag = cli.Uint64Flag{
+		Name:  "txpool.userops",
+		Usage: "Maximum number of pooled ERC-4337 user operations",
+		Value: ethconfig.Defaults.UserOpPool.MaxOps,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -405,6 +491,10 @@ var (
 		Usage: "Percentage of cache memory allowance to use for snapshot caching (default = 10% full mode, 20% archive mode)",
 		Value: 10,
 	}
+	CacheDynamicFlag = cli.BoolFlag{
+		Name:  "cache.dynamic",
+		Usage: "Dynamically rebalance the trie pruning, chain data, transaction pool and download caches based on sync status",
+	}
 	CacheNoPrefetchFlag = cli.BoolFlag{
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
@@ -460,6 +550,15 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerBuildDeadlineFlag = cli.DurationFlag{
+		Name:  "miner.deadline",
+		Usage: "Time after a new head to stop improving the block being mined (0 = no deadline)",
+	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: "Transaction ordering of mined blocks (price, fifo or fair)",
+		Value: ethconfig.Defaults.Miner.Ordering,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -494,11 +593,52 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCGlobalReturnDataCapFlag = cli.Uint64Flag{
+		Name:  "rpc.returndatacap",
+		Usage: "Sets a cap on the size in bytes of the data returned by eth_call (0 = no cap)",
+		Value: ethconfig.Defaults.RPCReturnDataCap,
+	}
+	RPCGlobalLogsCapFlag = cli.Uint64Flag{
+		Name:  "rpc.logscap",
+		Usage: "Sets a cap on the number of logs returned by a single log query (0 = no cap)",
+		Value: ethconfig.Defaults.RPCLogsCap,
+	}
+	RPCLogsPageSizeFlag = cli.Uint64Flag{
+		Name:  "rpc.logspagesize",
+		Usage: "Sets the maximum number of logs returned by a page of a paginated log query (0 = logs cap)",
+		Value: ethconfig.Defaults.RPCLogsPageSize,
+	}
+	RPCSafeDepthFlag = cli.Uint64Flag{
+		Name:  "rpc.safedepth",
+		Usage: "Number of confirmations after which blocks are reported as safe, unless provided by the consensus engine",
+		Value: ethconfig.Defaults.RPCSafeDepth,
+	}
+	RPCFinalizedDepthFlag = cli.Uint64Flag{
+		Name:  "rpc.finalizeddepth",
+		Usage: "Number of confirmations after which blocks are reported as finalized, unless provided by the consensus engine",
+		Value: ethconfig.Defaults.RPCFinalizedDepth,
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
 		Usage: "Reporting URL of a ethstats service (nodename:secret@host:port)",
 	}
+	EthStatsTLSCertFlag = cli.StringFlag{
+		Name:  "ethstats.tls.cert",
+		Usage: "Client certificate enabling authenticated ethstats reporting over mutual TLS",
+	}
+	EthStatsTLSKeyFlag = cli.StringFlag{
+		Name:  "ethstats.tls.key",
+		Usage: "Private key of the authenticated ethstats reporting certificate",
+	}
+	EthStatsTLSCAFlag = cli.StringFlag{
+		Name:  "ethstats.tls.ca",
+		Usage: "CA certificate authenticating the ethstats server (default = system roots)",
+	}
+	EthStatsMetricsFlag = cli.StringFlag{
+		Name:  "ethstats.metrics",
+		Usage: "Comma separated metrics pushed in authenticated ethstats mode (txpool.pending, txpool.queued, peers, sync.lag)",
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -550,6 +690,25 @@ var (
 		Usage: "HTTP path path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	AuthListenAddrFlag = cli.StringFlag{
+		Name:  "authrpc.addr",
+		Usage: "Listening interface of the JWT authenticated RPC server (engine API)",
+		Value: node.DefaultAuthHost,
+	}
+	AuthPortFlag = cli.IntFlag{
+		Name:  "authrpc.port",
+		Usage: "Listening port of the JWT authenticated RPC server (engine API)",
+		Value: node.DefaultAuthPort,
+	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to the hex encoded JWT secret of the authenticated RPC server, generated if missing",
+		Value: "",
+	}
+	EngineAPIFlag = cli.BoolFlag{
+		Name:  "engine",
+		Usage: "Enable the engine API on the authenticated RPC server, letting an external consensus client drive the chain",
+	}
 	GraphQLEnabledFlag = cli.BoolFlag{
 		Name:  "graphql",
 		Usage: "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -605,6 +764,22 @@ var (
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	GatewayRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.gateway.ratelimit",
+		Usage: "Maximum number of HTTP and WS-RPC calls per second from a single IP address (0 = unlimited)",
+	}
+	GatewayRateBurstFlag = cli.IntFlag{
+		Name:  "rpc.gateway.burst",
+		Usage: "Maximum number of HTTP and WS-RPC calls from a single IP address in a burst above the rate limit (0 = rate limit)",
+	}
+	GatewayConcurrencyFlag = cli.IntFlag{
+		Name:  "rpc.gateway.concurrency",
+		Usage: "Maximum number of concurrent HTTP and WS-RPC calls of any single method (0 = unlimited)",
+	}
+	RPCBatchParallelismFlag = cli.IntFlag{
+		Name:  "rpc.batch.parallelism",
+		Usage: "Maximum number of calls of an HTTP or WS-RPC batch served concurrently per connection (0 = sequential)",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -617,6 +792,39 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: node.DefaultConfig.P2P.MaxPendingPeers,
 	}
+	MaxMsgSizeFlag = cli.Uint64Flag{
+		Name:  "maxmsgsize",
+		Usage: "Maximum size of a single p2p message accepted from peers in bytes (transport limit used if set to 0)",
+		Value: uint64(node.DefaultConfig.P2P.MaxMsgSize),
+	}
+	PeerBanThresholdFlag = cli.Float64Flag{
+		Name:  "p2p.banthreshold",
+		Usage: "Decayed offence penalty at which misbehaving peers are temporarily banned (default 100, negative disables banning)",
+	}
+	PeerBanDurationFlag = cli.DurationFlag{
+		Name:  "p2p.banduration",
+		Usage: "Time misbehaving peers stay banned (default 1h)",
+	}
+	PeerAllowListFlag = cli.StringFlag{
+		Name:  "p2p.allowlist",
+		Usage: "File listing the only node IDs, IPs and CIDR masks accepted as peers, reloadable with admin.reloadPeerFilters",
+	}
+	PeerDenyListFlag = cli.StringFlag{
+		Name:  "p2p.denylist",
+		Usage: "File listing the node IDs, IPs and CIDR masks never accepted as peers, reloadable with admin.reloadPeerFilters",
+	}
+	ZstdProtocolsFlag = cli.StringFlag{
+		Name:  "p2p.zstd",
+		Usage: "Comma separated protocols to compress with zstd instead of snappy, if peers enable it too (e.g. eth,snap)",
+	}
+	ZstdLevelFlag = cli.IntFlag{
+		Name:  "p2p.zstdlevel",
+		Usage: "Compression level of zstd compressed messages, from 1 (fastest) to 4 (best) (default 2)",
+	}
+	NodeDBLimitFlag = cli.IntFlag{
+		Name:  "nodedb.limit",
+		Usage: "Maximum number of nodes retained in the discovery database (default 10000, negative disables the limit)",
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -637,7 +845,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|pcp|extip:<IP>)",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -648,6 +856,10 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	PeerExchangeFlag = cli.BoolFlag{
+		Name:  "pex",
+		Usage: "Enables sharing the records of connected peers to speed up peer discovery",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -738,6 +950,26 @@ var (
 		Usage: "Comma-separated InfluxDB tags (key/values) attached to all measurements",
 		Value: metrics.DefaultConfig.InfluxDBTags,
 	}
+
+	// Tracing flags
+	TracingEnabledFlag = cli.BoolFlag{
+		Name:  "tracing",
+		Usage: "Enable OpenTelemetry tracing of RPC calls, sync and block imports",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "OTLP/gRPC collector endpoint to export trace spans to",
+		Value: tracing.DefaultConfig.Endpoint,
+	}
+	TracingInsecureFlag = cli.BoolFlag{
+		Name:  "tracing.insecure",
+		Usage: "Connect to the trace collector without TLS",
+	}
+	TracingSampleRatioFlag = cli.Float64Flag{
+		Name:  "tracing.sampleratio",
+		Usage: "Ratio of the traces to sample (0-1)",
+		Value: tracing.DefaultConfig.SampleRatio,
+	}
 	EWASMInterpreterFlag = cli.StringFlag{
 		Name:  "vm.ewasm",
 		Usage: "External ewasm configuration (default = built-in interpreter)",
@@ -951,6 +1183,23 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.GlobalIsSet(RPCBatchParallelismFlag.Name) {
+		cfg.RPCBatchParallelism = ctx.GlobalInt(RPCBatchParallelismFlag.Name)
+	}
+}
+
+// setAuthRPC configures the JWT authenticated RPC server from the set command
+// line flags. The server is only started if authenticated APIs are registered.
+func setAuthRPC(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(AuthListenAddrFlag.Name) {
+		cfg.AuthAddr = ctx.GlobalString(AuthListenAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -990,6 +1239,26 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setGateway configures the gateway mode of the HTTP and WebSocket RPC endpoints
+// from the set command line flags, enabling it if any of its limits is set.
+func setGateway(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.GlobalIsSet(GatewayRateLimitFlag.Name) && !ctx.GlobalIsSet(GatewayRateBurstFlag.Name) && !ctx.GlobalIsSet(GatewayConcurrencyFlag.Name) {
+		return
+	}
+	if cfg.Gateway == nil {
+		cfg.Gateway = new(node.GatewayConfig)
+	}
+	if ctx.GlobalIsSet(GatewayRateLimitFlag.Name) {
+		cfg.Gateway.RateLimit = ctx.GlobalFloat64(GatewayRateLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(GatewayRateBurstFlag.Name) {
+		cfg.Gateway.RateBurst = ctx.GlobalInt(GatewayRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(GatewayConcurrencyFlag.Name) {
+		cfg.Gateway.MaxConcurrent = ctx.GlobalInt(GatewayConcurrencyFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1029,6 +1298,9 @@ func setLes(ctx *cli.Context, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(UltraLightOnlyAnnounceFlag.Name) {
 		cfg.UltraLightOnlyAnnounce = ctx.GlobalBool(UltraLightOnlyAnnounceFlag.Name)
 	}
+	if ctx.GlobalIsSet(UltraLightConfigFileFlag.Name) {
+		cfg.UltraLightConfigFile = ctx.GlobalString(UltraLightConfigFileFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightNoPruneFlag.Name) {
 		cfg.LightNoPrune = ctx.GlobalBool(LightNoPruneFlag.Name)
 	}
@@ -1157,6 +1429,38 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(MaxMsgSizeFlag.Name) {
+		size := ctx.GlobalUint64(MaxMsgSizeFlag.Name)
+		if size > rlpx.MaxMessageSize {
+			Fatalf("Invalid %s: %d exceeds the transport limit %d", MaxMsgSizeFlag.Name, size, rlpx.MaxMessageSize)
+		}
+		cfg.MaxMsgSize = uint32(size)
+	}
+	if ctx.GlobalIsSet(PeerBanThresholdFlag.Name) {
+		cfg.PeerBanThreshold = ctx.GlobalFloat64(PeerBanThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(PeerBanDurationFlag.Name) {
+		cfg.PeerBanDuration = ctx.GlobalDuration(PeerBanDurationFlag.Name)
+	}
+	if ctx.GlobalIsSet(PeerAllowListFlag.Name) {
+		cfg.PeerAllowList = ctx.GlobalString(PeerAllowListFlag.Name)
+	}
+	if ctx.GlobalIsSet(PeerDenyListFlag.Name) {
+		cfg.PeerDenyList = ctx.GlobalString(PeerDenyListFlag.Name)
+	}
+	if ctx.GlobalIsSet(ZstdProtocolsFlag.Name) {
+		cfg.ZstdProtocols = SplitAndTrim(ctx.GlobalString(ZstdProtocolsFlag.Name))
+	}
+	if ctx.GlobalIsSet(ZstdLevelFlag.Name) {
+		level := ctx.GlobalInt(ZstdLevelFlag.Name)
+		if level < rlpx.MinZstdLevel || level > rlpx.MaxZstdLevel {
+			Fatalf("Invalid %s: %d out of range %d-%d", ZstdLevelFlag.Name, level, rlpx.MinZstdLevel, rlpx.MaxZstdLevel)
+		}
+		cfg.ZstdLevel = level
+	}
+	if ctx.GlobalIsSet(NodeDBLimitFlag.Name) {
+		cfg.NodeDatabaseLimit = ctx.GlobalInt(NodeDBLimitFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1170,6 +1474,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if ctx.GlobalIsSet(PeerExchangeFlag.Name) {
+		cfg.PeerExchange = ctx.GlobalBool(PeerExchangeFlag.Name)
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -1194,11 +1501,27 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
+	setAuthRPC(ctx, cfg)
 	setWS(ctx, cfg)
+	setGateway(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		engine := ctx.GlobalString(DBEngineFlag.Name)
+		if engine != rawdb.EngineLevelDB && engine != rawdb.EnginePebble {
+			Fatalf("--%s must be either '%s' or '%s'", DBEngineFlag.Name, rawdb.EngineLevelDB, rawdb.EnginePebble)
+		}
+		cfg.DBEngine = engine
+	}
+	if ctx.GlobalBool(ReadOnlyFlag.Name) {
+		// Read-only nodes can't process anything received from the network
+		cfg.P2P.MaxPeers = 0
+		cfg.P2P.ListenAddr = ""
+		cfg.P2P.NoDiscovery = true
+		cfg.P2P.DiscoveryV5 = false
+	}
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1285,6 +1608,22 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	}
 }
 
+func setUserOpPool(ctx *cli.Context, cfg *core.UserOpPoolConfig) {
+	if ctx.GlobalIsSet(TxPoolEntryPointsFlag.Name) {
+		entryPoints := strings.Split(ctx.GlobalString(TxPoolEntryPointsFlag.Name), ",")
+		for _, entryPoint := range entryPoints {
+			if trimmed := strings.TrimSpace(entryPoint); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid entry point in --txpool.entrypoints: %s", trimmed)
+			} else {
+				cfg.EntryPoints = append(cfg.EntryPoints, common.HexToAddress(trimmed))
+			}
+		}
+	}
+	if ctx.GlobalIsSet(TxPoolUserOpsFlag.Name) {
+		cfg.MaxOps = ctx.GlobalUint64(TxPoolUserOpsFlag.Name)
+	}
+}
+
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLocalsFlag.Name) {
 		locals := strings.Split(ctx.GlobalString(TxPoolLocalsFlag.Name), ",")
@@ -1296,6 +1635,16 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 			}
 		}
 	}
+	if ctx.GlobalIsSet(TxPoolPriorityFlag.Name) {
+		priority := strings.Split(ctx.GlobalString(TxPoolPriorityFlag.Name), ",")
+		for _, account := range priority {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid account in --txpool.priority: %s", trimmed)
+			} else {
+				cfg.Priority = append(cfg.Priority, common.HexToAddress(trimmed))
+			}
+		}
+	}
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
 	}
@@ -1311,6 +1660,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolPriceBumpFlag.Name) {
 		cfg.PriceBump = ctx.GlobalUint64(TxPoolPriceBumpFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolReplaceByTipFlag.Name) {
+		cfg.ReplaceByTip = ctx.GlobalBool(TxPoolReplaceByTipFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.GlobalUint64(TxPoolAccountSlotsFlag.Name)
 	}
@@ -1377,6 +1729,51 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerBuildDeadlineFlag.Name) {
+		cfg.BuildDeadline = ctx.GlobalDuration(MinerBuildDeadlineFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.Ordering = ctx.GlobalString(MinerOrderingFlag.Name)
+		if err := miner.ValidateOrdering(cfg.Ordering); err != nil {
+			Fatalf("Invalid %s: %v", MinerOrderingFlag.Name, err)
+		}
+	}
+}
+
+// makeOverrideForkFlags creates the --override.<fork> flags of all forks which
+// can be rescheduled at startup.
+func makeOverrideForkFlags() []cli.Flag {
+	var flags []cli.Flag
+	for _, name := range params.OverridableForks() {
+		flags = append(flags, cli.Uint64Flag{
+			Name:  "override." + name,
+			Usage: fmt.Sprintf("Manually specify %s fork-block, overriding the bundled setting", name),
+		})
+	}
+	return flags
+}
+
+func setForkOverrides(ctx *cli.Context, cfg *ethconfig.Config) {
+	if cfg.OverrideBerlin != nil {
+		log.Warn("The config option OverrideBerlin is deprecated, please use ForkOverrides", "berlin", cfg.OverrideBerlin)
+		if cfg.ForkOverrides == nil {
+			cfg.ForkOverrides = make(params.ForkOverrides)
+		}
+		if _, ok := cfg.ForkOverrides["berlin"]; !ok {
+			cfg.ForkOverrides["berlin"] = cfg.OverrideBerlin
+		}
+		cfg.OverrideBerlin = nil
+	}
+	for _, flag := range OverrideForkFlags {
+		name := flag.GetName()
+		if !ctx.GlobalIsSet(name) {
+			continue
+		}
+		if cfg.ForkOverrides == nil {
+			cfg.ForkOverrides = make(params.ForkOverrides)
+		}
+		cfg.ForkOverrides[strings.TrimPrefix(name, "override.")] = new(big.Int).SetUint64(ctx.GlobalUint64(name))
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1402,6 +1799,26 @@ func setWhitelist(ctx *cli.Context, cfg *ethconfig.Config) {
 	}
 }
 
+func setTrustedHead(ctx *cli.Context, cfg *ethconfig.Config) {
+	head := ctx.GlobalString(TrustedHeadFlag.Name)
+	if head == "" {
+		return
+	}
+	parts := strings.Split(head, "=")
+	if len(parts) != 2 {
+		Fatalf("Invalid trusted head: %s", head)
+	}
+	number, err := strconv.ParseUint(parts[0], 0, 64)
+	if err != nil {
+		Fatalf("Invalid trusted head block number %s: %v", parts[0], err)
+	}
+	var hash common.Hash
+	if err = hash.UnmarshalText([]byte(parts[1])); err != nil {
+		Fatalf("Invalid trusted head hash %s: %v", parts[1], err)
+	}
+	cfg.TrustedHead = &downloader.TrustedHead{Number: number, Hash: hash}
+}
+
 // CheckExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1443,12 +1860,25 @@ func CheckExclusive(ctx *cli.Context, args ...interface{}) {
 	}
 }
 
+// dynamicCacheExtra is the memory allowance (MB) added to --cache when caches are
+// sized dynamically. The recent chain data and download queue caches get 64MB
+// each outside of --cache in the static mode, pooling them into the budget must
+// not take that memory away from the other caches.
+const dynamicCacheExtra = 2 * 64
+
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	// Avoid conflicting network flags
 	CheckExclusive(ctx, MainnetFlag, DeveloperFlag, RopstenFlag, RinkebyFlag, GoerliFlag, YoloV3Flag)
 	CheckExclusive(ctx, LightServeFlag, SyncModeFlag, "light")
 	CheckExclusive(ctx, DeveloperFlag, ExternalSignerFlag) // Can't use both ephemeral unlocked and external signer
+	CheckExclusive(ctx, ReadOnlyFlag, MiningEnabledFlag)
+	CheckExclusive(ctx, ReadOnlyFlag, DeveloperFlag)
+	CheckExclusive(ctx, ReadOnlyFlag, LightServeFlag)
+	CheckExclusive(ctx, ReadOnlyFlag, DBIdleCompactionFlag)
+	CheckExclusive(ctx, ReadOnlyFlag, SyncModeFlag, "light")
+	CheckExclusive(ctx, ObserverFlag, MiningEnabledFlag)
+	CheckExclusive(ctx, ObserverFlag, SyncModeFlag, "snap")
 	if ctx.GlobalString(GCModeFlag.Name) == "archive" && ctx.GlobalUint64(TxLookupLimitFlag.Name) != 0 {
 		ctx.GlobalSet(TxLookupLimitFlag.Name, "0")
 		log.Warn("Disable transaction unindexing for archive node")
@@ -1456,6 +1886,23 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(LightServeFlag.Name) && ctx.GlobalUint64(TxLookupLimitFlag.Name) != 0 {
 		log.Warn("LES server cannot serve old transaction status and cannot connect below les/4 protocol version if transaction lookup index is limited")
 	}
+	if ctx.GlobalString(GCModeFlag.Name) == "archive" && (ctx.GlobalUint64(TransactionHistoryFlag.Name) != 0 || ctx.GlobalUint64(LogHistoryFlag.Name) != 0) {
+		Fatalf("Archive nodes must retain the entire chain history")
+	}
+	if ctx.GlobalIsSet(LightServeFlag.Name) && (ctx.GlobalUint64(TransactionHistoryFlag.Name) != 0 || ctx.GlobalUint64(LogHistoryFlag.Name) != 0) {
+		log.Warn("LES server cannot serve the bodies and receipts of blocks older than the history limits")
+	}
+	if ctx.GlobalString(GCModeFlag.Name) == "archive" && ctx.GlobalUint64(StateHistoryFlag.Name) != 0 {
+		log.Warn("Archive nodes retain all historical state, reverse state diffs are redundant")
+	}
+	if ctx.GlobalIsSet(BloomBitsBlocksFlag.Name) {
+		if size := ctx.GlobalUint64(BloomBitsBlocksFlag.Name); size == 0 || size%8 != 0 {
+			Fatalf("--%s must be a non-zero multiple of 8", BloomBitsBlocksFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(LightServeFlag.Name) && ctx.GlobalIsSet(BloomBitsBlocksFlag.Name) && ctx.GlobalUint64(BloomBitsBlocksFlag.Name) != params.BloomBitsBlocks {
+		Fatalf("LES server requires the default --%s of %d", BloomBitsBlocksFlag.Name, params.BloomBitsBlocks)
+	}
 	var ks *keystore.KeyStore
 	if keystores := stack.AccountManager().Backends(keystore.KeyStoreType); len(keystores) > 0 {
 		ks = keystores[0].(*keystore.KeyStore)
@@ -1463,9 +1910,24 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO, ctx.GlobalString(SyncModeFlag.Name) == "light")
 	setTxPool(ctx, &cfg.TxPool)
+	setUserOpPool(ctx, &cfg.UserOpPool)
 	setEthash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setWhitelist(ctx, cfg)
+	setTrustedHead(ctx, cfg)
+	setForkOverrides(ctx, cfg)
+	if ctx.GlobalIsSet(HeadWatchdogFlag.Name) {
+		cfg.HeadWatchdog = ctx.GlobalDuration(HeadWatchdogFlag.Name)
+	}
+	if ctx.GlobalIsSet(HeadWatchdogRotationFlag.Name) {
+		cfg.HeadWatchdogRotation = ctx.GlobalInt(HeadWatchdogRotationFlag.Name)
+	}
+	if ctx.GlobalIsSet(ObserverFlag.Name) {
+		cfg.Observer = ctx.GlobalBool(ObserverFlag.Name)
+	}
+	if ctx.GlobalIsSet(ObserverServeRateFlag.Name) {
+		cfg.ObserverServeRate = ctx.GlobalInt(ObserverServeRateFlag.Name)
+	}
 	setLes(ctx, cfg)
 
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
@@ -1479,7 +1941,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	}
 	cfg.DatabaseHandles = MakeDatabaseHandles()
 	if ctx.GlobalIsSet(AncientFlag.Name) {
-		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+		cfg.DatabaseFreezer = ancientLocation(ctx)
+	}
+	if ctx.GlobalIsSet(ReadOnlyFlag.Name) {
+		cfg.ReadOnly = ctx.GlobalBool(ReadOnlyFlag.Name)
+	}
+	if ctx.GlobalIsSet(DBIdleCompactionFlag.Name) {
+		cfg.DatabaseCompaction = ctx.GlobalBool(DBIdleCompactionFlag.Name)
 	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
@@ -1500,6 +1968,27 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(TransactionHistoryFlag.Name) {
+		cfg.TransactionHistory = ctx.GlobalUint64(TransactionHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogHistoryFlag.Name) {
+		cfg.LogHistory = ctx.GlobalUint64(LogHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalUint64(StateHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(EngineAPIFlag.Name) {
+		cfg.EngineAPI = ctx.GlobalBool(EngineAPIFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(CallIndexFlag.Name) {
+		cfg.CallIndex = ctx.GlobalBool(CallIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(BloomBitsBlocksFlag.Name) {
+		cfg.BloomBitsBlocks = ctx.GlobalUint64(BloomBitsBlocksFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1515,6 +2004,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheSnapshotFlag.Name) {
 		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
 	}
+	if ctx.GlobalBool(CacheDynamicFlag.Name) {
+		// Pool the whole cache allowance with the (otherwise fixed) chain data,
+		// transaction pool and download caches, letting the node move memory to
+		// where it's needed. The clean trie and snapshot caches stay fixed, but
+		// are accounted for so the total isn't overshot.
+		cfg.CacheBudget = cfg.TrieCleanCache + cfg.TrieDirtyCache + cfg.SnapshotCache + dynamicCacheExtra
+	}
 	if !ctx.GlobalBool(SnapshotFlag.Name) {
 		// If snap-sync is requested, this flag is also required
 		if cfg.SyncMode == downloader.SnapSync {
@@ -1550,6 +2046,21 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCGlobalReturnDataCapFlag.Name) {
+		cfg.RPCReturnDataCap = ctx.GlobalUint64(RPCGlobalReturnDataCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCGlobalLogsCapFlag.Name) {
+		cfg.RPCLogsCap = ctx.GlobalUint64(RPCGlobalLogsCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCLogsPageSizeFlag.Name) {
+		cfg.RPCLogsPageSize = ctx.GlobalUint64(RPCLogsPageSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSafeDepthFlag.Name) {
+		cfg.RPCSafeDepth = ctx.GlobalUint64(RPCSafeDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCFinalizedDepthFlag.Name) {
+		cfg.RPCFinalizedDepth = ctx.GlobalUint64(RPCFinalizedDepthFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1664,34 +2175,44 @@ func SetDNSDiscoveryDefaults(cfg *ethconfig.Config, genesis common.Hash) {
 	}
 }
 
-// RegisterEthService adds an Acent client to the stack.
-func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) ethapi.Backend {
+// RegisterEthService adds an Acent client to the stack. The API backend of the
+// client is returned along with the lifecycle of the service behind it.
+func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend, node.Lifecycle) {
 	if cfg.SyncMode == downloader.LightSync {
+		if cfg.EngineAPI {
+			Fatalf("The engine API is not supported in light sync mode")
+		}
 		backend, err := les.New(stack, cfg)
 		if err != nil {
 			Fatalf("Failed to register the Acent service: %v", err)
 		}
 		stack.RegisterAPIs(tracers.APIs(backend.ApiBackend))
-		return backend.ApiBackend
+		return backend.ApiBackend, backend
 	}
 	backend, err := eth.New(stack, cfg)
 	if err != nil {
 		Fatalf("Failed to register the Acent service: %v", err)
 	}
 	if cfg.LightServ > 0 {
-		_, err := les.NewLesServer(stack, backend, cfg)
+		srv, err := les.NewLesServer(stack, backend, cfg)
 		if err != nil {
 			Fatalf("Failed to create the LES server: %v", err)
 		}
+		stack.RequireLifecycle(srv, backend)
+	}
+	if cfg.EngineAPI {
+		if err := catalyst.Register(stack, backend); err != nil {
+			Fatalf("Failed to register the engine API: %v", err)
+		}
 	}
 	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
-	return backend.APIBackend
+	return backend.APIBackend, backend
 }
 
 // RegisterEthStatsService configures the Acent Stats daemon and adds it to
-// the given node.
-func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string) {
-	if err := ethstats.New(stack, backend, backend.Engine(), url); err != nil {
+// the given node, started after the service of the reported backend.
+func RegisterEthStatsService(stack *node.Node, service node.Lifecycle, backend ethapi.Backend, cfg ethstats.Config) {
+	if err := ethstats.New(stack, service, backend, backend.Engine(), cfg); err != nil {
 		Fatalf("Failed to register the Acent Stats service: %v", err)
 	}
 }
@@ -1731,6 +2252,20 @@ func SetupMetrics(ctx *cli.Context) {
 	}
 }
 
+// SetupTracing starts exporting trace spans if enabled.
+func SetupTracing(ctx *cli.Context) {
+	if !ctx.GlobalBool(TracingEnabledFlag.Name) {
+		return
+	}
+	config := tracing.DefaultConfig
+	config.Endpoint = ctx.GlobalString(TracingEndpointFlag.Name)
+	config.Insecure = ctx.GlobalBool(TracingInsecureFlag.Name)
+	config.SampleRatio = ctx.GlobalFloat64(TracingSampleRatioFlag.Name)
+	if err := tracing.Setup(config); err != nil {
+		Fatalf("Failed to set up tracing: %v", err)
+	}
+}
+
 func SplitTagsFlag(tagsFlag string) map[string]string {
 	tags := strings.Split(tagsFlag, ",")
 	tagsMap := map[string]string{}
@@ -1748,6 +2283,16 @@ func SplitTagsFlag(tagsFlag string) map[string]string {
 	return tagsMap
 }
 
+// ancientLocation returns the ancient store location set on the command line,
+// expanding it if it's a directory rather than an object store URL.
+func ancientLocation(ctx *cli.Context) string {
+	location := ctx.GlobalString(AncientFlag.Name)
+	if location == "" || remotefreezer.IsURL(location) {
+		return location
+	}
+	return expandPath(location)
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.Database {
 	var (
@@ -1762,7 +2307,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		chainDb, err = stack.OpenDatabase(name, cache, handles, "", readonly)
 	} else {
 		name := "chaindata"
-		chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "", readonly)
+		chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ancientLocation(ctx), "", readonly)
 	}
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
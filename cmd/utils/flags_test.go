@@ -18,8 +18,13 @@
 package utils
 
 import (
+	"flag"
+	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/acent/go-acent/eth/ethconfig"
+	"gopkg.in/urfave/cli.v1"
 )
 
 func Test_SplitTagsFlag(t *testing.T) {
@@ -62,3 +67,33 @@ func Test_SplitTagsFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestSetForkOverrides(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range OverrideForkFlags {
+		f.Apply(set)
+	}
+	if err := set.Parse([]string{"--override.istanbul", "200"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	// The deprecated Berlin override is moved into the generic overrides
+	cfg := &ethconfig.Config{OverrideBerlin: big.NewInt(100)}
+	setForkOverrides(ctx, cfg)
+	if cfg.OverrideBerlin != nil {
+		t.Errorf("deprecated override not cleared: %v", cfg.OverrideBerlin)
+	}
+	if have := cfg.ForkOverrides["berlin"]; have == nil || have.Uint64() != 100 {
+		t.Errorf("berlin override mismatch: have %v, want 100", have)
+	}
+	if have := cfg.ForkOverrides["istanbul"]; have == nil || have.Uint64() != 200 {
+		t.Errorf("istanbul override mismatch: have %v, want 200", have)
+	}
+	// An explicit generic override takes precedence over the deprecated one
+	cfg = &ethconfig.Config{OverrideBerlin: big.NewInt(100), ForkOverrides: map[string]*big.Int{"berlin": big.NewInt(150)}}
+	setForkOverrides(ctx, cfg)
+	if have := cfg.ForkOverrides["berlin"]; have.Uint64() != 150 {
+		t.Errorf("berlin override mismatch: have %v, want 150", have)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package lru implements byte-size aware least-recently-used caches.
+//
+// Contrary to the item count based caches used throughout the codebase, the
+// caches in this package track the approximate memory footprint of their items
+// and can be resized at runtime, allowing a central memory budget to be shared
+// between them.
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// entry is a single key/value pair tracked by a SizeCache.
+type entry struct {
+	key   interface{}
+	value interface{}
+	size  uint64
+}
+
+// SizeCache is a thread safe LRU cache bounded by the total byte size of the
+// contained items instead of their count.
+type SizeCache struct {
+	limit uint64 // Maximum number of bytes to retain
+	used  uint64 // Number of bytes currently retained
+
+	items map[interface{}]*list.Element
+	order *list.List // Most recently used items at the front
+
+	hits   uint64 // Number of successful lookups
+	misses uint64 // Number of failed lookups
+
+	lock sync.Mutex
+}
+
+// NewSizeCache creates a size bounded LRU cache retaining at most limit bytes.
+func NewSizeCache(limit uint64) *SizeCache {
+	return &SizeCache{
+		limit: limit,
+		items: make(map[interface{}]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Add inserts a value into the cache, accounting it as size bytes. If the key
+// is already present, its value and size are updated. Items that do not fit
+// into the cache at all are not inserted. The number of evicted items is
+// returned.
+func (c *SizeCache) Add(key, value interface{}, size uint64) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	if size > c.limit {
+		return 0
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, size: size})
+	c.used += size
+
+	return c.shrink(c.limit)
+}
+
+// Get looks up a key's value from the cache, marking it as recently used.
+func (c *SizeCache) Get(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		c.hits++
+		return elem.Value.(*entry).value, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// Peek looks up a key's value from the cache without updating its recentness.
+func (c *SizeCache) Peek(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		return elem.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
+// Contains checks whether a key is in the cache without updating its recentness.
+func (c *SizeCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.items[key]
+	return ok
+}
+
+// Remove deletes a key from the cache, returning whether it was contained.
+func (c *SizeCache) Remove(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+		return true
+	}
+	return false
+}
+
+// Purge drops all items from the cache.
+func (c *SizeCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.items = make(map[interface{}]*list.Element)
+	c.order.Init()
+	c.used = 0
+}
+
+// Len returns the number of items in the cache.
+func (c *SizeCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.items)
+}
+
+// Used returns the number of bytes currently accounted to cached items.
+func (c *SizeCache) Used() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.used
+}
+
+// Limit returns the maximum number of bytes the cache may retain.
+func (c *SizeCache) Limit() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.limit
+}
+
+// Stats returns the number of cache hits and misses since creation.
+func (c *SizeCache) Stats() (hits uint64, misses uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.hits, c.misses
+}
+
+// Resize changes the byte limit of the cache, evicting the least recently used
+// items if the new limit is exceeded. The number of evicted items is returned.
+func (c *SizeCache) Resize(limit uint64) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.limit = limit
+	return c.shrink(limit)
+}
+
+// shrink evicts the least recently used items until the cache fits into the
+// given number of bytes. Callers must hold c.lock.
+func (c *SizeCache) shrink(limit uint64) int {
+	var evicted int
+	for c.used > limit {
+		c.removeElement(c.order.Back())
+		evicted++
+	}
+	return evicted
+}
+
+// removeElement drops a list element from the cache. Callers must hold c.lock.
+func (c *SizeCache) removeElement(elem *list.Element) {
+	item := c.order.Remove(elem).(*entry)
+	delete(c.items, item.key)
+	c.used -= item.size
+}
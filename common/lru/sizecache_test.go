@@ -0,0 +1,76 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package lru
+
+import "testing"
+
+// Tests that items are evicted in least-recently-used order once the byte limit
+// is exceeded.
+func TestSizeCacheEviction(t *testing.T) {
+	cache := NewSizeCache(100)
+
+	cache.Add(1, "one", 40)
+	cache.Add(2, "two", 40)
+	if _, ok := cache.Get(1); !ok { // Mark 1 as recently used
+		t.Fatalf("item 1 missing")
+	}
+	if evicted := cache.Add(3, "three", 40); evicted != 1 {
+		t.Fatalf("evicted count mismatch: have %d, want %d", evicted, 1)
+	}
+	if cache.Contains(2) {
+		t.Errorf("least recently used item not evicted")
+	}
+	if !cache.Contains(1) || !cache.Contains(3) {
+		t.Errorf("recently used items evicted")
+	}
+	if used := cache.Used(); used != 80 {
+		t.Errorf("used size mismatch: have %d, want %d", used, 80)
+	}
+	// Oversized items should be rejected outright
+	cache.Add(4, "four", 101)
+	if cache.Contains(4) || cache.Len() != 2 {
+		t.Errorf("oversized item inserted")
+	}
+	// Updating an item should replace its accounted size
+	cache.Add(1, "uno", 10)
+	if used := cache.Used(); used != 50 {
+		t.Errorf("used size mismatch after update: have %d, want %d", used, 50)
+	}
+}
+
+// Tests that resizing the cache evicts items to fit the new limit.
+func TestSizeCacheResize(t *testing.T) {
+	cache := NewSizeCache(100)
+	for i := 0; i < 10; i++ {
+		cache.Add(i, i, 10)
+	}
+	if evicted := cache.Resize(35); evicted != 7 {
+		t.Fatalf("evicted count mismatch: have %d, want %d", evicted, 7)
+	}
+	for i := 0; i < 10; i++ {
+		if want := i >= 7; cache.Contains(i) != want {
+			t.Errorf("item %d: presence mismatch: have %v, want %v", i, !want, want)
+		}
+	}
+	if limit := cache.Limit(); limit != 35 {
+		t.Errorf("limit mismatch: have %d, want %d", limit, 35)
+	}
+	cache.Purge()
+	if cache.Len() != 0 || cache.Used() != 0 {
+		t.Errorf("cache not empty after purge")
+	}
+}
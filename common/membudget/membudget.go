@@ -0,0 +1,188 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package membudget implements a central memory accountant that apportions a
+// fixed memory budget between resizable caches based on the node's workload.
+//
+// Instead of statically slicing the --cache allowance up front, consumers are
+// registered with a minimum allowance and per-phase weights. Whenever the node
+// switches between syncing and serving, the budget is redistributed and each
+// consumer is notified of its new allowance. Caches which can't be resized are
+// reserved a fixed share, so the others are sized around them.
+package membudget
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/acent/go-acent/log"
+)
+
+// Phase is the workload phase the node is currently in.
+type Phase int
+
+const (
+	// PhaseSyncing is active while the node is catching up with the network.
+	// Import heavy caches (dirty trie nodes, download queues) are favoured.
+	PhaseSyncing Phase = iota
+
+	// PhaseServing is active when the node is in sync and serving requests.
+	// Read heavy caches (recent blocks, receipts, RPC buffers) are favoured.
+	PhaseServing
+)
+
+// String implements fmt.Stringer.
+func (p Phase) String() string {
+	switch p {
+	case PhaseSyncing:
+		return "syncing"
+	case PhaseServing:
+		return "serving"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
+// Weights are the relative shares of the budget a consumer is entitled to in
+// the individual workload phases.
+type Weights struct {
+	Syncing uint64
+	Serving uint64
+}
+
+// weight returns the consumer's relative share in the given phase.
+func (w Weights) weight(phase Phase) uint64 {
+	if phase == PhaseSyncing {
+		return w.Syncing
+	}
+	return w.Serving
+}
+
+// consumer is a single resizable memory user tracked by the manager.
+type consumer struct {
+	name      string
+	weights   Weights
+	min       uint64
+	resize    func(uint64)
+	allowance uint64
+}
+
+// Manager apportions a memory budget between a set of registered consumers.
+type Manager struct {
+	total     uint64      // Total number of bytes to apportion
+	phase     Phase       // Current workload phase
+	consumers []*consumer // Registered consumers, in registration order
+
+	lock sync.Mutex
+}
+
+// NewManager creates a memory budget manager apportioning total bytes. The node
+// is considered to be syncing until told otherwise.
+func NewManager(total uint64) *Manager {
+	return &Manager{total: total, phase: PhaseSyncing}
+}
+
+// Register adds a resizable consumer to the budget. The consumer is guaranteed
+// at least min bytes, and shares the remainder of the budget with the others
+// proportionally to its weight in the current phase. The resize callback is
+// invoked with the consumer's new allowance whenever the budget is rebalanced,
+// including once during registration.
+//
+// Callbacks are invoked with the manager's lock held, so they must not call
+// back into the manager.
+func (m *Manager) Register(name string, weights Weights, min uint64, resize func(uint64)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.consumers = append(m.consumers, &consumer{
+		name:    name,
+		weights: weights,
+		min:     min,
+		resize:  resize,
+	})
+	m.rebalance()
+}
+
+// Reserve accounts a fixed size memory user in the budget, such as a cache that
+// can't be resized after creation. The reserved bytes are withheld from the
+// resizable consumers in every phase.
+func (m *Manager) Reserve(name string, size uint64) {
+	m.Register(name, Weights{}, size, nil)
+}
+
+// SetPhase switches the workload phase, rebalancing the budget if it changed.
+func (m *Manager) SetPhase(phase Phase) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.phase == phase {
+		return
+	}
+	log.Info("Rebalancing memory budget", "phase", phase, "total", m.total)
+	m.phase = phase
+	m.rebalance()
+}
+
+// Phase returns the current workload phase.
+func (m *Manager) Phase() Phase {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.phase
+}
+
+// Allowances returns the current allowance of every registered consumer.
+func (m *Manager) Allowances() map[string]uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	allowances := make(map[string]uint64, len(m.consumers))
+	for _, c := range m.consumers {
+		allowances[c.name] += c.allowance
+	}
+	return allowances
+}
+
+// rebalance recalculates the allowance of every consumer: the minimums are
+// handed out first, after which whatever's left is split by weight. If the
+// minimums alone exceed the budget, consumers get only their minimums.
+//
+// Callers must hold m.lock.
+func (m *Manager) rebalance() {
+	var reserved, weights uint64
+	for _, c := range m.consumers {
+		reserved += c.min
+		weights += c.weights.weight(m.phase)
+	}
+	var free uint64
+	if reserved < m.total {
+		free = m.total - reserved
+	}
+	for _, c := range m.consumers {
+		allowance := c.min
+		if weights > 0 {
+			allowance += free * c.weights.weight(m.phase) / weights
+		}
+		if allowance == c.allowance {
+			continue
+		}
+		log.Debug("Updated memory allowance", "consumer", c.name, "phase", m.phase, "old", c.allowance, "new", allowance)
+		c.allowance = allowance
+		if c.resize != nil {
+			c.resize(allowance)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package membudget
+
+import "testing"
+
+// Tests that the budget is split by weight after handing out the minimums, and
+// that switching phases rebalances and notifies the consumers.
+func TestRebalance(t *testing.T) {
+	var trie, chain uint64
+
+	budget := NewManager(1000)
+	budget.Register("trie", Weights{Syncing: 3, Serving: 1}, 100, func(n uint64) { trie = n })
+	budget.Register("chain", Weights{Syncing: 1, Serving: 3}, 100, func(n uint64) { chain = n })
+
+	if trie != 700 || chain != 300 {
+		t.Fatalf("syncing allowances mismatch: have %d/%d, want %d/%d", trie, chain, 700, 300)
+	}
+	budget.SetPhase(PhaseServing)
+	if trie != 300 || chain != 700 {
+		t.Fatalf("serving allowances mismatch: have %d/%d, want %d/%d", trie, chain, 300, 700)
+	}
+	if allowances := budget.Allowances(); allowances["trie"] != trie || allowances["chain"] != chain {
+		t.Fatalf("reported allowances mismatch: have %v", allowances)
+	}
+	if trie+chain > 1000 {
+		t.Fatalf("budget overshot: %d > %d", trie+chain, 1000)
+	}
+}
+
+// Tests that consumers always get their minimum allowance, even if the budget
+// is too small to accommodate everyone.
+func TestMinimumAllowance(t *testing.T) {
+	var a, b uint64
+
+	budget := NewManager(100)
+	budget.Register("a", Weights{Syncing: 1, Serving: 1}, 80, func(n uint64) { a = n })
+	budget.Register("b", Weights{Syncing: 1, Serving: 1}, 80, func(n uint64) { b = n })
+
+	if a != 80 || b != 80 {
+		t.Fatalf("allowances mismatch: have %d/%d, want %d/%d", a, b, 80, 80)
+	}
+}
+
+// Tests that reserved memory is withheld from the resizable consumers, and that
+// the budget is handed back to the import caches when the node falls behind.
+func TestReserveAndResync(t *testing.T) {
+	var trie, chain uint64
+
+	budget := NewManager(1400)
+	budget.Reserve("clean", 400)
+	budget.Register("trie", Weights{Syncing: 3, Serving: 1}, 100, func(n uint64) { trie = n })
+	budget.Register("chain", Weights{Syncing: 1, Serving: 3}, 100, func(n uint64) { chain = n })
+
+	if trie != 700 || chain != 300 {
+		t.Fatalf("syncing allowances mismatch: have %d/%d, want %d/%d", trie, chain, 700, 300)
+	}
+	if allowances := budget.Allowances(); allowances["clean"] != 400 {
+		t.Fatalf("reserved allowance mismatch: have %d, want %d", allowances["clean"], 400)
+	}
+	budget.SetPhase(PhaseServing)
+	if trie != 300 || chain != 700 {
+		t.Fatalf("serving allowances mismatch: have %d/%d, want %d/%d", trie, chain, 300, 700)
+	}
+	budget.SetPhase(PhaseSyncing)
+	if trie != 700 || chain != 300 {
+		t.Fatalf("resync allowances mismatch: have %d/%d, want %d/%d", trie, chain, 700, 300)
+	}
+}
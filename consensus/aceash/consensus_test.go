@@ -23,6 +23,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/acent/go-acent/common"
@@ -139,6 +140,35 @@ func TestDifficultyCalculators(t *testing.T) {
 	}
 }
 
+func TestBlockRewards(t *testing.T) {
+	var (
+		header = &types.Header{Number: big.NewInt(100)}
+		uncles = []*types.Header{{Number: big.NewInt(99)}, {Number: big.NewInt(98)}}
+	)
+	// The default schedule pays the fork rewards
+	reward, uncleRewards := BlockRewards(params.TestChainConfig, header, uncles)
+	if want := big.NewInt(2125e15); reward.Cmp(want) != 0 {
+		t.Errorf("default miner reward mismatch: have %v, want %v", reward, want)
+	}
+	if want := []*big.Int{big.NewInt(1750e15), big.NewInt(1500e15)}; !reflect.DeepEqual(uncleRewards, want) {
+		t.Errorf("default uncle rewards mismatch: have %v, want %v", uncleRewards, want)
+	}
+	// Custom schedules replace both the block and uncle rewards
+	config := *params.TestChainConfig
+	config.Rewards = &params.RewardSchedule{
+		Steps:         []*params.RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(1600)}},
+		UncleDivisor:  16,
+		NephewDivisor: 100,
+	}
+	reward, uncleRewards = BlockRewards(&config, header, uncles)
+	if want := big.NewInt(1632); reward.Cmp(want) != 0 {
+		t.Errorf("custom miner reward mismatch: have %v, want %v", reward, want)
+	}
+	if want := []*big.Int{big.NewInt(700), big.NewInt(600)}; !reflect.DeepEqual(uncleRewards, want) {
+		t.Errorf("custom uncle rewards mismatch: have %v, want %v", uncleRewards, want)
+	}
+}
+
 func BenchmarkDifficultyCalculator(b *testing.B) {
 	x1 := makeDifficultyCalculator(big.NewInt(1000000))
 	x2 := MakeDifficultyCalculatorU256(big.NewInt(1000000))
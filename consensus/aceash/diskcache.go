@@ -0,0 +1,119 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+const (
+	genLockStale = 10 * time.Minute       // Age after which a generation lock is considered abandoned
+	genLockWait  = 30 * time.Second       // Maximum time to wait for another process generating a file
+	genLockPoll  = 100 * time.Millisecond // Interval of checking for the file generated by another process
+
+	pregenWriteRate  = 16 * 1024 * 1024 // Bytes per second written when persisting a pre-generated cache
+	pregenWriteChunk = 1024 * 1024      // Bytes written at once when persisting a pre-generated cache
+)
+
+// lockGeneration claims the generation of the file at path among the processes
+// sharing the directory, so that only one of them generates it and the others
+// map the result. It returns the function releasing the claim, or false if
+// another process is generating the file.
+func lockGeneration(path string) (func(), bool) {
+	lockpath := path + ".lock"
+	for i := 0; i < 2; i++ {
+		lock, err := os.OpenFile(lockpath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			lock.Close()
+			return func() { os.Remove(lockpath) }, true
+		}
+		if !os.IsExist(err) {
+			// Locking isn't possible, the generation will most likely fail on
+			// the same directory too and fall back to memory.
+			return func() {}, true
+		}
+		// Remove locks left over by crashed processes
+		info, err := os.Stat(lockpath)
+		if err != nil || time.Since(info.ModTime()) < genLockStale {
+			return nil, false
+		}
+		os.Remove(lockpath)
+	}
+	return nil, false
+}
+
+// waitForFile waits until another process has generated the file at path and
+// memory maps it.
+func waitForFile(path string, lock bool) (*os.File, mmap.MMap, []uint32, error) {
+	deadline := time.Now().Add(genLockWait)
+	for {
+		dump, mem, buffer, err := memoryMap(path, lock)
+		if err == nil || time.Now().After(deadline) {
+			return dump, mem, buffer, err
+		}
+		time.Sleep(genLockPoll)
+	}
+}
+
+// writeThrottled writes the content of an in-memory buffer into a file in the
+// format of memoryMapAndGenerate, limiting the write rate to avoid IO spikes.
+func writeThrottled(path string, data []uint32, rate int) error {
+	temp := path + "." + strconv.Itoa(rand.Int())
+	dump, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp) // no-op after the rename
+
+	// The file is a memory dump, so keep the native byte order
+	var order binary.ByteOrder = binary.BigEndian
+	if isLittleEndian() {
+		order = binary.LittleEndian
+	}
+	chunk := make([]byte, pregenWriteChunk)
+	for _, words := range [][]uint32{dumpMagic, data} {
+		for len(words) > 0 {
+			n := len(chunk) / 4
+			if n > len(words) {
+				n = len(words)
+			}
+			for i, word := range words[:n] {
+				order.PutUint32(chunk[i*4:], word)
+			}
+			if _, err := dump.Write(chunk[:n*4]); err != nil {
+				dump.Close()
+				return err
+			}
+			words = words[n:]
+			time.Sleep(time.Duration(n*4) * time.Second / time.Duration(rate))
+		}
+	}
+	if err := dump.Sync(); err != nil {
+		dump.Close()
+		return err
+	}
+	if err := dump.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
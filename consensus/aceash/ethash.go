@@ -221,6 +221,19 @@ func newCache(epoch uint64) interface{} {
 
 // generate ensures that the cache content is generated before use.
 func (c *cache) generate(dir string, limit int, lock bool, test bool) {
+	c.generateCache(dir, limit, lock, test, false)
+}
+
+// pregenerate generates the cache ahead of its use. If the cache is stored on
+// disk, it's generated in memory and persisted afterwards with throttled IO, so
+// the cache is ready as soon as possible without disturbing the node.
+func (c *cache) pregenerate(dir string, limit int, lock bool, test bool) {
+	c.generateCache(dir, limit, lock, test, true)
+}
+
+func (c *cache) generateCache(dir string, limit int, lock bool, test bool, background bool) {
+	var persist func() // Deferred disk write of a pre-generated cache
+
 	c.once.Do(func() {
 		size := cacheSize(c.epoch*epochLength + 1)
 		seed := seedHash(c.epoch*epochLength + 1)
@@ -254,13 +267,41 @@ func (c *cache) generate(dir string, limit int, lock bool, test bool) {
 		}
 		logger.Debug("Failed to load old ethash cache", "err", err)
 
-		// No previous cache available, create a new cache file to fill
-		c.dump, c.mmap, c.cache, err = memoryMapAndGenerate(path, size, lock, func(buffer []uint32) { generateCache(buffer, c.epoch, seed) })
-		if err != nil {
-			logger.Error("Failed to generate mapped ethash cache", "err", err)
+		// If another process sharing the directory is generating the cache,
+		// wait for it and map its file instead of generating a copy.
+		release, ok := lockGeneration(path)
+		if !ok {
+			c.dump, c.mmap, c.cache, err = waitForFile(path, lock)
+			if err == nil {
+				logger.Debug("Loaded ethash cache generated by another process")
+				return
+			}
+			logger.Warn("Failed to load ethash cache generated by another process", "err", err)
 
 			c.cache = make([]uint32, size/4)
 			generateCache(c.cache, c.epoch, seed)
+			return
+		}
+		if background {
+			c.cache = make([]uint32, size/4)
+			generateCache(c.cache, c.epoch, seed)
+
+			persist = func() {
+				defer release()
+				if err := writeThrottled(path, c.cache, pregenWriteRate); err != nil {
+					logger.Warn("Failed to persist ethash cache", "err", err)
+				}
+			}
+		} else {
+			// No previous cache available, create a new cache file to fill
+			c.dump, c.mmap, c.cache, err = memoryMapAndGenerate(path, size, lock, func(buffer []uint32) { generateCache(buffer, c.epoch, seed) })
+			release()
+			if err != nil {
+				logger.Error("Failed to generate mapped ethash cache", "err", err)
+
+				c.cache = make([]uint32, size/4)
+				generateCache(c.cache, c.epoch, seed)
+			}
 		}
 		// Iterate over all previous instances and delete old ones
 		for ep := int(c.epoch) - limit; ep >= 0; ep-- {
@@ -269,6 +310,9 @@ func (c *cache) generate(dir string, limit int, lock bool, test bool) {
 			os.Remove(path)
 		}
 	})
+	if persist != nil {
+		persist()
+	}
 }
 
 // finalizer unmaps the memory and closes the file.
@@ -562,7 +606,7 @@ func (ethash *Ethash) cache(block uint64) *cache {
 	// If we need a new future cache, now's a good time to regenerate it.
 	if futureI != nil {
 		future := futureI.(*cache)
-		go future.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.CachesLockMmap, ethash.config.PowMode == ModeTest)
+		go future.pregenerate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.CachesLockMmap, ethash.config.PowMode == ModeTest)
 	}
 	return current
 }
@@ -17,10 +17,13 @@
 package ethash
 
 import (
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -75,6 +78,82 @@ func TestCacheFileEvict(t *testing.T) {
 	wg.Wait()
 }
 
+// testCachePath returns the path of the test mode cache of epoch 0.
+func testCachePath(dir string) string {
+	var endian string
+	if !isLittleEndian() {
+		endian = ".be"
+	}
+	seed := seedHash(1)
+	return filepath.Join(dir, fmt.Sprintf("cache-R%d-%x%s", algorithmRevision, seed[:8], endian))
+}
+
+// Tests that pre-generated caches are persisted for later use.
+func TestCachePregenerate(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	pregen := newCache(0).(*cache)
+	pregen.pregenerate(tmpdir, 1, false, true)
+
+	loaded := newCache(0).(*cache)
+	loaded.generate(tmpdir, 1, false, true)
+	if loaded.dump == nil {
+		t.Fatal("pre-generated cache not loaded from disk")
+	}
+	if !reflect.DeepEqual(loaded.cache, pregen.cache) {
+		t.Error("persisted cache differs from the pre-generated one")
+	}
+	if _, err := os.Stat(testCachePath(tmpdir) + ".lock"); !os.IsNotExist(err) {
+		t.Error("generation lock not released:", err)
+	}
+}
+
+// Tests that a cache being generated by another process is shared instead of
+// being generated again.
+func TestCacheSharedGeneration(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "ethash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Claim the generation as if another process was generating the cache
+	path := testCachePath(tmpdir)
+	release, ok := lockGeneration(path)
+	if !ok {
+		t.Fatal("can't claim generation")
+	}
+	if _, ok := lockGeneration(path); ok {
+		t.Fatal("generation claimed twice")
+	}
+	c := newCache(0).(*cache)
+	done := make(chan struct{})
+	go func() {
+		c.generate(tmpdir, 1, false, true)
+		close(done)
+	}()
+
+	want := make([]uint32, 1024/4)
+	generateCache(want, 0, seedHash(1))
+	time.Sleep(2 * genLockPoll)
+	if err := writeThrottled(path, want, pregenWriteRate); err != nil {
+		t.Fatal("can't write cache:", err)
+	}
+	release()
+	<-done
+
+	if c.dump == nil {
+		t.Fatal("cache generated by the other process not loaded")
+	}
+	if !reflect.DeepEqual(c.cache, want) {
+		t.Error("loaded cache differs from the generated one")
+	}
+}
+
 func verifyTest(wg *sync.WaitGroup, e *Ethash, workerIndex, epochs int) {
 	defer wg.Done()
 
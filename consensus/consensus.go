@@ -118,6 +118,20 @@ type Engine interface {
 	Close() error
 }
 
+// Finality is a consensus engine which knows the blocks of the chain that are no
+// longer subject to reorgs.
+type Finality interface {
+	Engine
+
+	// SafeHeader returns the latest block unlikely to be reorged, or nil if the
+	// engine can't tell.
+	SafeHeader(chain ChainHeaderReader) *types.Header
+
+	// FinalizedHeader returns the latest block that can't be reorged, or nil if
+	// the engine can't tell.
+	FinalizedHeader(chain ChainHeaderReader) *types.Header
+}
+
 // PoW is a consensus engine based on proof-of-work.
 type PoW interface {
 	Engine
@@ -65,6 +65,17 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	// Sidecars are only propagated next to their transactions, never in blocks
+	blobs := 0
+	for _, tx := range block.Transactions() {
+		if tx.Sidecar() != nil {
+			return fmt.Errorf("sidecar included with transaction %x", tx.Hash())
+		}
+		blobs += len(tx.BlobHashes())
+	}
+	if blobs > params.MaxBlockSidecarBlobs {
+		return fmt.Errorf("too many sidecar blobs: have %d, max %d", blobs, params.MaxBlockSidecarBlobs)
+	}
 	if !v.bc.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
 		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
 			return consensus.ErrUnknownAncestor
@@ -18,6 +18,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -29,7 +30,9 @@ import (
 	"time"
 
 	"github.com/acent/go-acent/common"
+	sizelru "github.com/acent/go-acent/common/lru"
 	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/common/prque"
 	"github.com/acent/go-acent/consensus"
 	"github.com/acent/go-acent/core/rawdb"
@@ -45,6 +48,10 @@ import (
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/trie"
 	lru "github.com/hashicorp/golang-lru"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -71,6 +78,8 @@ var (
 	blockExecutionTimer  = metrics.NewRegisteredTimer("chain/execution", nil)
 	blockWriteTimer      = metrics.NewRegisteredTimer("chain/write", nil)
 
+	blockImportHistogram = metrics.NewRegisteredBucketHistogram("chain/import/seconds", nil, metrics.DefaultBuckets)
+
 	blockReorgMeter         = metrics.NewRegisteredMeter("chain/reorg/executes", nil)
 	blockReorgAddMeter      = metrics.NewRegisteredMeter("chain/reorg/add", nil)
 	blockReorgDropMeter     = metrics.NewRegisteredMeter("chain/reorg/drop", nil)
@@ -79,14 +88,18 @@ var (
 	blockPrefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
 	blockPrefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
 
+	// tracer creates the spans of the block imports. It does nothing unless
+	// tracing is set up by the application.
+	tracer = otel.Tracer("github.com/acent/go-acent/core")
+
 	errInsertionInterrupted = errors.New("insertion is interrupted")
 )
 
 const (
-	bodyCacheLimit      = 256
-	blockCacheLimit     = 256
-	receiptsCacheLimit  = 32
+	chainCacheLimit     = 64 // Default memory allowance (MB) for recent chain data
 	txLookupCacheLimit  = 1024
+	stateDiffCacheLimit = 256
+	stateDiffQueueLimit = 64
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	TriesInMemory       = 128
@@ -128,7 +141,10 @@ type CacheConfig struct {
 	TrieDirtyDisabled   bool          // Whether to disable trie write caching and GC altogether (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
+	ChainCacheLimit     int           // Memory allowance (MB) to use for caching recent blocks, bodies and receipts
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	ReadOnly            bool          // Whether the database is read-only, disabling all chain mutations and repairs
+	StateHistory        uint64        // Number of recent blocks to keep reverse state diffs for (0 = disabled)
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -136,11 +152,12 @@ type CacheConfig struct {
 // defaultCacheConfig are the default caching values if none are specified by the
 // user (also used during testing).
 var defaultCacheConfig = &CacheConfig{
-	TrieCleanLimit: 256,
-	TrieDirtyLimit: 256,
-	TrieTimeLimit:  5 * time.Minute,
-	SnapshotLimit:  256,
-	SnapshotWait:   true,
+	TrieCleanLimit:  256,
+	TrieDirtyLimit:  256,
+	TrieTimeLimit:   5 * time.Minute,
+	SnapshotLimit:   256,
+	ChainCacheLimit: chainCacheLimit,
+	SnapshotWait:    true,
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -173,12 +190,16 @@ type BlockChain struct {
 	//  * nil: disable tx reindexer/deleter, but still index new blocks
 	txLookupLimit uint64
 
+	bodyTail    uint64 // Oldest block whose body is available (atomic access)
+	receiptTail uint64 // Oldest block whose receipts are available (atomic access)
+
 	hc            *HeaderChain
 	rmLogsFeed    event.Feed
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
+	reorgFeed     event.Feed
 	blockProcFeed event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
@@ -188,17 +209,21 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
-	stateCache    state.Database // State database to reuse between imports (contains state cache)
-	bodyCache     *lru.Cache     // Cache for the most recent block bodies
-	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
-	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
-	blockCache    *lru.Cache     // Cache for the most recent entire blocks
-	txLookupCache *lru.Cache     // Cache for the most recent transaction lookup data.
-	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
+	stateCache    state.Database     // State database to reuse between imports (contains state cache)
+	bodyCache     *sizelru.SizeCache // Cache for the most recent block bodies
+	bodyRLPCache  *sizelru.SizeCache // Cache for the most recent block bodies in RLP encoded format
+	receiptsCache *sizelru.SizeCache // Cache for the most recent receipts per block
+	blockCache    *sizelru.SizeCache // Cache for the most recent entire blocks
+	txLookupCache *lru.Cache         // Cache for the most recent transaction lookup data.
+	futureBlocks  *lru.Cache         // future blocks are blocks added for later processing
+
+	stateDiffCache *lru.Cache          // Cache for the most recent decoded reverse state diffs
+	stateDiffCh    chan *stateDiffTask // Canonical blocks whose reverse state diffs are to be recorded
 
 	quit          chan struct{}  // blockchain quit channel
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 	running       int32          // 0 if chain is running, 1 when stopped
+	dirtyLimit    uint64         // Memory limit (bytes) for dirty trie nodes, accessed atomically
 	procInterrupt int32          // interrupt signaler for block processing
 
 	engine     consensus.Engine
@@ -219,12 +244,19 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
-	bodyCache, _ := lru.New(bodyCacheLimit)
-	bodyRLPCache, _ := lru.New(bodyCacheLimit)
-	receiptsCache, _ := lru.New(receiptsCacheLimit)
-	blockCache, _ := lru.New(blockCacheLimit)
+	limit := cacheConfig.ChainCacheLimit
+	if limit <= 0 {
+		limit = chainCacheLimit
+	}
+	bodyLimit, bodyRLPLimit, receiptsLimit, blockLimit := chainCacheAllowance(uint64(limit) * 1024 * 1024)
+
+	bodyCache := sizelru.NewSizeCache(bodyLimit)
+	bodyRLPCache := sizelru.NewSizeCache(bodyRLPLimit)
+	receiptsCache := sizelru.NewSizeCache(receiptsLimit)
+	blockCache := sizelru.NewSizeCache(blockLimit)
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
+	stateDiffCache, _ := lru.New(stateDiffCacheLimit)
 
 	bc := &BlockChain{
 		chainConfig: chainConfig,
@@ -244,8 +276,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		blockCache:     blockCache,
 		txLookupCache:  txLookupCache,
 		futureBlocks:   futureBlocks,
+		stateDiffCache: stateDiffCache,
+		stateDiffCh:    make(chan *stateDiffTask, stateDiffQueueLimit),
 		engine:         engine,
 		vmConfig:       vmConfig,
+		dirtyLimit:     uint64(cacheConfig.TrieDirtyLimit) * 1024 * 1024,
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
@@ -269,6 +304,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var txIndexBlock uint64
 
 	if bc.empty() {
+		if bc.cacheConfig.ReadOnly {
+			return nil, errors.New("empty database can't be opened read-only")
+		}
 		rawdb.InitDatabaseFromFreezer(bc.db)
 		// If ancient database is not empty, reconstruct all missing
 		// indices in the background.
@@ -282,7 +320,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	// Make sure the state associated with the block is available
 	head := bc.CurrentBlock()
-	if _, err := state.New(head.Root(), bc.stateCache, bc.snaps); err != nil {
+	if _, err := state.New(head.Root(), bc.stateCache, bc.snaps); err != nil && bc.cacheConfig.ReadOnly {
+		log.Warn("Head state missing, serving read-only chain without repair", "number", head.Number(), "hash", head.Hash())
+	} else if err != nil {
 		// Head state is missing, before the state recovery, find out the
 		// disk layer point of snapshot(if it's enabled). Make sure the
 		// rewound point is lower than disk layer.
@@ -332,7 +372,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 				low = fastBlock.NumberU64()
 			}
 		}
-		if needRewind {
+		if needRewind && bc.cacheConfig.ReadOnly {
+			log.Warn("Ancient chain ahead of head, not truncating read-only chain", "head", low, "ancients", frozen)
+		} else if needRewind {
 			log.Error("Truncating ancient chain", "from", bc.CurrentHeader().Number.Uint64(), "to", low)
 			if err := bc.SetHead(low); err != nil {
 				return nil, err
@@ -351,6 +393,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			headerByNumber := bc.GetHeaderByNumber(header.Number.Uint64())
 			// make sure the headerByNumber (if present) is in our current canonical chain
 			if headerByNumber != nil && headerByNumber.Hash() == header.Hash() {
+				if bc.cacheConfig.ReadOnly {
+					log.Error("Found bad hash in read-only chain", "number", header.Number, "hash", hash)
+					continue
+				}
 				log.Error("Found bad hash, rewinding chain", "number", header.Number, "hash", header.ParentHash)
 				if err := bc.SetHead(header.Number.Uint64() - 1); err != nil {
 					return nil, err
@@ -359,8 +405,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
-	// Load any existing snapshot, regenerating it if loading failed
-	if bc.cacheConfig.SnapshotLimit > 0 {
+	// Load any existing snapshot, regenerating it if loading failed. Snapshots
+	// are maintained on disk, so they're not available on read-only chains.
+	if bc.cacheConfig.SnapshotLimit > 0 && !bc.cacheConfig.ReadOnly {
 		// If the chain was rewound past the snapshot persistent layer (causing
 		// a recovery block number to be persisted to disk), check if we're still
 		// in recovery mode and in that case, don't invalidate the snapshot on a
@@ -374,16 +421,22 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		}
 		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
 	}
+	bc.bodyTail, bc.receiptTail = rawdb.ReadHistoryTails(bc.db)
+
 	// Take ownership of this particular state
 	go bc.update()
-	if txLookupLimit != nil {
+	if bc.cacheConfig.StateHistory > 0 && !bc.cacheConfig.ReadOnly {
+		bc.wg.Add(1)
+		go bc.stateDiffLoop()
+	}
+	if txLookupLimit != nil && !bc.cacheConfig.ReadOnly {
 		bc.txLookupLimit = *txLookupLimit
 
 		bc.wg.Add(1)
 		go bc.maintainTxIndex(txIndexBlock)
 	}
 	// If periodic cache journal is required, spin it up.
-	if bc.cacheConfig.TrieCleanRejournal > 0 {
+	if bc.cacheConfig.TrieCleanRejournal > 0 && !bc.cacheConfig.ReadOnly {
 		if bc.cacheConfig.TrieCleanRejournal < time.Minute {
 			log.Warn("Sanitizing invalid trie cache journal time", "provided", bc.cacheConfig.TrieCleanRejournal, "updated", time.Minute)
 			bc.cacheConfig.TrieCleanRejournal = time.Minute
@@ -490,6 +543,9 @@ func (bc *BlockChain) SetHead(head uint64) error {
 //
 // The method returns the block number where the requested root cap was found.
 func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64, error) {
+	if bc.cacheConfig.ReadOnly {
+		return 0, ErrReadOnlyChain
+	}
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
@@ -627,6 +683,9 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 // FastSyncCommitHead sets the current head block to the one defined by the hash
 // irrelevant what the chain contents were prior.
 func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
+	if bc.cacheConfig.ReadOnly {
+		return ErrReadOnlyChain
+	}
 	// Make sure that both the block as well at its state trie exists
 	block := bc.GetBlockByHash(hash)
 	if block == nil {
@@ -763,6 +822,64 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	return nil
 }
 
+// ExportList writes a subset of the active chain to the given writer as a single
+// RLP list of blocks. The blocks are assembled from their stored encodings, read
+// from the freezer for ancient blocks, and streamed one by one: the list is never
+// held in memory, the chain is read twice instead to size it upfront.
+func (bc *BlockChain) ExportList(w io.Writer, first uint64, last uint64) error {
+	bc.chainmu.RLock()
+	defer bc.chainmu.RUnlock()
+
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	// blockRLP returns the encoded header and body content of a canonical block
+	blockRLP := func(nr uint64) (rlp.RawValue, []byte, error) {
+		hash := rawdb.ReadCanonicalHash(bc.db, nr)
+		header, body := rawdb.ReadHeaderRLP(bc.db, hash, nr), rawdb.ReadBodyRLP(bc.db, hash, nr)
+		if hash == (common.Hash{}) || len(header) == 0 || len(body) == 0 {
+			return nil, nil, fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		content, _, err := rlp.SplitList(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("export failed on #%d: %v", nr, err)
+		}
+		return header, content, nil
+	}
+	log.Info("Sizing batch of blocks", "count", last-first+1)
+
+	var size uint64
+	for nr := first; nr <= last; nr++ {
+		header, body, err := blockRLP(nr)
+		if err != nil {
+			return err
+		}
+		size += rlp.ListSize(uint64(len(header) + len(body)))
+	}
+	log.Info("Exporting batch of blocks", "count", last-first+1, "size", common.StorageSize(size))
+
+	lw, err := rlp.NewListWriter(w, size)
+	if err != nil {
+		return err
+	}
+	start, reported := time.Now(), time.Now()
+	for nr := first; nr <= last; nr++ {
+		header, body, err := blockRLP(nr)
+		if err != nil {
+			return err
+		}
+		// A block is the list of its header followed by the content of its body
+		if err := lw.Encode([]rlp.RawValue{header, body}); err != nil {
+			return err
+		}
+		if time.Since(reported) >= statsReportLimit {
+			log.Info("Exporting blocks", "exported", nr-first, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	return lw.Close()
+}
+
 // writeHeadBlock injects a new head block into the current block chain. This method
 // assumes that the block is indeed a true head. It will also reset the head
 // header and the head fast sync block to this very same block if they are older
@@ -796,6 +913,11 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	}
 	bc.currentBlock.Store(block)
 	headBlockGauge.Update(int64(block.NumberU64()))
+
+	// Record how the block changed the state now that it's canonical
+	if bc.cacheConfig.StateHistory > 0 && !bc.cacheConfig.ReadOnly && block.NumberU64() > 0 {
+		bc.queueStateDiff(block)
+	}
 }
 
 // Genesis retrieves the chain's genesis block.
@@ -820,7 +942,7 @@ func (bc *BlockChain) GetBody(hash common.Hash) *types.Body {
 		return nil
 	}
 	// Cache the found body for next time and return
-	bc.bodyCache.Add(hash, body)
+	bc.bodyCache.Add(hash, body, bodySize(body))
 	return body
 }
 
@@ -840,7 +962,7 @@ func (bc *BlockChain) GetBodyRLP(hash common.Hash) rlp.RawValue {
 		return nil
 	}
 	// Cache the found body for next time and return
-	bc.bodyRLPCache.Add(hash, body)
+	bc.bodyRLPCache.Add(hash, body, uint64(len(body)))
 	return body
 }
 
@@ -892,7 +1014,7 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 		return nil
 	}
 	// Cache the found block for next time and return
-	bc.blockCache.Add(block.Hash(), block)
+	bc.blockCache.Add(block.Hash(), block, uint64(block.Size()))
 	return block
 }
 
@@ -928,7 +1050,7 @@ func (bc *BlockChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
 	if receipts == nil {
 		return nil
 	}
-	bc.receiptsCache.Add(hash, receipts)
+	bc.receiptsCache.Add(hash, receipts, receiptsSize(receipts))
 	return receipts
 }
 
@@ -997,7 +1119,13 @@ func (bc *BlockChain) Stop() {
 	close(bc.quit)
 	bc.StopInsert()
 	bc.wg.Wait()
+	bc.drainStateDiffs()
 
+	// Nothing was changed on a read-only chain, and nothing can be persisted
+	if bc.cacheConfig.ReadOnly {
+		log.Info("Blockchain stopped")
+		return
+	}
 	// Ensure that the entirety of the state snapshot is journalled to disk.
 	var snapBase common.Hash
 	if bc.snaps != nil {
@@ -1023,7 +1151,9 @@ func (bc *BlockChain) Stop() {
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
-
+				if recent == nil {
+					continue // Block body missing, e.g. chain bootstrapped from a snapshot archive
+				}
 				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
 				if err := triedb.Commit(recent.Root(), true, nil); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
@@ -1132,6 +1262,9 @@ type numberHash struct {
 // InsertReceiptChain attempts to complete an already existing header chain with
 // transaction and receipt data.
 func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain []types.Receipts, ancientLimit uint64) (int, error) {
+	if bc.cacheConfig.ReadOnly {
+		return 0, ErrReadOnlyChain
+	}
 	// We don't require the chainMu here since we want to maximize the
 	// concurrency of header insertion and receipt insertion.
 	bc.wg.Add(1)
@@ -1456,6 +1589,33 @@ func (bc *BlockChain) TxLookupLimit() uint64 {
 	return bc.txLookupLimit
 }
 
+// HistoryTails returns the numbers of the oldest blocks whose bodies and receipts
+// are available, older ones having been skipped by the sync. Zero means the
+// entire history is available.
+func (bc *BlockChain) HistoryTails() (bodies uint64, receipts uint64) {
+	return atomic.LoadUint64(&bc.bodyTail), atomic.LoadUint64(&bc.receiptTail)
+}
+
+// SetHistoryTails updates and persists the numbers of the oldest blocks whose
+// bodies and receipts are available.
+func (bc *BlockChain) SetHistoryTails(bodies uint64, receipts uint64) {
+	rawdb.WriteHistoryTails(bc.db, bodies, receipts)
+	atomic.StoreUint64(&bc.bodyTail, bodies)
+	atomic.StoreUint64(&bc.receiptTail, receipts)
+}
+
+// CheckHistory returns a HistoryUnavailableError if the body, or the receipts if
+// requested, of the given block were skipped by the sync.
+func (bc *BlockChain) CheckHistory(number uint64, receipts bool) error {
+	if tail := atomic.LoadUint64(&bc.bodyTail); number < tail {
+		return &HistoryUnavailableError{Kind: "body", Number: number, Tail: tail}
+	}
+	if tail := atomic.LoadUint64(&bc.receiptTail); receipts && number < tail {
+		return &HistoryUnavailableError{Kind: "receipts", Number: number, Tail: tail}
+	}
+	return nil
+}
+
 var lastWrite uint64
 
 // writeBlockWithoutState writes only the block and its metadata to the database,
@@ -1492,6 +1652,9 @@ func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
 
 // WriteBlockWithState writes the block and all associated state to the database.
 func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.Receipt, logs []*types.Log, state *state.StateDB, emitHeadEvent bool) (status WriteStatus, err error) {
+	if bc.cacheConfig.ReadOnly {
+		return NonStatTy, ErrReadOnlyChain
+	}
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
@@ -1547,7 +1710,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			// If we exceeded our memory allowance, flush matured singleton nodes to disk
 			var (
 				nodes, imgs = triedb.Size()
-				limit       = common.StorageSize(bc.cacheConfig.TrieDirtyLimit) * 1024 * 1024
+				limit       = common.StorageSize(atomic.LoadUint64(&bc.dirtyLimit))
 			)
 			if nodes > limit || imgs > 4*1024*1024 {
 				triedb.Cap(limit - ethdb.IdealBatchSize)
@@ -1662,6 +1825,9 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 	if len(chain) == 0 {
 		return 0, nil
 	}
+	if bc.cacheConfig.ReadOnly {
+		return 0, ErrReadOnlyChain
+	}
 
 	bc.blockProcFeed.Send(true)
 	defer bc.blockProcFeed.Send(false)
@@ -1684,15 +1850,75 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 		}
 	}
 	// Pre-checks passed, start the full block imports
+	ctx, span := tracer.Start(context.Background(), "core.InsertChain", trace.WithAttributes(
+		attribute.Int("blocks", len(chain)),
+		attribute.Int64("first", chain[0].Number().Int64()),
+		attribute.Int64("last", chain[len(chain)-1].Number().Int64()),
+	))
+	defer span.End()
+
 	bc.wg.Add(1)
 	bc.chainmu.Lock()
-	n, err := bc.insertChain(chain, true)
+	n, err := bc.insertChain(ctx, chain, true)
 	bc.chainmu.Unlock()
 	bc.wg.Done()
 
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
 	return n, err
 }
 
+// InsertBlockWithoutSealVerification works exactly the same as InsertChain for
+// a single block, except that its seal is not verified. It is meant for blocks
+// agreed on by an external consensus client, which aren't sealed by the engine.
+func (bc *BlockChain) InsertBlockWithoutSealVerification(block *types.Block) (int, error) {
+	if bc.cacheConfig.ReadOnly {
+		return 0, ErrReadOnlyChain
+	}
+	bc.blockProcFeed.Send(true)
+	defer bc.blockProcFeed.Send(false)
+
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	return bc.insertChain(context.Background(), types.Blocks{block}, false)
+}
+
+// SetCanonical makes the given block, whose state must be available, the head
+// of the canonical chain regardless of its total difficulty, reorganising the
+// chain if needed. It lets an external consensus client choose the chain head.
+// Rewinding to an ancestor of the current head is left to SetHead.
+func (bc *BlockChain) SetCanonical(head *types.Block) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	if !bc.HasBlockAndState(head.Hash(), head.NumberU64()) {
+		return fmt.Errorf("unknown block or missing state #%d [%x…]", head.NumberU64(), head.Hash().Bytes()[:4])
+	}
+	current := bc.CurrentBlock()
+	if head.Hash() == current.Hash() {
+		return nil
+	}
+	if head.NumberU64() < current.NumberU64() && bc.GetCanonicalHash(head.NumberU64()) == head.Hash() {
+		return fmt.Errorf("block #%d [%x…] is an ancestor of the head", head.NumberU64(), head.Hash().Bytes()[:4])
+	}
+	if head.ParentHash() != current.Hash() {
+		if err := bc.reorg(current, head); err != nil {
+			return err
+		}
+	}
+	bc.writeHeadBlock(head)
+
+	bc.chainFeed.Send(ChainEvent{Block: head, Hash: head.Hash()})
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+	log.Info("Chain head was updated", "number", head.Number(), "hash", head.Hash())
+	return nil
+}
+
 // insertChain is the internal implementation of InsertChain, which assumes that
 // 1) chains are contiguous, and 2) The chain mutex is held.
 //
@@ -1701,7 +1927,7 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // racey behaviour. If a sidechain import is in progress, and the historic state
 // is imported, but then new canon-head is added before the actual sidechain
 // completes, then the historic state could be pruned again
-func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, error) {
+func (bc *BlockChain) insertChain(ctx context.Context, chain types.Blocks, verifySeals bool) (int, error) {
 	// If the chain is terminating, don't even bother starting up
 	if atomic.LoadInt32(&bc.procInterrupt) == 1 {
 		return 0, nil
@@ -1780,7 +2006,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	// First block is pruned, insert as sidechain and reorg only if TD grows enough
 	case errors.Is(err, consensus.ErrPrunedAncestor):
 		log.Debug("Pruned ancestor, inserting as sidechain", "number", block.Number(), "hash", block.Hash())
-		return bc.insertSideChain(block, it)
+		return bc.insertSideChain(ctx, block, it)
 
 	// First block is future, shove it (and all children) to the future queue (unknown ancestor)
 	case errors.Is(err, consensus.ErrFutureBlock) || (errors.Is(err, consensus.ErrUnknownAncestor) && bc.futureBlocks.Contains(it.first().ParentHash())):
@@ -1947,6 +2173,8 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		blockWriteTimer.Update(time.Since(substart) - statedb.AccountCommits - statedb.StorageCommits - statedb.SnapshotCommits)
 		blockInsertTimer.UpdateSince(start)
+		blockImportHistogram.Observe(time.Since(start).Seconds())
+		traceBlockImport(ctx, block, start, proctime, status)
 
 		switch status {
 		case CanonStatTy:
@@ -1999,13 +2227,28 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	return it.index, err
 }
 
+// traceBlockImport records the span of a successful block import after the
+// fact, marking the end of its processing (execution and validation) with an
+// event, so that no span is left open on the many error paths.
+func traceBlockImport(ctx context.Context, block *types.Block, start time.Time, proctime time.Duration, status WriteStatus) {
+	_, span := tracer.Start(ctx, "core.insertBlock", trace.WithTimestamp(start), trace.WithAttributes(
+		attribute.Int64("number", block.Number().Int64()),
+		attribute.String("hash", block.Hash().Hex()),
+		attribute.Int("txs", len(block.Transactions())),
+		attribute.Int64("gas", int64(block.GasUsed())),
+		attribute.Bool("canonical", status == CanonStatTy),
+	))
+	span.AddEvent("processed", trace.WithTimestamp(start.Add(proctime)))
+	span.End()
+}
+
 // insertSideChain is called when an import batch hits upon a pruned ancestor
 // error, which happens when a sidechain with a sufficiently old fork-block is
 // found.
 //
 // The method writes all (header-and-body-valid) blocks to disk, then tries to
 // switch over to the new chain if the TD exceeded the current chain.
-func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator) (int, error) {
+func (bc *BlockChain) insertSideChain(ctx context.Context, block *types.Block, it *insertIterator) (int, error) {
 	var (
 		externTd *big.Int
 		current  = bc.CurrentBlock()
@@ -2103,7 +2346,7 @@ func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator) (i
 		// memory here.
 		if len(blocks) >= 2048 || memory > 64*1024*1024 {
 			log.Info("Importing heavy sidechain segment", "blocks", len(blocks), "start", blocks[0].NumberU64(), "end", block.NumberU64())
-			if _, err := bc.insertChain(blocks, false); err != nil {
+			if _, err := bc.insertChain(ctx, blocks, false); err != nil {
 				return 0, err
 			}
 			blocks, memory = blocks[:0], 0
@@ -2117,7 +2360,7 @@ func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator) (i
 	}
 	if len(blocks) > 0 {
 		log.Info("Importing sidechain segment", "start", blocks[0].NumberU64(), "end", blocks[len(blocks)-1].NumberU64())
-		return bc.insertChain(blocks, false)
+		return bc.insertChain(ctx, blocks, false)
 	}
 	return 0, nil
 }
@@ -2239,6 +2482,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		blockReorgAddMeter.Mark(int64(len(newChain)))
 		blockReorgDropMeter.Mark(int64(len(oldChain)))
 		blockReorgMeter.Mark(1)
+	} else if len(newChain) > 0 {
+		// The old head is an ancestor of the new one, which happens if an external
+		// consensus client moves the head forward by multiple blocks
+		log.Info("Extend chain", "add", len(newChain), "number", newChain[0].Number(), "hash", newChain[0].Hash())
+		blockReorgAddMeter.Mark(int64(len(newChain)))
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
@@ -2287,9 +2535,35 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			bc.chainSideFeed.Send(ChainSideEvent{Block: oldChain[i]})
 		}
 	}
+	if len(oldChain) > 0 && len(newChain) > 0 {
+		bc.reorgFeed.Send(newReorgEvent(commonBlock, oldChain, newChain, deletedTxs))
+	}
 	return nil
 }
 
+// newReorgEvent assembles the event of a reorg from the common ancestor and the
+// dropped and added blocks in descending order, as collected by reorg.
+func newReorgEvent(ancestor *types.Block, oldChain, newChain types.Blocks, deletedTxs types.Transactions) ReorgEvent {
+	ev := ReorgEvent{
+		CommonAncestor: ancestor,
+		Dropped:        make(types.Blocks, len(oldChain)),
+		Added:          make(types.Blocks, len(newChain)),
+	}
+	var addedTxs types.Transactions
+	for i, block := range oldChain {
+		ev.Dropped[len(oldChain)-1-i] = block
+	}
+	for i, block := range newChain {
+		ev.Added[len(newChain)-1-i] = block
+	}
+	for _, block := range ev.Added {
+		addedTxs = append(addedTxs, block.Transactions()...)
+	}
+	ev.DroppedTxs = types.TxDifference(deletedTxs, addedTxs)
+	ev.AddedTxs = types.TxDifference(addedTxs, deletedTxs)
+	return ev
+}
+
 func (bc *BlockChain) update() {
 	futureTimer := time.NewTicker(5 * time.Second)
 	defer futureTimer.Stop()
@@ -2421,6 +2695,9 @@ Error: %v
 // of the header retrieval mechanisms already need to verify nonces, as well as
 // because nonces can be verified sparsely, not needing to check each.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (int, error) {
+	if bc.cacheConfig.ReadOnly {
+		return 0, ErrReadOnlyChain
+	}
 	start := time.Now()
 	if i, err := bc.hc.ValidateHeaderChain(chain, checkFreq); err != nil {
 		return i, err
@@ -2525,6 +2802,11 @@ func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) even
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
 }
 
+// SubscribeReorgEvent registers a subscription of ReorgEvent.
+func (bc *BlockChain) SubscribeReorgEvent(ch chan<- ReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // SubscribeChainEvent registers a subscription of ChainEvent.
 func (bc *BlockChain) SubscribeChainEvent(ch chan<- ChainEvent) event.Subscription {
 	return bc.scope.Track(bc.chainFeed.Subscribe(ch))
@@ -2550,3 +2832,61 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
 	return bc.scope.Track(bc.blockProcFeed.Subscribe(ch))
 }
+
+// RegisterMemoryBudget hands the sizing of the chain's resizable caches over to
+// a global memory budget: the dirty trie node limit is favoured during sync to
+// reduce database writes, whereas the recent chain data caches are favoured
+// while serving to speed up RPC and peer requests. The fixed size clean trie
+// and snapshot caches are reserved their configured allowance, the contract
+// code cache is kept off-heap outside of the budget.
+func (bc *BlockChain) RegisterMemoryBudget(budget *membudget.Manager) {
+	budget.Register("chain", membudget.Weights{Syncing: 1, Serving: 4}, 16*1024*1024, func(allowance uint64) {
+		body, bodyRLP, receipts, block := chainCacheAllowance(allowance)
+		bc.bodyCache.Resize(body)
+		bc.bodyRLPCache.Resize(bodyRLP)
+		bc.receiptsCache.Resize(receipts)
+		bc.blockCache.Resize(block)
+	})
+	// The clean trie and snapshot caches can't be resized, account them as is
+	budget.Reserve("trie/clean", uint64(bc.cacheConfig.TrieCleanLimit)*1024*1024)
+	budget.Reserve("snapshot", uint64(bc.cacheConfig.SnapshotLimit)*1024*1024)
+
+	if bc.cacheConfig.TrieDirtyDisabled {
+		return // Archive nodes flush every trie, nothing to size
+	}
+	budget.Register("trie/dirty", membudget.Weights{Syncing: 6, Serving: 3}, 64*1024*1024, func(allowance uint64) {
+		atomic.StoreUint64(&bc.dirtyLimit, allowance)
+	})
+}
+
+// chainCacheAllowance splits the memory allowance for recent chain data between
+// the individual caches. Entire blocks are the most requested items (RPC, block
+// propagation), followed by bodies for serving peers and receipts for log queries.
+func chainCacheAllowance(total uint64) (body, bodyRLP, receipts, block uint64) {
+	body = total / 5
+	bodyRLP = total / 5
+	receipts = total / 10
+	block = total - body - bodyRLP - receipts
+	return body, bodyRLP, receipts, block
+}
+
+// bodySize approximates the memory footprint of a block body.
+func bodySize(body *types.Body) uint64 {
+	var size common.StorageSize
+	for _, tx := range body.Transactions {
+		size += tx.Size()
+	}
+	for _, uncle := range body.Uncles {
+		size += uncle.Size()
+	}
+	return uint64(size)
+}
+
+// receiptsSize approximates the memory footprint of a block's receipts.
+func receiptsSize(receipts types.Receipts) uint64 {
+	var size common.StorageSize
+	for _, receipt := range receipts {
+		size += receipt.Size()
+	}
+	return uint64(size)
+}
@@ -0,0 +1,210 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+)
+
+var (
+	// errStateHistoryDisabled is returned when requesting historical state from a
+	// chain which doesn't keep state history.
+	errStateHistoryDisabled = errors.New("state history disabled")
+
+	// errChainStopped is returned when waiting for the state history of a chain
+	// which is being stopped.
+	errChainStopped = errors.New("blockchain stopped")
+)
+
+// stateDiffTask is a canonical block whose reverse state diff is to be recorded
+// in the background, or a flush request if the block is nil.
+type stateDiffTask struct {
+	block      *types.Block
+	parentRoot common.Hash
+	done       chan struct{} // Closed once all the earlier tasks are done, for flushes
+}
+
+// queueStateDiff schedules the recording of the reverse state diff of a block
+// which became canonical. The states of the block and its parent are referenced
+// until the diff is computed, so that they're not garbage collected meanwhile.
+func (bc *BlockChain) queueStateDiff(block *types.Block) {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return
+	}
+	triedb := bc.stateCache.TrieDB()
+	triedb.Reference(parent.Root, common.Hash{})
+	triedb.Reference(block.Root(), common.Hash{})
+
+	task := &stateDiffTask{block: block, parentRoot: parent.Root}
+	select {
+	case bc.stateDiffCh <- task:
+	case <-bc.quit:
+		bc.writeStateDiff(task)
+	}
+}
+
+// flushStateDiffs waits until the reverse state diffs of all the blocks queued
+// so far are recorded.
+func (bc *BlockChain) flushStateDiffs() error {
+	if bc.cacheConfig.ReadOnly {
+		return nil // Nothing is queued on a read-only chain
+	}
+	task := &stateDiffTask{done: make(chan struct{})}
+	select {
+	case bc.stateDiffCh <- task:
+	case <-bc.quit:
+		return errChainStopped
+	}
+	select {
+	case <-task.done:
+		return nil
+	case <-bc.quit:
+		return errChainStopped
+	}
+}
+
+// stateDiffLoop records the reverse state diffs of the queued canonical blocks
+// off the block insertion path until termination.
+func (bc *BlockChain) stateDiffLoop() {
+	defer bc.wg.Done()
+
+	for {
+		select {
+		case task := <-bc.stateDiffCh:
+			bc.writeStateDiff(task)
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// drainStateDiffs records the reverse state diffs still queued on shutdown,
+// releasing the states they reference.
+func (bc *BlockChain) drainStateDiffs() {
+	for {
+		select {
+		case task := <-bc.stateDiffCh:
+			bc.writeStateDiff(task)
+		default:
+			return
+		}
+	}
+}
+
+// writeStateDiff records the reverse diff of the state transition of a block,
+// and drops the diff of the block falling out of the retained history.
+func (bc *BlockChain) writeStateDiff(task *stateDiffTask) {
+	if task.block == nil {
+		close(task.done)
+		return
+	}
+	block, triedb := task.block, bc.stateCache.TrieDB()
+	defer func() {
+		triedb.Dereference(task.parentRoot)
+		triedb.Dereference(block.Root())
+	}()
+	diff, err := state.DiffState(bc.stateCache, task.parentRoot, block.Root())
+	if err != nil {
+		log.Warn("Failed to compute state diff", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+		return
+	}
+	enc, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		log.Crit("Failed to encode state diff", "err", err)
+	}
+	batch := bc.db.NewBatch()
+	rawdb.WriteStateDiff(batch, block.Hash(), block.NumberU64(), enc)
+	if limit := bc.cacheConfig.StateHistory; block.NumberU64() > limit {
+		number := block.NumberU64() - limit
+		for _, hash := range rawdb.ReadAllHashes(bc.db, number) {
+			rawdb.DeleteStateDiff(batch, hash, number)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state diff", "err", err)
+	}
+}
+
+// stateDiff retrieves the reverse state diff of a block, caching it if found.
+func (bc *BlockChain) stateDiff(hash common.Hash, number uint64) (*state.StateDiff, error) {
+	if diff, ok := bc.stateDiffCache.Get(hash); ok {
+		return diff.(*state.StateDiff), nil
+	}
+	enc := rawdb.ReadStateDiff(bc.db, hash, number)
+	if len(enc) == 0 {
+		return nil, fmt.Errorf("state diff of block #%d [%x…] missing", number, hash[:4])
+	}
+	diff := new(state.StateDiff)
+	if err := rlp.DecodeBytes(enc, diff); err != nil {
+		return nil, err
+	}
+	bc.stateDiffCache.Add(hash, diff)
+	return diff, nil
+}
+
+// HistoricState returns the state of an ancestor of the current block, which is
+// reconstructed from the current state through the reverse state diffs of the
+// blocks in between. Only the states of the blocks within the retained state
+// history can be reconstructed.
+func (bc *BlockChain) HistoricState(header *types.Header) (*state.StateDB, error) {
+	limit := bc.cacheConfig.StateHistory
+	if limit == 0 {
+		return nil, errStateHistoryDisabled
+	}
+	// Make sure the diffs of the blocks already made canonical are recorded
+	if err := bc.flushStateDiffs(); err != nil {
+		return nil, err
+	}
+	var (
+		head   = bc.CurrentBlock().Header()
+		number = header.Number.Uint64()
+	)
+	if number > head.Number.Uint64() {
+		return nil, fmt.Errorf("block #%d is ahead of the current block", number)
+	}
+	if head.Number.Uint64()-number > limit {
+		return nil, fmt.Errorf("block #%d is beyond the state history of %d blocks", number, limit)
+	}
+	// Gather the diffs from the current block back to the requested one
+	diffs := make([]*state.StateDiff, head.Number.Uint64()-number)
+	for current := head; current.Hash() != header.Hash(); {
+		if current.Number.Uint64() <= number {
+			return nil, fmt.Errorf("block #%d [%x…] is not canonical", number, header.Hash().Bytes()[:4])
+		}
+		diff, err := bc.stateDiff(current.Hash(), current.Number.Uint64())
+		if err != nil {
+			return nil, err
+		}
+		diffs[current.Number.Uint64()-number-1] = diff
+
+		parent := bc.GetHeader(current.ParentHash, current.Number.Uint64()-1)
+		if parent == nil {
+			return nil, fmt.Errorf("parent of block #%d missing", current.Number)
+		}
+		current = parent
+	}
+	return state.NewHistorical(header.Root, bc.stateCache, head.Root, diffs)
+}
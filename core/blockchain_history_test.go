@@ -0,0 +1,183 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that the states of the recent blocks can be reconstructed from the
+// reverse state diffs, and that the diffs beyond the history are pruned.
+func TestHistoricState(t *testing.T) {
+	var (
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+
+		// Contract setting slot 0 and the slot of the block number to the number
+		counter     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		counterCode = []byte{
+			byte(vm.NUMBER), byte(vm.PUSH1), 0x00, byte(vm.SSTORE),
+			byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE),
+			byte(vm.STOP),
+		}
+		// Contract with preset storage which selfdestructs when called
+		suicidal     = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		suicidalCode = []byte{byte(vm.CALLER), byte(vm.SELFDESTRUCT)}
+	)
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			address: {Balance: funds},
+			counter: {Code: counterCode, Balance: big.NewInt(0)},
+			suicidal: {
+				Code:    suicidalCode,
+				Balance: big.NewInt(100),
+				Storage: map[common.Hash]common.Hash{
+					common.HexToHash("01"): common.HexToHash("01"),
+					common.HexToHash("02"): common.HexToHash("02"),
+				},
+			},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 20, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i + 2)}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+
+		tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(address), counter, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+
+		if i == 14 {
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(address), suicidal, big.NewInt(0), 50000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+			b.AddTx(tx)
+		}
+	})
+	// Import the chain into a pruning node retaining 10 blocks of state history
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	config := *defaultCacheConfig
+	config.StateHistory = 10
+
+	chain, err := NewBlockChain(diskdb, &config, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// The states of the recent blocks are all still in memory, compare them
+	accounts := []common.Address{address, counter, suicidal, {1}}
+	for i := 0; i < 20; i++ {
+		accounts = append(accounts, common.Address{byte(i + 2)})
+	}
+	slots := make([]common.Hash, 0, 22)
+	for i := 0; i <= 21; i++ {
+		slots = append(slots, common.BigToHash(big.NewInt(int64(i))))
+	}
+	for number := uint64(10); number <= 20; number++ {
+		header := chain.GetHeaderByNumber(number)
+
+		want, err := chain.StateAt(header.Root)
+		if err != nil {
+			t.Fatalf("block #%d: failed to open state: %v", number, err)
+		}
+		have, err := chain.HistoricState(header)
+		if err != nil {
+			t.Fatalf("block #%d: failed to reconstruct state: %v", number, err)
+		}
+		for _, addr := range accounts {
+			if have.Exist(addr) != want.Exist(addr) {
+				t.Errorf("block #%d, account %x: existence mismatch: have %v, want %v", number, addr, have.Exist(addr), want.Exist(addr))
+			}
+			if have.GetBalance(addr).Cmp(want.GetBalance(addr)) != 0 {
+				t.Errorf("block #%d, account %x: balance mismatch: have %v, want %v", number, addr, have.GetBalance(addr), want.GetBalance(addr))
+			}
+			if have.GetNonce(addr) != want.GetNonce(addr) {
+				t.Errorf("block #%d, account %x: nonce mismatch: have %d, want %d", number, addr, have.GetNonce(addr), want.GetNonce(addr))
+			}
+			if !bytes.Equal(have.GetCode(addr), want.GetCode(addr)) {
+				t.Errorf("block #%d, account %x: code mismatch: have %x, want %x", number, addr, have.GetCode(addr), want.GetCode(addr))
+			}
+			for _, slot := range slots {
+				if have.GetState(addr, slot) != want.GetState(addr, slot) {
+					t.Errorf("block #%d, account %x, slot %x: value mismatch: have %x, want %x", number, addr, slot, have.GetState(addr, slot), want.GetState(addr, slot))
+				}
+			}
+		}
+	}
+	// Ensure states beyond the history are rejected and their diffs pruned
+	if _, err := chain.HistoricState(chain.GetHeaderByNumber(9)); err == nil {
+		t.Errorf("reconstructed state beyond the history")
+	}
+	if diff := rawdb.ReadStateDiff(diskdb, chain.GetCanonicalHash(10), 10); len(diff) != 0 {
+		t.Errorf("state diff of block #10 not pruned")
+	}
+	if diff := rawdb.ReadStateDiff(diskdb, chain.GetCanonicalHash(11), 11); len(diff) == 0 {
+		t.Errorf("state diff of block #11 missing")
+	}
+	// Side chain blocks have no diffs, until a reorg makes them canonical
+	fork, _ := GenerateChain(params.TestChainConfig, blocks[16], engine, db, 4, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	if n, err := chain.InsertChain(fork[:1]); err != nil {
+		t.Fatalf("fork block %d: failed to insert into chain: %v", n, err)
+	}
+	if err := chain.flushStateDiffs(); err != nil {
+		t.Fatalf("failed to flush state diffs: %v", err)
+	}
+	if diff := rawdb.ReadStateDiff(diskdb, fork[0].Hash(), fork[0].NumberU64()); len(diff) != 0 {
+		t.Errorf("state diff of side chain block recorded")
+	}
+	if n, err := chain.InsertChain(fork[1:]); err != nil {
+		t.Fatalf("fork block %d: failed to insert into chain: %v", n+1, err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != fork[3].Hash() {
+		t.Fatalf("fork not canonical: head %x, want %x", head, fork[3].Hash())
+	}
+	for _, block := range fork[:3] {
+		have, err := chain.HistoricState(block.Header())
+		if err != nil {
+			t.Fatalf("fork block #%d: failed to reconstruct state: %v", block.NumberU64(), err)
+		}
+		for _, addr := range []common.Address{{1}, {2}} {
+			want, _ := chain.StateAt(block.Root())
+			if have.GetBalance(addr).Cmp(want.GetBalance(addr)) != 0 {
+				t.Errorf("fork block #%d, account %x: balance mismatch: have %v, want %v", block.NumberU64(), addr, have.GetBalance(addr), want.GetBalance(addr))
+			}
+		}
+	}
+}
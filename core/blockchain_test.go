@@ -17,12 +17,15 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -37,6 +40,7 @@ import (
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/trie"
 )
 
@@ -1208,6 +1212,101 @@ done:
 
 }
 
+// Tests that reorgs post a single event with the common ancestor, the dropped and
+// added blocks and the net transaction difference.
+func TestReorgEvent(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	newTx := func(nonce uint64, to common.Address) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key1)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		return tx
+	}
+	var (
+		shared  = newTx(0, common.Address{0x01}) // Included in both chains
+		dropped = newTx(1, common.Address{0x02}) // Only included in the old chain
+		added   = newTx(1, common.Address{0x03}) // Only included in the new chain
+	)
+	chain, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		switch i {
+		case 1:
+			gen.AddTx(shared)
+		case 2:
+			gen.AddTx(dropped)
+		}
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// The second block of the replacement makes it heavier than the old chain
+	replacement, _ := GenerateChain(gspec.Config, chain[0], ethash.NewFaker(), db, 2, func(i int, gen *BlockGen) {
+		switch i {
+		case 0:
+			gen.SetExtra([]byte("fork"))
+			gen.AddTx(shared)
+		case 1:
+			gen.OffsetTime(-9)
+			gen.AddTx(added)
+		}
+	})
+	reorgCh := make(chan ReorgEvent, 4)
+	sub := blockchain.SubscribeReorgEvent(reorgCh)
+	defer sub.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(replacement); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != replacement[1].Hash() {
+		t.Fatalf("replacement chain not canonical: head %x", head)
+	}
+	hashes := func(blocks types.Blocks) []common.Hash {
+		var res []common.Hash
+		for _, block := range blocks {
+			res = append(res, block.Hash())
+		}
+		return res
+	}
+	select {
+	case ev := <-reorgCh:
+		if ev.CommonAncestor.Hash() != chain[0].Hash() {
+			t.Errorf("wrong common ancestor: have %x, want %x", ev.CommonAncestor.Hash(), chain[0].Hash())
+		}
+		if have, want := hashes(ev.Dropped), hashes(chain[1:]); !reflect.DeepEqual(have, want) {
+			t.Errorf("wrong dropped blocks: have %x, want %x", have, want)
+		}
+		if have, want := hashes(ev.Added), hashes(replacement); !reflect.DeepEqual(have, want) {
+			t.Errorf("wrong added blocks: have %x, want %x", have, want)
+		}
+		if len(ev.DroppedTxs) != 1 || ev.DroppedTxs[0].Hash() != dropped.Hash() {
+			t.Errorf("wrong dropped transactions: %v", ev.DroppedTxs)
+		}
+		if len(ev.AddedTxs) != 1 || ev.AddedTxs[0].Hash() != added.Hash() {
+			t.Errorf("wrong added transactions: %v", ev.AddedTxs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reorg event posted")
+	}
+	select {
+	case ev := <-reorgCh:
+		t.Errorf("unexpected reorg event: %+v", ev)
+	default:
+	}
+}
+
 // Tests if the canonical block can be fetched from the database during chain insertion.
 func TestCanonicalBlockRetrieval(t *testing.T) {
 	_, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
@@ -3108,3 +3207,150 @@ func TestEIP2718Transition(t *testing.T) {
 
 	}
 }
+
+// Tests that a blockchain opened read-only serves the existing chain, rejects
+// any mutation and leaves the database untouched, also on shutdown.
+func TestReadOnlyBlockChain(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 8, nil)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[:6]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// Snapshot the database content and reopen the chain read-only
+	dump := func() map[string]string {
+		items := make(map[string]string)
+		it := db.NewIterator(nil, nil)
+		defer it.Release()
+		for it.Next() {
+			items[string(it.Key())] = string(it.Value())
+		}
+		return items
+	}
+	before := dump()
+
+	config := *defaultCacheConfig
+	config.ReadOnly = true
+	chain, err = NewBlockChain(db, &config, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to open read-only chain: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[5].Hash() {
+		t.Fatalf("head mismatch: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), blocks[5].NumberU64(), blocks[5].Hash())
+	}
+	if _, err := chain.State(); err != nil {
+		t.Fatalf("failed to access head state: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks[6:]); err != ErrReadOnlyChain {
+		t.Errorf("block import error mismatch: have %v, want %v", err, ErrReadOnlyChain)
+	}
+	if err := chain.SetHead(2); err != ErrReadOnlyChain {
+		t.Errorf("rewind error mismatch: have %v, want %v", err, ErrReadOnlyChain)
+	}
+	chain.Stop()
+
+	if after := dump(); !reflect.DeepEqual(before, after) {
+		t.Errorf("read-only chain modified the database: %d items before, %d after", len(before), len(after))
+	}
+	// Opening an empty database read-only should fail
+	if _, err := NewBlockChain(rawdb.NewMemoryDatabase(), &config, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil); err == nil {
+		t.Errorf("opened empty database read-only")
+	}
+}
+
+// Tests that the history tails set by the sync are persisted and that the data
+// older than them is reported unavailable.
+func TestHistoryTails(t *testing.T) {
+	db, chain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	chain.SetHistoryTails(5, 10)
+	chain.Stop()
+
+	chain, err = NewBlockChain(db, nil, params.AllEthashProtocolChanges, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if bodies, receipts := chain.HistoryTails(); bodies != 5 || receipts != 10 {
+		t.Fatalf("history tails mismatch: have %d/%d, want 5/10", bodies, receipts)
+	}
+	tests := []struct {
+		number   uint64
+		receipts bool
+		kind     string // Kind of unavailable data, empty if available
+	}{
+		{4, false, "body"},
+		{4, true, "body"},
+		{5, false, ""},
+		{5, true, "receipts"},
+		{10, true, ""},
+	}
+	for i, tt := range tests {
+		err := chain.CheckHistory(tt.number, tt.receipts)
+		switch {
+		case tt.kind == "" && err != nil:
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		case tt.kind != "" && (err == nil || err.(*HistoryUnavailableError).Kind != tt.kind):
+			t.Errorf("test %d: error mismatch: have %v, want %s unavailable", i, err, tt.kind)
+		}
+	}
+}
+
+// Tests that exporting a chain as a list streams the same blocks as the plain
+// export, wrapped in a single list.
+func TestExportList(t *testing.T) {
+	_, blockchain, err := newCanonical(ethash.NewFaker(), 16, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	var plain, list bytes.Buffer
+	if err := blockchain.ExportN(&plain, 3, 12); err != nil {
+		t.Fatalf("failed to export blocks: %v", err)
+	}
+	if err := blockchain.ExportList(&list, 3, 12); err != nil {
+		t.Fatalf("failed to export list of blocks: %v", err)
+	}
+	content, rest, err := rlp.SplitList(list.Bytes())
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("exported list malformed: %v, %d trailing bytes", err, len(rest))
+	}
+	if !bytes.Equal(content, plain.Bytes()) {
+		t.Fatalf("exported list content mismatch")
+	}
+	lr, err := rlp.NewListReader(&list)
+	if err != nil {
+		t.Fatalf("failed to open exported list: %v", err)
+	}
+	for nr := uint64(3); ; nr++ {
+		var block types.Block
+		if err := lr.Decode(&block); err == io.EOF {
+			if nr != 13 {
+				t.Fatalf("exported block count mismatch: have %d, want %d", nr-3, 10)
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("failed to decode block #%d: %v", nr, err)
+		}
+		if want := blockchain.GetBlockByNumber(nr).Hash(); block.Hash() != want {
+			t.Fatalf("block #%d hash mismatch: have %x, want %x", nr, block.Hash(), want)
+		}
+	}
+	if err := blockchain.ExportList(&list, 12, 17); err == nil {
+		t.Fatalf("exported missing blocks")
+	}
+}
@@ -18,6 +18,8 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/acent/go-acent/common"
@@ -26,6 +28,8 @@ import (
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/params"
 )
 
 const (
@@ -90,3 +94,105 @@ func (b *BloomIndexer) Commit() error {
 func (b *BloomIndexer) Prune(threshold uint64) error {
 	return nil
 }
+
+// SetBloomBitsSize records the section size of the bloom bits index. If it differs
+// from the size the index was built with, the index is dropped to be rebuilt from
+// scratch. It must be called before the bloom indexer is created.
+func SetBloomBitsSize(db ethdb.Database, size uint64) {
+	stored := rawdb.ReadBloomBitsSize(db)
+	if stored == 0 {
+		stored = params.BloomBitsBlocks // Index built before the size was configurable
+	}
+	if stored != size {
+		log.Warn("Bloom bits section size changed, dropping index", "old", stored, "new", size)
+		for i := 0; i < types.BloomBitLength; i++ {
+			rawdb.DeleteBloombits(db, uint(i), 0, math.MaxUint64)
+		}
+		writeValidSections(rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix)), 0)
+		rawdb.DeleteBloomBitsExtent(db)
+	}
+	rawdb.WriteBloomBitsSize(db, size)
+}
+
+// RebuildBloomBits regenerates the bloom bits of the sections first to last
+// (inclusive) from the canonical headers in the database, calling progress after
+// each section if set. Rebuilt sections extending the contiguously indexed ones
+// are adopted by the bloom indexer, others are recorded as an extent which log
+// filtering uses beyond the indexed sections. The node must not be running.
+func RebuildBloomBits(db ethdb.Database, size, first, last uint64, progress func(section uint64)) error {
+	indexDb := rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix))
+
+	for section := first; section <= last; section++ {
+		gen, err := bloombits.NewGenerator(uint(size))
+		if err != nil {
+			return err
+		}
+		var head common.Hash
+		for i := uint64(0); i < size; i++ {
+			number := section*size + i
+			head = rawdb.ReadCanonicalHash(db, number)
+			header := rawdb.ReadHeader(db, head, number)
+			if header == nil {
+				return fmt.Errorf("missing header #%d", number)
+			}
+			if err := gen.AddBloom(uint(i), header.Bloom); err != nil {
+				return err
+			}
+		}
+		batch := db.NewBatch()
+		for i := 0; i < types.BloomBitLength; i++ {
+			bits, err := gen.Bitset(uint(i))
+			if err != nil {
+				return err
+			}
+			rawdb.WriteBloomBits(batch, uint(i), section, head, bitutil.CompressBytes(bits))
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		writeSectionHead(indexDb, section, head)
+		if progress != nil {
+			progress(section)
+		}
+	}
+	// Adopt the sections into the index if contiguous, merge them into the extent otherwise
+	var (
+		sections           = readValidSections(indexDb)
+		extFirst, extCount = rawdb.ReadBloomBitsExtent(db)
+		extEnd             = extFirst + extCount
+	)
+	switch {
+	case first <= sections:
+		if last+1 > sections {
+			sections = last + 1
+		}
+		if extCount > 0 && extFirst <= sections {
+			if extEnd > sections {
+				sections = extEnd
+			}
+			rawdb.DeleteBloomBitsExtent(db)
+		}
+		writeValidSections(indexDb, sections)
+
+	case extCount > 0 && first <= extEnd && extFirst <= last+1:
+		if first < extFirst {
+			extFirst = first
+		}
+		if last+1 > extEnd {
+			extEnd = last + 1
+		}
+		rawdb.WriteBloomBitsExtent(db, extFirst, extEnd-extFirst)
+
+	default:
+		rawdb.WriteBloomBitsExtent(db, first, last-first+1)
+	}
+	return nil
+}
+
+// BloomBitsStatus returns the number of contiguously indexed bloom bits sections,
+// along with the first and the number of the sections rebuilt beyond them.
+func BloomBitsStatus(db ethdb.Database) (uint64, uint64, uint64) {
+	sections := readValidSections(rawdb.NewTable(db, string(rawdb.BloomBitsIndexPrefix)))
+	extFirst, extCount := rawdb.ReadBloomBitsExtent(db)
+	return sections, extFirst, extCount
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+)
+
+// Tests that rebuilt bloom bits sections are adopted by the index if contiguous
+// with it, kept as an extent otherwise, and dropped on section size changes.
+func TestRebuildBloomBits(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	for i := int64(0); i < 40; i++ {
+		header := &types.Header{Number: big.NewInt(i), Bloom: types.BytesToBloom([]byte{0xff})}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	}
+	SetBloomBitsSize(db, 8)
+
+	tests := []struct {
+		first, last                  uint64
+		sections, extFirst, extCount uint64
+	}{
+		{2, 3, 0, 2, 2}, // Detached sections become the extent
+		{0, 0, 1, 2, 2}, // Contiguous sections are adopted
+		{4, 4, 1, 2, 3}, // Adjacent sections extend the extent
+		{1, 1, 5, 0, 0}, // Closing the gap adopts the extent
+	}
+	for i, test := range tests {
+		if err := RebuildBloomBits(db, 8, test.first, test.last, nil); err != nil {
+			t.Fatalf("test %d: failed to rebuild bloom bits: %v", i, err)
+		}
+		sections, extFirst, extCount := BloomBitsStatus(db)
+		if sections != test.sections || extFirst != test.extFirst || extCount != test.extCount {
+			t.Errorf("test %d: status mismatch: have %d/%d/%d, want %d/%d/%d", i, sections, extFirst, extCount, test.sections, test.extFirst, test.extCount)
+		}
+	}
+	head := rawdb.ReadCanonicalHash(db, 39)
+	if bits, _ := rawdb.ReadBloomBits(db, 0, 4, head); bits == nil {
+		t.Fatalf("rebuilt bloom bits missing")
+	}
+	// Changing the section size must drop the index
+	SetBloomBitsSize(db, 16)
+	if sections, _, extCount := BloomBitsStatus(db); sections != 0 || extCount != 0 {
+		t.Fatalf("index not dropped: sections %d, extent %d", sections, extCount)
+	}
+	if bits, _ := rawdb.ReadBloomBits(db, 0, 4, head); bits != nil {
+		t.Fatalf("stale bloom bits retained")
+	}
+	if size := rawdb.ReadBloomBitsSize(db); size != 16 {
+		t.Fatalf("section size mismatch: have %d, want %d", size, 16)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/misc"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+)
+
+const (
+	// CallIndexBlocks is the number of blocks a single call index section covers.
+	// Sections are kept short, so that they are traced while the state of their
+	// blocks is still held in memory, even by non-archive nodes.
+	CallIndexBlocks = 64
+
+	// CallIndexConfirms is the number of confirmation blocks before a call index
+	// section is considered final and traced.
+	CallIndexConfirms = 16
+)
+
+// CallIndexer implements a core.ChainIndexer, tracing the blocks to build up a
+// per-contract index of the transactions calling each contract, including the
+// internal calls made by other contracts.
+//
+// Tracing needs the state of the parent block, so blocks whose state is not
+// available anymore (e.g. beyond the in-memory state of non-archive nodes) are
+// skipped, moving the tail of the index past them.
+type CallIndexer struct {
+	chain   *BlockChain                               // blockchain to trace the blocks on
+	db      ethdb.Database                            // database instance to write index data and metadata into
+	calls   map[common.Address][]rawdb.CallIndexEntry // transactions calling the contracts in the current section
+	tail    uint64                                    // oldest block from which on the index is complete
+	section uint64                                    // Section is the section number being processed currently
+	head    common.Hash                               // Head is the hash of the last header processed
+}
+
+// NewCallIndexer returns a chain indexer that generates the contract call index
+// for the canonical chain, answering which transactions called a contract.
+func NewCallIndexer(chain *BlockChain, db ethdb.Database) *ChainIndexer {
+	backend := &CallIndexer{
+		chain: chain,
+		db:    db,
+	}
+	table := rawdb.NewTable(db, string(rawdb.CallIndexPrefix))
+
+	return NewChainIndexer(db, table, backend, CallIndexBlocks, CallIndexConfirms, bloomThrottling, "callindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new call index section.
+func (b *CallIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	b.calls, b.section, b.head = make(map[common.Address][]rawdb.CallIndexEntry), section, common.Hash{}
+	b.tail = rawdb.ReadCallIndexTail(b.db)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, tracing the header's block and
+// adding its transactions to the entries of all the contracts they called.
+func (b *CallIndexer) Process(ctx context.Context, header *types.Header) error {
+	number, hash := header.Number.Uint64(), header.Hash()
+	b.head = hash
+
+	block := b.chain.GetBlock(hash, number)
+	if block == nil {
+		return fmt.Errorf("missing block #%d [%x..]", number, hash.Bytes()[:4])
+	}
+	if len(block.Transactions()) == 0 {
+		return nil
+	}
+	parent := b.chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return fmt.Errorf("missing parent of block #%d [%x..]", number, hash.Bytes()[:4])
+	}
+	statedb, err := b.chain.StateAt(parent.Root)
+	if err != nil {
+		log.Trace("Skipping call indexing of block without state", "number", number, "hash", hash)
+		if number+1 > b.tail {
+			b.tail = number + 1 // Never move the tail backwards, e.g. on reindexing after a reorg
+		}
+		return nil
+	}
+	callees, err := traceCallees(b.chain, block, statedb)
+	if err != nil {
+		return err
+	}
+	for i, addresses := range callees {
+		for address := range addresses {
+			b.calls[address] = append(b.calls[address], rawdb.CallIndexEntry{Number: number, Index: uint64(i)})
+		}
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the entries of the section
+// and the tail of the index out into the database.
+func (b *CallIndexer) Commit() error {
+	batch := b.db.NewBatch()
+	for address, entries := range b.calls {
+		rawdb.WriteCallIndex(batch, address, b.section, b.head, entries)
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if b.tail != rawdb.ReadCallIndexTail(b.db) {
+		rawdb.WriteCallIndexTail(batch, b.tail)
+	}
+	return batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (b *CallIndexer) Prune(threshold uint64) error {
+	return nil
+}
+
+// traceCallees executes the transactions of a block on top of the state of its
+// parent, returning the recipients of all the calls made by each transaction.
+func traceCallees(chain *BlockChain, block *types.Block, statedb *state.StateDB) ([]map[common.Address]struct{}, error) {
+	var (
+		config  = chain.Config()
+		header  = block.Header()
+		signer  = types.MakeSigner(config, header.Number)
+		tracer  = new(calleeTracer)
+		vmenv   = vm.NewEVM(NewEVMBlockContext(header, chain, nil), vm.TxContext{}, statedb, config, vm.Config{Debug: true, Tracer: tracer})
+		gp      = new(GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+		callees = make([]map[common.Address]struct{}, len(block.Transactions()))
+	)
+	if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	for i, tx := range block.Transactions() {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, err
+		}
+		tracer.callees = make(map[common.Address]struct{})
+
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if _, err := applyTransaction(msg, config, chain, nil, gp, statedb, header, tx, usedGas, vmenv); err != nil {
+			return nil, fmt.Errorf("could not trace tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		callees[i] = tracer.callees
+	}
+	return callees, nil
+}
+
+// calleeTracer is a vm.Tracer collecting the recipients of the calls made by a
+// transaction, including the internal ones.
+type calleeTracer struct {
+	callees map[common.Address]struct{}
+}
+
+// CaptureStart implements vm.Tracer, collecting the recipient of the transaction.
+func (t *calleeTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	if !create {
+		t.callees[to] = struct{}{}
+	}
+	return nil
+}
+
+// CaptureState implements vm.Tracer, collecting the recipients of the internal calls.
+func (t *calleeTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	switch op {
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		if len(stack.Data()) > 1 {
+			t.callees[common.Address(stack.Back(1).Bytes20())] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *calleeTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *calleeTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) error {
+	return nil
+}
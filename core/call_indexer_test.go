@@ -0,0 +1,147 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that the call indexer records both the direct and the internal calls
+// made by the transactions of a section.
+func TestCallIndexer(t *testing.T) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		bb = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+
+		engine  = ethash.NewFaker()
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000)},
+				// The address 0xAAAA does nothing
+				aa: {Code: []byte{byte(vm.STOP)}, Balance: big.NewInt(0)},
+				// The address 0xBBBB calls 0xAAAA
+				bb: {
+					Code: []byte{
+						byte(vm.PUSH1), 0x00, // out size
+						byte(vm.DUP1), // out offset
+						byte(vm.DUP1), // in size
+						byte(vm.DUP1), // in offset
+						byte(vm.DUP1), // value
+						byte(vm.PUSH2), 0xaa, 0xaa,
+						byte(vm.GAS),
+						byte(vm.CALL),
+						byte(vm.STOP),
+					},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2, func(i int, b *BlockGen) {
+		if i != 1 {
+			return
+		}
+		tx, _ := types.SignTx(types.NewTransaction(0, aa, big.NewInt(0), 50000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(1, bb, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// Index the blocks as a single section and check the entries of both contracts
+	indexer := &CallIndexer{chain: chain, db: diskdb}
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	for _, block := range blocks {
+		if err := indexer.Process(context.Background(), block.Header()); err != nil {
+			t.Fatalf("failed to process block #%d: %v", block.NumberU64(), err)
+		}
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit section: %v", err)
+	}
+	head := blocks[len(blocks)-1].Hash()
+	tests := []struct {
+		address common.Address
+		entries []rawdb.CallIndexEntry
+	}{
+		{aa, []rawdb.CallIndexEntry{{Number: 2, Index: 0}, {Number: 2, Index: 1}}},
+		{bb, []rawdb.CallIndexEntry{{Number: 2, Index: 1}}},
+		{address, nil},
+	}
+	for _, test := range tests {
+		if entries := rawdb.ReadCallIndex(diskdb, test.address, 0, head); !reflect.DeepEqual(entries, test.entries) {
+			t.Errorf("%x: call index mismatch: have %v, want %v", test.address, entries, test.entries)
+		}
+	}
+	if tail := rawdb.ReadCallIndexTail(diskdb); tail != 0 {
+		t.Errorf("call index tail mismatch: have %d, want %d", tail, 0)
+	}
+	// Blocks without state below the tail must not move the tail backwards
+	statelessdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(statelessdb)
+
+	stateless, err := NewBlockChain(statelessdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create stateless chain: %v", err)
+	}
+	defer stateless.Stop()
+	for _, block := range blocks {
+		rawdb.WriteBlock(statelessdb, block)
+	}
+	rawdb.WriteCallIndexTail(statelessdb, 10)
+
+	indexer = &CallIndexer{chain: stateless, db: statelessdb}
+	if err := indexer.Reset(context.Background(), 0, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset indexer: %v", err)
+	}
+	if err := indexer.Process(context.Background(), blocks[1].Header()); err != nil {
+		t.Fatalf("failed to process stateless block: %v", err)
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit section: %v", err)
+	}
+	if tail := rawdb.ReadCallIndexTail(statelessdb); tail != 10 {
+		t.Errorf("call index tail mismatch: have %d, want %d", tail, 10)
+	}
+}
@@ -469,18 +469,13 @@ func (c *ChainIndexer) Prune(threshold uint64) error {
 // loadValidSections reads the number of valid sections from the index database
 // and caches is into the local state.
 func (c *ChainIndexer) loadValidSections() {
-	data, _ := c.indexDb.Get([]byte("count"))
-	if len(data) == 8 {
-		c.storedSections = binary.BigEndian.Uint64(data)
-	}
+	c.storedSections = readValidSections(c.indexDb)
 }
 
 // setValidSections writes the number of valid sections to the index database
 func (c *ChainIndexer) setValidSections(sections uint64) {
 	// Set the current number of valid sections in the database
-	var data [8]byte
-	binary.BigEndian.PutUint64(data[:], sections)
-	c.indexDb.Put([]byte("count"), data[:])
+	writeValidSections(c.indexDb, sections)
 
 	// Remove any reorged sections, caching the valids in the mean time
 	for c.storedSections > sections {
@@ -493,30 +488,58 @@ func (c *ChainIndexer) setValidSections(sections uint64) {
 // SectionHead retrieves the last block hash of a processed section from the
 // index database.
 func (c *ChainIndexer) SectionHead(section uint64) common.Hash {
+	return readSectionHead(c.indexDb, section)
+}
+
+// setSectionHead writes the last block hash of a processed section to the index
+// database.
+func (c *ChainIndexer) setSectionHead(section uint64, hash common.Hash) {
+	writeSectionHead(c.indexDb, section, hash)
+}
+
+// removeSectionHead removes the reference to a processed section from the index
+// database.
+func (c *ChainIndexer) removeSectionHead(section uint64) {
 	var data [8]byte
 	binary.BigEndian.PutUint64(data[:], section)
 
-	hash, _ := c.indexDb.Get(append([]byte("shead"), data[:]...))
-	if len(hash) == len(common.Hash{}) {
-		return common.BytesToHash(hash)
+	c.indexDb.Delete(append([]byte("shead"), data[:]...))
+}
+
+// readValidSections reads the number of valid sections from an index database.
+func readValidSections(indexDb ethdb.KeyValueReader) uint64 {
+	data, _ := indexDb.Get([]byte("count"))
+	if len(data) == 8 {
+		return binary.BigEndian.Uint64(data)
 	}
-	return common.Hash{}
+	return 0
 }
 
-// setSectionHead writes the last block hash of a processed section to the index
+// writeValidSections writes the number of valid sections to an index database.
+func writeValidSections(indexDb ethdb.KeyValueWriter, sections uint64) {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], sections)
+	indexDb.Put([]byte("count"), data[:])
+}
+
+// readSectionHead reads the last block hash of a processed section from an index
 // database.
-func (c *ChainIndexer) setSectionHead(section uint64, hash common.Hash) {
+func readSectionHead(indexDb ethdb.KeyValueReader, section uint64) common.Hash {
 	var data [8]byte
 	binary.BigEndian.PutUint64(data[:], section)
 
-	c.indexDb.Put(append([]byte("shead"), data[:]...), hash.Bytes())
+	hash, _ := indexDb.Get(append([]byte("shead"), data[:]...))
+	if len(hash) == len(common.Hash{}) {
+		return common.BytesToHash(hash)
+	}
+	return common.Hash{}
 }
 
-// removeSectionHead removes the reference to a processed section from the index
+// writeSectionHead writes the last block hash of a processed section to an index
 // database.
-func (c *ChainIndexer) removeSectionHead(section uint64) {
+func writeSectionHead(indexDb ethdb.KeyValueWriter, section uint64, hash common.Hash) {
 	var data [8]byte
 	binary.BigEndian.PutUint64(data[:], section)
 
-	c.indexDb.Delete(append([]byte("shead"), data[:]...))
+	indexDb.Put(append([]byte("shead"), data[:]...), hash.Bytes())
 }
@@ -18,6 +18,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/acent/go-acent/core/types"
 )
@@ -31,8 +32,30 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrReadOnlyChain is returned if a chain mutation is attempted on a blockchain
+	// opened in read-only mode.
+	ErrReadOnlyChain = errors.New("blockchain is read-only")
 )
 
+// HistoryUnavailableError is returned when the body or the receipts of a block
+// are requested, but were not downloaded by the sync due to the history limits
+// of the node.
+type HistoryUnavailableError struct {
+	Kind   string // Kind of the unavailable data, body or receipts
+	Number uint64 // Number of the requested block
+	Tail   uint64 // Oldest block whose data is available
+}
+
+func (e *HistoryUnavailableError) Error() string {
+	return fmt.Sprintf("%s of block %d unavailable, history starts at block %d", e.Kind, e.Number, e.Tail)
+}
+
+// ErrorCode returns the JSON error code of pruned history.
+func (e *HistoryUnavailableError) ErrorCode() int {
+	return 4444
+}
+
 // List of evm-call-message pre-checking errors. All state transition messages will
 // be pre-checked before execution. If any invalidation detected, the corresponding
 // error should be returned which is defined here.
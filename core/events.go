@@ -24,6 +24,26 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// DropTxsEvent is posted when a batch of transactions is dropped from the
+// transaction pool for the same reason.
+type DropTxsEvent struct {
+	Txs    []*types.Transaction
+	Reason TxDropReason
+}
+
+// ReplaceTxEvent is posted when a transaction in the transaction pool is replaced
+// by another one of the same sender and nonce.
+type ReplaceTxEvent struct {
+	Old *types.Transaction
+	New *types.Transaction
+}
+
+// NewUserOpsEvent is posted when user operations enter the user operation pool.
+type NewUserOpsEvent struct {
+	EntryPoint common.Address
+	Ops        []*types.UserOperation
+}
+
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.Block }
 
@@ -41,3 +61,13 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ReorgEvent is posted when blocks are dropped from the canonical chain in favour
+// of a competing one. Block lists are in ascending order.
+type ReorgEvent struct {
+	CommonAncestor *types.Block
+	Dropped        types.Blocks       // Blocks removed from the canonical chain
+	Added          types.Blocks       // Blocks made canonical, including the new head
+	DroppedTxs     types.Transactions // Transactions of the dropped blocks not in the new chain
+	AddedTxs       types.Transactions // Transactions of the added blocks not in the dropped ones
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+
+	"github.com/acent/go-acent/consensus"
+	"github.com/acent/go-acent/core/types"
+)
+
+// Finality resolves the safe and finalized blocks of a chain. Markers set by an
+// external consensus client take precedence, then those of the consensus engine
+// if it provides them, otherwise blocks are considered safe and finalized after
+// a fixed number of confirmations.
+type Finality struct {
+	chain          consensus.ChainHeaderReader
+	engine         consensus.Engine
+	safeDepth      uint64 // Confirmations after which a block is considered safe
+	finalizedDepth uint64 // Confirmations after which a block is considered finalized
+
+	lock      sync.RWMutex
+	safe      *types.Header // Safe block reported by the consensus client
+	finalized *types.Header // Finalized block reported by the consensus client
+}
+
+// NewFinality creates a finality resolver for the given chain. The safe depth is
+// capped by the finalized one, as finalized blocks are always safe.
+func NewFinality(chain consensus.ChainHeaderReader, engine consensus.Engine, safeDepth, finalizedDepth uint64) *Finality {
+	if safeDepth > finalizedDepth {
+		safeDepth = finalizedDepth
+	}
+	return &Finality{
+		chain:          chain,
+		engine:         engine,
+		safeDepth:      safeDepth,
+		finalizedDepth: finalizedDepth,
+	}
+}
+
+// SetMarkers records the safe and finalized blocks reported by an external
+// consensus client. A nil header leaves the respective marker unchanged.
+func (f *Finality) SetMarkers(safe, finalized *types.Header) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if safe != nil {
+		f.safe = safe
+	}
+	if finalized != nil {
+		f.finalized = finalized
+	}
+}
+
+// SafeHeader returns the header of the latest block unlikely to be reorged.
+func (f *Finality) SafeHeader() *types.Header {
+	f.lock.RLock()
+	marker := f.safe
+	f.lock.RUnlock()
+
+	if header := f.canonical(marker); header != nil {
+		return header
+	}
+	if engine, ok := f.engine.(consensus.Finality); ok {
+		if header := engine.SafeHeader(f.chain); header != nil {
+			return header
+		}
+	}
+	return f.confirmed(f.safeDepth)
+}
+
+// FinalizedHeader returns the header of the latest block that can't be reorged.
+func (f *Finality) FinalizedHeader() *types.Header {
+	f.lock.RLock()
+	marker := f.finalized
+	f.lock.RUnlock()
+
+	if header := f.canonical(marker); header != nil {
+		return header
+	}
+	if engine, ok := f.engine.(consensus.Finality); ok {
+		if header := engine.FinalizedHeader(f.chain); header != nil {
+			return header
+		}
+	}
+	return f.confirmed(f.finalizedDepth)
+}
+
+// confirmed returns the canonical header with the given number of confirmations,
+// or the genesis if the chain is shorter.
+func (f *Finality) confirmed(depth uint64) *types.Header {
+	head := f.chain.CurrentHeader()
+	if head == nil {
+		return nil
+	}
+	var number uint64
+	if head.Number.Uint64() > depth {
+		number = head.Number.Uint64() - depth
+	}
+	return f.chain.GetHeaderByNumber(number)
+}
+
+// canonical returns the given marker if it is still part of the canonical chain,
+// or nil if it was reorged out or was never set.
+func (f *Finality) canonical(marker *types.Header) *types.Header {
+	if marker == nil {
+		return nil
+	}
+	header := f.chain.GetHeaderByNumber(marker.Number.Uint64())
+	if header == nil || header.Hash() != marker.Hash() {
+		return nil
+	}
+	return header
+}
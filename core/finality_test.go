@@ -0,0 +1,93 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/acent/go-acent/consensus"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core/types"
+)
+
+// finalityEngine is a consensus engine reporting fixed finality markers.
+type finalityEngine struct {
+	consensus.Engine
+	safe, finalized uint64
+}
+
+func (e *finalityEngine) SafeHeader(chain consensus.ChainHeaderReader) *types.Header {
+	return chain.GetHeaderByNumber(e.safe)
+}
+
+func (e *finalityEngine) FinalizedHeader(chain consensus.ChainHeaderReader) *types.Header {
+	return chain.GetHeaderByNumber(e.finalized)
+}
+
+func TestFinality(t *testing.T) {
+	_, blockchain, err := newCanonical(ethash.NewFaker(), 100, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	check := func(name string, header *types.Header, want uint64) {
+		t.Helper()
+		if header == nil {
+			t.Errorf("%s: no header", name)
+		} else if header.Number.Uint64() != want {
+			t.Errorf("%s: wrong block: have %d, want %d", name, header.Number, want)
+		}
+	}
+	// Without engine markers, the confirmation depths are used
+	f := NewFinality(blockchain, blockchain.Engine(), 10, 64)
+	check("safe", f.SafeHeader(), 90)
+	check("finalized", f.FinalizedHeader(), 36)
+
+	// Depths beyond the head resolve to the genesis, and safe blocks can't be
+	// behind the finalized ones
+	f = NewFinality(blockchain, blockchain.Engine(), 200, 150)
+	check("deep safe", f.SafeHeader(), 0)
+	check("deep finalized", f.FinalizedHeader(), 0)
+
+	// Markers of the engine take precedence
+	f = NewFinality(blockchain, &finalityEngine{blockchain.Engine(), 95, 80}, 10, 64)
+	check("engine safe", f.SafeHeader(), 95)
+	check("engine finalized", f.FinalizedHeader(), 80)
+
+	// Unknown markers fall back to the confirmation depths
+	f = NewFinality(blockchain, &finalityEngine{blockchain.Engine(), 1000, 1000}, 10, 64)
+	check("fallback safe", f.SafeHeader(), 90)
+	check("fallback finalized", f.FinalizedHeader(), 36)
+
+	// Markers of the consensus client take precedence over the engine ones
+	f = NewFinality(blockchain, &finalityEngine{blockchain.Engine(), 95, 80}, 10, 64)
+	f.SetMarkers(blockchain.GetHeaderByNumber(98), blockchain.GetHeaderByNumber(70))
+	check("client safe", f.SafeHeader(), 98)
+	check("client finalized", f.FinalizedHeader(), 70)
+
+	// Nil markers leave the previous ones in place
+	f.SetMarkers(nil, blockchain.GetHeaderByNumber(75))
+	check("kept safe", f.SafeHeader(), 98)
+	check("updated finalized", f.FinalizedHeader(), 75)
+
+	// Markers no longer on the canonical chain are ignored
+	stale := types.CopyHeader(blockchain.GetHeaderByNumber(99))
+	stale.Extra = []byte("reorged")
+	f.SetMarkers(stale, nil)
+	check("stale safe", f.SafeHeader(), 95)
+}
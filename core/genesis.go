@@ -31,6 +31,7 @@ import (
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/log"
@@ -156,7 +157,10 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	return SetupGenesisBlockWithOverride(db, genesis, nil)
 }
 
-func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, overrideBerlin *big.Int) (*params.ChainConfig, common.Hash, error) {
+// SetupGenesisBlockWithOverride writes or updates the genesis block in db like
+// SetupGenesisBlock, rescheduling the given forks of the chain configuration.
+// Overrides of forks which already activated on the local chain are rejected.
+func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, overrides params.ForkOverrides) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AllEthashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
@@ -173,7 +177,8 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		if err != nil {
 			return genesis.Config, common.Hash{}, err
 		}
-		return genesis.Config, block.Hash(), nil
+		newcfg, err := overrideConfig(genesis.Config, overrides, true)
+		return newcfg, block.Hash(), err
 	}
 	// We have the genesis block in database(perhaps in ancient database)
 	// but the corresponding state is missing.
@@ -187,11 +192,12 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		if hash != stored {
 			return genesis.Config, hash, &GenesisMismatchError{stored, hash}
 		}
-		block, err := genesis.Commit(db)
+		block, err := genesis.commit(db, false)
 		if err != nil {
 			return genesis.Config, hash, err
 		}
-		return genesis.Config, block.Hash(), nil
+		newcfg, err := overrideConfig(genesis.Config, overrides, false)
+		return newcfg, block.Hash(), err
 	}
 	// Check whether the genesis block is already written.
 	if genesis != nil {
@@ -201,11 +207,11 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		}
 	}
 	// Get the existing chain configuration.
-	newcfg := genesis.configOrDefault(stored)
-	if overrideBerlin != nil {
-		newcfg.BerlinBlock = overrideBerlin
+	newcfg, err := overrideConfig(genesis.configOrDefault(stored), overrides, false)
+	if err != nil {
+		return newcfg, common.Hash{}, err
 	}
-	if err := newcfg.CheckConfigForkOrder(); err != nil {
+	if err := vm.ValidateEVMForks(newcfg); err != nil {
 		return newcfg, common.Hash{}, err
 	}
 	storedcfg := rawdb.ReadChainConfig(db, stored)
@@ -226,6 +232,12 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
+	if err := newcfg.CheckOverrides(storedcfg, *height); err != nil {
+		return newcfg, stored, err
+	}
+	if err := newcfg.CheckGenesisRules(storedcfg, *height); err != nil {
+		return newcfg, stored, err
+	}
 	compatErr := storedcfg.CheckCompatible(newcfg, *height)
 	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
 		return newcfg, stored, compatErr
@@ -234,6 +246,32 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	return newcfg, stored, nil
 }
 
+// overrideConfig reschedules the forks of the chain config and validates the
+// result. The given config is left untouched.
+//
+// Only fresh genesis configs are fully validated. Configs of existing chains skip
+// the consistency checks older versions didn't enforce, so that datadirs created
+// with such configs still start.
+func overrideConfig(config *params.ChainConfig, overrides params.ForkOverrides, fresh bool) (*params.ChainConfig, error) {
+	newcfg, err := config.ApplyOverrides(overrides)
+	if err != nil {
+		return config, err
+	}
+	for _, fork := range newcfg.ForkSchedule() {
+		if fork.Overridden {
+			log.Warn("Overriding fork block", "fork", fork.Name, "original", fork.Original, "block", fork.Block)
+		}
+	}
+	validate := newcfg.ValidateStored
+	if fresh {
+		validate = newcfg.Validate
+	}
+	if err := validate(); err != nil {
+		return newcfg, err
+	}
+	return newcfg, nil
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
@@ -297,6 +335,13 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
+	return g.commit(db, true)
+}
+
+// commit writes the genesis block and state to the database, validating the chain
+// config fully if the genesis is fresh, or as a stored config if it's rewritten
+// for an existing chain.
+func (g *Genesis) commit(db ethdb.Database, fresh bool) (*types.Block, error) {
 	block := g.ToBlock(db)
 	if block.Number().Sign() != 0 {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
@@ -305,7 +350,14 @@ func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
 	if config == nil {
 		config = params.AllEthashProtocolChanges
 	}
-	if err := config.CheckConfigForkOrder(); err != nil {
+	validate := config.ValidateStored
+	if fresh {
+		validate = config.Validate
+	}
+	if err := validate(); err != nil {
+		return nil, err
+	}
+	if err := vm.ValidateEVMForks(config); err != nil {
 		return nil, err
 	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), g.Difficulty)
@@ -162,3 +162,172 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+// Tests that forks can be rescheduled at startup, but only if they haven't yet
+// activated on the local chain.
+func TestSetupGenesisOverrides(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.BerlinBlock = big.NewInt(3)
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: &config}
+		genesis = gspec.MustCommit(db)
+	)
+	bc, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer bc.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, nil)
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Upcoming forks can be rescheduled, leaving the genesis config untouched
+	cfg, _, err := SetupGenesisBlockWithOverride(db, gspec, params.ForkOverrides{"bls12381": big.NewInt(10)})
+	if err != nil {
+		t.Fatalf("failed to override pending fork: %v", err)
+	}
+	if cfg.BLS12381Block == nil || cfg.BLS12381Block.Uint64() != 10 {
+		t.Errorf("override not applied: have %v, want 10", cfg.BLS12381Block)
+	}
+	if config.BLS12381Block != nil {
+		t.Errorf("genesis config modified: %v", config.BLS12381Block)
+	}
+	// Activated forks must not be rescheduled
+	_, _, err = SetupGenesisBlockWithOverride(db, gspec, params.ForkOverrides{"berlin": big.NewInt(10)})
+	if errs, ok := err.(params.ConfigErrors); !ok || errs[0].Code != params.ConfigErrForkOverride {
+		t.Errorf("activated fork override: have error %v, want %s", err, params.ConfigErrForkOverride)
+	}
+	// Unknown forks are rejected
+	if _, _, err = SetupGenesisBlockWithOverride(db, gspec, params.ForkOverrides{"london": big.NewInt(10)}); err == nil {
+		t.Error("unknown fork override accepted")
+	}
+}
+
+// Tests that the full config validation only applies to fresh genesis blocks,
+// and existing chains with configs failing the newer consistency checks still
+// start, while the safety bounds apply to every chain.
+func TestSetupGenesisValidation(t *testing.T) {
+	// Replay protection without chain id was accepted by older versions
+	config := &params.ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(0), EIP155Block: big.NewInt(0)}
+
+	db := rawdb.NewMemoryDatabase()
+	if _, _, err := SetupGenesisBlock(db, &Genesis{Config: config}); err == nil {
+		t.Fatal("invalid fresh genesis config accepted")
+	}
+	// Emulate a datadir created before the check was added
+	gspec := &Genesis{Config: config}
+	block := gspec.ToBlock(db)
+	rawdb.WriteTd(db, block.Hash(), 0, block.Difficulty())
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteCanonicalHash(db, block.Hash(), 0)
+	rawdb.WriteHeadBlockHash(db, block.Hash())
+	rawdb.WriteHeadHeaderHash(db, block.Hash())
+	rawdb.WriteChainConfig(db, block.Hash(), config)
+
+	if _, _, err := SetupGenesisBlock(db, nil); err != nil {
+		t.Fatalf("existing chain rejected: %v", err)
+	}
+	if _, _, err := SetupGenesisBlock(db, gspec); err != nil {
+		t.Fatalf("existing chain with genesis spec rejected: %v", err)
+	}
+	// Fork ordering is still enforced on existing chains
+	misordered := *config
+	misordered.EIP150Block = big.NewInt(5)
+	misordered.EIP155Block = big.NewInt(1)
+	if _, _, err := SetupGenesisBlock(db, &Genesis{Config: &misordered}); err == nil {
+		t.Fatal("misordered config accepted for existing chain")
+	}
+	// Safety bounds are enforced on existing chains too, whether their genesis
+	// state is still present or not
+	unsafe := *config
+	unsafe.EVMLimits = &params.EVMLimits{MaxCallDepth: 1 << 20}
+	if _, _, err := SetupGenesisBlock(db, &Genesis{Config: &unsafe}); err == nil {
+		t.Fatal("out of bounds limits accepted for existing chain")
+	}
+	stateless := rawdb.NewMemoryDatabase()
+	block = gspec.ToBlock(nil)
+	rawdb.WriteBlock(stateless, block)
+	rawdb.WriteCanonicalHash(stateless, block.Hash(), 0)
+	rawdb.WriteHeadHeaderHash(stateless, block.Hash())
+	rawdb.WriteChainConfig(stateless, block.Hash(), config)
+
+	if _, _, err := SetupGenesisBlock(stateless, &Genesis{Config: &unsafe}); err == nil {
+		t.Fatal("out of bounds limits accepted for existing chain without genesis state")
+	}
+	if _, _, err := SetupGenesisBlock(stateless, gspec); err != nil {
+		t.Fatalf("existing chain without genesis state rejected: %v", err)
+	}
+}
+
+// Tests that the EVM limits of a chain can't be changed once it has blocks beyond
+// genesis, as they apply to every block from genesis on.
+func TestSetupGenesisLimitsChange(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.ChainID = big.NewInt(1337)
+	config.EVMLimits = &params.EVMLimits{MaxCallDepth: 2048}
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: &config}
+		genesis = gspec.MustCommit(db)
+	)
+	bc, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer bc.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, nil)
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	changed := config
+	changed.EVMLimits = &params.EVMLimits{MaxCallDepth: 4096}
+
+	_, _, err := SetupGenesisBlock(db, &Genesis{Config: &changed})
+	if errs, ok := err.(params.ConfigErrors); !ok || errs[0].Code != params.ConfigErrEVMLimits {
+		t.Fatalf("limits change accepted: have error %v, want %s", err, params.ConfigErrEVMLimits)
+	}
+	if stored := rawdb.ReadChainConfig(db, genesis.Hash()); stored.EVMLimits.MaxCallDepth != 2048 {
+		t.Errorf("changed limits stored: have call depth %d, want 2048", stored.EVMLimits.MaxCallDepth)
+	}
+}
+
+// Tests that issuance changes on a chain with blocks either rewind the chain to
+// the first block affected, or are rejected if they reach back to block 1.
+func TestSetupGenesisRewardsChange(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.Rewards = &params.RewardSchedule{
+		Steps:        []*params.RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(1000)}},
+		EraLength:    3,
+		EraReduction: 50,
+	}
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: &config}
+		genesis = gspec.MustCommit(db)
+	)
+	bc, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer bc.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 5, nil)
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Era changes only affect the blocks from the first era on
+	eras := config
+	eras.Rewards = &params.RewardSchedule{Steps: config.Rewards.Steps, EraLength: 3, EraReduction: 20}
+
+	_, _, err := SetupGenesisBlock(db, &Genesis{Config: &eras})
+	if compat, ok := err.(*params.ConfigCompatError); !ok || compat.RewindTo != 2 {
+		t.Errorf("era change: have error %v, want rewind to 2", err)
+	}
+	// Uncle reward changes affect every block, they can't be rewound
+	divisors := config
+	divisors.Rewards = &params.RewardSchedule{Steps: config.Rewards.Steps, EraLength: 3, EraReduction: 50, UncleDivisor: 16}
+
+	_, _, err = SetupGenesisBlock(db, &Genesis{Config: &divisors})
+	if errs, ok := err.(params.ConfigErrors); !ok || errs[0].Code != params.ConfigErrRewards {
+		t.Errorf("divisor change: have error %v, want %s", err, params.ConfigErrRewards)
+	}
+	if stored := rawdb.ReadChainConfig(db, genesis.Hash()); stored.Rewards.UncleDivisor != 0 || stored.Rewards.EraReduction != 50 {
+		t.Errorf("changed rewards stored: %+v", stored.Rewards)
+	}
+}
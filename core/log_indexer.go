@@ -0,0 +1,103 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/bitutil"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/ethdb"
+)
+
+// LogIndexer implements a core.ChainIndexer, building up a per-contract index of
+// the blocks containing logs emitted by each contract. Contrary to the bloom bits,
+// the index has no false positives, so address-constrained log queries only need
+// to look at the blocks which actually contain relevant logs.
+type LogIndexer struct {
+	size    uint64                    // section size to generate the log index for
+	db      ethdb.Database            // database instance to write index data and metadata into
+	bits    map[common.Address][]byte // block bitsets of the contracts in the current section
+	section uint64                    // Section is the section number being processed currently
+	head    common.Hash               // Head is the hash of the last header processed
+}
+
+// NewLogIndexer returns a chain indexer that generates the contract log index
+// for the canonical chain for fast address-constrained log filtering.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexPrefix))
+
+	return NewChainIndexer(db, table, backend, size, confirms, bloomThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (b *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	b.bits, b.section, b.head = make(map[common.Address][]byte), section, common.Hash{}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, marking the header's block in the
+// bitsets of all contracts that emitted logs in it.
+func (b *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	number, hash := header.Number.Uint64(), header.Hash()
+
+	receipts := rawdb.ReadRawReceipts(b.db, hash, number)
+	if receipts == nil && header.Bloom != (types.Bloom{}) {
+		return fmt.Errorf("missing receipts of block #%d [%x..]", number, hash.Bytes()[:4])
+	}
+	offset := number - b.section*b.size
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			bits := b.bits[log.Address]
+			if bits == nil {
+				bits = make([]byte, b.size/8)
+				b.bits[log.Address] = bits
+			}
+			bits[offset/8] |= 1 << byte(7-offset%8)
+		}
+	}
+	b.head = hash
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the bitsets of the section
+// out into the database.
+func (b *LogIndexer) Commit() error {
+	batch := b.db.NewBatch()
+	for address, bits := range b.bits {
+		rawdb.WriteLogIndex(batch, address, b.section, b.head, bitutil.CompressBytes(bits))
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (b *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
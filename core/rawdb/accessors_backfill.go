@@ -0,0 +1,81 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+)
+
+// ReadBackfillStatus retrieves the serialized status of the backwards header
+// sync saved at the last shutdown.
+func ReadBackfillStatus(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(backfillStatusKey)
+	return data
+}
+
+// WriteBackfillStatus stores the serialized status of the backwards header sync.
+func WriteBackfillStatus(db ethdb.KeyValueWriter, status []byte) {
+	if err := db.Put(backfillStatusKey, status); err != nil {
+		log.Crit("Failed to store backfill status", "err", err)
+	}
+}
+
+// DeleteBackfillStatus deletes the serialized status of the backwards header sync.
+func DeleteBackfillStatus(db ethdb.KeyValueWriter) {
+	if err := db.Delete(backfillStatusKey); err != nil {
+		log.Crit("Failed to remove backfill status", "err", err)
+	}
+}
+
+// ReadBackfillHeader retrieves the header with the given number downloaded by
+// the backwards header sync, but not yet linked into the local chain.
+func ReadBackfillHeader(db ethdb.KeyValueReader, number uint64) *types.Header {
+	data, _ := db.Get(backfillHeaderKey(number))
+	if len(data) == 0 {
+		return nil
+	}
+	header := new(types.Header)
+	if err := rlp.Decode(bytes.NewReader(data), header); err != nil {
+		log.Error("Invalid backfill header RLP", "number", number, "err", err)
+		return nil
+	}
+	return header
+}
+
+// WriteBackfillHeader stores a header downloaded by the backwards header sync.
+func WriteBackfillHeader(db ethdb.KeyValueWriter, header *types.Header) {
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		log.Crit("Failed to RLP encode backfill header", "err", err)
+	}
+	if err := db.Put(backfillHeaderKey(header.Number.Uint64()), data); err != nil {
+		log.Crit("Failed to store backfill header", "err", err)
+	}
+}
+
+// DeleteBackfillHeader removes the header with the given number downloaded by
+// the backwards header sync.
+func DeleteBackfillHeader(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(backfillHeaderKey(number)); err != nil {
+		log.Crit("Failed to delete backfill header", "err", err)
+	}
+}
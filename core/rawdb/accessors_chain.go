@@ -269,6 +269,30 @@ func WriteFastTxLookupLimit(db ethdb.KeyValueWriter, number uint64) {
 	}
 }
 
+// ReadHistoryTails retrieves the numbers of the oldest blocks whose bodies and
+// receipts have been downloaded, older ones having been skipped by the sync.
+// Zero means the entire history is available.
+func ReadHistoryTails(db ethdb.KeyValueReader) (bodies uint64, receipts uint64) {
+	if data, _ := db.Get(bodyHistoryTailKey); len(data) == 8 {
+		bodies = binary.BigEndian.Uint64(data)
+	}
+	if data, _ := db.Get(receiptHistoryTailKey); len(data) == 8 {
+		receipts = binary.BigEndian.Uint64(data)
+	}
+	return bodies, receipts
+}
+
+// WriteHistoryTails stores the numbers of the oldest blocks whose bodies and
+// receipts have been downloaded into the database.
+func WriteHistoryTails(db ethdb.KeyValueWriter, bodies uint64, receipts uint64) {
+	if err := db.Put(bodyHistoryTailKey, encodeBlockNumber(bodies)); err != nil {
+		log.Crit("Failed to store the body history tail", "err", err)
+	}
+	if err := db.Put(receiptHistoryTailKey, encodeBlockNumber(receipts)); err != nil {
+		log.Crit("Failed to store the receipt history tail", "err", err)
+	}
+}
+
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawValue {
 	// First try to look up the data in ancient database. Extra hash
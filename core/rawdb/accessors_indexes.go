@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/acent/go-acent/common"
@@ -155,6 +156,115 @@ func WriteBloomBits(db ethdb.KeyValueWriter, bit uint, section uint64, head comm
 	}
 }
 
+// ReadBloomBitsSize retrieves the number of blocks of the bloom bits sections the
+// index was built with, zero if unknown.
+func ReadBloomBitsSize(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(bloomBitsSizeKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteBloomBitsSize stores the number of blocks of the bloom bits sections.
+func WriteBloomBitsSize(db ethdb.KeyValueWriter, size uint64) {
+	if err := db.Put(bloomBitsSizeKey, encodeBlockNumber(size)); err != nil {
+		log.Crit("Failed to store the bloom bits section size", "err", err)
+	}
+}
+
+// ReadBloomBitsExtent retrieves the first and the number of the bloom bits sections
+// rebuilt beyond the contiguously indexed ones, zero if there are none.
+func ReadBloomBitsExtent(db ethdb.KeyValueReader) (uint64, uint64) {
+	data, _ := db.Get(bloomBitsExtentKey)
+	if len(data) != 16 {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint64(data[:8]), binary.BigEndian.Uint64(data[8:])
+}
+
+// WriteBloomBitsExtent stores the first and the number of the bloom bits sections
+// rebuilt beyond the contiguously indexed ones.
+func WriteBloomBitsExtent(db ethdb.KeyValueWriter, first, sections uint64) {
+	if err := db.Put(bloomBitsExtentKey, append(encodeBlockNumber(first), encodeBlockNumber(sections)...)); err != nil {
+		log.Crit("Failed to store the bloom bits extent", "err", err)
+	}
+}
+
+// DeleteBloomBitsExtent removes the extent of the rebuilt bloom bits sections.
+func DeleteBloomBitsExtent(db ethdb.KeyValueWriter) {
+	if err := db.Delete(bloomBitsExtentKey); err != nil {
+		log.Crit("Failed to delete the bloom bits extent", "err", err)
+	}
+}
+
+// ReadLogIndex retrieves the compressed bit vector of the blocks containing logs
+// emitted by the given contract within a section. Nil is returned if the contract
+// emitted no logs in the section.
+func ReadLogIndex(db ethdb.KeyValueReader, address common.Address, section uint64, head common.Hash) []byte {
+	data, _ := db.Get(logIndexKey(address, section, head))
+	return data
+}
+
+// WriteLogIndex stores the compressed bit vector of the blocks containing logs
+// emitted by the given contract within a section.
+func WriteLogIndex(db ethdb.KeyValueWriter, address common.Address, section uint64, head common.Hash, bits []byte) {
+	if err := db.Put(logIndexKey(address, section, head), bits); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// CallIndexEntry locates a transaction calling a contract, directly or internally.
+type CallIndexEntry struct {
+	Number uint64 // Number of the block containing the transaction
+	Index  uint64 // Index of the transaction within the block
+}
+
+// ReadCallIndex retrieves the transactions calling the given contract within a
+// section. Nil is returned if the contract wasn't called in the section.
+func ReadCallIndex(db ethdb.KeyValueReader, address common.Address, section uint64, head common.Hash) []CallIndexEntry {
+	data, _ := db.Get(callIndexKey(address, section, head))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []CallIndexEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid call index RLP", "address", address, "section", section, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WriteCallIndex stores the transactions calling the given contract within a
+// section.
+func WriteCallIndex(db ethdb.KeyValueWriter, address common.Address, section uint64, head common.Hash, entries []CallIndexEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to encode call index", "err", err)
+	}
+	if err := db.Put(callIndexKey(address, section, head), data); err != nil {
+		log.Crit("Failed to store call index", "err", err)
+	}
+}
+
+// ReadCallIndexTail retrieves the number of the oldest block from which on the
+// call index is complete, older blocks lacking the state to be traced on.
+func ReadCallIndexTail(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(callIndexTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteCallIndexTail stores the number of the oldest block from which on the
+// call index is complete.
+func WriteCallIndexTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(callIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the call index tail", "err", err)
+	}
+}
+
 // DeleteBloombits removes all compressed bloom bits vector belonging to the
 // given section range and bit index.
 func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -28,10 +29,17 @@ import (
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/ethdb/leveldb"
 	"github.com/acent/go-acent/ethdb/memorydb"
+	"github.com/acent/go-acent/ethdb/remotefreezer"
 	"github.com/acent/go-acent/log"
 	"github.com/olekukonko/tablewriter"
 )
 
+// Supported key-value database engines.
+const (
+	EngineLevelDB = "leveldb"
+	EnginePebble  = "pebble"
+)
+
 // freezerdb is a database wrapper that enabled freezer data retrievals.
 type freezerdb struct {
 	ethdb.KeyValueStore
@@ -58,18 +66,18 @@ func (frdb *freezerdb) Close() error {
 // a freeze cycle completes, without having to sleep for a minute to trigger the
 // automatic background run.
 func (frdb *freezerdb) Freeze(threshold uint64) error {
-	if frdb.AncientStore.(*freezer).readonly {
+	if frdb.AncientStore.(*chainFreezer).readonly {
 		return errReadOnly
 	}
 	// Set the freezer threshold to a temporary value
 	defer func(old uint64) {
-		atomic.StoreUint64(&frdb.AncientStore.(*freezer).threshold, old)
-	}(atomic.LoadUint64(&frdb.AncientStore.(*freezer).threshold))
-	atomic.StoreUint64(&frdb.AncientStore.(*freezer).threshold, threshold)
+		atomic.StoreUint64(&frdb.AncientStore.(*chainFreezer).threshold, old)
+	}(atomic.LoadUint64(&frdb.AncientStore.(*chainFreezer).threshold))
+	atomic.StoreUint64(&frdb.AncientStore.(*chainFreezer).threshold, threshold)
 
 	// Trigger a freeze cycle and block until it's done
 	trigger := make(chan struct{}, 1)
-	frdb.AncientStore.(*freezer).trigger <- trigger
+	frdb.AncientStore.(*chainFreezer).trigger <- trigger
 	<-trigger
 	return nil
 }
@@ -131,6 +139,13 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 	if err != nil {
 		return nil, err
 	}
+	return NewDatabaseWithAncientStore(db, frdb, readonly)
+}
+
+// NewDatabaseWithAncientStore creates a high level database on top of a given
+// key-value data store and ancient store, moving immutable chain segments from
+// the former into the latter.
+func NewDatabaseWithAncientStore(db ethdb.KeyValueStore, frdb ethdb.AncientStore, readonly bool) (ethdb.Database, error) {
 	// Since the freezer can be stored separately from the user's key-value database,
 	// there's a fairly high probability that the user requests invalid combinations
 	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
@@ -196,12 +211,13 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
-	if !frdb.readonly {
-		go frdb.freeze(db)
+	chain := newChainFreezer(frdb, readonly)
+	if !readonly {
+		go chain.freeze(db)
 	}
 	return &freezerdb{
 		KeyValueStore: db,
-		AncientStore:  frdb,
+		AncientStore:  chain,
 	}, nil
 }
 
@@ -243,6 +259,80 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
+// NewDatabaseWithRemoteFreezer creates a high level database on top of a given
+// key-value data store with a freezer moving immutable chain segments into an
+// S3-compatible object store, caching the recently accessed segments in a local
+// directory.
+func NewDatabaseWithRemoteFreezer(db ethdb.KeyValueStore, url string, cacheDir string, namespace string, readonly bool) (ethdb.Database, error) {
+	config, err := remotefreezer.ParseS3URL(url)
+	if err != nil {
+		return nil, err
+	}
+	store, err := remotefreezer.NewS3Store(*config)
+	if err != nil {
+		return nil, err
+	}
+	ancients, err := remotefreezer.New(remotefreezer.Config{
+		Store:     store,
+		CacheDir:  cacheDir,
+		Namespace: namespace,
+		ReadOnly:  readonly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithAncientStore(db, ancients, readonly)
+	if err != nil {
+		ancients.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
+// NewKeyValueStore opens a persistent key-value database with the given engine.
+// If no engine is requested, the one of the database already present at the
+// given path is used, falling back to LevelDB for new databases. Requesting an
+// engine different from the one of an existing database is an error.
+func NewKeyValueStore(engine string, file string, cache int, handles int, namespace string, readonly bool) (ethdb.KeyValueStore, error) {
+	existing := PreexistingDatabase(file)
+	switch {
+	case engine == "" && existing != "":
+		engine = existing
+	case engine == "":
+		engine = EngineLevelDB
+	case existing != "" && existing != engine:
+		return nil, fmt.Errorf("database engine mismatch: requested %s, existing database is %s", engine, existing)
+	}
+	switch engine {
+	case EngineLevelDB:
+		log.Info("Using leveldb as the backing database", "path", file)
+		db, err := leveldb.New(file, cache, handles, namespace, readonly)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case EnginePebble:
+		log.Info("Using pebble as the backing database", "path", file)
+		return newPebbleDatabase(file, cache, handles, namespace, readonly)
+	default:
+		return nil, fmt.Errorf("unknown database engine %q", engine)
+	}
+}
+
+// PreexistingDatabase checks the given path for an existing database and returns
+// the engine it was created with, or an empty string if there's none.
+func PreexistingDatabase(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
+		return "" // No database present
+	}
+	// Both engines use the same manifest layout, but only pebble persists its
+	// options into dedicated files
+	if matches, err := filepath.Glob(filepath.Join(path, "OPTIONS*")); err == nil && len(matches) > 0 {
+		return EnginePebble
+	}
+	return EngineLevelDB
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -298,6 +388,10 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		storageSnaps    stat
 		preimages       stat
 		bloomBits       stat
+		logIndex        stat
+		callIndex       stat
+		stateDiffs      stat
+		backfillHeaders stat
 		cliqueSnaps     stat
 
 		// Ancient store statistics
@@ -355,6 +449,18 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, logIndexPrefix) && len(key) == (len(logIndexPrefix)+common.AddressLength+8+common.HashLength):
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, LogIndexPrefix):
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, callIndexPrefix) && len(key) == (len(callIndexPrefix)+common.AddressLength+8+common.HashLength):
+			callIndex.Add(size)
+		case bytes.HasPrefix(key, CallIndexPrefix):
+			callIndex.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8+common.HashLength):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, backfillHeaderPrefix) && len(key) == (len(backfillHeaderPrefix)+8):
+			backfillHeaders.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
 		case bytes.HasPrefix(key, []byte("cht-")) ||
@@ -373,7 +479,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
 				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
+				badBlockKey, callIndexTailKey, bloomBitsSizeKey, bloomBitsExtentKey, backfillStatusKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -409,11 +515,15 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
 		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
 		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
+		{"Key-Value store", "Backfill headers", backfillHeaders.Size(), backfillHeaders.Count()},
 		{"Key-Value store", "Difficulties", tds.Size(), tds.Count()},
 		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
+		{"Key-Value store", "Contract log index", logIndex.Size(), logIndex.Count()},
+		{"Key-Value store", "Contract call index", callIndex.Size(), callIndex.Count()},
+		{"Key-Value store", "State history", stateDiffs.Size(), stateDiffs.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
@@ -73,6 +73,10 @@ func (i *indexEntry) marshallBinary() []byte {
 // freezerTable represents a single chained data table within the freezer (e.g. blocks).
 // It consists of a data file (snappy encoded arbitrary data blobs) and an indexEntry
 // file (uncompressed 64 bit indices into the data file).
+//
+// Every item is stored and retrieved as a separate blob of at most a single block's
+// data, the table is never encoded as one RLP list. Streaming whole sections of the
+// chain (e.g. an export) is done by reading the items one by one instead.
 type freezerTable struct {
 	// WARNING: The `items` field is accessed atomically. On 32 bit platforms, only
 	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
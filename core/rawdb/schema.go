@@ -60,12 +60,31 @@ var (
 	// snapshotSyncStatusKey tracks the snapshot sync status across restarts.
 	snapshotSyncStatusKey = []byte("SnapshotSyncStatus")
 
+	// backfillStatusKey tracks the backwards header sync status across restarts.
+	backfillStatusKey = []byte("BackfillStatus")
+
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// bodyHistoryTailKey tracks the oldest block whose body has been downloaded.
+	bodyHistoryTailKey = []byte("BodyHistoryTail")
+
+	// receiptHistoryTailKey tracks the oldest block whose receipts have been downloaded.
+	receiptHistoryTailKey = []byte("ReceiptHistoryTail")
+
+	// callIndexTailKey tracks the oldest block from which on the call index is complete.
+	callIndexTailKey = []byte("CallIndexTail")
+
+	// bloomBitsSizeKey tracks the number of blocks of the bloom bits index sections.
+	bloomBitsSizeKey = []byte("BloomBitsSize")
+
+	// bloomBitsExtentKey tracks the bloom bits sections rebuilt beyond the ones
+	// contiguously indexed.
+	bloomBitsExtentKey = []byte("BloomBitsExtent")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -81,17 +100,26 @@ var (
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 
+	backfillHeaderPrefix = []byte("S") // backfillHeaderPrefix + num (uint64 big endian) -> header of a chain being synced backwards
+
 	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix        = []byte("A") // logIndexPrefix + address + section (uint64 big endian) + hash -> log index bits
+	callIndexPrefix       = []byte("C") // callIndexPrefix + address + section (uint64 big endian) + hash -> call index entries
+	stateDiffPrefix       = []byte("D") // stateDiffPrefix + num (uint64 big endian) + hash -> reverse state diff
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	SnapshotImportPrefix = []byte("snapshot-import-") // SnapshotImportPrefix + snapshot key -> snapshot entry staged by an import
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("acent-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iA") // LogIndexPrefix is the data table of the contract log indexer to track its progress
+	CallIndexPrefix      = []byte("iC") // CallIndexPrefix is the data table of the contract call indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -174,6 +202,16 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// backfillHeaderKey = backfillHeaderPrefix + num (uint64 big endian)
+func backfillHeaderKey(number uint64) []byte {
+	return append(backfillHeaderPrefix, encodeBlockNumber(number)...)
+}
+
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -204,6 +242,24 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKey = logIndexPrefix + address + section (uint64 big endian) + hash
+func logIndexKey(address common.Address, section uint64, hash common.Hash) []byte {
+	key := append(append(append(logIndexPrefix, address.Bytes()...), make([]byte, 8)...), hash.Bytes()...)
+
+	binary.BigEndian.PutUint64(key[1+common.AddressLength:], section)
+
+	return key
+}
+
+// callIndexKey = callIndexPrefix + address + section (uint64 big endian) + hash
+func callIndexKey(address common.Address, section uint64, hash common.Hash) []byte {
+	key := append(append(append(callIndexPrefix, address.Bytes()...), make([]byte, 8)...), hash.Bytes()...)
+
+	binary.BigEndian.PutUint64(key[1+common.AddressLength:], section)
+
+	return key
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
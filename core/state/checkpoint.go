@@ -0,0 +1,73 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+)
+
+// checkpoint is a frozen copy of the state along with the transaction context
+// it was taken in.
+type checkpoint struct {
+	state        *StateDB
+	thash, bhash common.Hash
+	txIndex      int
+}
+
+// Checkpoint captures the entire state, including the refund counter and the
+// logs, returning an identifier to restore it with. Unlike journal snapshots,
+// which are discarded when a transaction is finalised, checkpoints span any
+// number of transactions and can be restored repeatedly, allowing speculative
+// re-execution of transaction sequences. They should be taken between
+// transactions: snapshots taken before a checkpoint cannot be reverted after
+// it's restored. Checkpoints must be released with DiscardCheckpoint.
+func (s *StateDB) Checkpoint() int {
+	cp := &checkpoint{
+		state:   s.Copy(),
+		thash:   s.thash,
+		bhash:   s.bhash,
+		txIndex: s.txIndex,
+	}
+	cp.state.prefetcher = nil // Restores keep the live prefetcher
+
+	if s.checkpoints == nil {
+		s.checkpoints = make(map[int]*checkpoint)
+	}
+	id := s.nextCheckpointId
+	s.nextCheckpointId++
+	s.checkpoints[id] = cp
+	return id
+}
+
+// RestoreCheckpoint reverts the state to the given checkpoint, discarding all the
+// changes made since, including finalised transactions. The checkpoint remains
+// valid and can be restored again.
+func (s *StateDB) RestoreCheckpoint(id int) {
+	cp, ok := s.checkpoints[id]
+	if !ok {
+		panic(fmt.Errorf("checkpoint id %v cannot be restored", id))
+	}
+	cp.state.copyInto(s)
+	s.thash, s.bhash, s.txIndex = cp.thash, cp.bhash, cp.txIndex
+}
+
+// DiscardCheckpoint releases a checkpoint which is not needed anymore.
+func (s *StateDB) DiscardCheckpoint(id int) {
+	delete(s.checkpoints, id)
+}
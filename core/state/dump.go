@@ -37,14 +37,15 @@ type DumpCollector interface {
 
 // DumpAccount represents an account in the state.
 type DumpAccount struct {
-	Balance   string                 `json:"balance"`
-	Nonce     uint64                 `json:"nonce"`
-	Root      string                 `json:"root"`
-	CodeHash  string                 `json:"codeHash"`
-	Code      string                 `json:"code,omitempty"`
-	Storage   map[common.Hash]string `json:"storage,omitempty"`
-	Address   *common.Address        `json:"address,omitempty"` // Address only present in iterative (line-by-line) mode
-	SecureKey hexutil.Bytes          `json:"key,omitempty"`     // If we don't have address, we can output the key
+	Balance     string                 `json:"balance"`
+	Nonce       uint64                 `json:"nonce"`
+	Root        string                 `json:"root"`
+	CodeHash    string                 `json:"codeHash"`
+	Code        string                 `json:"code,omitempty"`
+	Storage     map[common.Hash]string `json:"storage,omitempty"`
+	Address     *common.Address        `json:"address,omitempty"`     // Address only present in iterative (line-by-line) mode
+	SecureKey   hexutil.Bytes          `json:"key,omitempty"`         // If we don't have address, we can output the key
+	StorageNext *common.Hash           `json:"storageNext,omitempty"` // Hash of the first omitted slot if the storage was truncated
 
 }
 
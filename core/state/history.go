@@ -0,0 +1,368 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+)
+
+// errHistoricalState is returned when attempting an operation which requires the
+// trie of a historical state, which only exists as reverse diffs.
+var errHistoricalState = errors.New("not supported on historical state")
+
+// StateDiff is the reverse diff of a state transition, holding the values that
+// the accounts and storage slots modified by the transition had before it.
+type StateDiff struct {
+	Accounts []AccountDiff // Modified accounts, ordered by hash
+}
+
+// AccountDiff holds the values an account had before a state transition.
+type AccountDiff struct {
+	Hash    common.Hash // Hash of the account address
+	Account []byte      // Trie encoding of the account, empty if it didn't exist
+	Storage []SlotDiff  // Modified storage slots, ordered by hash
+}
+
+// SlotDiff holds the value a storage slot had before a state transition.
+type SlotDiff struct {
+	Hash  common.Hash // Hash of the slot key
+	Value []byte      // Trie encoding of the value, empty if the slot was unset
+}
+
+// DiffState computes the reverse diff of the transition between two states,
+// both of which must be present in the trie database.
+func DiffState(db Database, parent, root common.Hash) (*StateDiff, error) {
+	triedb := db.TrieDB()
+
+	accounts, err := diffTries(triedb, parent, root)
+	if err != nil {
+		return nil, err
+	}
+	next, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, err
+	}
+	diff := &StateDiff{Accounts: make([]AccountDiff, 0, len(accounts))}
+	for hash, prev := range accounts {
+		// Diff the storage too if the account's storage root changed
+		account := AccountDiff{Hash: hash, Account: prev}
+
+		before, err := storageRoot(prev)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := next.TryGet(hash[:])
+		if err != nil {
+			return nil, err
+		}
+		after, err := storageRoot(enc)
+		if err != nil {
+			return nil, err
+		}
+		if before != after {
+			slots, err := diffTries(triedb, before, after)
+			if err != nil {
+				return nil, err
+			}
+			for slot, value := range slots {
+				account.Storage = append(account.Storage, SlotDiff{Hash: slot, Value: value})
+			}
+			sort.Slice(account.Storage, func(i, j int) bool {
+				return bytes.Compare(account.Storage[i].Hash[:], account.Storage[j].Hash[:]) < 0
+			})
+		}
+		diff.Accounts = append(diff.Accounts, account)
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	return diff, nil
+}
+
+// diffTries returns the keys whose values differ between two tries, mapped to
+// their values in the first one (nil if absent).
+func diffTries(triedb *trie.Database, parent, root common.Hash) (map[common.Hash][]byte, error) {
+	prev, err := trie.New(parent, triedb)
+	if err != nil {
+		return nil, err
+	}
+	next, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, err
+	}
+	diff := make(map[common.Hash][]byte)
+
+	// Leaves modified or deleted by the transition carry the prior values
+	it, _ := trie.NewDifferenceIterator(next.NodeIterator(nil), prev.NodeIterator(nil))
+	for it.Next(true) {
+		if it.Leaf() {
+			diff[common.BytesToHash(it.LeafKey())] = common.CopyBytes(it.LeafBlob())
+		}
+	}
+	if it.Error() != nil {
+		return nil, it.Error()
+	}
+	// Leaves only present after the transition were created by it
+	it, _ = trie.NewDifferenceIterator(prev.NodeIterator(nil), next.NodeIterator(nil))
+	for it.Next(true) {
+		if it.Leaf() {
+			key := common.BytesToHash(it.LeafKey())
+			if _, ok := diff[key]; !ok {
+				diff[key] = nil
+			}
+		}
+	}
+	if it.Error() != nil {
+		return nil, it.Error()
+	}
+	return diff, nil
+}
+
+// storageRoot returns the storage root of a trie encoded account, which is the
+// empty root if the account doesn't exist.
+func storageRoot(enc []byte) (common.Hash, error) {
+	if len(enc) == 0 {
+		return emptyRoot, nil
+	}
+	var account Account
+	if err := rlp.DecodeBytes(enc, &account); err != nil {
+		return common.Hash{}, err
+	}
+	return account.Root, nil
+}
+
+// account returns the prior value of an account if modified by the transition.
+func (d *StateDiff) account(hash common.Hash) (*AccountDiff, bool) {
+	i := sort.Search(len(d.Accounts), func(i int) bool {
+		return bytes.Compare(d.Accounts[i].Hash[:], hash[:]) >= 0
+	})
+	if i < len(d.Accounts) && d.Accounts[i].Hash == hash {
+		return &d.Accounts[i], true
+	}
+	return nil, false
+}
+
+// slot returns the prior value of a storage slot if modified by the transition.
+func (d *AccountDiff) slot(hash common.Hash) ([]byte, bool) {
+	i := sort.Search(len(d.Storage), func(i int) bool {
+		return bytes.Compare(d.Storage[i].Hash[:], hash[:]) >= 0
+	})
+	if i < len(d.Storage) && d.Storage[i].Hash == hash {
+		return d.Storage[i].Value, true
+	}
+	return nil, false
+}
+
+// NewHistorical creates a state of a past block, reconstructed from the state
+// of a later block and the reverse diffs of the blocks in between, ordered from
+// the oldest. Reads are served from the first diff which touched the requested
+// item, or from the later state if none did.
+//
+// The state can be modified in memory, but neither committed nor proven.
+func NewHistorical(root common.Hash, db Database, head common.Hash, diffs []*StateDiff) (*StateDB, error) {
+	tr, err := trie.New(head, db.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	history := &stateHistory{
+		root:    root,
+		triedb:  db.TrieDB(),
+		head:    tr,
+		diffs:   diffs,
+		storage: make(map[common.Hash]*trie.Trie),
+	}
+	return New(root, &historyDatabase{Database: db, history: history}, nil)
+}
+
+// stateHistory resolves the values of a historical state.
+type stateHistory struct {
+	root   common.Hash // Root of the historical state
+	triedb *trie.Database
+	head   *trie.Trie   // Account trie of the later state
+	diffs  []*StateDiff // Reverse diffs from the historical to the later state
+
+	storage map[common.Hash]*trie.Trie // Storage tries of the later state
+	lock    sync.Mutex                 // Lock protecting the later state tries
+}
+
+// account retrieves the trie encoding of an account.
+func (h *stateHistory) account(hash common.Hash) ([]byte, error) {
+	for _, diff := range h.diffs {
+		if account, ok := diff.account(hash); ok {
+			return account.Account, nil
+		}
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.head.TryGet(hash[:])
+}
+
+// slot retrieves the trie encoding of a storage slot.
+func (h *stateHistory) slot(owner, hash common.Hash) ([]byte, error) {
+	for _, diff := range h.diffs {
+		if account, ok := diff.account(owner); ok {
+			if value, ok := account.slot(hash); ok {
+				return value, nil
+			}
+		}
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	tr, ok := h.storage[owner]
+	if !ok {
+		enc, err := h.head.TryGet(owner[:])
+		if err != nil {
+			return nil, err
+		}
+		root, err := storageRoot(enc)
+		if err != nil {
+			return nil, err
+		}
+		if tr, err = trie.New(root, h.triedb); err != nil {
+			return nil, err
+		}
+		h.storage[owner] = tr
+	}
+	return tr.TryGet(hash[:])
+}
+
+// historyDatabase is a state database serving the tries of a historical state.
+type historyDatabase struct {
+	Database // Database of the later state, serving contract code
+	history  *stateHistory
+}
+
+// OpenTrie opens the account trie of the historical state.
+func (db *historyDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	if root != db.history.root {
+		return nil, fmt.Errorf("historical state %x unavailable", root)
+	}
+	return &historyTrie{history: db.history, root: root}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account of the historical state.
+func (db *historyDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	return &historyTrie{history: db.history, owner: &addrHash, root: root}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *historyDatabase) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*historyTrie); ok {
+		return t.copy()
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// historyTrie is an account or storage trie of a historical state, keeping any
+// modifications in memory.
+type historyTrie struct {
+	history *stateHistory
+	owner   *common.Hash           // Account owning the storage trie, nil for the account trie
+	root    common.Hash            // Root of the trie before any modifications
+	dirty   map[common.Hash][]byte // Modified values, empty if deleted
+}
+
+// GetKey returns nil, preimages are not tracked by historical states.
+func (t *historyTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// TryGet returns the value for key stored in the trie.
+func (t *historyTrie) TryGet(key []byte) ([]byte, error) {
+	hash := crypto.Keccak256Hash(key)
+	if value, ok := t.dirty[hash]; ok {
+		return value, nil
+	}
+	if t.owner == nil {
+		return t.history.account(hash)
+	}
+	return t.history.slot(*t.owner, hash)
+}
+
+// TryUpdate associates key with value in the trie.
+func (t *historyTrie) TryUpdate(key, value []byte) error {
+	if t.dirty == nil {
+		t.dirty = make(map[common.Hash][]byte)
+	}
+	t.dirty[crypto.Keccak256Hash(key)] = common.CopyBytes(value)
+	return nil
+}
+
+// TryDelete removes any existing value for key from the trie.
+func (t *historyTrie) TryDelete(key []byte) error {
+	return t.TryUpdate(key, nil)
+}
+
+// Hash returns the root hash of the trie, disregarding any modifications as the
+// historical trie nodes are not available to recompute it.
+func (t *historyTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit is not supported on historical states.
+func (t *historyTrie) Commit(onleaf trie.LeafCallback) (common.Hash, error) {
+	return common.Hash{}, errHistoricalState
+}
+
+// NodeIterator returns an iterator failing right away, historical states being
+// unable to iterate their contents.
+func (t *historyTrie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return historyIterator{}
+}
+
+// Prove is not supported on historical states.
+func (t *historyTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errHistoricalState
+}
+
+// copy returns an independent copy of the trie.
+func (t *historyTrie) copy() *historyTrie {
+	cpy := &historyTrie{history: t.history, owner: t.owner, root: t.root}
+	if t.dirty != nil {
+		cpy.dirty = make(map[common.Hash][]byte, len(t.dirty))
+		for hash, value := range t.dirty {
+			cpy.dirty[hash] = value
+		}
+	}
+	return cpy
+}
+
+// historyIterator is a trie iterator of a historical state, which is always
+// exhausted with an error.
+type historyIterator struct{}
+
+func (historyIterator) Next(bool) bool      { return false }
+func (historyIterator) Error() error        { return errHistoricalState }
+func (historyIterator) Hash() common.Hash   { return common.Hash{} }
+func (historyIterator) Parent() common.Hash { return common.Hash{} }
+func (historyIterator) Path() []byte        { return nil }
+func (historyIterator) Leaf() bool          { return false }
+func (historyIterator) LeafKey() []byte     { return nil }
+func (historyIterator) LeafBlob() []byte    { return nil }
+func (historyIterator) LeafProof() [][]byte { return nil }
@@ -17,7 +17,9 @@
 package state
 
 import (
+	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/acent/go-acent/common"
 )
@@ -32,29 +34,253 @@ type journalEntry interface {
 	dirtied() *common.Address
 }
 
+// journalLayer is a segment of the journal opened by a snapshot, keyed by the
+// id of that snapshot. Snapshot ids are handed out in increasing order, so the
+// layers are always sorted by id.
+type journalLayer struct {
+	id    int // Snapshot id that opened this layer (-1 for the base)
+	index int // Journal length when the layer was opened
+}
+
+// journalField identifies an account field whose pre-image is journalled.
+type journalField uint8
+
+const (
+	journalBalance journalField = iota
+	journalNonce
+	journalCode
+	journalRefund
+)
+
+// journalKey identifies a journalled field of a single account (or the refund
+// counter, with a zero address).
+type journalKey struct {
+	account common.Address
+	field   journalField
+}
+
+// storageKey identifies a storage slot of a live state object. Diffs are keyed
+// by object instead of address so that an account recreated within a layer
+// doesn't observe the storage of its predecessor.
+type storageKey struct {
+	object *stateObject
+	slot   common.Hash
+}
+
+// storageDiff is the value a storage slot was set to within a snapshot layer.
+type storageDiff struct {
+	id    int // Snapshot id of the layer that made the write
+	value common.Hash
+}
+
 // journal contains the list of state modifications applied since the last state
 // commit. These are tracked to be able to be reverted in case of an execution
 // exception or revertal request.
+//
+// Storage writes made within a snapshot are not applied to the state objects,
+// they are kept as copy-on-write diffs tagged with the id of the topmost layer
+// instead. Reverting a snapshot drops the layers and thereby every diff written
+// in them without touching the diffs, so the cost of a revert doesn't depend on
+// the number of storage writes done in the reverted call tree. The surviving
+// diffs are flattened into the state objects when the transaction is finalised.
+//
+// Account fields are still journalled, but only the first modification within
+// a layer is recorded: subsequent changes are copied over the same pre-image
+// and need not be journalled again.
 type journal struct {
 	entries []journalEntry         // Current changes tracked by the journal
 	dirties map[common.Address]int // Dirty accounts and the number of changes
+	layers  []journalLayer         // Snapshot layers, the first one being the base
+
+	recorded map[journalKey]int           // Layer id in which each field's pre-image was last journalled
+	storage  map[storageKey][]storageDiff // Storage writes done within snapshot layers, oldest first
 }
 
 // newJournal create a new initialized journal.
 func newJournal() *journal {
 	return &journal{
 		dirties: make(map[common.Address]int),
+		layers:  []journalLayer{{id: -1}},
 	}
 }
 
-// append inserts a new modification entry to the end of the change journal.
+// append inserts a new modification entry to the end of the change journal,
+// unless the modified field was already journalled in the current layer.
 func (j *journal) append(entry journalEntry) {
+	if j.redundant(entry) {
+		return
+	}
 	j.entries = append(j.entries, entry)
 	if addr := entry.dirtied(); addr != nil {
 		j.dirties[*addr]++
 	}
 }
 
+// redundant checks whether the pre-image overwritten by a journal entry is
+// already recorded in the current layer, marking it as recorded otherwise.
+func (j *journal) redundant(entry journalEntry) bool {
+	var key journalKey
+	switch ch := entry.(type) {
+	case refundChange:
+		key = journalKey{field: journalRefund}
+	case balanceChange:
+		key = journalKey{account: *ch.account, field: journalBalance}
+	case nonceChange:
+		key = journalKey{account: *ch.account, field: journalNonce}
+	case codeChange:
+		key = journalKey{account: *ch.account, field: journalCode}
+
+	case createObjectChange:
+		// The account is replaced, its fields need journalling anew
+		j.forget(*ch.account)
+		return false
+	case resetObjectChange:
+		j.forget(ch.prev.address)
+		return false
+	default:
+		return false
+	}
+	id := j.layers[len(j.layers)-1].id
+	if prev, ok := j.recorded[key]; ok && prev == id {
+		return true
+	}
+	if j.recorded == nil {
+		j.recorded = make(map[journalKey]int)
+	}
+	j.recorded[key] = id
+	return false
+}
+
+// forget drops the journalling marks of all the fields of an account.
+func (j *journal) forget(addr common.Address) {
+	if j.recorded == nil {
+		return
+	}
+	delete(j.recorded, journalKey{account: addr, field: journalBalance})
+	delete(j.recorded, journalKey{account: addr, field: journalNonce})
+	delete(j.recorded, journalKey{account: addr, field: journalCode})
+}
+
+// snapshot opens a new journal layer tagged with the given snapshot id. Ids
+// must be monotonically increasing.
+func (j *journal) snapshot(id int) {
+	j.layers = append(j.layers, journalLayer{id: id, index: len(j.entries)})
+}
+
+// revertToSnapshot undoes all the modifications done since the layer with the
+// given snapshot id was opened, dropping it and all subsequent layers. Storage
+// diffs of the dropped layers become unreachable and are cleaned up lazily.
+func (j *journal) revertToSnapshot(statedb *StateDB, id int) {
+	idx := j.layer(id)
+	if idx == 0 {
+		panic(fmt.Errorf("revision id %v cannot be reverted", id))
+	}
+	j.revert(statedb, j.layers[idx].index)
+	j.layers = j.layers[:idx]
+}
+
+// layer returns the index of the live layer opened by the given snapshot id, or
+// 0 if no such layer exists (the base layer cannot be reverted to).
+func (j *journal) layer(id int) int {
+	last := len(j.layers) - 1
+	if j.layers[last].id == id {
+		return last
+	}
+	idx := sort.Search(last, func(i int) bool {
+		return j.layers[i+1].id >= id
+	}) + 1
+	if idx > last || j.layers[idx].id != id {
+		return 0
+	}
+	return idx
+}
+
+// setState records a storage write in the diff layer of the current snapshot.
+// It returns false if there's no open snapshot, in which case the write needs
+// to be applied to the object and journalled the usual way.
+func (j *journal) setState(obj *stateObject, slot, value common.Hash) bool {
+	id := j.layers[len(j.layers)-1].id
+	if id < 0 {
+		return false
+	}
+	key := storageKey{object: obj, slot: slot}
+	diffs := j.live(j.storage[key])
+	if n := len(diffs); n > 0 && diffs[n-1].id == id {
+		diffs[n-1].value = value
+		return true
+	}
+	if j.storage == nil {
+		j.storage = make(map[storageKey][]storageDiff)
+	}
+	j.storage[key] = append(diffs, storageDiff{id: id, value: value})
+	return true
+}
+
+// getState retrieves the value a storage slot was set to in the topmost live
+// snapshot layer, if any.
+func (j *journal) getState(obj *stateObject, slot common.Hash) (common.Hash, bool) {
+	if len(j.storage) == 0 {
+		return common.Hash{}, false
+	}
+	key := storageKey{object: obj, slot: slot}
+	diffs, ok := j.storage[key]
+	if !ok {
+		return common.Hash{}, false
+	}
+	if live := j.live(diffs); len(live) != len(diffs) {
+		// Keep emptied lists around until flattening, the slot is likely to be
+		// written again and can then reuse the allocation.
+		j.storage[key] = live
+		diffs = live
+	}
+	if len(diffs) == 0 {
+		return common.Hash{}, false
+	}
+	return diffs[len(diffs)-1].value, true
+}
+
+// live trims the diffs written in reverted layers off the end of a slot's diff
+// list. Since diffs are appended in layer order and reverts only ever drop the
+// topmost layers, the dead diffs are always a suffix of the list.
+func (j *journal) live(diffs []storageDiff) []storageDiff {
+	for len(diffs) > 0 && j.layer(diffs[len(diffs)-1].id) == 0 {
+		diffs = diffs[:len(diffs)-1]
+	}
+	return diffs
+}
+
+// forEachState iterates over the storage slots written within the live
+// snapshot layers, along with their current values.
+func (j *journal) forEachState(cb func(obj *stateObject, slot, value common.Hash)) {
+	for key, diffs := range j.storage {
+		if diffs = j.live(diffs); len(diffs) > 0 {
+			cb(key.object, key.slot, diffs[len(diffs)-1].value)
+		}
+	}
+}
+
+// copyStates applies the storage writes made to an object within the live
+// snapshot layers to a copy of it.
+func (j *journal) copyStates(obj, cpy *stateObject) {
+	j.forEachState(func(owner *stateObject, slot, value common.Hash) {
+		if owner == obj {
+			cpy.setState(slot, value)
+		}
+	})
+}
+
+// flatten applies the storage writes of the live snapshot layers to the state
+// objects and marks the written accounts dirty. Reverting the snapshots after
+// flattening does not undo the writes anymore, so it may only be done when the
+// journal is about to be discarded.
+func (j *journal) flatten() {
+	j.forEachState(func(obj *stateObject, slot, value common.Hash) {
+		obj.setState(slot, value)
+		j.dirties[obj.address]++
+	})
+	j.storage = nil
+}
+
 // revert undoes a batch of journalled modifications along with any reverted
 // dirty handling too.
 func (j *journal) revert(statedb *StateDB, snapshot int) {
@@ -72,6 +298,12 @@ func (j *journal) revert(statedb *StateDB, snapshot int) {
 	j.entries = j.entries[:snapshot]
 }
 
+// resetLayers drops all snapshot layers, leaving an empty base layer. It's used
+// when the journal entries are discarded, but the journal itself is reused.
+func (j *journal) resetLayers() {
+	j.layers = append(j.layers[:0], journalLayer{id: -1})
+}
+
 // dirty explicitly sets an address to dirty, even if the change entries would
 // otherwise suggest it as clean. This method is an ugly hack to handle the RIPEMD
 // precompile consensus exception.
@@ -0,0 +1,306 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+)
+
+// Kinds of the entries of an exported snapshot.
+const (
+	archiveAccount = iota // Slim RLP account, keyed by account hash
+	archiveStorage        // Storage slot, keyed by account hash and slot hash
+	archiveCode           // Contract code, keyed by code hash
+)
+
+var (
+	errArchiveOrder = errors.New("snapshot entries out of order")
+	errArchiveKind  = errors.New("unknown snapshot entry")
+)
+
+// archiveEntry is a single entry of an exported snapshot. Every account is
+// followed by its storage slots and by its code, unless the same code has
+// already been exported with an earlier account.
+type archiveEntry struct {
+	Kind uint8
+	Hash common.Hash
+	Slot common.Hash
+	Data []byte
+}
+
+// ArchiveStats counts the entries of an exported snapshot.
+type ArchiveStats struct {
+	Accounts uint64
+	Slots    uint64
+	Codes    uint64
+}
+
+// Export writes the flattened state of the snapshot with the given root into w
+// as a stream of RLP entries, reading contract codes from the given database.
+func Export(t *Tree, root common.Hash, codes ethdb.KeyValueReader, w io.Writer) (ArchiveStats, error) {
+	var stats ArchiveStats
+
+	acctIt, err := t.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return stats, err
+	}
+	defer acctIt.Release()
+
+	var (
+		seen   = make(map[common.Hash]struct{})
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for acctIt.Next() {
+		hash := acctIt.Hash()
+		account, err := FullAccount(acctIt.Account())
+		if err != nil {
+			return stats, err
+		}
+		if err := rlp.Encode(w, &archiveEntry{Kind: archiveAccount, Hash: hash, Data: acctIt.Account()}); err != nil {
+			return stats, err
+		}
+		stats.Accounts++
+
+		if common.BytesToHash(account.Root) != emptyRoot {
+			storageIt, err := t.StorageIterator(root, hash, common.Hash{})
+			if err != nil {
+				return stats, err
+			}
+			for storageIt.Next() {
+				if err := rlp.Encode(w, &archiveEntry{Kind: archiveStorage, Hash: hash, Slot: storageIt.Hash(), Data: storageIt.Slot()}); err != nil {
+					storageIt.Release()
+					return stats, err
+				}
+				stats.Slots++
+			}
+			err = storageIt.Error()
+			storageIt.Release()
+			if err != nil {
+				return stats, err
+			}
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if _, ok := seen[codeHash]; !ok {
+				code := rawdb.ReadCode(codes, codeHash)
+				if len(code) == 0 {
+					return stats, fmt.Errorf("missing code %x of account %x", codeHash, hash)
+				}
+				if err := rlp.Encode(w, &archiveEntry{Kind: archiveCode, Hash: codeHash, Data: code}); err != nil {
+					return stats, err
+				}
+				seen[codeHash] = struct{}{}
+				stats.Codes++
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state snapshot", "at", hash, "accounts", stats.Accounts, "slots", stats.Slots, "codes", stats.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := acctIt.Error(); err != nil {
+		return stats, err
+	}
+	log.Info("Exported state snapshot", "root", root, "accounts", stats.Accounts, "slots", stats.Slots, "codes", stats.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return stats, nil
+}
+
+// Import replaces the snapshot in the database with the one read from r, which
+// must have been produced by Export, and regenerates the state trie of the given
+// root from it. The imported snapshot becomes the disk layer, without any diff
+// layers on top.
+//
+// The entries are staged under a separate prefix and only replace the existing
+// snapshot once the regenerated root matches, so an invalid archive leaves the
+// existing snapshot untouched.
+func Import(db ethdb.Database, r io.Reader, root common.Hash) (ArchiveStats, error) {
+	start := time.Now()
+
+	// Drop anything staged by an earlier interrupted import
+	staging := rawdb.NewTable(db, string(rawdb.SnapshotImportPrefix))
+	if err := wipeContent(staging); err != nil {
+		return ArchiveStats{}, err
+	}
+	stats, err := importStaged(db, staging, r, root, start)
+	if err != nil {
+		if err := wipeContent(staging); err != nil {
+			log.Error("Failed to drop staged snapshot", "err", err)
+		}
+		return stats, err
+	}
+	// Drop any previous snapshot and its journal, they are superseded
+	<-wipeSnapshot(db, true)
+	rawdb.DeleteSnapshotJournal(db)
+	rawdb.DeleteSnapshotGenerator(db)
+	rawdb.DeleteSnapshotRecoveryNumber(db)
+
+	// Move the staged entries in place and mark them as a fully generated disk layer
+	for _, prefix := range [][]byte{rawdb.SnapshotAccountPrefix, rawdb.SnapshotStoragePrefix} {
+		if err := moveStaged(db, staging, prefix); err != nil {
+			return stats, err
+		}
+	}
+	batch := db.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	journalProgress(batch, nil, nil)
+	if err := batch.Write(); err != nil {
+		return stats, err
+	}
+	log.Info("Imported state snapshot", "root", root, "accounts", stats.Accounts, "slots", stats.Slots, "codes", stats.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return stats, nil
+}
+
+// moveStaged moves the staged snapshot entries with the given prefix into their
+// final place in the database.
+func moveStaged(db ethdb.KeyValueStore, staging ethdb.Iteratee, prefix []byte) error {
+	var (
+		batch = db.NewBatch()
+		it    = staging.NewIterator(prefix, nil)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		batch.Put(it.Key(), it.Value())
+		batch.Delete(append(common.CopyBytes(rawdb.SnapshotImportPrefix), it.Key()...))
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// importStaged writes the snapshot entries read from r into the staging table,
+// regenerating the state trie from them and checking it against the given root.
+// Contract codes and trie nodes are keyed by their hashes, so they are written
+// into the database directly.
+func importStaged(db ethdb.KeyValueStore, staging ethdb.KeyValueStore, r io.Reader, root common.Hash, start time.Time) (ArchiveStats, error) {
+	var (
+		stats   ArchiveStats
+		stream  = rlp.NewStream(r, 0)
+		batch   = staging.NewBatch()
+		codes   = db.NewBatch()
+		account common.Hash
+		slot    common.Hash
+		slots   int // Number of slots imported for the current account
+		logged  = time.Now()
+	)
+	for index := 0; ; index++ {
+		var entry archiveEntry
+		if err := stream.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return stats, fmt.Errorf("entry %d: %v", index, err)
+		}
+		switch entry.Kind {
+		case archiveAccount:
+			if stats.Accounts > 0 && bytes.Compare(entry.Hash[:], account[:]) <= 0 {
+				return stats, fmt.Errorf("entry %d: %w: account %x after %x", index, errArchiveOrder, entry.Hash, account)
+			}
+			if _, err := FullAccount(entry.Data); err != nil {
+				return stats, fmt.Errorf("entry %d: invalid account %x: %v", index, entry.Hash, err)
+			}
+			account, slots = entry.Hash, 0
+			rawdb.WriteAccountSnapshot(batch, entry.Hash, entry.Data)
+			stats.Accounts++
+
+		case archiveStorage:
+			if stats.Accounts == 0 || entry.Hash != account {
+				return stats, fmt.Errorf("entry %d: %w: slot of account %x not following it", index, errArchiveOrder, entry.Hash)
+			}
+			if slots > 0 && bytes.Compare(entry.Slot[:], slot[:]) <= 0 {
+				return stats, fmt.Errorf("entry %d: %w: slot %x after %x", index, errArchiveOrder, entry.Slot, slot)
+			}
+			slot, slots = entry.Slot, slots+1
+			rawdb.WriteStorageSnapshot(batch, entry.Hash, entry.Slot, entry.Data)
+			stats.Slots++
+
+		case archiveCode:
+			if crypto.Keccak256Hash(entry.Data) != entry.Hash {
+				return stats, fmt.Errorf("entry %d: code hash mismatch: %x", index, entry.Hash)
+			}
+			rawdb.WriteCode(codes, entry.Hash, entry.Data)
+			stats.Codes++
+
+		default:
+			return stats, fmt.Errorf("entry %d: %w: kind %d", index, errArchiveKind, entry.Kind)
+		}
+		for _, b := range []ethdb.Batch{batch, codes} {
+			if b.ValueSize() > ethdb.IdealBatchSize {
+				if err := b.Write(); err != nil {
+					return stats, err
+				}
+				b.Reset()
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state snapshot", "at", account, "accounts", stats.Accounts, "slots", stats.Slots, "codes", stats.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	for _, b := range []ethdb.Batch{batch, codes} {
+		if err := b.Write(); err != nil {
+			return stats, err
+		}
+	}
+	// Rebuild the state trie from the staged snapshot, checking that every
+	// referenced code is present and that the result matches the given root
+	base := &diskLayer{
+		diskdb: staging,
+		triedb: trie.NewDatabase(db),
+		cache:  fastcache.New(16 * 1024 * 1024),
+		root:   root,
+	}
+	acctIt := base.AccountIterator(common.Hash{})
+	defer acctIt.Release()
+
+	got, err := generateTrieRoot(db, acctIt, common.Hash{}, stackTrieGenerate, func(nodes ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+		if codeHash != emptyCode && len(rawdb.ReadCode(db, codeHash)) == 0 {
+			return common.Hash{}, fmt.Errorf("missing code %x of account %x", codeHash, accountHash)
+		}
+		storageIt, _ := base.StorageIterator(accountHash, common.Hash{})
+		defer storageIt.Release()
+
+		return generateTrieRoot(nodes, storageIt, accountHash, stackTrieGenerate, nil, stat, false)
+	}, newGenerateStats(), true)
+
+	if err != nil {
+		return stats, err
+	}
+	if got != root {
+		return stats, fmt.Errorf("state root hash mismatch: got %x, want %x", got, root)
+	}
+	return stats, nil
+}
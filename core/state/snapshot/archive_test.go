@@ -0,0 +1,205 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+)
+
+// makeArchiveState creates a small state with plain accounts and two contracts
+// sharing the same code, returning its database and root.
+func makeArchiveState(t *testing.T) (ethdb.Database, common.Hash) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		triedb = trie.NewDatabase(diskdb)
+		code   = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	)
+	rawdb.WriteCode(diskdb, crypto.Keccak256Hash(code), code)
+
+	stTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+	stTrie.Update([]byte("key-1"), []byte("val-1"))
+	stTrie.Update([]byte("key-2"), []byte("val-2"))
+	stTrie.Update([]byte("key-3"), []byte("val-3"))
+	stRoot, _ := stTrie.Commit(nil)
+	if err := triedb.Commit(stRoot, false, nil); err != nil {
+		t.Fatalf("failed to commit storage: %v", err)
+	}
+
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+	for i, acc := range []*Account{
+		{Balance: big.NewInt(1), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()},
+		{Balance: big.NewInt(2), Root: stRoot.Bytes(), CodeHash: crypto.Keccak256(code)},
+		{Balance: big.NewInt(3), Root: stRoot.Bytes(), CodeHash: crypto.Keccak256(code)},
+	} {
+		val, _ := rlp.EncodeToBytes(acc)
+		accTrie.Update([]byte{'a', 'c', 'c', byte(i)}, val)
+	}
+	root, _ := accTrie.Commit(nil)
+	if err := triedb.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return diskdb, root
+}
+
+// Tests that an exported snapshot can be imported into an empty database, fully
+// restoring both the snapshot and the state trie.
+func TestArchiveExportImport(t *testing.T) {
+	diskdb, root := makeArchiveState(t)
+	snaps, err := New(diskdb, trie.NewDatabase(diskdb), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	var buf bytes.Buffer
+	stats, err := Export(snaps, root, diskdb, &buf)
+	if err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	if want := (ArchiveStats{Accounts: 3, Slots: 6, Codes: 1}); stats != want {
+		t.Fatalf("export stats mismatch: have %+v, want %+v", stats, want)
+	}
+	db := rawdb.NewMemoryDatabase()
+	imported, err := Import(db, bytes.NewReader(buf.Bytes()), root)
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if imported != stats {
+		t.Fatalf("import stats mismatch: have %+v, want %+v", imported, stats)
+	}
+	// The imported snapshot must load without regeneration and match the trie
+	snaps, err = New(db, trie.NewDatabase(db), 16, root, false, false, false)
+	if err != nil {
+		t.Fatalf("failed to load imported snapshot: %v", err)
+	}
+	if err := snaps.Verify(root); err != nil {
+		t.Fatalf("imported snapshot invalid: %v", err)
+	}
+	accTrie, err := trie.NewSecure(root, trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("imported state trie missing: %v", err)
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	accounts := 0
+	for it.Next() {
+		accounts++
+	}
+	if it.Err != nil || accounts != 3 {
+		t.Fatalf("imported state trie incomplete: %d accounts, error %v", accounts, it.Err)
+	}
+}
+
+// Tests that corrupted or mismatching snapshot streams are rejected.
+func TestArchiveImportInvalid(t *testing.T) {
+	diskdb, root := makeArchiveState(t)
+	snaps, err := New(diskdb, trie.NewDatabase(diskdb), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := Export(snaps, root, diskdb, &buf); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	var entries []archiveEntry
+	stream := rlp.NewStream(bytes.NewReader(buf.Bytes()), 0)
+	for {
+		var entry archiveEntry
+		if err := stream.Decode(&entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	encode := func(entries []archiveEntry) []byte {
+		var buf bytes.Buffer
+		for i := range entries {
+			rlp.Encode(&buf, &entries[i])
+		}
+		return buf.Bytes()
+	}
+	// A mismatching root must be detected after regenerating the trie
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(buf.Bytes()), common.Hash{0x01}); err == nil {
+		t.Fatal("imported snapshot with wrong root")
+	}
+	// Reversed entries violate the ordering
+	reversed := make([]archiveEntry, len(entries))
+	for i := range entries {
+		reversed[len(entries)-1-i] = entries[i]
+	}
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(encode(reversed)), root); !errors.Is(err, errArchiveOrder) {
+		t.Fatalf("reversed snapshot error mismatch: have %v, want %v", err, errArchiveOrder)
+	}
+	// Dropping the code must be detected during trie regeneration
+	var nocode []archiveEntry
+	for _, entry := range entries {
+		if entry.Kind != archiveCode {
+			nocode = append(nocode, entry)
+		}
+	}
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(encode(nocode)), root); err == nil {
+		t.Fatal("imported snapshot with missing code")
+	}
+	// Unknown entries are rejected
+	unknown := append(entries[:1:1], archiveEntry{Kind: 0xff})
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(encode(unknown)), root); !errors.Is(err, errArchiveKind) {
+		t.Fatalf("unknown entry error mismatch: have %v, want %v", err, errArchiveKind)
+	}
+}
+
+// Tests that a failed import leaves the existing snapshot of the database intact,
+// without leaving any staged entries behind.
+func TestArchiveImportKeepsSnapshot(t *testing.T) {
+	diskdb, root := makeArchiveState(t)
+	snaps, err := New(diskdb, trie.NewDatabase(diskdb), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := Export(snaps, root, diskdb, &buf); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+
+	// Neither a truncated archive nor one of a different root replace the snapshot
+	if _, err := Import(diskdb, bytes.NewReader(buf.Bytes()[:buf.Len()/2]), root); err == nil {
+		t.Fatal("imported truncated snapshot")
+	}
+	if _, err := Import(diskdb, bytes.NewReader(buf.Bytes()), common.Hash{0x01}); err == nil {
+		t.Fatal("imported snapshot with wrong root")
+	}
+	if have := rawdb.ReadSnapshotRoot(diskdb); have != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	snaps, err = New(diskdb, trie.NewDatabase(diskdb), 16, root, false, false, false)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if err := snaps.Verify(root); err != nil {
+		t.Fatalf("snapshot damaged by failed imports: %v", err)
+	}
+	it := diskdb.NewIterator(rawdb.SnapshotImportPrefix, nil)
+	defer it.Release()
+	if it.Next() {
+		t.Fatalf("staged entry left behind: %x", it.Key())
+	}
+}
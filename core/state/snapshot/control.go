@@ -0,0 +1,177 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common"
+)
+
+// errInvalidCPUShare is returned if a generator CPU share outside of (0, 1] is
+// requested.
+var errInvalidCPUShare = errors.New("cpu share must be within (0, 1]")
+
+// GeneratorProgress is the state of the background snapshot generation.
+type GeneratorProgress struct {
+	Generating bool               // Whether the snapshot is still being generated
+	Paused     bool               // Whether the generation was paused by the operator
+	Marker     []byte             // Account (and storage slot) hash the generation reached
+	Accounts   uint64             // Number of accounts indexed
+	Slots      uint64             // Number of storage slots indexed
+	Storage    common.StorageSize // Account and storage slot size
+	Elapsed    time.Duration      // Time spent generating since the last (re)start
+	RateLimit  uint64             // Maximum bytes written per second, zero if unlimited
+	CPUShare   float64            // Fraction of time the generator may be busy
+}
+
+// generatorControl holds the operator controls over the background snapshot
+// generation. It's shared by all the generator runs of a snapshot tree, which
+// are restarted every time the disk layer changes.
+type generatorControl struct {
+	paused   bool
+	resume   chan struct{} // Closed when the generator is resumed or its limits change
+	rate     uint64        // Maximum bytes written per second, zero for unlimited
+	cpuShare float64       // Fraction of time the generator may be busy, one for unlimited
+
+	progress GeneratorProgress // Progress reported by the last flush of the generator
+	lock     sync.Mutex
+}
+
+// newGeneratorControl creates an unthrottled generator control.
+func newGeneratorControl() *generatorControl {
+	return &generatorControl{
+		resume:   make(chan struct{}),
+		cpuShare: 1,
+	}
+}
+
+// setPaused pauses or resumes the generation.
+func (c *generatorControl) setPaused(paused bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.paused = paused
+	c.notify()
+}
+
+// setLimits updates the IO rate limit and CPU share of the generation.
+func (c *generatorControl) setLimits(rate uint64, cpuShare float64) error {
+	if cpuShare <= 0 || cpuShare > 1 {
+		return errInvalidCPUShare
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.rate, c.cpuShare = rate, cpuShare
+	c.notify()
+	return nil
+}
+
+// notify wakes up the generator if it's waiting, so the new settings are applied.
+// The lock must be held by the caller.
+func (c *generatorControl) notify() {
+	close(c.resume)
+	c.resume = make(chan struct{})
+}
+
+// report records the progress of the generator.
+func (c *generatorControl) report(stats *generatorStats, marker []byte) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.progress = GeneratorProgress{
+		Marker:   common.CopyBytes(marker),
+		Accounts: stats.accounts,
+		Slots:    stats.slots,
+		Storage:  stats.storage,
+		Elapsed:  time.Since(stats.start),
+	}
+}
+
+// status returns the last reported progress along with the current settings.
+func (c *generatorControl) status() *GeneratorProgress {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	progress := c.progress
+	progress.Marker = common.CopyBytes(progress.Marker)
+	progress.Paused = c.paused
+	progress.RateLimit = c.rate
+	progress.CPUShare = c.cpuShare
+	return &progress
+}
+
+// wait blocks the generator after it flushed the given amount of data, having
+// been busy for the given time, as long as it's paused or it's ahead of its
+// IO rate and CPU share allowance. If an abort request arrives in the meantime,
+// the wait is cut short and the request returned.
+func (c *generatorControl) wait(abort chan chan *generatorStats, written int, busy time.Duration) chan *generatorStats {
+	if c == nil {
+		return nil
+	}
+	for {
+		c.lock.Lock()
+		var (
+			paused = c.paused
+			resume = c.resume
+			delay  time.Duration
+		)
+		if c.rate > 0 {
+			delay = time.Duration(float64(written)/float64(c.rate)*float64(time.Second)) - busy
+		}
+		if c.cpuShare < 1 {
+			if idle := time.Duration(float64(busy) * (1 - c.cpuShare) / c.cpuShare); idle > delay {
+				delay = idle
+			}
+		}
+		c.lock.Unlock()
+
+		if !paused && delay <= 0 {
+			return nil
+		}
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if !paused {
+			timer = time.NewTimer(delay)
+			timeout = timer.C
+		}
+		select {
+		case req := <-abort:
+			if timer != nil {
+				timer.Stop()
+			}
+			return req
+
+		case <-resume:
+			// Settings changed, reevaluate them
+			if timer != nil {
+				timer.Stop()
+			}
+
+		case <-timeout:
+			return nil
+		}
+	}
+}
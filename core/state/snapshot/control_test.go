@@ -0,0 +1,93 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that a paused generator blocks until it's resumed or aborted.
+func TestGeneratorControlPause(t *testing.T) {
+	var (
+		control = newGeneratorControl()
+		abort   = make(chan chan *generatorStats)
+		done    = make(chan chan *generatorStats)
+	)
+	control.setPaused(true)
+	go func() { done <- control.wait(abort, 0, 0) }()
+
+	select {
+	case <-done:
+		t.Fatal("paused generator was not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	control.setPaused(false)
+	select {
+	case req := <-done:
+		if req != nil {
+			t.Fatal("resumed generator returned abort request")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("resumed generator still blocked")
+	}
+	// Pause again and ensure an abort request cuts the wait short
+	control.setPaused(true)
+	go func() { done <- control.wait(abort, 0, 0) }()
+
+	req := make(chan *generatorStats)
+	abort <- req
+	if got := <-done; got != req {
+		t.Fatal("abort request not returned from paused generator")
+	}
+}
+
+// Tests that the generator is delayed according to its IO rate and CPU share.
+func TestGeneratorControlLimits(t *testing.T) {
+	control := newGeneratorControl()
+	if err := control.setLimits(0, 0); err != errInvalidCPUShare {
+		t.Fatalf("zero cpu share error mismatch: have %v, want %v", err, errInvalidCPUShare)
+	}
+	if err := control.setLimits(0, 1.5); err != errInvalidCPUShare {
+		t.Fatalf("excess cpu share error mismatch: have %v, want %v", err, errInvalidCPUShare)
+	}
+	tests := []struct {
+		rate     uint64
+		cpuShare float64
+		written  int
+		busy     time.Duration
+		min      time.Duration
+	}{
+		{0, 1, 1000, 0, 0},                                          // Unlimited
+		{10000, 1, 1000, 0, 100 * time.Millisecond},                 // Rate limited
+		{10000, 1, 1000, 100 * time.Millisecond, 0},                 // Rate already honoured
+		{0, 0.5, 0, 100 * time.Millisecond, 100 * time.Millisecond}, // CPU share limited
+	}
+	for i, tt := range tests {
+		if err := control.setLimits(tt.rate, tt.cpuShare); err != nil {
+			t.Fatalf("test %d: failed to set limits: %v", i, err)
+		}
+		start := time.Now()
+		control.wait(nil, tt.written, tt.busy)
+		if elapsed := time.Since(start); elapsed < tt.min {
+			t.Errorf("test %d: wait too short: have %v, want at least %v", i, elapsed, tt.min)
+		}
+	}
+	if progress := control.status(); progress.RateLimit != 0 || progress.CPUShare != 0.5 {
+		t.Errorf("limits mismatch: have %d/%v, want 0/0.5", progress.RateLimit, progress.CPUShare)
+	}
+}
@@ -94,7 +94,7 @@ func (gs *generatorStats) Log(msg string, root common.Hash, marker []byte) {
 // generateSnapshot regenerates a brand new snapshot based on an existing state
 // database and head block asynchronously. The snapshot is returned immediately
 // and generation is continued in the background until done.
-func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, wiper chan struct{}) *diskLayer {
+func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, wiper chan struct{}, control *generatorControl) *diskLayer {
 	// Wipe any previously existing snapshot from the database if no wiper is
 	// currently in progress.
 	if wiper == nil {
@@ -119,6 +119,7 @@ func generateSnapshot(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache i
 		genMarker:  genMarker,
 		genPending: make(chan struct{}),
 		genAbort:   make(chan chan *generatorStats),
+		genControl: control,
 	}
 	go base.generate(stats)
 	log.Debug("Start snapshot generation", "root", root)
@@ -199,7 +200,7 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 	batch := dl.diskdb.NewBatch()
 
 	// Iterate from the previous marker and continue generating the state snapshot
-	logged := time.Now()
+	logged, flushed := time.Now(), time.Now()
 	for accIt.Next() {
 		// Retrieve the current account and flatten it into the internal format
 		accountHash := common.BytesToHash(accIt.Key)
@@ -234,12 +235,20 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 				marker := accountHash[:]
 				journalProgress(batch, marker, stats)
 
+				written := batch.ValueSize()
 				batch.Write()
 				batch.Reset()
 
 				dl.lock.Lock()
 				dl.genMarker = marker
 				dl.lock.Unlock()
+
+				// Report the progress and yield to the throttling if not aborting
+				dl.genControl.report(stats, marker)
+				if abort == nil {
+					abort = dl.genControl.wait(dl.genAbort, written, time.Since(flushed))
+					flushed = time.Now()
+				}
 			}
 			if abort != nil {
 				stats.Log("Aborting state snapshot generation", dl.root, accountHash[:])
@@ -279,12 +288,20 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 						marker := append(accountHash[:], storeIt.Key...)
 						journalProgress(batch, marker, stats)
 
+						written := batch.ValueSize()
 						batch.Write()
 						batch.Reset()
 
 						dl.lock.Lock()
 						dl.genMarker = marker
 						dl.lock.Unlock()
+
+						// Report the progress and yield to the throttling if not aborting
+						dl.genControl.report(stats, marker)
+						if abort == nil {
+							abort = dl.genControl.wait(dl.genAbort, written, time.Since(flushed))
+							flushed = time.Now()
+						}
 					}
 					if abort != nil {
 						stats.Log("Aborting state snapshot generation", dl.root, append(accountHash[:], storeIt.Key...))
@@ -322,6 +339,7 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 	// generator anyway to mark the snapshot is complete.
 	journalProgress(batch, nil, stats)
 	batch.Write()
+	dl.genControl.report(stats, nil)
 
 	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
 		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
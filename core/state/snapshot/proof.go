@@ -0,0 +1,242 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+)
+
+// proofList is a list of the trie nodes of a Merkle proof.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// Prover creates Merkle proofs of a state maintained by the snapshot tree whose
+// trie nodes aren't available any more. The tries of the state are rebuilt in
+// memory, applying the changes of the diff layers to the tries of the disk layer,
+// which only need to be retained for the disk layer root.
+//
+// A prover is not safe for concurrent use.
+type Prover struct {
+	triedb   *trie.Database
+	base     *trie.Trie                             // Account trie of the disk layer
+	accounts *trie.Trie                             // Account trie of the proven state
+	storages map[common.Hash]*trie.Trie             // Storage tries of the proven state, rebuilt on demand
+	destruct map[common.Hash]struct{}               // Accounts destructed since the disk layer
+	storage  map[common.Hash]map[common.Hash][]byte // Storage slots changed since the disk layer
+}
+
+// Prover returns a prover of the state with the given root, rebuilding its
+// account trie from the tries of the disk layer in the given database.
+func (t *Tree) Prover(root common.Hash, triedb *trie.Database) (*Prover, error) {
+	t.lock.RLock()
+	snap := t.layers[root]
+	t.lock.RUnlock()
+	if snap == nil {
+		return nil, fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	// Collect the diff layers down to the disk layer, the oldest first
+	var diffs []*diffLayer
+	for {
+		diff, ok := snap.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append([]*diffLayer{diff}, diffs...)
+		snap = diff.Parent()
+	}
+	// Merge the changes of the diff layers, later ones overriding earlier ones
+	var (
+		accounts = make(map[common.Hash][]byte)
+		prover   = &Prover{
+			triedb:   triedb,
+			storages: make(map[common.Hash]*trie.Trie),
+			destruct: make(map[common.Hash]struct{}),
+			storage:  make(map[common.Hash]map[common.Hash][]byte),
+		}
+	)
+	for _, diff := range diffs {
+		diff.lock.RLock()
+		for hash := range diff.destructSet {
+			prover.destruct[hash] = struct{}{}
+			accounts[hash] = nil
+			delete(prover.storage, hash)
+		}
+		for hash, data := range diff.accountData {
+			accounts[hash] = data
+		}
+		for hash, slots := range diff.storageData {
+			merged := prover.storage[hash]
+			if merged == nil {
+				merged = make(map[common.Hash][]byte)
+				prover.storage[hash] = merged
+			}
+			for slot, data := range slots {
+				merged[slot] = data
+			}
+		}
+		diff.lock.RUnlock()
+	}
+	if snap.Stale() {
+		return nil, ErrSnapshotStale
+	}
+	for _, diff := range diffs {
+		if diff.Stale() {
+			return nil, ErrSnapshotStale
+		}
+	}
+	// Apply the account changes to the account trie of the disk layer
+	var err error
+	if prover.base, err = trie.New(snap.Root(), triedb); err != nil {
+		return nil, err
+	}
+	if prover.accounts, err = trie.New(snap.Root(), triedb); err != nil {
+		return nil, err
+	}
+	for hash, data := range accounts {
+		if len(data) == 0 {
+			err = prover.accounts.TryDelete(hash[:])
+		} else {
+			var full []byte
+			if full, err = FullAccountRLP(data); err == nil {
+				err = prover.accounts.TryUpdate(hash[:], full)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if have := prover.accounts.Hash(); have != root {
+		return nil, fmt.Errorf("rebuilt state root mismatch: have %#x, want %#x", have, root)
+	}
+	return prover, nil
+}
+
+// AccountProof returns the account with the given address, or nil if it doesn't
+// exist, along with the Merkle proof of it.
+func (p *Prover) AccountProof(address common.Address) (*Account, [][]byte, error) {
+	hash := crypto.Keccak256Hash(address.Bytes())
+
+	account, err := trieAccount(p.accounts, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	var proof proofList
+	if err := p.accounts.Prove(hash[:], 0, &proof); err != nil {
+		return nil, nil, err
+	}
+	return account, proof, nil
+}
+
+// StorageProof returns the value of the storage slot of the account with the
+// given address, along with the Merkle proof of it. The account must exist.
+func (p *Prover) StorageProof(address common.Address, key common.Hash) (common.Hash, [][]byte, error) {
+	storage, err := p.storageTrie(crypto.Keccak256Hash(address.Bytes()))
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	slot := crypto.Keccak256Hash(key[:])
+
+	var value common.Hash
+	enc, err := storage.TryGet(slot[:])
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if len(enc) > 0 {
+		_, content, _, err := rlp.Split(enc)
+		if err != nil {
+			return common.Hash{}, nil, err
+		}
+		value.SetBytes(content)
+	}
+	var proof proofList
+	if err := storage.Prove(slot[:], 0, &proof); err != nil {
+		return common.Hash{}, nil, err
+	}
+	return value, proof, nil
+}
+
+// storageTrie rebuilds the storage trie of an account in the proven state,
+// applying the slot changes to its storage trie in the disk layer.
+func (p *Prover) storageTrie(hash common.Hash) (*trie.Trie, error) {
+	if storage := p.storages[hash]; storage != nil {
+		return storage, nil
+	}
+	account, err := trieAccount(p.accounts, hash)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %#x missing", hash)
+	}
+	// Destructed accounts start over from empty storage
+	base := emptyRoot
+	if _, destructed := p.destruct[hash]; !destructed {
+		original, err := trieAccount(p.base, hash)
+		if err != nil {
+			return nil, err
+		}
+		if original != nil {
+			base = common.BytesToHash(original.Root)
+		}
+	}
+	storage, err := trie.New(base, p.triedb)
+	if err != nil {
+		return nil, err
+	}
+	for slot, data := range p.storage[hash] {
+		if len(data) == 0 {
+			err = storage.TryDelete(slot[:])
+		} else {
+			err = storage.TryUpdate(slot[:], data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if have, want := storage.Hash(), common.BytesToHash(account.Root); have != want {
+		return nil, fmt.Errorf("rebuilt storage root mismatch of account %#x: have %#x, want %#x", hash, have, want)
+	}
+	p.storages[hash] = storage
+	return storage, nil
+}
+
+// trieAccount reads the account with the given hash from an account trie, or nil
+// if it doesn't exist.
+func trieAccount(tr *trie.Trie, hash common.Hash) (*Account, error) {
+	enc, err := tr.TryGet(hash[:])
+	if err != nil || len(enc) == 0 {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(enc, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
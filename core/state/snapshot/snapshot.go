@@ -167,6 +167,8 @@ type Tree struct {
 	cache  int                      // Megabytes permitted to use for read caches
 	layers map[common.Hash]snapshot // Collection of all known layers
 	lock   sync.RWMutex
+
+	control *generatorControl // Operator controls over the background generation
 }
 
 // New attempts to load an already existing snapshot from a persistent key-value
@@ -181,16 +183,17 @@ type Tree struct {
 func New(diskdb ethdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, async bool, rebuild bool, recovery bool) (*Tree, error) {
 	// Create a new, empty snapshot tree
 	snap := &Tree{
-		diskdb: diskdb,
-		triedb: triedb,
-		cache:  cache,
-		layers: make(map[common.Hash]snapshot),
+		diskdb:  diskdb,
+		triedb:  triedb,
+		cache:   cache,
+		layers:  make(map[common.Hash]snapshot),
+		control: newGeneratorControl(),
 	}
 	if !async {
 		defer snap.waitBuild()
 	}
 	// Attempt to load a previously persisted snapshot and rebuild one if failed
-	head, err := loadSnapshot(diskdb, triedb, cache, root, recovery)
+	head, err := loadSnapshot(diskdb, triedb, cache, root, recovery, snap.control)
 	if err != nil {
 		if rebuild {
 			log.Warn("Failed to load snapshot, regenerating", "err", err)
@@ -558,6 +561,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	if base.genMarker != nil && base.genAbort != nil {
 		res.genMarker = base.genMarker
 		res.genAbort = make(chan chan *generatorStats)
+		res.genControl = base.genControl
 		go res.generate(stats)
 	}
 	return res
@@ -672,7 +676,7 @@ func (t *Tree) Rebuild(root common.Hash) {
 	// generator will run a wiper first if there's not one running right now.
 	log.Info("Rebuilding state snapshot")
 	t.layers = map[common.Hash]snapshot{
-		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root, wiper),
+		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root, wiper, t.control),
 	}
 }
 
@@ -734,6 +738,37 @@ func (t *Tree) Verify(root common.Hash) error {
 	return nil
 }
 
+// PauseGeneration suspends the background snapshot generation at its next flush
+// point, until it's resumed. Snapshot layers flattened into the disk layer in
+// the meantime restart the generator in the paused state.
+func (t *Tree) PauseGeneration() {
+	t.control.setPaused(true)
+}
+
+// ResumeGeneration continues a paused background snapshot generation.
+func (t *Tree) ResumeGeneration() {
+	t.control.setPaused(false)
+}
+
+// SetGenerationLimits throttles the background snapshot generation to write at
+// most rate bytes per second (zero meaning unlimited) and to be busy at most the
+// given fraction of the time.
+func (t *Tree) SetGenerationLimits(rate uint64, cpuShare float64) error {
+	return t.control.setLimits(rate, cpuShare)
+}
+
+// GenerationProgress returns the progress of the background snapshot generation
+// along with its current throttling settings.
+func (t *Tree) GenerationProgress() (*GeneratorProgress, error) {
+	generating, err := t.generating()
+	if err != nil {
+		return nil, err
+	}
+	progress := t.control.status()
+	progress.Generating = generating
+	return progress, nil
+}
+
 // disklayer is an internal helper function to return the disk layer.
 // The lock of snapTree is assumed to be held already.
 func (t *Tree) disklayer() *diskLayer {
@@ -183,7 +183,7 @@ func (s *stateObject) GetState(db Database, key common.Hash) common.Hash {
 		return s.fakeStorage[key]
 	}
 	// If we have a dirty value for this state entry, return it
-	value, dirty := s.dirtyStorage[key]
+	value, dirty := s.dirtyState(key)
 	if dirty {
 		return value
 	}
@@ -191,6 +191,17 @@ func (s *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	return s.GetCommittedState(db, key)
 }
 
+// dirtyState retrieves the value a storage slot was modified to during the
+// current transaction, if any.
+func (s *stateObject) dirtyState(key common.Hash) (common.Hash, bool) {
+	// Writes done within a snapshot are held by the journal until finalisation
+	if value, dirty := s.db.journal.getState(s, key); dirty {
+		return value, true
+	}
+	value, dirty := s.dirtyStorage[key]
+	return value, dirty
+}
+
 // GetCommittedState retrieves a value from the committed account storage trie.
 func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Hash {
 	// If the fake storage is set, only lookup the state here(in the debugging mode)
@@ -204,6 +215,9 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 	if value, cached := s.originStorage[key]; cached {
 		return value
 	}
+	if s.db.witness != nil {
+		s.db.witness.addSlot(s.address, key)
+	}
 	// If no live objects are available, attempt to use snapshots
 	var (
 		enc   []byte
@@ -276,7 +290,11 @@ func (s *stateObject) SetState(db Database, key, value common.Hash) {
 	if prev == value {
 		return
 	}
-	// New value is different, update and journal the change
+	// New value is different, record it in the current snapshot's diff layer,
+	// or update and journal the change if no snapshot is open
+	if s.db.journal.setState(s, key, value) {
+		return
+	}
 	s.db.journal.append(storageChange{
 		account:  &s.address,
 		key:      key,
@@ -354,6 +372,9 @@ func (s *stateObject) updateTrie(db Database) Trie {
 		var v []byte
 		if (value == common.Hash{}) {
 			s.setError(tr.TryDelete(key[:]))
+			if s.db.witness != nil {
+				s.db.witness.deleteSlot(s.address, key)
+			}
 		} else {
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ = rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
@@ -462,6 +483,7 @@ func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.dirtyStorage = s.dirtyStorage.Copy()
 	stateObject.originStorage = s.originStorage.Copy()
 	stateObject.pendingStorage = s.pendingStorage.Copy()
+	s.db.journal.copyStates(s, stateObject) // Writes done within snapshots are only held by the journal
 	stateObject.suicided = s.suicided
 	stateObject.dirtyCode = s.dirtyCode
 	stateObject.deleted = s.deleted
@@ -485,6 +507,9 @@ func (s *stateObject) Code(db Database) []byte {
 	if bytes.Equal(s.CodeHash(), emptyCodeHash) {
 		return nil
 	}
+	if s.db.witness != nil {
+		s.db.witness.addCode(s.addrHash, s.CodeHash())
+	}
 	code, err := db.ContractCode(s.addrHash, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.setError(fmt.Errorf("can't load code hash %x: %v", s.CodeHash(), err))
@@ -503,6 +528,9 @@ func (s *stateObject) CodeSize(db Database) int {
 	if bytes.Equal(s.CodeHash(), emptyCodeHash) {
 		return 0
 	}
+	if s.db.witness != nil {
+		s.db.witness.addCode(s.addrHash, s.CodeHash())
+	}
 	size, err := db.ContractCodeSize(s.addrHash, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.setError(fmt.Errorf("can't load code size %x: %v", s.CodeHash(), err))
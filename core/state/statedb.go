@@ -21,7 +21,6 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"sort"
 	"time"
 
 	"github.com/acent/go-acent/common"
@@ -35,11 +34,6 @@ import (
 	"github.com/acent/go-acent/trie"
 )
 
-type revision struct {
-	id           int
-	journalIndex int
-}
-
 var (
 	// emptyRoot is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
@@ -99,12 +93,18 @@ type StateDB struct {
 	// Per-transaction access list
 	accessList *accessList
 
+	// Optional collector of all state accessed, for generating stateless witnesses
+	witness *Witness
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
-	validRevisions []revision
 	nextRevisionId int
 
+	// Checkpoints of the entire state, spanning transactions unlike the journal
+	checkpoints      map[int]*checkpoint
+	nextCheckpointId int
+
 	// Measurements gathered during execution for debugging purposes
 	AccountReads         time.Duration
 	AccountHashes        time.Duration
@@ -182,6 +182,19 @@ func (s *StateDB) Error() error {
 	return s.dbErr
 }
 
+// StartWitness starts collecting all the state accessed from now on, relative
+// to the pre-state root the StateDB was opened at. Any state already loaded is
+// not retroactively collected, so the witness should be started before use.
+func (s *StateDB) StartWitness() *Witness {
+	s.witness = NewWitness(s.originalRoot)
+	return s.witness
+}
+
+// Witness returns the state access collector, if any is active.
+func (s *StateDB) Witness() *Witness {
+	return s.witness
+}
+
 func (s *StateDB) AddLog(log *types.Log) {
 	s.journal.append(addLogChange{txhash: s.thash})
 
@@ -358,6 +371,13 @@ func (s *StateDB) Database() Database {
 	return s.db
 }
 
+// AccountTrie returns the account trie of the state, including the changes
+// hashed into it so far. The trie is not copied, as a copy would lose the
+// preimages of the uncommitted keys, so it must not be modified.
+func (s *StateDB) AccountTrie() Trie {
+	return s.trie
+}
+
 // StorageTrie returns the storage trie of an account.
 // The return value is a copy and is nil for non-existent accounts.
 func (s *StateDB) StorageTrie(addr common.Address) Trie {
@@ -497,6 +517,9 @@ func (s *StateDB) deleteStateObject(obj *stateObject) {
 	if err := s.trie.TryDelete(addr[:]); err != nil {
 		s.setError(fmt.Errorf("deleteStateObject (%x) error: %v", addr[:], err))
 	}
+	if s.witness != nil {
+		s.witness.deleteAccount(addr)
+	}
 }
 
 // getStateObject retrieves a state object given by the address, returning nil if
@@ -518,6 +541,9 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	if obj := s.stateObjects[addr]; obj != nil {
 		return obj
 	}
+	if s.witness != nil {
+		s.witness.addAccount(addr)
+	}
 	// If no live objects are available, attempt to use snapshots
 	var (
 		data *Account
@@ -636,7 +662,7 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 
 	for it.Next() {
 		key := common.BytesToHash(db.trie.GetKey(it.Key))
-		if value, dirty := so.dirtyStorage[key]; dirty {
+		if value, dirty := so.dirtyState(key); dirty {
 			if !cb(key, value) {
 				return nil
 			}
@@ -659,20 +685,28 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 // Copy creates a deep, independent copy of the state.
 // Snapshots of the copied state cannot be applied to the copy.
 func (s *StateDB) Copy() *StateDB {
+	state := new(StateDB)
+	s.copyInto(state)
+	return state
+}
+
+// copyInto overwrites the contents of the given state with a deep copy of s. The
+// fields not related to the content of the state, like the revision ids and the
+// measurements, are left untouched.
+func (s *StateDB) copyInto(state *StateDB) {
 	// Copy all the basic fields, initialize the memory ones
-	state := &StateDB{
-		db:                  s.db,
-		trie:                s.db.CopyTrie(s.trie),
-		stateObjects:        make(map[common.Address]*stateObject, len(s.journal.dirties)),
-		stateObjectsPending: make(map[common.Address]struct{}, len(s.stateObjectsPending)),
-		stateObjectsDirty:   make(map[common.Address]struct{}, len(s.journal.dirties)),
-		refund:              s.refund,
-		logs:                make(map[common.Hash][]*types.Log, len(s.logs)),
-		logSize:             s.logSize,
-		preimages:           make(map[common.Hash][]byte, len(s.preimages)),
-		journal:             newJournal(),
-		hasher:              crypto.NewKeccakState(),
-	}
+	state.db = s.db
+	state.trie = s.db.CopyTrie(s.trie)
+	state.stateObjects = make(map[common.Address]*stateObject, len(s.journal.dirties))
+	state.stateObjectsPending = make(map[common.Address]struct{}, len(s.stateObjectsPending))
+	state.stateObjectsDirty = make(map[common.Address]struct{}, len(s.journal.dirties))
+	state.refund = s.refund
+	state.logs = make(map[common.Hash][]*types.Log, len(s.logs))
+	state.logSize = s.logSize
+	state.preimages = make(map[common.Hash][]byte, len(s.preimages))
+	state.journal = newJournal()
+	state.hasher = crypto.NewKeccakState()
+
 	// Copy the dirty states, logs, and preimages
 	for addr := range s.journal.dirties {
 		// As documented [here](https://github.com/acent/go-acent/pull/16485#issuecomment-380438527),
@@ -689,6 +723,21 @@ func (s *StateDB) Copy() *StateDB {
 			state.stateObjectsPending[addr] = struct{}{} // Mark the copy pending to force external (account) commits
 		}
 	}
+	// Storage writes done within snapshots are only held by the journal and not
+	// tracked as dirty yet, copy the objects they were made to (deepCopy applies
+	// the writes, unless the objects were replaced since).
+	s.journal.forEachState(func(obj *stateObject, slot, value common.Hash) {
+		current := s.stateObjects[obj.address]
+		if current == nil {
+			return
+		}
+		if _, exist := state.stateObjects[obj.address]; !exist {
+			state.stateObjects[obj.address] = current.deepCopy(state)
+
+			state.stateObjectsDirty[obj.address] = struct{}{}
+			state.stateObjectsPending[obj.address] = struct{}{}
+		}
+	})
 	// Above, we don't copy the actual journal. This means that if the copy is copied, the
 	// loop above will be a no-op, since the copy's journal is empty.
 	// Thus, here we iterate over stateObjects, to enable copies of copies
@@ -753,31 +802,19 @@ func (s *StateDB) Copy() *StateDB {
 			state.snapStorage[k] = temp
 		}
 	}
-	return state
 }
 
 // Snapshot returns an identifier for the current revision of the state.
 func (s *StateDB) Snapshot() int {
 	id := s.nextRevisionId
 	s.nextRevisionId++
-	s.validRevisions = append(s.validRevisions, revision{id, s.journal.length()})
+	s.journal.snapshot(id)
 	return id
 }
 
 // RevertToSnapshot reverts all state changes made since the given revision.
 func (s *StateDB) RevertToSnapshot(revid int) {
-	// Find the snapshot in the stack of valid snapshots.
-	idx := sort.Search(len(s.validRevisions), func(i int) bool {
-		return s.validRevisions[i].id >= revid
-	})
-	if idx == len(s.validRevisions) || s.validRevisions[idx].id != revid {
-		panic(fmt.Errorf("revision id %v cannot be reverted", revid))
-	}
-	snapshot := s.validRevisions[idx].journalIndex
-
-	// Replay the journal to undo changes and remove invalidated snapshots
-	s.journal.revert(s, snapshot)
-	s.validRevisions = s.validRevisions[:idx]
+	s.journal.revertToSnapshot(s, revid)
 }
 
 // GetRefund returns the current value of the refund counter.
@@ -789,6 +826,9 @@ func (s *StateDB) GetRefund() uint64 {
 // the journal as well as the refunds. Finalise, however, will not push any updates
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
 func (s *StateDB) Finalise(deleteEmptyObjects bool) {
+	// Apply the storage writes still held in snapshot layers to their objects
+	s.journal.flatten()
+
 	addressesToPrefetch := make([][]byte, 0, len(s.journal.dirties))
 	for addr := range s.journal.dirties {
 		obj, exist := s.stateObjects[addr]
@@ -906,7 +946,7 @@ func (s *StateDB) clearJournalAndRefund() {
 		s.journal = newJournal()
 		s.refund = 0
 	}
-	s.validRevisions = s.validRevisions[:0] // Snapshots can be created without journal entires
+	s.journal.resetLayers() // Snapshots can be created without journal entires
 }
 
 // Commit writes the state to the underlying in-memory trie database.
@@ -32,6 +32,7 @@ import (
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/rlp"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -695,6 +696,187 @@ func TestDeleteCreateRevert(t *testing.T) {
 	}
 }
 
+// Tests that repeated modifications of the same fields within a snapshot are
+// journalled only once, that storage writes are kept out of the journal, and
+// that reverting nested snapshots still restores the correct values.
+func TestJournalLayerDedup(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	addr := toAddr([]byte("loop"))
+	key := common.HexToHash("0x01")
+
+	state.SetBalance(addr, big.NewInt(1))
+	state.SetState(addr, key, common.HexToHash("0x01"))
+
+	outer := state.Snapshot()
+	start := state.journal.length()
+	for i := 2; i < 1000; i++ {
+		state.SetState(addr, key, common.BigToHash(big.NewInt(int64(i))))
+		state.AddBalance(addr, big.NewInt(1))
+	}
+	if entries := state.journal.length() - start; entries != 1 {
+		t.Fatalf("journal entries mismatch: have %d, want %d", entries, 1)
+	}
+	inner := state.Snapshot()
+	state.SetState(addr, key, common.HexToHash("0xff"))
+	state.SetState(addr, key, common.HexToHash("0xfe"))
+
+	state.RevertToSnapshot(inner)
+	if have, want := state.GetState(addr, key), common.BigToHash(big.NewInt(999)); have != want {
+		t.Fatalf("inner revert: storage mismatch: have %x, want %x", have, want)
+	}
+	state.RevertToSnapshot(outer)
+	if have, want := state.GetState(addr, key), common.HexToHash("0x01"); have != want {
+		t.Fatalf("outer revert: storage mismatch: have %x, want %x", have, want)
+	}
+	if have := state.GetBalance(addr); have.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("outer revert: balance mismatch: have %v, want %v", have, 1)
+	}
+}
+
+// Tests that storage writes held in snapshot layers survive copies and are
+// flattened into the state when the transaction is finalised, and that they
+// don't leak into an account recreated within the same layer.
+func TestJournalLayerFlatten(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	var (
+		addr     = toAddr([]byte("flatten"))
+		recreate = toAddr([]byte("recreate"))
+		key      = common.HexToHash("0x01")
+		value    = common.HexToHash("0x02")
+	)
+	state.SetBalance(addr, big.NewInt(1))
+	state.SetBalance(recreate, big.NewInt(1))
+
+	state.Snapshot()
+	state.SetState(addr, key, value)
+	state.SetState(recreate, key, value)
+	state.CreateAccount(recreate)
+
+	if have := state.GetState(recreate, key); have != (common.Hash{}) {
+		t.Fatalf("recreated account storage mismatch: have %x, want %x", have, common.Hash{})
+	}
+	copy := state.Copy()
+	if have := copy.GetState(addr, key); have != value {
+		t.Fatalf("copied storage mismatch: have %x, want %x", have, value)
+	}
+	// Storage tries must be derived with the writes held in the layers too
+	enc, err := state.StorageTrie(addr).TryGet(key.Bytes())
+	if err != nil {
+		t.Fatalf("failed to read storage trie: %v", err)
+	}
+	if _, content, _, _ := rlp.Split(enc); common.BytesToHash(content) != value {
+		t.Fatalf("storage trie mismatch: have %x, want %x", content, value)
+	}
+	state.Finalise(true)
+	if len(state.journal.storage) != 0 {
+		t.Fatalf("storage diffs not flattened: %d left", len(state.journal.storage))
+	}
+	for i, s := range []*StateDB{state, copy} {
+		if have := s.GetState(addr, key); have != value {
+			t.Fatalf("state %d: storage mismatch: have %x, want %x", i, have, value)
+		}
+		if have := s.GetState(recreate, key); have != (common.Hash{}) {
+			t.Fatalf("state %d: recreated account storage mismatch: have %x, want %x", i, have, common.Hash{})
+		}
+	}
+	if root, want := state.IntermediateRoot(true), copy.IntermediateRoot(true); root != want {
+		t.Fatalf("root mismatch: have %x, want %x", root, want)
+	}
+}
+
+// BenchmarkSnapshotRevert measures reverting a deep call tree in which every
+// frame rewrites a set of storage slots and the balance of the same contract,
+// as done by simulations of reverted arbitrage transactions.
+func BenchmarkSnapshotRevert(b *testing.B) {
+	for _, depth := range []int{1, 16, 256} {
+		for _, writes := range []int{1, 64} {
+			b.Run(fmt.Sprintf("depth-%d/writes-%d", depth, writes), func(b *testing.B) {
+				benchmarkSnapshotRevert(b, depth, writes)
+			})
+		}
+	}
+}
+
+func benchmarkSnapshotRevert(b *testing.B, depth, writes int) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	addr := toAddr([]byte("arbitrage"))
+	state.SetBalance(addr, big.NewInt(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root := state.Snapshot()
+		for d := 0; d < depth; d++ {
+			state.Snapshot()
+			for w := 0; w < writes; w++ {
+				state.SetState(addr, common.BigToHash(big.NewInt(int64(w))), common.BigToHash(big.NewInt(int64(i*depth+d+1))))
+			}
+			state.AddBalance(addr, big.NewInt(1))
+		}
+		state.RevertToSnapshot(root)
+	}
+}
+
+// Tests that checkpoints restore the state across finalised transactions, and
+// can be restored repeatedly.
+func TestCheckpoint(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	addr := toAddr([]byte("speculative"))
+	key := common.HexToHash("0x01")
+
+	state.SetBalance(addr, big.NewInt(1))
+	state.SetState(addr, key, common.HexToHash("0x01"))
+	state.Finalise(true)
+	root := state.IntermediateRoot(true)
+
+	cp := state.Checkpoint()
+	for i := 0; i < 2; i++ {
+		// Execute two transactions on top of the checkpoint
+		for j := 0; j < 2; j++ {
+			state.Prepare(common.Hash{byte(j + 1)}, common.Hash{}, j)
+			state.AddBalance(addr, big.NewInt(1))
+			state.SetState(addr, key, common.BigToHash(big.NewInt(int64(j+2))))
+			state.AddLog(&types.Log{Address: addr})
+			state.AddRefund(100)
+			state.Finalise(true)
+		}
+		state.IntermediateRoot(true)
+
+		// Restoring must undo both, including their logs and refunds
+		state.RestoreCheckpoint(cp)
+		if have := state.GetBalance(addr); have.Cmp(big.NewInt(1)) != 0 {
+			t.Fatalf("round %d: balance mismatch: have %v, want %v", i, have, 1)
+		}
+		if have, want := state.GetState(addr, key), common.HexToHash("0x01"); have != want {
+			t.Fatalf("round %d: storage mismatch: have %x, want %x", i, have, want)
+		}
+		if have, want := state.GetCommittedState(addr, key), common.HexToHash("0x01"); have != want {
+			t.Fatalf("round %d: committed storage mismatch: have %x, want %x", i, have, want)
+		}
+		if logs := state.Logs(); len(logs) != 0 {
+			t.Fatalf("round %d: logs retained: %d", i, len(logs))
+		}
+		if refund := state.GetRefund(); refund != 0 {
+			t.Fatalf("round %d: refund retained: %d", i, refund)
+		}
+		if have := state.IntermediateRoot(true); have != root {
+			t.Fatalf("round %d: root mismatch: have %x, want %x", i, have, root)
+		}
+	}
+	// Discarded checkpoints must not be restorable
+	state.DiscardCheckpoint(cp)
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("discarded checkpoint restored")
+		}
+	}()
+	state.RestoreCheckpoint(cp)
+}
+
 // TestMissingTrieNodes tests that if the StateDB fails to load parts of the trie,
 // the Commit operation fails with an error
 // If we are missing trie nodes, we should not continue writing to the trie
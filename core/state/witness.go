@@ -0,0 +1,276 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb/memorydb"
+	"github.com/acent/go-acent/rlp"
+	"github.com/acent/go-acent/trie"
+)
+
+// Witness collects all the pieces of state accessed while executing on top of
+// a pre-state root: the accounts, the storage slots and the contract codes. It
+// can be turned into a BlockWitness containing every trie node needed to re-run
+// the execution and recompute the post-state root without access to the full
+// state.
+type Witness struct {
+	root            common.Hash                                 // Pre-state root the accesses are relative to
+	accounts        map[common.Address]struct{}                 // Accounts accessed (including non-existent ones)
+	slots           map[common.Address]map[common.Hash]struct{} // Storage slots accessed per account
+	codes           map[common.Hash]common.Hash                 // Contract codes accessed (code hash -> address hash)
+	deletedAccounts map[common.Address]struct{}                 // Accounts deleted from the account trie
+	deletedSlots    map[common.Address]map[common.Hash]struct{} // Storage slots deleted per account
+	lock            sync.Mutex
+}
+
+// NewWitness creates an empty witness collector for the given pre-state root.
+func NewWitness(root common.Hash) *Witness {
+	return &Witness{
+		root:            root,
+		accounts:        make(map[common.Address]struct{}),
+		slots:           make(map[common.Address]map[common.Hash]struct{}),
+		codes:           make(map[common.Hash]common.Hash),
+		deletedAccounts: make(map[common.Address]struct{}),
+		deletedSlots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// Root returns the pre-state root the witness is collected against.
+func (w *Witness) Root() common.Hash {
+	return w.root
+}
+
+// addAccount marks an account as accessed.
+func (w *Witness) addAccount(addr common.Address) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.accounts[addr] = struct{}{}
+}
+
+// addSlot marks a storage slot of an account as accessed.
+func (w *Witness) addSlot(addr common.Address, key common.Hash) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.accounts[addr] = struct{}{}
+	if w.slots[addr] == nil {
+		w.slots[addr] = make(map[common.Hash]struct{})
+	}
+	w.slots[addr][key] = struct{}{}
+}
+
+// addCode marks a contract code as accessed.
+func (w *Witness) addCode(addrHash common.Hash, codeHash []byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.codes[common.BytesToHash(codeHash)] = addrHash
+}
+
+// deleteAccount marks an account as deleted from the account trie.
+func (w *Witness) deleteAccount(addr common.Address) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.accounts[addr] = struct{}{}
+	w.deletedAccounts[addr] = struct{}{}
+}
+
+// deleteSlot marks a storage slot of an account as deleted from its trie.
+func (w *Witness) deleteSlot(addr common.Address, key common.Hash) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.accounts[addr] = struct{}{}
+	if w.slots[addr] == nil {
+		w.slots[addr] = make(map[common.Hash]struct{})
+	}
+	w.slots[addr][key] = struct{}{}
+
+	if w.deletedSlots[addr] == nil {
+		w.deletedSlots[addr] = make(map[common.Hash]struct{})
+	}
+	w.deletedSlots[addr][key] = struct{}{}
+}
+
+// BlockWitness is the compact, self contained proof of all the state accessed
+// during the execution of a block: the set of trie nodes on the paths to every
+// touched account and storage slot in the pre-state, the siblings needed to
+// collapse the branches left behind by deletions, and the touched contract
+// codes. Entries are sorted by hash to keep the encoding deterministic.
+type BlockWitness struct {
+	Root  common.Hash // Pre-state root the nodes are anchored to
+	Nodes [][]byte    // Trie nodes of the account and storage tries
+	Codes [][]byte    // Contract codes
+}
+
+// witnessNodes is a proof database collecting trie nodes keyed by hash.
+type witnessNodes map[common.Hash][]byte
+
+// Put implements ethdb.KeyValueWriter.
+func (n witnessNodes) Put(key []byte, value []byte) error {
+	n[common.BytesToHash(key)] = common.CopyBytes(value)
+	return nil
+}
+
+// Delete implements ethdb.KeyValueWriter.
+func (n witnessNodes) Delete(key []byte) error {
+	delete(n, common.BytesToHash(key))
+	return nil
+}
+
+// Build generates the block witness from the collected accesses by proving
+// every touched account and storage slot against the pre-state, and replaying
+// the deletions to gather the sibling nodes they resolve.
+func (w *Witness) Build(db Database) (*BlockWitness, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	accountTrie, err := db.OpenTrie(w.root)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(witnessNodes)
+	for addr := range w.accounts {
+		// Note, the secure trie's prover operates on hashed keys
+		if err := accountTrie.Prove(crypto.Keccak256(addr.Bytes()), 0, nodes); err != nil {
+			return nil, fmt.Errorf("failed to prove account %x: %v", addr, err)
+		}
+		slots := w.slots[addr]
+		if len(slots) == 0 {
+			continue
+		}
+		// Storage was accessed, prove the slots against the pre-state storage
+		// root (if the account didn't exist, the account proof suffices)
+		enc, err := accountTrie.TryGet(addr.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if len(enc) == 0 {
+			continue
+		}
+		var account Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return nil, err
+		}
+		if account.Root == emptyRoot {
+			continue
+		}
+		storageTrie, err := db.OpenStorageTrie(crypto.Keccak256Hash(addr.Bytes()), account.Root)
+		if err != nil {
+			return nil, err
+		}
+		for key := range slots {
+			if err := storageTrie.Prove(crypto.Keccak256(key.Bytes()), 0, nodes); err != nil {
+				return nil, fmt.Errorf("failed to prove slot %x of account %x: %v", key, addr, err)
+			}
+		}
+		if deleted := w.deletedSlots[addr]; len(deleted) > 0 {
+			keys := make([][]byte, 0, len(deleted))
+			for key := range deleted {
+				keys = append(keys, key.Bytes())
+			}
+			if err := proveDeletions(db, account.Root, keys, nodes); err != nil {
+				return nil, fmt.Errorf("failed to replay slot deletions of account %x: %v", addr, err)
+			}
+		}
+	}
+	if len(w.deletedAccounts) > 0 {
+		keys := make([][]byte, 0, len(w.deletedAccounts))
+		for addr := range w.deletedAccounts {
+			keys = append(keys, addr.Bytes())
+		}
+		if err := proveDeletions(db, w.root, keys, nodes); err != nil {
+			return nil, fmt.Errorf("failed to replay account deletions: %v", err)
+		}
+	}
+	witness := &BlockWitness{Root: w.root}
+	for _, hash := range sortedHashes(nodes) {
+		witness.Nodes = append(witness.Nodes, nodes[hash])
+	}
+	codes := make(map[common.Hash][]byte, len(w.codes))
+	for codeHash, addrHash := range w.codes {
+		code, err := db.ContractCode(addrHash, codeHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load code %x: %v", codeHash, err)
+		}
+		codes[codeHash] = code
+	}
+	for _, hash := range sortedHashes(codes) {
+		witness.Codes = append(witness.Codes, codes[hash])
+	}
+	return witness, nil
+}
+
+// proveDeletions deletes the given keys from a trie rebuilt from the collected
+// nodes, pulling in every node the deletions need from the database. Deleting a
+// key may collapse its parent branch into the remaining child, which was never
+// on an accessed path, yet a verifier needs it to recompute the post-state root.
+func proveDeletions(db Database, root common.Hash, keys [][]byte, nodes witnessNodes) error {
+	diskdb := memorydb.New()
+	for hash, blob := range nodes {
+		diskdb.Put(hash[:], blob)
+	}
+	resolve := func(err error) error {
+		missing, ok := err.(*trie.MissingNodeError)
+		if !ok {
+			return err
+		}
+		blob, err := db.TrieDB().Node(missing.NodeHash)
+		if err != nil {
+			return err
+		}
+		nodes.Put(missing.NodeHash[:], blob)
+		return diskdb.Put(missing.NodeHash[:], blob)
+	}
+	triedb := trie.NewDatabase(diskdb)
+	tr, err := trie.NewSecure(root, triedb)
+	for err != nil {
+		if err = resolve(err); err != nil {
+			return err
+		}
+		tr, err = trie.NewSecure(root, triedb)
+	}
+	for _, key := range keys {
+		for err := tr.TryDelete(key); err != nil; err = tr.TryDelete(key) {
+			if err := resolve(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedHashes returns the keys of a hash keyed blob set in ascending order.
+func sortedHashes(blobs map[common.Hash][]byte) []common.Hash {
+	hashes := make([]common.Hash, 0, len(blobs))
+	for hash := range blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	return hashes
+}
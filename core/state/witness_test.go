@@ -0,0 +1,135 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/crypto"
+)
+
+// Tests that the witness contains every node needed to re-read the accessed
+// state, and nothing related to untouched accounts.
+func TestWitnessBuild(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	state, _ := New(common.Hash{}, db, nil)
+
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.SetBalance(addr, big.NewInt(int64(i)))
+		state.SetState(addr, common.Hash{i}, common.Hash{i})
+	}
+	contract := common.BytesToAddress([]byte{1})
+	state.SetCode(contract, code)
+
+	root, _ := state.Commit(false)
+	state.Database().TrieDB().Commit(root, false, nil)
+
+	// Reopen the state, touch a few items and build the witness
+	state, _ = New(root, db, nil)
+	witness := state.StartWitness()
+
+	state.GetBalance(common.BytesToAddress([]byte{7}))
+	state.GetState(contract, common.Hash{1})
+	state.GetCode(contract)
+	state.GetBalance(common.BytesToAddress([]byte{0xff})) // non-existent
+
+	result, err := witness.Build(db)
+	if err != nil {
+		t.Fatalf("failed to build witness: %v", err)
+	}
+	if result.Root != root {
+		t.Fatalf("root mismatch: have %x, want %x", result.Root, root)
+	}
+	var hasRoot bool
+	for _, node := range result.Nodes {
+		if crypto.Keccak256Hash(node) == root {
+			hasRoot = true
+		}
+	}
+	if !hasRoot {
+		t.Errorf("witness misses the root node")
+	}
+	if len(result.Codes) != 1 || !bytes.Equal(result.Codes[0], code) {
+		t.Errorf("code mismatch: have %x, want [%x]", result.Codes, code)
+	}
+	// Proving all accounts should require strictly more nodes
+	full := NewWitness(root)
+	for i := byte(0); i < 64; i++ {
+		full.addAccount(common.BytesToAddress([]byte{i}))
+	}
+	all, err := full.Build(db)
+	if err != nil {
+		t.Fatalf("failed to build full witness: %v", err)
+	}
+	if len(all.Nodes) <= len(result.Nodes) {
+		t.Errorf("witness not compact: %d nodes, full state %d nodes", len(result.Nodes), len(all.Nodes))
+	}
+}
+
+// Tests that the witness contains the nodes needed to recompute the post-state
+// root after deletions collapse branches of the account and storage tries.
+func TestWitnessDeletions(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	state, _ := New(common.Hash{}, db, nil)
+
+	contract := common.BytesToAddress([]byte{1})
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.SetBalance(addr, big.NewInt(int64(i)+1))
+		state.SetState(contract, common.Hash{i}, common.Hash{i + 1})
+	}
+	root, _ := state.Commit(false)
+	state.Database().TrieDB().Commit(root, false, nil)
+
+	// Delete most accounts and slots, collapsing branches along the way
+	mutate := func(state *StateDB) common.Hash {
+		for i := byte(2); i < 60; i++ {
+			state.Suicide(common.BytesToAddress([]byte{i}))
+			state.SetState(contract, common.Hash{i}, common.Hash{})
+		}
+		return state.IntermediateRoot(true)
+	}
+	state, _ = New(root, db, nil)
+	witness := state.StartWitness()
+	post := mutate(state)
+
+	result, err := witness.Build(db)
+	if err != nil {
+		t.Fatalf("failed to build witness: %v", err)
+	}
+	// Re-execute the changes on top of the witness alone
+	diskdb := rawdb.NewMemoryDatabase()
+	for _, node := range result.Nodes {
+		diskdb.Put(crypto.Keccak256(node), node)
+	}
+	stateless, err := New(root, NewDatabase(diskdb), nil)
+	if err != nil {
+		t.Fatalf("failed to open witness state: %v", err)
+	}
+	if have := mutate(stateless); have != post {
+		t.Errorf("post-state root mismatch: have %x, want %x", have, post)
+	}
+	if err := stateless.Error(); err != nil {
+		t.Errorf("witness misses nodes: %v", err)
+	}
+}
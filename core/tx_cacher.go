@@ -25,6 +25,14 @@ import (
 // senderCacher is a concurrent transaction sender recoverer and cacher.
 var senderCacher = newTxSenderCacher(runtime.NumCPU())
 
+// CacheSenders recovers the senders of a batch of transactions on the shared
+// background threads and caches them into the transactions themselves, so later
+// sender lookups during import are free. There is no validation being done, nor
+// any reaction to invalid signatures.
+func CacheSenders(signer types.Signer, txs []*types.Transaction) {
+	senderCacher.recover(signer, txs)
+}
+
 // txSenderCacherRequest is a request for recovering transaction senders with a
 // specific signature scheme and caching it into the transactions themselves.
 //
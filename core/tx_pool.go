@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/common/prque"
 	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/types"
@@ -83,6 +84,10 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrInvalidPriceBump is returned if the replacement policy is updated with a
+	// price bump below 1%.
+	ErrInvalidPriceBump = errors.New("price bump below 1%")
 )
 
 var (
@@ -127,6 +132,35 @@ const (
 	TxStatusIncluded
 )
 
+// TxDropReason is the reason of a transaction being dropped from the pool.
+type TxDropReason string
+
+const (
+	TxDropStale       TxDropReason = "stale"       // Nonce already used by the chain state
+	TxDropUnpayable   TxDropReason = "unpayable"   // Sender can't afford the cost or gas over the block limit
+	TxDropUnderpriced TxDropReason = "underpriced" // Evicted by or lost to better priced transactions
+	TxDropOverflow    TxDropReason = "overflow"    // Over the account or global pool limits
+	TxDropExpired     TxDropReason = "expired"     // Queued for longer than the pool lifetime or past its conditions
+	TxDropRejected    TxDropReason = "rejected"    // Rejected or released by the lane validator
+)
+
+// TxConditions are the bounds of the blocks a conditional transaction may still be
+// included in. The pool drops the transaction once the next block is past them.
+// Unset bounds are ignored.
+type TxConditions struct {
+	BlockNumberMax *big.Int // Maximum number of the block including the transaction
+	TimestampMax   *uint64  // Maximum timestamp of the block including the transaction
+}
+
+// expired reports whether the block following the given head is past the bounds.
+// Its timestamp is at least the head's + 1.
+func (c *TxConditions) expired(head *types.Header) bool {
+	if c.BlockNumberMax != nil && head.Number.Cmp(c.BlockNumberMax) >= 0 {
+		return true
+	}
+	return c.TimestampMax != nil && head.Time >= *c.TimestampMax
+}
+
 // blockChain provides the state of blockchain and current gas limit to do
 // some pre checks in tx pool and event subscribers.
 type blockChain interface {
@@ -140,6 +174,7 @@ type blockChain interface {
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
 	Locals    []common.Address // Addresses that should be treated by default as local
+	Priority  []common.Address // Addresses whose transactions bypass global limits and are evicted last
 	NoLocals  bool             // Whether local transaction handling should be disabled
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
@@ -147,12 +182,19 @@ type TxPoolConfig struct {
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	// ReplaceByTip accepts replacements paying any higher gas price than the
+	// existing transaction, ignoring the minimum price bump.
+	ReplaceByTip bool
+
 	AccountSlots uint64 // Number of executable transaction slots guaranteed per account
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	LaneSlots        uint64 // Maximum number of transactions in the alternative validation lane
+	LaneAccountSlots uint64 // Maximum number of lane transactions permitted per account
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -170,6 +212,9 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	LaneSlots:        1024,
+	LaneAccountSlots: 16,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -208,6 +253,14 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.LaneSlots < 1 {
+		log.Warn("Sanitizing invalid txpool lane slots", "provided", conf.LaneSlots, "updated", DefaultTxPoolConfig.LaneSlots)
+		conf.LaneSlots = DefaultTxPoolConfig.LaneSlots
+	}
+	if conf.LaneAccountSlots < 1 {
+		log.Warn("Sanitizing invalid txpool lane account slots", "provided", conf.LaneAccountSlots, "updated", DefaultTxPoolConfig.LaneAccountSlots)
+		conf.LaneAccountSlots = DefaultTxPoolConfig.LaneAccountSlots
+	}
 	return conf
 }
 
@@ -224,19 +277,26 @@ type TxPool struct {
 	chain       blockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
+	dropFeed    event.Feed
+	replaceFeed event.Feed
 	scope       event.SubscriptionScope
 	signer      types.Signer
 	mu          sync.RWMutex
 
 	istanbul bool // Fork indicator whether we are in the istanbul stage.
 	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
+	sidecar  bool // Fork indicator whether we are accepting sidecar transactions.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals   *accountSet              // Set of local transaction to exempt from eviction rules
+	priority *accountSet              // Set of operator designated senders to exempt from global limits
+	journal  *txJournal               // Journal of local transaction to back up to disk
+	private  map[common.Hash]struct{} // Transactions submitted privately, never to be propagated
+
+	conditionals map[common.Hash]*TxConditions // Conditions of the transactions submitted conditionally
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -244,6 +304,12 @@ type TxPool struct {
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	laneValidator TxLaneValidator // Hook accepting transactions by custom rules, nil if disabled
+	lane          *txLane         // Transactions accepted by the lane validator
+
+	drops    map[TxDropReason][]*types.Transaction // Dropped transactions to announce once unlocked
+	replaces []ReplaceTxEvent                      // Replaced transactions to announce once unlocked
+
 	chainHeadCh     chan ChainHeadEvent
 	chainHeadSub    event.Subscription
 	reqResetCh      chan *txpoolResetRequest
@@ -274,6 +340,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:           make(map[common.Address]*txList),
 		beats:           make(map[common.Address]time.Time),
 		all:             newTxLookup(),
+		lane:            newTxLane(),
+		private:         make(map[common.Hash]struct{}),
+		conditionals:    make(map[common.Hash]*TxConditions),
 		chainHeadCh:     make(chan ChainHeadEvent, chainHeadChanSize),
 		reqResetCh:      make(chan *txpoolResetRequest),
 		reqPromoteCh:    make(chan *accountSet),
@@ -287,6 +356,11 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		log.Info("Setting new local account", "address", addr)
 		pool.locals.add(addr)
 	}
+	pool.priority = newAccountSet(pool.signer)
+	for _, addr := range config.Priority {
+		log.Info("Setting new priority account", "address", addr)
+		pool.priority.add(addr)
+	}
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
@@ -373,10 +447,12 @@ func (pool *TxPool) loop() {
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true)
 					}
+					pool.queueDropEvent(TxDropExpired, list...)
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
 			pool.mu.Unlock()
+			pool.announceEvents()
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
@@ -412,6 +488,18 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDropTxsEvent registers a subscription of DropTxsEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeDropTxsEvent(ch chan<- DropTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// SubscribeReplaceTxEvent registers a subscription of ReplaceTxEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeReplaceTxEvent(ch chan<- ReplaceTxEvent) event.Subscription {
+	return pool.scope.Track(pool.replaceFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -423,16 +511,88 @@ func (pool *TxPool) GasPrice() *big.Int {
 // SetGasPrice updates the minimum price required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
+	defer pool.announceEvents()
+
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price) {
+	drops := pool.priced.Cap(price)
+	for _, tx := range drops {
 		pool.removeTx(tx.Hash(), false)
 	}
+	pool.queueDropEvent(TxDropUnderpriced, drops...)
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// RegisterMemoryBudget hands the sizing of the pool over to a global memory
+// budget. Transactions are only accepted once the node is in sync, so the pool
+// gets no allowance beyond its configured limits while syncing and grows past
+// them while serving, keeping the configured proportion of executable and
+// queued slots. The configured limits are never shrunk.
+func (pool *TxPool) RegisterMemoryBudget(budget *membudget.Manager) {
+	pool.mu.RLock()
+	slots, queue := pool.config.GlobalSlots, pool.config.GlobalQueue
+	pool.mu.RUnlock()
+
+	budget.Register("txpool", membudget.Weights{Syncing: 0, Serving: 1}, 16*1024*1024, func(allowance uint64) {
+		pool.setSlotLimits(allowance/txSlotSize, slots, queue)
+	})
+}
+
+// setSlotLimits resizes the global slot limits of the pool to the given total,
+// split in the proportion of the given executable and queued slot counts. The
+// given counts are the floor of the limits, smaller totals restore them. Any
+// excess is evicted on the next promotion.
+func (pool *TxPool) setSlotLimits(total, slots, queue uint64) {
+	if total > slots+queue {
+		queue = total * queue / (slots + queue)
+		slots = total - queue
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.config.GlobalSlots == slots && pool.config.GlobalQueue == queue {
+		return
+	}
+	pool.config.GlobalSlots, pool.config.GlobalQueue = slots, queue
+	log.Info("Transaction pool limits updated", "slots", slots, "queue", queue)
+}
+
+// ReplacementPolicy returns the minimum price bump percentage required to replace
+// an already pooled transaction, and whether any higher gas price suffices.
+func (pool *TxPool) ReplacementPolicy() (uint64, bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.config.PriceBump, pool.config.ReplaceByTip
+}
+
+// SetReplacementPolicy updates the rules a transaction must satisfy to replace an
+// already pooled one with the same nonce. Pooled transactions are not affected.
+func (pool *TxPool) SetReplacementPolicy(priceBump uint64, byTip bool) error {
+	if priceBump < 1 {
+		return ErrInvalidPriceBump
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.PriceBump, pool.config.ReplaceByTip = priceBump, byTip
+	log.Info("Transaction pool replacement policy updated", "pricebump", priceBump, "bytip", byTip)
+	return nil
+}
+
+// priceBump returns the price bump percentage replacements must pay, which is
+// zero if any higher gas price suffices.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) priceBump() uint64 {
+	if pool.config.ReplaceByTip {
+		return 0
+	}
+	return pool.config.PriceBump
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.Address) uint64 {
@@ -482,6 +642,22 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the data content of the transaction pool for a single
+// account, returning its pending as well as queued transactions, sorted by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		pending = list.Flatten()
+	}
+	if list := pool.queue[addr]; list != nil {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -493,6 +669,11 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	for addr, list := range pool.pending {
 		pending[addr] = list.Flatten()
 	}
+	for addr := range pool.lane.accounts {
+		if txs := pool.laneExecutables(addr); len(txs) > 0 {
+			pending[addr] = append(pending[addr], txs...)
+		}
+	}
 	return pending, nil
 }
 
@@ -507,19 +688,50 @@ func (pool *TxPool) Locals() []common.Address {
 // local retrieves all currently known local transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
+//
+// Private transactions are left out, they are not journaled so they can't leak
+// to the network after a restart.
 func (pool *TxPool) local() map[common.Address]types.Transactions {
 	txs := make(map[common.Address]types.Transactions)
 	for addr := range pool.locals.accounts {
 		if pending := pool.pending[addr]; pending != nil {
-			txs[addr] = append(txs[addr], pending.Flatten()...)
+			txs[addr] = append(txs[addr], pool.journaled(pending.Flatten())...)
 		}
 		if queued := pool.queue[addr]; queued != nil {
-			txs[addr] = append(txs[addr], queued.Flatten()...)
+			txs[addr] = append(txs[addr], pool.journaled(queued.Flatten())...)
+		}
+		if len(txs[addr]) == 0 {
+			delete(txs, addr)
 		}
 	}
 	return txs
 }
 
+// journaled filters the transactions kept in memory only, the private and the
+// conditional ones, out of the given list.
+func (pool *TxPool) journaled(txs types.Transactions) types.Transactions {
+	if len(pool.private) == 0 && len(pool.conditionals) == 0 {
+		return txs
+	}
+	filtered := txs[:0]
+	for _, tx := range txs {
+		if !pool.memoryOnly(tx.Hash()) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// memoryOnly reports whether the transaction with the given hash must not be
+// journaled, either being private or losing its conditions across restarts.
+func (pool *TxPool) memoryOnly(hash common.Hash) bool {
+	if _, ok := pool.private[hash]; ok {
+		return true
+	}
+	_, ok := pool.conditionals[hash]
+	return ok
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -527,10 +739,20 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !pool.eip2718 && tx.Type() != types.LegacyTxType {
 		return ErrTxTypeNotSupported
 	}
-	// Reject transactions over defined size to prevent DOS attacks
-	if uint64(tx.Size()) > txMaxSize {
+	// Accept sidecar transactions only once their experimental fork activates.
+	if !pool.sidecar && tx.Type() == types.SidecarTxType {
+		return ErrTxTypeNotSupported
+	}
+	// Reject transactions over defined size to prevent DOS attacks, sidecars
+	// have their own limits
+	if uint64(tx.WithoutSidecar().Size()) > txMaxSize {
 		return ErrOversizedData
 	}
+	// Ensure the sidecar matches the blobs the transaction commits to. It's only
+	// validated here, execution never sees it.
+	if err := tx.VerifySidecar(); err != nil {
+		return err
+	}
 	// Transactions can't be negative. This may never happen using RLP decoded
 	// transactions but may occur if you create a transaction using the RPC.
 	if tx.Value().Sign() < 0 {
@@ -576,10 +798,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // whitelisted, preventing any associated transaction from being dropped out of the pool
 // due to pricing constraints.
+//
+// Transactions of priority accounts are validated like remote ones, but are exempt
+// from the global pool limits the same way local ones are.
 func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
-	if pool.all.Get(hash) != nil {
+	if pool.all.Get(hash) != nil || pool.lane.get(hash) != nil {
 		log.Trace("Discarding already known transaction", "hash", hash)
 		knownTxMeter.Mark(1)
 		return false, ErrAlreadyKnown
@@ -588,8 +813,16 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	// the sender is marked as local previously, treat it as the local transaction.
 	isLocal := local || pool.locals.containsTx(tx)
 
-	// If the transaction fails basic validation, discard it
+	// Transactions exempt from the pool limits aren't tracked in the price list, so
+	// they are never evicted to make room for others.
+	exempt := isLocal || pool.priority.containsTx(tx)
+
+	// If the transaction fails basic validation, discard it unless accepted into
+	// the alternative lane by the custom rules
 	if err := pool.validateTx(tx, isLocal); err != nil {
+		if err = pool.addLane(tx, err); err == nil {
+			return false, nil
+		}
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxMeter.Mark(1)
 		return false, err
@@ -597,18 +830,18 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Count()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if !isLocal && pool.priced.Underpriced(tx) {
+		if !exempt && pool.priced.Underpriced(tx) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
 			underpricedTxMeter.Mark(1)
 			return false, ErrUnderpriced
 		}
 		// New transaction is better than our worse ones, make room for it.
-		// If it's a local transaction, forcibly discard all available transactions.
+		// If it's an exempt transaction, forcibly discard all available transactions.
 		// Otherwise if we can't make enough room for new one, abort the operation.
-		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), isLocal)
+		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), exempt)
 
 		// Special case, we still can't make the room for the new remote one.
-		if !isLocal && !success {
+		if !exempt && !success {
 			log.Trace("Discarding overflown transaction", "hash", hash)
 			overflowedTxMeter.Mark(1)
 			return false, ErrTxPoolOverflow
@@ -619,12 +852,13 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			underpricedTxMeter.Mark(1)
 			pool.removeTx(tx.Hash(), false)
 		}
+		pool.queueDropEvent(TxDropUnderpriced, drop...)
 	}
 	// Try to replace an existing transaction in the pending pool
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
-		inserted, old := list.Add(tx, pool.config.PriceBump)
+		inserted, old := list.Add(tx, pool.priceBump())
 		if !inserted {
 			pendingDiscardMeter.Mark(1)
 			return false, ErrReplaceUnderpriced
@@ -633,10 +867,11 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		if old != nil {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
+			pool.queueReplaceEvent(old, tx)
 			pendingReplaceMeter.Mark(1)
 		}
-		pool.all.Add(tx, isLocal)
-		pool.priced.Put(tx, isLocal)
+		pool.all.Add(tx, exempt)
+		pool.priced.Put(tx, exempt)
 		pool.journalTx(from, tx)
 		pool.queueTxEvent(tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -646,7 +881,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		return old != nil, nil
 	}
 	// New transaction isn't replacing a pending one, push into queue
-	replaced, err = pool.enqueueTx(hash, tx, isLocal, true)
+	replaced, err = pool.enqueueTx(hash, tx, exempt, true)
 	if err != nil {
 		return false, err
 	}
@@ -674,7 +909,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	if pool.queue[from] == nil {
 		pool.queue[from] = newTxList(false)
 	}
-	inserted, old := pool.queue[from].Add(tx, pool.config.PriceBump)
+	inserted, old := pool.queue[from].Add(tx, pool.priceBump())
 	if !inserted {
 		// An older transaction was better, discard this
 		queuedDiscardMeter.Mark(1)
@@ -684,6 +919,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	if old != nil {
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
+		pool.queueReplaceEvent(old, tx)
 		queuedReplaceMeter.Mark(1)
 	} else {
 		// Nothing was replaced, bump the queued counter
@@ -712,6 +948,10 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+	// Private and conditional transactions are kept in memory only
+	if pool.memoryOnly(tx.Hash()) {
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -728,11 +968,12 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	}
 	list := pool.pending[addr]
 
-	inserted, old := list.Add(tx, pool.config.PriceBump)
+	inserted, old := list.Add(tx, pool.priceBump())
 	if !inserted {
 		// An older transaction was better, discard this
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
+		pool.queueDropEvent(TxDropUnderpriced, tx)
 		pendingDiscardMeter.Mark(1)
 		return false
 	}
@@ -740,6 +981,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if old != nil {
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
+		pool.queueReplaceEvent(old, tx)
 		pendingReplaceMeter.Mark(1)
 	} else {
 		// Nothing was replaced, bump the pending counter
@@ -759,7 +1001,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 // This method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, !pool.config.NoLocals, false, true)
 }
 
 // AddLocal enqueues a single local transaction into the pool if it is valid. This is
@@ -769,18 +1011,57 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return errs[0]
 }
 
+// AddPrivate enqueues a single local transaction into the pool if it is valid,
+// marking it as private. Private transactions are available to the local miner,
+// but are never propagated to the network, nor journaled to disk.
+//
+// This method is used to add transactions from the RPC API and performs synchronous
+// pool reorganization and event propagation.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	errs := pool.addTxs([]*types.Transaction{tx}, !pool.config.NoLocals, true, true)
+	return errs[0]
+}
+
+// AddConditional enqueues a single local transaction into the pool if it is valid,
+// dropping it again once the chain progresses past the given conditions. Like
+// private transactions, conditional ones are not journaled to disk.
+//
+// This method is used to add transactions from the RPC API and performs synchronous
+// pool reorganization and event propagation.
+func (pool *TxPool) AddConditional(tx *types.Transaction, conditions *TxConditions) error {
+	// Mark the transaction before insertion so it is never journaled
+	pool.mu.Lock()
+	if _, ok := pool.conditionals[tx.Hash()]; !ok && pool.all.Get(tx.Hash()) == nil {
+		pool.conditionals[tx.Hash()] = conditions
+	}
+	pool.mu.Unlock()
+
+	errs := pool.addTxs([]*types.Transaction{tx}, !pool.config.NoLocals, false, true)
+	return errs[0]
+}
+
+// IsPrivate returns whether the transaction with the given hash was submitted
+// privately and must not be propagated.
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, ok := pool.private[hash]
+	return ok
+}
+
 // AddRemotes enqueues a batch of transactions into the pool if they are valid. If the
 // senders are not among the locally tracked ones, full pricing constraints will apply.
 //
 // This method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, false, false, false)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this method.
 func (pool *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, false, false, true)
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this method.
@@ -799,7 +1080,7 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 }
 
 // addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
+func (pool *TxPool) addTxs(txs []*types.Transaction, local, private, sync bool) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -830,7 +1111,21 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
+	if private {
+		// Mark the transactions before insertion, so they are never journaled
+		// nor announced, and unmark the rejected ones afterwards
+		for _, tx := range news {
+			pool.private[tx.Hash()] = struct{}{}
+		}
+	}
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	if private {
+		for i, err := range newErrs {
+			if err != nil {
+				delete(pool.private, news[i].Hash())
+			}
+		}
+	}
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -880,6 +1175,8 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 			status[i] = TxStatusPending
 		} else if txList := pool.queue[from]; txList != nil && txList.txs.items[tx.Nonce()] != nil {
 			status[i] = TxStatusQueued
+		} else if pool.lane.get(hash) != nil {
+			status[i] = TxStatusPending
 		}
 		// implicit else: the tx may have been included into a block between
 		// checking pool.Get and obtaining the lock. In that case, TxStatusUnknown is correct
@@ -890,13 +1187,16 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 
 // Get returns a transaction if it is contained in the pool and nil otherwise.
 func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
-	return pool.all.Get(hash)
+	if tx := pool.all.Get(hash); tx != nil {
+		return tx
+	}
+	return pool.lane.get(hash)
 }
 
 // Has returns an indicator whether txpool has a transaction cached with the
 // given hash.
 func (pool *TxPool) Has(hash common.Hash) bool {
-	return pool.all.Get(hash) != nil
+	return pool.Get(hash) != nil
 }
 
 // removeTx removes a single transaction from the queue, moving all subsequent
@@ -979,6 +1279,45 @@ func (pool *TxPool) queueTxEvent(tx *types.Transaction) {
 	}
 }
 
+// queueDropEvent records transactions dropped for the given reason, to be
+// announced once the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) queueDropEvent(reason TxDropReason, txs ...*types.Transaction) {
+	if len(txs) == 0 {
+		return
+	}
+	if pool.drops == nil {
+		pool.drops = make(map[TxDropReason][]*types.Transaction)
+	}
+	pool.drops[reason] = append(pool.drops[reason], txs...)
+}
+
+// queueReplaceEvent records a transaction replaced by another, to be announced
+// once the pool lock is released.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) queueReplaceEvent(old, tx *types.Transaction) {
+	pool.replaces = append(pool.replaces, ReplaceTxEvent{Old: old, New: tx})
+}
+
+// announceEvents sends the recorded drop and replace events to the subscribers.
+// It must be called without holding the pool lock, as the feeds block until all
+// subscribers received the events.
+func (pool *TxPool) announceEvents() {
+	pool.mu.Lock()
+	drops, replaces := pool.drops, pool.replaces
+	pool.drops, pool.replaces = nil, nil
+	pool.mu.Unlock()
+
+	for reason, txs := range drops {
+		pool.dropFeed.Send(DropTxsEvent{Txs: txs, Reason: reason})
+	}
+	for _, ev := range replaces {
+		pool.replaceFeed.Send(ev)
+	}
+}
+
 // scheduleReorgLoop schedules runs of reset and promoteExecutables. Code above should not
 // call those methods directly, but request them being run using requestReset and
 // requestPromoteExecutables instead.
@@ -1088,11 +1427,25 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	// because of another transaction (e.g. higher gas price).
 	if reset != nil {
 		pool.demoteUnexecutables()
+		pool.pruneLane()
+		pool.dropExpiredConditionals()
 	}
 	// Ensure pool.queue and pool.pending sizes stay within the configured limits.
 	pool.truncatePending()
 	pool.truncateQueue()
 
+	// Forget about the private and conditional transactions no longer in the pool
+	for hash := range pool.private {
+		if pool.all.Get(hash) == nil && pool.lane.get(hash) == nil {
+			delete(pool.private, hash)
+		}
+	}
+	for hash := range pool.conditionals {
+		if pool.all.Get(hash) == nil && pool.lane.get(hash) == nil {
+			delete(pool.conditionals, hash)
+		}
+	}
+
 	// Update all accounts to the latest known pending nonce
 	for addr, list := range pool.pending {
 		highestPending := list.LastElement()
@@ -1115,6 +1468,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 		}
 		pool.txFeed.Send(NewTxsEvent{txs})
 	}
+	pool.announceEvents()
 }
 
 // reset retrieves the current state of the blockchain and ensures the content
@@ -1205,6 +1559,29 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
+	pool.sidecar = pool.chainconfig.IsSidecar(next)
+}
+
+// dropExpiredConditionals removes the conditional transactions that can't be
+// included in the block following the current head any more.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropExpiredConditionals() {
+	if len(pool.conditionals) == 0 {
+		return
+	}
+	head := pool.chain.CurrentBlock().Header()
+	for hash, conditions := range pool.conditionals {
+		if !conditions.expired(head) {
+			continue
+		}
+		if tx := pool.all.Get(hash); tx != nil {
+			log.Trace("Removing expired conditional transaction", "hash", hash)
+			pool.removeTx(hash, true)
+			pool.queueDropEvent(TxDropExpired, tx)
+		}
+		delete(pool.conditionals, hash)
+	}
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -1226,6 +1603,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.queueDropEvent(TxDropStale, forwards...)
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1233,6 +1611,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.queueDropEvent(TxDropUnpayable, drops...)
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
@@ -1256,6 +1635,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.queueDropEvent(TxDropOverflow, caps...)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1290,7 +1670,7 @@ func (pool *TxPool) truncatePending() {
 	spammers := prque.New(nil)
 	for addr, list := range pool.pending {
 		// Only evict transactions from high rollers
-		if !pool.locals.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
+		if !pool.locals.contains(addr) && !pool.priority.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
 			spammers.Push(addr, int64(list.Len()))
 		}
 	}
@@ -1321,6 +1701,7 @@ func (pool *TxPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.queueDropEvent(TxDropOverflow, caps...)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1348,6 +1729,7 @@ func (pool *TxPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.queueDropEvent(TxDropOverflow, caps...)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1373,13 +1755,13 @@ func (pool *TxPool) truncateQueue() {
 	// Sort all accounts with queued transactions by heartbeat
 	addresses := make(addressesByHeartbeat, 0, len(pool.queue))
 	for addr := range pool.queue {
-		if !pool.locals.contains(addr) { // don't drop locals
+		if !pool.locals.contains(addr) && !pool.priority.contains(addr) { // don't drop locals and priority accounts
 			addresses = append(addresses, addressByHeartbeat{addr, pool.beats[addr]})
 		}
 	}
 	sort.Sort(addresses)
 
-	// Drop transactions until the total is below the limit or only locals remain
+	// Drop transactions until the total is below the limit or only exempt accounts remain
 	for drop := queued - pool.config.GlobalQueue; drop > 0 && len(addresses) > 0; {
 		addr := addresses[len(addresses)-1]
 		list := pool.queue[addr.address]
@@ -1388,9 +1770,11 @@ func (pool *TxPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true)
 			}
+			pool.queueDropEvent(TxDropOverflow, txs...)
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
 			continue
@@ -1399,6 +1783,7 @@ func (pool *TxPool) truncateQueue() {
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true)
+			pool.queueDropEvent(TxDropOverflow, txs[i])
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1420,6 +1805,8 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.queueDropEvent(TxDropStale, olds...)
+
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1427,6 +1814,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.queueDropEvent(TxDropUnpayable, drops...)
 		pool.priced.Removed(len(olds) + len(drops))
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -0,0 +1,264 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/metrics"
+)
+
+var (
+	// ErrLaneFull is returned if a transaction accepted by the lane validator
+	// can't be added because the lane is full.
+	ErrLaneFull = errors.New("transaction lane is full")
+
+	// ErrLaneAccountLimit is returned if a transaction accepted by the lane
+	// validator can't be added because its sender has too many lane transactions.
+	ErrLaneAccountLimit = errors.New("too many lane transactions of sender")
+)
+
+var (
+	validLaneTxMeter   = metrics.NewRegisteredMeter("txpool/lane/valid", nil)
+	invalidLaneTxMeter = metrics.NewRegisteredMeter("txpool/lane/invalid", nil)
+
+	laneGauge = metrics.NewRegisteredGauge("txpool/lane", nil)
+)
+
+// TxLaneValidator is a hook validating transactions by custom rules, allowing
+// extensions to accept transactions the standard rules reject for their price,
+// e.g. sponsored or meta transactions on private networks. The sender must still
+// be able to pay for the transaction, as the state transition charges it as any
+// other. The state of the chain head must not be modified.
+type TxLaneValidator func(tx *types.Transaction, from common.Address, state *state.StateDB) error
+
+// txLane is the alternative lane of the transaction pool, holding the transactions
+// accepted by the lane validator, grouped by sender.
+type txLane struct {
+	accounts map[common.Address]*txList // Lane transactions sorted by nonce per sender
+
+	all  map[common.Hash]*types.Transaction // All lane transactions to allow lookups
+	lock sync.RWMutex                       // Protects all for lookups without the pool lock
+}
+
+// newTxLane creates an empty transaction lane.
+func newTxLane() *txLane {
+	return &txLane{
+		accounts: make(map[common.Address]*txList),
+		all:      make(map[common.Hash]*types.Transaction),
+	}
+}
+
+// get returns the lane transaction of the given hash, or nil if not in the lane.
+func (l *txLane) get(hash common.Hash) *types.Transaction {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return l.all[hash]
+}
+
+// count returns the number of transactions in the lane.
+func (l *txLane) count() int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	return len(l.all)
+}
+
+// add inserts a transaction of the sender into the lane, replacing the one of the
+// same nonce if the price bump is met.
+func (l *txLane) add(from common.Address, tx *types.Transaction, priceBump uint64) (bool, *types.Transaction) {
+	if l.accounts[from] == nil {
+		l.accounts[from] = newTxList(false)
+	}
+	inserted, old := l.accounts[from].Add(tx, priceBump)
+	if !inserted {
+		if l.accounts[from].Empty() {
+			delete(l.accounts, from)
+		}
+		return false, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if old != nil {
+		delete(l.all, old.Hash())
+	}
+	l.all[tx.Hash()] = tx
+	return true, old
+}
+
+// remove drops the given transactions of the sender from the lane.
+func (l *txLane) remove(from common.Address, txs types.Transactions) {
+	list := l.accounts[from]
+	if list == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, tx := range txs {
+		list.Remove(tx)
+		delete(l.all, tx.Hash())
+	}
+	if list.Empty() {
+		delete(l.accounts, from)
+	}
+}
+
+// SetLaneValidator registers the hook accepting transactions rejected by the
+// standard validation rules into the alternative lane, which has its own limits
+// (LaneSlots and LaneAccountSlots). Setting nil disables the lane, dropping the
+// transactions in it.
+//
+// Lane transactions are executable once they follow the pending transactions of
+// their sender in the main pool without a nonce gap. They are validated again by
+// the hook on every new chain head and dropped once rejected.
+func (pool *TxPool) SetLaneValidator(validator TxLaneValidator) {
+	defer pool.announceEvents()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.laneValidator = validator
+	if validator == nil {
+		for from, list := range pool.lane.accounts {
+			txs := list.Flatten()
+			pool.lane.remove(from, txs)
+			pool.queueDropEvent(TxDropRejected, txs...)
+		}
+		laneGauge.Update(0)
+	}
+}
+
+// addLane validates a transaction rejected by the standard rules for the given
+// reason with the lane validator, inserting it into the lane if accepted. The
+// original rejection is returned if the transaction isn't eligible for the lane.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) addLane(tx *types.Transaction, reason error) error {
+	// Only transactions rejected for their price are eligible, the ones failing
+	// the structural checks are invalid regardless of the custom rules
+	if pool.laneValidator == nil || reason != ErrUnderpriced {
+		return reason
+	}
+	from, _ := types.Sender(pool.signer, tx) // checked before the price
+	if pool.currentState.GetNonce(from) > tx.Nonce() {
+		return ErrNonceTooLow
+	}
+	// Transactions the sender can't pay for would never be mined, reject them
+	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
+		return ErrInsufficientFunds
+	}
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
+	if err != nil {
+		return err
+	}
+	if tx.Gas() < intrGas {
+		return ErrIntrinsicGas
+	}
+	if err := pool.laneValidator(tx, from, pool.currentState); err != nil {
+		log.Trace("Discarding transaction rejected by lane validator", "hash", tx.Hash(), "err", err)
+		invalidLaneTxMeter.Mark(1)
+		return reason
+	}
+	// The transaction is valid by the custom rules, ensure the lane limits
+	list := pool.lane.accounts[from]
+	if list == nil || !list.Overlaps(tx) {
+		if list != nil && uint64(list.Len()) >= pool.config.LaneAccountSlots {
+			return ErrLaneAccountLimit
+		}
+		if uint64(pool.lane.count()) >= pool.config.LaneSlots {
+			return ErrLaneFull
+		}
+	}
+	inserted, old := pool.lane.add(from, tx, pool.priceBump())
+	if !inserted {
+		return ErrReplaceUnderpriced
+	}
+	if old != nil {
+		pool.queueReplaceEvent(old, tx)
+	}
+	validLaneTxMeter.Mark(1)
+	laneGauge.Update(int64(pool.lane.count()))
+
+	pool.queueTxEvent(tx)
+	log.Trace("Pooled new lane transaction", "hash", tx.Hash(), "from", from, "to", tx.To())
+	return nil
+}
+
+// laneExecutables returns the lane transactions of the sender following its pending
+// transactions of the main pool without a nonce gap.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) laneExecutables(from common.Address) types.Transactions {
+	list := pool.lane.accounts[from]
+	if list == nil {
+		return nil
+	}
+	next := pool.currentState.GetNonce(from)
+	if pending := pool.pending[from]; pending != nil && !pending.Empty() {
+		next = pending.LastElement().Nonce() + 1
+	}
+	var txs types.Transactions
+	for _, tx := range list.Flatten() {
+		if tx.Nonce() < next {
+			continue // Superseded by a pending transaction
+		}
+		if tx.Nonce() > next {
+			break
+		}
+		txs = append(txs, tx)
+		next++
+	}
+	return txs
+}
+
+// pruneLane drops the lane transactions included in the chain, unaffordable by their
+// sender or rejected by the lane validator on top of the new head.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) pruneLane() {
+	if pool.laneValidator == nil {
+		return
+	}
+	for from, list := range pool.lane.accounts {
+		olds := list.Forward(pool.currentState.GetNonce(from))
+		pool.lane.remove(from, olds)
+		pool.queueDropEvent(TxDropStale, olds...)
+
+		drops, _ := list.Filter(pool.currentState.GetBalance(from), pool.currentMaxGas)
+		pool.lane.remove(from, drops)
+		pool.queueDropEvent(TxDropUnpayable, drops...)
+
+		var invalids types.Transactions
+		for _, tx := range list.Flatten() {
+			if err := pool.laneValidator(tx, from, pool.currentState); err != nil {
+				log.Trace("Removing lane transaction rejected by validator", "hash", tx.Hash(), "err", err)
+				invalids = append(invalids, tx)
+			}
+		}
+		pool.lane.remove(from, invalids)
+		pool.queueDropEvent(TxDropRejected, invalids...)
+	}
+	laneGauge.Update(int64(pool.lane.count()))
+}
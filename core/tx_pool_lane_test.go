@@ -0,0 +1,134 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/event"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that transactions rejected by the standard rules are accepted into the
+// lane by the lane validator, within the lane limits, and leave it once included,
+// unaffordable or rejected by the validator.
+func TestTransactionLane(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 10000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.LaneSlots = 3
+	config.LaneAccountSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	otherAddr := crypto.PubkeyToAddress(other.PublicKey)
+
+	// Free transactions are rejected without a lane validator
+	if err := pool.AddRemote(pricedTransaction(0, 100000, new(big.Int), key)); err != ErrUnderpriced {
+		t.Fatalf("error mismatch without lane: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Sponsor free transactions with a limited gas allowance
+	sponsored := true
+	pool.SetLaneValidator(func(tx *types.Transaction, from common.Address, state *state.StateDB) error {
+		if !sponsored || tx.Gas() > 100000 {
+			return errors.New("not sponsored")
+		}
+		return nil
+	})
+	// Transactions the sender can't pay for are rejected regardless of the validator
+	if err := pool.AddRemote(pricedTransaction(0, 100000, new(big.Int), key)); err != ErrInsufficientFunds {
+		t.Fatalf("error mismatch for unfunded transaction: have %v, want %v", err, ErrInsufficientFunds)
+	}
+	pool.currentState.AddBalance(addr, big.NewInt(1000))
+	pool.currentState.AddBalance(otherAddr, big.NewInt(1000))
+
+	if err := pool.AddRemote(pricedTransaction(0, 200000, new(big.Int), key)); err != ErrUnderpriced {
+		t.Fatalf("error mismatch for rejected transaction: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 1000, new(big.Int), key)); err != ErrIntrinsicGas {
+		t.Fatalf("error mismatch for invalid transaction: have %v, want %v", err, ErrIntrinsicGas)
+	}
+	var (
+		first = pricedTransaction(0, 100000, new(big.Int), key)
+		gap   = pricedTransaction(2, 100000, new(big.Int), key)
+	)
+	if err := pool.AddRemotesSync([]*types.Transaction{first})[0]; err != nil {
+		t.Fatalf("failed to add lane transaction: %v", err)
+	}
+	if err := pool.AddRemote(gap); err != nil {
+		t.Fatalf("failed to add lane transaction: %v", err)
+	}
+	if err := pool.AddRemote(first); err != ErrAlreadyKnown {
+		t.Fatalf("error mismatch for known transaction: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if pool.Get(first.Hash()) == nil || !pool.Has(gap.Hash()) {
+		t.Fatalf("lane transactions not found")
+	}
+	if status := pool.Status([]common.Hash{first.Hash()}); status[0] != TxStatusPending {
+		t.Fatalf("lane transaction status mismatch: have %v, want %v", status[0], TxStatusPending)
+	}
+	// Only the gapless lane transactions are executable
+	pending, _ := pool.Pending()
+	if txs := pending[addr]; len(txs) != 1 || txs[0].Hash() != first.Hash() {
+		t.Fatalf("executable lane transactions mismatch: have %v, want [%x]", txs, first.Hash())
+	}
+	// Ensure the lane limits are enforced
+	if err := pool.AddRemote(pricedTransaction(3, 100000, new(big.Int), key)); err != ErrLaneAccountLimit {
+		t.Fatalf("error mismatch over account limit: have %v, want %v", err, ErrLaneAccountLimit)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, new(big.Int), other)); err != nil {
+		t.Fatalf("failed to add lane transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, new(big.Int), other)); err != ErrLaneFull {
+		t.Fatalf("error mismatch over lane limit: have %v, want %v", err, ErrLaneFull)
+	}
+	// Include the first transaction, ensure it leaves the lane
+	pool.currentState.SetNonce(addr, 1)
+	<-pool.requestReset(nil, nil)
+
+	if pool.Has(first.Hash()) || !pool.Has(gap.Hash()) {
+		t.Fatalf("included lane transaction not removed")
+	}
+	// Drain the funds of the other sender, ensure its transaction leaves the lane
+	pool.currentState.SetBalance(otherAddr, new(big.Int))
+	<-pool.requestReset(nil, nil)
+
+	if pool.lane.accounts[otherAddr] != nil || !pool.Has(gap.Hash()) {
+		t.Fatalf("unaffordable lane transaction not removed")
+	}
+	// Withdraw the sponsorship, ensure the lane is emptied
+	sponsored = false
+	<-pool.requestReset(nil, nil)
+
+	if pool.Has(gap.Hash()) || pool.lane.count() != 0 {
+		t.Fatalf("rejected lane transactions not removed: %d left", pool.lane.count())
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/membudget"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
 	"github.com/acent/go-acent/core/types"
@@ -1197,6 +1198,38 @@ func TestTransactionCapClearsFromAll(t *testing.T) {
 	}
 }
 
+// Tests that the global slot limits of the pool follow its memory allowance,
+// keeping the configured proportion of executable and queued slots.
+func TestTransactionPoolMemoryBudget(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	budget := membudget.NewManager(256 * 1024 * 1024)
+	pool.RegisterMemoryBudget(budget)
+
+	// While syncing the pool keeps its configured limits
+	check := func(slots, queue uint64) {
+		t.Helper()
+		pool.mu.RLock()
+		defer pool.mu.RUnlock()
+
+		if pool.config.GlobalSlots != slots || pool.config.GlobalQueue != queue {
+			t.Errorf("limits mismatch: have %d/%d, want %d/%d", pool.config.GlobalSlots, pool.config.GlobalQueue, slots, queue)
+		}
+	}
+	check(4096, 1024)
+	budget.SetPhase(membudget.PhaseServing)
+	check(6554, 1638) // 256MB worth of slots, split 4096:1024
+	budget.SetPhase(membudget.PhaseSyncing)
+	check(4096, 1024)
+
+	// Allowances below the configured limits never shrink the pool
+	pool.setSlotLimits(512, 4096, 1024)
+	check(4096, 1024)
+}
+
 // Tests that if the transaction count belonging to multiple accounts go above
 // some hard threshold, if they are under the minimum guaranteed slot count then
 // the transactions are still kept.
@@ -1534,6 +1567,70 @@ func TestTransactionPoolUnderpricing(t *testing.T) {
 	}
 }
 
+// Tests that transactions of priority accounts bypass the global pool limits and
+// are retained under pressure, while still being validated like remote ones.
+func TestTransactionPoolPriorityAccounts(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool with a priority account to test the exemptions with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	priority, _ := crypto.GenerateKey()
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.PriceLimit = 2
+	config.Priority = []common.Address{crypto.PubkeyToAddress(priority.PublicKey)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 2)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(priority.PublicKey), big.NewInt(1000000000))
+
+	// Fill the pool up with remote transactions
+	for i := 0; i < len(keys); i++ {
+		for j := 0; j < 2; j++ {
+			if err := pool.addRemoteSync(pricedTransaction(uint64(j), 100000, big.NewInt(int64(3+j)), keys[i])); err != nil {
+				t.Fatalf("failed to add remote transaction: %v", err)
+			}
+		}
+	}
+	// Ensure priority transactions are still validated against the price limit
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), priority)); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced priority transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Ensure cheap priority transactions push out better priced remote ones
+	for i := 0; i < 3; i++ {
+		if err := pool.addRemoteSync(pricedTransaction(uint64(i), 100000, big.NewInt(2), priority)); err != nil {
+			t.Fatalf("failed to add priority transaction %d: %v", i, err)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Ensure remote transactions can't push out the priority ones
+	if err := pool.addRemoteSync(pricedTransaction(2, 100000, big.NewInt(10), keys[0])); err != nil && err != ErrTxPoolOverflow {
+		t.Fatalf("failed to add well priced remote transaction: %v", err)
+	}
+	pending, _ := pool.Stats()
+	if have := pool.pending[crypto.PubkeyToAddress(priority.PublicKey)].Len(); have != 3 {
+		t.Fatalf("pending priority transactions mismatched: have %d, want %d", have, 3)
+	}
+	if pending < 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want at least %d", pending, 3)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that more expensive transactions push out cheap ones from the pool, but
 // without producing instability by creating gaps that start jumping transactions
 // back and forth between queued/pending.
@@ -1746,6 +1843,200 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that the replacement policy can be updated at runtime, and that replacing
+// by tip accepts any higher gas price.
+func TestTransactionReplacementPolicy(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.SetReplacementPolicy(0, true); err != ErrInvalidPriceBump {
+		t.Fatalf("invalid policy error mismatch: have %v, want %v", err, ErrInvalidPriceBump)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(100), key)); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(101), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	// Replace by tip, any higher price is accepted for pending and queued ones
+	if err := pool.SetReplacementPolicy(50, true); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if bump, byTip := pool.ReplacementPolicy(); bump != 50 || !byTip {
+		t.Fatalf("policy mismatch: have %d/%v, want 50/true", bump, byTip)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100001, big.NewInt(100), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("same price replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(101), key)); err != nil {
+		t.Fatalf("failed to replace pending transaction by tip: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(100), key)); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(101), key)); err != nil {
+		t.Fatalf("failed to replace queued transaction by tip: %v", err)
+	}
+	// Back to bumps, the new percentage is enforced
+	if err := pool.SetReplacementPolicy(50, false); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(150), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(151), key)); err != nil {
+		t.Fatalf("failed to replace pending transaction: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the transactions dropped from or replaced in the pool are announced
+// with their reasons, and that the content of a single account is retrievable.
+func TestTransactionDropReplaceEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(addr, big.NewInt(1000000000))
+
+	drops := make(chan DropTxsEvent, 16)
+	dropSub := pool.SubscribeDropTxsEvent(drops)
+	defer dropSub.Unsubscribe()
+
+	replaces := make(chan ReplaceTxEvent, 16)
+	replaceSub := pool.SubscribeReplaceTxEvent(replaces)
+	defer replaceSub.Unsubscribe()
+
+	var (
+		first    = pricedTransaction(0, 100000, big.NewInt(100), key)
+		replaced = pricedTransaction(0, 100000, big.NewInt(200), key)
+		queued   = pricedTransaction(2, 100000, big.NewInt(100), key)
+	)
+	for _, tx := range []*types.Transaction{first, queued} {
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	pending, queue := pool.ContentFrom(addr)
+	if len(pending) != 1 || pending[0] != first || len(queue) != 1 || queue[0] != queued {
+		t.Fatalf("account content mismatch: have %v/%v, want [%x]/[%x]", pending, queue, first.Hash(), queued.Hash())
+	}
+	if pending, queue := pool.ContentFrom(common.Address{1}); len(pending) != 0 || len(queue) != 0 {
+		t.Fatalf("unknown account content mismatch: have %v/%v, want none", pending, queue)
+	}
+	// Replace the pending transaction, ensure the replacement is announced
+	if err := pool.addRemoteSync(replaced); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	select {
+	case ev := <-replaces:
+		if ev.Old != first || ev.New != replaced {
+			t.Fatalf("replace event mismatch: have %x->%x, want %x->%x", ev.Old.Hash(), ev.New.Hash(), first.Hash(), replaced.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("replace event not fired")
+	}
+	// Drop the queued transaction by its price and the pending one as included
+	checkDrop := func(tx *types.Transaction, reason TxDropReason) {
+		t.Helper()
+		select {
+		case ev := <-drops:
+			if len(ev.Txs) != 1 || ev.Txs[0] != tx || ev.Reason != reason {
+				t.Fatalf("drop event mismatch: have %v (%s), want [%x] (%s)", ev.Txs, ev.Reason, tx.Hash(), reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("drop event not fired for %x", tx.Hash())
+		}
+	}
+	pool.SetGasPrice(big.NewInt(150))
+	checkDrop(queued, TxDropUnderpriced)
+
+	pool.currentState.SetNonce(addr, 1)
+	<-pool.requestReset(nil, nil)
+	checkDrop(replaced, TxDropStale)
+
+	select {
+	case ev := <-drops:
+		t.Fatalf("unexpected drop event: %v (%s)", ev.Txs, ev.Reason)
+	case ev := <-replaces:
+		t.Fatalf("unexpected replace event: %x->%x", ev.Old.Hash(), ev.New.Hash())
+	default:
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that sidecar transactions are only accepted once their fork activates, and
+// only along with a sidecar matching their blob hashes.
+func TestTransactionSidecar(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	sidecar := &types.Sidecar{Blobs: [][]byte{make([]byte, params.MaxSidecarBlobSize)}}
+	sign := func(config *params.ChainConfig, nonce uint64, hashes []common.Hash) *types.Transaction {
+		return types.MustSignNewTx(key, types.NewSidecarSigner(config.ChainID), &types.SidecarTx{
+			ChainID:    config.ChainID,
+			Nonce:      nonce,
+			Gas:        100000,
+			GasPrice:   big.NewInt(1),
+			BlobHashes: hashes,
+		})
+	}
+	// Sidecar transactions are rejected before the fork, by the signer of the pool
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(addr, big.NewInt(1000000000))
+	if err := pool.AddRemote(sign(params.TestChainConfig, 0, sidecar.Hashes()).WithSidecar(sidecar)); err != ErrInvalidSender {
+		t.Fatalf("pre-fork error mismatch: have %v, want %v", err, ErrInvalidSender)
+	}
+	// Once activated, the sidecars are validated apart from the transaction size
+	config := *params.TestChainConfig
+	config.SidecarBlock = big.NewInt(0)
+
+	forked := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer forked.Stop()
+
+	forked.currentState.AddBalance(addr, big.NewInt(1000000000))
+	if err := forked.AddRemote(sign(&config, 0, sidecar.Hashes())); err != types.ErrSidecarMissing {
+		t.Fatalf("missing sidecar error mismatch: have %v, want %v", err, types.ErrSidecarMissing)
+	}
+	if err := forked.AddRemote(sign(&config, 0, []common.Hash{{1}}).WithSidecar(sidecar)); err != types.ErrSidecarMismatch {
+		t.Fatalf("mismatching sidecar error mismatch: have %v, want %v", err, types.ErrSidecarMismatch)
+	}
+	if err := forked.AddRemote(sign(&config, 0, nil)); err != types.ErrSidecarBlobCount {
+		t.Fatalf("blobless error mismatch: have %v, want %v", err, types.ErrSidecarBlobCount)
+	}
+	tx := sign(&config, 0, sidecar.Hashes()).WithSidecar(sidecar)
+	if err := forked.addRemoteSync(tx); err != nil {
+		t.Fatalf("failed to add sidecar transaction: %v", err)
+	}
+	if pooled := forked.Get(tx.Hash()); pooled == nil || pooled.Sidecar() != sidecar {
+		t.Fatalf("sidecar not pooled with transaction")
+	}
+	if err := validateTxPoolInternals(forked); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -1859,6 +2150,132 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that private transactions are tracked as such while in the pool, and
+// are neither journaled nor remembered once dropped.
+func TestTransactionPrivate(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	// Clean up the temporary file, we only need the path for now
+	file.Close()
+	os.Remove(journal)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = journal
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Add a public and a private transaction, and ensure only the latter is private
+	public := pricedTransaction(0, 100000, big.NewInt(1), key)
+	private := pricedTransaction(1, 100000, big.NewInt(1), key)
+	if err := pool.AddLocal(public); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Errorf("public transaction marked private")
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Errorf("private transaction not marked private")
+	}
+	// Rejected transactions must not be marked private
+	if err := pool.AddPrivate(public); err != ErrAlreadyKnown {
+		t.Fatalf("known transaction error mismatch: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Errorf("known transaction marked private")
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Restart the pool and ensure the private transaction didn't survive
+	pool.Stop()
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pool.Get(public.Hash()) == nil {
+		t.Errorf("public transaction not journaled")
+	}
+	if pool.Get(private.Hash()) != nil {
+		t.Errorf("private transaction journaled")
+	}
+	// Drop a private transaction and ensure it's forgotten
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	statedb.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 2)
+	<-pool.requestReset(nil, nil)
+
+	if pool.IsPrivate(private.Hash()) {
+		t.Errorf("dropped private transaction still marked private")
+	}
+}
+
+// Tests that conditional transactions are dropped once the chain progresses past
+// their conditions, and are forgotten once dropped.
+func TestTransactionConditional(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// The test chain is stuck at the genesis, so the next block is #1 with a
+	// timestamp of at least 1
+	var (
+		valid   = pricedTransaction(0, 100000, big.NewInt(1), key)
+		expired = pricedTransaction(1, 100000, big.NewInt(1), key)
+		never   = uint64(0)
+	)
+	if err := pool.AddConditional(valid, &TxConditions{BlockNumberMax: big.NewInt(1)}); err != nil {
+		t.Fatalf("failed to add conditional transaction: %v", err)
+	}
+	if err := pool.AddConditional(expired, &TxConditions{TimestampMax: &never}); err != nil {
+		t.Fatalf("failed to add conditional transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	<-pool.requestReset(nil, nil)
+
+	if pool.Get(valid.Hash()) == nil {
+		t.Errorf("valid conditional transaction dropped")
+	}
+	if pool.Get(expired.Hash()) != nil {
+		t.Errorf("expired conditional transaction not dropped")
+	}
+	if _, ok := pool.conditionals[expired.Hash()]; ok {
+		t.Errorf("expired conditional transaction not forgotten")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
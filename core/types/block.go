@@ -201,7 +201,7 @@ type storageblock struct {
 //
 // The values of TxHash, UncleHash, ReceiptHash and Bloom in header
 // are ignored and set to values derived from the given txs, uncles
-// and receipts.
+// and receipts. The sidecars of the transactions are not included.
 func NewBlock(header *Header, txs []*Transaction, uncles []*Header, receipts []*Receipt, hasher TrieHasher) *Block {
 	b := &Block{header: CopyHeader(header), td: new(big.Int)}
 
@@ -211,7 +211,9 @@ func NewBlock(header *Header, txs []*Transaction, uncles []*Header, receipts []*
 	} else {
 		b.header.TxHash = DeriveSha(Transactions(txs), hasher)
 		b.transactions = make(Transactions, len(txs))
-		copy(b.transactions, txs)
+		for i, tx := range txs {
+			b.transactions[i] = tx.WithoutSidecar()
+		}
 	}
 
 	if len(receipts) == 0 {
@@ -370,14 +372,17 @@ func (b *Block) WithSeal(header *Header) *Block {
 	}
 }
 
-// WithBody returns a new block with the given transaction and uncle contents.
+// WithBody returns a new block with the given transaction and uncle contents,
+// dropping the sidecars of the transactions.
 func (b *Block) WithBody(transactions []*Transaction, uncles []*Header) *Block {
 	block := &Block{
 		header:       CopyHeader(b.header),
 		transactions: make([]*Transaction, len(transactions)),
 		uncles:       make([]*Header, len(uncles)),
 	}
-	copy(block.transactions, transactions)
+	for i, tx := range transactions {
+		block.transactions[i] = tx.WithoutSidecar()
+	}
 	for i := range uncles {
 		block.uncles[i] = CopyHeader(uncles[i])
 	}
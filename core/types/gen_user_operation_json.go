@@ -0,0 +1,110 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+)
+
+var _ = (*userOperationMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (u UserOperation) MarshalJSON() ([]byte, error) {
+	type UserOperation struct {
+		Sender               common.Address `json:"sender" gencodec:"required"`
+		Nonce                *hexutil.Big   `json:"nonce" gencodec:"required"`
+		InitCode             hexutil.Bytes  `json:"initCode" gencodec:"required"`
+		CallData             hexutil.Bytes  `json:"callData" gencodec:"required"`
+		CallGasLimit         *hexutil.Big   `json:"callGasLimit" gencodec:"required"`
+		VerificationGasLimit *hexutil.Big   `json:"verificationGasLimit" gencodec:"required"`
+		PreVerificationGas   *hexutil.Big   `json:"preVerificationGas" gencodec:"required"`
+		MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas" gencodec:"required"`
+		MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas" gencodec:"required"`
+		PaymasterAndData     hexutil.Bytes  `json:"paymasterAndData" gencodec:"required"`
+		Signature            hexutil.Bytes  `json:"signature" gencodec:"required"`
+	}
+	var enc UserOperation
+	enc.Sender = u.Sender
+	enc.Nonce = (*hexutil.Big)(u.Nonce)
+	enc.InitCode = u.InitCode
+	enc.CallData = u.CallData
+	enc.CallGasLimit = (*hexutil.Big)(u.CallGasLimit)
+	enc.VerificationGasLimit = (*hexutil.Big)(u.VerificationGasLimit)
+	enc.PreVerificationGas = (*hexutil.Big)(u.PreVerificationGas)
+	enc.MaxFeePerGas = (*hexutil.Big)(u.MaxFeePerGas)
+	enc.MaxPriorityFeePerGas = (*hexutil.Big)(u.MaxPriorityFeePerGas)
+	enc.PaymasterAndData = u.PaymasterAndData
+	enc.Signature = u.Signature
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (u *UserOperation) UnmarshalJSON(input []byte) error {
+	type UserOperation struct {
+		Sender               *common.Address `json:"sender" gencodec:"required"`
+		Nonce                *hexutil.Big    `json:"nonce" gencodec:"required"`
+		InitCode             *hexutil.Bytes  `json:"initCode" gencodec:"required"`
+		CallData             *hexutil.Bytes  `json:"callData" gencodec:"required"`
+		CallGasLimit         *hexutil.Big    `json:"callGasLimit" gencodec:"required"`
+		VerificationGasLimit *hexutil.Big    `json:"verificationGasLimit" gencodec:"required"`
+		PreVerificationGas   *hexutil.Big    `json:"preVerificationGas" gencodec:"required"`
+		MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas" gencodec:"required"`
+		MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas" gencodec:"required"`
+		PaymasterAndData     *hexutil.Bytes  `json:"paymasterAndData" gencodec:"required"`
+		Signature            *hexutil.Bytes  `json:"signature" gencodec:"required"`
+	}
+	var dec UserOperation
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Sender == nil {
+		return errors.New("missing required field 'sender' for UserOperation")
+	}
+	u.Sender = *dec.Sender
+	if dec.Nonce == nil {
+		return errors.New("missing required field 'nonce' for UserOperation")
+	}
+	u.Nonce = (*big.Int)(dec.Nonce)
+	if dec.InitCode == nil {
+		return errors.New("missing required field 'initCode' for UserOperation")
+	}
+	u.InitCode = *dec.InitCode
+	if dec.CallData == nil {
+		return errors.New("missing required field 'callData' for UserOperation")
+	}
+	u.CallData = *dec.CallData
+	if dec.CallGasLimit == nil {
+		return errors.New("missing required field 'callGasLimit' for UserOperation")
+	}
+	u.CallGasLimit = (*big.Int)(dec.CallGasLimit)
+	if dec.VerificationGasLimit == nil {
+		return errors.New("missing required field 'verificationGasLimit' for UserOperation")
+	}
+	u.VerificationGasLimit = (*big.Int)(dec.VerificationGasLimit)
+	if dec.PreVerificationGas == nil {
+		return errors.New("missing required field 'preVerificationGas' for UserOperation")
+	}
+	u.PreVerificationGas = (*big.Int)(dec.PreVerificationGas)
+	if dec.MaxFeePerGas == nil {
+		return errors.New("missing required field 'maxFeePerGas' for UserOperation")
+	}
+	u.MaxFeePerGas = (*big.Int)(dec.MaxFeePerGas)
+	if dec.MaxPriorityFeePerGas == nil {
+		return errors.New("missing required field 'maxPriorityFeePerGas' for UserOperation")
+	}
+	u.MaxPriorityFeePerGas = (*big.Int)(dec.MaxPriorityFeePerGas)
+	if dec.PaymasterAndData == nil {
+		return errors.New("missing required field 'paymasterAndData' for UserOperation")
+	}
+	u.PaymasterAndData = *dec.PaymasterAndData
+	if dec.Signature == nil {
+		return errors.New("missing required field 'signature' for UserOperation")
+	}
+	u.Signature = *dec.Signature
+	return nil
+}
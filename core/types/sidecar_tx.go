@@ -0,0 +1,225 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rlp"
+)
+
+var (
+	ErrSidecarMissing      = errors.New("missing transaction sidecar")
+	ErrSidecarMismatch     = errors.New("transaction sidecar doesn't match blob hashes")
+	ErrSidecarBlobCount    = errors.New("invalid number of sidecar blobs")
+	ErrSidecarBlobTooLarge = errors.New("sidecar blob too large")
+)
+
+// SidecarTx is the data of experimental sidecar transactions, committing to large
+// blobs of data carried next to the transaction instead of in it. The blobs are
+// validated and propagated along with the transaction, but only their hashes are
+// included in blocks, the blobs themselves are pruned once the transaction leaves
+// the pool.
+type SidecarTx struct {
+	ChainID    *big.Int        // destination chain ID
+	Nonce      uint64          // nonce of sender account
+	GasPrice   *big.Int        // wei per gas
+	Gas        uint64          // gas limit
+	To         *common.Address `rlp:"nil"` // nil means contract creation
+	Value      *big.Int        // wei amount
+	Data       []byte          // contract invocation input data
+	AccessList AccessList      // EIP-2930 access list
+	BlobHashes []common.Hash   // hashes of the sidecar blobs
+	V, R, S    *big.Int        // signature values
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *SidecarTx) copy() TxData {
+	cpy := &SidecarTx{
+		Nonce: tx.Nonce,
+		To:    tx.To, // TODO: copy pointed-to address
+		Data:  common.CopyBytes(tx.Data),
+		Gas:   tx.Gas,
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		BlobHashes: make([]common.Hash, len(tx.BlobHashes)),
+		Value:      new(big.Int),
+		ChainID:    new(big.Int),
+		GasPrice:   new(big.Int),
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+	}
+	copy(cpy.AccessList, tx.AccessList)
+	copy(cpy.BlobHashes, tx.BlobHashes)
+	if tx.Value != nil {
+		cpy.Value.Set(tx.Value)
+	}
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+	if tx.GasPrice != nil {
+		cpy.GasPrice.Set(tx.GasPrice)
+	}
+	if tx.V != nil {
+		cpy.V.Set(tx.V)
+	}
+	if tx.R != nil {
+		cpy.R.Set(tx.R)
+	}
+	if tx.S != nil {
+		cpy.S.Set(tx.S)
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+
+func (tx *SidecarTx) txType() byte           { return SidecarTxType }
+func (tx *SidecarTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *SidecarTx) protected() bool        { return true }
+func (tx *SidecarTx) accessList() AccessList { return tx.AccessList }
+func (tx *SidecarTx) data() []byte           { return tx.Data }
+func (tx *SidecarTx) gas() uint64            { return tx.Gas }
+func (tx *SidecarTx) gasPrice() *big.Int     { return tx.GasPrice }
+func (tx *SidecarTx) value() *big.Int        { return tx.Value }
+func (tx *SidecarTx) nonce() uint64          { return tx.Nonce }
+func (tx *SidecarTx) to() *common.Address    { return tx.To }
+
+func (tx *SidecarTx) rawSignatureValues() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *SidecarTx) setSignatureValues(chainID, v, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+// Sidecar is the data a sidecar transaction commits to, carried next to it in the
+// network and the transaction pool.
+type Sidecar struct {
+	Blobs [][]byte
+}
+
+// sidecarTxEnvelope is the network encoding of a sidecar transaction with its
+// sidecar attached.
+type sidecarTxEnvelope struct {
+	Tx    *SidecarTx
+	Blobs [][]byte
+}
+
+// decodeSidecarTx decodes the payload of a sidecar transaction, either in the
+// canonical format or wrapped along with its sidecar.
+func decodeSidecarTx(b []byte) (TxData, *Sidecar, error) {
+	_, content, _, err := rlp.Split(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	kind, _, _, err := rlp.Split(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kind != rlp.List {
+		var inner SidecarTx
+		err := rlp.DecodeBytes(b, &inner)
+		return &inner, nil, err
+	}
+	var envelope sidecarTxEnvelope
+	if err := rlp.DecodeBytes(b, &envelope); err != nil {
+		return nil, nil, err
+	}
+	return envelope.Tx, &Sidecar{Blobs: envelope.Blobs}, nil
+}
+
+// Hashes returns the hashes of the sidecar blobs, which transactions commit to.
+func (s *Sidecar) Hashes() []common.Hash {
+	hashes := make([]common.Hash, len(s.Blobs))
+	for i, blob := range s.Blobs {
+		hashes[i] = crypto.Keccak256Hash(blob)
+	}
+	return hashes
+}
+
+// BlobHashes returns the hashes of the sidecar blobs the transaction commits to,
+// nil for other transaction types.
+func (tx *Transaction) BlobHashes() []common.Hash {
+	if inner, ok := tx.inner.(*SidecarTx); ok {
+		return inner.BlobHashes
+	}
+	return nil
+}
+
+// Sidecar returns the sidecar attached to the transaction, nil if none is.
+func (tx *Transaction) Sidecar() *Sidecar {
+	return tx.sidecar
+}
+
+// WithSidecar returns a copy of the sidecar transaction with the given sidecar
+// attached. Other transaction types are returned as is.
+func (tx *Transaction) WithSidecar(sidecar *Sidecar) *Transaction {
+	if tx.Type() != SidecarTxType {
+		return tx
+	}
+	cpy := &Transaction{inner: tx.inner, time: tx.time, sidecar: sidecar}
+	if hash := tx.hash.Load(); hash != nil {
+		cpy.hash.Store(hash)
+	}
+	if from := tx.from.Load(); from != nil {
+		cpy.from.Store(from)
+	}
+	return cpy
+}
+
+// WithoutSidecar returns a copy of the transaction with its sidecar detached, as
+// included in blocks. Transactions without a sidecar are returned as is.
+func (tx *Transaction) WithoutSidecar() *Transaction {
+	if tx.sidecar == nil {
+		return tx
+	}
+	return tx.WithSidecar(nil)
+}
+
+// VerifySidecar checks that a sidecar transaction commits to a valid number of
+// blobs and that its attached sidecar matches them. Other transaction types are
+// always valid.
+func (tx *Transaction) VerifySidecar() error {
+	inner, ok := tx.inner.(*SidecarTx)
+	if !ok {
+		return nil
+	}
+	if len(inner.BlobHashes) == 0 || len(inner.BlobHashes) > params.MaxTxSidecarBlobs {
+		return ErrSidecarBlobCount
+	}
+	if tx.sidecar == nil {
+		return ErrSidecarMissing
+	}
+	if len(tx.sidecar.Blobs) != len(inner.BlobHashes) {
+		return ErrSidecarMismatch
+	}
+	for i, blob := range tx.sidecar.Blobs {
+		if len(blob) > params.MaxSidecarBlobSize {
+			return ErrSidecarBlobTooLarge
+		}
+		if crypto.Keccak256Hash(blob) != inner.BlobHashes[i] {
+			return ErrSidecarMismatch
+		}
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/acent/go-acent/common"
+)
+
+// This file implements the SSZ (SimpleSerialize) encoding of headers and transactions,
+// for interoperating with tooling of the consensus layer ecosystem.
+//
+// Big integers are encoded as uint256, optional recipients as lists of at most one
+// address and transactions as unions keyed by their EIP-2718 type, the legacy type
+// being zero. Sidecars are not part of the encoding of transactions.
+
+const (
+	sszOffsetSize = 4   // size of the offsets of variable size fields
+	sszHeaderSize = 544 // size of the fixed part of an encoded header
+)
+
+var (
+	errSSZShort       = errors.New("ssz: input too short")
+	errSSZTrailing    = errors.New("ssz: input has trailing bytes")
+	errSSZOffset      = errors.New("ssz: invalid offset")
+	errSSZListSize    = errors.New("ssz: list size not a multiple of its element size")
+	errSSZOptional    = errors.New("ssz: optional value with more than one element")
+	errSSZUint256     = errors.New("ssz: integer does not fit in 256 bits")
+	errSSZUint64      = errors.New("ssz: integer does not fit in 64 bits")
+	errSSZNegative    = errors.New("ssz: negative integer")
+	errSSZEmptyTx     = errors.New("ssz: empty transaction")
+	errSSZUnknownType = errors.New("ssz: unknown union selector")
+)
+
+// sszEncoder assembles an SSZ container, appending its fixed size fields in order
+// and the variable size ones behind offsets after the fixed part.
+type sszEncoder struct {
+	fixed    []byte
+	offsets  []int // positions of the offsets in the fixed part
+	variable [][]byte
+	err      error
+}
+
+func (e *sszEncoder) bytes(b []byte) {
+	e.fixed = append(e.fixed, b...)
+}
+
+func (e *sszEncoder) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.fixed = append(e.fixed, b[:]...)
+}
+
+// uint256 appends a big integer as a little endian uint256, nil being zero.
+func (e *sszEncoder) uint256(v *big.Int) {
+	var b [32]byte
+	if v != nil {
+		switch {
+		case v.Sign() < 0:
+			e.setErr(errSSZNegative)
+		case v.BitLen() > 256:
+			e.setErr(errSSZUint256)
+		default:
+			v.FillBytes(b[:])
+		}
+	}
+	for i := 0; i < 16; i++ {
+		b[i], b[31-i] = b[31-i], b[i]
+	}
+	e.fixed = append(e.fixed, b[:]...)
+}
+
+// dynamic appends a variable size field behind an offset.
+func (e *sszEncoder) dynamic(b []byte) {
+	e.offsets = append(e.offsets, len(e.fixed))
+	e.fixed = append(e.fixed, make([]byte, sszOffsetSize)...)
+	e.variable = append(e.variable, b)
+}
+
+func (e *sszEncoder) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// finish patches the offsets and returns the encoded container.
+func (e *sszEncoder) finish() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	out, offset := e.fixed, len(e.fixed)
+	for i, b := range e.variable {
+		binary.LittleEndian.PutUint32(out[e.offsets[i]:], uint32(offset))
+		out = append(out, b...)
+		offset += len(b)
+	}
+	return out, nil
+}
+
+// sszDecoder reads an SSZ container, consuming its fixed part field by field. The
+// variable size fields are read in order once the fixed part is consumed.
+type sszDecoder struct {
+	buf     []byte
+	pos     int
+	offsets []int
+	err     error
+}
+
+func (d *sszDecoder) bytes(n int) []byte {
+	if d.err != nil || len(d.buf)-d.pos < n {
+		d.setErr(errSSZShort)
+		return make([]byte, n)
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *sszDecoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.bytes(8))
+}
+
+func (d *sszDecoder) uint256() *big.Int {
+	var b [32]byte
+	copy(b[:], d.bytes(32))
+	for i := 0; i < 16; i++ {
+		b[i], b[31-i] = b[31-i], b[i]
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+// dynamic reads the offset of a variable size field.
+func (d *sszDecoder) dynamic() {
+	d.offsets = append(d.offsets, int(binary.LittleEndian.Uint32(d.bytes(sszOffsetSize))))
+}
+
+func (d *sszDecoder) setErr(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// finish checks the offsets, which must start right after the fixed part and not
+// decrease, and returns the variable size fields.
+func (d *sszDecoder) finish() ([][]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.offsets) == 0 {
+		if d.pos != len(d.buf) {
+			return nil, errSSZTrailing
+		}
+		return nil, nil
+	}
+	if d.offsets[0] != d.pos {
+		return nil, errSSZOffset
+	}
+	fields := make([][]byte, len(d.offsets))
+	for i, start := range d.offsets {
+		end := len(d.buf)
+		if i+1 < len(d.offsets) {
+			end = d.offsets[i+1]
+		}
+		if end < start || end > len(d.buf) {
+			return nil, errSSZOffset
+		}
+		fields[i] = d.buf[start:end]
+	}
+	return fields, nil
+}
+
+// MarshalSSZ encodes the header in SSZ. The block number must fit in 64 bits.
+func (h *Header) MarshalSSZ() ([]byte, error) {
+	enc := &sszEncoder{fixed: make([]byte, 0, sszHeaderSize+len(h.Extra))}
+	enc.bytes(h.ParentHash[:])
+	enc.bytes(h.UncleHash[:])
+	enc.bytes(h.Coinbase[:])
+	enc.bytes(h.Root[:])
+	enc.bytes(h.TxHash[:])
+	enc.bytes(h.ReceiptHash[:])
+	enc.bytes(h.Bloom[:])
+	enc.uint256(h.Difficulty)
+	var number uint64
+	if h.Number != nil {
+		switch {
+		case h.Number.Sign() < 0:
+			return nil, errSSZNegative
+		case !h.Number.IsUint64():
+			return nil, errSSZUint64
+		}
+		number = h.Number.Uint64()
+	}
+	enc.uint64(number)
+	enc.uint64(h.GasLimit)
+	enc.uint64(h.GasUsed)
+	enc.uint64(h.Time)
+	enc.dynamic(h.Extra)
+	enc.bytes(h.MixDigest[:])
+	enc.bytes(h.Nonce[:])
+	return enc.finish()
+}
+
+// UnmarshalSSZ decodes an SSZ encoded header.
+func (h *Header) UnmarshalSSZ(b []byte) error {
+	var (
+		dec = &sszDecoder{buf: b}
+		dh  Header
+	)
+	copy(dh.ParentHash[:], dec.bytes(common.HashLength))
+	copy(dh.UncleHash[:], dec.bytes(common.HashLength))
+	copy(dh.Coinbase[:], dec.bytes(common.AddressLength))
+	copy(dh.Root[:], dec.bytes(common.HashLength))
+	copy(dh.TxHash[:], dec.bytes(common.HashLength))
+	copy(dh.ReceiptHash[:], dec.bytes(common.HashLength))
+	copy(dh.Bloom[:], dec.bytes(BloomByteLength))
+	dh.Difficulty = dec.uint256()
+	dh.Number = new(big.Int).SetUint64(dec.uint64())
+	dh.GasLimit = dec.uint64()
+	dh.GasUsed = dec.uint64()
+	dh.Time = dec.uint64()
+	dec.dynamic()
+	copy(dh.MixDigest[:], dec.bytes(common.HashLength))
+	copy(dh.Nonce[:], dec.bytes(8))
+
+	fields, err := dec.finish()
+	if err != nil {
+		return err
+	}
+	dh.Extra = common.CopyBytes(fields[0])
+	*h = dh
+	return nil
+}
+
+// MarshalSSZ encodes the transaction in SSZ, as a union of the transaction types
+// selected by the type byte. The sidecar of the transaction is not encoded.
+func (tx *Transaction) MarshalSSZ() ([]byte, error) {
+	var (
+		enc = new(sszEncoder)
+		sig = func(v, r, s *big.Int) {
+			enc.uint256(v)
+			enc.uint256(r)
+			enc.uint256(s)
+		}
+	)
+	switch inner := tx.inner.(type) {
+	case *LegacyTx:
+		enc.uint64(inner.Nonce)
+		enc.uint256(inner.GasPrice)
+		enc.uint64(inner.Gas)
+		enc.dynamic(sszEncodeRecipient(inner.To))
+		enc.uint256(inner.Value)
+		enc.dynamic(inner.Data)
+		sig(inner.V, inner.R, inner.S)
+	case *AccessListTx:
+		enc.uint256(inner.ChainID)
+		enc.uint64(inner.Nonce)
+		enc.uint256(inner.GasPrice)
+		enc.uint64(inner.Gas)
+		enc.dynamic(sszEncodeRecipient(inner.To))
+		enc.uint256(inner.Value)
+		enc.dynamic(inner.Data)
+		enc.dynamic(sszEncodeAccessList(inner.AccessList))
+		sig(inner.V, inner.R, inner.S)
+	case *SidecarTx:
+		enc.uint256(inner.ChainID)
+		enc.uint64(inner.Nonce)
+		enc.uint256(inner.GasPrice)
+		enc.uint64(inner.Gas)
+		enc.dynamic(sszEncodeRecipient(inner.To))
+		enc.uint256(inner.Value)
+		enc.dynamic(inner.Data)
+		enc.dynamic(sszEncodeAccessList(inner.AccessList))
+		enc.dynamic(sszEncodeHashes(inner.BlobHashes))
+		sig(inner.V, inner.R, inner.S)
+	default:
+		return nil, ErrTxTypeNotSupported
+	}
+	body, err := enc.finish()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.Type()}, body...), nil
+}
+
+// UnmarshalSSZ decodes an SSZ encoded transaction.
+func (tx *Transaction) UnmarshalSSZ(b []byte) error {
+	if len(b) == 0 {
+		return errSSZEmptyTx
+	}
+	var (
+		dec   = &sszDecoder{buf: b[1:]}
+		inner TxData
+		sig   = func() (v, r, s *big.Int) {
+			return dec.uint256(), dec.uint256(), dec.uint256()
+		}
+	)
+	switch b[0] {
+	case LegacyTxType:
+		var itx LegacyTx
+		itx.Nonce = dec.uint64()
+		itx.GasPrice = dec.uint256()
+		itx.Gas = dec.uint64()
+		dec.dynamic()
+		itx.Value = dec.uint256()
+		dec.dynamic()
+		itx.V, itx.R, itx.S = sig()
+
+		fields, err := dec.finish()
+		if err != nil {
+			return err
+		}
+		if itx.To, err = sszDecodeRecipient(fields[0]); err != nil {
+			return err
+		}
+		itx.Data = common.CopyBytes(fields[1])
+		inner = &itx
+
+	case AccessListTxType:
+		var itx AccessListTx
+		itx.ChainID = dec.uint256()
+		itx.Nonce = dec.uint64()
+		itx.GasPrice = dec.uint256()
+		itx.Gas = dec.uint64()
+		dec.dynamic()
+		itx.Value = dec.uint256()
+		dec.dynamic()
+		dec.dynamic()
+		itx.V, itx.R, itx.S = sig()
+
+		fields, err := dec.finish()
+		if err != nil {
+			return err
+		}
+		if itx.To, err = sszDecodeRecipient(fields[0]); err != nil {
+			return err
+		}
+		itx.Data = common.CopyBytes(fields[1])
+		if itx.AccessList, err = sszDecodeAccessList(fields[2]); err != nil {
+			return err
+		}
+		inner = &itx
+
+	case SidecarTxType:
+		var itx SidecarTx
+		itx.ChainID = dec.uint256()
+		itx.Nonce = dec.uint64()
+		itx.GasPrice = dec.uint256()
+		itx.Gas = dec.uint64()
+		dec.dynamic()
+		itx.Value = dec.uint256()
+		dec.dynamic()
+		dec.dynamic()
+		dec.dynamic()
+		itx.V, itx.R, itx.S = sig()
+
+		fields, err := dec.finish()
+		if err != nil {
+			return err
+		}
+		if itx.To, err = sszDecodeRecipient(fields[0]); err != nil {
+			return err
+		}
+		itx.Data = common.CopyBytes(fields[1])
+		if itx.AccessList, err = sszDecodeAccessList(fields[2]); err != nil {
+			return err
+		}
+		if itx.BlobHashes, err = sszDecodeHashes(fields[3]); err != nil {
+			return err
+		}
+		inner = &itx
+
+	default:
+		return fmt.Errorf("%w: %d", errSSZUnknownType, b[0])
+	}
+	tx.setDecoded(inner, 0)
+	return nil
+}
+
+// sszEncodeRecipient encodes an optional recipient as a list of at most one address.
+func sszEncodeRecipient(to *common.Address) []byte {
+	if to == nil {
+		return nil
+	}
+	return to.Bytes()
+}
+
+func sszDecodeRecipient(b []byte) (*common.Address, error) {
+	switch len(b) {
+	case 0:
+		return nil, nil
+	case common.AddressLength:
+		to := common.BytesToAddress(b)
+		return &to, nil
+	default:
+		return nil, errSSZOptional
+	}
+}
+
+func sszEncodeHashes(hashes []common.Hash) []byte {
+	b := make([]byte, 0, len(hashes)*common.HashLength)
+	for _, hash := range hashes {
+		b = append(b, hash[:]...)
+	}
+	return b
+}
+
+func sszDecodeHashes(b []byte) ([]common.Hash, error) {
+	if len(b)%common.HashLength != 0 {
+		return nil, errSSZListSize
+	}
+	var hashes []common.Hash
+	for i := 0; i < len(b); i += common.HashLength {
+		hashes = append(hashes, common.BytesToHash(b[i:i+common.HashLength]))
+	}
+	return hashes, nil
+}
+
+// sszEncodeAccessList encodes an access list as a list of variable size containers,
+// the tuples being preceded by their offsets.
+func sszEncodeAccessList(al AccessList) []byte {
+	enc := new(sszEncoder)
+	for _, tuple := range al {
+		item := new(sszEncoder)
+		item.bytes(tuple.Address[:])
+		item.dynamic(sszEncodeHashes(tuple.StorageKeys))
+		b, _ := item.finish()
+		enc.dynamic(b)
+	}
+	b, _ := enc.finish()
+	return b
+}
+
+func sszDecodeAccessList(b []byte) (AccessList, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) < sszOffsetSize {
+		return nil, errSSZShort
+	}
+	first := int(binary.LittleEndian.Uint32(b))
+	if first == 0 || first%sszOffsetSize != 0 || first > len(b) {
+		return nil, errSSZOffset
+	}
+	dec := &sszDecoder{buf: b}
+	for i := 0; i < first/sszOffsetSize; i++ {
+		dec.dynamic()
+	}
+	items, err := dec.finish()
+	if err != nil {
+		return nil, err
+	}
+	al := make(AccessList, len(items))
+	for i, item := range items {
+		dec := &sszDecoder{buf: item}
+		copy(al[i].Address[:], dec.bytes(common.AddressLength))
+		dec.dynamic()
+		fields, err := dec.finish()
+		if err != nil {
+			return nil, err
+		}
+		if al[i].StorageKeys, err = sszDecodeHashes(fields[0]); err != nil {
+			return nil, err
+		}
+	}
+	return al, nil
+}
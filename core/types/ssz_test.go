@@ -0,0 +1,183 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/rlp"
+)
+
+func TestHeaderSSZ(t *testing.T) {
+	var block Block
+	blockEnc := common.FromHex("f90260f901f9a083cafc574e1f51ba9dc0568fc617a08ea2429fb384059c972f13b19fa1c8dd55a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347948888f1f195afa192cfee860698584c030f4c9db1a0ef1552a40b7165c3cd773806b9e0c165b75356e0314bf0706f279c729f51e017a05fe50b260da6308036625b850b5d6ced6d0a9f814c0688bc91ffb7b7a3a54b67a0bc37d79753ad738a6dac4921e57392f145d8887476de3f783dfa7edae9283e52b90100000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000008302000001832fefd8825208845506eb0780a0bd4472abb6659ebe3ee06ee4d7b72a00a9f4d001caca51342001075469aff49888a13a5a8c8f2bb1c4f861f85f800a82c35094095e7baea6a6c7c4c2dfeb977efac326af552d870a801ba09bea4c4daac7c7c52e093e6a4c35dbbcf8856f1af7b059ba20253e70848d094fa08a8fae537ce25ed8cb5af9adac3f141af69bd515bd2ba031522df09b97dd72b1c0")
+	if err := rlp.DecodeBytes(blockEnc, &block); err != nil {
+		t.Fatal("decode error:", err)
+	}
+	headers := []*Header{
+		block.Header(),
+		{Extra: []byte("extra data of the header"), Difficulty: new(big.Int).Lsh(common.Big1, 255)},
+		{}, // nil big integers are encoded as zero
+	}
+	for i, header := range headers {
+		enc, err := header.MarshalSSZ()
+		if err != nil {
+			t.Fatalf("header %d: encode error: %v", i, err)
+		}
+		if len(enc) != sszHeaderSize+len(header.Extra) {
+			t.Errorf("header %d: encoding size mismatch: have %d, want %d", i, len(enc), sszHeaderSize+len(header.Extra))
+		}
+		var dec Header
+		if err := dec.UnmarshalSSZ(enc); err != nil {
+			t.Fatalf("header %d: decode error: %v", i, err)
+		}
+		if dec.Hash() != header.Hash() {
+			t.Errorf("header %d: hash mismatch: have %x, want %x", i, dec.Hash(), header.Hash())
+		}
+	}
+	if _, err := (&Header{Number: new(big.Int).Lsh(common.Big1, 64)}).MarshalSSZ(); err != errSSZUint64 {
+		t.Errorf("oversized number error mismatch: have %v, want %v", err, errSSZUint64)
+	}
+	if _, err := (&Header{Difficulty: big.NewInt(-1)}).MarshalSSZ(); err != errSSZNegative {
+		t.Errorf("negative difficulty error mismatch: have %v, want %v", err, errSSZNegative)
+	}
+}
+
+func TestTransactionSSZ(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewSidecarSigner(big.NewInt(1))
+
+	accessList := AccessList{
+		{Address: testAddr, StorageKeys: []common.Hash{{1}, {2}}},
+		{Address: common.Address{3}},
+	}
+	txs := []*Transaction{
+		emptyTx,
+		rightvrsTx,
+		signedEip2718Tx,
+		MustSignNewTx(key, signer, &LegacyTx{Nonce: 1, Gas: 53000, GasPrice: big.NewInt(1), Data: []byte{0xde, 0xad}}),
+		MustSignNewTx(key, signer, &AccessListTx{ChainID: big.NewInt(1), Nonce: 2, To: &testAddr, Gas: 30000, GasPrice: big.NewInt(1), AccessList: accessList}),
+		MustSignNewTx(key, signer, &SidecarTx{ChainID: big.NewInt(1), Nonce: 3, To: &testAddr, Gas: 30000, GasPrice: big.NewInt(1), AccessList: accessList, BlobHashes: []common.Hash{{4}, {5}}}),
+	}
+	for i, tx := range txs {
+		enc, err := tx.MarshalSSZ()
+		if err != nil {
+			t.Fatalf("tx %d: encode error: %v", i, err)
+		}
+		if enc[0] != tx.Type() {
+			t.Errorf("tx %d: union selector mismatch: have %d, want %d", i, enc[0], tx.Type())
+		}
+		var dec Transaction
+		if err := dec.UnmarshalSSZ(enc); err != nil {
+			t.Fatalf("tx %d: decode error: %v", i, err)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("tx %d: hash mismatch: have %x, want %x", i, dec.Hash(), tx.Hash())
+		}
+		reenc, err := dec.MarshalSSZ()
+		if err != nil {
+			t.Fatalf("tx %d: re-encode error: %v", i, err)
+		}
+		if !bytes.Equal(reenc, enc) {
+			t.Errorf("tx %d: re-encoding mismatch:\nhave %x\nwant %x", i, reenc, enc)
+		}
+	}
+}
+
+func TestTransactionSSZInvalid(t *testing.T) {
+	valid, err := signedEip2718Tx.MarshalSSZ()
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+	// The recipient offset follows the chain ID, nonce, gas price and gas
+	recipient := 1 + 32 + 8 + 32 + 8
+
+	badSelector := common.CopyBytes(valid)
+	badSelector[0] = 0x7f
+	badOffset := common.CopyBytes(valid)
+	badOffset[recipient]++
+	badRecipient := common.CopyBytes(valid)
+	badRecipient[recipient+sszOffsetSize+32]-- // first byte of the data offset
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"selector":  badSelector,
+		"short":     valid[:len(valid)/2],
+		"offset":    badOffset,
+		"recipient": badRecipient,
+	}
+	for name, enc := range tests {
+		var tx Transaction
+		if err := tx.UnmarshalSSZ(enc); err == nil {
+			t.Errorf("%s: invalid encoding decoded", name)
+		}
+	}
+}
+
+// Tests that any input decoding successfully re-encodes to the same bytes, such
+// that the encoding is canonical.
+func TestSSZCanonical(t *testing.T) {
+	header, err := (&Header{Extra: []byte{1, 2, 3}, Number: big.NewInt(5)}).MarshalSSZ()
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+	tx, err := signedEip2718Tx.MarshalSSZ()
+	if err != nil {
+		t.Fatal("encode error:", err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		var input []byte
+		if i%2 == 0 {
+			input = common.CopyBytes(header)
+		} else {
+			input = common.CopyBytes(tx)
+		}
+		input[rnd.Intn(len(input))] = byte(rnd.Intn(256))
+		if rnd.Intn(4) == 0 {
+			input = input[:rnd.Intn(len(input))]
+		}
+		var (
+			reenc []byte
+			err   error
+		)
+		if i%2 == 0 {
+			var h Header
+			if h.UnmarshalSSZ(input) != nil {
+				continue
+			}
+			reenc, err = h.MarshalSSZ()
+		} else {
+			var tx Transaction
+			if tx.UnmarshalSSZ(input) != nil {
+				continue
+			}
+			reenc, err = tx.MarshalSSZ()
+		}
+		if err != nil {
+			t.Fatalf("input %x: re-encode error: %v", input, err)
+		}
+		if !bytes.Equal(reenc, input) {
+			t.Fatalf("re-encoding mismatch:\nhave %x\nwant %x", reenc, input)
+		}
+	}
+}
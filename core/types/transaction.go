@@ -44,10 +44,16 @@ const (
 	AccessListTxType
 )
 
+// SidecarTxType is the type of the experimental sidecar transactions. It is taken
+// from the top of the EIP-2718 range, away from the type bytes allocated by EIPs,
+// so that the private encoding never collides with a standardised transaction.
+const SidecarTxType = 0x7a
+
 // Transaction is an Acent transaction.
 type Transaction struct {
-	inner TxData    // Consensus contents of a transaction
-	time  time.Time // Time first seen locally (spam avoidance)
+	inner   TxData    // Consensus contents of a transaction
+	sidecar *Sidecar  // Data committed to by a sidecar transaction, not part of the consensus encoding
+	time    time.Time // Time first seen locally (spam avoidance)
 
 	// caches
 	hash atomic.Value
@@ -64,7 +70,7 @@ func NewTx(inner TxData) *Transaction {
 
 // TxData is the underlying data of a transaction.
 //
-// This is implemented by LegacyTx and AccessListTx.
+// This is implemented by LegacyTx, AccessListTx and SidecarTx.
 type TxData interface {
 	txType() byte // returns the type ID
 	copy() TxData // creates a deep copy and initializes all fields
@@ -91,7 +97,7 @@ func (tx *Transaction) EncodeRLP(w io.Writer) error {
 	buf := encodeBufferPool.Get().(*bytes.Buffer)
 	defer encodeBufferPool.Put(buf)
 	buf.Reset()
-	if err := tx.encodeTyped(buf); err != nil {
+	if err := tx.encodeEnvelope(buf); err != nil {
 		return err
 	}
 	return rlp.Encode(w, buf.Bytes())
@@ -103,15 +109,26 @@ func (tx *Transaction) encodeTyped(w *bytes.Buffer) error {
 	return rlp.Encode(w, tx.inner)
 }
 
+// encodeEnvelope writes the encoding of a typed transaction to w, wrapped along
+// with its sidecar if one is attached.
+func (tx *Transaction) encodeEnvelope(w *bytes.Buffer) error {
+	if tx.sidecar == nil {
+		return tx.encodeTyped(w)
+	}
+	w.WriteByte(tx.Type())
+	return rlp.Encode(w, &sidecarTxEnvelope{Tx: tx.inner.(*SidecarTx), Blobs: tx.sidecar.Blobs})
+}
+
 // MarshalBinary returns the canonical encoding of the transaction.
 // For legacy transactions, it returns the RLP encoding. For EIP-2718 typed
-// transactions, it returns the type and payload.
+// transactions, it returns the type and payload, wrapped along with the sidecar
+// of sidecar transactions if attached.
 func (tx *Transaction) MarshalBinary() ([]byte, error) {
 	if tx.Type() == LegacyTxType {
 		return rlp.EncodeToBytes(tx.inner)
 	}
 	var buf bytes.Buffer
-	err := tx.encodeTyped(&buf)
+	err := tx.encodeEnvelope(&buf)
 	return buf.Bytes(), err
 }
 
@@ -122,22 +139,31 @@ func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
 	case err != nil:
 		return err
 	case kind == rlp.List:
-		// It's a legacy transaction.
+		// It's a legacy transaction, hashed from its raw encoding.
+		raw, err := s.Raw()
+		if err != nil {
+			return err
+		}
 		var inner LegacyTx
-		err := s.Decode(&inner)
-		if err == nil {
-			tx.setDecoded(&inner, int(rlp.ListSize(size)))
+		if err := rlp.DecodeBytes(raw, &inner); err != nil {
+			return err
 		}
-		return err
+		tx.setDecoded(&inner, int(rlp.ListSize(size)))
+		tx.hash.Store(crypto.Keccak256Hash(raw))
+		return nil
 	case kind == rlp.String:
 		// It's an EIP-2718 typed TX envelope.
 		var b []byte
 		if b, err = s.Bytes(); err != nil {
 			return err
 		}
-		inner, err := tx.decodeTyped(b)
+		inner, sidecar, err := tx.decodeTyped(b)
 		if err == nil {
 			tx.setDecoded(inner, len(b))
+			tx.sidecar = sidecar
+			if sidecar == nil {
+				tx.hash.Store(crypto.Keccak256Hash(b))
+			}
 		}
 		return err
 	default:
@@ -156,29 +182,37 @@ func (tx *Transaction) UnmarshalBinary(b []byte) error {
 			return err
 		}
 		tx.setDecoded(&data, len(b))
+		tx.hash.Store(crypto.Keccak256Hash(b))
 		return nil
 	}
 	// It's an EIP2718 typed transaction envelope.
-	inner, err := tx.decodeTyped(b)
+	inner, sidecar, err := tx.decodeTyped(b)
 	if err != nil {
 		return err
 	}
 	tx.setDecoded(inner, len(b))
+	tx.sidecar = sidecar
+	if sidecar == nil {
+		tx.hash.Store(crypto.Keccak256Hash(b))
+	}
 	return nil
 }
 
-// decodeTyped decodes a typed transaction from the canonical format.
-func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
+// decodeTyped decodes a typed transaction from the canonical format, along with
+// the sidecar of a sidecar transaction if wrapped with it.
+func (tx *Transaction) decodeTyped(b []byte) (TxData, *Sidecar, error) {
 	if len(b) == 0 {
-		return nil, errEmptyTypedTx
+		return nil, nil, errEmptyTypedTx
 	}
 	switch b[0] {
 	case AccessListTxType:
 		var inner AccessListTx
 		err := rlp.DecodeBytes(b[1:], &inner)
-		return &inner, err
+		return &inner, nil, err
+	case SidecarTxType:
+		return decodeSidecarTx(b[1:])
 	default:
-		return nil, ErrTxTypeNotSupported
+		return nil, nil, ErrTxTypeNotSupported
 	}
 }
 
@@ -291,6 +325,11 @@ func (tx *Transaction) RawSignatureValues() (v, r, s *big.Int) {
 	return tx.inner.rawSignatureValues()
 }
 
+// Time returns the time the transaction was first seen locally.
+func (tx *Transaction) Time() time.Time {
+	return tx.time
+}
+
 // GasPriceCmp compares the gas prices of two transactions.
 func (tx *Transaction) GasPriceCmp(other *Transaction) int {
 	return tx.inner.gasPrice().Cmp(other.inner.gasPrice())
@@ -317,14 +356,38 @@ func (tx *Transaction) Hash() common.Hash {
 	return h
 }
 
-// Size returns the true RLP encoded storage size of the transaction, either by
-// encoding and returning it, or returning a previously cached value.
+// CachedHash returns the transaction hash if it was already computed, either
+// during decoding or by a previous call to Hash, without computing it.
+func (tx *Transaction) CachedHash() (common.Hash, bool) {
+	if hash := tx.hash.Load(); hash != nil {
+		return hash.(common.Hash), true
+	}
+	return common.Hash{}, false
+}
+
+// CachedSize returns the encoded size of the transaction if it was already
+// computed, either during decoding or by a previous call to Size, without
+// computing it.
+func (tx *Transaction) CachedSize() (common.StorageSize, bool) {
+	if size := tx.size.Load(); size != nil {
+		return size.(common.StorageSize), true
+	}
+	return 0, false
+}
+
+// Size returns the true RLP encoded storage size of the transaction, including
+// its sidecar if attached, either by encoding and returning it, or returning a
+// previously cached value.
 func (tx *Transaction) Size() common.StorageSize {
 	if size := tx.size.Load(); size != nil {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.inner)
+	if tx.sidecar != nil {
+		rlp.Encode(&c, &sidecarTxEnvelope{Tx: tx.inner.(*SidecarTx), Blobs: tx.sidecar.Blobs})
+	} else {
+		rlp.Encode(&c, &tx.inner)
+	}
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -338,7 +401,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	}
 	cpy := tx.inner.copy()
 	cpy.setSignatureValues(signer.ChainID(), v, r, s)
-	return &Transaction{inner: cpy, time: tx.time}, nil
+	return &Transaction{inner: cpy, sidecar: tx.sidecar, time: tx.time}, nil
 }
 
 // Transactions implements DerivableList for transactions.
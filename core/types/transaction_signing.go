@@ -40,6 +40,8 @@ type sigCache struct {
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	var signer Signer
 	switch {
+	case config.IsSidecar(blockNumber):
+		signer = NewSidecarSigner(config.ChainID)
 	case config.IsBerlin(blockNumber):
 		signer = NewEIP2930Signer(config.ChainID)
 	case config.IsEIP155(blockNumber):
@@ -61,6 +63,9 @@ func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 // have the current block number available, use MakeSigner instead.
 func LatestSigner(config *params.ChainConfig) Signer {
 	if config.ChainID != nil {
+		if config.SidecarBlock != nil {
+			return NewSidecarSigner(config.ChainID)
+		}
 		if config.BerlinBlock != nil || config.YoloV3Block != nil {
 			return NewEIP2930Signer(config.ChainID)
 		}
@@ -82,7 +87,7 @@ func LatestSignerForChainID(chainID *big.Int) Signer {
 	if chainID == nil {
 		return HomesteadSigner{}
 	}
-	return NewEIP2930Signer(chainID)
+	return NewSidecarSigner(chainID)
 }
 
 // SignTx signs the transaction using the given signer and private key.
@@ -142,6 +147,18 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	return addr, nil
 }
 
+// CachedSender returns the sender of the transaction if it was already derived
+// with the given signer, without recovering it from the signature.
+func CachedSender(signer Signer, tx *Transaction) (common.Address, bool) {
+	if sc := tx.from.Load(); sc != nil {
+		sigCache := sc.(sigCache)
+		if sigCache.signer.Equal(signer) {
+			return sigCache.from, true
+		}
+	}
+	return common.Address{}, false
+}
+
 // Signer encapsulates transaction signature handling. The name of this type is slightly
 // misleading because Signers don't actually sign, they're just for validating and
 // processing of signatures.
@@ -165,6 +182,70 @@ type Signer interface {
 	Equal(Signer) bool
 }
 
+type sidecarSigner struct{ eip2930Signer }
+
+// NewSidecarSigner returns a signer that accepts experimental sidecar transactions,
+// EIP-2930 access list transactions, EIP-155 replay protected transactions, and
+// legacy Homestead transactions.
+func NewSidecarSigner(chainId *big.Int) Signer {
+	return sidecarSigner{eip2930Signer{NewEIP155Signer(chainId)}}
+}
+
+func (s sidecarSigner) Equal(s2 Signer) bool {
+	x, ok := s2.(sidecarSigner)
+	return ok && x.chainId.Cmp(s.chainId) == 0
+}
+
+func (s sidecarSigner) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != SidecarTxType {
+		return s.eip2930Signer.Sender(tx)
+	}
+	V, R, S := tx.RawSignatureValues()
+	// Sidecar txs are defined to use 0 and 1 as their recovery
+	// id, add 27 to become equivalent to unprotected Homestead signatures.
+	V = new(big.Int).Add(V, big.NewInt(27))
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	return recoverPlain(s.Hash(tx), R, S, V, true)
+}
+
+func (s sidecarSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	txdata, ok := tx.inner.(*SidecarTx)
+	if !ok {
+		return s.eip2930Signer.SignatureValues(tx, sig)
+	}
+	// Check that chain ID of tx matches the signer. We also accept ID zero here,
+	// because it indicates that the chain ID was not specified in the tx.
+	if txdata.ChainID.Sign() != 0 && txdata.ChainID.Cmp(s.chainId) != 0 {
+		return nil, nil, nil, ErrInvalidChainId
+	}
+	R, S, _ = decodeSignature(sig)
+	V = big.NewInt(int64(sig[64]))
+	return R, S, V, nil
+}
+
+// Hash returns the hash to be signed by the sender.
+// It does not uniquely identify the transaction.
+func (s sidecarSigner) Hash(tx *Transaction) common.Hash {
+	if tx.Type() != SidecarTxType {
+		return s.eip2930Signer.Hash(tx)
+	}
+	return prefixedRlpHash(
+		tx.Type(),
+		[]interface{}{
+			s.chainId,
+			tx.Nonce(),
+			tx.GasPrice(),
+			tx.Gas(),
+			tx.To(),
+			tx.Value(),
+			tx.Data(),
+			tx.AccessList(),
+			tx.BlobHashes(),
+		})
+}
+
 type eip2930Signer struct{ EIP155Signer }
 
 // NewEIP2930Signer returns a signer that accepts EIP-2930 access list transactions,
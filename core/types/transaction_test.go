@@ -441,6 +441,147 @@ func TestTransactionCoding(t *testing.T) {
 	}
 }
 
+// Tests that decoding populates the size and hash caches from the encoding, and
+// that the cached accessors don't compute missing values.
+func TestTransactionCaches(t *testing.T) {
+	key, _ := defaultTestKey()
+	legacyTx, err := SignTx(NewTransaction(0, testAddr, big.NewInt(10), 21000, big.NewInt(1), nil), HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	signers := []Signer{HomesteadSigner{}, NewEIP2930Signer(big.NewInt(1))}
+	for i, tx := range []*Transaction{legacyTx, signedEip2718Tx} {
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatalf("tx %d: failed to encode: %v", i, err)
+		}
+		bin, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("tx %d: failed to marshal: %v", i, err)
+		}
+		var fromRLP, fromBinary Transaction
+		if err := rlp.DecodeBytes(enc, &fromRLP); err != nil {
+			t.Fatalf("tx %d: failed to decode: %v", i, err)
+		}
+		if err := fromBinary.UnmarshalBinary(bin); err != nil {
+			t.Fatalf("tx %d: failed to unmarshal: %v", i, err)
+		}
+		for _, decoded := range []*Transaction{&fromRLP, &fromBinary} {
+			if hash, ok := decoded.CachedHash(); !ok || hash != tx.Hash() {
+				t.Errorf("tx %d: cached hash mismatch: have %x (%v), want %x", i, hash, ok, tx.Hash())
+			}
+			if size, ok := decoded.CachedSize(); !ok || size != common.StorageSize(len(bin)) {
+				t.Errorf("tx %d: cached size mismatch: have %v (%v), want %v", i, size, ok, len(bin))
+			}
+		}
+		signer := signers[i]
+		if _, ok := CachedSender(signer, &fromRLP); ok {
+			t.Errorf("tx %d: sender cached before recovery", i)
+		}
+		from, err := Sender(signer, &fromRLP)
+		if err != nil {
+			t.Fatalf("tx %d: failed to recover sender: %v", i, err)
+		}
+		if cached, ok := CachedSender(signer, &fromRLP); !ok || cached != from {
+			t.Errorf("tx %d: cached sender mismatch: have %x (%v), want %x", i, cached, ok, from)
+		}
+		if _, ok := CachedSender(NewEIP155Signer(big.NewInt(1)), &fromRLP); ok {
+			t.Errorf("tx %d: sender cached for a different signer", i)
+		}
+	}
+	// Locally created transactions have nothing cached until first use
+	tx := NewTx(&LegacyTx{Nonce: 1, GasPrice: big.NewInt(1)})
+	if _, ok := tx.CachedHash(); ok {
+		t.Errorf("hash cached before first use")
+	}
+	if _, ok := tx.CachedSize(); ok {
+		t.Errorf("size cached before first use")
+	}
+	tx.Hash()
+	if hash, ok := tx.CachedHash(); !ok || hash != tx.Hash() {
+		t.Errorf("hash not cached after first use")
+	}
+}
+
+// Tests that sidecar transactions are encoded along with their sidecars for the
+// network, while their hashes, signatures and block inclusion only depend on the
+// blob hashes.
+func TestSidecarTransaction(t *testing.T) {
+	key, _ := defaultTestKey()
+	signer := NewSidecarSigner(big.NewInt(1))
+
+	sidecar := &Sidecar{Blobs: [][]byte{[]byte("blob 1"), []byte("blob 2")}}
+	tx, err := SignNewTx(key, signer, &SidecarTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      1,
+		To:         &testAddr,
+		Gas:        25000,
+		GasPrice:   big.NewInt(1),
+		BlobHashes: sidecar.Hashes(),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := tx.VerifySidecar(); err != ErrSidecarMissing {
+		t.Fatalf("error mismatch without sidecar: have %v, want %v", err, ErrSidecarMissing)
+	}
+	wrapped := tx.WithSidecar(sidecar)
+	if err := wrapped.VerifySidecar(); err != nil {
+		t.Fatalf("failed to verify sidecar: %v", err)
+	}
+	if err := tx.WithSidecar(&Sidecar{Blobs: [][]byte{[]byte("blob 2"), []byte("blob 1")}}).VerifySidecar(); err != ErrSidecarMismatch {
+		t.Fatalf("error mismatch for swapped blobs: have %v, want %v", err, ErrSidecarMismatch)
+	}
+	if wrapped.Size() <= tx.Size() {
+		t.Errorf("sidecar not accounted in size: have %v, want above %v", wrapped.Size(), tx.Size())
+	}
+	// Ensure the sidecar survives the network encodings, but not the consensus one
+	bin, err := wrapped.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal transaction: %v", err)
+	}
+	enc, err := rlp.EncodeToBytes(wrapped)
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+	var fromBinary, fromRLP Transaction
+	if err := fromBinary.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("failed to unmarshal transaction: %v", err)
+	}
+	if err := rlp.DecodeBytes(enc, &fromRLP); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	for _, decoded := range []*Transaction{&fromBinary, &fromRLP} {
+		if decoded.Hash() != tx.Hash() {
+			t.Errorf("hash mismatch: have %x, want %x", decoded.Hash(), tx.Hash())
+		}
+		if !reflect.DeepEqual(decoded.Sidecar(), sidecar) {
+			t.Errorf("sidecar mismatch: have %v, want %v", decoded.Sidecar(), sidecar)
+		}
+		if from, err := Sender(signer, decoded); err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Errorf("sender mismatch: have %x (%v), want %x", from, err, crypto.PubkeyToAddress(key.PublicKey))
+		}
+	}
+	if DeriveSha(Transactions{wrapped}, newHasher()) != DeriveSha(Transactions{tx}, newHasher()) {
+		t.Errorf("sidecar included in the transaction root")
+	}
+	block := NewBlock(&Header{Number: big.NewInt(1)}, []*Transaction{wrapped}, nil, nil, newHasher())
+	if block.Transactions()[0].Sidecar() != nil {
+		t.Errorf("sidecar included in block")
+	}
+	// Sidecar transactions are only supported once their signer is used
+	if _, err := Sender(NewEIP2930Signer(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("pre-fork sender error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	parsed, err := encodeDecodeJSON(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Hash() != tx.Hash() || !reflect.DeepEqual(parsed.BlobHashes(), tx.BlobHashes()) {
+		t.Errorf("JSON round trip mismatch: have %x, want %x", parsed.Hash(), tx.Hash())
+	}
+}
+
 func encodeDecodeJSON(tx *Transaction) (*Transaction, error) {
 	data, err := json.Marshal(tx)
 	if err != nil {
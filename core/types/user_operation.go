@@ -0,0 +1,101 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/common/math"
+	"github.com/acent/go-acent/crypto"
+)
+
+//go:generate gencodec -type UserOperation -field-override userOperationMarshaling -out gen_user_operation_json.go
+
+// UserOperation is an ERC-4337 user operation: a transaction-like request of a
+// smart contract account, executed by an entry point contract as part of a
+// bundle submitted by a bundler.
+type UserOperation struct {
+	Sender               common.Address `json:"sender" gencodec:"required"`
+	Nonce                *big.Int       `json:"nonce" gencodec:"required"`
+	InitCode             []byte         `json:"initCode" gencodec:"required"`
+	CallData             []byte         `json:"callData" gencodec:"required"`
+	CallGasLimit         *big.Int       `json:"callGasLimit" gencodec:"required"`
+	VerificationGasLimit *big.Int       `json:"verificationGasLimit" gencodec:"required"`
+	PreVerificationGas   *big.Int       `json:"preVerificationGas" gencodec:"required"`
+	MaxFeePerGas         *big.Int       `json:"maxFeePerGas" gencodec:"required"`
+	MaxPriorityFeePerGas *big.Int       `json:"maxPriorityFeePerGas" gencodec:"required"`
+	PaymasterAndData     []byte         `json:"paymasterAndData" gencodec:"required"`
+	Signature            []byte         `json:"signature" gencodec:"required"`
+}
+
+type userOperationMarshaling struct {
+	Nonce                *hexutil.Big
+	InitCode             hexutil.Bytes
+	CallData             hexutil.Bytes
+	CallGasLimit         *hexutil.Big
+	VerificationGasLimit *hexutil.Big
+	PreVerificationGas   *hexutil.Big
+	MaxFeePerGas         *hexutil.Big
+	MaxPriorityFeePerGas *hexutil.Big
+	PaymasterAndData     hexutil.Bytes
+	Signature            hexutil.Bytes
+}
+
+// Hash returns the hash identifying the user operation, as computed by the given
+// entry point on the given chain. The signature is not part of the hash.
+func (op *UserOperation) Hash(entryPoint common.Address, chainID *big.Int) common.Hash {
+	packed := make([]byte, 0, 10*32)
+	packed = append(packed, common.LeftPadBytes(op.Sender.Bytes(), 32)...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.Nonce))...)
+	packed = append(packed, crypto.Keccak256(op.InitCode)...)
+	packed = append(packed, crypto.Keccak256(op.CallData)...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.CallGasLimit))...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.VerificationGasLimit))...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.PreVerificationGas))...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.MaxFeePerGas))...)
+	packed = append(packed, math.U256Bytes(new(big.Int).Set(op.MaxPriorityFeePerGas))...)
+	packed = append(packed, crypto.Keccak256(op.PaymasterAndData)...)
+
+	return crypto.Keccak256Hash(
+		crypto.Keccak256(packed),
+		common.LeftPadBytes(entryPoint.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(chainID)),
+	)
+}
+
+// Factory returns the address of the factory deploying the sender account, or
+// nil if the account already exists.
+func (op *UserOperation) Factory() *common.Address {
+	return entityAddress(op.InitCode)
+}
+
+// Paymaster returns the address of the paymaster sponsoring the operation, or
+// nil if the sender pays for itself.
+func (op *UserOperation) Paymaster() *common.Address {
+	return entityAddress(op.PaymasterAndData)
+}
+
+// entityAddress returns the address prefixing a factory or paymaster field.
+func entityAddress(field []byte) *common.Address {
+	if len(field) < common.AddressLength {
+		return nil
+	}
+	addr := common.BytesToAddress(field[:common.AddressLength])
+	return &addr
+}
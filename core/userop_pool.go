@@ -0,0 +1,610 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/event"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/metrics"
+	"github.com/acent/go-acent/params"
+)
+
+const (
+	// chainEventChanSize is the size of channel listening to ChainEvent.
+	chainEventChanSize = 10
+
+	// reputationDecayInterval is the time interval at which the reputation
+	// counters of the user operation entities decay.
+	reputationDecayInterval = time.Hour
+)
+
+var (
+	// ErrUserOpEntryPoint is returned if a user operation is submitted for an
+	// entry point the pool doesn't serve.
+	ErrUserOpEntryPoint = errors.New("unsupported entry point")
+
+	// ErrUserOpKnown is returned if a user operation is already in the pool.
+	ErrUserOpKnown = errors.New("already known")
+
+	// ErrUserOpReplaceUnderpriced is returned if a user operation is attempted
+	// to be replaced without the required fee bump.
+	ErrUserOpReplaceUnderpriced = errors.New("replacement user operation underpriced")
+
+	// ErrUserOpSenderLimit is returned if the sender of a user operation has
+	// too many operations pooled already.
+	ErrUserOpSenderLimit = errors.New("too many pooled user operations of sender")
+
+	// ErrUserOpPoolFull is returned if the pool is full and the user operation
+	// doesn't pay more than the cheapest pooled one.
+	ErrUserOpPoolFull = errors.New("user operation pool full")
+
+	// ErrUserOpBanned is returned if an entity of a user operation is banned due
+	// to its reputation.
+	ErrUserOpBanned = errors.New("entity banned")
+
+	// ErrUserOpThrottled is returned if an entity of a user operation is throttled
+	// due to its reputation and has the allowed operations pooled already.
+	ErrUserOpThrottled = errors.New("entity throttled")
+)
+
+var (
+	validUserOpMeter    = metrics.NewRegisteredMeter("txpool/userops/valid", nil)
+	invalidUserOpMeter  = metrics.NewRegisteredMeter("txpool/userops/invalid", nil)
+	replaceUserOpMeter  = metrics.NewRegisteredMeter("txpool/userops/replace", nil)
+	includedUserOpMeter = metrics.NewRegisteredMeter("txpool/userops/included", nil)
+	evictedUserOpMeter  = metrics.NewRegisteredMeter("txpool/userops/evicted", nil)
+
+	userOpsGauge = metrics.NewRegisteredGauge("txpool/userops/pending", nil)
+)
+
+// userOpChain provides the state of the blockchain to simulate user operations
+// on, and the events to track their inclusion.
+type userOpChain interface {
+	ChainContext
+
+	CurrentBlock() *types.Block
+	StateAt(root common.Hash) (*state.StateDB, error)
+
+	SubscribeChainEvent(ch chan<- ChainEvent) event.Subscription
+}
+
+// UserOpValidator is a hook validating user operations before they are simulated,
+// allowing operators to enforce their own policies (e.g. whitelisted paymasters).
+type UserOpValidator func(op *types.UserOperation, entryPoint common.Address) error
+
+// UserOpPoolConfig are the configuration parameters of the user operation pool.
+type UserOpPoolConfig struct {
+	EntryPoints []common.Address // Entry points to accept user operations for, none disabling the pool
+
+	MaxOps    uint64 // Maximum number of user operations in the pool
+	SenderOps uint64 // Maximum number of user operations pooled per sender
+	PriceBump uint64 // Minimum fee bump percentage to replace a user operation (sender and nonce)
+
+	SimulationGas uint64        // Gas allowance for simulating the validation of a user operation
+	Lifetime      time.Duration // Maximum time user operations are pooled without being included
+}
+
+// DefaultUserOpPoolConfig contains the default configurations for the user
+// operation pool.
+var DefaultUserOpPoolConfig = UserOpPoolConfig{
+	MaxOps:    4096,
+	SenderOps: 4,
+	PriceBump: 10,
+
+	SimulationGas: 10000000,
+	Lifetime:      time.Hour,
+}
+
+// sanitize checks the provided user configurations and changes anything that's
+// unreasonable or unworkable.
+func (config *UserOpPoolConfig) sanitize() UserOpPoolConfig {
+	conf := *config
+	if conf.MaxOps < 1 {
+		log.Warn("Sanitizing invalid user operation pool size", "provided", conf.MaxOps, "updated", DefaultUserOpPoolConfig.MaxOps)
+		conf.MaxOps = DefaultUserOpPoolConfig.MaxOps
+	}
+	if conf.SenderOps < 1 {
+		log.Warn("Sanitizing invalid user operation sender limit", "provided", conf.SenderOps, "updated", DefaultUserOpPoolConfig.SenderOps)
+		conf.SenderOps = DefaultUserOpPoolConfig.SenderOps
+	}
+	if conf.PriceBump < 1 {
+		log.Warn("Sanitizing invalid user operation price bump", "provided", conf.PriceBump, "updated", DefaultUserOpPoolConfig.PriceBump)
+		conf.PriceBump = DefaultUserOpPoolConfig.PriceBump
+	}
+	if conf.SimulationGas < params.TxGas {
+		log.Warn("Sanitizing invalid user operation simulation gas", "provided", conf.SimulationGas, "updated", DefaultUserOpPoolConfig.SimulationGas)
+		conf.SimulationGas = DefaultUserOpPoolConfig.SimulationGas
+	}
+	if conf.Lifetime < 1 {
+		log.Warn("Sanitizing invalid user operation lifetime", "provided", conf.Lifetime, "updated", DefaultUserOpPoolConfig.Lifetime)
+		conf.Lifetime = DefaultUserOpPoolConfig.Lifetime
+	}
+	return conf
+}
+
+// userOpEntry is a user operation tracked by the pool.
+type userOpEntry struct {
+	op         *types.UserOperation
+	entryPoint common.Address
+	added      time.Time
+}
+
+// entities returns the addresses of the entities taking part in the operation.
+func (e *userOpEntry) entities() []common.Address {
+	entities := []common.Address{e.op.Sender}
+	if factory := e.op.Factory(); factory != nil {
+		entities = append(entities, *factory)
+	}
+	if paymaster := e.op.Paymaster(); paymaster != nil {
+		entities = append(entities, *paymaster)
+	}
+	return entities
+}
+
+// UserOpPool is an alternative mempool of ERC-4337 user operations, collected for
+// a bundler running in-process. Operations are validated by simulating them
+// against their entry point on top of the chain head, and the entities taking
+// part in them are subject to the reputation rules of ERC-4337. Operations leave
+// the pool once the entry point logs their execution.
+type UserOpPool struct {
+	config      UserOpPoolConfig
+	chainconfig *params.ChainConfig
+	chain       userOpChain
+	entryPoints map[common.Address]bool
+
+	validators []UserOpValidator
+	opFeed     event.Feed
+	scope      event.SubscriptionScope
+
+	mu         sync.RWMutex
+	ops        map[common.Hash]*userOpEntry     // All pooled user operations by hash
+	senders    map[common.Address][]common.Hash // Hashes of the pooled user operations by sender
+	reputation *userOpReputation                // Seen and included operation counters of the entities
+
+	chainEventCh  chan ChainEvent
+	chainEventSub event.Subscription
+	quit          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewUserOpPool creates a new user operation pool, tracking the inclusion of the
+// pooled operations in the given chain.
+func NewUserOpPool(config UserOpPoolConfig, chainconfig *params.ChainConfig, chain userOpChain) *UserOpPool {
+	config = (&config).sanitize()
+
+	pool := &UserOpPool{
+		config:       config,
+		chainconfig:  chainconfig,
+		chain:        chain,
+		entryPoints:  make(map[common.Address]bool),
+		ops:          make(map[common.Hash]*userOpEntry),
+		senders:      make(map[common.Address][]common.Hash),
+		reputation:   newUserOpReputation(),
+		chainEventCh: make(chan ChainEvent, chainEventChanSize),
+		quit:         make(chan struct{}),
+	}
+	for _, entryPoint := range config.EntryPoints {
+		log.Info("Serving user operations", "entrypoint", entryPoint)
+		pool.entryPoints[entryPoint] = true
+	}
+	pool.chainEventSub = chain.SubscribeChainEvent(pool.chainEventCh)
+
+	pool.wg.Add(1)
+	go pool.loop()
+	return pool
+}
+
+// loop is the pool's main event loop, removing the included user operations and
+// evicting the stale ones.
+func (pool *UserOpPool) loop() {
+	defer pool.wg.Done()
+
+	var (
+		evict = time.NewTicker(evictionInterval)
+		decay = time.NewTicker(reputationDecayInterval)
+	)
+	defer evict.Stop()
+	defer decay.Stop()
+
+	for {
+		select {
+		case ev := <-pool.chainEventCh:
+			pool.included(ev.Logs)
+
+		case <-evict.C:
+			pool.evict(time.Now())
+
+		case <-decay.C:
+			pool.mu.Lock()
+			pool.reputation.decay()
+			pool.mu.Unlock()
+
+		case <-pool.chainEventSub.Err():
+			return
+		case <-pool.quit:
+			return
+		}
+	}
+}
+
+// Stop terminates the user operation pool.
+func (pool *UserOpPool) Stop() {
+	pool.scope.Close()
+	pool.chainEventSub.Unsubscribe()
+	close(pool.quit)
+	pool.wg.Wait()
+
+	log.Info("User operation pool stopped")
+}
+
+// EntryPoints returns the entry points the pool accepts user operations for.
+func (pool *UserOpPool) EntryPoints() []common.Address {
+	return append([]common.Address{}, pool.config.EntryPoints...)
+}
+
+// AddValidator registers a hook validating user operations before simulation.
+// Hooks must be registered before operations are added.
+func (pool *UserOpPool) AddValidator(validator UserOpValidator) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.validators = append(pool.validators, validator)
+}
+
+// SubscribeNewUserOpsEvent registers a subscription of NewUserOpsEvent and
+// starts sending event to the given channel.
+func (pool *UserOpPool) SubscribeNewUserOpsEvent(ch chan<- NewUserOpsEvent) event.Subscription {
+	return pool.scope.Track(pool.opFeed.Subscribe(ch))
+}
+
+// Add validates a user operation and adds it to the pool, returning its hash. An
+// operation of the same sender and nonce is replaced if the new one pays enough
+// more.
+func (pool *UserOpPool) Add(op *types.UserOperation, entryPoint common.Address) (common.Hash, error) {
+	hash, err := pool.add(op, entryPoint)
+	if err != nil {
+		invalidUserOpMeter.Mark(1)
+		return common.Hash{}, err
+	}
+	validUserOpMeter.Mark(1)
+	pool.opFeed.Send(NewUserOpsEvent{EntryPoint: entryPoint, Ops: []*types.UserOperation{op}})
+	return hash, nil
+}
+
+// add validates and inserts a user operation. The operation is simulated without
+// holding pool.mu, so that slow simulations don't block the pool, and its
+// admission is checked again once the simulation is done.
+func (pool *UserOpPool) add(op *types.UserOperation, entryPoint common.Address) (common.Hash, error) {
+	if !pool.entryPoints[entryPoint] {
+		return common.Hash{}, ErrUserOpEntryPoint
+	}
+	if err := pool.validateFields(op); err != nil {
+		return common.Hash{}, err
+	}
+	var (
+		hash  = op.Hash(entryPoint, pool.chainconfig.ChainID)
+		entry = &userOpEntry{op: op, entryPoint: entryPoint}
+	)
+	pool.mu.RLock()
+	validators := pool.validators
+	_, err := pool.admit(hash, entry)
+	pool.mu.RUnlock()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	for _, validator := range validators {
+		if err := validator(op, entryPoint); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if err := pool.simulate(op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	// The operation is valid, insert it unless the pool changed meanwhile
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	replaced, err := pool.admit(hash, entry)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// Drop the replaced or cheapest operation if needed
+	switch {
+	case replaced != (common.Hash{}):
+		pool.remove(replaced)
+		replaceUserOpMeter.Mark(1)
+
+	case uint64(len(pool.ops)) >= pool.config.MaxOps:
+		cheapest := pool.cheapest()
+		if pool.ops[cheapest].op.MaxPriorityFeePerGas.Cmp(op.MaxPriorityFeePerGas) >= 0 {
+			return common.Hash{}, ErrUserOpPoolFull
+		}
+		pool.remove(cheapest)
+		evictedUserOpMeter.Mark(1)
+	}
+	entry.added = time.Now()
+	pool.ops[hash] = entry
+	pool.senders[op.Sender] = append(pool.senders[op.Sender], hash)
+	for _, entity := range entry.entities() {
+		pool.reputation.markSeen(entity)
+	}
+	userOpsGauge.Update(int64(len(pool.ops)))
+
+	log.Trace("Pooled new user operation", "hash", hash, "sender", op.Sender, "nonce", op.Nonce, "entrypoint", entryPoint)
+	return hash, nil
+}
+
+// admit checks whether a user operation can enter the pool as it is, returning
+// the hash of the pooled operation it replaces, if any. The caller must hold
+// pool.mu.
+func (pool *UserOpPool) admit(hash common.Hash, entry *userOpEntry) (common.Hash, error) {
+	if pool.ops[hash] != nil {
+		return common.Hash{}, ErrUserOpKnown
+	}
+	if err := pool.checkReputation(entry); err != nil {
+		return common.Hash{}, err
+	}
+	// Find the operation to replace, or make room for a new one of the sender
+	op := entry.op
+	for _, old := range pool.senders[op.Sender] {
+		prev := pool.ops[old].op
+		if prev.Nonce.Cmp(op.Nonce) != 0 {
+			continue
+		}
+		if !bumped(prev.MaxFeePerGas, op.MaxFeePerGas, pool.config.PriceBump) || !bumped(prev.MaxPriorityFeePerGas, op.MaxPriorityFeePerGas, pool.config.PriceBump) {
+			return common.Hash{}, ErrUserOpReplaceUnderpriced
+		}
+		return old, nil
+	}
+	if uint64(len(pool.senders[op.Sender])) >= pool.config.SenderOps {
+		return common.Hash{}, ErrUserOpSenderLimit
+	}
+	return common.Hash{}, nil
+}
+
+// validateFields checks the user operation fields which don't need simulation.
+func (pool *UserOpPool) validateFields(op *types.UserOperation) error {
+	for name, field := range map[string]*big.Int{
+		"nonce":                op.Nonce,
+		"callGasLimit":         op.CallGasLimit,
+		"verificationGasLimit": op.VerificationGasLimit,
+		"preVerificationGas":   op.PreVerificationGas,
+		"maxFeePerGas":         op.MaxFeePerGas,
+		"maxPriorityFeePerGas": op.MaxPriorityFeePerGas,
+	} {
+		if field == nil || field.Sign() < 0 {
+			return fmt.Errorf("invalid %s", name)
+		}
+	}
+	if op.MaxPriorityFeePerGas.Cmp(op.MaxFeePerGas) > 0 {
+		return errors.New("max priority fee per gas higher than max fee per gas")
+	}
+	if !op.VerificationGasLimit.IsUint64() || op.VerificationGasLimit.Uint64() > pool.config.SimulationGas {
+		return fmt.Errorf("verification gas limit above %d", pool.config.SimulationGas)
+	}
+	if len(op.InitCode) > 0 && len(op.InitCode) < common.AddressLength {
+		return errors.New("init code too short")
+	}
+	if len(op.PaymasterAndData) > 0 && len(op.PaymasterAndData) < common.AddressLength {
+		return errors.New("paymaster and data too short")
+	}
+	return nil
+}
+
+// checkReputation rejects the user operation if any of its entities is banned, or
+// throttled with the allowed number of operations pooled already. The caller must
+// hold pool.mu.
+func (pool *UserOpPool) checkReputation(entry *userOpEntry) error {
+	for _, entity := range entry.entities() {
+		switch pool.reputation.status(entity) {
+		case ReputationBanned:
+			return fmt.Errorf("%w: %x", ErrUserOpBanned, entity)
+
+		case ReputationThrottled:
+			var pooled int
+			for _, other := range pool.ops {
+				for _, e := range other.entities() {
+					if e == entity {
+						pooled++
+						break
+					}
+				}
+			}
+			if pooled >= throttledEntityOps {
+				return fmt.Errorf("%w: %x", ErrUserOpThrottled, entity)
+			}
+		}
+	}
+	return nil
+}
+
+// simulate validates the user operation against the entry point on top of the
+// current chain head, also checking the code of the sender and paymaster.
+func (pool *UserOpPool) simulate(op *types.UserOperation, entryPoint common.Address) error {
+	head := pool.chain.CurrentBlock()
+	statedb, err := pool.chain.StateAt(head.Root())
+	if err != nil {
+		return err
+	}
+	deployed := statedb.GetCodeSize(op.Sender) > 0
+	if deployed && len(op.InitCode) > 0 {
+		return errors.New("init code of deployed sender")
+	}
+	if !deployed && len(op.InitCode) == 0 {
+		return errors.New("sender not deployed")
+	}
+	if paymaster := op.Paymaster(); paymaster != nil && statedb.GetCodeSize(*paymaster) == 0 {
+		return errors.New("paymaster not deployed")
+	}
+	return simulateUserOp(pool.chainconfig, pool.chain, head.Header(), statedb, op, entryPoint, pool.config.SimulationGas)
+}
+
+// cheapest returns the hash of the pooled user operation paying the lowest priority
+// fee. The caller must hold pool.mu.
+func (pool *UserOpPool) cheapest() common.Hash {
+	var (
+		cheapest common.Hash
+		fee      *big.Int
+	)
+	for hash, entry := range pool.ops {
+		if fee == nil || entry.op.MaxPriorityFeePerGas.Cmp(fee) < 0 {
+			cheapest, fee = hash, entry.op.MaxPriorityFeePerGas
+		}
+	}
+	return cheapest
+}
+
+// remove drops a user operation from the pool. The caller must hold pool.mu.
+func (pool *UserOpPool) remove(hash common.Hash) {
+	entry := pool.ops[hash]
+	if entry == nil {
+		return
+	}
+	delete(pool.ops, hash)
+
+	hashes := pool.senders[entry.op.Sender]
+	for i, h := range hashes {
+		if h == hash {
+			hashes = append(hashes[:i:i], hashes[i+1:]...)
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		delete(pool.senders, entry.op.Sender)
+	} else {
+		pool.senders[entry.op.Sender] = hashes
+	}
+	userOpsGauge.Update(int64(len(pool.ops)))
+}
+
+// Remove drops user operations from the pool, e.g. if a bundler found them to
+// fail when building a bundle.
+func (pool *UserOpPool) Remove(hashes ...common.Hash) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for _, hash := range hashes {
+		pool.remove(hash)
+	}
+}
+
+// Get returns a pooled user operation and its entry point, or nil if the operation
+// is not in the pool.
+func (pool *UserOpPool) Get(hash common.Hash) (*types.UserOperation, common.Address) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if entry := pool.ops[hash]; entry != nil {
+		return entry.op, entry.entryPoint
+	}
+	return nil, common.Address{}
+}
+
+// Pending returns the user operations a bundler should include for the given entry
+// point, ordered by priority fee. Only the lowest nonce operation of each sender
+// is returned, as a bundle must not contain several operations of a sender.
+func (pool *UserOpPool) Pending(entryPoint common.Address) []*types.UserOperation {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var ops []*types.UserOperation
+	for _, hashes := range pool.senders {
+		var next *types.UserOperation
+		for _, hash := range hashes {
+			entry := pool.ops[hash]
+			if entry.entryPoint != entryPoint {
+				continue
+			}
+			if next == nil || entry.op.Nonce.Cmp(next.Nonce) < 0 {
+				next = entry.op
+			}
+		}
+		if next != nil {
+			ops = append(ops, next)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].MaxPriorityFeePerGas.Cmp(ops[j].MaxPriorityFeePerGas) > 0
+	})
+	return ops
+}
+
+// Reputation returns the reputation status of a user operation entity.
+func (pool *UserOpPool) Reputation(addr common.Address) ReputationStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.reputation.status(addr)
+}
+
+// included removes the user operations whose execution was logged by their entry
+// point, crediting their entities with the inclusion.
+func (pool *UserOpPool) included(logs []*types.Log) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for _, l := range logs {
+		if !pool.entryPoints[l.Address] || len(l.Topics) < 4 || l.Topics[0] != userOpEventTopic {
+			continue
+		}
+		// Credit the entities of the operation if known, otherwise the sender
+		// and paymaster logged by the event
+		hash := l.Topics[1]
+		if entry := pool.ops[hash]; entry != nil {
+			for _, entity := range entry.entities() {
+				pool.reputation.markIncluded(entity)
+			}
+			pool.remove(hash)
+		} else {
+			pool.reputation.markIncluded(common.BytesToAddress(l.Topics[2].Bytes()))
+			if paymaster := common.BytesToAddress(l.Topics[3].Bytes()); paymaster != (common.Address{}) {
+				pool.reputation.markIncluded(paymaster)
+			}
+		}
+		includedUserOpMeter.Mark(1)
+	}
+}
+
+// evict drops the user operations pooled for longer than the configured lifetime.
+func (pool *UserOpPool) evict(now time.Time) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for hash, entry := range pool.ops {
+		if now.Sub(entry.added) > pool.config.Lifetime {
+			pool.remove(hash)
+			evictedUserOpMeter.Mark(1)
+		}
+	}
+}
+
+// bumped reports whether the new value exceeds the old one by at least the given
+// percentage.
+func bumped(prev, next *big.Int, percent uint64) bool {
+	threshold := new(big.Int).Mul(prev, big.NewInt(int64(100+percent)))
+	return threshold.Cmp(new(big.Int).Mul(next, big.NewInt(100))) <= 0
+}
@@ -0,0 +1,319 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/acent/go-acent/accounts/abi"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/params"
+)
+
+var (
+	testEntryPoint      = common.HexToAddress("0x000000000000000000000000000000000000ee01") // Accepts everything
+	testFailEntryPoint  = common.HexToAddress("0x000000000000000000000000000000000000ee02") // Reverts with FailedOp
+	testSigEntryPoint   = common.HexToAddress("0x000000000000000000000000000000000000ee03") // Reports a signature failure
+	testRuleEntryPoint  = common.HexToAddress("0x000000000000000000000000000000000000ee04") // Calls an entity using TIMESTAMP
+	testCallEntryPoint  = common.HexToAddress("0x000000000000000000000000000000000000ee05") // Calls the sender
+	testStakeEntryPoint = common.HexToAddress("0x000000000000000000000000000000000000ee06") // Calls the sender, reports a staked paymaster
+
+	testAccount        = common.HexToAddress("0x000000000000000000000000000000000000aa01")
+	testTimingAccount  = common.HexToAddress("0x000000000000000000000000000000000000aa02")
+	testMissingAccount = common.HexToAddress("0x000000000000000000000000000000000000aa03")
+	testSlotAccount    = common.HexToAddress("0x000000000000000000000000000000000000aa04") // Calls testSlotContract
+	testMappingAccount = common.HexToAddress("0x000000000000000000000000000000000000aa05") // Calls testMappingContract
+
+	testSlotContract    = common.HexToAddress("0x000000000000000000000000000000000000cc01") // Reads its own slot 0
+	testMappingContract = common.HexToAddress("0x000000000000000000000000000000000000cc02") // Reads the mapping slot of its caller
+)
+
+// revertingCode creates contract code running the given prefix, then reverting
+// with the given data.
+func revertingCode(prefix []byte, data []byte) []byte {
+	offset := len(prefix) + 15
+	code := append(append([]byte{}, prefix...),
+		byte(vm.PUSH2), byte(len(data)>>8), byte(len(data)),
+		byte(vm.PUSH2), byte(offset>>8), byte(offset),
+		byte(vm.PUSH1), 0x00,
+		byte(vm.CODECOPY),
+		byte(vm.PUSH2), byte(len(data)>>8), byte(len(data)),
+		byte(vm.PUSH1), 0x00,
+		byte(vm.REVERT),
+	)
+	return append(code, data...)
+}
+
+// validationResult creates the revert data of a ValidationResult error.
+func validationResult(sigFailed bool) []byte {
+	words := make([][]byte, 14)
+	for i := range words {
+		words[i] = make([]byte, 32)
+	}
+	words[0][31] = 7 * 32 // Offset of the return info
+	if sigFailed {
+		words[7+2][31] = 1
+	}
+	words[7+5][31] = 6 * 32 // Offset of the paymaster context
+
+	data := append([]byte{}, validationResultSelector...)
+	for _, word := range words {
+		data = append(data, word...)
+	}
+	return data
+}
+
+// failedOp creates the revert data of a FailedOp error.
+func failedOp(reason string) []byte {
+	args := abi.Arguments{{Type: uint256Type}, {Type: stringType}}
+	data, _ := args.Pack(new(big.Int), reason)
+	return append(append([]byte{}, failedOpSelector...), data...)
+}
+
+// newTestUserOpPool creates a user operation pool on top of a chain with the test
+// entry points and accounts deployed.
+func newTestUserOpPool(t *testing.T) (*UserOpPool, *BlockChain) {
+	timestamp := []byte{byte(vm.TIMESTAMP), byte(vm.STOP)}
+	callTiming := []byte{
+		byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1),
+		byte(vm.PUSH2), 0xaa, 0x02,
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.POP),
+	}
+	callSender := []byte{
+		byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1),
+		byte(vm.PUSH1), 0x24, byte(vm.CALLDATALOAD), // Sender, the first field of the operation
+		byte(vm.GAS),
+		byte(vm.CALL),
+		byte(vm.POP),
+	}
+	staked := validationResult(false)
+	staked[4+5*32+31], staked[4+6*32+31] = 1, 1 // Stake and unstake delay of the paymaster
+
+	// callAccount reads the own slot 0 of the sender, then calls the given contract
+	callAccount := func(contract common.Address) []byte {
+		return []byte{
+			byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.POP),
+			byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1),
+			byte(vm.PUSH2), contract[18], contract[19],
+			byte(vm.GAS),
+			byte(vm.CALL),
+			byte(vm.STOP),
+		}
+	}
+	readMapping := []byte{
+		byte(vm.CALLER), byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x20, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0x40, byte(vm.PUSH1), 0x00, byte(vm.SHA3),
+		byte(vm.SLOAD),
+		byte(vm.STOP),
+	}
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				testEntryPoint:     {Code: revertingCode(nil, validationResult(false)), Balance: new(big.Int)},
+				testFailEntryPoint: {Code: revertingCode(nil, failedOp("AA23 reverted")), Balance: new(big.Int)},
+				testSigEntryPoint:  {Code: revertingCode(nil, validationResult(true)), Balance: new(big.Int)},
+				testRuleEntryPoint: {Code: revertingCode(callTiming, validationResult(false)), Balance: new(big.Int)},
+				testAccount:        {Code: []byte{byte(vm.STOP)}, Balance: new(big.Int)},
+				testTimingAccount:  {Code: timestamp, Balance: new(big.Int)},
+
+				testCallEntryPoint:  {Code: revertingCode(callSender, validationResult(false)), Balance: new(big.Int)},
+				testStakeEntryPoint: {Code: revertingCode(callSender, staked), Balance: new(big.Int)},
+				testSlotAccount:     {Code: callAccount(testSlotContract), Balance: new(big.Int)},
+				testMappingAccount:  {Code: callAccount(testMappingContract), Balance: new(big.Int)},
+				testSlotContract:    {Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.STOP)}, Balance: new(big.Int)},
+				testMappingContract: {Code: readMapping, Balance: new(big.Int)},
+			},
+		}
+	)
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	config := DefaultUserOpPoolConfig
+	config.EntryPoints = []common.Address{testEntryPoint, testFailEntryPoint, testSigEntryPoint, testRuleEntryPoint, testCallEntryPoint, testStakeEntryPoint}
+
+	return NewUserOpPool(config, params.TestChainConfig, chain), chain
+}
+
+// testUserOp creates a user operation of the given sender and nonce.
+func testUserOp(sender common.Address, nonce int64, fee int64) *types.UserOperation {
+	return &types.UserOperation{
+		Sender:               sender,
+		Nonce:                big.NewInt(nonce),
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(100000),
+		PreVerificationGas:   big.NewInt(21000),
+		MaxFeePerGas:         big.NewInt(fee),
+		MaxPriorityFeePerGas: big.NewInt(fee),
+	}
+}
+
+// Tests that user operations are validated by simulation against their entry point
+// before entering the pool.
+func TestUserOpPoolValidation(t *testing.T) {
+	pool, chain := newTestUserOpPool(t)
+	defer chain.Stop()
+	defer pool.Stop()
+
+	pool.AddValidator(func(op *types.UserOperation, entryPoint common.Address) error {
+		if op.Nonce.Int64() == 666 {
+			return errors.New("rejected by hook")
+		}
+		return nil
+	})
+	underpriced := testUserOp(testAccount, 0, 1)
+	underpriced.CallGasLimit = big.NewInt(200000)
+
+	tests := []struct {
+		op         *types.UserOperation
+		entryPoint common.Address
+		err        string
+	}{
+		{testUserOp(testAccount, 0, 1), testEntryPoint, ""},
+		{testUserOp(testAccount, 0, 1), testEntryPoint, ErrUserOpKnown.Error()},
+		{testUserOp(testAccount, 1, 1), common.Address{0xee}, ErrUserOpEntryPoint.Error()},
+		{testUserOp(testAccount, 666, 1), testEntryPoint, "rejected by hook"},
+		{testUserOp(testAccount, 1, 1), testFailEntryPoint, "AA23 reverted"},
+		{testUserOp(testAccount, 1, 1), testSigEntryPoint, "invalid user operation signature"},
+		{testUserOp(testAccount, 1, 1), testRuleEntryPoint, "forbidden opcode TIMESTAMP"},
+		{testUserOp(testMissingAccount, 0, 1), testEntryPoint, "sender not deployed"},
+		{underpriced, testEntryPoint, ErrUserOpReplaceUnderpriced.Error()},
+		{testUserOp(testAccount, 1, 1), testEntryPoint, ""},
+	}
+	for i, test := range tests {
+		_, err := pool.Add(test.op, test.entryPoint)
+		if test.err == "" && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+	// Bump the fees of the first operation enough to replace it
+	replacement := testUserOp(testAccount, 0, 2)
+	hash, err := pool.Add(replacement, testEntryPoint)
+	if err != nil {
+		t.Fatalf("failed to replace user operation: %v", err)
+	}
+	if op, entryPoint := pool.Get(hash); op != replacement || entryPoint != testEntryPoint {
+		t.Fatalf("replacement mismatch: have %v/%x, want %v/%x", op, entryPoint, replacement, testEntryPoint)
+	}
+	if len(pool.ops) != 2 {
+		t.Fatalf("pooled operation count mismatch: have %d, want %d", len(pool.ops), 2)
+	}
+	// Bundlers must only get the lowest nonce operation of each sender
+	if pending := pool.Pending(testEntryPoint); len(pending) != 1 || pending[0] != replacement {
+		t.Fatalf("pending operations mismatch: have %v, want %v", pending, replacement)
+	}
+	// Operations logged as executed by the entry point must be removed
+	pool.included([]*types.Log{{
+		Address: testEntryPoint,
+		Topics:  []common.Hash{userOpEventTopic, hash, common.BytesToHash(testAccount.Bytes()), {}},
+	}})
+	if op, _ := pool.Get(hash); op != nil {
+		t.Fatalf("included operation still pooled")
+	}
+	if included := pool.reputation.included[testAccount]; included != 1 {
+		t.Fatalf("included operation count mismatch: have %d, want %d", included, 1)
+	}
+}
+
+// Tests that the validating entities may only access the storage of the sender,
+// the slots associated with the sender, and their own storage if staked.
+func TestUserOpStorageRules(t *testing.T) {
+	pool, chain := newTestUserOpPool(t)
+	defer chain.Stop()
+	defer pool.Stop()
+
+	// Validators run without the pool lock held, so they may query the pool
+	pool.AddValidator(func(op *types.UserOperation, entryPoint common.Address) error {
+		pool.Pending(entryPoint)
+		return nil
+	})
+	withPaymaster := func(op *types.UserOperation, paymaster common.Address) *types.UserOperation {
+		op.PaymasterAndData = paymaster.Bytes()
+		return op
+	}
+	tests := []struct {
+		op         *types.UserOperation
+		entryPoint common.Address
+		err        string
+	}{
+		{testUserOp(testMappingAccount, 0, 1), testCallEntryPoint, ""},
+		{testUserOp(testSlotAccount, 0, 1), testCallEntryPoint, "forbidden access to storage slot"},
+		{withPaymaster(testUserOp(testSlotAccount, 0, 1), testSlotContract), testCallEntryPoint, "storage of unstaked entity"},
+		{withPaymaster(testUserOp(testSlotAccount, 0, 1), testSlotContract), testStakeEntryPoint, ""},
+	}
+	for i, test := range tests {
+		_, err := pool.Add(test.op, test.entryPoint)
+		if test.err == "" && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+// Tests that entities not getting their operations included are throttled, then
+// banned, and that their reputation recovers over time.
+func TestUserOpReputation(t *testing.T) {
+	pool, chain := newTestUserOpPool(t)
+	defer chain.Stop()
+	defer pool.Stop()
+
+	rep := pool.reputation
+	rep.seen[testAccount] = (throttlingSlack + 1) * minInclusionDenominator
+	if status := pool.Reputation(testAccount); status != ReputationThrottled {
+		t.Fatalf("reputation mismatch: have %v, want %v", status, ReputationThrottled)
+	}
+	rep.seen[testAccount] = (banSlack + 1) * minInclusionDenominator
+	if status := pool.Reputation(testAccount); status != ReputationBanned {
+		t.Fatalf("reputation mismatch: have %v, want %v", status, ReputationBanned)
+	}
+	if _, err := pool.Add(testUserOp(testAccount, 0, 1), testEntryPoint); !errors.Is(err, ErrUserOpBanned) {
+		t.Fatalf("banned entity error mismatch: have %v, want %v", err, ErrUserOpBanned)
+	}
+	// Inclusions must restore the reputation
+	for i := 0; i <= banSlack-throttlingSlack; i++ {
+		rep.markIncluded(testAccount)
+	}
+	if status := pool.Reputation(testAccount); status != ReputationOK {
+		t.Fatalf("reputation mismatch: have %v, want %v", status, ReputationOK)
+	}
+	// Decaying must eventually forget the entity
+	for i := 0; i < 200 && len(rep.seen)+len(rep.included) > 0; i++ {
+		rep.decay()
+	}
+	if len(rep.seen)+len(rep.included) > 0 {
+		t.Fatalf("reputation not forgotten: seen %d, included %d", rep.seen[testAccount], rep.included[testAccount])
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/acent/go-acent/common"
+)
+
+const (
+	// Parameters of the ERC-4337 reputation rules: an entity is expected to get
+	// at least one in minInclusionDenominator of its operations included, being
+	// throttled or banned if it falls behind by the respective slack.
+	minInclusionDenominator = 10
+	throttlingSlack         = 10
+	banSlack                = 50
+
+	// throttledEntityOps is the maximum number of pooled user operations a
+	// throttled entity may take part in.
+	throttledEntityOps = 4
+)
+
+// ReputationStatus is the standing of a user operation entity (sender, factory or
+// paymaster), derived from how many of its operations got included.
+type ReputationStatus int
+
+const (
+	ReputationOK ReputationStatus = iota
+	ReputationThrottled
+	ReputationBanned
+)
+
+// String implements fmt.Stringer.
+func (s ReputationStatus) String() string {
+	switch s {
+	case ReputationOK:
+		return "ok"
+	case ReputationThrottled:
+		return "throttled"
+	case ReputationBanned:
+		return "banned"
+	default:
+		return "unknown"
+	}
+}
+
+// userOpReputation tracks the number of user operations seen and included for
+// each entity. It is not thread safe, the pool's lock guards it.
+type userOpReputation struct {
+	seen     map[common.Address]uint64
+	included map[common.Address]uint64
+}
+
+// newUserOpReputation creates an empty reputation tracker.
+func newUserOpReputation() *userOpReputation {
+	return &userOpReputation{
+		seen:     make(map[common.Address]uint64),
+		included: make(map[common.Address]uint64),
+	}
+}
+
+// status returns the reputation status of an entity.
+func (r *userOpReputation) status(addr common.Address) ReputationStatus {
+	maxSeen := r.seen[addr] / minInclusionDenominator
+	switch {
+	case maxSeen > r.included[addr]+banSlack:
+		return ReputationBanned
+	case maxSeen > r.included[addr]+throttlingSlack:
+		return ReputationThrottled
+	default:
+		return ReputationOK
+	}
+}
+
+// markSeen counts an operation of the entity accepted into the pool.
+func (r *userOpReputation) markSeen(addr common.Address) {
+	r.seen[addr]++
+}
+
+// markIncluded counts an operation of the entity included in the chain.
+func (r *userOpReputation) markIncluded(addr common.Address) {
+	r.included[addr]++
+}
+
+// decay reduces the counters by 1/24. Called hourly, it makes misbehaviour be
+// forgotten over time. Entities reaching zero are dropped.
+func (r *userOpReputation) decay() {
+	for addr, seen := range r.seen {
+		if seen = seen * 23 / 24; seen == 0 {
+			delete(r.seen, addr)
+		} else {
+			r.seen[addr] = seen
+		}
+	}
+	for addr, included := range r.included {
+		if included = included * 23 / 24; included == 0 {
+			delete(r.included, addr)
+		} else {
+			r.included[addr] = included
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/acent/go-acent/accounts/abi"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+	"github.com/holiman/uint256"
+)
+
+// entryPointABI is the part of the ERC-4337 entry point interface used to simulate
+// the validation of user operations.
+const entryPointABI = `[{"type":"function","name":"simulateValidation","inputs":[{"name":"userOp","type":"tuple","components":[
+	{"name":"sender","type":"address"},
+	{"name":"nonce","type":"uint256"},
+	{"name":"initCode","type":"bytes"},
+	{"name":"callData","type":"bytes"},
+	{"name":"callGasLimit","type":"uint256"},
+	{"name":"verificationGasLimit","type":"uint256"},
+	{"name":"preVerificationGas","type":"uint256"},
+	{"name":"maxFeePerGas","type":"uint256"},
+	{"name":"maxPriorityFeePerGas","type":"uint256"},
+	{"name":"paymasterAndData","type":"bytes"},
+	{"name":"signature","type":"bytes"}]}],"outputs":[]}]`
+
+var (
+	// parsedEntryPointABI is the parsed form of entryPointABI.
+	parsedEntryPointABI, _ = abi.JSON(strings.NewReader(entryPointABI))
+
+	// Selectors of the custom errors simulateValidation always reverts with.
+	failedOpSelector         = crypto.Keccak256([]byte("FailedOp(uint256,string)"))[:4]
+	validationResultSelector = crypto.Keccak256([]byte("ValidationResult((uint256,uint256,bool,uint48,uint48,bytes),(uint256,uint256),(uint256,uint256),(uint256,uint256))"))[:4]
+	aggregatedResultSelector = crypto.Keccak256([]byte("ValidationResultWithAggregation((uint256,uint256,bool,uint48,uint48,bytes),(uint256,uint256),(uint256,uint256),(uint256,uint256),(address,(uint256,uint256)))"))[:4]
+
+	// userOpEventTopic is the topic of the event logged by the entry point for
+	// each user operation executed.
+	userOpEventTopic = crypto.Keccak256Hash([]byte("UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256)"))
+)
+
+// userOpExpiryMargin is the minimum remaining validity of a user operation for
+// it to be accepted, leaving time for a bundler to include it.
+const userOpExpiryMargin = 30 * time.Second
+
+// simulateUserOp runs the validation of a user operation against the entry point
+// on top of the given block, checking the result as well as the opcodes used and
+// the storage accessed by the validating entities.
+func simulateUserOp(config *params.ChainConfig, chain ChainContext, header *types.Header, statedb *state.StateDB, op *types.UserOperation, entryPoint common.Address, gas uint64) error {
+	data, err := parsedEntryPointABI.Pack("simulateValidation", *op)
+	if err != nil {
+		return err
+	}
+	var (
+		msg    = types.NewMessage(common.Address{}, &entryPoint, 0, new(big.Int), gas, new(big.Int), data, nil, false)
+		tracer = newUserOpTracer(op, entryPoint)
+		vmenv  = vm.NewEVM(NewEVMBlockContext(header, chain, nil), NewEVMTxContext(msg), statedb, config, vm.Config{Debug: true, Tracer: tracer})
+	)
+	result, err := ApplyMessage(vmenv, msg, new(GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return err
+	}
+	if tracer.err != nil {
+		return tracer.err
+	}
+	if !result.Failed() {
+		return errors.New("entry point simulation did not revert")
+	}
+	res, err := parseValidationResult(result.Revert(), time.Now())
+	if err != nil {
+		return err
+	}
+	// Factories and paymasters may only use their own storage if staked
+	for entity := range tracer.selfAccess {
+		if !(entity == tracer.factory && res.factoryStaked) && !(entity == tracer.paymaster && res.paymasterStaked) {
+			return fmt.Errorf("storage of unstaked entity %x accessed", entity)
+		}
+	}
+	return nil
+}
+
+// userOpValidation is the outcome of a successful user operation validation.
+type userOpValidation struct {
+	factoryStaked   bool
+	paymasterStaked bool
+}
+
+// parseValidationResult interprets the revert data of simulateValidation, which
+// is a FailedOp error if the validation failed or a ValidationResult otherwise.
+func parseValidationResult(revert []byte, now time.Time) (*userOpValidation, error) {
+	switch {
+	case len(revert) >= 4 && bytes.Equal(revert[:4], failedOpSelector):
+		args := abi.Arguments{{Type: uint256Type}, {Type: stringType}}
+		values, err := args.Unpack(revert[4:])
+		if err != nil {
+			return nil, errors.New("user operation validation failed")
+		}
+		return nil, fmt.Errorf("user operation validation failed: %s", values[1].(string))
+
+	case len(revert) >= 4 && bytes.Equal(revert[:4], aggregatedResultSelector):
+		return nil, errors.New("signature aggregators not supported")
+
+	case len(revert) >= 4 && bytes.Equal(revert[:4], validationResultSelector):
+		// The return info is the first, dynamic field of the result, followed
+		// by the stake infos of the sender, factory and paymaster
+		words := revert[4:]
+		if len(words) < 7*32 {
+			return nil, errors.New("malformed validation result")
+		}
+		offset := new(big.Int).SetBytes(words[:32])
+		if !offset.IsUint64() || offset.Uint64()+5*32 > uint64(len(words)) {
+			return nil, errors.New("malformed validation result")
+		}
+		info := words[offset.Uint64():]
+		if new(big.Int).SetBytes(info[2*32:3*32]).Sign() != 0 {
+			return nil, errors.New("invalid user operation signature")
+		}
+		validAfter := new(big.Int).SetBytes(info[3*32 : 4*32]).Uint64()
+		validUntil := new(big.Int).SetBytes(info[4*32 : 5*32]).Uint64()
+		if validAfter > uint64(now.Unix()) {
+			return nil, fmt.Errorf("user operation not valid until %d", validAfter)
+		}
+		if validUntil != 0 && validUntil < uint64(now.Add(userOpExpiryMargin).Unix()) {
+			return nil, fmt.Errorf("user operation expires at %d", validUntil)
+		}
+		return &userOpValidation{
+			factoryStaked:   staked(words[3*32 : 5*32]),
+			paymasterStaked: staked(words[5*32 : 7*32]),
+		}, nil
+
+	default:
+		return nil, errors.New("unexpected entry point simulation result")
+	}
+}
+
+// staked reports whether a stake info of a validation result, i.e. the stake and
+// the unstake delay, shows the entity as staked.
+func staked(info []byte) bool {
+	return new(big.Int).SetBytes(info[:32]).Sign() > 0 && new(big.Int).SetBytes(info[32:64]).Sign() > 0
+}
+
+var (
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+	stringType, _  = abi.NewType("string", "", nil)
+)
+
+// userOpForbiddenOps are the opcodes the validating entities of a user operation
+// must not use, since their outcome may differ between the simulation and the
+// inclusion of the operation.
+var userOpForbiddenOps = map[vm.OpCode]bool{
+	vm.GASPRICE:     true,
+	vm.GASLIMIT:     true,
+	vm.DIFFICULTY:   true,
+	vm.TIMESTAMP:    true,
+	vm.BLOCKHASH:    true,
+	vm.NUMBER:       true,
+	vm.SELFBALANCE:  true,
+	vm.BALANCE:      true,
+	vm.ORIGIN:       true,
+	vm.CREATE:       true,
+	vm.COINBASE:     true,
+	vm.SELFDESTRUCT: true,
+}
+
+// userOpAssociatedSlots is the number of storage slots following the hash of an
+// address that are associated with it, e.g. for structs in mappings.
+const userOpAssociatedSlots = 128
+
+// userOpTracer is a vm.Tracer enforcing the opcode and storage rules of ERC-4337
+// on the code of the validating entities, i.e. everything but the entry point
+// itself. Entities may access the storage of the sender and the slots associated
+// with the sender in any contract, and their own storage if they're staked.
+type userOpTracer struct {
+	entryPoint common.Address
+	sender     common.Address
+	factory    common.Address // Zero if the sender is already deployed
+	paymaster  common.Address // Zero if the sender pays for itself
+
+	associated []*uint256.Int          // Hashes of data starting with the sender address
+	selfAccess map[common.Address]bool // Factory and paymaster accessing their own storage
+	gasOp      bool                    // Whether the previous opcode of an entity was GAS
+	err        error
+}
+
+// newUserOpTracer creates a tracer enforcing the rules on the entities of the
+// given user operation.
+func newUserOpTracer(op *types.UserOperation, entryPoint common.Address) *userOpTracer {
+	tracer := &userOpTracer{
+		entryPoint: entryPoint,
+		sender:     op.Sender,
+		selfAccess: make(map[common.Address]bool),
+	}
+	if factory := op.Factory(); factory != nil {
+		tracer.factory = *factory
+	}
+	if paymaster := op.Paymaster(); paymaster != nil {
+		tracer.paymaster = *paymaster
+	}
+	return tracer
+}
+
+// CaptureStart implements vm.Tracer.
+func (t *userOpTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements vm.Tracer, checking the opcodes used by the entities.
+func (t *userOpTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	if t.err != nil {
+		return nil
+	}
+	// Track the mapping keys derived from the sender, wherever they're hashed
+	if op == vm.SHA3 {
+		t.trackAssociated(memory, stack)
+	}
+	if contract.Address() == t.entryPoint {
+		return nil
+	}
+	// GAS is only allowed right before a call, to forward the remaining gas
+	if t.gasOp {
+		t.gasOp = false
+		switch op {
+		case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		default:
+			t.err = fmt.Errorf("forbidden opcode GAS used by %x", contract.Address())
+			return nil
+		}
+	}
+	if op == vm.GAS {
+		t.gasOp = true
+	}
+	if userOpForbiddenOps[op] {
+		t.err = fmt.Errorf("forbidden opcode %v used by %x", op, contract.Address())
+		return nil
+	}
+	if op == vm.SLOAD || op == vm.SSTORE {
+		t.checkStorage(contract.Address(), stack.Back(0))
+	}
+	return nil
+}
+
+// trackAssociated records the hash of the data about to be hashed if it starts
+// with the sender address, as the storage slots derived from it are associated
+// with the sender.
+func (t *userOpTracer) trackAssociated(memory *vm.Memory, stack *vm.Stack) {
+	offset, size := stack.Back(0), stack.Back(1)
+	if !offset.IsUint64() || !size.IsUint64() || size.Uint64() < 32 {
+		return
+	}
+	if offset.Uint64()+size.Uint64() > uint64(memory.Len()) {
+		return
+	}
+	data := memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
+	if common.BytesToAddress(data[:32]) != t.sender || !bytes.Equal(data[:12], make([]byte, 12)) {
+		return
+	}
+	t.associated = append(t.associated, new(uint256.Int).SetBytes(crypto.Keccak256(data)))
+}
+
+// checkStorage enforces the storage rules on an access to the storage of owner.
+func (t *userOpTracer) checkStorage(owner common.Address, slot *uint256.Int) {
+	if owner == t.sender {
+		return
+	}
+	// Slots associated with the sender may be accessed in any contract
+	if new(uint256.Int).SetBytes(t.sender.Bytes()).Eq(slot) {
+		return
+	}
+	for _, hash := range t.associated {
+		if slot.Lt(hash) {
+			continue
+		}
+		if diff := new(uint256.Int).Sub(slot, hash); diff.IsUint64() && diff.Uint64() <= userOpAssociatedSlots {
+			return
+		}
+	}
+	// Factories and paymasters may access their own storage if staked, which
+	// is only known once the validation result is in
+	if owner != (common.Address{}) && (owner == t.factory || owner == t.paymaster) {
+		t.selfAccess[owner] = true
+		return
+	}
+	t.err = fmt.Errorf("forbidden access to storage slot %#x of %x", slot.Bytes32(), owner)
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *userOpTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer.
+func (t *userOpTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import "sync/atomic"
+
+// ExecutionCheckpoint is a restorable point of the execution context of an EVM
+// between transactions: the state with its refund counter, logs and journal, the
+// transaction context, and the gas left to the caller (e.g. in the block). It
+// allows speculatively executing transactions, then retrying or dropping them
+// (bundle merging, parallel execution retries) with deterministic results.
+type ExecutionCheckpoint struct {
+	statedb StateDB
+	id      int
+	txCtx   TxContext
+	gas     uint64
+	refund  uint64
+}
+
+// Gas returns the gas left to the caller when the checkpoint was taken.
+func (cp *ExecutionCheckpoint) Gas() uint64 {
+	return cp.gas
+}
+
+// Refund returns the refund counter of the state when the checkpoint was taken.
+func (cp *ExecutionCheckpoint) Refund() uint64 {
+	return cp.refund
+}
+
+// Checkpoint captures the execution context of the EVM along with the gas left
+// to the caller. It must be released with DiscardCheckpoint.
+func (evm *EVM) Checkpoint(gas uint64) *ExecutionCheckpoint {
+	return &ExecutionCheckpoint{
+		statedb: evm.StateDB,
+		id:      evm.StateDB.Checkpoint(),
+		txCtx:   evm.TxContext,
+		gas:     gas,
+		refund:  evm.StateDB.GetRefund(),
+	}
+}
+
+// RestoreCheckpoint reverts the execution context of the EVM to the checkpoint,
+// returning the gas left to the caller at the time. A cancelled EVM is resumed.
+// The checkpoint remains valid and can be restored again.
+func (evm *EVM) RestoreCheckpoint(cp *ExecutionCheckpoint) uint64 {
+	cp.statedb.RestoreCheckpoint(cp.id)
+	evm.Reset(cp.txCtx, cp.statedb)
+	atomic.StoreInt32(&evm.abort, 0)
+	return cp.gas
+}
+
+// DiscardCheckpoint releases a checkpoint which is not needed anymore.
+func (evm *EVM) DiscardCheckpoint(cp *ExecutionCheckpoint) {
+	cp.statedb.DiscardCheckpoint(cp.id)
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that re-executions from a checkpoint are deterministic, using the same
+// gas and getting the same refunds as the first execution.
+func TestExecutionCheckpoint(t *testing.T) {
+	var (
+		contract = common.BytesToAddress([]byte("contract"))
+		key      = common.Hash{}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, hexutil.MustDecode("0x6000600055")) // PUSH1 0, PUSH1 0, SSTORE: clears slot 0
+	statedb.SetState(contract, key, common.HexToHash("0x01"))
+	statedb.Finalise(true)
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: new(big.Int),
+	}
+	origin := common.BytesToAddress([]byte("origin"))
+	vmenv := NewEVM(vmctx, TxContext{Origin: origin}, statedb, params.AllEthashProtocolChanges, Config{})
+
+	cp := vmenv.Checkpoint(1000000)
+	defer vmenv.DiscardCheckpoint(cp)
+
+	var leftGas, refund uint64
+	for i := 0; i < 3; i++ {
+		statedb.PrepareAccessList(origin, &contract, nil, nil)
+		_, left, err := vmenv.Call(AccountRef(origin), contract, nil, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("run %d: execution failed: %v", i, err)
+		}
+		if i == 0 {
+			leftGas, refund = left, statedb.GetRefund()
+			if refund == 0 {
+				t.Fatalf("run %d: no refund for clearing storage", i)
+			}
+		}
+		if left != leftGas || statedb.GetRefund() != refund {
+			t.Errorf("run %d: gas/refund mismatch: have %d/%d, want %d/%d", i, left, statedb.GetRefund(), leftGas, refund)
+		}
+		statedb.Finalise(true)
+
+		// Mess up the execution context, restoring must undo everything
+		vmenv.Origin = common.Address{}
+		vmenv.Cancel()
+		if gas := vmenv.RestoreCheckpoint(cp); gas != 1000000 {
+			t.Fatalf("run %d: restored gas mismatch: have %d, want %d", i, gas, 1000000)
+		}
+		if vmenv.Origin != origin || vmenv.Cancelled() {
+			t.Fatalf("run %d: execution context not restored", i)
+		}
+		if statedb.GetRefund() != cp.Refund() {
+			t.Fatalf("run %d: refund mismatch: have %d, want %d", i, statedb.GetRefund(), cp.Refund())
+		}
+		if have := statedb.GetState(contract, key); have != common.HexToHash("0x01") {
+			t.Fatalf("run %d: storage not restored: %x", i, have)
+		}
+	}
+}
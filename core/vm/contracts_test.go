@@ -21,10 +21,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/params"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -391,3 +393,28 @@ func BenchmarkPrecompiledBLS12381G2MultiExpWorstCase(b *testing.B) {
 	}
 	benchmarkPrecompiled("0f", testcase, b)
 }
+
+// Tests that the BLS12-381 precompiles are only active after their fork block.
+func TestPrecompiledBLS12381Activation(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.BLS12381Block = big.NewInt(10)
+
+	for _, tt := range []struct {
+		number uint64
+		active bool
+	}{{9, false}, {10, true}, {11, true}} {
+		evm := NewEVM(BlockContext{BlockNumber: new(big.Int).SetUint64(tt.number)}, TxContext{}, nil, &config, Config{})
+		for addr := range PrecompiledContractsBLS {
+			if _, ok := evm.precompile(addr); ok != tt.active {
+				t.Errorf("block %d: precompile %x activation mismatch: have %v, want %v", tt.number, addr, ok, tt.active)
+			}
+		}
+		if have, want := len(evm.ActivePrecompiles()), len(PrecompiledAddressesBerlin); tt.active {
+			if have != want+len(PrecompiledAddressesBLS) {
+				t.Errorf("block %d: active precompile count mismatch: have %d, want %d", tt.number, have, want+len(PrecompiledAddressesBLS))
+			}
+		} else if have != want {
+			t.Errorf("block %d: active precompile count mismatch: have %d, want %d", tt.number, have, want)
+		}
+	}
+}
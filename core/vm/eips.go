@@ -31,6 +31,14 @@ var activators = map[int]func(*JumpTable){
 	1344: enable1344,
 }
 
+// eipPrerequisites lists the opcodes modified by each EIP, which have to be
+// defined in the jump table the EIP is enabled on.
+var eipPrerequisites = map[int][]OpCode{
+	2929: {SSTORE, SLOAD, EXTCODECOPY, EXTCODESIZE, EXTCODEHASH, BALANCE, CALL, CALLCODE, STATICCALL, DELEGATECALL, SELFDESTRUCT},
+	2200: {SLOAD, SSTORE},
+	1884: {SLOAD, BALANCE, EXTCODEHASH},
+}
+
 // EnableEIP enables the given EIP on the config.
 // This operation writes in-place, and callers need to ensure that the globally
 // defined jump tables are not polluted.
@@ -39,6 +47,11 @@ func EnableEIP(eipNum int, jt *JumpTable) error {
 	if !ok {
 		return fmt.Errorf("undefined eip %d", eipNum)
 	}
+	for _, op := range eipPrerequisites[eipNum] {
+		if jt[op] == nil {
+			return fmt.Errorf("eip %d modifies opcode %v, undefined in the instruction set", eipNum, op)
+		}
+	}
 	enablerFn(jt)
 	return nil
 }
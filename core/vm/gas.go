@@ -33,11 +33,12 @@ const (
 // callGas returns the actual gas cost of the call.
 //
 // The cost of gas was changed during the homestead price change HF.
-// As part of EIP 150 (TangerineWhistle), the returned gas is gas - base * 63 / 64.
-func callGas(isEip150 bool, availableGas, base uint64, callCost *uint256.Int) (uint64, error) {
+// As part of EIP 150 (TangerineWhistle), the returned gas is gas - base * 63 / 64,
+// the divisor being configurable on custom chains.
+func callGas(isEip150 bool, divisor, availableGas, base uint64, callCost *uint256.Int) (uint64, error) {
 	if isEip150 {
 		availableGas = availableGas - base
-		gas := availableGas - availableGas/64
+		gas := availableGas - availableGas/divisor
 		// If the bit length exceeds 64 bit we know that the newly calculated "gas" for EIP150
 		// is smaller than the requested amount. Therefore we return the new gas instead
 		// of returning an error.
@@ -0,0 +1,246 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/acent/go-acent/common"
+)
+
+// OpcodeGas is the aggregated gas spent by a single opcode.
+type OpcodeGas struct {
+	Op    string `json:"op"`
+	Count uint64 `json:"count"` // Number of times the opcode was executed
+	Gas   uint64 `json:"gas"`   // Gas spent by the opcode itself, excluding sub-calls
+}
+
+// FrameGas is the gas spent by a single call frame.
+type FrameGas struct {
+	Type    string         `json:"type"` // Opcode which created the frame (CALL, CREATE, ...)
+	Depth   int            `json:"depth"`
+	Address common.Address `json:"address"`
+	GasUsed uint64         `json:"gasUsed"` // Gas used by the frame, including sub-calls
+	SelfGas uint64         `json:"selfGas"` // Gas used by the frame, excluding sub-calls
+	Error   string         `json:"error,omitempty"`
+}
+
+// GasProfile is the gas accounting report of an execution.
+type GasProfile struct {
+	Gas     uint64      `json:"gas"` // Total gas used by the execution, excluding intrinsic gas
+	Failed  bool        `json:"failed"`
+	Opcodes []OpcodeGas `json:"opcodes"` // Opcodes ordered by gas spent, most expensive first
+	Frames  []FrameGas  `json:"frames"`  // Call frames in the order they were entered
+}
+
+// profiledOp is an executed opcode whose gas usage is not yet known, as it can
+// only be deduced from the gas available to the next opcode of the same frame.
+type profiledOp struct {
+	op    OpCode
+	gas   uint64 // Gas available before executing the opcode
+	cost  uint64 // Gas cost reported by the interpreter
+	valid bool
+}
+
+// profiledFrame is the accounting state of an active call frame.
+type profiledFrame struct {
+	index    int        // Index of the frame in the profile
+	startGas uint64     // Gas available when entering the frame
+	pending  profiledOp // Last opcode executed in the frame
+	subGas   uint64     // Gas used by the sub-calls of the pending opcode
+	subTotal uint64     // Gas used by all sub-calls of the frame
+	err      error
+}
+
+// GasProfiler is an EVM tracer which aggregates the gas spent per opcode and per
+// call frame. The gas of call and create opcodes is attributed to the opcode
+// only to the extent it's not spent by the called code itself.
+//
+// GasProfiler implements Tracer.
+type GasProfiler struct {
+	opCount [256]uint64
+	opGas   [256]uint64
+	frames  []FrameGas
+	stack   []*profiledFrame
+
+	gasUsed uint64
+	err     error
+}
+
+// NewGasProfiler creates a new gas accounting tracer.
+func NewGasProfiler() *GasProfiler {
+	return new(GasProfiler)
+}
+
+// CaptureStart implements the Tracer interface to open the outermost frame.
+func (p *GasProfiler) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := CALL
+	if create {
+		typ = CREATE
+	}
+	p.enter(typ, to, gas)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to account the gas of the
+// previous opcode of the frame and track frame transitions.
+func (p *GasProfiler) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rData []byte, contract *Contract, depth int, err error) error {
+	p.unwind(depth)
+
+	if depth > len(p.stack) {
+		typ := CALL
+		if parent := p.top(); parent != nil && parent.pending.valid {
+			typ = parent.pending.op
+		}
+		p.enter(typ, contract.Address(), gas)
+	}
+	frame := p.top()
+	if frame.pending.valid {
+		p.account(frame.pending.op, subGas(frame.pending.gas, gas), frame.subGas)
+	}
+	frame.pending = profiledOp{op: op, gas: gas, cost: cost, valid: true}
+	frame.subGas = 0
+	if err != nil {
+		frame.err = err
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to record the failure of the
+// current frame.
+func (p *GasProfiler) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	p.unwind(depth)
+	if frame := p.top(); frame != nil {
+		frame.err = err
+	}
+	return nil
+}
+
+// CaptureEnd implements the Tracer interface to close all remaining frames.
+func (p *GasProfiler) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	p.unwind(1)
+	if frame := p.top(); frame != nil {
+		if err != nil && frame.err == nil {
+			frame.err = err
+		}
+		p.exit(frame, gasUsed)
+		p.stack = p.stack[:0]
+	}
+	p.gasUsed, p.err = gasUsed, err
+	return nil
+}
+
+// Profile returns the gas accounting report of the traced execution.
+func (p *GasProfiler) Profile() *GasProfile {
+	profile := &GasProfile{
+		Gas:     p.gasUsed,
+		Failed:  p.err != nil,
+		Opcodes: []OpcodeGas{},
+		Frames:  p.frames,
+	}
+	for op, count := range p.opCount {
+		if count > 0 {
+			profile.Opcodes = append(profile.Opcodes, OpcodeGas{Op: OpCode(op).String(), Count: count, Gas: p.opGas[op]})
+		}
+	}
+	// Order by gas, falling back to the opcode name to keep the report deterministic
+	sort.SliceStable(profile.Opcodes, func(i, j int) bool {
+		if profile.Opcodes[i].Gas != profile.Opcodes[j].Gas {
+			return profile.Opcodes[i].Gas > profile.Opcodes[j].Gas
+		}
+		return profile.Opcodes[i].Op < profile.Opcodes[j].Op
+	})
+	if profile.Frames == nil {
+		profile.Frames = []FrameGas{}
+	}
+	return profile
+}
+
+// top returns the innermost active frame, or nil if there's none.
+func (p *GasProfiler) top() *profiledFrame {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+// enter opens a new call frame.
+func (p *GasProfiler) enter(typ OpCode, addr common.Address, gas uint64) {
+	p.stack = append(p.stack, &profiledFrame{index: len(p.frames), startGas: gas})
+	p.frames = append(p.frames, FrameGas{Type: typ.String(), Depth: len(p.stack), Address: addr})
+}
+
+// unwind closes all frames deeper than the given depth, deducing their gas usage
+// from their last executed opcode.
+func (p *GasProfiler) unwind(depth int) {
+	for len(p.stack) > depth && len(p.stack) > 1 {
+		frame := p.top()
+
+		used := frame.startGas
+		if !frame.failed() && frame.pending.valid {
+			used -= subGas(frame.pending.gas, frame.pending.cost)
+		}
+		p.exit(frame, used)
+		p.stack = p.stack[:len(p.stack)-1]
+
+		parent := p.top()
+		parent.subGas += used
+		parent.subTotal += used
+	}
+}
+
+// exit accounts the last opcode of a frame and finalizes its report.
+func (p *GasProfiler) exit(frame *profiledFrame, used uint64) {
+	if frame.pending.valid {
+		// A failing opcode consumes all the gas left, otherwise it only costs
+		// what the interpreter charged for it
+		gas := frame.pending.cost
+		if frame.failed() {
+			gas = frame.pending.gas
+		}
+		p.account(frame.pending.op, gas, frame.subGas)
+		frame.pending.valid = false
+	}
+	report := &p.frames[frame.index]
+	report.GasUsed = used
+	report.SelfGas = subGas(used, frame.subTotal)
+	if frame.err != nil {
+		report.Error = frame.err.Error()
+	}
+}
+
+// account attributes the gas spent by an opcode, excluding the gas used by the
+// sub-calls it made.
+func (p *GasProfiler) account(op OpCode, gas, sub uint64) {
+	p.opCount[op]++
+	p.opGas[op] += subGas(gas, sub)
+}
+
+// failed reports whether the frame failed with all its remaining gas consumed.
+func (frame *profiledFrame) failed() bool {
+	return frame.err != nil && frame.err != ErrExecutionReverted
+}
+
+// subGas returns a-b, or zero if it would underflow.
+func subGas(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that the gas profiler attributes all the gas used by an execution to
+// opcodes and call frames, without counting sub-calls twice.
+func TestGasProfiler(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	// PUSH1 0 x5, PUSH20 callee, GAS, CALL, STOP
+	callerCode := append(hexutil.MustDecode("0x60006000600060006000"+"73"), callee.Bytes()...)
+	callerCode = append(callerCode, byte(GAS), byte(CALL), byte(STOP))
+
+	tests := []struct {
+		code      string
+		calleeOK  bool
+		calleeGas func(forwarded uint64) uint64
+	}{
+		// PUSH1 1, PUSH1 0, SSTORE (cold), STOP
+		{"0x600160005500", true, func(uint64) uint64 { return 3 + 3 + 2100 + 20000 }},
+		// PUSH1 0, PUSH1 0, REVERT
+		{"0x60006000fd", false, func(uint64) uint64 { return 3 + 3 }},
+		// INVALID
+		{"0xfe", false, func(forwarded uint64) uint64 { return forwarded }},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(caller, callerCode)
+		statedb.SetCode(callee, hexutil.MustDecode(tt.code))
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: new(big.Int),
+		}
+		profiler := NewGasProfiler()
+		vmenv := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{Debug: true, Tracer: profiler})
+
+		_, left, err := vmenv.Call(AccountRef(common.Address{}), caller, nil, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("test %d: execution failed: %v", i, err)
+		}
+		profile := profiler.Profile()
+		if used := 100000 - left; profile.Gas != used {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, profile.Gas, used)
+		}
+		// All gas must be accounted for exactly once, both by opcodes and frames
+		var opGas, selfGas uint64
+		for _, op := range profile.Opcodes {
+			opGas += op.Gas
+		}
+		for _, frame := range profile.Frames {
+			selfGas += frame.SelfGas
+		}
+		if opGas != profile.Gas {
+			t.Errorf("test %d: opcode gas mismatch: have %d, want %d", i, opGas, profile.Gas)
+		}
+		if selfGas != profile.Gas {
+			t.Errorf("test %d: frame gas mismatch: have %d, want %d", i, selfGas, profile.Gas)
+		}
+		if len(profile.Frames) != 2 {
+			t.Fatalf("test %d: frame count mismatch: have %d, want 2", i, len(profile.Frames))
+		}
+		outer, inner := profile.Frames[0], profile.Frames[1]
+		if outer.Address != caller || outer.Depth != 1 || outer.GasUsed != profile.Gas {
+			t.Errorf("test %d: outer frame mismatch: %+v", i, outer)
+		}
+		if inner.Address != callee || inner.Depth != 2 || inner.Type != "CALL" {
+			t.Errorf("test %d: inner frame mismatch: %+v", i, inner)
+		}
+		if (inner.Error == "") != tt.calleeOK {
+			t.Errorf("test %d: inner frame error mismatch: have %q", i, inner.Error)
+		}
+		// The caller forwards all but one 64th of the gas left after the pushes
+		// and the cold account access
+		forwarded := 100000 - 5*3 - 3 - 2 - 100 - 2500
+		forwarded -= forwarded / 64
+		if want := tt.calleeGas(uint64(forwarded)); inner.GasUsed != want {
+			t.Errorf("test %d: inner frame gas mismatch: have %d, want %d", i, inner.GasUsed, want)
+		}
+	}
+}
+
+// Tests that the opcode report is ordered deterministically.
+func TestGasProfilerOrder(t *testing.T) {
+	profiler := NewGasProfiler()
+	profiler.account(PUSH1, 3, 0)
+	profiler.account(DUP1, 3, 0)
+	profiler.account(SSTORE, 20000, 0)
+	profiler.account(ADD, 3, 0)
+
+	want := []OpcodeGas{
+		{Op: "SSTORE", Count: 1, Gas: 20000},
+		{Op: "ADD", Count: 1, Gas: 3},
+		{Op: "DUP1", Count: 1, Gas: 3},
+		{Op: "PUSH1", Count: 1, Gas: 3},
+	}
+	if have := profiler.Profile().Opcodes; !reflect.DeepEqual(have, want) {
+		t.Errorf("opcode order mismatch: have %+v, want %+v", have, want)
+	}
+}
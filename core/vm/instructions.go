@@ -570,7 +570,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]
 		gas          = callContext.contract.Gas
 	)
 	if interpreter.evm.chainRules.IsEIP150 {
-		gas -= gas / 64
+		gas -= gas / interpreter.evm.chainRules.CallGasDivisor
 	}
 	// reuse size int for stackvalue
 	stackvalue := size
@@ -613,7 +613,7 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([
 	)
 
 	// Apply EIP150
-	gas -= gas / 64
+	gas -= gas / interpreter.evm.chainRules.CallGasDivisor
 	callContext.contract.UseGas(gas)
 	// reuse size int for stackvalue
 	stackvalue := size
@@ -70,6 +70,13 @@ type StateDB interface {
 	RevertToSnapshot(int)
 	Snapshot() int
 
+	// Checkpoint captures the entire state, surviving transaction boundaries
+	// unlike snapshots. RestoreCheckpoint reverts to it any number of times
+	// until it's released by DiscardCheckpoint.
+	Checkpoint() int
+	RestoreCheckpoint(int)
+	DiscardCheckpoint(int)
+
 	AddLog(*types.Log)
 	AddPreimage(common.Hash, []byte)
 
@@ -96,24 +96,15 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	// the jump table was initialised. If it was not
 	// we'll set the default jump table.
 	if cfg.JumpTable[STOP] == nil {
-		var jt JumpTable
-		switch {
-		case evm.chainRules.IsBerlin:
-			jt = berlinInstructionSet
-		case evm.chainRules.IsIstanbul:
-			jt = istanbulInstructionSet
-		case evm.chainRules.IsConstantinople:
-			jt = constantinopleInstructionSet
-		case evm.chainRules.IsByzantium:
-			jt = byzantiumInstructionSet
-		case evm.chainRules.IsEIP158:
-			jt = spuriousDragonInstructionSet
-		case evm.chainRules.IsEIP150:
-			jt = tangerineWhistleInstructionSet
-		case evm.chainRules.IsHomestead:
-			jt = homesteadInstructionSet
-		default:
-			jt = frontierInstructionSet
+		jt, err := instructionSetForRules(evm.chainConfig, evm.chainRules)
+		if err != nil {
+			// Chain configs are checked by ValidateEVMForks when loaded, any other
+			// instruction set would silently fork the node off the network.
+			log.Crit("Invalid EVM fork configuration", "err", err)
+		}
+		if len(cfg.ExtraEips) > 0 {
+			// Don't modify the shared instruction sets
+			jt = jt.copy()
 		}
 		for i, eip := range cfg.ExtraEips {
 			if err := EnableEIP(eip, &jt); err != nil {
@@ -258,6 +249,9 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			if memorySize, overflow = math.SafeMul(toWordSize(memSize), 32); overflow {
 				return nil, ErrGasUintOverflow
 			}
+			if limit := in.evm.chainRules.MaxMemory; limit > 0 && memorySize > limit {
+				return nil, ErrMaxMemoryExceeded
+			}
 		}
 		// Dynamic portion of gas
 		// consume the gas and return an error if not enough gas is available.
@@ -17,7 +17,12 @@
 package vm
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+
 	"github.com/acent/go-acent/params"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 type (
@@ -48,69 +53,221 @@ type operation struct {
 	returns bool // determines whether the operations sets the return data content
 }
 
-var (
-	frontierInstructionSet         = newFrontierInstructionSet()
-	homesteadInstructionSet        = newHomesteadInstructionSet()
-	tangerineWhistleInstructionSet = newTangerineWhistleInstructionSet()
-	spuriousDragonInstructionSet   = newSpuriousDragonInstructionSet()
-	byzantiumInstructionSet        = newByzantiumInstructionSet()
-	constantinopleInstructionSet   = newConstantinopleInstructionSet()
-	istanbulInstructionSet         = newIstanbulInstructionSet()
-	berlinInstructionSet           = newBerlinInstructionSet()
-)
-
 // JumpTable contains the EVM opcodes supported at a given fork.
 type JumpTable [256]*operation
 
-// newBerlinInstructionSet returns the frontier, homestead, byzantium,
-// contantinople, istanbul, petersburg and berlin instructions.
-func newBerlinInstructionSet() JumpTable {
-	instructionSet := newIstanbulInstructionSet()
-	enable2929(&instructionSet) // Access lists for trie accesses https://eips.acent.org/EIPS/eip-2929
-	return instructionSet
+// copy returns a deep copy of the jump table, so that forks can modify the
+// operations without polluting the instruction sets they are derived from.
+func (jt *JumpTable) copy() JumpTable {
+	var cpy JumpTable
+	for i, op := range jt {
+		if op != nil {
+			opCopy := *op
+			cpy[i] = &opCopy
+		}
+	}
+	return cpy
+}
+
+// instructionSetDelta is the set of changes a fork applies to the instruction
+// set of its predecessor.
+type instructionSetDelta struct {
+	name   string                  // Fork name, for diagnostics
+	active func(params.Rules) bool // Whether the fork is active under the given rules
+	apply  func(jt *JumpTable)     // Modifications made by the fork
+}
+
+// instructionSetDeltas are the built-in forks in activation order. Every fork
+// is applied on top of the instruction set produced by the previous ones.
+var instructionSetDeltas = []instructionSetDelta{
+	{"homestead", func(r params.Rules) bool { return r.IsHomestead }, applyHomestead},
+	{"tangerineWhistle", func(r params.Rules) bool { return r.IsEIP150 }, applyTangerineWhistle},
+	{"spuriousDragon", func(r params.Rules) bool { return r.IsEIP158 }, applySpuriousDragon},
+	{"byzantium", func(r params.Rules) bool { return r.IsByzantium }, applyByzantium},
+	{"constantinople", func(r params.Rules) bool { return r.IsConstantinople }, applyConstantinople},
+	{"istanbul", func(r params.Rules) bool { return r.IsIstanbul }, applyIstanbul},
+	{"berlin", func(r params.Rules) bool { return r.IsBerlin }, applyBerlin},
+}
+
+// instructionSets are the precomputed instruction sets of the built-in forks:
+// the first one is the frontier set, every subsequent one has one more delta
+// from instructionSetDeltas applied.
+var instructionSets = newInstructionSets()
+
+// newInstructionSets builds the instruction sets of all built-in forks.
+func newInstructionSets() []JumpTable {
+	sets := []JumpTable{newFrontierInstructionSet()}
+	for _, delta := range instructionSetDeltas {
+		jt := sets[len(sets)-1].copy()
+		delta.apply(&jt)
+		sets = append(sets, jt)
+	}
+	return sets
+}
+
+// customSetKey identifies an instruction set derived from a chain config's
+// data driven forks.
+type customSetKey struct {
+	config *params.ChainConfig
+	base   int // Index of the built-in instruction set the forks are applied on
+	forks  int // Number of data driven forks applied
+}
+
+// customSetsLimit is the number of instruction sets produced by data driven forks
+// which are cached. A chain config has one per fork transition, the bound keeps
+// short lived configs (e.g. copies made for RPC calls) from accumulating.
+const customSetsLimit = 64
+
+// customSets caches the instruction sets produced by data driven forks, since
+// they would otherwise need to be rebuilt for every interpreter.
+var customSets, _ = lru.New(customSetsLimit) // customSetKey -> JumpTable
+
+// baseInstructionSet returns the index of the latest built-in instruction set
+// active under the given chain rules.
+func baseInstructionSet(rules params.Rules) int {
+	for i := len(instructionSetDeltas) - 1; i >= 0; i-- {
+		if instructionSetDeltas[i].active(rules) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// instructionSetForRules returns the instruction set mandated by the given
+// chain rules: the latest active built-in fork, with the active data driven
+// forks of the chain config layered on top.
+func instructionSetForRules(config *params.ChainConfig, rules params.Rules) (JumpTable, error) {
+	base := baseInstructionSet(rules)
+	if len(rules.EVMForks) == 0 {
+		return instructionSets[base], nil
+	}
+	key := customSetKey{config: config, base: base, forks: len(rules.EVMForks)}
+	if jt, ok := customSets.Get(key); ok {
+		return jt.(JumpTable), nil
+	}
+	jt, err := buildInstructionSet(base, rules.EVMForks)
+	if err != nil {
+		return JumpTable{}, err
+	}
+	customSets.Add(key, jt)
+	return jt, nil
+}
+
+// buildInstructionSet layers the data driven forks on top of a built-in
+// instruction set.
+func buildInstructionSet(base int, forks []*params.EVMFork) (JumpTable, error) {
+	jt := instructionSets[base].copy()
+	for _, fork := range forks {
+		if err := applyEVMFork(&jt, fork); err != nil {
+			return JumpTable{}, err
+		}
+	}
+	return jt, nil
+}
+
+// applyEVMFork applies a data driven fork to the given jump table. The EIPs are
+// enabled first, after which opcodes are disabled and repriced.
+func applyEVMFork(jt *JumpTable, fork *params.EVMFork) error {
+	for _, eip := range fork.EIPs {
+		if err := EnableEIP(eip, jt); err != nil {
+			return fmt.Errorf("evm fork %q: %v", fork.Name, err)
+		}
+	}
+	for _, name := range fork.Disable {
+		op, ok := stringToOp[name]
+		if !ok {
+			return fmt.Errorf("evm fork %q: unknown opcode %q", fork.Name, name)
+		}
+		jt[op] = nil
+	}
+	for name, gas := range fork.Reprice {
+		op, ok := stringToOp[name]
+		if !ok {
+			return fmt.Errorf("evm fork %q: unknown opcode %q", fork.Name, name)
+		}
+		if jt[op] == nil {
+			return fmt.Errorf("evm fork %q: cannot reprice undefined opcode %s", fork.Name, name)
+		}
+		jt[op].constantGas = gas
+	}
+	return nil
 }
 
-// newIstanbulInstructionSet returns the frontier, homestead, byzantium,
-// contantinople, istanbul and petersburg instructions.
-func newIstanbulInstructionSet() JumpTable {
-	instructionSet := newConstantinopleInstructionSet()
+// ValidateEVMForks checks that the data driven forks of a chain config can be
+// applied on top of the built-in instruction sets. It is meant to be called on
+// configuration load, to reject invalid configs before any block is processed.
+//
+// The forks are layered on the built-in set active at the block, so they are
+// checked at every block where either a data driven or a built-in fork activates.
+func ValidateEVMForks(config *params.ChainConfig) error {
+	var blocks []*big.Int
+	for _, fork := range config.EVMForks {
+		if fork == nil {
+			return errors.New("empty evm fork definition")
+		}
+		if fork.Block != nil {
+			blocks = append(blocks, fork.Block)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	for _, fork := range config.ForkSchedule() {
+		if fork.Block != nil {
+			blocks = append(blocks, fork.Block)
+		}
+	}
+	for _, num := range blocks {
+		rules := config.Rules(num)
+		if len(rules.EVMForks) == 0 {
+			continue
+		}
+		if _, err := buildInstructionSet(baseInstructionSet(rules), rules.EVMForks); err != nil {
+			return fmt.Errorf("%v (at block %v)", err, num)
+		}
+	}
+	return nil
+}
 
-	enable1344(&instructionSet) // ChainID opcode - https://eips.acent.org/EIPS/eip-1344
-	enable1884(&instructionSet) // Reprice reader opcodes - https://eips.acent.org/EIPS/eip-1884
-	enable2200(&instructionSet) // Net metered SSTORE - https://eips.acent.org/EIPS/eip-2200
+// applyBerlin applies the berlin changes.
+func applyBerlin(jt *JumpTable) {
+	enable2929(jt) // Access lists for trie accesses https://eips.acent.org/EIPS/eip-2929
+}
 
-	return instructionSet
+// applyIstanbul applies the istanbul and petersburg changes.
+func applyIstanbul(jt *JumpTable) {
+	enable1344(jt) // ChainID opcode - https://eips.acent.org/EIPS/eip-1344
+	enable1884(jt) // Reprice reader opcodes - https://eips.acent.org/EIPS/eip-1884
+	enable2200(jt) // Net metered SSTORE - https://eips.acent.org/EIPS/eip-2200
 }
 
-// newConstantinopleInstructionSet returns the frontier, homestead,
-// byzantium and contantinople instructions.
-func newConstantinopleInstructionSet() JumpTable {
-	instructionSet := newByzantiumInstructionSet()
-	instructionSet[SHL] = &operation{
+// applyConstantinople applies the contantinople changes.
+func applyConstantinople(jt *JumpTable) {
+	jt[SHL] = &operation{
 		execute:     opSHL,
 		constantGas: GasFastestStep,
 		minStack:    minStack(2, 1),
 		maxStack:    maxStack(2, 1),
 	}
-	instructionSet[SHR] = &operation{
+	jt[SHR] = &operation{
 		execute:     opSHR,
 		constantGas: GasFastestStep,
 		minStack:    minStack(2, 1),
 		maxStack:    maxStack(2, 1),
 	}
-	instructionSet[SAR] = &operation{
+	jt[SAR] = &operation{
 		execute:     opSAR,
 		constantGas: GasFastestStep,
 		minStack:    minStack(2, 1),
 		maxStack:    maxStack(2, 1),
 	}
-	instructionSet[EXTCODEHASH] = &operation{
+	jt[EXTCODEHASH] = &operation{
 		execute:     opExtCodeHash,
 		constantGas: params.ExtcodeHashGasConstantinople,
 		minStack:    minStack(1, 1),
 		maxStack:    maxStack(1, 1),
 	}
-	instructionSet[CREATE2] = &operation{
+	jt[CREATE2] = &operation{
 		execute:     opCreate2,
 		constantGas: params.Create2Gas,
 		dynamicGas:  gasCreate2,
@@ -120,14 +277,11 @@ func newConstantinopleInstructionSet() JumpTable {
 		writes:      true,
 		returns:     true,
 	}
-	return instructionSet
 }
 
-// newByzantiumInstructionSet returns the frontier, homestead and
-// byzantium instructions.
-func newByzantiumInstructionSet() JumpTable {
-	instructionSet := newSpuriousDragonInstructionSet()
-	instructionSet[STATICCALL] = &operation{
+// applyByzantium applies the byzantium changes.
+func applyByzantium(jt *JumpTable) {
+	jt[STATICCALL] = &operation{
 		execute:     opStaticCall,
 		constantGas: params.CallGasEIP150,
 		dynamicGas:  gasStaticCall,
@@ -136,13 +290,13 @@ func newByzantiumInstructionSet() JumpTable {
 		memorySize:  memoryStaticCall,
 		returns:     true,
 	}
-	instructionSet[RETURNDATASIZE] = &operation{
+	jt[RETURNDATASIZE] = &operation{
 		execute:     opReturnDataSize,
 		constantGas: GasQuickStep,
 		minStack:    minStack(0, 1),
 		maxStack:    maxStack(0, 1),
 	}
-	instructionSet[RETURNDATACOPY] = &operation{
+	jt[RETURNDATACOPY] = &operation{
 		execute:     opReturnDataCopy,
 		constantGas: GasFastestStep,
 		dynamicGas:  gasReturnDataCopy,
@@ -150,7 +304,7 @@ func newByzantiumInstructionSet() JumpTable {
 		maxStack:    maxStack(3, 0),
 		memorySize:  memoryReturnDataCopy,
 	}
-	instructionSet[REVERT] = &operation{
+	jt[REVERT] = &operation{
 		execute:    opRevert,
 		dynamicGas: gasRevert,
 		minStack:   minStack(2, 0),
@@ -159,35 +313,27 @@ func newByzantiumInstructionSet() JumpTable {
 		reverts:    true,
 		returns:    true,
 	}
-	return instructionSet
 }
 
-// EIP 158 a.k.a Spurious Dragon
-func newSpuriousDragonInstructionSet() JumpTable {
-	instructionSet := newTangerineWhistleInstructionSet()
-	instructionSet[EXP].dynamicGas = gasExpEIP158
-	return instructionSet
-
+// applySpuriousDragon applies the EIP 158 a.k.a Spurious Dragon changes.
+func applySpuriousDragon(jt *JumpTable) {
+	jt[EXP].dynamicGas = gasExpEIP158
 }
 
-// EIP 150 a.k.a Tangerine Whistle
-func newTangerineWhistleInstructionSet() JumpTable {
-	instructionSet := newHomesteadInstructionSet()
-	instructionSet[BALANCE].constantGas = params.BalanceGasEIP150
-	instructionSet[EXTCODESIZE].constantGas = params.ExtcodeSizeGasEIP150
-	instructionSet[SLOAD].constantGas = params.SloadGasEIP150
-	instructionSet[EXTCODECOPY].constantGas = params.ExtcodeCopyBaseEIP150
-	instructionSet[CALL].constantGas = params.CallGasEIP150
-	instructionSet[CALLCODE].constantGas = params.CallGasEIP150
-	instructionSet[DELEGATECALL].constantGas = params.CallGasEIP150
-	return instructionSet
+// applyTangerineWhistle applies the EIP 150 a.k.a Tangerine Whistle changes.
+func applyTangerineWhistle(jt *JumpTable) {
+	jt[BALANCE].constantGas = params.BalanceGasEIP150
+	jt[EXTCODESIZE].constantGas = params.ExtcodeSizeGasEIP150
+	jt[SLOAD].constantGas = params.SloadGasEIP150
+	jt[EXTCODECOPY].constantGas = params.ExtcodeCopyBaseEIP150
+	jt[CALL].constantGas = params.CallGasEIP150
+	jt[CALLCODE].constantGas = params.CallGasEIP150
+	jt[DELEGATECALL].constantGas = params.CallGasEIP150
 }
 
-// newHomesteadInstructionSet returns the frontier and homestead
-// instructions that can be executed during the homestead phase.
-func newHomesteadInstructionSet() JumpTable {
-	instructionSet := newFrontierInstructionSet()
-	instructionSet[DELEGATECALL] = &operation{
+// applyHomestead applies the homestead changes.
+func applyHomestead(jt *JumpTable) {
+	jt[DELEGATECALL] = &operation{
 		execute:     opDelegateCall,
 		dynamicGas:  gasDelegateCall,
 		constantGas: params.CallGasFrontier,
@@ -196,7 +342,6 @@ func newHomesteadInstructionSet() JumpTable {
 		memorySize:  memoryDelegateCall,
 		returns:     true,
 	}
-	return instructionSet
 }
 
 // newFrontierInstructionSet returns the frontier instructions
@@ -0,0 +1,174 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/acent/go-acent/params"
+)
+
+// Tests that the built-in instruction sets are selected according to the
+// chain rules and don't share operations with each other.
+func TestInstructionSetForRules(t *testing.T) {
+	tests := []struct {
+		config *params.ChainConfig
+		check  func(jt JumpTable) bool
+	}{
+		// Frontier: no DELEGATECALL yet
+		{&params.ChainConfig{}, func(jt JumpTable) bool { return jt[DELEGATECALL] == nil }},
+		// Tangerine whistle: repriced SLOAD
+		{&params.ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(0)}, func(jt JumpTable) bool {
+			return jt[SLOAD].constantGas == params.SloadGasEIP150 && jt[STATICCALL] == nil
+		}},
+		// Istanbul: CHAINID defined and SLOAD repriced again
+		{&params.ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(0), EIP158Block: big.NewInt(0), ByzantiumBlock: big.NewInt(0), ConstantinopleBlock: big.NewInt(0), IstanbulBlock: big.NewInt(0)}, func(jt JumpTable) bool {
+			return jt[CHAINID] != nil && jt[SLOAD].constantGas == params.SloadGasEIP1884 && jt[SLOAD].dynamicGas == nil
+		}},
+		// Berlin: SLOAD fully dynamic
+		{params.AllEthashProtocolChanges, func(jt JumpTable) bool {
+			return jt[SLOAD].constantGas == 0 && jt[SLOAD].dynamicGas != nil
+		}},
+	}
+	number := big.NewInt(0)
+	for i, tt := range tests {
+		jt, err := instructionSetForRules(tt.config, tt.config.Rules(number))
+		if err != nil {
+			t.Fatalf("test %d: failed to build instruction set: %v", i, err)
+		}
+		if !tt.check(jt) {
+			t.Errorf("test %d: instruction set mismatch", i)
+		}
+	}
+	// Forks must not alter the sets of their predecessors
+	if instructionSets[0][SLOAD] == instructionSets[1][SLOAD] {
+		t.Errorf("instruction sets share operations")
+	}
+	if gas := instructionSets[0][SLOAD].constantGas; gas != params.SloadGasFrontier {
+		t.Errorf("frontier SLOAD polluted: have %d, want %d", gas, params.SloadGasFrontier)
+	}
+}
+
+// Tests that data driven forks from the chain config are layered on top of the
+// built-in instruction sets at their activation blocks.
+func TestEVMForks(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.EVMForks = []*params.EVMFork{
+		{Name: "first", Block: big.NewInt(10), Disable: []string{"SELFDESTRUCT"}},
+		{Name: "second", Block: big.NewInt(20), Reprice: map[string]uint64{"BALANCE": 1234}},
+	}
+	if err := ValidateEVMForks(&config); err != nil {
+		t.Fatalf("failed to validate forks: %v", err)
+	}
+	jt, _ := instructionSetForRules(&config, config.Rules(big.NewInt(9)))
+	if jt[SELFDESTRUCT] == nil {
+		t.Errorf("fork activated early")
+	}
+	jt, _ = instructionSetForRules(&config, config.Rules(big.NewInt(10)))
+	if jt[SELFDESTRUCT] != nil || jt[BALANCE].constantGas == 1234 {
+		t.Errorf("first fork not applied alone")
+	}
+	jt, _ = instructionSetForRules(&config, config.Rules(big.NewInt(20)))
+	if jt[SELFDESTRUCT] != nil || jt[BALANCE].constantGas != 1234 {
+		t.Errorf("forks not layered")
+	}
+	// The built-in instruction sets must remain untouched
+	if berlin := instructionSets[len(instructionSets)-1]; berlin[SELFDESTRUCT] == nil || berlin[BALANCE].constantGas == 1234 {
+		t.Errorf("built-in instruction set polluted")
+	}
+	// Invalid forks should be rejected
+	config.EVMForks = append(config.EVMForks, &params.EVMFork{Name: "third", Block: big.NewInt(30), Reprice: map[string]uint64{"SELFDESTRUCT": 1}})
+	if err := ValidateEVMForks(&config); err == nil {
+		t.Errorf("repricing disabled opcode accepted")
+	}
+	config.EVMForks[2] = &params.EVMFork{Name: "third", Block: big.NewInt(30), Disable: []string{"NOSUCHOP"}}
+	if err := ValidateEVMForks(&config); err == nil {
+		t.Errorf("unknown opcode accepted")
+	}
+}
+
+// Tests that data driven forks are validated against the built-in instruction
+// set active at their activation block, and at every later built-in fork.
+func TestEVMForksValidationBase(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.IstanbulBlock = big.NewInt(50)
+	config.MuirGlacierBlock = big.NewInt(50)
+	config.BerlinBlock = big.NewInt(50)
+
+	// CHAINID only exists from istanbul on, so repricing it earlier is invalid
+	config.EVMForks = []*params.EVMFork{{Name: "early", Block: big.NewInt(10), Reprice: map[string]uint64{"CHAINID": 5}}}
+	if err := ValidateEVMForks(&config); err == nil {
+		t.Errorf("fork repricing opcode undefined at its activation accepted")
+	}
+	config.EVMForks[0].Block = big.NewInt(50)
+	if err := ValidateEVMForks(&config); err != nil {
+		t.Errorf("fork valid at its activation rejected: %v", err)
+	}
+	// Opcodes disabled by a fork may not be reintroduced by a later built-in fork
+	config.EVMForks = []*params.EVMFork{
+		{Name: "disable", Block: big.NewInt(10), Disable: []string{"SLOAD"}},
+	}
+	if err := ValidateEVMForks(&config); err != nil {
+		t.Errorf("valid fork rejected: %v", err)
+	}
+	config.EVMForks = append(config.EVMForks, &params.EVMFork{Name: "eip", Block: big.NewInt(20), EIPs: []int{2929}})
+	if err := ValidateEVMForks(&config); err == nil {
+		t.Errorf("enabling EIP on disabled opcode accepted")
+	}
+}
+
+// Tests that EIPs are rejected on instruction sets lacking the opcodes they
+// modify, instead of crashing.
+func TestEnableEIPPrerequisites(t *testing.T) {
+	frontier := instructionSets[0].copy()
+	if err := EnableEIP(2929, &frontier); err == nil {
+		t.Errorf("eip 2929 enabled without STATICCALL")
+	}
+	if frontier[SLOAD].dynamicGas != nil {
+		t.Errorf("rejected eip partially applied")
+	}
+	istanbul := instructionSets[len(instructionSets)-2].copy()
+	if err := EnableEIP(2929, &istanbul); err != nil {
+		t.Errorf("eip 2929 rejected on istanbul: %v", err)
+	}
+}
+
+// Tests that data driven forks which can't be applied are rejected, and that an
+// interpreter is never run with an instruction set other than the configured one.
+func TestEVMForksInvalidConfig(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.EVMForks = []*params.EVMFork{{Name: "bad", Block: big.NewInt(0), Disable: []string{"NOSUCHOP"}}}
+
+	if err := ValidateEVMForks(&config); err == nil {
+		t.Fatalf("invalid evm fork accepted")
+	}
+	// Creating an interpreter for the config must abort the process instead of
+	// running any other instruction set, check it in a child process
+	if os.Getenv("EVM_FORKS_INVALID_CONFIG") == "1" {
+		NewEVM(BlockContext{BlockNumber: big.NewInt(1)}, TxContext{}, nil, &config, Config{})
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEVMForksInvalidConfig$")
+	cmd.Env = append(os.Environ(), "EVM_FORKS_INVALID_CONFIG=1")
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+		t.Fatalf("interpreter created for invalid evm forks: %v", err)
+	}
+}
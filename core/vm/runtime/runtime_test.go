@@ -18,6 +18,7 @@ package runtime
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"os"
 	"strings"
@@ -124,6 +125,76 @@ func TestCall(t *testing.T) {
 	}
 }
 
+// Tests that the interpreter limits configured in the chain config are enforced.
+func TestEVMLimits(t *testing.T) {
+	newConfig := func(limits *params.EVMLimits) *Config {
+		chainConfig := *params.AllEthashProtocolChanges
+		chainConfig.EVMLimits = limits
+		return &Config{ChainConfig: &chainConfig}
+	}
+	// Expanding memory beyond the limit must fail, up to it must succeed
+	expand := []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH2), 0x10, 0x00,
+		byte(vm.MSTORE),
+	}
+	if _, _, err := Execute(expand, nil, newConfig(&params.EVMLimits{MaxMemory: 1024})); err != vm.ErrMaxMemoryExceeded {
+		t.Errorf("memory limit error mismatch: have %v, want %v", err, vm.ErrMaxMemoryExceeded)
+	}
+	if _, _, err := Execute(expand, nil, newConfig(&params.EVMLimits{MaxMemory: 8192})); err != nil {
+		t.Errorf("memory expansion within limit failed: %v", err)
+	}
+	// Deploying code larger than the limit must fail
+	deploy := []byte{
+		byte(vm.PUSH1), 100,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	if _, _, _, err := Create(deploy, newConfig(&params.EVMLimits{MaxCodeSize: 50})); err != vm.ErrMaxCodeSizeExceeded {
+		t.Errorf("code size limit error mismatch: have %v, want %v", err, vm.ErrMaxCodeSizeExceeded)
+	}
+	if _, _, _, err := Create(deploy, newConfig(nil)); err != nil {
+		t.Errorf("deployment within default limit failed: %v", err)
+	}
+	// Recursing beyond the call depth must stop at the limit: the code counts
+	// its invocations in slot 0 and calls itself
+	recurse := []byte{
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.ADDRESS), byte(vm.GAS), byte(vm.CALL),
+	}
+	_, statedb, err := Execute(recurse, nil, newConfig(&params.EVMLimits{MaxCallDepth: 5}))
+	if err != nil {
+		t.Fatalf("recursion failed: %v", err)
+	}
+	// The outermost call runs at depth 0, so one more frame than the limit executes
+	if calls := statedb.GetState(common.BytesToAddress([]byte("contract")), common.Hash{}); calls != common.BigToHash(big.NewInt(6)) {
+		t.Errorf("call depth mismatch: have %d invocations, want 6", calls.Big())
+	}
+	// Calls must forward all but the configured part of the available gas: the
+	// callee stores the gas it received (minus the cost of GAS) in slot 0
+	forward := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0xff, byte(vm.GAS), byte(vm.CALL),
+	}
+	received := func(limits *params.EVMLimits) uint64 {
+		config := newConfig(limits)
+		config.State, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		config.State.SetCode(common.BytesToAddress([]byte{0xff}), []byte{byte(vm.GAS), byte(vm.PUSH1), 0, byte(vm.SSTORE)})
+		if _, _, err := Execute(forward, nil, config); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		return config.State.GetState(common.BytesToAddress([]byte{0xff}), common.Hash{}).Big().Uint64() + vm.GasQuickStep
+	}
+	available := received(&params.EVMLimits{CallGasDivisor: math.MaxUint64})
+	if have, want := received(nil), available-available/64; have != want {
+		t.Errorf("default forwarded gas mismatch: have %d, want %d", have, want)
+	}
+	if have, want := received(&params.EVMLimits{CallGasDivisor: 2}), available-available/2; have != want {
+		t.Errorf("forwarded gas mismatch: have %d, want %d", have, want)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -0,0 +1,118 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package acent
+
+import "fmt"
+
+// JSON-RPC error codes of the typed API errors.
+const (
+	ServerErrorCode         = -32000 // Generic server error, kept by the errors predating the typed ones
+	TransactionRejectedCode = -32003 // Transaction creation failed, as proposed by EIP-1474
+)
+
+// Error is a typed error of the Acent RPC API. The server reports it with its
+// code and its kind as the error data, by which the client recognizes it again.
+//
+// Errors match the sentinel errors of the same kind with errors.Is regardless of
+// their message, so they can be wrapped in context by the server and checked
+// without string matching by the client:
+//
+//	if errors.Is(err, acent.ErrBlockNotFound) { ... }
+//
+// The not found errors also match NotFound, which the client returned for them
+// before.
+type Error struct {
+	Code    int    // JSON-RPC error code
+	Kind    string // Machine readable identifier of the error
+	Message string // Human readable description
+}
+
+// Typed errors of the Acent RPC API.
+var (
+	// ErrBlockNotFound is returned if the requested block or header is unknown.
+	ErrBlockNotFound = &Error{Code: ServerErrorCode, Kind: "block", Message: "header not found"}
+
+	// ErrTxNotFound is returned if the requested transaction is unknown.
+	ErrTxNotFound = &Error{Code: ServerErrorCode, Kind: "transaction", Message: "transaction not found"}
+
+	// ErrStatePruned is returned if the state of the requested block is not
+	// available any more, e.g. because it was pruned.
+	ErrStatePruned = &Error{Code: ServerErrorCode, Kind: "state", Message: "state not available"}
+
+	// ErrPaused is returned if the requested data is not available because the
+	// service producing it is paused, e.g. the snapshot generation.
+	ErrPaused = &Error{Code: ServerErrorCode, Kind: "paused", Message: "paused"}
+
+	// ErrConditionsNotMet is returned if a conditional transaction is rejected
+	// because its conditions are not met.
+	ErrConditionsNotMet = &Error{Code: TransactionRejectedCode, Kind: "conditions", Message: "conditions not met"}
+)
+
+// apiErrors are the sentinel errors by their kind.
+var apiErrors = map[string]*Error{
+	ErrBlockNotFound.Kind:    ErrBlockNotFound,
+	ErrTxNotFound.Kind:       ErrTxNotFound,
+	ErrStatePruned.Kind:      ErrStatePruned,
+	ErrPaused.Kind:           ErrPaused,
+	ErrConditionsNotMet.Kind: ErrConditionsNotMet,
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the JSON-RPC error code.
+func (e *Error) ErrorCode() int {
+	return e.Code
+}
+
+// ErrorData returns the kind of the error, transferred as the error data.
+func (e *Error) ErrorData() interface{} {
+	return e.Kind
+}
+
+// Errorf returns an error of the same kind with the given message, keeping the
+// messages the API reported before the error was typed.
+func (e *Error) Errorf(format string, a ...interface{}) *Error {
+	return &Error{Code: e.Code, Kind: e.Kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// Is reports whether the target is an error of the same kind, or NotFound for
+// the not found errors.
+func (e *Error) Is(target error) bool {
+	if target == NotFound {
+		return e.Kind == ErrBlockNotFound.Kind || e.Kind == ErrTxNotFound.Kind
+	}
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// ParseError recreates the typed error from the code, message and data of an
+// error received from the RPC API. It returns nil if the error is not a known
+// typed error.
+func ParseError(code int, message string, data interface{}) *Error {
+	kind, ok := data.(string)
+	if !ok {
+		return nil
+	}
+	sentinel, ok := apiErrors[kind]
+	if !ok || sentinel.Code != code {
+		return nil
+	}
+	return &Error{Code: code, Kind: kind, Message: message}
+}
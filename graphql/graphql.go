@@ -831,7 +831,7 @@ func (b *Block) Call(ctx context.Context, args struct {
 			return nil, err
 		}
 	}
-	result, err := ethapi.DoCall(ctx, b.backend, args.Data, *b.numberOrHash, nil, vm.Config{}, 5*time.Second, b.backend.RPCGasCap())
+	result, err := ethapi.DoCall(ctx, b.backend, args.Data, *b.numberOrHash, nil, nil, vm.Config{}, 5*time.Second, b.backend.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -856,7 +856,7 @@ func (b *Block) EstimateGas(ctx context.Context, args struct {
 			return 0, err
 		}
 	}
-	gas, err := ethapi.DoEstimateGas(ctx, b.backend, args.Data, *b.numberOrHash, b.backend.RPCGasCap())
+	gas, err := ethapi.DoEstimateGas(ctx, b.backend, args.Data, *b.numberOrHash, nil, nil, b.backend.RPCGasCap())
 	return Long(gas), err
 }
 
@@ -901,7 +901,7 @@ func (p *Pending) Call(ctx context.Context, args struct {
 	Data ethapi.CallArgs
 }) (*CallResult, error) {
 	pendingBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
-	result, err := ethapi.DoCall(ctx, p.backend, args.Data, pendingBlockNr, nil, vm.Config{}, 5*time.Second, p.backend.RPCGasCap())
+	result, err := ethapi.DoCall(ctx, p.backend, args.Data, pendingBlockNr, nil, nil, vm.Config{}, 5*time.Second, p.backend.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -921,7 +921,7 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 	Data ethapi.CallArgs
 }) (Long, error) {
 	pendingBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
-	gas, err := ethapi.DoEstimateGas(ctx, p.backend, args.Data, pendingBlockNr, p.backend.RPCGasCap())
+	gas, err := ethapi.DoEstimateGas(ctx, p.backend, args.Data, pendingBlockNr, nil, nil, p.backend.RPCGasCap())
 	return Long(gas), err
 }
 
@@ -970,6 +970,32 @@ func (r *Resolver) Block(ctx context.Context, args struct {
 	return block, nil
 }
 
+func (r *Resolver) SafeBlock(ctx context.Context) (*Block, error) {
+	return r.labelledBlock(ctx, rpc.SafeBlockNumber)
+}
+
+func (r *Resolver) FinalizedBlock(ctx context.Context) (*Block, error) {
+	return r.labelledBlock(ctx, rpc.FinalizedBlockNumber)
+}
+
+// labelledBlock resolves a block label into the block it currently points to. The
+// block is pinned by hash, so its fields are consistent even if the label moves.
+func (r *Resolver) labelledBlock(ctx context.Context, label rpc.BlockNumber) (*Block, error) {
+	header, err := r.backend.HeaderByNumber(ctx, label)
+	if err != nil {
+		return nil, err
+	} else if header == nil {
+		return nil, nil
+	}
+	numberOrHash := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+	return &Block{
+		backend:      r.backend,
+		numberOrHash: &numberOrHash,
+		header:       header,
+		hash:         header.Hash(),
+	}, nil
+}
+
 func (r *Resolver) Blocks(ctx context.Context, args struct {
 	From *Long
 	To   *Long
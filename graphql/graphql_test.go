@@ -127,6 +127,11 @@ func TestGraphQLBlockSerialization(t *testing.T) {
 			want: `{"errors":[{"message":"Cannot query field \"bleh\" on type \"Query\".","locations":[{"line":1,"column":2}]}]}`,
 			code: 400,
 		},
+		{
+			body: `{"query": "{safeBlock{number},finalizedBlock{number}}","variables": null}`,
+			want: `{"data":{"safeBlock":{"number":8},"finalizedBlock":{"number":5}}}`,
+			code: 200,
+		},
 		// should return `estimateGas` as decimal
 		{
 			body: `{"query": "{block{ estimateGas(data:{}) }}"}`,
@@ -208,6 +213,8 @@ func createGQLService(t *testing.T, stack *node.Node) {
 		TrieDirtyCache:          5,
 		TrieTimeout:             60 * time.Minute,
 		SnapshotCache:           5,
+		RPCSafeDepth:            2,
+		RPCFinalizedDepth:       5,
 	}
 	ethBackend, err := eth.New(stack, ethConf)
 	if err != nil {
@@ -301,6 +301,12 @@ const schema string = `
         # Blocks returns all the blocks between two numbers, inclusive. If
         # to is not supplied, it defaults to the most recent known block.
         blocks(from: Long, to: Long): [Block!]!
+        # SafeBlock returns the most recent block which is unlikely to be
+        # reorganised out of the canonical chain.
+        safeBlock: Block
+        # FinalizedBlock returns the most recent block which can no longer be
+        # reorganised out of the canonical chain.
+        finalizedBlock: Block
         # Pending returns the current pending state.
         pending: Pending!
         # Transaction returns a transaction specified by its hash.
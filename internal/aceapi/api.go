@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/accounts"
 	"github.com/acent/go-acent/accounts/abi"
 	"github.com/acent/go-acent/accounts/keystore"
@@ -36,6 +37,8 @@ import (
 	"github.com/acent/go-acent/consensus/clique"
 	"github.com/acent/go-acent/consensus/ethash"
 	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/core/vm"
 	"github.com/acent/go-acent/crypto"
@@ -192,14 +195,17 @@ type PrivateAccountAPI struct {
 	am        *accounts.Manager
 	nonceLock *AddrLocker
 	b         Backend
+	hooks     *Hooks
 }
 
-// NewPrivateAccountAPI create a new PrivateAccountAPI.
-func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker) *PrivateAccountAPI {
+// NewPrivateAccountAPI create a new PrivateAccountAPI, customised by the given
+// hooks, which may be nil.
+func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker, hooks *Hooks) *PrivateAccountAPI {
 	return &PrivateAccountAPI{
 		am:        b.AccountManager(),
 		nonceLock: nonceLock,
 		b:         b,
+		hooks:     hooks,
 	}
 }
 
@@ -214,22 +220,22 @@ type rawWallet struct {
 	URL      string             `json:"url"`
 	Status   string             `json:"status"`
 	Failure  string             `json:"failure,omitempty"`
+	Opened   bool               `json:"opened"`
 	Accounts []accounts.Account `json:"accounts,omitempty"`
 }
 
 // ListWallets will return a list of wallets this node manages.
 func (s *PrivateAccountAPI) ListWallets() []rawWallet {
 	wallets := make([]rawWallet, 0) // return [] instead of nil if empty
-	for _, wallet := range s.am.Wallets() {
-		status, failure := wallet.Status()
-
+	for _, wallet := range s.am.WalletStatuses() {
 		raw := rawWallet{
-			URL:      wallet.URL().String(),
-			Status:   status,
-			Accounts: wallet.Accounts(),
+			URL:      wallet.URL.String(),
+			Status:   wallet.Status,
+			Opened:   wallet.Opened,
+			Accounts: wallet.Accounts,
 		}
-		if failure != nil {
-			raw.Failure = failure.Error()
+		if wallet.Failure != nil {
+			raw.Failure = wallet.Failure.Error()
 		}
 		wallets = append(wallets, raw)
 	}
@@ -358,7 +364,7 @@ func (s *PrivateAccountAPI) signTransaction(ctx context.Context, args *SendTxArg
 		return nil, err
 	}
 	// Set some sanity defaults and terminate on failure
-	if err := args.setDefaults(ctx, s.b); err != nil {
+	if err := s.hooks.prepareTxArgs(ctx, s.b, args); err != nil {
 		return nil, err
 	}
 	// Assemble the transaction and sign with the wallet
@@ -521,12 +527,14 @@ func (s *PrivateAccountAPI) Unpair(ctx context.Context, url string, pin string)
 // PublicBlockChainAPI provides an API to access the Acent blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
-	b Backend
+	b     Backend
+	hooks *Hooks
 }
 
-// NewPublicBlockChainAPI creates a new Acent blockchain API.
-func NewPublicBlockChainAPI(b Backend) *PublicBlockChainAPI {
-	return &PublicBlockChainAPI{b}
+// NewPublicBlockChainAPI creates a new Acent blockchain API, customised by the
+// given hooks, which may be nil.
+func NewPublicBlockChainAPI(b Backend, hooks *Hooks) *PublicBlockChainAPI {
+	return &PublicBlockChainAPI{b: b, hooks: hooks}
 }
 
 // ChainId returns the chainID value for transaction replay protection.
@@ -567,13 +575,136 @@ type StorageResult struct {
 	Proof []string     `json:"proof"`
 }
 
+const (
+	// defaultProofReexec is the number of blocks the proof generator is willing
+	// to go back and reexecute to regenerate the state of a historical block.
+	defaultProofReexec = uint64(128)
+
+	// maxProofBatch is the maximum number of accounts proven in a single call.
+	maxProofBatch = 256
+)
+
+// ProofRequest is an account and the storage keys to prove in a batched
+// proof request.
+type ProofRequest struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
+
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
+//
+// If the state of the requested block is not available any more, it's proven
+// from the snapshot tree if the block is recent enough, or regenerated from the
+// closest retained state otherwise, as long as it's not too old.
 func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
-	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
+	prove, release, err := s.proofState(ctx, blockNrOrHash)
+	if prove == nil || err != nil {
 		return nil, err
 	}
+	defer release()
+
+	return prove(address, storageKeys)
+}
+
+// GetProofs returns the Merkle-proofs for a batch of accounts and optionally
+// some storage keys of each, all from the same state.
+func (s *PublicBlockChainAPI) GetProofs(ctx context.Context, requests []ProofRequest, blockNrOrHash rpc.BlockNumberOrHash) ([]*AccountResult, error) {
+	if len(requests) > maxProofBatch {
+		return nil, fmt.Errorf("too many accounts requested: %d > %d", len(requests), maxProofBatch)
+	}
+	prove, release, err := s.proofState(ctx, blockNrOrHash)
+	if prove == nil || err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results := make([]*AccountResult, len(requests))
+	for i, req := range requests {
+		if results[i], err = prove(req.Address, req.StorageKeys); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// proofFunc creates the Merkle-proof for a given account and optionally some
+// storage keys in a fixed state.
+type proofFunc func(address common.Address, storageKeys []string) (*AccountResult, error)
+
+// proofState retrieves the state of the requested block to prove accounts in.
+// If the state was already pruned, the accounts are proven from the snapshot
+// tree if it still maintains the state, otherwise the state is regenerated by
+// reexecuting blocks on top of the closest retained state.
+func (s *PublicBlockChainAPI) proofState(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (proofFunc, func(), error) {
+	statedb, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb != nil && err == nil {
+		return stateProof(statedb), func() {}, nil
+	}
+	if header == nil {
+		return nil, nil, err
+	}
+	if prover, perr := s.b.StateProver(ctx, header); perr == nil {
+		return snapshotProof(prover), func() {}, nil
+	}
+	block, berr := s.b.BlockByHash(ctx, header.Hash())
+	if block == nil || berr != nil {
+		return nil, nil, err
+	}
+	statedb, release, err := s.b.StateAtBlock(ctx, block, defaultProofReexec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stateProof(statedb), release, nil
+}
+
+// stateProof returns a proof function creating the proofs from the given state.
+func stateProof(state *state.StateDB) proofFunc {
+	return func(address common.Address, storageKeys []string) (*AccountResult, error) {
+		return accountProof(state, address, storageKeys)
+	}
+}
+
+// snapshotProof returns a proof function creating the proofs from the tries
+// rebuilt by the given snapshot prover.
+func snapshotProof(prover *snapshot.Prover) proofFunc {
+	return func(address common.Address, storageKeys []string) (*AccountResult, error) {
+		account, proof, err := prover.AccountProof(address)
+		if err != nil {
+			return nil, err
+		}
+		result := &AccountResult{
+			Address:      address,
+			AccountProof: toHexSlice(proof),
+			Balance:      new(hexutil.Big),
+			CodeHash:     crypto.Keccak256Hash(nil),
+			StorageHash:  types.EmptyRootHash,
+			StorageProof: make([]StorageResult, len(storageKeys)),
+		}
+		if account == nil {
+			for i, key := range storageKeys {
+				result.StorageProof[i] = StorageResult{key, &hexutil.Big{}, []string{}}
+			}
+			return result, nil
+		}
+		result.Balance = (*hexutil.Big)(account.Balance)
+		result.CodeHash = common.BytesToHash(account.CodeHash)
+		result.Nonce = hexutil.Uint64(account.Nonce)
+		result.StorageHash = common.BytesToHash(account.Root)
+
+		for i, key := range storageKeys {
+			value, proof, err := prover.StorageProof(address, common.HexToHash(key))
+			if err != nil {
+				return nil, err
+			}
+			result.StorageProof[i] = StorageResult{key, (*hexutil.Big)(value.Big()), toHexSlice(proof)}
+		}
+		return result, nil
+	}
+}
 
+// accountProof creates the Merkle-proof for a given account and optionally some
+// storage keys in the given state.
+func accountProof(state *state.StateDB, address common.Address, storageKeys []string) (*AccountResult, error) {
 	storageTrie := state.StorageTrie(address)
 	storageHash := types.EmptyRootHash
 	codeHash := state.GetCodeHash(address)
@@ -620,6 +751,7 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 // GetHeaderByNumber returns the requested canonical block header.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
+// * When blockNr is -3 or -4 the finalized or safe block is returned.
 func (s *PublicBlockChainAPI) GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error) {
 	header, err := s.b.HeaderByNumber(ctx, number)
 	if header != nil && err == nil {
@@ -647,6 +779,7 @@ func (s *PublicBlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.H
 // GetBlockByNumber returns the requested canonical block.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
+// * When blockNr is -3 or -4 the finalized or safe block is returned.
 // * When fullTx is true all transactions in the block are returned, otherwise
 //   only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
@@ -674,6 +807,67 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Ha
 	return nil, err
 }
 
+// GetBlockReceipts returns the receipts of all the transactions in the given block,
+// saving clients from retrieving them one by one. It returns nil if the block is
+// not found.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(receipts), len(txs))
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+	}
+	return result, nil
+}
+
+// BlockRewards are the mining rewards paid for a block.
+type BlockRewards struct {
+	Miner  common.Address `json:"miner"`
+	Reward *hexutil.Big   `json:"reward"` // Block reward plus the bonuses for including uncles
+	Uncles []UncleReward  `json:"uncles"`
+}
+
+// UncleReward is the mining reward paid for an uncle included in a block.
+type UncleReward struct {
+	Miner  common.Address `json:"miner"`
+	Reward *hexutil.Big   `json:"reward"`
+}
+
+// GetBlockRewards returns the mining rewards paid for the given block according
+// to the reward schedule of the chain. It returns nil if the block is not found.
+func (s *PublicBlockChainAPI) GetBlockRewards(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*BlockRewards, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	config := s.b.ChainConfig()
+	if config.Clique != nil {
+		return nil, errors.New("no mining rewards on proof-of-authority chains")
+	}
+	rewards := &BlockRewards{Miner: block.Coinbase(), Reward: new(hexutil.Big), Uncles: []UncleReward{}}
+	if block.NumberU64() == 0 {
+		return rewards, nil // Genesis allocations aren't rewards
+	}
+	reward, uncleRewards := ethash.BlockRewards(config, block.Header(), block.Uncles())
+	rewards.Reward = (*hexutil.Big)(reward)
+	for i, uncle := range block.Uncles() {
+		rewards.Uncles = append(rewards.Uncles, UncleReward{Miner: uncle.Coinbase, Reward: (*hexutil.Big)(uncleRewards[i])})
+	}
+	return rewards, nil
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
 // all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
@@ -812,15 +1006,15 @@ type account struct {
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
 
-func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]account, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]account
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
-		return nil, err
+// Apply overrides the fields of specified accounts into the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
 	}
-	// Override the fields of specified contracts before execution.
-	for addr, account := range overrides {
+	for addr, account := range *diff {
 		// Override account nonce.
 		if account.Nonce != nil {
 			state.SetNonce(addr, uint64(*account.Nonce))
@@ -834,7 +1028,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			state.SetBalance(addr, (*big.Int)(*account.Balance))
 		}
 		if account.State != nil && account.StateDiff != nil {
-			return nil, fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
 		}
 		// Replace entire state if caller requires.
 		if account.State != nil {
@@ -847,6 +1041,45 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			}
 		}
 	}
+	return nil
+}
+
+// BlockOverrides is a set of header fields to override during the execution of
+// a message call.
+// Note, the overrides are only visible to the executed code (NUMBER, TIMESTAMP,
+// COINBASE), the fork rules are still those of the block the call is run on.
+type BlockOverrides struct {
+	Number   *hexutil.Big    `json:"number"`
+	Time     *hexutil.Uint64 `json:"time"`
+	Coinbase *common.Address `json:"coinbase"`
+}
+
+// Apply overrides the given header fields into the given block context.
+func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
+	if diff == nil {
+		return
+	}
+	if diff.Number != nil {
+		blockCtx.BlockNumber = diff.Number.ToInt()
+	}
+	if diff.Time != nil {
+		blockCtx.Time = new(big.Int).SetUint64(uint64(*diff.Time))
+	}
+	if diff.Coinbase != nil {
+		blockCtx.Coinbase = *diff.Coinbase
+	}
+}
+
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
 	var cancel context.CancelFunc
@@ -865,6 +1098,8 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	if err != nil {
 		return nil, err
 	}
+	blockOverrides.Apply(&evm.Context)
+
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
 	go func() {
@@ -919,18 +1154,41 @@ func (e *revertError) ErrorData() interface{} {
 	return e.reason
 }
 
+// returnDataTruncatedError is an API error reporting call return data larger
+// than the configured cap, with the data up to the cap.
+type returnDataTruncatedError struct {
+	size, limit uint64
+	data        hexutil.Bytes // Return data cut at the cap
+}
+
+func (e *returnDataTruncatedError) Error() string {
+	return fmt.Sprintf("return data size %d exceeds cap %d", e.size, e.limit)
+}
+
+// ErrorCode returns the JSON error code for an exceeded limit.
+func (e *returnDataTruncatedError) ErrorCode() int {
+	return -32005
+}
+
+// ErrorData returns the truncated return data and its full size.
+func (e *returnDataTruncatedError) ErrorData() interface{} {
+	return &struct {
+		Truncated bool           `json:"truncated"`
+		Size      hexutil.Uint64 `json:"size"`
+		Limit     hexutil.Uint64 `json:"limit"`
+		Data      hexutil.Bytes  `json:"data"`
+	}{true, hexutil.Uint64(e.size), hexutil.Uint64(e.limit), e.data}
+}
+
 // Call executes the given transaction on the state for the given block number.
 //
-// Additionally, the caller can specify a batch of contract for fields overriding.
+// Additionally, the caller can specify a batch of contract for fields overriding
+// and a set of block header fields to override.
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]account) (hexutil.Bytes, error) {
-	var accounts map[common.Address]account
-	if overrides != nil {
-		accounts = *overrides
-	}
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Bytes, error) {
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, blockOverrides, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -938,10 +1196,18 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOr
 	if len(result.Revert()) > 0 {
 		return nil, newRevertError(result)
 	}
+	// Don't return more data than the cap allows.
+	if limit := s.b.RPCReturnDataCap(); limit != 0 && uint64(len(result.Return())) > limit {
+		return nil, &returnDataTruncatedError{
+			size:  uint64(len(result.Return())),
+			limit: limit,
+			data:  result.Return()[:limit],
+		}
+	}
 	return result.Return(), result.Err
 }
 
-func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, gasCap uint64) (hexutil.Uint64, error) {
+func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, gasCap uint64) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -962,7 +1228,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 			return 0, err
 		}
 		if block == nil {
-			return 0, errors.New("block not found")
+			return 0, acent.ErrBlockNotFound.Errorf("block not found")
 		}
 		hi = block.GasLimit()
 	}
@@ -972,6 +1238,9 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 		if err != nil {
 			return 0, err
 		}
+		if err := overrides.Apply(state); err != nil {
+			return 0, err
+		}
 		balance := state.GetBalance(*args.From) // from can't be nil
 		available := new(big.Int).Set(balance)
 		if args.Value != nil {
@@ -1004,7 +1273,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 	executable := func(gas uint64) (bool, *core.ExecutionResult, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
 
-		result, err := DoCall(ctx, b, args, blockNrOrHash, nil, vm.Config{}, 0, gasCap)
+		result, err := DoCall(ctx, b, args, blockNrOrHash, overrides, blockOverrides, vm.Config{}, 0, gasCap)
 		if err != nil {
 			if errors.Is(err, core.ErrIntrinsicGas) {
 				return true, nil, nil // Special case, raise gas limit
@@ -1051,13 +1320,14 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+// given transaction against the current pending block, optionally with some
+// accounts and block header fields overridden.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Uint64, error) {
 	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
+	return DoEstimateGas(ctx, s.b, args, bNrOrHash, overrides, blockOverrides, s.b.RPCGasCap())
 }
 
 // ExecutionResult groups all structured logs emitted by the EVM
@@ -1185,6 +1455,7 @@ func RPCMarshalBlock(block *types.Block, inclTx bool, fullTx bool) (map[string]i
 func (s *PublicBlockChainAPI) rpcMarshalHeader(ctx context.Context, header *types.Header) map[string]interface{} {
 	fields := RPCMarshalHeader(header)
 	fields["totalDifficulty"] = (*hexutil.Big)(s.b.GetTd(ctx, header.Hash()))
+	s.hooks.marshalHeader(ctx, header, fields)
 	return fields
 }
 
@@ -1198,7 +1469,10 @@ func (s *PublicBlockChainAPI) rpcMarshalBlock(ctx context.Context, b *types.Bloc
 	if inclTx {
 		fields["totalDifficulty"] = (*hexutil.Big)(s.b.GetTd(ctx, b.Hash()))
 	}
-	return fields, err
+	if err := s.hooks.marshalBlock(ctx, b, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
 }
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
@@ -1217,9 +1491,23 @@ type RPCTransaction struct {
 	Type             hexutil.Uint64    `json:"type"`
 	Accesses         *types.AccessList `json:"accessList,omitempty"`
 	ChainID          *hexutil.Big      `json:"chainId,omitempty"`
+	BlobHashes       []common.Hash     `json:"blobHashes,omitempty"`
 	V                *hexutil.Big      `json:"v"`
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
+	PoolStatus       *RPCPoolStatus    `json:"poolStatus,omitempty"`
+}
+
+// RPCPoolStatus is the local transaction pool's view of a transaction which is
+// not yet included in the chain.
+//
+// The peer count is an approximation: only peers which announced the transaction
+// or had it sent to them are counted, and peers forget old transactions once
+// their bounded sets of known hashes overflow, so it may undercount.
+type RPCPoolStatus struct {
+	Status    string         `json:"status"`    // Either "pending" or "queued"
+	FirstSeen hexutil.Uint64 `json:"firstSeen"` // Unix time the transaction was first seen locally
+	Peers     hexutil.Uint   `json:"peers"`     // Approximate number of peers known to have the transaction
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1257,11 +1545,14 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.BlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
 		result.TransactionIndex = (*hexutil.Uint64)(&index)
 	}
-	if tx.Type() == types.AccessListTxType {
+	if tx.Type() == types.AccessListTxType || tx.Type() == types.SidecarTxType {
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
 	}
+	if tx.Type() == types.SidecarTxType {
+		result.BlobHashes = tx.BlobHashes()
+	}
 	return result
 }
 
@@ -1304,14 +1595,16 @@ type PublicTransactionPoolAPI struct {
 	b         Backend
 	nonceLock *AddrLocker
 	signer    types.Signer
+	hooks     *Hooks
 }
 
-// NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool.
-func NewPublicTransactionPoolAPI(b Backend, nonceLock *AddrLocker) *PublicTransactionPoolAPI {
+// NewPublicTransactionPoolAPI creates a new RPC service with methods specific for the transaction pool,
+// customised by the given hooks, which may be nil.
+func NewPublicTransactionPoolAPI(b Backend, nonceLock *AddrLocker, hooks *Hooks) *PublicTransactionPoolAPI {
 	// The signer used by the API should always be the 'latest' known one because we expect
 	// signers to be backwards-compatible with old transactions.
 	signer := types.LatestSigner(b.ChainConfig())
-	return &PublicTransactionPoolAPI{b, nonceLock, signer}
+	return &PublicTransactionPoolAPI{b: b, nonceLock: nonceLock, signer: signer, hooks: hooks}
 }
 
 // GetBlockTransactionCountByNumber returns the number of transactions in the block with the given block number.
@@ -1395,7 +1688,20 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx), nil
+		result := newRPCPendingTransaction(tx)
+
+		status, peers := s.b.GetPoolTransactionStatus(hash)
+		if status != core.TxStatusUnknown {
+			result.PoolStatus = &RPCPoolStatus{
+				Status:    "pending",
+				FirstSeen: hexutil.Uint64(tx.Time().Unix()),
+				Peers:     hexutil.Uint(peers),
+			}
+			if status == core.TxStatusQueued {
+				result.PoolStatus.Status = "queued"
+			}
+		}
+		return result, nil
 	}
 
 	// Transaction unknown, return as such
@@ -1432,18 +1738,22 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if len(receipts) <= int(index) {
 		return nil, nil
 	}
-	receipt := receipts[index]
-
-	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+	return marshalReceipt(receipts[index], blockHash, blockNumber, signer, tx, int(index)), nil
+}
+
+// marshalReceipt converts a receipt into the JSON form returned by the RPC API,
+// amended with the fields of its transaction.
+func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int) map[string]interface{} {
+	// Derive the sender.
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
-		"transactionIndex":  hexutil.Uint64(index),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(txIndex),
 		"from":              from,
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
@@ -1467,7 +1777,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1553,7 +1863,7 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 			AccessList: args.AccessList,
 		}
 		pendingBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
-		estimated, err := DoEstimateGas(ctx, b, callArgs, pendingBlockNr, b.RPCGasCap())
+		estimated, err := DoEstimateGas(ctx, b, callArgs, pendingBlockNr, nil, nil, b.RPCGasCap())
 		if err != nil {
 			return err
 		}
@@ -1604,6 +1914,12 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 
 // SubmitTransaction is a helper function that submits tx to txPool and logs a message.
 func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	return submitTransaction(ctx, b, tx, false, nil)
+}
+
+// submitTransaction submits tx to the txPool, privately if requested so it is
+// never propagated, or under the given conditions if any, and logs a message.
+func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, private bool, conditions *core.TxConditions) (common.Hash, error) {
 	// If the transaction fee cap is already specified, ensure the
 	// fee of the given transaction is _reasonable_.
 	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
@@ -1613,7 +1929,16 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 		// Ensure only eip155 signed transactions are submitted if EIP155Required is set.
 		return common.Hash{}, errors.New("only replay-protected (EIP-155) transactions allowed over RPC")
 	}
-	if err := b.SendTx(ctx, tx); err != nil {
+	send := b.SendTx
+	if private {
+		send = b.SendPrivateTx
+	}
+	if conditions != nil {
+		send = func(ctx context.Context, tx *types.Transaction) error {
+			return b.SendConditionalTx(ctx, tx, conditions)
+		}
+	}
+	if err := send(ctx, tx); err != nil {
 		return common.Hash{}, err
 	}
 	// Print a log with full tx details for manual investigations and interventions
@@ -1625,9 +1950,9 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 
 	if tx.To() == nil {
 		addr := crypto.CreateAddress(from, tx.Nonce())
-		log.Info("Submitted contract creation", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "contract", addr.Hex(), "value", tx.Value())
+		log.Info("Submitted contract creation", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "contract", addr.Hex(), "value", tx.Value(), "private", private)
 	} else {
-		log.Info("Submitted transaction", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "recipient", tx.To(), "value", tx.Value())
+		log.Info("Submitted transaction", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "recipient", tx.To(), "value", tx.Value(), "private", private)
 	}
 	return tx.Hash(), nil
 }
@@ -1651,7 +1976,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	}
 
 	// Set some sanity defaults and terminate on failure
-	if err := args.setDefaults(ctx, s.b); err != nil {
+	if err := s.hooks.prepareTxArgs(ctx, s.b, &args); err != nil {
 		return common.Hash{}, err
 	}
 	// Assemble the transaction and sign with the wallet
@@ -1668,7 +1993,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 // and returns it to the caller for further processing (signing + broadcast)
 func (s *PublicTransactionPoolAPI) FillTransaction(ctx context.Context, args SendTxArgs) (*SignTransactionResult, error) {
 	// Set some sanity defaults and terminate on failure
-	if err := args.setDefaults(ctx, s.b); err != nil {
+	if err := s.hooks.prepareTxArgs(ctx, s.b, &args); err != nil {
 		return nil, err
 	}
 	// Assemble the transaction and obtain rlp
@@ -1734,7 +2059,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if args.Nonce == nil {
 		return nil, fmt.Errorf("nonce not specified")
 	}
-	if err := args.setDefaults(ctx, s.b); err != nil {
+	if err := s.hooks.prepareTxArgs(ctx, s.b, &args); err != nil {
 		return nil, err
 	}
 	// Before actually sign the transaction, ensure the transaction fee is reasonable.
@@ -1781,7 +2106,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	if sendArgs.Nonce == nil {
 		return common.Hash{}, fmt.Errorf("missing transaction nonce in transaction spec")
 	}
-	if err := sendArgs.setDefaults(ctx, s.b); err != nil {
+	if err := s.hooks.prepareTxArgs(ctx, s.b, &sendArgs); err != nil {
 		return common.Hash{}, err
 	}
 	matchTx := sendArgs.toTransaction()
@@ -1824,7 +2149,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 			return signedTx.Hash(), nil
 		}
 	}
-	return common.Hash{}, fmt.Errorf("transaction %#x not found", matchTx.Hash())
+	return common.Hash{}, acent.ErrTxNotFound.Errorf("transaction %#x not found", matchTx.Hash())
 }
 
 // PublicDebugAPI is the collection of Acent APIs exposed over the public
@@ -1843,7 +2168,7 @@ func NewPublicDebugAPI(b Backend) *PublicDebugAPI {
 func (api *PublicDebugAPI) GetBlockRlp(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", acent.ErrBlockNotFound.Errorf("block #%d not found", number)
 	}
 	encoded, err := rlp.EncodeToBytes(block)
 	if err != nil {
@@ -1860,7 +2185,7 @@ func (api *PublicDebugAPI) GetBlockRlp(ctx context.Context, number uint64) (stri
 func (api *PublicDebugAPI) TestSignCliqueBlock(ctx context.Context, address common.Address, number uint64) (common.Address, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return common.Address{}, fmt.Errorf("block #%d not found", number)
+		return common.Address{}, acent.ErrBlockNotFound.Errorf("block #%d not found", number)
 	}
 	header := block.Header()
 	header.Extra = make([]byte, 32+65)
@@ -1895,7 +2220,7 @@ func (api *PublicDebugAPI) TestSignCliqueBlock(ctx context.Context, address comm
 func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", acent.ErrBlockNotFound.Errorf("block #%d not found", number)
 	}
 	return spew.Sdump(block), nil
 }
@@ -1904,11 +2229,43 @@ func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (strin
 func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
 	if block == nil {
-		return "", fmt.Errorf("block #%d not found", number)
+		return "", acent.ErrBlockNotFound.Errorf("block #%d not found", number)
 	}
 	return fmt.Sprintf("0x%x", ethash.SeedHash(number)), nil
 }
 
+// ForkScheduleEntry is a block based fork in the effective fork schedule of the
+// chain, as reported by debug_forkSchedule.
+type ForkScheduleEntry struct {
+	Name       string       `json:"name"`
+	Block      *hexutil.Big `json:"block"`              // Effective switch block, nil if unscheduled
+	Original   *hexutil.Big `json:"original,omitempty"` // Bundled switch block, if overridden
+	Overridden bool         `json:"overridden"`
+	Active     bool         `json:"active"` // Whether the fork is active at the current head
+}
+
+// ForkSchedule returns the effective fork schedule of the chain, including any
+// forks rescheduled at startup through the override flags.
+func (api *PublicDebugAPI) ForkSchedule() []ForkScheduleEntry {
+	var (
+		head     = api.b.CurrentHeader().Number
+		schedule []ForkScheduleEntry
+	)
+	for _, fork := range api.b.ChainConfig().ForkSchedule() {
+		entry := ForkScheduleEntry{
+			Name:       fork.Name,
+			Block:      (*hexutil.Big)(fork.Block),
+			Overridden: fork.Overridden,
+			Active:     fork.Block != nil && fork.Block.Cmp(head) <= 0,
+		}
+		if fork.Overridden {
+			entry.Original = (*hexutil.Big)(fork.Original)
+		}
+		schedule = append(schedule, entry)
+	}
+	return schedule
+}
+
 // PrivateDebugAPI is the collection of Acent APIs exposed over the private
 // debugging endpoint.
 type PrivateDebugAPI struct {
@@ -0,0 +1,312 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rpc"
+	"github.com/acent/go-acent/trie"
+)
+
+// Tests that state overrides decoded from their RPC form are applied to the
+// state, replacing or patching the storage as requested.
+func TestStateOverrideApply(t *testing.T) {
+	var (
+		replaced = common.HexToAddress("0x01")
+		patched  = common.HexToAddress("0x02")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetState(replaced, common.Hash{0x01}, common.Hash{0x01})
+	statedb.SetState(patched, common.Hash{0x01}, common.Hash{0x01})
+
+	var overrides StateOverride
+	err := json.Unmarshal([]byte(`{
+		"0x0000000000000000000000000000000000000001": {
+			"nonce": "0x5",
+			"code": "0x6000",
+			"balance": "0x64",
+			"state": {"0x0200000000000000000000000000000000000000000000000000000000000000": "0x0000000000000000000000000000000000000000000000000000000000000002"}
+		},
+		"0x0000000000000000000000000000000000000002": {
+			"stateDiff": {"0x0200000000000000000000000000000000000000000000000000000000000000": "0x0000000000000000000000000000000000000000000000000000000000000002"}
+		}
+	}`), &overrides)
+	if err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	if err := overrides.Apply(statedb); err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if nonce := statedb.GetNonce(replaced); nonce != 5 {
+		t.Errorf("nonce mismatch: have %d, want 5", nonce)
+	}
+	if code := statedb.GetCode(replaced); !bytes.Equal(code, []byte{0x60, 0x00}) {
+		t.Errorf("code mismatch: have %x, want 6000", code)
+	}
+	if balance := statedb.GetBalance(replaced); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 100", balance)
+	}
+	// The full state override drops the previous slots, the diff keeps them
+	if value := statedb.GetState(replaced, common.Hash{0x01}); value != (common.Hash{}) {
+		t.Errorf("replaced storage retained old slot: %x", value)
+	}
+	if value := statedb.GetState(patched, common.Hash{0x01}); value != (common.Hash{0x01}) {
+		t.Errorf("patched storage lost old slot: %x", value)
+	}
+	for _, addr := range []common.Address{replaced, patched} {
+		if value := statedb.GetState(addr, common.Hash{0x02}); value != common.BigToHash(big.NewInt(2)) {
+			t.Errorf("account %x: overridden slot mismatch: have %x, want 2", addr, value)
+		}
+	}
+	// Nil overrides are a no-op, conflicting ones are rejected
+	var empty *StateOverride
+	if err := empty.Apply(statedb); err != nil {
+		t.Errorf("nil overrides failed: %v", err)
+	}
+	storage := map[common.Hash]common.Hash{}
+	conflict := StateOverride{replaced: {State: &storage, StateDiff: &storage}}
+	if err := conflict.Apply(statedb); err == nil {
+		t.Error("overrides with both state and stateDiff accepted")
+	}
+}
+
+// Tests that block overrides only replace the fields they specify.
+func TestBlockOverridesApply(t *testing.T) {
+	blockCtx := vm.BlockContext{
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(10),
+		Coinbase:    common.HexToAddress("0x01"),
+	}
+	var overrides BlockOverrides
+	if err := json.Unmarshal([]byte(`{"number": "0x64", "coinbase": "0x0000000000000000000000000000000000000002"}`), &overrides); err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	overrides.Apply(&blockCtx)
+
+	if blockCtx.BlockNumber.Uint64() != 100 {
+		t.Errorf("number mismatch: have %v, want 100", blockCtx.BlockNumber)
+	}
+	if blockCtx.Time.Uint64() != 10 {
+		t.Errorf("time overridden: have %v, want 10", blockCtx.Time)
+	}
+	if blockCtx.Coinbase != common.HexToAddress("0x02") {
+		t.Errorf("coinbase mismatch: have %x, want 0x02", blockCtx.Coinbase)
+	}
+	var empty *BlockOverrides
+	empty.Apply(&blockCtx)
+	if blockCtx.BlockNumber.Uint64() != 100 {
+		t.Errorf("nil overrides changed the context")
+	}
+}
+
+// poolBackend is a backend holding transactions in its pool only.
+type poolBackend struct {
+	Backend
+	txs    map[common.Hash]*types.Transaction
+	status core.TxStatus
+	peers  int
+}
+
+func (b *poolBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *poolBackend) GetTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return nil, common.Hash{}, 0, 0, nil
+}
+
+func (b *poolBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	return b.txs[hash]
+}
+
+func (b *poolBackend) GetPoolTransactionStatus(hash common.Hash) (core.TxStatus, int) {
+	if b.txs[hash] == nil {
+		return core.TxStatusUnknown, 0
+	}
+	return b.status, b.peers
+}
+
+// Tests that pooled transactions are returned with their pool status.
+func TestGetTransactionPoolStatus(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{1}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	backend := &poolBackend{txs: map[common.Hash]*types.Transaction{tx.Hash(): tx}}
+	api := NewPublicTransactionPoolAPI(backend, nil, nil)
+
+	for _, test := range []struct {
+		status core.TxStatus
+		peers  int
+		want   string
+	}{
+		{core.TxStatusPending, 3, "pending"},
+		{core.TxStatusQueued, 0, "queued"},
+	} {
+		backend.status, backend.peers = test.status, test.peers
+
+		result, err := api.GetTransactionByHash(context.Background(), tx.Hash())
+		if err != nil {
+			t.Fatalf("failed to get transaction: %v", err)
+		}
+		status := result.PoolStatus
+		if status == nil {
+			t.Fatalf("pool status missing")
+		}
+		if status.Status != test.want || int(status.Peers) != test.peers || uint64(status.FirstSeen) != uint64(tx.Time().Unix()) {
+			t.Errorf("pool status mismatch: have %+v, want %s with %d peers", status, test.want, test.peers)
+		}
+	}
+	// Unknown transactions have no status
+	if result, err := api.GetTransactionByHash(context.Background(), common.Hash{1}); err != nil || result != nil {
+		t.Errorf("unknown transaction returned: %v, %v", result, err)
+	}
+}
+
+// proofBackend is a backend whose states are all pruned, leaving only the
+// snapshot tree of the chain to prove accounts from.
+type proofBackend struct {
+	Backend
+	chain  *core.BlockChain
+	triedb *trie.Database
+}
+
+func (b *proofBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	number, _ := blockNrOrHash.Number()
+	header := b.chain.GetHeaderByNumber(uint64(number))
+	return nil, header, errors.New("missing trie node")
+}
+
+func (b *proofBackend) StateProver(ctx context.Context, header *types.Header) (*snapshot.Prover, error) {
+	return b.chain.Snapshots().Prover(header.Root, b.triedb)
+}
+
+func (b *proofBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return nil, nil
+}
+
+// Tests that the proofs of pruned states are served from the snapshot tree,
+// matching the proofs created from the full state.
+func TestGetProofsFromSnapshots(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		recv    = common.HexToAddress("0xbeef")
+		store   = common.HexToAddress("0x5705e")
+		suicide = common.HexToAddress("0xdead")
+		missing = common.HexToAddress("0x1234")
+		signer  = types.HomesteadSigner{}
+		db      = rawdb.NewMemoryDatabase()
+		slots   = map[common.Hash]common.Hash{{0x01}: {0x01}, {0x02}: {0x02}}
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				// Stores the block number in slot 0 and clears slot 1
+				store: {Code: []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x01, byte(vm.SSTORE)}, Balance: common.Big0, Storage: map[common.Hash]common.Hash{
+					common.BigToHash(common.Big1): {0x01},
+					common.BigToHash(common.Big2): {0x02},
+				}},
+				suicide: {Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.SELFDESTRUCT)}, Balance: common.Big0, Storage: slots},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 4, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), recv, big.NewInt(1000), 21000, common.Big1, nil), signer, key)
+		b.AddTx(tx)
+		if i%2 == 0 {
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(sender), store, nil, 100000, common.Big1, nil), signer, key)
+			b.AddTx(tx)
+		}
+		if i == 2 {
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(sender), suicide, nil, 100000, common.Big1, nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		SnapshotLimit:  256,
+		SnapshotWait:   true,
+	}
+	chain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Only the disk layer tries of the snapshot tree are persisted
+	api := NewPublicBlockChainAPI(&proofBackend{chain: chain, triedb: trie.NewDatabase(db)}, nil)
+
+	var (
+		keys     = []string{"0x0", "0x1", "0x2", "0x3"}
+		requests = []ProofRequest{
+			{Address: sender},
+			{Address: recv, StorageKeys: keys},
+			{Address: store, StorageKeys: keys},
+			{Address: suicide, StorageKeys: []string{common.Hash{0x01}.Hex(), common.Hash{0x02}.Hex()}},
+			{Address: missing, StorageKeys: keys},
+		}
+	)
+	for _, block := range append([]*types.Block{genesis}, blocks...) {
+		statedb, err := chain.StateAt(block.Root())
+		if err != nil {
+			t.Fatalf("block %d: failed to open state: %v", block.NumberU64(), err)
+		}
+		want := make([]*AccountResult, len(requests))
+		for i, req := range requests {
+			if want[i], err = accountProof(statedb, req.Address, req.StorageKeys); err != nil {
+				t.Fatalf("block %d: failed to prove account %x: %v", block.NumberU64(), req.Address, err)
+			}
+		}
+		number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(block.NumberU64()))
+
+		have, err := api.GetProofs(context.Background(), requests, number)
+		if err != nil {
+			t.Fatalf("block %d: failed to prove accounts: %v", block.NumberU64(), err)
+		}
+		haveJSON, _ := json.Marshal(have)
+		wantJSON, _ := json.Marshal(want)
+		if !bytes.Equal(haveJSON, wantJSON) {
+			t.Errorf("block %d: proofs mismatch:\nhave %s\nwant %s", block.NumberU64(), haveJSON, wantJSON)
+		}
+		single, err := api.GetProof(context.Background(), store, keys, number)
+		if err != nil {
+			t.Fatalf("block %d: failed to prove account: %v", block.NumberU64(), err)
+		}
+		singleJSON, _ := json.Marshal(single)
+		if wantJSON, _ := json.Marshal(want[2]); !bytes.Equal(singleJSON, wantJSON) {
+			t.Errorf("block %d: proof mismatch:\nhave %s\nwant %s", block.NumberU64(), singleJSON, wantJSON)
+		}
+	}
+}
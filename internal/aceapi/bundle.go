@@ -0,0 +1,285 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/accounts/abi"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/rpc"
+)
+
+// defaultBundleTimeout is the time allowance for simulating a bundle if the
+// caller didn't specify one, and the maximum one callers may request.
+const defaultBundleTimeout = 5 * time.Second
+
+// PublicBundleAPI provides an API to simulate ordered lists of transactions on
+// top of the chain, without making any changes to it.
+type PublicBundleAPI struct {
+	b Backend
+}
+
+// NewPublicBundleAPI creates a new bundle simulation API.
+func NewPublicBundleAPI(b Backend) *PublicBundleAPI {
+	return &PublicBundleAPI{b}
+}
+
+// CallBundleArgs represents the arguments for simulating a bundle of transactions.
+type CallBundleArgs struct {
+	Txs              []hexutil.Bytes        `json:"txs"`              // Signed transactions in their binary encoding
+	BlockNumber      *hexutil.Big           `json:"blockNumber"`      // Number of the simulated block, defaults to the state block + 1
+	StateBlockNumber *rpc.BlockNumberOrHash `json:"stateBlockNumber"` // Block whose state the bundle is executed on, defaults to the parent of the simulated block
+	Coinbase         *common.Address        `json:"coinbase"`         // Beneficiary of the simulated block, defaults to the state block's
+	Timestamp        *hexutil.Uint64        `json:"timestamp"`        // Timestamp of the simulated block, defaults to the state block's + 1
+	GasLimit         *hexutil.Uint64        `json:"gasLimit"`         // Gas limit of the simulated block, defaults to the state block's
+	Timeout          *hexutil.Uint64        `json:"timeout"`          // Time allowance for the simulation in milliseconds, capped by the default
+}
+
+// CallBundleTxResult is the outcome of a single transaction of a simulated bundle.
+type CallBundleTxResult struct {
+	TxHash       common.Hash     `json:"txHash"`
+	From         common.Address  `json:"fromAddress"`
+	To           *common.Address `json:"toAddress"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	GasPrice     *hexutil.Big    `json:"gasPrice"`
+	GasFees      *hexutil.Big    `json:"gasFees"`           // Gas used times gas price
+	CoinbaseDiff *hexutil.Big    `json:"coinbaseDiff"`      // Balance change of the coinbase caused by the transaction
+	CoinbasePaid *hexutil.Big    `json:"ethSentToCoinbase"` // Coinbase balance change on top of the gas fees
+	ReturnData   hexutil.Bytes   `json:"value,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Revert       hexutil.Bytes   `json:"revert,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"` // Decoded reason string of the revert, if any
+}
+
+// CallBundleResult is the outcome of a simulated bundle.
+type CallBundleResult struct {
+	BundleHash       common.Hash          `json:"bundleHash"` // Hash of the concatenated transaction hashes
+	Results          []CallBundleTxResult `json:"results"`
+	GasUsed          hexutil.Uint64       `json:"totalGasUsed"`
+	GasFees          *hexutil.Big         `json:"gasFees"`
+	CoinbaseDiff     *hexutil.Big         `json:"coinbaseDiff"`
+	CoinbasePaid     *hexutil.Big         `json:"ethSentToCoinbase"`
+	BundleGasPrice   *hexutil.Big         `json:"bundleGasPrice"` // Coinbase payment per unit of gas used
+	StateBlockNumber hexutil.Uint64       `json:"stateBlockNumber"`
+}
+
+// CallBundle executes the given ordered list of signed transactions on top of
+// the requested block, each of them seeing the state changes of the previous
+// ones, and reports the per transaction results along with the payment made to
+// the coinbase of the simulated block.
+//
+// A transaction that cannot be included at all (e.g. due to an invalid nonce or
+// insufficient funds) fails the entire bundle, whereas a reverting transaction
+// is reported in its result. The gas of every transaction is capped by the RPC
+// gas cap, like for eth_call.
+//
+// Note, this function doesn't make any changes in the state/blockchain.
+func (s *PublicBundleAPI) CallBundle(ctx context.Context, args CallBundleArgs) (*CallBundleResult, error) {
+	if len(args.Txs) == 0 {
+		return nil, errors.New("bundle missing txs")
+	}
+	defer func(start time.Time) { log.Debug("Executing bundle call finished", "runtime", time.Since(start)) }(time.Now())
+
+	txs := make([]*types.Transaction, len(args.Txs))
+	for i, input := range args.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(input); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	// Execute on top of the block preceding the simulated one, unless a state
+	// block was requested explicitly.
+	stateBlock := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	switch {
+	case args.StateBlockNumber != nil:
+		stateBlock = *args.StateBlockNumber
+	case args.BlockNumber != nil:
+		number := args.BlockNumber.ToInt()
+		if number.Sign() <= 0 || !number.IsInt64() {
+			return nil, fmt.Errorf("invalid block number %v", number)
+		}
+		stateBlock = rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(number.Int64() - 1))
+	}
+	state, parent, err := s.b.StateAndHeaderByNumberOrHash(ctx, stateBlock)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Assemble the header of the simulated block
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		Difficulty: parent.Difficulty,
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		Coinbase:   parent.Coinbase,
+	}
+	if args.BlockNumber != nil {
+		header.Number = args.BlockNumber.ToInt()
+	}
+	if args.Timestamp != nil {
+		header.Time = uint64(*args.Timestamp)
+	}
+	if args.GasLimit != nil {
+		header.GasLimit = uint64(*args.GasLimit)
+	}
+	if args.Coinbase != nil {
+		header.Coinbase = *args.Coinbase
+	}
+	// Setup context so it may be cancelled when the simulation has completed
+	// or it ran out of its time allowance. Callers may only shorten it, so the
+	// simulations can't tie up the node.
+	timeout := defaultBundleTimeout
+	if args.Timeout != nil && *args.Timeout != 0 && uint64(*args.Timeout) < uint64(timeout/time.Millisecond) {
+		timeout = time.Duration(*args.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Wait for the context to be done and cancel the EVM executing at the time.
+	// Even if the EVM has finished, cancelling may be done (repeatedly)
+	var (
+		evmLock sync.Mutex
+		running *vm.EVM
+	)
+	go func() {
+		<-ctx.Done()
+
+		evmLock.Lock()
+		defer evmLock.Unlock()
+		if running != nil {
+			running.Cancel()
+		}
+	}()
+
+	var (
+		config = s.b.ChainConfig()
+		signer = types.MakeSigner(config, header.Number)
+		gp     = new(core.GasPool).AddGas(header.GasLimit)
+
+		coinbaseStart = state.GetBalance(header.Coinbase)
+		gasFees       = new(big.Int)
+		gasUsed       uint64
+		hashes        []byte
+
+		results = make([]CallBundleTxResult, 0, len(txs))
+	)
+	for i, tx := range txs {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, fmt.Errorf("err: %w; txhash %s", err, tx.Hash())
+		}
+		// Cap the gas of the transactions like eth_call does, so that a bundle
+		// can't tie up the node for longer than a call
+		if gasCap := s.b.RPCGasCap(); gasCap != 0 && msg.Gas() > gasCap {
+			log.Warn("Bundle transaction gas above allowance, capping", "tx", tx.Hash(), "requested", msg.Gas(), "cap", gasCap)
+			msg = types.NewMessage(msg.From(), msg.To(), msg.Nonce(), msg.Value(), gasCap, msg.GasPrice(), msg.Data(), msg.AccessList(), msg.CheckNonce())
+		}
+		state.Prepare(tx.Hash(), common.Hash{}, i)
+
+		evm, vmError, err := s.b.GetEVM(ctx, msg, state, header)
+		if err != nil {
+			return nil, err
+		}
+		// The engine may not be able to derive the author of an unsealed header
+		evm.Context.Coinbase = header.Coinbase
+
+		// Hand the EVM over to the canceller, checking the context afterwards in
+		// case it was done before
+		evmLock.Lock()
+		running = evm
+		evmLock.Unlock()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		coinbaseBefore := state.GetBalance(header.Coinbase)
+
+		result, err := core.ApplyMessage(evm, msg, gp)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("err: %w; txhash %s", err, tx.Hash())
+		}
+		if config.IsByzantium(header.Number) {
+			state.Finalise(true)
+		} else {
+			state.IntermediateRoot(config.IsEIP158(header.Number))
+		}
+		// Account the gas and the coinbase payment of the transaction
+		var (
+			txGasFees      = new(big.Int).Mul(new(big.Int).SetUint64(result.UsedGas), tx.GasPrice())
+			txCoinbaseDiff = new(big.Int).Sub(state.GetBalance(header.Coinbase), coinbaseBefore)
+		)
+		gasUsed += result.UsedGas
+		gasFees.Add(gasFees, txGasFees)
+		hashes = append(hashes, tx.Hash().Bytes()...)
+
+		txResult := CallBundleTxResult{
+			TxHash:       tx.Hash(),
+			From:         msg.From(),
+			To:           msg.To(),
+			GasUsed:      hexutil.Uint64(result.UsedGas),
+			GasPrice:     (*hexutil.Big)(tx.GasPrice()),
+			GasFees:      (*hexutil.Big)(txGasFees),
+			CoinbaseDiff: (*hexutil.Big)(txCoinbaseDiff),
+			CoinbasePaid: (*hexutil.Big)(new(big.Int).Sub(txCoinbaseDiff, txGasFees)),
+		}
+		if result.Err != nil {
+			txResult.Error = result.Err.Error()
+			txResult.Revert = result.Revert()
+			if reason, err := abi.UnpackRevert(result.Revert()); err == nil {
+				txResult.RevertReason = reason
+			}
+		} else {
+			txResult.ReturnData = result.Return()
+		}
+		results = append(results, txResult)
+	}
+	var (
+		coinbaseDiff   = new(big.Int).Sub(state.GetBalance(header.Coinbase), coinbaseStart)
+		bundleGasPrice = new(big.Int)
+	)
+	if gasUsed > 0 {
+		bundleGasPrice.Div(coinbaseDiff, new(big.Int).SetUint64(gasUsed))
+	}
+	return &CallBundleResult{
+		BundleHash:       crypto.Keccak256Hash(hashes),
+		Results:          results,
+		GasUsed:          hexutil.Uint64(gasUsed),
+		GasFees:          (*hexutil.Big)(gasFees),
+		CoinbaseDiff:     (*hexutil.Big)(coinbaseDiff),
+		CoinbasePaid:     (*hexutil.Big)(new(big.Int).Sub(coinbaseDiff, gasFees)),
+		BundleGasPrice:   (*hexutil.Big)(bundleGasPrice),
+		StateBlockNumber: hexutil.Uint64(parent.Number.Uint64()),
+	}, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/accounts/abi"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/core/vm"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/params"
+	"github.com/acent/go-acent/rpc"
+)
+
+// bundleBackend is a backend simulating bundles on top of a fixed state.
+type bundleBackend struct {
+	Backend
+	statedb *state.StateDB
+	header  *types.Header
+	gasCap  uint64
+}
+
+func (b *bundleBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *bundleBackend) RPCGasCap() uint64                { return b.gasCap }
+
+func (b *bundleBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return b.statedb.Copy(), b.header, nil
+}
+
+func (b *bundleBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+	context := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, params.TestChainConfig, vm.Config{}), func() error { return nil }, nil
+}
+
+// revertCode returns contract code reverting with the given reason string.
+func revertCode(reason string) []byte {
+	data := append(common.Hex2Bytes("08c379a0"), common.LeftPadBytes(big.NewInt(32).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(reason))).Bytes(), 32)...)
+	data = append(data, common.RightPadBytes([]byte(reason), 32)...)
+
+	var code []byte
+	for i := 0; i < len(data); i += 32 {
+		chunk := common.RightPadBytes(data[i:], 32)[:32]
+		code = append(code, byte(vm.PUSH32))
+		code = append(code, chunk...)
+		code = append(code, byte(vm.PUSH1), byte(i), byte(vm.MSTORE))
+	}
+	return append(code, byte(vm.PUSH1), byte(len(data)), byte(vm.PUSH1), 0, byte(vm.REVERT))
+}
+
+// Tests that bundles are simulated in order, accounting the coinbase payments,
+// reporting reverts and capping the gas of the transactions.
+func TestCallBundle(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.HexToAddress("0xc0ffee")
+		reverter = common.HexToAddress("0xdead")
+		looper   = common.HexToAddress("0x1009")
+		signer   = types.MakeSigner(params.TestChainConfig, common.Big1)
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetBalance(sender, big.NewInt(params.Ether))
+	statedb.SetCode(reverter, revertCode("nope"))
+	statedb.SetCode(looper, []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.JUMP)})
+
+	backend := &bundleBackend{
+		statedb: statedb,
+		header:  &types.Header{Number: common.Big0, Difficulty: common.Big1, GasLimit: 10000000, Coinbase: coinbase},
+		gasCap:  50000,
+	}
+	sign := func(nonce uint64, to common.Address, value int64, gas uint64) hexutil.Bytes {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(value), gas, common.Big1, nil), signer, key)
+		enc, _ := tx.MarshalBinary()
+		return enc
+	}
+	api := NewPublicBundleAPI(backend)
+	res, err := api.CallBundle(context.Background(), CallBundleArgs{Txs: []hexutil.Bytes{
+		sign(0, coinbase, 1000, 21000), // Direct payment to the coinbase
+		sign(1, reverter, 0, 100000),   // Reverting call
+		sign(2, looper, 0, 1000000),    // Endless loop, capped by the gas cap
+	}})
+	if err != nil {
+		t.Fatalf("failed to simulate bundle: %v", err)
+	}
+	if len(res.Results) != 3 {
+		t.Fatalf("result count mismatch: have %d, want 3", len(res.Results))
+	}
+	// The payment is accounted on top of the gas fees
+	if paid := res.Results[0].CoinbasePaid.ToInt(); paid.Int64() != 1000 {
+		t.Errorf("coinbase payment mismatch: have %v, want 1000", paid)
+	}
+	if diff := res.Results[0].CoinbaseDiff.ToInt(); diff.Int64() != 21000+1000 {
+		t.Errorf("coinbase diff mismatch: have %v, want %d", diff, 21000+1000)
+	}
+	// Reverts are reported with their reason, without failing the bundle
+	if res.Results[1].Error != vm.ErrExecutionReverted.Error() || res.Results[1].RevertReason != "nope" {
+		t.Errorf("revert mismatch: have %q (%q), want %q (%q)", res.Results[1].Error, res.Results[1].RevertReason, vm.ErrExecutionReverted, "nope")
+	}
+	if reason, err := abi.UnpackRevert(res.Results[1].Revert); err != nil || reason != "nope" {
+		t.Errorf("revert data mismatch: have %q (%v)", reason, err)
+	}
+	// The transaction gas is capped by the RPC gas cap
+	if used := uint64(res.Results[2].GasUsed); used != backend.gasCap || res.Results[2].Error == "" {
+		t.Errorf("capped gas mismatch: have %d (%q), want %d", used, res.Results[2].Error, backend.gasCap)
+	}
+	var gasUsed uint64
+	for _, result := range res.Results {
+		gasUsed += uint64(result.GasUsed)
+	}
+	if uint64(res.GasUsed) != gasUsed || res.CoinbasePaid.ToInt().Int64() != 1000 {
+		t.Errorf("bundle totals mismatch: gas %d (want %d), paid %v (want 1000)", res.GasUsed, gasUsed, res.CoinbasePaid)
+	}
+	// Transactions that can't be included fail the whole bundle
+	if _, err := api.CallBundle(context.Background(), CallBundleArgs{Txs: []hexutil.Bytes{sign(0, coinbase, 0, 21000), sign(0, coinbase, 0, 21000)}}); err == nil {
+		t.Errorf("bundle with reused nonce simulated")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/acent/go-acent"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+)
+
+// TransactionConditional is the set of conditions a transaction is only accepted
+// under. The conditions are checked against the block the transaction would be
+// included in next: its number is the current head's + 1 and its timestamp is
+// estimated as the current time.
+type TransactionConditional struct {
+	BlockNumberMin *hexutil.Big    `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Big    `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64 `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64 `json:"timestampMax,omitempty"`
+}
+
+// check validates the conditions against the next block on top of the given head,
+// returning acent.ErrConditionsNotMet if violated.
+func (c *TransactionConditional) check(head *types.Header, now time.Time) error {
+	number := new(big.Int).Add(head.Number, common.Big1)
+	if c.BlockNumberMin != nil && number.Cmp(c.BlockNumberMin.ToInt()) < 0 {
+		return fmt.Errorf("%w: block number %v below minimum %v", acent.ErrConditionsNotMet, number, c.BlockNumberMin.ToInt())
+	}
+	if c.BlockNumberMax != nil && number.Cmp(c.BlockNumberMax.ToInt()) > 0 {
+		return fmt.Errorf("%w: block number %v above maximum %v", acent.ErrConditionsNotMet, number, c.BlockNumberMax.ToInt())
+	}
+	timestamp := uint64(now.Unix())
+	if timestamp <= head.Time {
+		timestamp = head.Time + 1
+	}
+	if c.TimestampMin != nil && timestamp < uint64(*c.TimestampMin) {
+		return fmt.Errorf("%w: timestamp %d below minimum %d", acent.ErrConditionsNotMet, timestamp, uint64(*c.TimestampMin))
+	}
+	if c.TimestampMax != nil && timestamp > uint64(*c.TimestampMax) {
+		return fmt.Errorf("%w: timestamp %d above maximum %d", acent.ErrConditionsNotMet, timestamp, uint64(*c.TimestampMax))
+	}
+	return nil
+}
+
+// PrivateConditionalAPI provides an API for transaction relayers to submit
+// transactions only if they are not stale yet.
+type PrivateConditionalAPI struct {
+	b Backend
+}
+
+// NewPrivateConditionalAPI creates a new conditional transaction API.
+func NewPrivateConditionalAPI(b Backend) *PrivateConditionalAPI {
+	return &PrivateConditionalAPI{b}
+}
+
+// poolConditions returns the upper bounds of the conditions, which the transaction
+// pool enforces until the transaction is included, or nil if there are none.
+func (c *TransactionConditional) poolConditions() *core.TxConditions {
+	if c.BlockNumberMax == nil && c.TimestampMax == nil {
+		return nil
+	}
+	conditions := new(core.TxConditions)
+	if c.BlockNumberMax != nil {
+		conditions.BlockNumberMax = c.BlockNumberMax.ToInt()
+	}
+	if c.TimestampMax != nil {
+		max := uint64(*c.TimestampMax)
+		conditions.TimestampMax = &max
+	}
+	return conditions
+}
+
+// SendRawTransactionConditional will add the signed transaction to the transaction
+// pool if its conditions are met by the next block, returning its hash.
+//
+// The transaction pool keeps track of the maximum block number and timestamp,
+// dropping the transaction once the chain progresses past them.
+func (s *PrivateConditionalAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, options TransactionConditional) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if err := options.check(s.b.CurrentHeader(), time.Now()); err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(ctx, s.b, tx, false, options.poolConditions())
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+
+	"github.com/acent/go-acent/core/types"
+)
+
+// Hooks are the override points of the API services, allowing chains built on
+// acent to customise the RPC behaviour without forking the package. Any of the
+// hooks may be nil. The services are composed through the public rpcapi package.
+type Hooks struct {
+	// ValidateTxArgs is invoked on the arguments of every transaction to be
+	// filled, signed or sent, after their defaults are set. Returning an error
+	// rejects the transaction.
+	ValidateTxArgs func(ctx context.Context, b Backend, args *SendTxArgs) error
+
+	// MarshalHeader is invoked on the RPC output of every header and block,
+	// allowing chain specific fields to be added or replaced.
+	MarshalHeader func(ctx context.Context, header *types.Header, fields map[string]interface{})
+
+	// MarshalBlock is invoked on the RPC output of every block after the header
+	// hook, allowing chain specific fields derived from the body to be added.
+	MarshalBlock func(ctx context.Context, block *types.Block, fields map[string]interface{}) error
+}
+
+// prepareTxArgs sets the defaults of the transaction arguments and runs them
+// through the validation hook, if any.
+func (h *Hooks) prepareTxArgs(ctx context.Context, b Backend, args *SendTxArgs) error {
+	if err := args.setDefaults(ctx, b); err != nil {
+		return err
+	}
+	if h != nil && h.ValidateTxArgs != nil {
+		return h.ValidateTxArgs(ctx, b, args)
+	}
+	return nil
+}
+
+// marshalHeader runs the RPC output of a header through the header hook, if any.
+func (h *Hooks) marshalHeader(ctx context.Context, header *types.Header, fields map[string]interface{}) {
+	if h != nil && h.MarshalHeader != nil {
+		h.MarshalHeader(ctx, header, fields)
+	}
+}
+
+// marshalBlock runs the RPC output of a block through the header and block
+// hooks, if any.
+func (h *Hooks) marshalBlock(ctx context.Context, block *types.Block, fields map[string]interface{}) error {
+	if h == nil {
+		return nil
+	}
+	h.marshalHeader(ctx, block.Header(), fields)
+	if h.MarshalBlock != nil {
+		return h.MarshalBlock(ctx, block, fields)
+	}
+	return nil
+}
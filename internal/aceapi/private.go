@@ -0,0 +1,49 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core/types"
+)
+
+// PrivateTransactionAPI provides an API for operators running their own miners
+// to submit transactions that are included locally but never propagated.
+type PrivateTransactionAPI struct {
+	b Backend
+}
+
+// NewPrivateTransactionAPI creates a new private transaction submission API.
+func NewPrivateTransactionAPI(b Backend) *PrivateTransactionAPI {
+	return &PrivateTransactionAPI{b}
+}
+
+// SendPrivateTransaction will add the signed transaction to the transaction pool
+// for the local miner, without ever propagating it to the network, returning its
+// hash.
+//
+// Note, private transactions are not journaled, they are lost on restart.
+func (s *PrivateTransactionAPI) SendPrivateTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(ctx, s.b, tx, true, nil)
+}
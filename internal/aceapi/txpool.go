@@ -0,0 +1,174 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/rpc"
+)
+
+// txPoolEventChanSize is the size of the channels receiving the pool events of
+// an events subscription.
+const txPoolEventChanSize = 128
+
+// ContentFrom returns the transactions of the given account contained within the
+// transaction pool.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := make(map[string]map[string]*RPCTransaction, 2)
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	// Build the pending transactions
+	dump := make(map[string]*RPCTransaction, len(pending))
+	for _, tx := range pending {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	content["pending"] = dump
+
+	// Build the queued transactions
+	dump = make(map[string]*RPCTransaction, len(queue))
+	for _, tx := range queue {
+		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+	}
+	content["queued"] = dump
+
+	return content
+}
+
+// NonceGap is an inclusive range of nonces missing from the transaction pool.
+type NonceGap struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// NonceGaps is the report of the nonces missing from the transaction pool that
+// keep the queued transactions of an account from being executable.
+type NonceGaps struct {
+	Nonce hexutil.Uint64 `json:"nonce"` // Next nonce of the account in the latest state
+	Gaps  []NonceGap     `json:"gaps"`  // Missing nonce ranges in ascending order
+}
+
+// NonceGaps returns the nonces missing from the transaction pool between the
+// next nonce of the account in the latest state and its highest pooled one.
+func (s *PublicTxPoolAPI) NonceGaps(ctx context.Context, addr common.Address) (*NonceGaps, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	nonce := state.GetNonce(addr)
+
+	pending, queue := s.b.TxPoolContentFrom(addr)
+	nonces := make([]uint64, 0, len(pending)+len(queue))
+	for _, tx := range append(pending, queue...) {
+		nonces = append(nonces, tx.Nonce())
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+
+	report := &NonceGaps{Nonce: hexutil.Uint64(nonce), Gaps: []NonceGap{}}
+	next := nonce
+	for _, n := range nonces {
+		if n < next {
+			continue // Stale or duplicate, not yet pruned from the pool
+		}
+		if n > next {
+			report.Gaps = append(report.Gaps, NonceGap{From: hexutil.Uint64(next), To: hexutil.Uint64(n - 1)})
+		}
+		next = n + 1
+	}
+	return report, nil
+}
+
+// TxPoolEvent is the notification of a transaction entering or leaving the pool.
+type TxPoolEvent struct {
+	Type       string         `json:"type"` // One of "added", "dropped" or "replaced"
+	Hash       common.Hash    `json:"hash"`
+	From       common.Address `json:"from"`
+	Nonce      hexutil.Uint64 `json:"nonce"`
+	Reason     string         `json:"reason,omitempty"`     // Reason of a dropped transaction
+	ReplacedBy *common.Hash   `json:"replacedBy,omitempty"` // Replacement of a replaced transaction
+}
+
+// Events creates a subscription that is triggered each time a transaction is
+// added to, dropped from or replaced in the transaction pool. If accounts are
+// given, only the events of their transactions are sent.
+func (s *PublicTxPoolAPI) Events(ctx context.Context, accounts *[]common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var filter map[common.Address]bool
+	if accounts != nil && len(*accounts) > 0 {
+		filter = make(map[common.Address]bool, len(*accounts))
+		for _, addr := range *accounts {
+			filter[addr] = true
+		}
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			signer = types.LatestSigner(s.b.ChainConfig())
+
+			addCh      = make(chan core.NewTxsEvent, txPoolEventChanSize)
+			dropCh     = make(chan core.DropTxsEvent, txPoolEventChanSize)
+			replaceCh  = make(chan core.ReplaceTxEvent, txPoolEventChanSize)
+			addSub     = s.b.SubscribeNewTxsEvent(addCh)
+			dropSub    = s.b.SubscribeDropTxsEvent(dropCh)
+			replaceSub = s.b.SubscribeReplaceTxEvent(replaceCh)
+		)
+		defer addSub.Unsubscribe()
+		defer dropSub.Unsubscribe()
+		defer replaceSub.Unsubscribe()
+
+		// notify sends the event of the transaction if its sender is monitored
+		notify := func(kind string, tx *types.Transaction, ev *TxPoolEvent) {
+			from, _ := types.Sender(signer, tx)
+			if filter != nil && !filter[from] {
+				return
+			}
+			ev.Type, ev.Hash, ev.From, ev.Nonce = kind, tx.Hash(), from, hexutil.Uint64(tx.Nonce())
+			notifier.Notify(rpcSub.ID, ev)
+		}
+		for {
+			select {
+			case ev := <-addCh:
+				for _, tx := range ev.Txs {
+					notify("added", tx, new(TxPoolEvent))
+				}
+			case ev := <-dropCh:
+				for _, tx := range ev.Txs {
+					notify("dropped", tx, &TxPoolEvent{Reason: string(ev.Reason)})
+				}
+			case ev := <-replaceCh:
+				hash := ev.New.Hash()
+				notify("replaced", ev.Old, &TxPoolEvent{ReplacedBy: &hash})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
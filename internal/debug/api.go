@@ -69,6 +69,12 @@ func (*HandlerT) BacktraceAt(location string) error {
 	return glogger.BacktraceAt(location)
 }
 
+// LogRateLimits sets the per message log rate limits. See package log for details
+// on the ruleset syntax.
+func (*HandlerT) LogRateLimits(ruleset string) error {
+	return ratelimiter.RateLimits(ruleset)
+}
+
 // MemStats returns detailed runtime memory statistics.
 func (*HandlerT) MemStats() *runtime.MemStats {
 	s := new(runtime.MemStats)
@@ -55,6 +55,11 @@ var (
 		Usage: "Request a stack trace at a specific logging statement (e.g. \"block.go:271\")",
 		Value: "",
 	}
+	logRateLimitFlag = cli.StringFlag{
+		Name:  "log.ratelimit",
+		Usage: "Per-message rate limits: comma-separated list of <message>=<count>/<period> (e.g. \"*=100/10s\")",
+		Value: "",
+	}
 	debugFlag = cli.BoolFlag{
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
@@ -94,17 +99,19 @@ var (
 
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
-	verbosityFlag, logjsonFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
+	verbosityFlag, logjsonFlag, vmoduleFlag, backtraceAtFlag, logRateLimitFlag, debugFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag, memprofilerateFlag,
 	blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
 
 var (
-	glogger *log.GlogHandler
+	glogger     *log.GlogHandler
+	ratelimiter *log.RateLimitHandler
 )
 
 func init() {
-	glogger = log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	ratelimiter = log.NewRateLimitHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger = log.NewGlogHandler(ratelimiter)
 	glogger.Verbosity(log.LvlInfo)
 	log.Root().SetHandler(glogger)
 }
@@ -123,7 +130,10 @@ func Setup(ctx *cli.Context) error {
 		}
 		ostream = log.StreamHandler(output, log.TerminalFormat(usecolor))
 	}
-	glogger.SetHandler(ostream)
+	ratelimiter.SetHandler(ostream)
+	if err := ratelimiter.RateLimits(ctx.GlobalString(logRateLimitFlag.Name)); err != nil {
+		return fmt.Errorf("invalid %s: %v", logRateLimitFlag.Name, err)
+	}
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
@@ -0,0 +1,232 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements a segmented archive format for exported chains.
+//
+// An archive is a directory of segments, each holding the blocks of a fixed size
+// range as a single RLP list, optionally gzip compressed. An index file lists the
+// segments along with their checksums and boundary block hashes, such that every
+// segment can be downloaded and verified on its own.
+package era
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/acent/go-acent/common"
+)
+
+const (
+	// Version is the version of the archive format.
+	Version = 1
+
+	// IndexFile is the name of the index file of an archive.
+	IndexFile = "index.json"
+
+	// DefaultSegmentSize is the default number of blocks of a segment.
+	DefaultSegmentSize = 8192
+)
+
+var (
+	errNoSegments      = errors.New("archive has no segments")
+	errChecksum        = errors.New("segment checksum mismatch")
+	errSegmentSize     = errors.New("segment size mismatch")
+	errSegmentGap      = errors.New("segments not contiguous")
+	errInvalidFileName = errors.New("invalid segment file name")
+)
+
+// Index describes the segments of an archive.
+type Index struct {
+	Version     int       `json:"version"`
+	SegmentSize uint64    `json:"segmentSize"` // Number of blocks of every segment but the last
+	Segments    []Segment `json:"segments"`
+}
+
+// Segment describes a file of an archive holding a range of blocks.
+type Segment struct {
+	File      string      `json:"file"`      // Name of the file in the archive directory
+	First     uint64      `json:"first"`     // Number of the first block
+	Last      uint64      `json:"last"`      // Number of the last block
+	FirstHash common.Hash `json:"firstHash"` // Hash of the first block
+	LastHash  common.Hash `json:"lastHash"`  // Hash of the last block
+	Size      int64       `json:"size"`      // Size of the file
+	Checksum  string      `json:"sha256"`    // Hex encoded SHA256 of the file
+}
+
+// SegmentFile returns the name of the file of a segment.
+func SegmentFile(first, last uint64, compressed bool) string {
+	name := fmt.Sprintf("blocks-%010d-%010d.rlp", first, last)
+	if compressed {
+		name += ".gz"
+	}
+	return name
+}
+
+// ReadIndex reads the index of the archive in the given directory, checking that
+// its segments follow each other.
+func ReadIndex(dir string) (*Index, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(blob, &index); err != nil {
+		return nil, fmt.Errorf("invalid archive index: %v", err)
+	}
+	if index.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d", index.Version)
+	}
+	if len(index.Segments) == 0 {
+		return nil, errNoSegments
+	}
+	for i, seg := range index.Segments {
+		if filepath.Base(seg.File) != seg.File || seg.File == "." || seg.File == ".." {
+			return nil, fmt.Errorf("segment %d: %w: %q", i, errInvalidFileName, seg.File)
+		}
+		if seg.Last < seg.First {
+			return nil, fmt.Errorf("segment %d: last block %d before first block %d", i, seg.Last, seg.First)
+		}
+		if i > 0 && seg.First != index.Segments[i-1].Last+1 {
+			return nil, fmt.Errorf("segment %d: %w: first block %d after block %d", i, errSegmentGap, seg.First, index.Segments[i-1].Last)
+		}
+	}
+	return &index, nil
+}
+
+// Write writes the index into the archive in the given directory.
+func (index *Index) Write(dir string) error {
+	blob, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, IndexFile), blob)
+}
+
+// Verify checks the size and checksum of the segment file in the given directory.
+func (seg *Segment) Verify(dir string) error {
+	f, err := os.Open(filepath.Join(dir, seg.File))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return err
+	}
+	if size != seg.Size {
+		return fmt.Errorf("%s: %w: have %d, want %d", seg.File, errSegmentSize, size, seg.Size)
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != seg.Checksum {
+		return fmt.Errorf("%s: %w: have %s, want %s", seg.File, errChecksum, sum, seg.Checksum)
+	}
+	return nil
+}
+
+// SegmentWriter writes the file of a segment, checksumming it on the fly. The
+// file only appears in the archive directory once the writer is closed.
+type SegmentWriter struct {
+	seg    Segment
+	dir    string
+	file   *os.File
+	gzip   *gzip.Writer
+	out    io.Writer
+	hasher hash.Hash
+	size   int64
+}
+
+// NewSegmentWriter creates the writer of the segment holding the given range of
+// blocks in the archive in dir, compressing it if requested.
+func NewSegmentWriter(dir string, first, last uint64, compress bool) (*SegmentWriter, error) {
+	name := SegmentFile(first, last, compress)
+	file, err := ioutil.TempFile(dir, name+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	w := &SegmentWriter{
+		seg:    Segment{File: name, First: first, Last: last},
+		dir:    dir,
+		file:   file,
+		hasher: sha256.New(),
+	}
+	w.out = io.MultiWriter(file, w.hasher, (*countWriter)(&w.size))
+	if compress {
+		w.gzip = gzip.NewWriter(w.out)
+		w.out = w.gzip
+	}
+	return w, nil
+}
+
+// Write writes the encoded blocks into the segment.
+func (w *SegmentWriter) Write(b []byte) (int, error) {
+	return w.out.Write(b)
+}
+
+// Close finalizes the segment file and returns its description, missing the hashes
+// of the boundary blocks which are up to the caller to fill.
+func (w *SegmentWriter) Close() (Segment, error) {
+	if w.gzip != nil {
+		if err := w.gzip.Close(); err != nil {
+			w.Abort()
+			return Segment{}, err
+		}
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return Segment{}, err
+	}
+	if err := os.Rename(w.file.Name(), filepath.Join(w.dir, w.seg.File)); err != nil {
+		os.Remove(w.file.Name())
+		return Segment{}, err
+	}
+	w.seg.Size = w.size
+	w.seg.Checksum = hex.EncodeToString(w.hasher.Sum(nil))
+	return w.seg, nil
+}
+
+// Abort discards the segment.
+func (w *SegmentWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// countWriter counts the bytes written into it.
+type countWriter int64
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	*c += countWriter(len(b))
+	return len(b), nil
+}
+
+// writeFileAtomic writes a file through a temporary file, such that readers never
+// see a partially written file.
+func writeFileAtomic(path string, blob []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
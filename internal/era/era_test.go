@@ -0,0 +1,124 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRoundtrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	index := &Index{Version: Version, SegmentSize: 10}
+	for i, compress := range []bool{false, true} {
+		first := uint64(i * 10)
+		w, err := NewSegmentWriter(dir, first, first+9, compress)
+		if err != nil {
+			t.Fatalf("segment %d: create error: %v", i, err)
+		}
+		if _, err := w.Write([]byte("segment content")); err != nil {
+			t.Fatalf("segment %d: write error: %v", i, err)
+		}
+		seg, err := w.Close()
+		if err != nil {
+			t.Fatalf("segment %d: close error: %v", i, err)
+		}
+		if want := SegmentFile(first, first+9, compress); seg.File != want {
+			t.Errorf("segment %d: file name mismatch: have %s, want %s", i, seg.File, want)
+		}
+		if err := seg.Verify(dir); err != nil {
+			t.Errorf("segment %d: verification failed: %v", i, err)
+		}
+		index.Segments = append(index.Segments, seg)
+	}
+	// Check that the compressed segment holds the written content
+	f, err := os.Open(filepath.Join(dir, index.Segments[1].File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal("not a gzip file:", err)
+	}
+	if content, err := ioutil.ReadAll(zr); err != nil || string(content) != "segment content" {
+		t.Errorf("compressed content mismatch: %q, %v", content, err)
+	}
+	// Check the index round-trip and that no temporary files are left
+	if err := index.Write(dir); err != nil {
+		t.Fatal("index write error:", err)
+	}
+	read, err := ReadIndex(dir)
+	if err != nil {
+		t.Fatal("index read error:", err)
+	}
+	if len(read.Segments) != 2 || read.Segments[1] != index.Segments[1] {
+		t.Errorf("index mismatch: have %+v, want %+v", read, index)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 3 {
+		t.Errorf("archive file count mismatch: have %d, want %d", len(files), 3)
+	}
+	// Corrupt a segment and check that verification fails
+	path := filepath.Join(dir, index.Segments[0].File)
+	if err := ioutil.WriteFile(path, []byte("segment CONTENT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Segments[0].Verify(dir); !errors.Is(err, errChecksum) {
+		t.Errorf("corrupted segment error mismatch: have %v, want %v", err, errChecksum)
+	}
+	if err := ioutil.WriteFile(path, []byte("segment"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Segments[0].Verify(dir); !errors.Is(err, errSegmentSize) {
+		t.Errorf("truncated segment error mismatch: have %v, want %v", err, errSegmentSize)
+	}
+}
+
+func TestReadIndexInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		index *Index
+		err   error
+	}{
+		{&Index{Version: Version}, errNoSegments},
+		{&Index{Version: Version, Segments: []Segment{{File: "a", First: 0, Last: 9}, {File: "b", First: 11, Last: 19}}}, errSegmentGap},
+		{&Index{Version: Version, Segments: []Segment{{File: "../a", First: 0, Last: 9}}}, errInvalidFileName},
+	}
+	for i, tt := range tests {
+		if err := tt.index.Write(dir); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadIndex(dir); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing sets up the OpenTelemetry tracing of the node, exporting the
+// spans of the instrumented subsystems (RPC server, downloader and block import)
+// to a collector via OTLP.
+//
+// The instrumented packages use the global tracer provider, which does nothing
+// until Setup is called, so tracing costs next to nothing while disabled.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// shutdownTimeout is the maximum time to wait for the pending spans to be
+// exported on shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// Config contains the configuration of the span exporter.
+type Config struct {
+	Endpoint    string  // OTLP/gRPC collector endpoint (host:port)
+	Insecure    bool    // Whether to connect to the collector without TLS
+	SampleRatio float64 // Ratio of the traces to sample, unless sampled by the caller
+	ServiceName string  // Name of the node in the traces
+}
+
+// DefaultConfig is the default tracing configuration.
+var DefaultConfig = Config{
+	Endpoint:    "localhost:4317",
+	SampleRatio: 1,
+	ServiceName: "gace",
+}
+
+var (
+	lock     sync.Mutex
+	provider *sdktrace.TracerProvider
+)
+
+// Setup starts exporting the spans to the configured collector, and installs
+// the W3C trace context propagator so that traces continue across services.
+func Setup(config Config) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if provider != nil {
+		return errors.New("tracing already set up")
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return errors.New("trace sample ratio must be between 0 and 1")
+	}
+	opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlpgrpc.WithInsecure())
+	}
+	exporter, err := otlp.NewExporter(context.Background(), otlpgrpc.NewDriver(opts...))
+	if err != nil {
+		return err
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	log.Info("Enabled OpenTelemetry tracing", "endpoint", config.Endpoint, "ratio", config.SampleRatio)
+	return nil
+}
+
+// Shutdown flushes the pending spans and stops exporting. It's a no-op if the
+// tracing was not set up.
+func Shutdown() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Warn("Failed to flush trace spans", "err", err)
+	}
+	provider = nil
+}
@@ -28,6 +28,7 @@ var Modules = map[string]string{
 	"miner":      MinerJs,
 	"net":        NetJs,
 	"personal":   PersonalJs,
+	"relay":      RelayJs,
 	"rpc":        RpcJs,
 	"shh":        ShhJs,
 	"swarmfs":    SwarmfsJs,
@@ -170,6 +171,23 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'clearPeerScore',
+			call: 'admin_clearPeerScore',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'clearPeerScores',
+			call: 'admin_clearPeerScores',
+		}),
+		new web3._extend.Method({
+			name: 'reloadPeerFilters',
+			call: 'admin_reloadPeerFilters',
+		}),
+		new web3._extend.Method({
+			name: 'compactNodeDB',
+			call: 'admin_compactNodeDB',
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -181,6 +199,16 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setTrustedHead',
+			call: 'admin_setTrustedHead',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, null]
+		}),
+		new web3._extend.Method({
+			name: 'clearTrustedHead',
+			call: 'admin_clearTrustedHead',
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -218,6 +246,10 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'apiKeyUsage',
+			call: 'admin_apiKeyUsage'
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -228,6 +260,14 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
+		new web3._extend.Property({
+			name: 'nodeDBStats',
+			getter: 'admin_nodeDBStats'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -246,6 +286,10 @@ web3._extend({
 			params: 6,
 			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null, null, null, null],
 		}),
+		new web3._extend.Method({
+			name: 'forkSchedule',
+			call: 'debug_forkSchedule',
+		}),
 		new web3._extend.Method({
 			name: 'printBlock',
 			call: 'debug_printBlock',
@@ -279,6 +323,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getTransactionsByCallee',
+			call: 'debug_getTransactionsByCallee',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -289,6 +339,10 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'compact',
+			call: 'debug_compact',
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -304,12 +358,22 @@ web3._extend({
 			call: 'debug_backtraceAt',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'logRateLimits',
+			call: 'debug_logRateLimits',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'stacks',
 			call: 'debug_stacks',
 			params: 0,
 			outputFormatter: console.log
 		}),
+		new web3._extend.Method({
+			name: 'lifecycles',
+			call: 'debug_lifecycles',
+			params: 0,
+		}),
 		new web3._extend.Method({
 			name: 'freeOSMemory',
 			call: 'debug_freeOSMemory',
@@ -449,17 +513,52 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, null, null]
 		}),
+		new web3._extend.Method({
+			name: 'traceFilter',
+			call: 'debug_traceFilter',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'txPropagation',
+			call: 'debug_txPropagation',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'snapshotProgress',
+			call: 'debug_snapshotProgress',
+		}),
+		new web3._extend.Method({
+			name: 'pauseSnapshotGeneration',
+			call: 'debug_pauseSnapshotGeneration',
+		}),
+		new web3._extend.Method({
+			name: 'resumeSnapshotGeneration',
+			call: 'debug_resumeSnapshotGeneration',
+		}),
+		new web3._extend.Method({
+			name: 'setSnapshotGenerationLimits',
+			call: 'debug_setSnapshotGenerationLimits',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, null]
+		}),
 		new web3._extend.Method({
 			name: 'getBadBlocks',
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'getBlockWitness',
+			call: 'debug_getBlockWitness',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter],
+		}),
 		new web3._extend.Method({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
@@ -517,10 +616,20 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'estimateGas',
 			call: 'eth_estimateGas',
-			params: 2,
-			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter],
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter, null, null],
 			outputFormatter: web3._extend.utils.toDecimal
 		}),
+		new web3._extend.Method({
+			name: 'callBundle',
+			call: 'eth_callBundle',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'sendPrivateTransaction',
+			call: 'eth_sendPrivateTransaction',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'eth_submitTransaction',
@@ -556,6 +665,34 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, function (val) { return !!val; }]
 		}),
+		new web3._extend.Method({
+			name: 'sendUserOperation',
+			call: 'eth_sendUserOperation',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'supportedEntryPoints',
+			call: 'eth_supportedEntryPoints',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getUserOperationByHash',
+			call: 'eth_getUserOperationByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getBlockReceipts',
+			call: 'eth_getBlockReceipts',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getBlockRewards',
+			call: 'eth_getBlockRewards',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransaction',
 			call: 'eth_getRawTransactionByHash',
@@ -575,6 +712,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getProofs',
+			call: 'eth_getProofs',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -589,6 +732,10 @@ web3._extend({
 				return formatted;
 			}
 		}),
+		new web3._extend.Property({
+			name: 'syncStages',
+			getter: 'eth_syncStages'
+		}),
 	]
 });
 `
@@ -629,6 +776,19 @@ web3._extend({
 			call: 'miner_setRecommitInterval',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'setOrdering',
+			call: 'miner_setOrdering',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'getWork',
+			call: 'miner_getWork',
+		}),
+		new web3._extend.Method({
+			name: 'getPayload',
+			call: 'miner_getPayload',
+		}),
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
@@ -764,10 +924,41 @@ web3._extend({
 });
 `
 
+const RelayJs = `
+web3._extend({
+	property: 'relay',
+	methods: [
+		new web3._extend.Method({
+			name: 'sendRawTransactionConditional',
+			call: 'relay_sendRawTransactionConditional',
+			params: 2,
+		}),
+	]
+});
+`
+
 const TxpoolJs = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'contentFrom',
+			call: 'txpool_contentFrom',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'nonceGaps',
+			call: 'txpool_nonceGaps',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'setPolicy',
+			call: 'txpool_setPolicy',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -778,6 +969,10 @@ web3._extend({
 			name: 'inspect',
 			getter: 'txpool_inspect'
 		}),
+		new web3._extend.Property({
+			name: 'policy',
+			getter: 'txpool_policy'
+		}),
 		new web3._extend.Property({
 			name: 'status',
 			getter: 'txpool_status',
@@ -870,6 +1065,36 @@ web3._extend({
 			call: 'les_addBalance',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'updatePricing',
+			call: 'les_updatePricing',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'pricingCurve',
+			call: 'les_pricingCurve',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'simulateCapacity',
+			call: 'les_simulateCapacity',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'pinServer',
+			call: 'les_pinServer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'unpinServer',
+			call: 'les_unpinServer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setUltraLightServers',
+			call: 'les_setUltraLightServers',
+			params: 2
+		}),
 	],
 	properties:
 	[
@@ -885,6 +1110,10 @@ web3._extend({
 			name: 'serverInfo',
 			getter: 'les_serverInfo'
 		}),
+		new web3._extend.Property({
+			name: 'pinnedServers',
+			getter: 'les_pinnedServers'
+		}),
 	]
 });
 `
@@ -19,6 +19,7 @@ package les
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/acent/go-acent/common/hexutil"
@@ -32,6 +33,7 @@ var (
 	errNotActivated         = errors.New("checkpoint registrar is not activated")
 	errUnknownBenchmarkType = errors.New("unknown benchmark type")
 	errNoPriority           = errors.New("priority too low to raise capacity")
+	errNotPinned            = errors.New("server is not pinned")
 )
 
 // PrivateLightServerAPI provides an API to access the LES light server.
@@ -216,6 +218,56 @@ func (api *PrivateLightServerAPI) SetDefaultParams(params map[string]interface{}
 	return err
 }
 
+// UpdatePricing sets the default price factors like SetDefaultParams, but also
+// applies them immediately to all connected clients which are priced by the
+// previous defaults. Clients with individually set price factors are left alone.
+// The number of clients switched over to the new pricing is returned.
+func (api *PrivateLightServerAPI) UpdatePricing(params map[string]interface{}) (int, error) {
+	posFactors, negFactors := api.defaultPosFactors, api.defaultNegFactors
+	update, err := api.setParams(params, nil, &posFactors, &negFactors)
+	if err != nil || !update {
+		return 0, err
+	}
+	oldPos, oldNeg := api.defaultPosFactors, api.defaultNegFactors
+	api.defaultPosFactors, api.defaultNegFactors = posFactors, negFactors
+	api.server.clientPool.setDefaultFactors(posFactors, negFactors)
+
+	var updated int
+	api.server.clientPool.forClients(nil, func(client *clientInfo) {
+		if !client.connected {
+			return
+		}
+		if pos, neg := client.balance.GetPriceFactors(); pos == oldPos && neg == oldNeg {
+			client.balance.SetPriceFactors(posFactors, negFactors)
+			updated++
+		}
+	})
+	return updated, nil
+}
+
+// PricingCurve returns the hourly connection price of clients with a positive
+// balance at the given capacities, sending requests at the given rate (request
+// cost units per second), under the current default pricing.
+func (api *PrivateLightServerAPI) PricingCurve(capacities []uint64, requestRate float64) []vfs.PricePoint {
+	return vfs.PricingCurve(api.defaultPosFactors, capacities, requestRate)
+}
+
+// SimulateCapacity estimates the service levels (wait times, connected time ratio,
+// token spending) the given client population would experience on this server
+// under the current default pricing, over the given time span. The simulation is
+// seeded deterministically so that results of different pricing parameters are
+// comparable.
+func (api *PrivateLightServerAPI) SimulateCapacity(profiles []vfs.ClientProfile, duration time.Duration) (*vfs.SimulationResult, error) {
+	total, _, _ := api.server.clientPool.capacityInfo()
+	return vfs.SimulateCapacity(vfs.SimulationConfig{
+		TotalCapacity: total,
+		MinCapacity:   api.server.minCapacity,
+		Factors:       api.defaultPosFactors,
+		Profiles:      profiles,
+		Duration:      duration,
+	})
+}
+
 // SetConnectedBias set the connection bias, which is applied to already connected clients
 // So that already connected client won't be kicked out very soon and we can ensure all
 // connected clients can have enough time to request or sync some data.
@@ -348,6 +400,123 @@ func (api *PrivateDebugAPI) FreezeClient(node string) error {
 	return err
 }
 
+// PrivateLightClientAPI provides an API to access the LES light client.
+type PrivateLightClientAPI struct {
+	client *LightAcent
+
+	lock        sync.Mutex
+	pinnedNodes map[enode.ID]*enode.Node // Pinned servers with a known address, kept connected
+}
+
+// NewPrivateLightClientAPI creates a new LES light client API.
+func NewPrivateLightClientAPI(client *LightAcent) *PrivateLightClientAPI {
+	return &PrivateLightClientAPI{
+		client:      client,
+		pinnedNodes: make(map[enode.ID]*enode.Node),
+	}
+}
+
+// ClientInfo returns the retrieval statistics and capacity assignments of the
+// connected servers listed in the nodes list, or all of them if it's empty.
+func (api *PrivateLightClientAPI) ClientInfo(nodes []string) map[enode.ID]map[string]interface{} {
+	ids := make(map[enode.ID]bool)
+	for _, node := range nodes {
+		if id, err := parseNode(node); err == nil {
+			ids[id] = true
+		}
+	}
+	res := make(map[enode.ID]map[string]interface{})
+	for _, p := range api.client.peers.allPeers() {
+		if len(nodes) == 0 || ids[p.ID()] {
+			res[p.ID()] = api.serverInfo(p)
+		}
+	}
+	return res
+}
+
+// serverInfo creates a server info data structure
+func (api *PrivateLightClientAPI) serverInfo(p *serverPeer) map[string]interface{} {
+	info := make(map[string]interface{})
+	info["version"] = p.version
+	info["trusted"] = p.trusted
+	info["pinned"] = p.isPinned()
+	info["onlyAnnounce"] = p.onlyAnnounce
+	info["frozen"] = p.isFrozen()
+
+	p.lock.RLock()
+	info["headNumber"] = p.headInfo.Number
+	params := p.fcParams
+	p.lock.RUnlock()
+
+	if p.fcServer != nil {
+		info["capacity/bufLimit"] = params.BufLimit
+		info["capacity/minRecharge"] = params.MinRecharge
+		_, info["capacity/bufferLevel"] = p.fcServer.CanSend(0)
+	}
+	stats := p.retrievalStats()
+	info["requests/sent"] = stats.sent
+	info["requests/valid"] = stats.valid
+	info["requests/invalid"] = stats.invalid
+	info["requests/timeouts"] = stats.timeouts
+	if answered := stats.valid + stats.invalid + stats.timeouts; answered > 0 {
+		info["requests/successRate"] = float64(stats.valid) / float64(answered)
+	}
+	if stats.valid > 0 {
+		info["requests/latency"] = float64(stats.latency) / float64(time.Second)
+	}
+	return info
+}
+
+// PinServer marks a server as preferred for retrievals. If an enode address is
+// given instead of a node id, the server is also kept connected.
+func (api *PrivateLightClientAPI) PinServer(node string) error {
+	id, err := parseNode(node)
+	if err != nil {
+		return err
+	}
+	if n, err := enode.Parse(enode.ValidSchemes, node); err == nil {
+		api.lock.Lock()
+		api.pinnedNodes[id] = n
+		api.lock.Unlock()
+		api.client.p2pServer.AddTrustedPeer(n)
+		api.client.p2pServer.AddPeer(n)
+	}
+	api.client.peers.pin(id)
+	return nil
+}
+
+// UnpinServer removes the preference of a pinned server.
+func (api *PrivateLightClientAPI) UnpinServer(node string) error {
+	id, err := parseNode(node)
+	if err != nil {
+		return err
+	}
+	if !api.client.peers.unpin(id) {
+		return errNotPinned
+	}
+	api.lock.Lock()
+	n := api.pinnedNodes[id]
+	delete(api.pinnedNodes, id)
+	api.lock.Unlock()
+	if n != nil {
+		api.client.p2pServer.RemoveTrustedPeer(n)
+		api.client.p2pServer.RemovePeer(n)
+	}
+	return nil
+}
+
+// PinnedServers returns the ids of the pinned servers.
+func (api *PrivateLightClientAPI) PinnedServers() []enode.ID {
+	return api.client.peers.pinnedIDs()
+}
+
+// SetUltraLightServers replaces the trusted servers of the ultra light client
+// and the percentage of them required to accept an announcement. Note, the
+// change doesn't persist; update the ultra light config file for that.
+func (api *PrivateLightClientAPI) SetUltraLightServers(servers []string, fraction int) error {
+	return api.client.handler.updateULC(servers, fraction)
+}
+
 // PrivateLightAPI provides an API to access the LES light server or light client.
 type PrivateLightAPI struct {
 	backend *lesCommons
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/acent/go-acent"
 	"github.com/acent/go-acent/accounts"
 	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/consensus"
@@ -28,6 +29,7 @@ import (
 	"github.com/acent/go-acent/core/bloombits"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/core/state"
+	"github.com/acent/go-acent/core/state/snapshot"
 	"github.com/acent/go-acent/core/types"
 	"github.com/acent/go-acent/core/vm"
 	"github.com/acent/go-acent/eth/downloader"
@@ -44,6 +46,7 @@ type LesApiBackend struct {
 	allowUnprotectedTxs bool
 	eth                 *LightAcent
 	gpo                 *gasprice.Oracle
+	finality            *core.Finality
 }
 
 func (b *LesApiBackend) ChainConfig() *params.ChainConfig {
@@ -60,8 +63,13 @@ func (b *LesApiBackend) SetHead(number uint64) {
 }
 
 func (b *LesApiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
-	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
 		return b.eth.blockchain.CurrentHeader(), nil
+	case rpc.SafeBlockNumber:
+		return b.finality.SafeHeader(), nil
+	case rpc.FinalizedBlockNumber:
+		return b.finality.FinalizedHeader(), nil
 	}
 	return b.eth.blockchain.GetHeaderByNumberOdr(ctx, uint64(number))
 }
@@ -76,7 +84,7 @@ func (b *LesApiBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash
 			return nil, err
 		}
 		if header == nil {
-			return nil, errors.New("header for hash not found")
+			return nil, acent.ErrBlockNotFound.Errorf("header for hash not found")
 		}
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, errors.New("hash is not currently canonical")
@@ -128,7 +136,7 @@ func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.B
 		return nil, nil, err
 	}
 	if header == nil {
-		return nil, nil, errors.New("header not found")
+		return nil, nil, acent.ErrBlockNotFound
 	}
 	return light.NewState(ctx, header, b.eth.odr), header, nil
 }
@@ -140,7 +148,7 @@ func (b *LesApiBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 	if hash, ok := blockNrOrHash.Hash(); ok {
 		header := b.eth.blockchain.GetHeaderByHash(hash)
 		if header == nil {
-			return nil, nil, errors.New("header for hash not found")
+			return nil, nil, acent.ErrBlockNotFound.Errorf("header for hash not found")
 		}
 		if blockNrOrHash.RequireCanonical && b.eth.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
@@ -181,6 +189,14 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.eth.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction) error {
+	return errors.New("private transactions not available in light mode")
+}
+
+func (b *LesApiBackend) SendConditionalTx(ctx context.Context, signedTx *types.Transaction, conditions *core.TxConditions) error {
+	return errors.New("conditional transactions not available in light mode")
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
@@ -193,6 +209,15 @@ func (b *LesApiBackend) GetPoolTransaction(txHash common.Hash) *types.Transactio
 	return b.eth.txPool.GetTransaction(txHash)
 }
 
+func (b *LesApiBackend) GetPoolTransactionStatus(txHash common.Hash) (core.TxStatus, int) {
+	// The light pool only holds executable transactions and relays them to the
+	// servers instead of the les peers
+	if b.eth.txPool.GetTransaction(txHash) == nil {
+		return core.TxStatusUnknown, 0
+	}
+	return core.TxStatusPending, 0
+}
+
 func (b *LesApiBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
 	return light.GetTransaction(ctx, b.eth.odr, txHash)
 }
@@ -209,10 +234,30 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.eth.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.eth.txPool.ContentFrom(addr)
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.eth.txPool.SubscribeNewTxsEvent(ch)
 }
 
+// The light pool only holds executable transactions until they are mined, it
+// neither drops nor replaces them.
+func (b *LesApiBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+func (b *LesApiBackend) SubscribeReplaceTxEvent(ch chan<- core.ReplaceTxEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeChainEvent(ch)
 }
@@ -240,6 +285,10 @@ func (b *LesApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEven
 	return b.eth.blockchain.SubscribeRemovedLogsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeReorgEvent(ch chan<- core.ReorgEvent) event.Subscription {
+	return b.eth.blockchain.SubscribeReorgEvent(ch)
+}
+
 func (b *LesApiBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -276,6 +325,18 @@ func (b *LesApiBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *LesApiBackend) RPCReturnDataCap() uint64 {
+	return b.eth.config.RPCReturnDataCap
+}
+
+func (b *LesApiBackend) RPCLogsCap() uint64 {
+	return b.eth.config.RPCLogsCap
+}
+
+func (b *LesApiBackend) RPCLogsPageSize() uint64 {
+	return b.eth.config.RPCLogsPageSize
+}
+
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	if b.eth.bloomIndexer == nil {
 		return 0, 0
@@ -284,12 +345,24 @@ func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocksClient, sections
 }
 
+func (b *LesApiBackend) BloomExtent() (uint64, uint64) {
+	return 0, 0
+}
+
 func (b *LesApiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
 		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.eth.bloomRequests)
 	}
 }
 
+func (b *LesApiBackend) LogIndexStatus() (uint64, uint64) {
+	return 0, 0
+}
+
+func (b *LesApiBackend) LogIndex(ctx context.Context, address common.Address, section uint64) ([]byte, error) {
+	return nil, errors.New("log index not available in light mode")
+}
+
 func (b *LesApiBackend) Engine() consensus.Engine {
 	return b.eth.engine
 }
@@ -302,6 +375,10 @@ func (b *LesApiBackend) StateAtBlock(ctx context.Context, block *types.Block, re
 	return b.eth.stateAtBlock(ctx, block, reexec)
 }
 
+func (b *LesApiBackend) StateProver(ctx context.Context, header *types.Header) (*snapshot.Prover, error) {
+	return nil, errors.New("snapshots not available in light mode")
+}
+
 func (b *LesApiBackend) StatesInRange(ctx context.Context, fromBlock *types.Block, toBlock *types.Block, reexec uint64) ([]*state.StateDB, func(), error) {
 	return b.eth.statesInRange(ctx, fromBlock, toBlock, reexec)
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/eth/filters"
 	"github.com/acent/go-acent/eth/gasprice"
+	"github.com/acent/go-acent/eth/rpcapi"
 	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/internal/ethapi"
 	"github.com/acent/go-acent/les/vflux"
@@ -63,6 +64,7 @@ type LightAcent struct {
 	serverPool         *vfc.ServerPool
 	serverPoolIterator enode.Iterator
 	pruner             *pruner
+	ulcWatcher         *ulcWatcher // Reloads the ultra light client config file, if any
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
@@ -88,7 +90,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*LightAcent, error) {
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideBerlin)
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.ForkOverrides)
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
@@ -117,11 +119,21 @@ func New(stack *node.Node, config *ethconfig.Config) (*LightAcent, error) {
 		udpEnabled:     stack.Config().P2P.DiscoveryV5,
 	}
 
+	// The trusted servers are taken from the config file if one is specified,
+	// so that they can be changed without a restart.
+	ulcServers, ulcFraction := config.UltraLightServers, config.UltraLightFraction
+	if config.UltraLightConfigFile != "" {
+		ulcConfig, err := loadULCConfig(config.UltraLightConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		ulcServers, ulcFraction = ulcConfig.Servers, ulcConfig.Fraction
+	}
 	var prenegQuery vfc.QueryFunc
 	if leth.udpEnabled {
 		prenegQuery = leth.prenegQuery
 	}
-	leth.serverPool, leth.serverPoolIterator = vfc.NewServerPool(lesDb, []byte("serverpool:"), time.Second, prenegQuery, &mclock.System{}, config.UltraLightServers, requestList)
+	leth.serverPool, leth.serverPoolIterator = vfc.NewServerPool(lesDb, []byte("serverpool:"), time.Second, prenegQuery, &mclock.System{}, ulcServers, requestList)
 	leth.serverPool.AddMetrics(suggestedTimeoutGauge, totalValueGauge, serverSelectableGauge, serverConnectedGauge, sessionValueMeter, serverDialedMeter)
 
 	leth.retriever = newRetrieveManager(peers, leth.reqDist, leth.serverPool.GetTimeout)
@@ -162,17 +174,27 @@ func New(stack *node.Node, config *ethconfig.Config) (*LightAcent, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 
-	leth.ApiBackend = &LesApiBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, leth, nil}
+	finality := core.NewFinality(leth.blockchain, leth.engine, config.RPCSafeDepth, config.RPCFinalizedDepth)
+	leth.ApiBackend = &LesApiBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, leth, nil, finality}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.Miner.GasPrice
 	}
 	leth.ApiBackend.gpo = gasprice.NewOracle(leth.ApiBackend, gpoParams)
 
-	leth.handler = newClientHandler(config.UltraLightServers, config.UltraLightFraction, checkpoint, leth)
+	leth.handler = newClientHandler(ulcServers, ulcFraction, checkpoint, leth)
+	if head := config.TrustedHead; head != nil {
+		if err := leth.handler.downloader.SetTrustedHead(head.Number, head.Hash); err != nil {
+			return nil, err
+		}
+	}
 	if leth.handler.ulc != nil {
-		log.Warn("Ultra light client is enabled", "trustedNodes", len(leth.handler.ulc.keys), "minTrustedFraction", leth.handler.ulc.fraction)
+		trusted, fraction := leth.handler.ulc.settings()
+		log.Warn("Ultra light client is enabled", "trustedNodes", trusted, "minTrustedFraction", fraction)
 		leth.blockchain.DisableCheckFreq()
+		if config.UltraLightConfigFile != "" {
+			leth.ulcWatcher = newULCWatcher(config.UltraLightConfigFile, leth.handler.updateULC)
+		}
 	}
 
 	leth.netRPCService = ethapi.NewPublicNetAPI(leth.p2pServer, leth.config.NetworkId)
@@ -285,7 +307,7 @@ func (s *LightDummyAPI) Mining() bool {
 // APIs returns the collection of RPC services the acent package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *LightAcent) APIs() []rpc.API {
-	apis := ethapi.GetAPIs(s.ApiBackend)
+	apis := rpcapi.GetAPIs(s.ApiBackend)
 	apis = append(apis, s.engine.APIs(s.BlockChain().HeaderChain())...)
 	return append(apis, []rpc.API{
 		{
@@ -313,6 +335,11 @@ func (s *LightAcent) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateLightAPI(&s.lesCommons),
 			Public:    false,
+		}, {
+			Namespace: "les",
+			Version:   "1.0",
+			Service:   NewPrivateLightClientAPI(s),
+			Public:    false,
 		}, {
 			Namespace: "vflux",
 			Version:   "1.0",
@@ -362,6 +389,9 @@ func (s *LightAcent) Start() error {
 	s.wg.Add(bloomServiceThreads)
 	s.startBloomHandlers(params.BloomBitsBlocksClient)
 	s.handler.start()
+	if s.ulcWatcher != nil {
+		s.ulcWatcher.start()
+	}
 
 	return nil
 }
@@ -370,6 +400,9 @@ func (s *LightAcent) Start() error {
 // Acent protocol.
 func (s *LightAcent) Stop() error {
 	close(s.closeCh)
+	if s.ulcWatcher != nil {
+		s.ulcWatcher.stop()
+	}
 	s.serverPool.Stop()
 	s.peers.close()
 	s.reqDist.close()
@@ -18,6 +18,7 @@ package les
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"sync"
 	"sync/atomic"
@@ -34,6 +35,8 @@ import (
 	"github.com/acent/go-acent/params"
 )
 
+var errULCDisabled = errors.New("ultra light client is not enabled")
+
 // clientHandler is responsible for receiving and processing all incoming server
 // responses.
 type clientHandler struct {
@@ -368,6 +371,26 @@ func (h *clientHandler) removePeer(id string) {
 	h.backend.peers.unregister(id)
 }
 
+// updateULC replaces the trusted servers and fraction of the ultra light client.
+// Servers whose trust changed are disconnected so that they reconnect with the
+// new status.
+func (h *clientHandler) updateULC(servers []string, fraction int) error {
+	if h.ulc == nil {
+		return errULCDisabled
+	}
+	if err := h.ulc.update(servers, fraction); err != nil {
+		return err
+	}
+	for _, p := range h.backend.peers.allPeers() {
+		if p.trusted != h.ulc.trusted(p.ID()) {
+			p.Log().Debug("Ultra light server trust changed", "trusted", !p.trusted)
+			h.removePeer(p.id)
+		}
+	}
+	h.backend.serverPool.SetTrustedURLs(servers)
+	return nil
+}
+
 type peerConnection struct {
 	handler *clientHandler
 	peer    *serverPeer
@@ -77,10 +77,11 @@ func (c *lesCommons) makeProtocols(versions []uint, runPeer func(version uint, p
 	for i, version := range versions {
 		version := version
 		protos[i] = p2p.Protocol{
-			Name:     "les",
-			Version:  version,
-			Length:   ProtocolLengths[version],
-			NodeInfo: c.nodeInfo,
+			Name:       "les",
+			Version:    version,
+			Length:     ProtocolLengths[version],
+			MaxMsgSize: ProtocolMaxMsgSize,
+			NodeInfo:   c.nodeInfo,
 			Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 				return runPeer(version, peer, rw)
 			},
@@ -193,8 +193,9 @@ func (d *requestDistributor) nextRequest() (distPeer, *distReq, time.Duration) {
 	checkedPeers := make(map[distPeer]struct{})
 	elem := d.reqQueue.Front()
 	var (
-		bestWait time.Duration
-		sel      *utils.WeightedRandomSelect
+		bestWait  time.Duration
+		sel       *utils.WeightedRandomSelect
+		pinnedSel *utils.WeightedRandomSelect // Pinned servers are preferred if any of them can serve
 	)
 
 	d.peerLock.RLock()
@@ -218,10 +219,18 @@ func (d *requestDistributor) nextRequest() (distPeer, *distReq, time.Duration) {
 				cost := req.getCost(peer)
 				wait, bufRemain := peer.waitBefore(cost)
 				if wait == 0 {
-					if sel == nil {
-						sel = utils.NewWeightedRandomSelect(selectPeerWeight)
+					item := selectPeerItem{peer: peer, req: req, weight: uint64(bufRemain*1000000) + 1}
+					if sp, ok := peer.(*serverPeer); ok && sp.isPinned() {
+						if pinnedSel == nil {
+							pinnedSel = utils.NewWeightedRandomSelect(selectPeerWeight)
+						}
+						pinnedSel.Update(item)
+					} else {
+						if sel == nil {
+							sel = utils.NewWeightedRandomSelect(selectPeerWeight)
+						}
+						sel.Update(item)
 					}
-					sel.Update(selectPeerItem{peer: peer, req: req, weight: uint64(bufRemain*1000000) + 1})
 				} else {
 					if bestWait == 0 || wait < bestWait {
 						bestWait = wait
@@ -238,6 +247,9 @@ func (d *requestDistributor) nextRequest() (distPeer, *distReq, time.Duration) {
 		elem = next
 	}
 
+	if pinnedSel != nil {
+		sel = pinnedSel
+	}
 	if sel != nil {
 		c := sel.Choose().(selectPeerItem)
 		return c.peer, c.req, 0
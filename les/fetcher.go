@@ -291,7 +291,7 @@ func (f *lightFetcher) mainloop() {
 		f.forEachPeer(func(id enode.ID, p *fetcherPeer) bool {
 			if anno := p.announces[hash]; anno != nil && anno.trust && anno.data.Number == number {
 				agreed = append(agreed, id)
-				if 100*len(agreed)/len(f.ulc.keys) >= f.ulc.fraction {
+				if f.ulc.enough(len(agreed)) {
 					trusted = true
 					return false // abort iteration
 				}
@@ -36,6 +36,7 @@ import (
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/trie"
+	"golang.org/x/time/rate"
 )
 
 func expectResponse(r p2p.MsgReader, msgcode, reqID, bv uint64, data interface{}) error {
@@ -183,6 +184,37 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 	}
 }
 
+// Tests that requests over the serve rate of observer mode are dropped.
+func TestObserverServeRate(t *testing.T) {
+	server, _, tearDown := newClientServerEnv(t, testnetConfig{blocks: 4, protocol: 4, nopruning: true})
+	defer tearDown()
+
+	server.handler.serveLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	rawPeer, closePeer, _ := server.newRawPeer(t, "peer", 4)
+	defer closePeer()
+
+	query := &GetBlockHeadersData{Origin: hashOrNumber{Number: 1}, Amount: 1}
+	headers := []*types.Header{server.handler.blockchain.GetHeaderByNumber(1)}
+	sendRequest(rawPeer.app, GetBlockHeadersMsg, 1, query)
+	if err := expectResponse(rawPeer.app, BlockHeadersMsg, 1, testBufLimit, headers); err != nil {
+		t.Fatalf("headers mismatch: %v", err)
+	}
+	// The burst is used up, the next request must be dropped
+	sendRequest(rawPeer.app, GetBlockHeadersMsg, 2, query)
+
+	answered := make(chan p2p.Msg, 1)
+	go func() {
+		if msg, err := rawPeer.app.ReadMsg(); err == nil {
+			answered <- msg
+		}
+	}()
+	select {
+	case msg := <-answered:
+		t.Fatalf("request over the serve rate answered with message %d", msg.Code)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodiesLes2(t *testing.T) { testGetBlockBodies(t, 2) }
 func TestGetBlockBodiesLes3(t *testing.T) { testGetBlockBodies(t, 3) }
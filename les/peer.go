@@ -37,6 +37,7 @@ import (
 	vfs "github.com/acent/go-acent/les/vflux/server"
 	"github.com/acent/go-acent/light"
 	"github.com/acent/go-acent/p2p"
+	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/rlp"
 )
@@ -356,6 +357,10 @@ type serverPeer struct {
 	errCount    utils.LinearExpiredValue // Counter the invalid responses server has replied
 	updateCount uint64
 	updateTime  mclock.AbsTime
+	pinned      uint32 // The flag whether the server is preferred for retrievals, accessed atomically
+
+	statsLock sync.Mutex
+	stats     retrievalStats // Outcome of the retrievals sent to the server
 
 	// Test callback hooks
 	hasBlockHook func(common.Hash, uint64, bool) bool // Used to determine whether the server has the specified block.
@@ -752,6 +757,66 @@ func (p *serverPeer) answeredRequest(id uint64) {
 	nvt.Served(vtReqs[:reqCount], dt)
 }
 
+// retrievalStats counts the outcome of the on-demand retrievals sent to a server.
+type retrievalStats struct {
+	sent, valid, invalid, timeouts uint64
+	latency                        time.Duration // Moving average of the response time of valid replies
+}
+
+// retrievalLatencyWeight is the weight of a new sample in the moving average of
+// the response time.
+const retrievalLatencyWeight = 0.1
+
+// retrievalSent marks a retrieval request sent to this server.
+func (p *serverPeer) retrievalSent() {
+	p.statsLock.Lock()
+	p.stats.sent++
+	p.statsLock.Unlock()
+}
+
+// retrievalDone marks the final outcome of a retrieval request sent to this
+// server the given time ago.
+func (p *serverPeer) retrievalDone(event int, elapsed time.Duration) {
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+
+	switch event {
+	case rpDeliveredValid:
+		if p.stats.valid == 0 {
+			p.stats.latency = elapsed
+		} else {
+			p.stats.latency += time.Duration(float64(elapsed-p.stats.latency) * retrievalLatencyWeight)
+		}
+		p.stats.valid++
+	case rpDeliveredInvalid:
+		p.stats.invalid++
+	case rpHardTimeout:
+		p.stats.timeouts++
+	}
+}
+
+// retrievalStats returns the statistics of the retrievals sent to this server.
+func (p *serverPeer) retrievalStats() retrievalStats {
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+
+	return p.stats
+}
+
+// isPinned returns whether the server is preferred for retrievals.
+func (p *serverPeer) isPinned() bool {
+	return atomic.LoadUint32(&p.pinned) == 1
+}
+
+// setPinned marks the server as preferred or not for retrievals.
+func (p *serverPeer) setPinned(pinned bool) {
+	var v uint32
+	if pinned {
+		v = 1
+	}
+	atomic.StoreUint32(&p.pinned, v)
+}
+
 // clientPeer represents each node to which the les server is connected.
 // The node here refers to the light client.
 type clientPeer struct {
@@ -1074,7 +1139,8 @@ type serverPeerSubscriber interface {
 // serverPeerSet represents the set of active server peers currently
 // participating in the Light Acent sub-protocol.
 type serverPeerSet struct {
-	peers map[string]*serverPeer
+	peers  map[string]*serverPeer
+	pinned map[enode.ID]struct{} // Servers preferred for retrievals, connected or not
 	// subscribers is a batch of subscribers and peerset will notify
 	// these subscribers when the peerset changes(new server peer is
 	// added or removed)
@@ -1085,7 +1151,50 @@ type serverPeerSet struct {
 
 // newServerPeerSet creates a new peer set to track the active server peers.
 func newServerPeerSet() *serverPeerSet {
-	return &serverPeerSet{peers: make(map[string]*serverPeer)}
+	return &serverPeerSet{
+		peers:  make(map[string]*serverPeer),
+		pinned: make(map[enode.ID]struct{}),
+	}
+}
+
+// pin marks the server with the given ID as preferred for retrievals, whether
+// it is connected now or later.
+func (ps *serverPeerSet) pin(id enode.ID) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.pinned[id] = struct{}{}
+	if p, ok := ps.peers[id.String()]; ok {
+		p.setPinned(true)
+	}
+}
+
+// unpin removes the preference of the server with the given ID, returning
+// false if it wasn't pinned.
+func (ps *serverPeerSet) unpin(id enode.ID) bool {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.pinned[id]; !ok {
+		return false
+	}
+	delete(ps.pinned, id)
+	if p, ok := ps.peers[id.String()]; ok {
+		p.setPinned(false)
+	}
+	return true
+}
+
+// pinnedIDs returns the IDs of the pinned servers.
+func (ps *serverPeerSet) pinnedIDs() []enode.ID {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	ids := make([]enode.ID, 0, len(ps.pinned))
+	for id := range ps.pinned {
+		ids = append(ids, id)
+	}
+	return ids
 }
 
 // subscribe adds a service to be notified about added or removed
@@ -1125,6 +1234,8 @@ func (ps *serverPeerSet) register(peer *serverPeer) error {
 	if _, exist := ps.peers[peer.id]; exist {
 		return errAlreadyRegistered
 	}
+	_, pinned := ps.pinned[peer.ID()]
+	peer.setPinned(pinned)
 	ps.peers[peer.id] = peer
 	for _, sub := range ps.subscribers {
 		sub.registerPeer(peer)
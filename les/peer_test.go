@@ -96,6 +96,57 @@ func TestPeerSubscription(t *testing.T) {
 	checkPeers(sub.unregCh)
 }
 
+func TestPeerPinning(t *testing.T) {
+	peers := newServerPeerSet()
+	defer peers.close()
+
+	var id1, id2 enode.ID
+	rand.Read(id1[:])
+	rand.Read(id2[:])
+	peer1 := newServerPeer(2, NetworkId, false, p2p.NewPeer(id1, "name", nil), nil)
+	peer2 := newServerPeer(2, NetworkId, false, p2p.NewPeer(id2, "name", nil), nil)
+
+	// Pinning must apply to connected servers as well as to ones connecting later.
+	peers.register(peer1)
+	peers.pin(id1)
+	peers.pin(id2)
+	peers.register(peer2)
+	if !peer1.isPinned() || !peer2.isPinned() {
+		t.Fatalf("servers not pinned: %v %v", peer1.isPinned(), peer2.isPinned())
+	}
+	if ids := peers.pinnedIDs(); len(ids) != 2 {
+		t.Fatalf("wrong pinned ids: %v", ids)
+	}
+	if !peers.unpin(id1) || peer1.isPinned() {
+		t.Fatalf("server not unpinned")
+	}
+	if peers.unpin(id1) {
+		t.Fatalf("unpinned server unpinned again")
+	}
+}
+
+func TestRetrievalStats(t *testing.T) {
+	var id enode.ID
+	rand.Read(id[:])
+	peer := newServerPeer(2, NetworkId, false, p2p.NewPeer(id, "name", nil), nil)
+
+	for i := 0; i < 4; i++ {
+		peer.retrievalSent()
+	}
+	peer.retrievalDone(rpDeliveredValid, 100*time.Millisecond)
+	peer.retrievalDone(rpDeliveredValid, 200*time.Millisecond)
+	peer.retrievalDone(rpDeliveredInvalid, time.Second)
+	peer.retrievalDone(rpHardTimeout, hardRequestTimeout)
+
+	stats := peer.retrievalStats()
+	if stats.sent != 4 || stats.valid != 2 || stats.invalid != 1 || stats.timeouts != 1 {
+		t.Fatalf("wrong retrieval counts: %+v", stats)
+	}
+	if stats.latency != 110*time.Millisecond {
+		t.Fatalf("wrong average latency: %v", stats.latency)
+	}
+}
+
 type fakeChain struct{}
 
 func (f *fakeChain) Config() *params.ChainConfig { return params.MainnetChainConfig }
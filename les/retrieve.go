@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/acent/go-acent/common/mclock"
 	"github.com/acent/go-acent/light"
 )
 
@@ -329,6 +330,18 @@ func (r *sentReq) tryRequest() {
 
 	hrto := false
 
+	// Account the outcome in the retrieval statistics of the server
+	sp, _ := p.(*serverPeer)
+	if sp != nil {
+		sp.retrievalSent()
+	}
+	sentAt := mclock.Now()
+	done := func(event int) {
+		if sp != nil {
+			sp.retrievalDone(event, time.Duration(mclock.Now()-sentAt))
+		}
+	}
+
 	r.lock.RLock()
 	s, ok := r.sentTo[p]
 	r.lock.RUnlock()
@@ -337,11 +350,10 @@ func (r *sentReq) tryRequest() {
 	}
 
 	defer func() {
-		pp, ok := p.(*serverPeer)
-		if hrto && ok {
-			pp.Log().Debug("Request timed out hard")
+		if hrto && sp != nil {
+			sp.Log().Debug("Request timed out hard")
 			if r.rm.peers != nil {
-				r.rm.peers.unregister(pp.id)
+				r.rm.peers.unregister(sp.id)
 			}
 		}
 	}()
@@ -353,6 +365,7 @@ func (r *sentReq) tryRequest() {
 			delete(r.sentTo, p)
 			r.lock.Unlock()
 		}
+		done(event)
 		r.eventsCh <- reqPeerEvent{event, p}
 		return
 	case <-time.After(r.rm.softRequestTimeout()):
@@ -366,9 +379,11 @@ func (r *sentReq) tryRequest() {
 			delete(r.sentTo, p)
 			r.lock.Unlock()
 		}
+		done(event)
 		r.eventsCh <- reqPeerEvent{event, p}
 	case <-time.After(hardRequestTimeout):
 		hrto = true
+		done(rpHardTimeout)
 		r.eventsCh <- reqPeerEvent{rpHardTimeout, p}
 	}
 }
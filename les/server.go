@@ -37,6 +37,7 @@ import (
 	"github.com/acent/go-acent/p2p/nodestate"
 	"github.com/acent/go-acent/params"
 	"github.com/acent/go-acent/rpc"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -127,6 +128,9 @@ func NewLesServer(node *node.Node, e ethBackend, config *ethconfig.Config) (*Les
 		issync = func() bool { return true }
 	}
 	srv.handler = newServerHandler(srv, e.BlockChain(), e.ChainDb(), e.TxPool(), issync)
+	if config.Observer {
+		srv.handler.serveLimiter = rate.NewLimiter(rate.Limit(config.ObserverServeRate), config.ObserverServeRate)
+	}
 	srv.costTracker, srv.minCapacity = newCostTracker(e.ChainDb(), config)
 	srv.oracle = srv.setupOracle(node, e.BlockChain().Genesis().Hash(), config)
 
@@ -40,6 +40,7 @@ import (
 	"github.com/acent/go-acent/p2p/nodestate"
 	"github.com/acent/go-acent/rlp"
 	"github.com/acent/go-acent/trie"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -75,6 +76,8 @@ type serverHandler struct {
 	wg      sync.WaitGroup // WaitGroup used to track all background routines of handler.
 	synced  func() bool    // Callback function used to determine whether local node is synced.
 
+	serveLimiter *rate.Limiter // Limiter of the served requests in observer mode, nil if unlimited
+
 	// Testing fields
 	addTxsSync bool
 }
@@ -325,6 +328,10 @@ func (h *serverHandler) handleMsg(p *clientPeer, wg *sync.WaitGroup) error {
 		req.InPacketsMeter.Mark(1)
 		req.InTrafficMeter.Mark(int64(msg.Size))
 	}
+	if h.serveLimiter != nil && !h.serveLimiter.Allow() {
+		p.Log().Trace("Dropping request over observer serve rate")
+		return nil
+	}
 	p.responseCount++
 	responseCount := p.responseCount
 
@@ -17,19 +17,49 @@
 package les
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p/enode"
 )
 
+// ulcReloadInterval is the interval of checking the ultra light client config
+// file for changes.
+const ulcReloadInterval = 5 * time.Second
+
 type ulc struct {
+	lock     sync.RWMutex
 	keys     map[string]bool
 	fraction int
 }
 
+// ulcConfig is the content of the ultra light client config file.
+type ulcConfig struct {
+	Servers  []string `json:"servers"`
+	Fraction int      `json:"fraction"`
+}
+
 // newULC creates and returns an ultra light client instance.
 func newULC(servers []string, fraction int) (*ulc, error) {
+	keys, err := parseULCServers(servers)
+	if err != nil {
+		return nil, err
+	}
+	return &ulc{
+		keys:     keys,
+		fraction: fraction,
+	}, nil
+}
+
+// parseULCServers parses the trusted server list into the set of node ids.
+func parseULCServers(servers []string) (map[string]bool, error) {
 	keys := make(map[string]bool)
 	for _, id := range servers {
 		node, err := enode.Parse(enode.ValidSchemes, id)
@@ -42,13 +72,130 @@ func newULC(servers []string, fraction int) (*ulc, error) {
 	if len(keys) == 0 {
 		return nil, errors.New("no trusted servers")
 	}
-	return &ulc{
-		keys:     keys,
-		fraction: fraction,
-	}, nil
+	return keys, nil
+}
+
+// loadULCConfig reads the trusted servers and fraction from a config file.
+func loadULCConfig(path string) (*ulcConfig, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config ulcConfig
+	if err := json.Unmarshal(blob, &config); err != nil {
+		return nil, fmt.Errorf("invalid ultra light client config %s: %v", path, err)
+	}
+	if config.Fraction == 0 {
+		config.Fraction = ethconfig.Defaults.UltraLightFraction
+	}
+	return &config, nil
 }
 
 // trusted return an indicator that whether the specified peer is trusted.
 func (u *ulc) trusted(p enode.ID) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
 	return u.keys[p.String()]
 }
+
+// enough returns whether the given number of trusted servers agreeing on an
+// announcement reaches the required fraction.
+func (u *ulc) enough(agreed int) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return 100*agreed/len(u.keys) >= u.fraction
+}
+
+// settings returns the number of trusted servers and the required fraction.
+func (u *ulc) settings() (int, int) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return len(u.keys), u.fraction
+}
+
+// update replaces the trusted servers and the required fraction.
+func (u *ulc) update(servers []string, fraction int) error {
+	if fraction <= 0 || fraction > 100 {
+		return fmt.Errorf("invalid ultra light fraction %d", fraction)
+	}
+	keys, err := parseULCServers(servers)
+	if err != nil {
+		return err
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.keys, u.fraction = keys, fraction
+	return nil
+}
+
+// ulcWatcher reloads the ultra light client config file when it changes.
+type ulcWatcher struct {
+	path   string
+	apply  func(servers []string, fraction int) error
+	closed chan struct{}
+	wg     sync.WaitGroup
+
+	modTime time.Time // Modification time of the last loaded version
+}
+
+// newULCWatcher creates a watcher of the config file at path, which is assumed
+// to be loaded already.
+func newULCWatcher(path string, apply func([]string, int) error) *ulcWatcher {
+	w := &ulcWatcher{path: path, apply: apply, closed: make(chan struct{})}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+func (w *ulcWatcher) start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+func (w *ulcWatcher) stop() {
+	close(w.closed)
+	w.wg.Wait()
+}
+
+func (w *ulcWatcher) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(ulcReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.reload()
+		case <-w.closed:
+			return
+		}
+	}
+}
+
+// reload applies the config file if it has been modified since the last load.
+func (w *ulcWatcher) reload() {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		log.Warn("Failed to check ultra light client config", "path", w.path, "err", err)
+		return
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return
+	}
+	w.modTime = info.ModTime()
+
+	config, err := loadULCConfig(w.path)
+	if err == nil {
+		err = w.apply(config.Servers, config.Fraction)
+	}
+	if err != nil {
+		log.Error("Failed to reload ultra light client config", "path", w.path, "err", err)
+		return
+	}
+	log.Info("Reloaded ultra light client config", "path", w.path, "trustedNodes", len(config.Servers), "minTrustedFraction", config.Fraction)
+}
@@ -19,11 +19,15 @@ package les
 import (
 	"crypto/rand"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/eth/ethconfig"
 	"github.com/acent/go-acent/p2p"
 	"github.com/acent/go-acent/p2p/enode"
 )
@@ -152,3 +156,67 @@ func newTestLightPeer(t *testing.T, protocol int, ulcServers []string, ulcFracti
 	_, c, teardown := newClientServerEnv(t, netconfig)
 	return c, teardown
 }
+
+func TestULCUpdate(t *testing.T) {
+	var nodes []string
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		nodes = append(nodes, enode.NewV4(&key.PublicKey, net.ParseIP("127.0.0.1"), 35000, 35000).String())
+	}
+	u, err := newULC(nodes[:2], 100)
+	if err != nil {
+		t.Fatalf("failed to create ulc: %v", err)
+	}
+	id := func(i int) enode.ID { return enode.MustParse(nodes[i]).ID() }
+	if !u.trusted(id(0)) || u.trusted(id(2)) || u.enough(1) || !u.enough(2) {
+		t.Fatalf("wrong initial trust")
+	}
+	if err := u.update(nodes[1:], 30); err != nil {
+		t.Fatalf("failed to update ulc: %v", err)
+	}
+	if u.trusted(id(0)) || !u.trusted(id(3)) || !u.enough(1) {
+		t.Fatalf("wrong trust after update")
+	}
+	// Invalid updates must leave the settings intact.
+	if err := u.update(nil, 50); err == nil {
+		t.Fatalf("empty server list accepted")
+	}
+	if err := u.update(nodes, 101); err == nil {
+		t.Fatalf("invalid fraction accepted")
+	}
+	if trusted, fraction := u.settings(); trusted != 3 || fraction != 30 {
+		t.Fatalf("wrong settings after invalid updates: %d servers, %d%%", trusted, fraction)
+	}
+}
+
+func TestULCConfigReload(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	node := enode.NewV4(&key.PublicKey, net.ParseIP("127.0.0.1"), 35000, 35000).String()
+
+	path := filepath.Join(t.TempDir(), "ulc.json")
+	if err := ioutil.WriteFile(path, []byte(`{"servers": ["`+node+`"], "fraction": 50}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		servers  []string
+		fraction int
+	)
+	w := newULCWatcher(path, func(s []string, f int) error {
+		servers, fraction = s, f
+		return nil
+	})
+	// Unchanged files must not be reloaded.
+	w.reload()
+	if servers != nil {
+		t.Fatalf("unchanged config reloaded")
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"servers": ["`+node+`"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	w.reload()
+	if len(servers) != 1 || servers[0] != node || fraction != ethconfig.Defaults.UltraLightFraction {
+		t.Fatalf("wrong config reloaded: %v, %d%%", servers, fraction)
+	}
+}
@@ -64,10 +64,13 @@ type ServerPool struct {
 	mixSources          []enode.Iterator
 	dialIterator        enode.Iterator
 	validSchemes        enr.IdentityScheme
-	trustedURLs         []string
 	fillSet             *FillSet
 	started, queryFails uint32
 
+	trustedLock    sync.Mutex
+	trustedURLs    []string
+	trustedApplied bool // Whether the trusted servers are marked in the node state machine
+
 	timeoutLock      sync.RWMutex
 	timeout          time.Duration
 	timeWeights      ResponseTimeWeights
@@ -303,6 +306,45 @@ func (s *ServerPool) addPreNegFilter(input enode.Iterator, query QueryFunc) enod
 	})
 }
 
+// SetTrustedURLs replaces the trusted servers which the pool always tries to stay
+// connected to.
+func (s *ServerPool) SetTrustedURLs(urls []string) {
+	s.trustedLock.Lock()
+	defer s.trustedLock.Unlock()
+
+	if s.trustedApplied {
+		keep := make(map[string]bool)
+		for _, url := range urls {
+			keep[url] = true
+		}
+		var removed []string
+		for _, url := range s.trustedURLs {
+			if !keep[url] {
+				removed = append(removed, url)
+			}
+		}
+		s.setTrusted(removed, false)
+		s.setTrusted(urls, true)
+	}
+	s.trustedURLs = urls
+}
+
+// setTrusted sets or resets the always connect flag of the given servers.
+func (s *ServerPool) setTrusted(urls []string, trusted bool) {
+	for _, url := range urls {
+		node, err := enode.Parse(s.validSchemes, url)
+		if err != nil {
+			log.Error("Invalid trusted server URL", "url", url, "error", err)
+			continue
+		}
+		if trusted {
+			s.ns.SetState(node, sfAlwaysConnect, nodestate.Flags{}, 0)
+		} else {
+			s.ns.SetState(node, nodestate.Flags{}, sfAlwaysConnect, 0)
+		}
+	}
+}
+
 // start starts the server pool. Note that NodeStateMachine should be started first.
 func (s *ServerPool) Start() {
 	s.ns.Start()
@@ -311,13 +353,10 @@ func (s *ServerPool) Start() {
 		// which should only happen after NodeStateMachine has been started
 		s.mixer.AddSource(iter)
 	}
-	for _, url := range s.trustedURLs {
-		if node, err := enode.Parse(s.validSchemes, url); err == nil {
-			s.ns.SetState(node, sfAlwaysConnect, nodestate.Flags{}, 0)
-		} else {
-			log.Error("Invalid trusted server URL", "url", url, "error", err)
-		}
-	}
+	s.trustedLock.Lock()
+	s.setTrusted(s.trustedURLs, true)
+	s.trustedApplied = true
+	s.trustedLock.Unlock()
 	unixTime := s.unixTime()
 	s.ns.Operation(func() {
 		s.ns.ForEach(sfHasValue, nodestate.Flags{}, func(node *enode.Node, state nodestate.Flags) {
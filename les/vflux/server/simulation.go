@@ -0,0 +1,245 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// PricePoint is the cost of a connection at a given capacity.
+type PricePoint struct {
+	Capacity uint64  `json:"capacity"`
+	PerHour  float64 `json:"perHour"` // Tokens spent per hour of connection
+}
+
+// PricingCurve calculates the hourly connection cost at the given capacities
+// for a client sending requests at the given rate (cost units per second).
+func PricingCurve(factors PriceFactors, capacities []uint64, requestRate float64) []PricePoint {
+	points := make([]PricePoint, len(capacities))
+	for i, capacity := range capacities {
+		perNs := factors.timePrice(capacity) + factors.RequestFactor*requestRate/float64(time.Second)
+		points[i] = PricePoint{Capacity: capacity, PerHour: perNs * float64(time.Hour)}
+	}
+	return points
+}
+
+// ClientProfile describes a class of identical clients for capacity simulation.
+type ClientProfile struct {
+	Name        string        `json:"name"`
+	Count       int           `json:"count"`       // Number of clients in the class
+	Capacity    uint64        `json:"capacity"`    // Capacity requested by paying clients
+	Balance     uint64        `json:"balance"`     // Initial positive balance (0 = free client)
+	RequestRate float64       `json:"requestRate"` // Request cost units sent per second while connected
+	Session     time.Duration `json:"session"`     // Average connected time wanted per session
+	Interval    time.Duration `json:"interval"`    // Average idle time between sessions
+}
+
+// SimulationConfig contains the parameters of a capacity simulation.
+type SimulationConfig struct {
+	TotalCapacity uint64          // Total capacity of the simulated server
+	MinCapacity   uint64          // Capacity assigned to free clients
+	Factors       PriceFactors    // Positive balance price factors
+	Profiles      []ClientProfile // Client population
+	Duration      time.Duration   // Simulated time span
+	Step          time.Duration   // Simulation resolution (default one second)
+	Seed          int64           // Random seed, making runs reproducible
+}
+
+// ProfileResult is the outcome of a simulation for a single client class.
+type ProfileResult struct {
+	Name      string        `json:"name"`
+	Sessions  int           `json:"sessions"`  // Number of successful connections
+	Evictions int           `json:"evictions"` // Number of times a client was kicked out
+	AvgWait   time.Duration `json:"avgWait"`   // Average wait time before getting connected
+	MaxWait   time.Duration `json:"maxWait"`   // Maximum wait time before getting connected
+	Served    float64       `json:"served"`    // Ratio of connected time to the time clients wanted to be connected
+	Spent     float64       `json:"spent"`     // Total tokens spent by the class
+	Exhausted int           `json:"exhausted"` // Number of clients running out of balance
+}
+
+// SimulationResult is the outcome of a capacity simulation.
+type SimulationResult struct {
+	Profiles    []ProfileResult `json:"profiles"`
+	Utilization float64         `json:"utilization"` // Average ratio of connected to total capacity
+}
+
+var errInvalidSimulation = errors.New("invalid simulation config")
+
+// simClient is the state of a single simulated client.
+type simClient struct {
+	profile   int
+	balance   float64
+	capacity  uint64
+	connected bool
+	wanting   bool
+	since     time.Duration // Time the current waiting or idle period started
+	next      time.Duration // Time the current idle period ends
+	remaining time.Duration // Connected time remaining from the current session
+}
+
+// priority returns the client's priority when competing for capacity, similar
+// to the positive balance priority of the real pool: balance per capacity unit.
+func (c *simClient) priority() float64 {
+	if c.balance <= 0 {
+		return 0
+	}
+	return c.balance / float64(c.capacity)
+}
+
+// SimulateCapacity runs a simplified simulation of the client pool's token
+// economy: clients alternate between idle and connected periods, paying clients
+// spend their balance according to the price factors and can evict clients of
+// lower priority, while everyone else waits for free capacity. The result can
+// be used to estimate the wait times and service levels of the client classes
+// under a given pricing policy.
+func SimulateCapacity(cfg SimulationConfig) (*SimulationResult, error) {
+	if cfg.TotalCapacity == 0 || cfg.MinCapacity == 0 || cfg.Duration <= 0 {
+		return nil, errInvalidSimulation
+	}
+	if cfg.Step <= 0 {
+		cfg.Step = time.Second
+	}
+	var (
+		rng     = rand.New(rand.NewSource(cfg.Seed))
+		clients []*simClient
+		results = make([]ProfileResult, len(cfg.Profiles))
+		waits   = make([]time.Duration, len(cfg.Profiles))
+		wanted  = make([]time.Duration, len(cfg.Profiles))
+		served  = make([]time.Duration, len(cfg.Profiles))
+
+		used, usedSum float64
+		steps         int
+	)
+	draw := func(mean time.Duration) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+	for i, profile := range cfg.Profiles {
+		if profile.Count < 0 || profile.Session <= 0 {
+			return nil, errInvalidSimulation
+		}
+		results[i].Name = profile.Name
+		for j := 0; j < profile.Count; j++ {
+			c := &simClient{profile: i, balance: float64(profile.Balance), capacity: cfg.MinCapacity}
+			if profile.Balance > 0 && profile.Capacity > cfg.MinCapacity {
+				c.capacity = profile.Capacity
+			}
+			c.next = draw(profile.Interval)
+			clients = append(clients, c)
+		}
+	}
+	connect := func(c *simClient, now time.Duration) {
+		wait := now - c.since
+		res := &results[c.profile]
+		res.Sessions++
+		waits[c.profile] += wait
+		if wait > res.MaxWait {
+			res.MaxWait = wait
+		}
+		c.connected = true
+		used += float64(c.capacity)
+	}
+	disconnect := func(c *simClient, now time.Duration) {
+		c.connected = false
+		c.since = now
+		used -= float64(c.capacity)
+	}
+	for now := time.Duration(0); now < cfg.Duration; now += cfg.Step {
+		// Advance the state of the individual clients
+		var waiting []*simClient
+		for _, c := range clients {
+			profile := &cfg.Profiles[c.profile]
+			switch {
+			case c.connected:
+				served[c.profile] += cfg.Step
+				wanted[c.profile] += cfg.Step
+				if c.balance > 0 {
+					cost := PricingCurve(cfg.Factors, []uint64{c.capacity}, profile.RequestRate)[0].PerHour * float64(cfg.Step) / float64(time.Hour)
+					if cost >= c.balance {
+						cost = c.balance
+						results[c.profile].Exhausted++
+					}
+					c.balance -= cost
+					results[c.profile].Spent += cost
+				}
+				if c.remaining -= cfg.Step; c.remaining <= 0 {
+					disconnect(c, now)
+					c.wanting, c.next = false, now+draw(profile.Interval)
+				} else if c.balance <= 0 && c.capacity != cfg.MinCapacity {
+					// Out of tokens, fall back to free service at minimum capacity
+					used -= float64(c.capacity - cfg.MinCapacity)
+					c.capacity = cfg.MinCapacity
+				}
+			case c.wanting:
+				wanted[c.profile] += cfg.Step
+				waiting = append(waiting, c)
+			case now >= c.next:
+				c.wanting, c.since, c.remaining = true, now, draw(profile.Session)+cfg.Step
+				waiting = append(waiting, c)
+			}
+		}
+		// Admit the waiting clients in priority order, evicting lower priority
+		// clients if there's not enough free capacity
+		sort.SliceStable(waiting, func(i, j int) bool {
+			return waiting[i].priority() > waiting[j].priority()
+		})
+		for _, c := range waiting {
+			if used+float64(c.capacity) <= float64(cfg.TotalCapacity) {
+				connect(c, now)
+				continue
+			}
+			var victims []*simClient
+			for _, v := range clients {
+				if v.connected && v.priority() < c.priority() {
+					victims = append(victims, v)
+				}
+			}
+			sort.Slice(victims, func(i, j int) bool {
+				return victims[i].priority() < victims[j].priority()
+			})
+			free, evict := float64(cfg.TotalCapacity)-used, 0
+			for evict < len(victims) && free < float64(c.capacity) {
+				free += float64(victims[evict].capacity)
+				evict++
+			}
+			if free < float64(c.capacity) {
+				continue
+			}
+			for _, v := range victims[:evict] {
+				disconnect(v, now)
+				results[v.profile].Evictions++
+			}
+			connect(c, now)
+		}
+		usedSum += used
+		steps++
+	}
+	for i := range results {
+		if results[i].Sessions > 0 {
+			results[i].AvgWait = waits[i] / time.Duration(results[i].Sessions)
+		}
+		if wanted[i] > 0 {
+			results[i].Served = float64(served[i]) / float64(wanted[i])
+		}
+	}
+	return &SimulationResult{
+		Profiles:    results,
+		Utilization: usedSum / float64(steps) / float64(cfg.TotalCapacity),
+	}, nil
+}
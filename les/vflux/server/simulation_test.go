@@ -0,0 +1,81 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPricingCurve(t *testing.T) {
+	factors := PriceFactors{
+		TimeFactor:     1 / float64(time.Second),
+		CapacityFactor: 1 / float64(time.Second),
+		RequestFactor:  1,
+	}
+	points := PricingCurve(factors, []uint64{1000000, 2000000}, 10)
+	// One token per second, plus one per million capacity units per second, plus
+	// one per request cost unit
+	for i, want := range []float64{(1 + 1 + 10) * 3600, (1 + 2 + 10) * 3600} {
+		if math.Abs(points[i].PerHour-want) > 1e-6 {
+			t.Errorf("point %d: hourly price mismatch: have %f, want %f", i, points[i].PerHour, want)
+		}
+	}
+}
+
+func TestSimulateCapacity(t *testing.T) {
+	cfg := SimulationConfig{
+		TotalCapacity: 100,
+		MinCapacity:   10,
+		Factors:       PriceFactors{TimeFactor: 1 / float64(time.Second)},
+		Profiles: []ClientProfile{
+			{Name: "free", Count: 50, Session: 10 * time.Minute, Interval: time.Minute},
+			{Name: "paid", Count: 5, Capacity: 10, Balance: 1000000, Session: 10 * time.Minute, Interval: time.Minute},
+		},
+		Duration: 2 * time.Hour,
+		Seed:     1,
+	}
+	res, err := SimulateCapacity(cfg)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	free, paid := res.Profiles[0], res.Profiles[1]
+	if paid.Served <= free.Served {
+		t.Errorf("paying clients not prioritized: served %f, free clients %f", paid.Served, free.Served)
+	}
+	if paid.AvgWait >= free.AvgWait {
+		t.Errorf("paying clients wait longer: %v, free clients %v", paid.AvgWait, free.AvgWait)
+	}
+	if free.Evictions == 0 {
+		t.Errorf("no free clients evicted")
+	}
+	if res.Utilization <= 0.5 || res.Utilization > 1 {
+		t.Errorf("utilization out of range: %f", res.Utilization)
+	}
+	// Simulations must be reproducible
+	again, _ := SimulateCapacity(cfg)
+	if again.Profiles[0] != free || again.Profiles[1] != paid {
+		t.Errorf("simulation not deterministic")
+	}
+	// Cheap balances should be exhausted
+	cfg.Profiles[1].Balance = 60
+	res, _ = SimulateCapacity(cfg)
+	if res.Profiles[1].Exhausted != 5 {
+		t.Errorf("exhausted client count mismatch: have %d, want %d", res.Profiles[1].Exhausted, 5)
+	}
+}
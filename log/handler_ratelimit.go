@@ -0,0 +1,273 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errRateLimitSyntax is returned when a user rate limit ruleset is invalid.
+var errRateLimitSyntax = errors.New("expect comma-separated list of message=N/period")
+
+// rateLimitSweep is the minimum time between two scans for expired rate limit
+// windows whose suppressed records need to be summarized.
+const rateLimitSweep = time.Second
+
+// rateLimit is the number of records of a message class let through within a
+// period of time.
+type rateLimit struct {
+	count  int
+	period time.Duration
+}
+
+// rateWindow is the accounting of a message class within its current period.
+type rateWindow struct {
+	limit      rateLimit
+	start      time.Time // Time of the first record of the window
+	count      int       // Number of records let through
+	suppressed int       // Number of records dropped
+	lvl        Lvl       // Most severe level among the dropped records
+	last       *Record   // Last dropped record, source of the summary's context
+}
+
+// RateLimitHandler is a log handler that suppresses repetitive records, letting
+// only a limited number of records with the same message through per period. At
+// the end of each period the number of suppressed records is reported instead,
+// even if no further records arrive to trigger it.
+type RateLimitHandler struct {
+	origin Handler // The origin handler this wraps
+
+	limited uint32 // Flag whether rate limits are set, atomically accessible
+
+	rules    map[string]rateLimit   // Rate limits of explicitly configured messages
+	fallback *rateLimit             // Rate limit of any other message, if set
+	windows  map[string]*rateWindow // Current windows of recently seen messages
+	swept    time.Time              // Time of the last scan for expired windows
+	flusher  *time.Timer            // Timer summarizing windows once they expire
+	deadline time.Time              // Time the flush timer is due to fire at
+	lock     sync.Mutex             // Lock protecting the rules and windows
+}
+
+// NewRateLimitHandler creates a new log handler suppressing repetitive records.
+// The returned handler lets everything through until rate limits are set.
+func NewRateLimitHandler(h Handler) *RateLimitHandler {
+	return &RateLimitHandler{
+		origin:  h,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// SetHandler updates the handler to write records to the specified sub-handler.
+func (h *RateLimitHandler) SetHandler(nh Handler) {
+	h.origin = nh
+}
+
+// RateLimits sets the per message rate limits.
+//
+// The syntax of the argument is a comma-separated list of message=N/period, where
+// message is the literal message of the records to limit, or "*" to limit every
+// message not listed explicitly, N is the number of records let through and
+// period is a duration such as "30s" or "1m".
+//
+// For instance:
+//
+//  ruleset="Synchronisation failed, dropping peer=10/1m"
+//   lets 10 sync failure warnings through every minute
+//
+//  ruleset="*=100/10s"
+//   lets at most 100 records of each distinct message through every 10 seconds
+func (h *RateLimitHandler) RateLimits(ruleset string) error {
+	var (
+		rules    = make(map[string]rateLimit)
+		fallback *rateLimit
+	)
+	for _, rule := range strings.Split(ruleset, ",") {
+		// Empty strings such as from a trailing comma can be ignored
+		if len(strings.TrimSpace(rule)) == 0 {
+			continue
+		}
+		// Ensure we have a message = limit rule, messages may contain spaces
+		pos := strings.LastIndex(rule, "=")
+		if pos < 0 {
+			return errRateLimitSyntax
+		}
+		msg, spec := strings.TrimSpace(rule[:pos]), strings.TrimSpace(rule[pos+1:])
+		parts := strings.Split(spec, "/")
+		if len(msg) == 0 || len(parts) != 2 {
+			return errRateLimitSyntax
+		}
+		// Parse the count and period and if correct, assemble the limit
+		count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || count < 0 {
+			return errRateLimitSyntax
+		}
+		period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || period <= 0 {
+			return errRateLimitSyntax
+		}
+		limit := rateLimit{count: count, period: period}
+		if msg == "*" {
+			fallback = &limit
+		} else {
+			rules[msg] = limit
+		}
+	}
+	// Swap out the rules, summarizing anything suppressed under the old ones
+	h.lock.Lock()
+	summaries := h.sweep(time.Time{}, true)
+	h.rules, h.fallback = rules, fallback
+	if len(rules) > 0 || fallback != nil {
+		atomic.StoreUint32(&h.limited, 1)
+	} else {
+		atomic.StoreUint32(&h.limited, 0)
+	}
+	h.lock.Unlock()
+
+	for _, summary := range summaries {
+		h.origin.Log(summary)
+	}
+	return nil
+}
+
+// Log implements Handler.Log, dropping the record if its message exceeded its
+// rate limit, and emitting summaries of the records suppressed in past periods.
+func (h *RateLimitHandler) Log(r *Record) error {
+	// If no rate limits are set, fast track logging
+	if atomic.LoadUint32(&h.limited) == 0 {
+		return h.origin.Log(r)
+	}
+	h.lock.Lock()
+	var summaries []*Record
+	if r.Time.Sub(h.swept) >= rateLimitSweep {
+		summaries = h.sweep(r.Time, false)
+	}
+	// Account the record in the current window of its message
+	window, ok := h.windows[r.Msg]
+	if ok && r.Time.Sub(window.start) >= window.limit.period {
+		if summary := window.summary(); summary != nil {
+			summaries = append(summaries, summary)
+		}
+		delete(h.windows, r.Msg)
+		ok = false
+	}
+	if !ok {
+		limit, limited := h.rules[r.Msg]
+		if !limited && h.fallback != nil {
+			limit, limited = *h.fallback, true
+		}
+		if limited {
+			window = &rateWindow{limit: limit, start: r.Time}
+			h.windows[r.Msg] = window
+		}
+	}
+	pass := true
+	if window != nil {
+		if window.count < window.limit.count {
+			window.count++
+		} else {
+			if window.suppressed == 0 || r.Lvl < window.lvl {
+				window.lvl = r.Lvl
+			}
+			window.suppressed++
+			window.last = r
+			pass = false
+
+			h.schedule(r.Time, window.start.Add(window.limit.period))
+		}
+	}
+	h.lock.Unlock()
+
+	// Emit the summaries first, they are about records preceding this one
+	for _, summary := range summaries {
+		h.origin.Log(summary)
+	}
+	if !pass {
+		return nil
+	}
+	return h.origin.Log(r)
+}
+
+// sweep drops all the windows whose period expired before the given time, or
+// all of them if forced, returning summaries of the records they suppressed.
+// The caller must hold the lock.
+func (h *RateLimitHandler) sweep(now time.Time, force bool) []*Record {
+	var summaries []*Record
+	for msg, window := range h.windows {
+		if !force && now.Sub(window.start) < window.limit.period {
+			continue
+		}
+		if summary := window.summary(); summary != nil {
+			summaries = append(summaries, summary)
+		}
+		delete(h.windows, msg)
+	}
+	h.swept = now
+	return summaries
+}
+
+// schedule arms the flush timer to fire at the given deadline, unless it's due
+// to fire earlier already. The caller must hold the lock.
+func (h *RateLimitHandler) schedule(now, deadline time.Time) {
+	if h.flusher != nil {
+		if !h.deadline.After(deadline) {
+			return
+		}
+		h.flusher.Stop()
+	}
+	h.flusher, h.deadline = time.AfterFunc(deadline.Sub(now), h.flush), deadline
+}
+
+// flush summarizes the records suppressed in expired windows, rearming the flush
+// timer if any window still has suppressed records to report later.
+func (h *RateLimitHandler) flush() {
+	h.lock.Lock()
+	now := time.Now()
+	h.flusher = nil
+
+	summaries := h.sweep(now, false)
+	for _, window := range h.windows {
+		if window.suppressed > 0 {
+			h.schedule(now, window.start.Add(window.limit.period))
+		}
+	}
+	h.lock.Unlock()
+
+	for _, summary := range summaries {
+		h.origin.Log(summary)
+	}
+}
+
+// summary creates a record reporting the number of records suppressed within
+// the window, or nil if none were.
+func (w *rateWindow) summary() *Record {
+	if w.suppressed == 0 {
+		return nil
+	}
+	return &Record{
+		Time:     time.Now(),
+		Lvl:      w.lvl,
+		Msg:      "Suppressed repeated log messages",
+		Ctx:      []interface{}{"message", w.last.Msg, "count", w.suppressed, "period", w.limit.period},
+		Call:     w.last.Call,
+		KeyNames: w.last.KeyNames,
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordCollector is a handler gathering the records it is passed.
+type recordCollector struct {
+	lock    sync.Mutex
+	records []*Record
+}
+
+func (c *recordCollector) Log(r *Record) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.records = append(c.records, r)
+	return nil
+}
+
+// messages returns the messages of the gathered records, in order.
+func (c *recordCollector) messages() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var msgs []string
+	for _, r := range c.records {
+		msgs = append(msgs, r.Msg)
+	}
+	return msgs
+}
+
+// summaries returns the summary records gathered, in order.
+func (c *recordCollector) summaries() []*Record {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var summaries []*Record
+	for _, r := range c.records {
+		if r.Msg == "Suppressed repeated log messages" {
+			summaries = append(summaries, r)
+		}
+	}
+	return summaries
+}
+
+func TestRateLimitsParse(t *testing.T) {
+	h := NewRateLimitHandler(DiscardHandler())
+
+	valid := []string{
+		"",
+		"foo=1/1s",
+		"foo bar = 10 / 1m, *=100/10s,",
+		"key=value=5/1h",
+		"foo=0/1s",
+	}
+	for _, ruleset := range valid {
+		if err := h.RateLimits(ruleset); err != nil {
+			t.Errorf("ruleset %q: unexpected error: %v", ruleset, err)
+		}
+	}
+	invalid := []string{
+		"foo",
+		"=1/1s",
+		"foo=1",
+		"foo=1/1s/1s",
+		"foo=x/1s",
+		"foo=-1/1s",
+		"foo=1/x",
+		"foo=1/0s",
+		"foo=1/-1s",
+	}
+	for _, ruleset := range invalid {
+		if err := h.RateLimits(ruleset); err != errRateLimitSyntax {
+			t.Errorf("ruleset %q: error mismatch: have %v, want %v", ruleset, err, errRateLimitSyntax)
+		}
+	}
+}
+
+func TestRateLimitSuppression(t *testing.T) {
+	var (
+		out  = new(recordCollector)
+		h    = NewRateLimitHandler(out)
+		base = time.Now()
+	)
+	if err := h.RateLimits("foo=2/1h,*=1/1h"); err != nil {
+		t.Fatal(err)
+	}
+	// Records beyond the limit of their message are suppressed, others pass
+	for i := 0; i < 5; i++ {
+		h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+		h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "bar"})
+	}
+	have, want := out.messages(), []string{"foo", "bar", "foo"}
+	if len(have) != len(want) {
+		t.Fatalf("records mismatch: have %v, want %v", have, want)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("records mismatch: have %v, want %v", have, want)
+		}
+	}
+	// Removing the limits lets everything through again
+	if err := h.RateLimits(""); err != nil {
+		t.Fatal(err)
+	}
+	before := len(out.messages())
+	h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+	if have := len(out.messages()); have != before+1 {
+		t.Errorf("record not passed without limits")
+	}
+}
+
+func TestRateLimitSummary(t *testing.T) {
+	var (
+		out  = new(recordCollector)
+		h    = NewRateLimitHandler(out)
+		base = time.Now()
+	)
+	if err := h.RateLimits("foo=1/1h"); err != nil {
+		t.Fatal(err)
+	}
+	h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+	h.Log(&Record{Time: base, Lvl: LvlDebug, Msg: "foo"})
+	h.Log(&Record{Time: base, Lvl: LvlWarn, Msg: "foo"})
+	h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+	if summaries := out.summaries(); len(summaries) != 0 {
+		t.Fatalf("summary emitted within the window: %v", summaries)
+	}
+	// The next record of the message after the period reports the suppressed ones
+	h.Log(&Record{Time: base.Add(time.Hour), Lvl: LvlInfo, Msg: "foo"})
+
+	summaries := out.summaries()
+	if len(summaries) != 1 {
+		t.Fatalf("summary count mismatch: have %d, want 1", len(summaries))
+	}
+	summary := summaries[0]
+	if summary.Lvl != LvlWarn {
+		t.Errorf("summary level mismatch: have %v, want %v", summary.Lvl, LvlWarn)
+	}
+	ctx := []interface{}{"message", "foo", "count", 3, "period", time.Hour}
+	if len(summary.Ctx) != len(ctx) {
+		t.Fatalf("summary context mismatch: have %v, want %v", summary.Ctx, ctx)
+	}
+	for i := range ctx {
+		if summary.Ctx[i] != ctx[i] {
+			t.Fatalf("summary context mismatch: have %v, want %v", summary.Ctx, ctx)
+		}
+	}
+	// The record starting the new window must pass after its summary
+	if have := out.messages(); have[len(have)-1] != "foo" {
+		t.Errorf("record of the new window not passed: %v", have)
+	}
+}
+
+func TestRateLimitSweep(t *testing.T) {
+	var (
+		out  = new(recordCollector)
+		h    = NewRateLimitHandler(out)
+		base = time.Now()
+	)
+	if err := h.RateLimits("foo=1/1h,bar=1/1h"); err != nil {
+		t.Fatal(err)
+	}
+	h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+	h.Log(&Record{Time: base, Lvl: LvlInfo, Msg: "foo"})
+
+	// A record of a different message triggers the sweep of expired windows
+	h.Log(&Record{Time: base.Add(time.Hour), Lvl: LvlInfo, Msg: "bar"})
+	if summaries := out.summaries(); len(summaries) != 1 || summaries[0].Ctx[1] != "foo" {
+		t.Fatalf("expired window not swept: %v", summaries)
+	}
+	// Changing the rules summarizes everything suppressed under the old ones
+	h.Log(&Record{Time: base.Add(time.Hour), Lvl: LvlInfo, Msg: "bar"})
+	if err := h.RateLimits("foo=1/1h"); err != nil {
+		t.Fatal(err)
+	}
+	if summaries := out.summaries(); len(summaries) != 2 || summaries[1].Ctx[1] != "bar" {
+		t.Fatalf("windows not swept on rule change: %v", summaries)
+	}
+}
+
+func TestRateLimitFlush(t *testing.T) {
+	var (
+		out = new(recordCollector)
+		h   = NewRateLimitHandler(out)
+	)
+	if err := h.RateLimits("foo=1/50ms"); err != nil {
+		t.Fatal(err)
+	}
+	// A burst followed by silence must still report what it suppressed
+	for i := 0; i < 3; i++ {
+		h.Log(&Record{Time: time.Now(), Lvl: LvlInfo, Msg: "foo"})
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if summaries := out.summaries(); len(summaries) > 0 {
+			if summaries[0].Ctx[3] != 2 {
+				t.Fatalf("suppressed count mismatch: have %v, want 2", summaries[0].Ctx[3])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("suppressed records not summarized after the period")
+}
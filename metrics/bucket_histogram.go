@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultBuckets are the default upper bounds of BucketHistograms, suited to
+// durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// BucketHistograms count observations into buckets of fixed upper bounds, and
+// keep their count and sum. Unlike Histograms they don't sample, so they can
+// be aggregated across nodes.
+type BucketHistogram interface {
+	Buckets() []float64
+	Clear()
+	Count() int64
+	Counts() []uint64
+	Observe(float64)
+	Snapshot() BucketHistogram
+	Sum() float64
+}
+
+// GetOrRegisterBucketHistogram returns an existing BucketHistogram or
+// constructs and registers a new StandardBucketHistogram.
+func GetOrRegisterBucketHistogram(name string, r Registry, buckets []float64) BucketHistogram {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() BucketHistogram { return NewBucketHistogram(buckets) }).(BucketHistogram)
+}
+
+// NewBucketHistogram constructs a new StandardBucketHistogram with the given
+// bucket upper bounds, DefaultBuckets if nil.
+func NewBucketHistogram(buckets []float64) BucketHistogram {
+	if !Enabled {
+		return NilBucketHistogram{}
+	}
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &StandardBucketHistogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// NewRegisteredBucketHistogram constructs and registers a new
+// StandardBucketHistogram.
+func NewRegisteredBucketHistogram(name string, r Registry, buckets []float64) BucketHistogram {
+	c := NewBucketHistogram(buckets)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// BucketHistogramSnapshot is a read-only copy of another BucketHistogram.
+type BucketHistogramSnapshot struct {
+	buckets []float64
+	counts  []uint64
+	count   int64
+	sum     float64
+}
+
+// Buckets returns the upper bounds of the buckets.
+func (h *BucketHistogramSnapshot) Buckets() []float64 { return h.buckets }
+
+// Clear panics.
+func (*BucketHistogramSnapshot) Clear() {
+	panic("Clear called on a BucketHistogramSnapshot")
+}
+
+// Count returns the number of observations at the time the snapshot was taken.
+func (h *BucketHistogramSnapshot) Count() int64 { return h.count }
+
+// Counts returns the cumulative number of observations in each bucket at the
+// time the snapshot was taken.
+func (h *BucketHistogramSnapshot) Counts() []uint64 { return h.counts }
+
+// Observe panics.
+func (*BucketHistogramSnapshot) Observe(float64) {
+	panic("Observe called on a BucketHistogramSnapshot")
+}
+
+// Snapshot returns the snapshot.
+func (h *BucketHistogramSnapshot) Snapshot() BucketHistogram { return h }
+
+// Sum returns the sum of the observations at the time the snapshot was taken.
+func (h *BucketHistogramSnapshot) Sum() float64 { return h.sum }
+
+// NilBucketHistogram is a no-op BucketHistogram.
+type NilBucketHistogram struct{}
+
+// Buckets is a no-op.
+func (NilBucketHistogram) Buckets() []float64 { return nil }
+
+// Clear is a no-op.
+func (NilBucketHistogram) Clear() {}
+
+// Count is a no-op.
+func (NilBucketHistogram) Count() int64 { return 0 }
+
+// Counts is a no-op.
+func (NilBucketHistogram) Counts() []uint64 { return nil }
+
+// Observe is a no-op.
+func (NilBucketHistogram) Observe(float64) {}
+
+// Snapshot is a no-op.
+func (NilBucketHistogram) Snapshot() BucketHistogram { return NilBucketHistogram{} }
+
+// Sum is a no-op.
+func (NilBucketHistogram) Sum() float64 { return 0 }
+
+// StandardBucketHistogram is the standard implementation of a BucketHistogram.
+type StandardBucketHistogram struct {
+	buckets []float64
+	counts  []uint64 // Non-cumulative number of observations per bucket
+	count   int64
+	sum     float64
+	mutex   sync.Mutex
+}
+
+// Buckets returns the upper bounds of the buckets.
+func (h *StandardBucketHistogram) Buckets() []float64 { return h.buckets }
+
+// Clear clears the histogram.
+func (h *StandardBucketHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.counts = make([]uint64, len(h.buckets))
+	h.count, h.sum = 0, 0
+}
+
+// Count returns the number of observations.
+func (h *StandardBucketHistogram) Count() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.count
+}
+
+// Counts returns the cumulative number of observations in each bucket.
+func (h *StandardBucketHistogram) Counts() []uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.cumulative()
+}
+
+// cumulative returns the cumulative number of observations in each bucket. The
+// caller must hold the mutex.
+func (h *StandardBucketHistogram) cumulative() []uint64 {
+	counts := make([]uint64, len(h.counts))
+	var total uint64
+	for i, count := range h.counts {
+		total += count
+		counts[i] = total
+	}
+	return counts
+}
+
+// Observe records a new observation.
+func (h *StandardBucketHistogram) Observe(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// Snapshot returns a read-only copy of the histogram.
+func (h *StandardBucketHistogram) Snapshot() BucketHistogram {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return &BucketHistogramSnapshot{
+		buckets: h.buckets,
+		counts:  h.cumulative(),
+		count:   h.count,
+		sum:     h.sum,
+	}
+}
+
+// Sum returns the sum of the observations.
+func (h *StandardBucketHistogram) Sum() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.sum
+}
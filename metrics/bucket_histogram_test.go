@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkBucketHistogram(b *testing.B) {
+	h := NewBucketHistogram(nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Observe(float64(i%100) / 10)
+	}
+}
+
+func TestBucketHistogramObserve(t *testing.T) {
+	h := NewBucketHistogram([]float64{10, 1})
+	for _, v := range []float64{0.5, 1, 5, 50} {
+		h.Observe(v)
+	}
+	if buckets := h.Buckets(); !reflect.DeepEqual(buckets, []float64{1, 10}) {
+		t.Errorf("h.Buckets(): [1 10] != %v\n", buckets)
+	}
+	if counts := h.Counts(); !reflect.DeepEqual(counts, []uint64{2, 3}) {
+		t.Errorf("h.Counts(): [2 3] != %v\n", counts)
+	}
+	if count := h.Count(); count != 4 {
+		t.Errorf("h.Count(): 4 != %v\n", count)
+	}
+	if sum := h.Sum(); sum != 56.5 {
+		t.Errorf("h.Sum(): 56.5 != %v\n", sum)
+	}
+}
+
+func TestBucketHistogramSnapshot(t *testing.T) {
+	h := NewBucketHistogram([]float64{1})
+	h.Observe(0.5)
+	snapshot := h.Snapshot()
+	h.Observe(0.5)
+	if counts := snapshot.Counts(); !reflect.DeepEqual(counts, []uint64{1}) {
+		t.Errorf("snapshot.Counts(): [1] != %v\n", counts)
+	}
+	if count := snapshot.Count(); count != 1 {
+		t.Errorf("snapshot.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestLabeledCounter(t *testing.T) {
+	c := NewLabeledCounter("protocol")
+	c.With("eth/66").Inc(1)
+	c.With("eth/66").Inc(1)
+	c.With("snap/1").Inc(1)
+
+	counts := make(map[string]int64)
+	c.Each(func(values []string, metric interface{}) {
+		counts[values[0]] = metric.(Counter).Count()
+	})
+	if want := map[string]int64{"eth/66": 2, "snap/1": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts: %v != %v\n", want, counts)
+	}
+}
+
+func TestGetOrRegisterBucketHistogram(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredBucketHistogram("foo", r, nil).Observe(1)
+	if h := GetOrRegisterBucketHistogram("foo", r, nil); h.Count() != 1 {
+		t.Fatalf("h.Count(): 1 != %v\n", h.Count())
+	}
+}
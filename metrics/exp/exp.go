@@ -45,6 +45,7 @@ func Exp(r metrics.Registry) {
 	// haven't found an elegant way, so just use a different endpoint
 	http.Handle("/debug/metrics", h)
 	http.Handle("/debug/metrics/prometheus", prometheus.Handler(r))
+	http.Handle("/metrics", prometheus.Handler(r))
 }
 
 // ExpHandler will return an expvar powered metrics handler.
@@ -53,13 +54,16 @@ func ExpHandler(r metrics.Registry) http.Handler {
 	return http.HandlerFunc(e.expHandler)
 }
 
-// Setup starts a dedicated metrics server at the given address.
-// This function enables metrics reporting separate from pprof.
+// Setup starts a dedicated metrics server at the given address, exposing the
+// metrics in Prometheus format on /metrics. The legacy expvar dump is still
+// served on /debug/metrics. This function enables metrics reporting separate
+// from pprof.
 func Setup(address string) {
 	m := http.NewServeMux()
+	m.Handle("/metrics", prometheus.Handler(metrics.DefaultRegistry))
 	m.Handle("/debug/metrics", ExpHandler(metrics.DefaultRegistry))
 	m.Handle("/debug/metrics/prometheus", prometheus.Handler(metrics.DefaultRegistry))
-	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/debug/metrics", address))
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/metrics", address))
 	go func() {
 		if err := http.ListenAndServe(address, m); err != nil {
 			log.Error("Failure in running metrics server", "err", err)
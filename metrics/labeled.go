@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+// Labeled metrics are families of metrics of the same type, distinguished by
+// the values of a fixed set of labels (e.g. the RPC method or p2p protocol).
+type Labeled interface {
+	// Each calls the given function for every member of the family, passing the
+	// values of its labels.
+	Each(func(values []string, metric interface{}))
+
+	// Labels returns the names of the labels.
+	Labels() []string
+}
+
+// labeledSet is the set of members of a labeled metric family.
+type labeledSet struct {
+	labels  []string
+	members map[string]*labeledMember // Members by their joined label values
+	mutex   sync.Mutex
+}
+
+// labeledMember is a member of a labeled metric family.
+type labeledMember struct {
+	values []string
+	metric interface{}
+}
+
+func newLabeledSet(labels []string) *labeledSet {
+	return &labeledSet{
+		labels:  labels,
+		members: make(map[string]*labeledMember),
+	}
+}
+
+// get returns the member with the given label values, creating it with the
+// given constructor if it doesn't exist yet.
+func (s *labeledSet) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(s.labels) {
+		panic("label value count mismatch")
+	}
+	key := strings.Join(values, "\x00")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if member, ok := s.members[key]; ok {
+		return member.metric
+	}
+	member := &labeledMember{values: append([]string{}, values...), metric: create()}
+	s.members[key] = member
+	return member.metric
+}
+
+// Each calls the given function for every member of the family.
+func (s *labeledSet) Each(f func(values []string, metric interface{})) {
+	s.mutex.Lock()
+	members := make([]*labeledMember, 0, len(s.members))
+	for _, member := range s.members {
+		members = append(members, member)
+	}
+	s.mutex.Unlock()
+
+	for _, member := range members {
+		f(member.values, member.metric)
+	}
+}
+
+// Labels returns the names of the labels.
+func (s *labeledSet) Labels() []string { return s.labels }
+
+// LabeledCounter is a family of Counters distinguished by label values.
+type LabeledCounter interface {
+	Labeled
+	With(values ...string) Counter
+}
+
+// NewLabeledCounter constructs a new StandardLabeledCounter with the given
+// label names.
+func NewLabeledCounter(labels ...string) LabeledCounter {
+	if !Enabled {
+		return NilLabeledCounter{}
+	}
+	return &StandardLabeledCounter{newLabeledSet(labels)}
+}
+
+// NewRegisteredLabeledCounter constructs and registers a new
+// StandardLabeledCounter.
+func NewRegisteredLabeledCounter(name string, r Registry, labels ...string) LabeledCounter {
+	c := NewLabeledCounter(labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilLabeledCounter is a no-op LabeledCounter.
+type NilLabeledCounter struct{}
+
+// Each is a no-op.
+func (NilLabeledCounter) Each(func([]string, interface{})) {}
+
+// Labels is a no-op.
+func (NilLabeledCounter) Labels() []string { return nil }
+
+// With returns a no-op Counter.
+func (NilLabeledCounter) With(...string) Counter { return NilCounter{} }
+
+// StandardLabeledCounter is the standard implementation of a LabeledCounter.
+type StandardLabeledCounter struct {
+	*labeledSet
+}
+
+// With returns the Counter with the given label values, creating it if needed.
+func (c *StandardLabeledCounter) With(values ...string) Counter {
+	return c.get(values, func() interface{} { return NewCounter() }).(Counter)
+}
+
+// LabeledBucketHistogram is a family of BucketHistograms distinguished by label
+// values, all sharing the same buckets.
+type LabeledBucketHistogram interface {
+	Labeled
+	With(values ...string) BucketHistogram
+}
+
+// NewLabeledBucketHistogram constructs a new StandardLabeledBucketHistogram
+// with the given bucket upper bounds (DefaultBuckets if nil) and label names.
+func NewLabeledBucketHistogram(buckets []float64, labels ...string) LabeledBucketHistogram {
+	if !Enabled {
+		return NilLabeledBucketHistogram{}
+	}
+	return &StandardLabeledBucketHistogram{newLabeledSet(labels), buckets}
+}
+
+// NewRegisteredLabeledBucketHistogram constructs and registers a new
+// StandardLabeledBucketHistogram.
+func NewRegisteredLabeledBucketHistogram(name string, r Registry, buckets []float64, labels ...string) LabeledBucketHistogram {
+	c := NewLabeledBucketHistogram(buckets, labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilLabeledBucketHistogram is a no-op LabeledBucketHistogram.
+type NilLabeledBucketHistogram struct{}
+
+// Each is a no-op.
+func (NilLabeledBucketHistogram) Each(func([]string, interface{})) {}
+
+// Labels is a no-op.
+func (NilLabeledBucketHistogram) Labels() []string { return nil }
+
+// With returns a no-op BucketHistogram.
+func (NilLabeledBucketHistogram) With(...string) BucketHistogram { return NilBucketHistogram{} }
+
+// StandardLabeledBucketHistogram is the standard implementation of a
+// LabeledBucketHistogram.
+type StandardLabeledBucketHistogram struct {
+	*labeledSet
+	buckets []float64
+}
+
+// With returns the BucketHistogram with the given label values, creating it if
+// needed.
+func (h *StandardLabeledBucketHistogram) With(values ...string) BucketHistogram {
+	return h.get(values, func() interface{} { return NewBucketHistogram(h.buckets) }).(BucketHistogram)
+}
@@ -19,6 +19,7 @@ package prometheus
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -29,8 +30,10 @@ var (
 	typeGaugeTpl           = "# TYPE %s gauge\n"
 	typeCounterTpl         = "# TYPE %s counter\n"
 	typeSummaryTpl         = "# TYPE %s summary\n"
+	typeHistogramTpl       = "# TYPE %s histogram\n"
 	keyValueTpl            = "%s %v\n\n"
 	keyQuantileTagValueTpl = "%s {quantile=\"%s\"} %v\n"
+	keyLabelsValueTpl      = "%s{%s} %v\n"
 )
 
 // collector is a collection of byte buffers that aggregate Prometheus reports
@@ -98,6 +101,74 @@ func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
 	c.buff.WriteRune('\n')
 }
 
+func (c *collector) addBucketHistogram(name string, m metrics.BucketHistogram) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeHistogramTpl, name))
+	c.writeBuckets(name, "", m)
+	c.buff.WriteRune('\n')
+}
+
+func (c *collector) addLabeledCounter(name string, m metrics.LabeledCounter) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
+	eachLabeled(m, func(labels string, metric interface{}) {
+		c.buff.WriteString(fmt.Sprintf(keyLabelsValueTpl, name, labels, metric.(metrics.Counter).Count()))
+	})
+	c.buff.WriteRune('\n')
+}
+
+func (c *collector) addLabeledBucketHistogram(name string, m metrics.LabeledBucketHistogram) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeHistogramTpl, name))
+	eachLabeled(m, func(labels string, metric interface{}) {
+		c.writeBuckets(name, labels+",", metric.(metrics.BucketHistogram).Snapshot())
+	})
+	c.buff.WriteRune('\n')
+}
+
+// writeBuckets writes the buckets, sum and count series of a histogram, the
+// given labels (with a trailing comma, if any) being attached to all of them.
+func (c *collector) writeBuckets(name, labels string, m metrics.BucketHistogram) {
+	counts := m.Counts()
+	for i, bucket := range m.Buckets() {
+		le := strconv.FormatFloat(bucket, 'g', -1, 64)
+		c.buff.WriteString(fmt.Sprintf(keyLabelsValueTpl, name+"_bucket", labels+`le="`+le+`"`, counts[i]))
+	}
+	c.buff.WriteString(fmt.Sprintf(keyLabelsValueTpl, name+"_bucket", labels+`le="+Inf"`, m.Count()))
+
+	labels = strings.TrimSuffix(labels, ",")
+	if labels == "" {
+		c.buff.WriteString(fmt.Sprintf("%s_sum %v\n", name, m.Sum()))
+		c.buff.WriteString(fmt.Sprintf("%s_count %v\n", name, m.Count()))
+		return
+	}
+	c.buff.WriteString(fmt.Sprintf(keyLabelsValueTpl, name+"_sum", labels, m.Sum()))
+	c.buff.WriteString(fmt.Sprintf(keyLabelsValueTpl, name+"_count", labels, m.Count()))
+}
+
+// eachLabeled calls the given function for every member of a labeled metric
+// family, sorted by their formatted labels to avoid random listings.
+func eachLabeled(m metrics.Labeled, f func(labels string, metric interface{})) {
+	var (
+		names   = m.Labels()
+		labels  []string
+		members = make(map[string]interface{})
+	)
+	m.Each(func(values []string, metric interface{}) {
+		pairs := make([]string, len(values))
+		for i, value := range values {
+			pairs[i] = fmt.Sprintf("%s=%q", mutateKey(names[i]), value)
+		}
+		joined := strings.Join(pairs, ",")
+		labels = append(labels, joined)
+		members[joined] = metric
+	})
+	sort.Strings(labels)
+	for _, joined := range labels {
+		f(joined, members[joined])
+	}
+}
+
 func (c *collector) writeGaugeCounter(name string, value interface{}) {
 	name = mutateKey(name)
 	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
@@ -115,6 +186,12 @@ func (c *collector) writeSummaryPercentile(name, p string, value interface{}) {
 	c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, p, value))
 }
 
+// mutateKey converts a metric name into a valid Prometheus metric name.
 func mutateKey(key string) string {
-	return strings.Replace(key, "/", "_", -1)
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, key)
 }
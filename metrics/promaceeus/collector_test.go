@@ -108,3 +108,47 @@ test_resetting_timer {quantile="0.99"} 120000000
 		t.Fatal("unexpected collector output")
 	}
 }
+
+func TestCollectorBuckets(t *testing.T) {
+	c := newCollector()
+
+	histogram := metrics.NewBucketHistogram([]float64{1, 0.1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	c.addBucketHistogram("test/bucket_histogram", histogram.Snapshot())
+
+	counter := metrics.NewLabeledCounter("protocol")
+	counter.With("snap/1").Inc(2)
+	counter.With("eth/66").Inc(3)
+	c.addLabeledCounter("test/labeled.counter", counter)
+
+	labeled := metrics.NewLabeledBucketHistogram([]float64{0.1}, "method")
+	labeled.With("eth_call").Observe(0.25)
+	c.addLabeledBucketHistogram("test/labeled_histogram", labeled)
+
+	const expectedOutput = `# TYPE test_bucket_histogram histogram
+test_bucket_histogram_bucket{le="0.1"} 1
+test_bucket_histogram_bucket{le="1"} 2
+test_bucket_histogram_bucket{le="+Inf"} 3
+test_bucket_histogram_sum 5.55
+test_bucket_histogram_count 3
+
+# TYPE test_labeled_counter counter
+test_labeled_counter{protocol="eth/66"} 3
+test_labeled_counter{protocol="snap/1"} 2
+
+# TYPE test_labeled_histogram histogram
+test_labeled_histogram_bucket{method="eth_call",le="0.1"} 0
+test_labeled_histogram_bucket{method="eth_call",le="+Inf"} 1
+test_labeled_histogram_sum{method="eth_call"} 0.25
+test_labeled_histogram_count{method="eth_call"} 1
+
+`
+	exp := c.buff.String()
+	if exp != expectedOutput {
+		t.Log("Expected Output:\n", expectedOutput)
+		t.Log("Actual Output:\n", exp)
+		t.Fatal("unexpected collector output")
+	}
+}
@@ -57,11 +57,17 @@ func Handler(reg metrics.Registry) http.Handler {
 				c.addTimer(name, m.Snapshot())
 			case metrics.ResettingTimer:
 				c.addResettingTimer(name, m.Snapshot())
+			case metrics.BucketHistogram:
+				c.addBucketHistogram(name, m.Snapshot())
+			case metrics.LabeledCounter:
+				c.addLabeledCounter(name, m)
+			case metrics.LabeledBucketHistogram:
+				c.addLabeledBucketHistogram(name, m)
 			default:
 				log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
 			}
 		}
-		w.Header().Add("Content-Type", "text/plain")
+		w.Header().Add("Content-Type", "text/plain; version=0.0.4")
 		w.Header().Add("Content-Length", fmt.Sprint(c.buff.Len()))
 		w.Write(c.buff.Bytes())
 	})
@@ -167,6 +167,10 @@ func (r *StandardRegistry) GetAll() map[string]map[string]interface{} {
 			values["5m.rate"] = t.Rate5()
 			values["15m.rate"] = t.Rate15()
 			values["mean.rate"] = t.RateMean()
+		case BucketHistogram:
+			h := metric.Snapshot()
+			values["count"] = h.Count()
+			values["sum"] = h.Sum()
 		}
 		data[name] = values
 	})
@@ -196,7 +200,7 @@ func (r *StandardRegistry) register(name string, i interface{}) error {
 		return DuplicateMetric(name)
 	}
 	switch i.(type) {
-	case Counter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer, ResettingTimer:
+	case Counter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer, ResettingTimer, BucketHistogram, Labeled:
 		r.metrics[name] = i
 	}
 	return nil
@@ -50,6 +50,16 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ethash).
+	Ordering  string         `toml:",omitempty"` // Transaction ordering strategy (price, fifo or fair)
+
+	BuildDeadline time.Duration `toml:",omitempty"` // Time after a new head to stop improving the mined block (0 = no deadline)
+}
+
+// Payload is a candidate block built by the miner.
+type Payload struct {
+	Block   *types.Block
+	Fees    *big.Int  // Fees paid to the coinbase by the block transactions, in wei
+	Created time.Time // Time the block was built
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -174,11 +184,26 @@ func (miner *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetOrdering sets the transaction ordering strategy used to fill new blocks.
+func (miner *Miner) SetOrdering(ordering string) error {
+	if err := ValidateOrdering(ordering); err != nil {
+		return err
+	}
+	miner.worker.setOrdering(ordering)
+	return nil
+}
+
 // SetRecommitInterval sets the interval for sealing work resubmitting.
 func (miner *Miner) SetRecommitInterval(interval time.Duration) {
 	miner.worker.setRecommitInterval(interval)
 }
 
+// BestPayload returns the most profitable block built on the current head so
+// far, or nil if mining isn't running or no block was built yet.
+func (miner *Miner) BestPayload() *Payload {
+	return miner.worker.bestPayload()
+}
+
 // Pending returns the currently pending block and associated state.
 func (miner *Miner) Pending() (*types.Block, *state.StateDB) {
 	return miner.worker.pending()
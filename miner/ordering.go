@@ -0,0 +1,186 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+)
+
+// Transaction ordering strategies of the miner.
+const (
+	OrderingPrice = "price" // Highest gas price first (default)
+	OrderingFIFO  = "fifo"  // Earliest seen first
+	OrderingFair  = "fair"  // One transaction per account in turns
+)
+
+// Orderings is the list of supported transaction ordering strategies.
+var Orderings = []string{OrderingPrice, OrderingFIFO, OrderingFair}
+
+// ValidateOrdering checks whether the given transaction ordering strategy is
+// supported by the miner.
+func ValidateOrdering(ordering string) error {
+	for _, name := range Orderings {
+		if name == ordering {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown transaction ordering %q (supported: %v)", ordering, Orderings)
+}
+
+// orderedTransactions is a set of pending transactions which can be iterated in
+// the order they should be included into a block, while honoring the nonce
+// order of the transactions of each account.
+type orderedTransactions interface {
+	// Peek returns the next transaction to include, or nil if none is left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same account.
+	Shift()
+
+	// Pop removes the current transaction along with all the remaining ones of
+	// the same account.
+	Pop()
+}
+
+// newOrderedTransactions creates a transaction set iterating the given nonce
+// sorted transactions of each account with the requested ordering strategy.
+//
+// Note, the input map is reowned, so the caller should not interact any more
+// with it after providing it to the constructor.
+func newOrderedTransactions(ordering string, signer types.Signer, txs map[common.Address]types.Transactions) orderedTransactions {
+	switch ordering {
+	case OrderingFIFO:
+		return newTransactionsByTimeAndNonce(txs)
+	case OrderingFair:
+		return newTransactionsRoundRobin(txs)
+	default:
+		return types.NewTransactionsByPriceAndNonce(signer, txs)
+	}
+}
+
+// txsByTime implements the heap interface over the nonce sorted transactions
+// of multiple accounts, ordered by the time their first transaction was seen.
+type txsByTime []types.Transactions
+
+func (s txsByTime) Len() int { return len(s) }
+func (s txsByTime) Less(i, j int) bool {
+	if ti, tj := s[i][0].Time(), s[j][0].Time(); !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	// Transactions seen at the same time fall back to the price ordering
+	return s[i][0].GasPriceCmp(s[j][0]) > 0
+}
+func (s txsByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByTime) Push(x interface{}) {
+	*s = append(*s, x.(types.Transactions))
+}
+
+func (s *txsByTime) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByTimeAndNonce iterates transactions in the order they were first
+// seen locally, as long as the nonce order of the accounts permits.
+type transactionsByTimeAndNonce struct {
+	heads txsByTime
+}
+
+// newTransactionsByTimeAndNonce creates a transaction set ordered by first seen
+// time and nonce.
+func newTransactionsByTimeAndNonce(txs map[common.Address]types.Transactions) *transactionsByTimeAndNonce {
+	heads := make(txsByTime, 0, len(txs))
+	for _, accTxs := range txs {
+		if len(accTxs) > 0 {
+			heads = append(heads, accTxs)
+		}
+	}
+	heap.Init(&heads)
+	return &transactionsByTimeAndNonce{heads: heads}
+}
+
+// Peek returns the earliest seen executable transaction.
+func (t *transactionsByTimeAndNonce) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0][0]
+}
+
+// Shift replaces the current transaction with the next one of the same account.
+func (t *transactionsByTimeAndNonce) Shift() {
+	if len(t.heads[0]) > 1 {
+		t.heads[0] = t.heads[0][1:]
+		heap.Fix(&t.heads, 0)
+		return
+	}
+	heap.Pop(&t.heads)
+}
+
+// Pop removes the current transaction along with its account.
+func (t *transactionsByTimeAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
+
+// transactionsRoundRobin iterates transactions one account at a time, moving to
+// the next account after each transaction, so no account can crowd out others.
+type transactionsRoundRobin struct {
+	queue []types.Transactions
+}
+
+// newTransactionsRoundRobin creates a transaction set taking turns between the
+// accounts, in the order their first transaction was seen.
+func newTransactionsRoundRobin(txs map[common.Address]types.Transactions) *transactionsRoundRobin {
+	queue := make(txsByTime, 0, len(txs))
+	for _, accTxs := range txs {
+		if len(accTxs) > 0 {
+			queue = append(queue, accTxs)
+		}
+	}
+	sort.Sort(queue)
+	return &transactionsRoundRobin{queue: queue}
+}
+
+// Peek returns the next transaction of the account whose turn it is.
+func (t *transactionsRoundRobin) Peek() *types.Transaction {
+	if len(t.queue) == 0 {
+		return nil
+	}
+	return t.queue[0][0]
+}
+
+// Shift moves the current account to the end of the queue with its next transaction.
+func (t *transactionsRoundRobin) Shift() {
+	if next := t.queue[0][1:]; len(next) > 0 {
+		t.queue = append(t.queue, next)
+	}
+	t.queue = t.queue[1:]
+}
+
+// Pop removes the current transaction along with its account.
+func (t *transactionsRoundRobin) Pop() {
+	t.queue = t.queue[1:]
+}
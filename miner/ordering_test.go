@@ -0,0 +1,118 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+)
+
+// Tests that the transaction ordering strategies iterate transactions in their
+// respective order, while keeping the nonce order of each account.
+func TestTransactionOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Create the transactions one after the other with the first account paying
+	// the lowest price and the second one the highest.
+	order := []struct {
+		key   int
+		nonce uint64
+		price int64
+	}{
+		{0, 0, 1}, {0, 1, 1}, {1, 0, 3}, {2, 0, 2}, {2, 1, 2},
+	}
+	var created []*types.Transaction
+	for _, o := range order {
+		tx, _ := types.SignTx(types.NewTransaction(o.nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(o.price), nil), signer, keys[o.key])
+		created = append(created, tx)
+		time.Sleep(time.Millisecond)
+	}
+	tests := []struct {
+		ordering string
+		want     []int // Indexes into the created transactions
+	}{
+		{OrderingPrice, []int{2, 3, 4, 0, 1}},
+		{OrderingFIFO, []int{0, 1, 2, 3, 4}},
+		{OrderingFair, []int{0, 2, 3, 1, 4}},
+	}
+	for _, tt := range tests {
+		txs := make(map[common.Address]types.Transactions)
+		for _, tx := range created {
+			from, _ := types.Sender(signer, tx)
+			txs[from] = append(txs[from], tx)
+		}
+		set := newOrderedTransactions(tt.ordering, signer, txs)
+		for i, idx := range tt.want {
+			tx := set.Peek()
+			if tx == nil {
+				t.Fatalf("%s: transaction %d missing", tt.ordering, i)
+			}
+			if tx != created[idx] {
+				t.Errorf("%s: transaction %d mismatch: have %x, want %x", tt.ordering, i, tx.Hash(), created[idx].Hash())
+			}
+			set.Shift()
+		}
+		if tx := set.Peek(); tx != nil {
+			t.Errorf("%s: unexpected extra transaction %x", tt.ordering, tx.Hash())
+		}
+	}
+}
+
+// Tests that popping a transaction drops all remaining ones of its account.
+func TestTransactionOrderingPop(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	var txs types.Transactions
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), signer, key)
+		txs = append(txs, tx)
+	}
+	for _, ordering := range Orderings {
+		set := newOrderedTransactions(ordering, signer, map[common.Address]types.Transactions{from: txs})
+		set.Shift()
+		if tx := set.Peek(); tx != txs[1] {
+			t.Fatalf("%s: shifted transaction mismatch", ordering)
+		}
+		set.Pop()
+		if tx := set.Peek(); tx != nil {
+			t.Errorf("%s: transaction %x left after pop", ordering, tx.Hash())
+		}
+	}
+}
+
+func TestValidateOrdering(t *testing.T) {
+	for _, ordering := range Orderings {
+		if err := ValidateOrdering(ordering); err != nil {
+			t.Errorf("ordering %s rejected: %v", ordering, err)
+		}
+	}
+	if err := ValidateOrdering("random"); err == nil {
+		t.Error("unknown ordering accepted")
+	}
+}
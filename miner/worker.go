@@ -86,6 +86,7 @@ type environment struct {
 	family    mapset.Set     // family set (used for checking uncle invalidity)
 	uncles    mapset.Set     // uncle set
 	tcount    int            // tx count in cycle
+	blobs     int            // sidecar blobs committed to by the packed transactions
 	gasPool   *core.GasPool  // available gas used to pack transactions
 
 	header   *types.Header
@@ -155,13 +156,17 @@ type worker struct {
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the coinbase, extra and ordering fields
 	coinbase common.Address
 	extra    []byte
+	ordering string // Transaction ordering strategy
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
 
+	bestMu sync.RWMutex // The lock used to protect the best payload
+	best   *Payload     // Most profitable block built on the current head
+
 	snapshotMu    sync.RWMutex // The lock used to protect the block snapshot and state snapshot
 	snapshotBlock *types.Block
 	snapshotState *state.StateDB
@@ -196,6 +201,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		mux:                mux,
 		chain:              eth.BlockChain(),
 		isLocalBlock:       isLocalBlock,
+		ordering:           config.Ordering,
 		localUncles:        make(map[common.Hash]*types.Block),
 		remoteUncles:       make(map[common.Hash]*types.Block),
 		unconfirmed:        newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
@@ -250,6 +256,13 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setOrdering sets the transaction ordering strategy used to fill new blocks.
+func (w *worker) setOrdering(ordering string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ordering = ordering
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -338,6 +351,7 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		interrupt   *int32
 		minRecommit = recommit // minimal resubmit interval specified by user.
 		timestamp   int64      // timestamp for each round of mining.
+		started     time.Time  // time the building of blocks on the current head started.
 	)
 
 	timer := time.NewTimer(0)
@@ -373,18 +387,24 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		select {
 		case <-w.startCh:
 			clearPending(w.chain.CurrentBlock().NumberU64())
-			timestamp = time.Now().Unix()
+			timestamp, started = time.Now().Unix(), time.Now()
 			commit(false, commitInterruptNewHead)
 
 		case head := <-w.chainHeadCh:
 			clearPending(head.Block.NumberU64())
-			timestamp = time.Now().Unix()
+			timestamp, started = time.Now().Unix(), time.Now()
 			commit(false, commitInterruptNewHead)
 
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
 			// higher priced transactions. Disable this overhead for pending blocks.
 			if w.isRunning() && (w.chainConfig.Clique == nil || w.chainConfig.Clique.Period > 0) {
+				// Stop improving the block once the building deadline passed, the
+				// best payload keeps being sealed until the next head arrives.
+				if w.config.BuildDeadline > 0 && time.Since(started) > w.config.BuildDeadline {
+					log.Debug("Miner building deadline reached", "elapsed", common.PrettyDuration(time.Since(started)))
+					continue
+				}
 				// Short circuit if no new transaction arrives.
 				if atomic.LoadInt32(&w.newTxs) == 0 {
 					timer.Reset(recommit)
@@ -491,7 +511,7 @@ func (w *worker) mainLoop() {
 					continue
 				}
 				w.mu.RLock()
-				coinbase := w.coinbase
+				coinbase, ordering := w.coinbase, w.ordering
 				w.mu.RUnlock()
 
 				txs := make(map[common.Address]types.Transactions)
@@ -499,7 +519,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+				txset := newOrderedTransactions(ordering, w.current.signer, txs)
 				tcount := w.current.tcount
 				w.commitTransactions(txset, coinbase, nil)
 				// Only update the snapshot if any new transactons were added
@@ -747,7 +767,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs orderedTransactions, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -803,6 +823,14 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			txs.Pop()
 			continue
 		}
+		// Skip sidecar transactions over the blob limit of the block, there may be
+		// room left for others.
+		if blobs := len(tx.BlobHashes()); blobs > 0 && w.current.blobs+blobs > params.MaxBlockSidecarBlobs {
+			log.Trace("Sidecar blob limit reached for current block", "sender", from, "blobs", blobs)
+
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		w.current.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount)
 
@@ -827,6 +855,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			w.current.tcount++
+			w.current.blobs += len(tx.BlobHashes())
 			txs.Shift()
 
 		case errors.Is(err, core.ErrTxTypeNotSupported):
@@ -973,13 +1002,13 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+		txs := newOrderedTransactions(w.ordering, w.current.signer, localTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+		txs := newOrderedTransactions(w.ordering, w.current.signer, remoteTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -1001,6 +1030,14 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 		if interval != nil {
 			interval()
 		}
+		// Only seal the block if it's at least as profitable as the best one built
+		// on the same parent so far.
+		fees := blockFees(block, receipts)
+		if !w.updateBest(block, fees) {
+			// Keep the pending snapshot of the better work too
+			log.Debug("Discarding less profitable mining work", "number", block.Number(), "txs", w.current.tcount, "fees", fees)
+			return nil
+		}
 		select {
 		case w.taskCh <- &task{receipts: receipts, state: s, block: block, createdAt: time.Now()}:
 			w.unconfirmed.Shift(block.NumberU64() - 1)
@@ -1019,6 +1056,31 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 	return nil
 }
 
+// updateBest records the block as the best payload, unless a more profitable one
+// was already built on the same parent. It returns whether the block was recorded.
+func (w *worker) updateBest(block *types.Block, fees *big.Int) bool {
+	w.bestMu.Lock()
+	defer w.bestMu.Unlock()
+
+	if best := w.best; best != nil && best.Block.ParentHash() == block.ParentHash() && best.Fees.Cmp(fees) > 0 {
+		return false
+	}
+	w.best = &Payload{Block: block, Fees: fees, Created: time.Now()}
+	return true
+}
+
+// bestPayload returns the most profitable block built on the current head, or
+// nil if there's none.
+func (w *worker) bestPayload() *Payload {
+	w.bestMu.RLock()
+	defer w.bestMu.RUnlock()
+
+	if w.best == nil || w.best.Block.ParentHash() != w.chain.CurrentBlock().Hash() {
+		return nil
+	}
+	return w.best
+}
+
 // copyReceipts makes a deep copy of the given receipts.
 func copyReceipts(receipts []*types.Receipt) []*types.Receipt {
 	result := make([]*types.Receipt, len(receipts))
@@ -1037,11 +1099,17 @@ func (w *worker) postSideBlock(event core.ChainSideEvent) {
 	}
 }
 
-// totalFees computes total consumed fees in ETH. Block transactions and receipts have to have the same order.
-func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
+// blockFees computes total consumed fees in wei. Block transactions and receipts have to have the same order.
+func blockFees(block *types.Block, receipts []*types.Receipt) *big.Int {
 	feesWei := new(big.Int)
 	for i, tx := range block.Transactions() {
 		feesWei.Add(feesWei, new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), tx.GasPrice()))
 	}
+	return feesWei
+}
+
+// totalFees computes total consumed fees in ETH. Block transactions and receipts have to have the same order.
+func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
+	feesWei := blockFees(block, receipts)
 	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
 }
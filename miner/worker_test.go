@@ -76,7 +76,9 @@ func init() {
 	testTxPoolConfig = core.DefaultTxPoolConfig
 	testTxPoolConfig.Journal = ""
 	ethashChainConfig = params.TestChainConfig
-	cliqueChainConfig = params.TestChainConfig
+	cliqueChainConfig = new(params.ChainConfig)
+	*cliqueChainConfig = *params.TestChainConfig
+	cliqueChainConfig.Ethash = nil
 	cliqueChainConfig.Clique = &params.CliqueConfig{
 		Period: 10,
 		Epoch:  30000,
@@ -516,3 +518,61 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+func TestUpdateBestPayload(t *testing.T) {
+	w := new(worker)
+
+	var (
+		parent = common.HexToHash("0x01")
+		first  = types.NewBlockWithHeader(&types.Header{ParentHash: parent, Number: big.NewInt(1)})
+		second = types.NewBlockWithHeader(&types.Header{ParentHash: parent, Number: big.NewInt(1), Extra: []byte{1}})
+		next   = types.NewBlockWithHeader(&types.Header{ParentHash: first.Hash(), Number: big.NewInt(2)})
+	)
+	tests := []struct {
+		block  *types.Block
+		fees   int64
+		update bool
+	}{
+		{first, 10, true},
+		{second, 5, false}, // less profitable on the same parent
+		{second, 10, true}, // as profitable on the same parent
+		{next, 1, true},    // less profitable on a new parent
+	}
+	for i, tt := range tests {
+		if updated := w.updateBest(tt.block, big.NewInt(tt.fees)); updated != tt.update {
+			t.Errorf("test %d: update mismatch: have %v, want %v", i, updated, tt.update)
+		}
+		if tt.update && w.best.Block != tt.block {
+			t.Errorf("test %d: best payload mismatch", i)
+		}
+	}
+}
+
+// Tests that discarding less profitable work keeps the pending block of the
+// better one.
+func TestDiscardedWorkSnapshot(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, _ := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	w.commitNewWork(nil, false, time.Now().Unix())
+	pending := w.pendingBlock()
+	if pending == nil || len(pending.Transactions()) == 0 {
+		t.Fatalf("pending block mismatch: have %v, want transactions", pending)
+	}
+	// Build an empty block while a more profitable one is known
+	w.updateBest(pending, big.NewInt(1000))
+	w.current.txs, w.current.receipts, w.current.tcount = nil, nil, 0
+
+	atomic.StoreInt32(&w.running, 1)
+	err := w.commit(nil, nil, true, time.Now())
+	atomic.StoreInt32(&w.running, 0)
+	if err != nil {
+		t.Fatalf("failed to commit work: %v", err)
+	}
+	if have := w.pendingBlock(); have.Hash() != pending.Hash() {
+		t.Fatalf("pending block replaced by discarded work: have %d txs, want %d", len(have.Transactions()), len(pending.Transactions()))
+	}
+}
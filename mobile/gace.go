@@ -108,7 +108,8 @@ func (conf *NodeConfig) String() string {
 
 // Node represents a Geth Acent node instance.
 type Node struct {
-	node *node.Node
+	node    *node.Node
+	watcher *addressWatcher // Watcher of addresses, nil if the light client is disabled
 }
 
 // NewNode creates and configures a new Geth node.
@@ -181,6 +182,7 @@ func NewNode(datadir string, config *NodeConfig) (stack *Node, _ error) {
 		}
 	}
 	// Register the Acent protocol if requested
+	var watcher *addressWatcher
 	if config.AcentEnabled {
 		ethConf := ethconfig.Defaults
 		ethConf.Genesis = genesis
@@ -193,12 +195,15 @@ func NewNode(datadir string, config *NodeConfig) (stack *Node, _ error) {
 		}
 		// If netstats reporting is requested, do it
 		if config.AcentNetStats != "" {
-			if err := ethstats.New(rawStack, lesBackend.ApiBackend, lesBackend.Engine(), config.AcentNetStats); err != nil {
+			if err := ethstats.New(rawStack, lesBackend, lesBackend.ApiBackend, lesBackend.Engine(), ethstats.Config{URL: config.AcentNetStats}); err != nil {
 				return nil, fmt.Errorf("netstats init: %v", err)
 			}
 		}
+		// Monitor the chain for the transactions of watched addresses
+		watcher = newAddressWatcher(lesBackend.BlockChain())
+		rawStack.RegisterLifecycle(watcher)
 	}
-	return &Node{rawStack}, nil
+	return &Node{rawStack, watcher}, nil
 }
 
 // Close terminates a running node along with all it's services, tearing internal state
@@ -239,3 +244,42 @@ func (n *Node) GetNodeInfo() *NodeInfo {
 func (n *Node) GetPeersInfo() *PeerInfos {
 	return &PeerInfos{n.node.Server().PeersInfo()}
 }
+
+// WatchAddress adds an address to the ones whose transactions are reported to the
+// transaction handler. The blocks are checked by the light client itself, with
+// the data retrieved from the light servers verified against the chain.
+func (n *Node) WatchAddress(address *Address) error {
+	if n.watcher == nil {
+		return errWatchDisabled
+	}
+	n.watcher.watch(address.address)
+	return nil
+}
+
+// UnwatchAddress removes an address from the ones whose transactions are reported.
+func (n *Node) UnwatchAddress(address *Address) error {
+	if n.watcher == nil {
+		return errWatchDisabled
+	}
+	n.watcher.unwatch(address.address)
+	return nil
+}
+
+// GetWatchedAddresses returns the addresses whose transactions are reported.
+func (n *Node) GetWatchedAddresses() *Addresses {
+	if n.watcher == nil {
+		return NewAddressesEmpty()
+	}
+	return &Addresses{n.watcher.addresses()}
+}
+
+// SetTransactionHandler sets the callback to invoke with the transactions sent
+// from or to the watched addresses once they are included in the chain. Setting
+// nil stops the notifications.
+func (n *Node) SetTransactionHandler(handler TransactionHandler) error {
+	if n.watcher == nil {
+		return errWatchDisabled
+	}
+	n.watcher.setHandler(handler)
+	return nil
+}
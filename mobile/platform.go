@@ -0,0 +1,232 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the integration of platform managed, hardware backed keystores (Android
+// Keystore, iOS Secure Enclave) with the account management of go-acent.
+
+package geth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/acent/go-acent"
+	"github.com/acent/go-acent/accounts"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/event"
+)
+
+// PlatformKeyStore is the callback interface implemented by the mobile app to
+// expose the keys held by the platform's hardware backed keystore. Private keys
+// never leave the platform, Go only ever asks for signatures of hashes.
+type PlatformKeyStore interface {
+	// GetAddresses returns the addresses of all the keys held by the platform.
+	GetAddresses() *Addresses
+
+	// SignHash signs the given 32 byte hash with the key of the given address. The
+	// signature must be in the [R || S || V] format where V is 0 or 1.
+	SignHash(address *Address, hash []byte) (signature []byte, _ error)
+}
+
+// errPlatformSignature is returned if the platform keystore produced a signature
+// which doesn't match the requested account.
+var errPlatformSignature = errors.New("invalid signature from platform keystore")
+
+// platformURL is the URL of the single wallet wrapping a platform keystore.
+var platformURL = accounts.URL{Scheme: "platform", Path: "keystore"}
+
+// platformWallet is an accounts.Wallet delegating all signing to the keys held
+// by a platform keystore.
+type platformWallet struct {
+	keystore PlatformKeyStore
+}
+
+// URL implements accounts.Wallet, returning the URL of the platform keystore.
+func (w *platformWallet) URL() accounts.URL {
+	return platformURL
+}
+
+// Status implements accounts.Wallet. Unlocking keys is managed by the platform.
+func (w *platformWallet) Status() (string, error) {
+	return "Platform managed", nil
+}
+
+// Open implements accounts.Wallet, but is a noop for platform keystores.
+func (w *platformWallet) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet, but is a noop for platform keystores.
+func (w *platformWallet) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning the keys currently held by the
+// platform keystore.
+func (w *platformWallet) Accounts() []accounts.Account {
+	addresses := w.keystore.GetAddresses()
+	if addresses == nil { // Null passed from mobile app
+		return nil
+	}
+	accs := make([]accounts.Account, len(addresses.addresses))
+	for i, address := range addresses.addresses {
+		accs[i] = accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: platformURL.Scheme, Path: address.Hex()},
+		}
+	}
+	return accs
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not held by the platform keystore.
+func (w *platformWallet) Contains(account accounts.Account) bool {
+	for _, acc := range w.Accounts() {
+		if acc.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == acc.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is a noop for platform keystores since
+// there is no notion of hierarchical account derivation for them.
+func (w *platformWallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for platform keystores.
+func (w *platformWallet) SelfDerive(bases []accounts.DerivationPath, chain acent.ChainStateReader) {
+}
+
+// signHash asks the platform keystore to sign the given hash and checks that the
+// signature was indeed produced by the requested account.
+func (w *platformWallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	if !w.Contains(account) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	sig, err := w.keystore.SignHash(&Address{account.Address}, common.CopyBytes(hash))
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("%w: length %d", errPlatformSignature, len(sig))
+	}
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPlatformSignature, err)
+	}
+	if crypto.PubkeyToAddress(*pubkey) != account.Address {
+		return nil, errPlatformSignature
+	}
+	return sig, nil
+}
+
+// SignData implements accounts.Wallet, signing the hash of the given data.
+func (w *platformWallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet. Platform keystores do their
+// own user authentication, so the passphrase is ignored.
+func (w *platformWallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, signing the hash of the given text in
+// the personal message format.
+func (w *platformWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet. Platform keystores do their
+// own user authentication, so the passphrase is ignored.
+func (w *platformWallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet, signing the hash of the transaction as
+// defined by the latest signer of the given chain.
+func (w *platformWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	sig, err := w.signHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. Platform keystores do their
+// own user authentication, so the passphrase is ignored.
+func (w *platformWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// platformBackend is an accounts.Backend exposing a platform keystore as a
+// single, always available wallet.
+type platformBackend struct {
+	wallet *platformWallet
+}
+
+// Wallets implements accounts.Backend, returning the platform keystore wallet.
+func (b *platformBackend) Wallets() []accounts.Wallet {
+	return []accounts.Wallet{b.wallet}
+}
+
+// Subscribe implements accounts.Backend. The platform keystore wallet never
+// arrives or departs after registration, so no events are ever sent.
+func (b *platformBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// RegisterPlatformKeyStore registers a platform keystore with the account manager
+// of the node, making its keys available to the APIs of the node for signing.
+func (n *Node) RegisterPlatformKeyStore(keystore PlatformKeyStore) error {
+	if keystore == nil { // Null passed from mobile app
+		return errors.New("no platform keystore")
+	}
+	n.node.AccountManager().AddBackend(&platformBackend{&platformWallet{keystore}})
+	return nil
+}
+
+// NewPlatformTransactOpts creates a transaction signer for contract transactions
+// from the given account, signing them with the platform keystore.
+func NewPlatformTransactOpts(keystore PlatformKeyStore, from *Address, chainID *BigInt) (*TransactOpts, error) {
+	if keystore == nil { // Null passed from mobile app
+		return nil, errors.New("no platform keystore")
+	}
+	if from == nil { // Null passed from mobile app
+		return nil, errors.New("no sender address")
+	}
+	if chainID == nil { // Null passed from mobile app
+		chainID = new(BigInt)
+	}
+	var (
+		wallet  = &platformWallet{keystore}
+		account = accounts.Account{Address: from.address}
+		opts    = &TransactOpts{}
+	)
+	opts.opts.From = from.address
+	opts.opts.Signer = func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if addr != account.Address {
+			return nil, accounts.ErrUnknownAccount
+		}
+		return wallet.SignTx(account, tx, chainID.bigint)
+	}
+	return opts, nil
+}
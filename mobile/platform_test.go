@@ -0,0 +1,108 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package geth
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/acent/go-acent/accounts"
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+)
+
+// testPlatformKeyStore is an in-process stand-in for a platform keystore.
+type testPlatformKeyStore struct {
+	key  *ecdsa.PrivateKey
+	evil *ecdsa.PrivateKey // Key to sign with instead, if set
+}
+
+func (ks *testPlatformKeyStore) GetAddresses() *Addresses {
+	return &Addresses{[]common.Address{crypto.PubkeyToAddress(ks.key.PublicKey)}}
+}
+
+func (ks *testPlatformKeyStore) SignHash(address *Address, hash []byte) ([]byte, error) {
+	if ks.evil != nil {
+		return crypto.Sign(hash, ks.evil)
+	}
+	return crypto.Sign(hash, ks.key)
+}
+
+func TestPlatformWallet(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		ks      = &testPlatformKeyStore{key: key}
+		wallet  = &platformWallet{ks}
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		account = accounts.Account{Address: addr}
+		chainID = big.NewInt(1337)
+	)
+	if accs := wallet.Accounts(); len(accs) != 1 || accs[0].Address != addr {
+		t.Fatalf("wrong accounts: %v", accs)
+	}
+	// Sign a transaction and ensure the sender is recovered correctly
+	tx := types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := wallet.SignTx(account, tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed); err != nil || sender != addr {
+		t.Fatalf("wrong sender: have %x (%v), want %x", sender, err, addr)
+	}
+	// Unknown accounts and signatures by the wrong key are rejected
+	if _, err := wallet.SignText(accounts.Account{Address: common.Address{0xbb}}, []byte("hello")); err != accounts.ErrUnknownAccount {
+		t.Fatalf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+	ks.evil, _ = crypto.GenerateKey()
+	if _, err := wallet.SignText(account, []byte("hello")); err != errPlatformSignature {
+		t.Fatalf("forged signature error mismatch: have %v, want %v", err, errPlatformSignature)
+	}
+}
+
+func TestPlatformTransactOpts(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		ks      = &testPlatformKeyStore{key: key}
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		chainID = big.NewInt(1337)
+	)
+	// Null arguments passed from the mobile app are rejected
+	if _, err := NewPlatformTransactOpts(nil, &Address{addr}, &BigInt{chainID}); err == nil {
+		t.Fatalf("missing keystore accepted")
+	}
+	if _, err := NewPlatformTransactOpts(ks, nil, &BigInt{chainID}); err == nil {
+		t.Fatalf("missing sender accepted")
+	}
+	// Transactions are signed by the sender only
+	opts, err := NewPlatformTransactOpts(ks, &Address{addr}, &BigInt{chainID})
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	tx := types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := opts.opts.Signer(addr, tx)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed); err != nil || sender != addr {
+		t.Fatalf("wrong sender: have %x (%v), want %x", sender, err, addr)
+	}
+	if _, err := opts.opts.Signer(common.Address{0xbb}, tx); err != accounts.ErrUnknownAccount {
+		t.Fatalf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
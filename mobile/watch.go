@@ -0,0 +1,272 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the watcher notifying the mobile app about the transactions of the
+// addresses it is interested in.
+
+package geth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/light"
+	"github.com/acent/go-acent/log"
+)
+
+const (
+	// maxWatchBackfill is the maximum number of blocks checked for watched
+	// transactions when the chain head advances by multiple blocks at once, e.g.
+	// when catching up after being offline. Older blocks are skipped.
+	maxWatchBackfill = 128
+
+	// watchRetrievalTimeout is the time allowed for retrieving the body and the
+	// receipts of a block from the light servers.
+	watchRetrievalTimeout = 30 * time.Second
+)
+
+// errWatchDisabled is returned when watching addresses on a node which doesn't
+// run the light client.
+var errWatchDisabled = errors.New("acent protocol not enabled")
+
+// TransactionHandler is a client-side callback to invoke when a transaction sent
+// from or to a watched address is included in the chain, and on failures.
+type TransactionHandler interface {
+	// OnTransaction is called with the transaction, its receipt and the watched
+	// address. Incoming is set if the watched address is the recipient. A
+	// transaction between two watched addresses is reported for both of them.
+	OnTransaction(tx *Transaction, receipt *Receipt, address *Address, incoming bool)
+	OnError(failure string)
+}
+
+// watchedTx is a transaction matching a watched address.
+type watchedTx struct {
+	index    int            // Index of the transaction in the block
+	address  common.Address // Watched address matched
+	incoming bool           // Whether the address is the recipient
+}
+
+// matchTransactions returns the transactions sent from or to the watched addresses.
+func matchTransactions(signer types.Signer, txs types.Transactions, watched map[common.Address]struct{}) ([]watchedTx, error) {
+	var matches []watchedTx
+	for i, tx := range txs {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := watched[from]; ok {
+			matches = append(matches, watchedTx{index: i, address: from})
+		}
+		if to := tx.To(); to != nil {
+			if _, ok := watched[*to]; ok {
+				matches = append(matches, watchedTx{index: i, address: *to, incoming: true})
+			}
+		}
+	}
+	return matches, nil
+}
+
+// addressWatcher monitors the blocks imported by the light client for transactions
+// of the watched addresses. The bodies and receipts of the blocks are retrieved
+// from the light servers and verified against the headers, so the notifications
+// don't rely on any trusted third party.
+//
+// Blocks reorganised out of the chain are not retracted, but the blocks replacing
+// them are checked.
+type addressWatcher struct {
+	chain *light.LightChain
+
+	watched map[common.Address]struct{}
+	handler TransactionHandler
+	lock    sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newAddressWatcher creates a watcher of the blocks imported into the light chain.
+func newAddressWatcher(chain *light.LightChain) *addressWatcher {
+	return &addressWatcher{
+		chain:   chain,
+		watched: make(map[common.Address]struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting the monitoring of new blocks.
+func (w *addressWatcher) Start() error {
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := w.chain.SubscribeChainHeadEvent(heads)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer sub.Unsubscribe()
+
+		last := w.chain.CurrentHeader()
+		for {
+			select {
+			case head := <-heads:
+				last = w.process(last, head.Block.NumberU64())
+			case <-sub.Err():
+				return
+			case <-w.quit:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the monitoring of new blocks.
+func (w *addressWatcher) Stop() error {
+	close(w.quit)
+	w.wg.Wait()
+	return nil
+}
+
+// watch adds an address to the watched ones.
+func (w *addressWatcher) watch(address common.Address) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.watched[address] = struct{}{}
+}
+
+// unwatch removes an address from the watched ones.
+func (w *addressWatcher) unwatch(address common.Address) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.watched, address)
+}
+
+// addresses returns the watched addresses.
+func (w *addressWatcher) addresses() []common.Address {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	addresses := make([]common.Address, 0, len(w.watched))
+	for address := range w.watched {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// setHandler replaces the callback invoked with the watched transactions.
+func (w *addressWatcher) setHandler(handler TransactionHandler) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.handler = handler
+}
+
+// process checks the canonical blocks after the last processed one up to the new
+// head for watched transactions, returning the header of the last processed block.
+// If the last processed block was reorganised out, the blocks after the fork point
+// are checked.
+func (w *addressWatcher) process(last *types.Header, head uint64) *types.Header {
+	// Rewind to the last processed block still in the canonical chain
+	for last.Number.Uint64()+maxWatchBackfill > head {
+		number := last.Number.Uint64()
+		if canon := w.chain.GetHeaderByNumber(number); canon != nil && canon.Hash() == last.Hash() {
+			break
+		}
+		parent := w.chain.GetHeader(last.ParentHash, number-1)
+		if parent == nil {
+			break // Unknown ancestry, only check up from the same height
+		}
+		last = parent
+	}
+	if head <= last.Number.Uint64() {
+		return last
+	}
+	from := last.Number.Uint64() + 1
+	if head-last.Number.Uint64() > maxWatchBackfill {
+		from = head - maxWatchBackfill + 1
+	}
+	// Take a snapshot of the watch settings, skipping any retrieval if unused
+	w.lock.RLock()
+	handler := w.handler
+	watched := make(map[common.Address]struct{}, len(w.watched))
+	for address := range w.watched {
+		watched[address] = struct{}{}
+	}
+	w.lock.RUnlock()
+
+	if handler == nil || len(watched) == 0 {
+		if header := w.chain.GetHeaderByNumber(head); header != nil {
+			return header
+		}
+		return last
+	}
+	for number := from; number <= head; number++ {
+		header := w.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return last // Rewound meanwhile, the new head will follow
+		}
+		if err := w.processBlock(header, watched, handler); err != nil {
+			log.Warn("Failed to check block for watched transactions", "number", number, "hash", header.Hash(), "err", err)
+			handler.OnError(fmt.Sprintf("block #%d: %v", number, err))
+		}
+		last = header
+	}
+	return last
+}
+
+// processBlock retrieves the transactions of a block and reports the watched ones,
+// retrieving the receipts only if there are any.
+func (w *addressWatcher) processBlock(header *types.Header, watched map[common.Address]struct{}, handler TransactionHandler) error {
+	ctx, cancel := context.WithTimeout(context.Background(), watchRetrievalTimeout)
+	defer cancel()
+
+	// Abort the retrievals when the watcher is stopped
+	go func() {
+		select {
+		case <-w.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	block, err := light.GetBlock(ctx, w.chain.Odr(), hash, number)
+	if err != nil {
+		return err
+	}
+	signer := types.MakeSigner(w.chain.Config(), header.Number)
+	matches, err := matchTransactions(signer, block.Transactions(), watched)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	receipts, err := light.GetBlockReceipts(ctx, w.chain.Odr(), hash, number)
+	if err != nil {
+		return err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("receipt count mismatch: have %d, want %d", len(receipts), len(block.Transactions()))
+	}
+	for _, match := range matches {
+		handler.OnTransaction(&Transaction{block.Transactions()[match.index]}, &Receipt{receipts[match.index]}, &Address{match.address}, match.incoming)
+	}
+	return nil
+}
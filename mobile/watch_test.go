@@ -0,0 +1,185 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package geth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/consensus/ethash"
+	"github.com/acent/go-acent/core"
+	"github.com/acent/go-acent/core/rawdb"
+	"github.com/acent/go-acent/core/types"
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/light"
+	"github.com/acent/go-acent/params"
+)
+
+// mustSign creates and signs a transaction to the given recipient, or a contract
+// creation if it is nil.
+func mustSign(t *testing.T, signer types.Signer, key *ecdsa.PrivateKey, to *common.Address) *types.Transaction {
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(0, new(big.Int), 100000, big.NewInt(1), nil)
+	} else {
+		tx = types.NewTransaction(0, *to, new(big.Int), 21000, big.NewInt(1), nil)
+	}
+	signed, err := types.SignTx(tx, signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return signed
+}
+
+// Tests that the transactions sent from or to watched addresses are matched.
+func TestMatchTransactions(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		other, _ = crypto.GenerateKey()
+		watched  = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		stranger = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		signer   = types.HomesteadSigner{}
+	)
+	txs := types.Transactions{
+		mustSign(t, signer, other, &stranger),
+		mustSign(t, signer, other, &watched),
+		mustSign(t, signer, key, &stranger),
+		mustSign(t, signer, key, nil),
+		mustSign(t, signer, key, &sender),
+	}
+	tests := []struct {
+		watched []common.Address
+		want    []watchedTx
+	}{
+		{watched: nil, want: nil},
+		{watched: []common.Address{stranger}, want: []watchedTx{
+			{index: 0, address: stranger, incoming: true},
+			{index: 2, address: stranger, incoming: true},
+		}},
+		{watched: []common.Address{watched, sender}, want: []watchedTx{
+			{index: 1, address: watched, incoming: true},
+			{index: 2, address: sender},
+			{index: 3, address: sender},
+			{index: 4, address: sender},
+			{index: 4, address: sender, incoming: true},
+		}},
+	}
+	for i, tt := range tests {
+		set := make(map[common.Address]struct{})
+		for _, address := range tt.watched {
+			set[address] = struct{}{}
+		}
+		have, err := matchTransactions(signer, txs, set)
+		if err != nil {
+			t.Fatalf("test %d: failed to match transactions: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: matches mismatch: have %+v, want %+v", i, have, tt.want)
+		}
+	}
+}
+
+// failingOdr is a light client backend failing all network retrievals.
+type failingOdr struct {
+	light.OdrBackend
+	db ethdb.Database
+}
+
+func (odr *failingOdr) Database() ethdb.Database            { return odr.db }
+func (odr *failingOdr) IndexerConfig() *light.IndexerConfig { return nil }
+func (odr *failingOdr) Retrieve(context.Context, light.OdrRequest) error {
+	return errors.New("offline")
+}
+
+// failureCollector is a transaction handler gathering the reported failures.
+type failureCollector struct {
+	failures []string
+}
+
+func (c *failureCollector) OnTransaction(*Transaction, *Receipt, *Address, bool) {}
+func (c *failureCollector) OnError(failure string)                               { c.failures = append(c.failures, failure) }
+
+// makeWatchHeaders creates a chain of headers on top of the parent, each with
+// the given difficulty and a body to retrieve.
+func makeWatchHeaders(parent *types.Header, n int, difficulty int64, seed byte) []*types.Header {
+	var headers []*types.Header
+	for i := 0; i < n; i++ {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Coinbase:    common.Address{seed},
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			Difficulty:  big.NewInt(difficulty),
+			UncleHash:   types.EmptyUncleHash,
+			TxHash:      common.Hash{seed},
+			ReceiptHash: types.EmptyRootHash,
+		}
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+// Tests that the blocks replacing the processed ones after a reorg are checked.
+func TestAddressWatcherReorg(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig, Difficulty: big.NewInt(1)}).MustCommit(db)
+	chain, err := light.NewLightChain(&failingOdr{db: db}, params.TestChainConfig, ethash.NewFullFaker(), nil)
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	defer chain.Stop()
+
+	handler := new(failureCollector)
+	w := newAddressWatcher(chain)
+	w.watch(common.Address{0xff})
+	w.setHandler(handler)
+
+	// Process an easy chain, checking all of its blocks
+	if _, err := chain.InsertHeaderChain(makeWatchHeaders(genesis.Header(), 3, 1, 1), 1); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	last := w.process(genesis.Header(), 3)
+	if last.Hash() != chain.CurrentHeader().Hash() || len(handler.failures) != 3 {
+		t.Fatalf("easy chain: have last #%d, %d checks, want #3, 3 checks", last.Number, len(handler.failures))
+	}
+	// Reorg to a harder chain of the same length forking after the first block
+	fork := chain.GetHeaderByNumber(1)
+	if _, err := chain.InsertHeaderChain(makeWatchHeaders(fork, 2, 10, 2), 1); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	handler.failures = nil
+	last = w.process(last, 3)
+	if last.Hash() != chain.CurrentHeader().Hash() {
+		t.Fatalf("last processed mismatch: have %x, want %x", last.Hash(), chain.CurrentHeader().Hash())
+	}
+	want := []string{"block #2: offline", "block #3: offline"}
+	if !reflect.DeepEqual(handler.failures, want) {
+		t.Fatalf("checked blocks mismatch: have %v, want %v", handler.failures, want)
+	}
+	// Processing the same head again checks nothing
+	handler.failures = nil
+	if w.process(last, 3); len(handler.failures) != 0 {
+		t.Fatalf("unchanged head rechecked: %v", handler.failures)
+	}
+}
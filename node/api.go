@@ -18,6 +18,7 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -46,6 +47,10 @@ func (n *Node) apis() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   debug.Handler,
+		}, {
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   &privateDebugAPI{n},
 		}, {
 			Namespace: "web3",
 			Version:   "1.0",
@@ -125,6 +130,76 @@ func (api *privateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// PeerScores retrieves the offence scores of the misbehaving remote nodes,
+// including the temporarily banned ones.
+func (api *privateAdminAPI) PeerScores() ([]p2p.PeerScore, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores(), nil
+}
+
+// ClearPeerScore forgets the offence score of a remote node, given by its enode
+// URL or hex ID, lifting its ban if any.
+func (api *privateAdminAPI) ClearPeerScore(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, err := enode.ParseID(url)
+	if err != nil {
+		node, perr := enode.Parse(enode.ValidSchemes, url)
+		if perr != nil {
+			return false, fmt.Errorf("invalid enode: %v", perr)
+		}
+		id = node.ID()
+	}
+	return server.ClearPeerScore(id), nil
+}
+
+// ClearPeerScores forgets the offence scores of all remote nodes, lifting all
+// bans.
+func (api *privateAdminAPI) ClearPeerScores() (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	server.ClearPeerScores()
+	return true, nil
+}
+
+// ReloadPeerFilters re-reads the peer allow and deny list files, disconnecting
+// the peers no longer accepted by them.
+func (api *privateAdminAPI) ReloadPeerFilters() (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.ReloadPeerFilters(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NodeDBStats retrieves a summary of the contents of the node database, which
+// holds the nodes seen by peer discovery.
+func (api *privateAdminAPI) NodeDBStats() (enode.DBStats, error) {
+	return api.node.Server().NodeDatabaseStats()
+}
+
+// CompactNodeDB drops the expired nodes and the ones beyond the retention limit
+// from the node database, and compacts its storage.
+func (api *privateAdminAPI) CompactNodeDB() (bool, error) {
+	if err := api.node.Server().CompactNodeDatabase(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *privateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -185,6 +260,8 @@ func (api *privateAdminAPI) StartHTTP(host *string, port *int, cors *string, api
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		apiKeys:            api.node.apiKeys,
+		gateway:            api.node.gateway,
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -258,6 +335,8 @@ func (api *privateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 	config := wsConfig{
 		Modules: api.node.config.WSModules,
 		Origins: api.node.config.WSOrigins,
+		apiKeys: api.node.apiKeys,
+		gateway: api.node.gateway,
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
@@ -295,6 +374,27 @@ func (api *privateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// ApiKeyUsage retrieves the usage accounting of the API keys accepted on the HTTP
+// and WebSocket endpoints, indexed by key name.
+func (api *privateAdminAPI) ApiKeyUsage() (map[string]APIKeyUsage, error) {
+	if api.node.apiKeys == nil {
+		return nil, errors.New("no API keys configured")
+	}
+	return api.node.apiKeys.usage(), nil
+}
+
+// privateDebugAPI is the collection of node debugging API methods exposed only
+// over a secure RPC channel.
+type privateDebugAPI struct {
+	node *Node // Node interfaced by this API
+}
+
+// Lifecycles retrieves the dependency graph of the services registered on the
+// node, in startup order, along with the time each one took to start.
+func (api *privateDebugAPI) Lifecycles() []LifecycleInfo {
+	return api.node.Lifecycles()
+}
+
 // publicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type publicAdminAPI struct {
@@ -0,0 +1,376 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/acent/go-acent/rpc"
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiKeyHeader = "X-API-Key" // HTTP header carrying the API key
+	jwtParam     = "token"     // URL query parameter carrying the JSON web token, for clients unable to set headers
+
+	apiKeyEndpointHTTP = "http" // Name of the HTTP endpoint in API key configurations
+	apiKeyEndpointWS   = "ws"   // Name of the WebSocket endpoint in API key configurations
+
+	minJWTSecretLength = 32 // Minimum length of the JSON web token secrets, in bytes
+)
+
+var (
+	errMethodDenied      = &apiKeyError{code: -32004, message: "method not permitted for API key"}
+	errRateLimitExceeded = &apiKeyError{code: -32005, message: "API key rate limit exceeded"}
+)
+
+// apiKeyError is an RPC error returned for calls rejected by an API key.
+type apiKeyError struct {
+	code    int
+	message string
+}
+
+func (e *apiKeyError) Error() string  { return e.message }
+func (e *apiKeyError) ErrorCode() int { return e.code }
+
+// APIKeyConfig configures an API key accepted on the HTTP and WebSocket RPC
+// endpoints, including the handlers served along HTTP such as GraphQL. Clients
+// present the key in the X-API-Key header, it is never accepted in the URL where
+// it would leak into access logs. Alternatively, clients authenticate by a JSON
+// web token signed with the JWT secret of the key, presented as a bearer token
+// in the Authorization header or in the token URL query parameter if they can't
+// set headers (e.g. browser WebSockets).
+type APIKeyConfig struct {
+	// Name identifies the owner of the key in logs and usage reports.
+	Name string
+
+	// Key is the secret presented by the clients. It may be omitted if the key
+	// is only authenticated by JSON web tokens.
+	Key string `toml:",omitempty"`
+
+	// JWTSecret is the hex encoded secret, at least 32 bytes long, with which the
+	// clients sign their JSON web tokens (HS256). Tokens must have been issued at
+	// most a minute off the local clock, limiting the damage of a leaked one.
+	JWTSecret string `toml:",omitempty"`
+
+	// Endpoints is the list of endpoints (http, ws) accepting the key. If empty,
+	// the key is accepted on all of them.
+	Endpoints []string `toml:",omitempty"`
+
+	// Modules is the list of API modules exposed to the key on top of the ones
+	// enabled on the endpoint, e.g. to grant remote access to admin or debug.
+	Modules []string `toml:",omitempty"`
+
+	// Methods is the list of methods the key may call. Entries are either full
+	// method names (eth_call), module wildcards (eth_*) or * for all methods.
+	// If empty, all methods exposed on the endpoint may be called.
+	Methods []string `toml:",omitempty"`
+
+	// RateLimit is the maximum number of calls per second, zero meaning unlimited.
+	RateLimit float64 `toml:",omitempty"`
+
+	// RateBurst is the maximum number of calls allowed in a burst above the rate
+	// limit. It defaults to the rate limit itself.
+	RateBurst int `toml:",omitempty"`
+}
+
+// APIKeyUsage is the usage accounting of an API key.
+type APIKeyUsage struct {
+	Calls   uint64            `json:"calls"`   // Number of calls served
+	Denied  uint64            `json:"denied"`  // Number of calls rejected for lack of permission
+	Limited uint64            `json:"limited"` // Number of calls rejected by the rate limit
+	Methods map[string]uint64 `json:"methods"` // Number of calls served per method
+}
+
+// apiKey is an API key along with its permissions, limiter and usage.
+type apiKey struct {
+	name      string
+	secret    string          // Static secret presented by the clients, empty if JWT only
+	jwtSecret []byte          // Secret signing the JSON web tokens of the clients, nil if static only
+	endpoints map[string]bool // Endpoints accepting the key, nil if all of them
+	modules   []string        // Modules exposed on top of the ones of the endpoint
+	methods   map[string]bool // Permitted methods and module wildcards, nil if all are permitted
+	limiter   *rate.Limiter   // Call rate limiter, nil if unlimited
+
+	lock  sync.Mutex
+	usage APIKeyUsage
+}
+
+// newAPIKey creates an API key from its configuration.
+func newAPIKey(config APIKeyConfig) *apiKey {
+	key := &apiKey{
+		name:    config.Name,
+		secret:  config.Key,
+		modules: config.Modules,
+		usage:   APIKeyUsage{Methods: make(map[string]uint64)},
+	}
+	if config.JWTSecret != "" {
+		key.jwtSecret, _ = hex.DecodeString(strings.TrimPrefix(config.JWTSecret, "0x"))
+	}
+	for _, endpoint := range config.Endpoints {
+		if key.endpoints == nil {
+			key.endpoints = make(map[string]bool)
+		}
+		key.endpoints[endpoint] = true
+	}
+	for _, method := range config.Methods {
+		if method == "*" {
+			key.methods = nil
+			break
+		}
+		if key.methods == nil {
+			key.methods = make(map[string]bool)
+		}
+		key.methods[method] = true
+	}
+	if config.RateLimit > 0 {
+		burst := config.RateBurst
+		if burst <= 0 {
+			burst = int(math.Ceil(config.RateLimit))
+		}
+		key.limiter = rate.NewLimiter(rate.Limit(config.RateLimit), burst)
+	}
+	return key
+}
+
+// accepted returns whether the key is accepted on the given endpoint.
+func (key *apiKey) accepted(endpoint string) bool {
+	return key.endpoints == nil || key.endpoints[endpoint]
+}
+
+// exposed returns the modules exposed to the key, given the ones enabled on the
+// endpoint and all the available APIs.
+func (key *apiKey) exposed(apis []rpc.API, modules []string) []string {
+	if len(key.modules) == 0 {
+		return modules
+	}
+	// An empty module list exposes the public APIs, keep doing so
+	exposed := append([]string{}, modules...)
+	if len(exposed) == 0 {
+		for _, api := range apis {
+			if api.Public {
+				exposed = append(exposed, api.Namespace)
+			}
+		}
+	}
+	return append(exposed, key.modules...)
+}
+
+// permitted returns whether the key may call the given method.
+func (key *apiKey) permitted(method string) bool {
+	if key.methods == nil || key.methods[method] {
+		return true
+	}
+	if i := strings.Index(method, "_"); i >= 0 {
+		return key.methods[method[:i+1]+"*"]
+	}
+	return false
+}
+
+// checkCall is the RPC call filter of the key, enforcing the method permissions
+// and the rate limit, and accounting the calls made.
+func (key *apiKey) checkCall(method string) error {
+	key.lock.Lock()
+	defer key.lock.Unlock()
+
+	if !key.permitted(method) {
+		key.usage.Denied++
+		return errMethodDenied
+	}
+	if key.limiter != nil && !key.limiter.Allow() {
+		key.usage.Limited++
+		return errRateLimitExceeded
+	}
+	key.usage.Calls++
+	key.usage.Methods[method]++
+	return nil
+}
+
+// report returns a copy of the usage accounting of the key.
+func (key *apiKey) report() APIKeyUsage {
+	key.lock.Lock()
+	defer key.lock.Unlock()
+
+	usage := key.usage
+	usage.Methods = make(map[string]uint64, len(key.usage.Methods))
+	for method, calls := range key.usage.Methods {
+		usage.Methods[method] = calls
+	}
+	return usage
+}
+
+// apiKeySet is the set of API keys accepted on the HTTP and WebSocket endpoints.
+// It is shared by all endpoints, so that a key's rate limit and usage span them.
+type apiKeySet struct {
+	keys []*apiKey
+}
+
+// newAPIKeySet creates the set of API keys from their configurations.
+func newAPIKeySet(configs []APIKeyConfig) (*apiKeySet, error) {
+	var (
+		set     = new(apiKeySet)
+		names   = make(map[string]bool)
+		secrets = make(map[string]string)
+	)
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, errors.New("API key without name")
+		}
+		if config.Key == "" && config.JWTSecret == "" {
+			return nil, fmt.Errorf("API key %q without secret", config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate API key name %q", config.Name)
+		}
+		for _, secret := range []string{config.Key, config.JWTSecret} {
+			if secret == "" {
+				continue
+			}
+			if owner, ok := secrets[secret]; ok {
+				return nil, fmt.Errorf("API key %q reuses the secret of %q", config.Name, owner)
+			}
+			secrets[secret] = config.Name
+		}
+		if config.JWTSecret != "" {
+			secret, err := hex.DecodeString(strings.TrimPrefix(config.JWTSecret, "0x"))
+			if err != nil {
+				return nil, fmt.Errorf("API key %q has invalid JWT secret: %v", config.Name, err)
+			}
+			if len(secret) < minJWTSecretLength {
+				return nil, fmt.Errorf("API key %q has too short JWT secret (%d<%d bytes)", config.Name, len(secret), minJWTSecretLength)
+			}
+		}
+		for _, endpoint := range config.Endpoints {
+			if endpoint != apiKeyEndpointHTTP && endpoint != apiKeyEndpointWS {
+				return nil, fmt.Errorf("API key %q has unknown endpoint %q", config.Name, endpoint)
+			}
+		}
+		if config.RateLimit < 0 {
+			return nil, fmt.Errorf("API key %q has negative rate limit", config.Name)
+		}
+		names[config.Name] = true
+		set.keys = append(set.keys, newAPIKey(config))
+	}
+	return set, nil
+}
+
+// usage returns the usage accounting of all keys, indexed by key name.
+func (set *apiKeySet) usage() map[string]APIKeyUsage {
+	usage := make(map[string]APIKeyUsage, len(set.keys))
+	for _, key := range set.keys {
+		usage[key.name] = key.report()
+	}
+	return usage
+}
+
+// keyHandler is the request handler of an API key, along with the secret of the
+// key or of its JSON web tokens.
+type keyHandler struct {
+	secret  []byte
+	handler http.Handler
+}
+
+// apiKeyHandler authenticates requests by their API key and dispatches them to
+// an RPC server dedicated to the key, so that its call filter enforces the
+// permissions and the rate limit of the key.
+type apiKeyHandler struct {
+	handlers    []keyHandler // Request handlers of the keys authenticated by static secrets
+	jwtHandlers []keyHandler // Request handlers of the keys authenticated by tokens
+}
+
+// newAPIKeyHandler creates an RPC server for every API key accepted on the named
+// endpoint, exposing the given modules along with the ones granted to the key,
+// and an HTTP handler dispatching the requests to them. The serve function
+// creates the request handler (HTTP or WebSocket) of an RPC server.
+func newAPIKeyHandler(set *apiKeySet, endpoint string, apis []rpc.API, modules []string, serve func(*rpc.Server) http.Handler) (*apiKeyHandler, []*rpc.Server, error) {
+	var (
+		handler = new(apiKeyHandler)
+		servers []*rpc.Server
+	)
+	for _, key := range set.keys {
+		if !key.accepted(endpoint) {
+			continue
+		}
+		srv := rpc.NewServer()
+		if err := RegisterApisFromWhitelist(apis, key.exposed(apis, modules), srv, false); err != nil {
+			for _, srv := range servers {
+				srv.Stop()
+			}
+			return nil, nil, err
+		}
+		srv.SetCallFilter(key.checkCall)
+
+		served := serve(srv)
+		if key.secret != "" {
+			handler.handlers = append(handler.handlers, keyHandler{[]byte(key.secret), served})
+		}
+		if key.jwtSecret != nil {
+			handler.jwtHandlers = append(handler.jwtHandlers, keyHandler{key.jwtSecret, served})
+		}
+		servers = append(servers, srv)
+	}
+	return handler, servers, nil
+}
+
+func (h *apiKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler := h.authenticate(w, r); handler != nil {
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// authenticate returns the request handler of the API key presented by the
+// request, or responds with an error and returns nil if there's no valid one.
+func (h *apiKeyHandler) authenticate(w http.ResponseWriter, r *http.Request) http.Handler {
+	// Authenticate by JSON web token if one is presented
+	token := r.URL.Query().Get(jwtParam)
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token != "" {
+		for _, key := range h.jwtHandlers {
+			if rpc.VerifyJWT(key.secret, token) == nil {
+				return key.handler
+			}
+		}
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return nil
+	}
+	// Otherwise authenticate by static API key, comparing against all of them in
+	// constant time to not leak the secrets through the response timing
+	secret := []byte(r.Header.Get(apiKeyHeader))
+	if len(secret) == 0 {
+		http.Error(w, "missing API key", http.StatusUnauthorized)
+		return nil
+	}
+	var handler http.Handler
+	for _, key := range h.handlers {
+		if subtle.ConstantTimeCompare(secret, key.secret) == 1 {
+			handler = key.handler
+		}
+	}
+	if handler == nil {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	}
+	return handler
+}
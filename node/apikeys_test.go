@@ -0,0 +1,228 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// apiKeyResponse performs an RPC request with the given API key header and
+// returns the error code of the response, zero if the call succeeded.
+func apiKeyResponse(t *testing.T, url string, key string) int {
+	t.Helper()
+
+	var headers []string
+	if key != "" {
+		headers = []string{apiKeyHeader, key}
+	}
+	return rpcResponseCode(t, url, headers...)
+}
+
+// rpcResponseCode performs an RPC request with the given headers and returns the
+// error code of the response, zero if the call succeeded.
+func rpcResponseCode(t *testing.T, url string, headers ...string) int {
+	t.Helper()
+
+	resp := rpcRequest(t, url, headers...)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var result struct {
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Error != nil {
+		return result.Error.Code
+	}
+	return 0
+}
+
+// dialAPIKeyWebsocket connects to a WebSocket endpoint with the given API key.
+func dialAPIKeyWebsocket(url string, key string) (*rpc.Client, error) {
+	return rpc.DialWebsocketWithHeaders(context.Background(), url, "", websocket.Dialer{}, func(ctx context.Context, header http.Header) error {
+		header.Set(apiKeyHeader, key)
+		return nil
+	})
+}
+
+// TestAPIKeys makes sure API keys are authenticated and their permissions, rate
+// limits and usage accounting are enforced on the HTTP and WebSocket endpoints.
+func TestAPIKeys(t *testing.T) {
+	keys, err := newAPIKeySet([]APIKeyConfig{
+		{Name: "alice", Key: "secret-a", Methods: []string{"rpc_*"}},
+		{Name: "bob", Key: "secret-b", Methods: []string{"eth_call"}},
+		{Name: "carol", Key: "secret-c", RateLimit: 0.001, RateBurst: 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to create API keys: %v", err)
+	}
+	srv := createAndStartServer(t, &httpConfig{apiKeys: keys}, true, &wsConfig{apiKeys: keys})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	// Requests without a valid key must be rejected
+	resp := rpcRequest(t, url)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = rpcRequest(t, url, apiKeyHeader, "secret-x")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = rpcRequest(t, url+"/?apikey=secret-a")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Method permissions and rate limits must be enforced per key
+	assert.Equal(t, 0, apiKeyResponse(t, url, "secret-a"))
+	assert.Equal(t, errMethodDenied.code, apiKeyResponse(t, url, "secret-b"))
+	assert.Equal(t, 0, apiKeyResponse(t, url, "secret-c"))
+	assert.Equal(t, errRateLimitExceeded.code, apiKeyResponse(t, url, "secret-c"))
+
+	// WebSocket connections must be authenticated the same way
+	client, err := dialAPIKeyWebsocket("ws://"+srv.listenAddr(), "secret-a")
+	if err != nil {
+		t.Fatalf("failed to dial WebSocket with API key: %v", err)
+	}
+	defer client.Close()
+	if err := client.Call(nil, "rpc_modules"); err != nil {
+		t.Fatalf("failed to call over WebSocket: %v", err)
+	}
+	if _, err := rpc.DialWebsocket(context.Background(), "ws://"+srv.listenAddr(), ""); err == nil {
+		t.Fatalf("WebSocket dial without API key succeeded")
+	}
+	// Usage must be accounted across both endpoints
+	usage := keys.usage()
+	assert.Equal(t, APIKeyUsage{Calls: 2, Methods: map[string]uint64{"rpc_modules": 2}}, usage["alice"])
+	assert.Equal(t, APIKeyUsage{Denied: 1, Methods: map[string]uint64{}}, usage["bob"])
+	assert.Equal(t, APIKeyUsage{Calls: 1, Limited: 1, Methods: map[string]uint64{"rpc_modules": 1}}, usage["carol"])
+}
+
+// TestAPIKeyConfigs makes sure invalid API key configurations are rejected.
+func TestAPIKeyConfigs(t *testing.T) {
+	tests := [][]APIKeyConfig{
+		{{Key: "secret"}},
+		{{Name: "alice"}},
+		{{Name: "alice", Key: "secret-a"}, {Name: "alice", Key: "secret-b"}},
+		{{Name: "alice", Key: "secret"}, {Name: "bob", Key: "secret"}},
+		{{Name: "alice", Key: "secret", RateLimit: -1}},
+		{{Name: "alice", JWTSecret: "0xzz"}},
+		{{Name: "alice", JWTSecret: "0x0123"}},
+		{{Name: "alice", Key: "secret", Endpoints: []string{"ipc"}}},
+		{{Name: "alice", Key: testJWTSecret}, {Name: "bob", JWTSecret: testJWTSecret}},
+	}
+	for i, configs := range tests {
+		if _, err := newAPIKeySet(configs); err == nil {
+			t.Errorf("test %d: invalid configuration accepted", i)
+		}
+	}
+}
+
+// testJWTSecret is a hex encoded JWT secret of the minimum allowed length.
+var testJWTSecret = "0x" + strings.Repeat("ab", minJWTSecretLength)
+
+// TestAPIKeyJWT makes sure API keys can be authenticated by JSON web tokens and
+// restricted to a subset of the endpoints.
+func TestAPIKeyJWT(t *testing.T) {
+	keys, err := newAPIKeySet([]APIKeyConfig{
+		{Name: "dave", JWTSecret: testJWTSecret, Endpoints: []string{apiKeyEndpointHTTP}},
+		{Name: "erin", Key: "secret-e", Endpoints: []string{apiKeyEndpointWS}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create API keys: %v", err)
+	}
+	srv := createAndStartServer(t, &httpConfig{apiKeys: keys}, true, &wsConfig{apiKeys: keys})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	secret, _ := hex.DecodeString(testJWTSecret[2:])
+	token := rpc.NewJWTToken(secret, time.Now())
+
+	// Valid tokens must be accepted, in the header or the query
+	assert.Equal(t, 0, rpcResponseCode(t, url, "Authorization", "Bearer "+token))
+	assert.Equal(t, 0, apiKeyResponse(t, url+"/?"+jwtParam+"="+token, ""))
+
+	// Forged and stale tokens must be rejected
+	resp := rpcRequest(t, url, "Authorization", "Bearer "+rpc.NewJWTToken([]byte("wrong secret"), time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = rpcRequest(t, url, "Authorization", "Bearer "+rpc.NewJWTToken(secret, time.Now().Add(-time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Keys must only be accepted on their endpoints
+	resp = rpcRequest(t, url, apiKeyHeader, "secret-e")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	client, err := dialAPIKeyWebsocket("ws://"+srv.listenAddr(), "secret-e")
+	if err != nil {
+		t.Fatalf("failed to dial WebSocket with API key: %v", err)
+	}
+	client.Close()
+	if _, err := rpc.DialWebsocket(context.Background(), "ws://"+srv.listenAddr()+"/?"+jwtParam+"="+token, ""); err == nil {
+		t.Fatalf("WebSocket dial with HTTP only token succeeded")
+	}
+}
+
+// TestAPIKeyModules makes sure API keys may be granted modules on top of the ones
+// enabled on the endpoint.
+func TestAPIKeyModules(t *testing.T) {
+	apis := []rpc.API{{Namespace: "eth", Public: true}, {Namespace: "admin"}, {Namespace: "debug"}}
+
+	key := newAPIKey(APIKeyConfig{Name: "alice", Key: "secret"})
+	assert.Equal(t, []string(nil), key.exposed(apis, nil))
+	assert.Equal(t, []string{"eth"}, key.exposed(apis, []string{"eth"}))
+
+	key = newAPIKey(APIKeyConfig{Name: "bob", Key: "secret", Modules: []string{"admin"}})
+	assert.Equal(t, []string{"eth", "admin"}, key.exposed(apis, nil))
+	assert.Equal(t, []string{"debug", "admin"}, key.exposed(apis, []string{"debug"}))
+}
+
+// TestAPIKeyHandlers makes sure the handlers served along the HTTP endpoint, such
+// as GraphQL, require an API key too.
+func TestAPIKeyHandlers(t *testing.T) {
+	keys, err := newAPIKeySet([]APIKeyConfig{{Name: "alice", Key: "secret-a"}})
+	if err != nil {
+		t.Fatalf("failed to create API keys: %v", err)
+	}
+	srv := createAndStartServer(t, &httpConfig{apiKeys: keys}, false, nil)
+	defer srv.stop()
+	srv.mux.Handle("/graphql", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(key string) int {
+		req, _ := http.NewRequest("GET", "http://"+srv.listenAddr()+"/graphql", nil)
+		if key != "" {
+			req.Header.Set(apiKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, request(""))
+	assert.Equal(t, http.StatusUnauthorized, request("secret-x"))
+	assert.Equal(t, http.StatusOK, request("secret-a"))
+}
@@ -18,6 +18,7 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -32,6 +33,7 @@ import (
 	"github.com/acent/go-acent/accounts/scwallet"
 	"github.com/acent/go-acent/accounts/usbwallet"
 	"github.com/acent/go-acent/common"
+	"github.com/acent/go-acent/common/hexutil"
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p"
@@ -45,6 +47,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the secret of the authenticated endpoint
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -70,6 +73,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the key-value database engine backing the node's databases,
+	// either "leveldb" or "pebble". If empty, the engine of an existing database
+	// is used, falling back to leveldb for new ones.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -171,6 +179,38 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// APIKeys is the list of API keys accepted on the HTTP and WebSocket JSON-RPC
+	// endpoints, each with its own static or JWT secret, exposed modules, method
+	// permissions and rate limit. If set, all requests to these endpoints and to
+	// the handlers served along HTTP (e.g. GraphQL) must be authenticated by one
+	// of the keys.
+	APIKeys []APIKeyConfig `toml:",omitempty"`
+
+	// Gateway enables the gateway mode of the HTTP and WebSocket JSON-RPC endpoints,
+	// rate limiting the calls of every client IP address and the concurrent calls
+	// of every method, for exposing the endpoints to the public.
+	Gateway *GatewayConfig `toml:",omitempty"`
+
+	// RPCBatchParallelism is the number of calls of a JSON-RPC batch served
+	// concurrently on a single HTTP or WebSocket connection. The responses are
+	// sent in the order of the requests regardless. Batches are served
+	// sequentially if below 2.
+	RPCBatchParallelism int `toml:",omitempty"`
+
+	// AuthAddr is the host interface on which to start the authenticated HTTP RPC
+	// server, exposing the APIs marked as authenticated (e.g. the engine API).
+	// The server is only started if such APIs are registered.
+	AuthAddr string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated HTTP
+	// RPC server.
+	AuthPort int `toml:",omitempty"`
+
+	// JWTSecret is the path to the hex encoded 32 byte secret authenticating the
+	// requests on the authenticated endpoint. If the file doesn't exist, a random
+	// secret is generated into it. If empty, the secret is kept in the datadir.
+	JWTSecret string `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -385,6 +425,44 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// jwtSecret retrieves the secret authenticating the requests on the authenticated
+// RPC endpoint, loading it from the configured file or the data folder. If no
+// secret can be found, a new one is generated and persisted.
+func (c *Config) jwtSecret() ([]byte, error) {
+	fileName := c.JWTSecret
+	if fileName == "" {
+		fileName = c.ResolvePath(datadirJWTSecret)
+	}
+	if fileName != "" {
+		if data, err := ioutil.ReadFile(fileName); err == nil {
+			secret := common.FromHex(strings.TrimSpace(string(data)))
+			if len(secret) != 32 {
+				return nil, fmt.Errorf("invalid JWT secret in %s: need 32 hex encoded bytes", fileName)
+			}
+			return secret, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	// No persistent secret found, generate and store a new one.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		log.Warn("Using ephemeral JWT secret", "secret", hexutil.Encode(secret))
+		return secret, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fileName, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", fileName)
+	return secret, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*enode.Node {
 	return c.parsePersistentNodes(&c.staticNodesWarning, c.ResolvePath(datadirStaticNodes))
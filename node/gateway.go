@@ -0,0 +1,200 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/metrics"
+	"golang.org/x/time/rate"
+)
+
+// gatewayClientTimeout is the minimum time a client must be idle before its
+// rate limiter is dropped. A dropped client starts over with a full burst.
+const gatewayClientTimeout = time.Minute
+
+var (
+	errGatewayRateLimited = &apiKeyError{code: -32005, message: "rate limit exceeded"}
+	errGatewayBusy        = &apiKeyError{code: -32005, message: "too many concurrent requests"}
+)
+
+var (
+	gatewayCallsCounter   = metrics.NewRegisteredLabeledCounter("rpc/gateway/calls", nil, "method")
+	gatewayLimitedCounter = metrics.NewRegisteredLabeledCounter("rpc/gateway/limited", nil, "method")
+	gatewayBusyCounter    = metrics.NewRegisteredLabeledCounter("rpc/gateway/busy", nil, "method")
+	gatewayClientsGauge   = metrics.NewRegisteredGauge("rpc/gateway/clients", nil)
+	gatewayInflightGauge  = metrics.NewRegisteredGauge("rpc/gateway/inflight", nil)
+)
+
+// GatewayConfig configures the gateway mode of the HTTP and WebSocket JSON-RPC
+// endpoints, limiting the load public clients may put on the node.
+type GatewayConfig struct {
+	// RateLimit is the maximum number of calls per second allowed from a single
+	// IP address, zero meaning unlimited.
+	RateLimit float64 `toml:",omitempty"`
+
+	// RateBurst is the maximum number of calls allowed from a single IP address
+	// in a burst above the rate limit. It defaults to the rate limit itself.
+	RateBurst int `toml:",omitempty"`
+
+	// MaxConcurrent is the maximum number of calls of any single method served
+	// concurrently, zero meaning unlimited.
+	MaxConcurrent int `toml:",omitempty"`
+
+	// MethodConcurrency overrides MaxConcurrent for specific methods. Keys are
+	// either full method names (eth_call) or module wildcards (eth_*).
+	MethodConcurrency map[string]int `toml:",omitempty"`
+}
+
+// gatewayClient is the rate limiter of a client IP address.
+type gatewayClient struct {
+	limiter  *rate.Limiter
+	lastSeen mclock.AbsTime
+}
+
+// gateway enforces the per-IP rate limits and the per-method concurrency limits
+// of the gateway mode, and meters the calls served. It is shared by all
+// endpoints, so that the limits span them.
+type gateway struct {
+	config  GatewayConfig
+	burst   int
+	timeout time.Duration
+	clock   mclock.Clock
+
+	lock    sync.Mutex
+	clients map[string]*gatewayClient // Rate limiters by client IP address
+	slots   map[string]chan struct{}  // Concurrency slots by method
+	evicted mclock.AbsTime            // Last time idle clients were evicted
+}
+
+// newGateway creates the gateway from its configuration.
+func newGateway(config GatewayConfig) (*gateway, error) {
+	if config.RateLimit < 0 {
+		return nil, errors.New("gateway has negative rate limit")
+	}
+	if config.RateBurst < 0 {
+		return nil, errors.New("gateway has negative rate burst")
+	}
+	if config.MaxConcurrent < 0 {
+		return nil, errors.New("gateway has negative concurrency limit")
+	}
+	for method, limit := range config.MethodConcurrency {
+		if limit < 0 {
+			return nil, fmt.Errorf("gateway has negative concurrency limit for %q", method)
+		}
+	}
+	gw := &gateway{
+		config:  config,
+		burst:   config.RateBurst,
+		timeout: gatewayClientTimeout,
+		clock:   mclock.System{},
+		clients: make(map[string]*gatewayClient),
+		slots:   make(map[string]chan struct{}),
+	}
+	if gw.burst == 0 {
+		gw.burst = int(math.Ceil(config.RateLimit))
+	}
+	// Keep clients at least until their burst is replenished, otherwise dropping
+	// them would grant them a free burst.
+	if config.RateLimit > 0 {
+		if refill := time.Duration(float64(gw.burst) / config.RateLimit * float64(time.Second)); refill > gw.timeout {
+			gw.timeout = refill
+		}
+	}
+	return gw, nil
+}
+
+// concurrency returns the maximum number of concurrent calls of the given method,
+// zero meaning unlimited.
+func (gw *gateway) concurrency(method string) int {
+	if limit, ok := gw.config.MethodConcurrency[method]; ok {
+		return limit
+	}
+	if i := strings.Index(method, "_"); i >= 0 {
+		if limit, ok := gw.config.MethodConcurrency[method[:i+1]+"*"]; ok {
+			return limit
+		}
+	}
+	return gw.config.MaxConcurrent
+}
+
+// limitCall is the RPC call limiter of the gateway, enforcing the rate limit of
+// the calling IP address and the concurrency limit of the called method.
+func (gw *gateway) limitCall(remote, method string) (func(), error) {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	if gw.config.RateLimit > 0 && !gw.client(remote).limiter.Allow() {
+		gatewayLimitedCounter.With(method).Inc(1)
+		return nil, errGatewayRateLimited
+	}
+	release := func() {}
+	if limit := gw.concurrency(method); limit > 0 {
+		slots := gw.slots[method]
+		if slots == nil {
+			slots = make(chan struct{}, limit)
+			gw.slots[method] = slots
+		}
+		select {
+		case slots <- struct{}{}:
+			release = func() { <-slots }
+		default:
+			gatewayBusyCounter.With(method).Inc(1)
+			return nil, errGatewayBusy
+		}
+	}
+	gatewayCallsCounter.With(method).Inc(1)
+	gatewayInflightGauge.Inc(1)
+
+	return func() {
+		release()
+		gatewayInflightGauge.Dec(1)
+	}, nil
+}
+
+// client returns the rate limiter of the IP address of the given remote, creating
+// it if needed and evicting the idle ones. The caller must hold gw.lock.
+func (gw *gateway) client(remote string) *gatewayClient {
+	now := gw.clock.Now()
+	if time.Duration(now-gw.evicted) >= gw.timeout {
+		for ip, client := range gw.clients {
+			if time.Duration(now-client.lastSeen) >= gw.timeout {
+				delete(gw.clients, ip)
+			}
+		}
+		gw.evicted = now
+	}
+	ip := remote
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		ip = host
+	}
+	client := gw.clients[ip]
+	if client == nil {
+		client = &gatewayClient{limiter: rate.NewLimiter(rate.Limit(gw.config.RateLimit), gw.burst)}
+		gw.clients[ip] = client
+	}
+	client.lastSeen = now
+	gatewayClientsGauge.Update(int64(len(gw.clients)))
+	return client
+}
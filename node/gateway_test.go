@@ -0,0 +1,125 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGateway makes sure the rate limit of the gateway mode is enforced per IP
+// address across the HTTP and WebSocket endpoints.
+func TestGateway(t *testing.T) {
+	gw, err := newGateway(GatewayConfig{RateLimit: 0.001, RateBurst: 2})
+	if err != nil {
+		t.Fatalf("failed to create gateway: %v", err)
+	}
+	srv := createAndStartServer(t, &httpConfig{gateway: gw}, true, &wsConfig{gateway: gw})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	assert.Equal(t, 0, rpcResponseCode(t, url))
+
+	client, err := rpc.DialWebsocket(context.Background(), "ws://"+srv.listenAddr(), "")
+	if err != nil {
+		t.Fatalf("failed to dial WebSocket: %v", err)
+	}
+	defer client.Close()
+	if err := client.Call(nil, "rpc_modules"); err != nil {
+		t.Fatalf("failed to call over WebSocket: %v", err)
+	}
+	// The burst is spent by now on both endpoints
+	assert.Equal(t, errGatewayRateLimited.code, rpcResponseCode(t, url))
+	if err := client.Call(nil, "rpc_modules"); err == nil || err.Error() != errGatewayRateLimited.Error() {
+		t.Fatalf("WebSocket call error mismatch: have %v, want %v", err, errGatewayRateLimited)
+	}
+}
+
+// TestGatewayLimits makes sure the concurrency limits of the gateway mode are
+// enforced per method and idle clients are evicted.
+func TestGatewayLimits(t *testing.T) {
+	gw, err := newGateway(GatewayConfig{
+		RateLimit:         1,
+		MaxConcurrent:     1,
+		MethodConcurrency: map[string]int{"eth_*": 2, "eth_call": 0},
+	})
+	if err != nil {
+		t.Fatalf("failed to create gateway: %v", err)
+	}
+	clock := new(mclock.Simulated)
+	gw.clock = clock
+
+	// Concurrency limits must be enforced per method, falling back to module wildcards
+	tests := []struct {
+		method string
+		limit  int
+	}{
+		{"rpc_modules", 1},
+		{"eth_getBalance", 2},
+		{"eth_call", 0},
+	}
+	for _, test := range tests {
+		if limit := gw.concurrency(test.method); limit != test.limit {
+			t.Errorf("%s: concurrency limit mismatch: have %d, want %d", test.method, limit, test.limit)
+		}
+	}
+	done, err := gw.limitCall("10.0.0.1:30303", "rpc_modules")
+	if err != nil {
+		t.Fatalf("first call rejected: %v", err)
+	}
+	if _, err := gw.limitCall("10.0.0.2:30303", "rpc_modules"); err != errGatewayBusy {
+		t.Fatalf("concurrent call error mismatch: have %v, want %v", err, errGatewayBusy)
+	}
+	done()
+	if _, err := gw.limitCall("10.0.0.3:30303", "rpc_modules"); err != nil {
+		t.Fatalf("released call rejected: %v", err)
+	}
+	// Rate limits must be enforced per IP address, regardless of the port
+	if _, err := gw.limitCall("10.0.0.1:40404", "eth_call"); err != errGatewayRateLimited {
+		t.Fatalf("rate limited call error mismatch: have %v, want %v", err, errGatewayRateLimited)
+	}
+	// Idle clients must be evicted
+	if len(gw.clients) != 3 {
+		t.Fatalf("tracked client count mismatch: have %d, want %d", len(gw.clients), 3)
+	}
+	clock.Run(gatewayClientTimeout)
+	if _, err := gw.limitCall("10.0.0.4:30303", "eth_call"); err != nil {
+		t.Fatalf("call rejected: %v", err)
+	}
+	if len(gw.clients) != 1 {
+		t.Fatalf("tracked client count mismatch: have %d, want %d", len(gw.clients), 1)
+	}
+}
+
+// TestGatewayConfigs makes sure invalid gateway configurations are rejected.
+func TestGatewayConfigs(t *testing.T) {
+	tests := []GatewayConfig{
+		{RateLimit: -1},
+		{RateLimit: 1, RateBurst: -1},
+		{MaxConcurrent: -1},
+		{MethodConcurrency: map[string]int{"eth_call": -1}},
+	}
+	for i, config := range tests {
+		if _, err := newGateway(config); err == nil {
+			t.Errorf("test %d: invalid configuration accepted", i)
+		}
+	}
+}
@@ -16,6 +16,13 @@
 
 package node
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acent/go-acent/common"
+)
+
 // Lifecycle encompasses the behavior of services that can be started and stopped
 // on the node. Lifecycle management is delegated to the node, but it is the
 // responsibility of the service-specific package to configure and register the
@@ -29,3 +36,56 @@ type Lifecycle interface {
 	// are all terminated.
 	Stop() error
 }
+
+// LifecycleInfo describes a registered lifecycle in the dependency graph of the
+// node.
+type LifecycleInfo struct {
+	Name     string                `json:"name"`     // Type of the lifecycle
+	Requires []string              `json:"requires"` // Lifecycles started before this one
+	Order    int                   `json:"order"`    // Position in the startup order, -1 if not started
+	Elapsed  common.PrettyDuration `json:"elapsed"`  // Time taken to start
+}
+
+// sortLifecycles orders the given lifecycles such that every lifecycle comes after
+// the ones it requires. Lifecycles not depending on each other keep their order of
+// registration. An error is returned if a required lifecycle isn't registered or
+// if the requirements are circular.
+func sortLifecycles(lifecycles []Lifecycle, requires map[Lifecycle][]Lifecycle) ([]Lifecycle, error) {
+	for _, lifecycle := range lifecycles {
+		for _, dep := range requires[lifecycle] {
+			if !containsLifecycle(lifecycles, dep) {
+				return nil, fmt.Errorf("lifecycle %T requires unregistered lifecycle %T", lifecycle, dep)
+			}
+		}
+	}
+	var (
+		sorted  = make([]Lifecycle, 0, len(lifecycles))
+		pending = append([]Lifecycle{}, lifecycles...)
+	)
+	for len(pending) > 0 {
+		next := -1
+		for i, lifecycle := range pending {
+			ready := true
+			for _, dep := range requires[lifecycle] {
+				if !containsLifecycle(sorted, dep) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			names := make([]string, len(pending))
+			for i, lifecycle := range pending {
+				names[i] = fmt.Sprintf("%T", lifecycle)
+			}
+			return nil, fmt.Errorf("circular lifecycle requirements between %s", strings.Join(names, ", "))
+		}
+		sorted = append(sorted, pending[next])
+		pending = append(pending[:next], pending[next+1:]...)
+	}
+	return sorted, nil
+}
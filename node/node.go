@@ -25,10 +25,13 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/acent/go-acent/accounts"
+	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/core/rawdb"
 	"github.com/acent/go-acent/ethdb"
+	"github.com/acent/go-acent/ethdb/remotefreezer"
 	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p"
@@ -55,9 +58,15 @@ type Node struct {
 	http          *httpServer //
 	ws            *httpServer //
 	ipc           *ipcServer  // Stores information about the ipc http server
+	httpAuth      *httpServer // JWT authenticated HTTP server for the authenticated APIs
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	apiKeys       *apiKeySet  // API keys required on the HTTP and WebSocket endpoints, nil if open
+	gateway       *gateway    // Call limits of the HTTP and WebSocket endpoints, nil if unlimited
 
 	databases map[*closeTrackingDB]struct{} // All open databases
+
+	requires   map[Lifecycle][]Lifecycle   // Lifecycles required to be started before each lifecycle
+	startTimes map[Lifecycle]time.Duration // Time taken to start each started lifecycle
 }
 
 const (
@@ -103,6 +112,8 @@ func New(conf *Config) (*Node, error) {
 		stop:          make(chan struct{}),
 		server:        &p2p.Server{Config: conf.P2P},
 		databases:     make(map[*closeTrackingDB]struct{}),
+		requires:      make(map[Lifecycle][]Lifecycle),
+		startTimes:    make(map[Lifecycle]time.Duration),
 	}
 
 	// Register built-in APIs.
@@ -142,11 +153,24 @@ func New(conf *Config) (*Node, error) {
 	if err := validatePrefix("WebSocket", conf.WSPathPrefix); err != nil {
 		return nil, err
 	}
+	// Set up the API keys shared by the HTTP and WebSocket endpoints.
+	if len(conf.APIKeys) > 0 {
+		if node.apiKeys, err = newAPIKeySet(conf.APIKeys); err != nil {
+			return nil, err
+		}
+	}
+	// Set up the gateway limits shared by the HTTP and WebSocket endpoints.
+	if conf.Gateway != nil {
+		if node.gateway, err = newGateway(*conf.Gateway); err != nil {
+			return nil, err
+		}
+	}
 
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
+	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 
 	return node, nil
 }
@@ -166,11 +190,16 @@ func (n *Node) Start() error {
 		n.lock.Unlock()
 		return ErrNodeStopped
 	}
+	// Order the lifecycles by their requirements, shutdown happens in reverse
+	lifecycles, err := sortLifecycles(n.lifecycles, n.requires)
+	if err != nil {
+		n.lock.Unlock()
+		return err
+	}
+	n.lifecycles = lifecycles
 	n.state = runningState
 	// open networking and RPC endpoints
-	err := n.openEndpoints()
-	lifecycles := make([]Lifecycle, len(n.lifecycles))
-	copy(lifecycles, n.lifecycles)
+	err = n.openEndpoints()
 	n.lock.Unlock()
 
 	// Check if endpoint startup failed.
@@ -181,9 +210,14 @@ func (n *Node) Start() error {
 	// Start all registered lifecycles.
 	var started []Lifecycle
 	for _, lifecycle := range lifecycles {
+		start := time.Now()
 		if err = lifecycle.Start(); err != nil {
 			break
 		}
+		n.lock.Lock()
+		n.startTimes[lifecycle] = time.Since(start)
+		n.lock.Unlock()
+
 		started = append(started, lifecycle)
 	}
 	// Check if any lifecycle failed to start.
@@ -341,9 +375,19 @@ func (n *Node) startRPC() error {
 		return err
 	}
 
+	// Authenticated APIs are only served on the authenticated endpoint.
+	var open, authenticated []rpc.API
+	for _, api := range n.rpcAPIs {
+		if api.Authenticated {
+			authenticated = append(authenticated, api)
+		} else {
+			open = append(open, api)
+		}
+	}
+
 	// Configure IPC.
 	if n.ipc.endpoint != "" {
-		if err := n.ipc.start(n.rpcAPIs); err != nil {
+		if err := n.ipc.start(open); err != nil {
 			return err
 		}
 	}
@@ -355,11 +399,14 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			apiKeys:            n.apiKeys,
+			gateway:            n.gateway,
+			batchParallelism:   n.config.RPCBatchParallelism,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
 		}
-		if err := n.http.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.http.enableRPC(open, config); err != nil {
 			return err
 		}
 	}
@@ -371,11 +418,22 @@ func (n *Node) startRPC() error {
 			Modules: n.config.WSModules,
 			Origins: n.config.WSOrigins,
 			prefix:  n.config.WSPathPrefix,
+			apiKeys: n.apiKeys,
+			gateway: n.gateway,
+
+			batchParallelism: n.config.RPCBatchParallelism,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
 		}
-		if err := server.enableWS(n.rpcAPIs, config); err != nil {
+		if err := server.enableWS(open, config); err != nil {
+			return err
+		}
+	}
+
+	// Configure the authenticated HTTP endpoint.
+	if n.config.AuthAddr != "" && len(authenticated) > 0 {
+		if err := n.enableAuthRPC(authenticated); err != nil {
 			return err
 		}
 	}
@@ -383,7 +441,37 @@ func (n *Node) startRPC() error {
 	if err := n.http.start(); err != nil {
 		return err
 	}
-	return n.ws.start()
+	if err := n.ws.start(); err != nil {
+		return err
+	}
+	return n.httpAuth.start()
+}
+
+// enableAuthRPC configures the JWT authenticated HTTP endpoint, exposing the given
+// authenticated APIs along with the eth namespace consensus clients rely on.
+func (n *Node) enableAuthRPC(authenticated []rpc.API) error {
+	secret, err := n.config.jwtSecret()
+	if err != nil {
+		return err
+	}
+	apis, modules := []rpc.API{}, []string{"eth"}
+	for _, api := range n.rpcAPIs {
+		if api.Namespace == "eth" && !api.Authenticated {
+			apis = append(apis, api)
+		}
+	}
+	for _, api := range authenticated {
+		apis, modules = append(apis, api), append(modules, api.Namespace)
+	}
+	if err := n.httpAuth.setListenAddr(n.config.AuthAddr, n.config.AuthPort); err != nil {
+		return err
+	}
+	// The tokens protect the endpoint, so virtual hosts aren't restricted.
+	return n.httpAuth.enableRPC(apis, httpConfig{
+		Modules:   modules,
+		Vhosts:    []string{"*"},
+		jwtSecret: secret,
+	})
 }
 
 func (n *Node) wsServerForPort(port int) *httpServer {
@@ -396,6 +484,7 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	n.httpAuth.stop()
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -434,6 +523,54 @@ func (n *Node) RegisterLifecycle(lifecycle Lifecycle) {
 	n.lifecycles = append(n.lifecycles, lifecycle)
 }
 
+// RequireLifecycle declares that the given registered Lifecycle requires the other
+// ones, which are started before it and stopped after it. Lifecycles not depending
+// on each other are started in the order of registration. The required lifecycles
+// may be registered later, but must be registered before the node is started.
+func (n *Node) RequireLifecycle(lifecycle Lifecycle, requires ...Lifecycle) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.state != initializingState {
+		panic("can't require lifecycle on running/stopped node")
+	}
+	if !containsLifecycle(n.lifecycles, lifecycle) {
+		panic(fmt.Sprintf("attempt to require lifecycles for unregistered lifecycle %T", lifecycle))
+	}
+	for _, dep := range requires {
+		if dep == lifecycle {
+			panic(fmt.Sprintf("lifecycle %T can't require itself", lifecycle))
+		}
+		if !containsLifecycle(n.requires[lifecycle], dep) {
+			n.requires[lifecycle] = append(n.requires[lifecycle], dep)
+		}
+	}
+}
+
+// Lifecycles returns the dependency graph of the registered lifecycles, in startup
+// order once the node is started, along with the time each one took to start.
+func (n *Node) Lifecycles() []LifecycleInfo {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	infos := make([]LifecycleInfo, len(n.lifecycles))
+	for i, lifecycle := range n.lifecycles {
+		infos[i] = LifecycleInfo{
+			Name:     fmt.Sprintf("%T", lifecycle),
+			Requires: []string{},
+			Order:    -1,
+		}
+		for _, dep := range n.requires[lifecycle] {
+			infos[i].Requires = append(infos[i].Requires, fmt.Sprintf("%T", dep))
+		}
+		if elapsed, ok := n.startTimes[lifecycle]; ok {
+			infos[i].Order = i
+			infos[i].Elapsed = common.PrettyDuration(elapsed)
+		}
+	}
+	return infos
+}
+
 // RegisterProtocols adds backend's protocols to the node's p2p server.
 func (n *Node) RegisterProtocols(protocols []p2p.Protocol) {
 	n.lock.Lock()
@@ -530,6 +667,11 @@ func (n *Node) HTTPEndpoint() string {
 	return "http://" + n.http.listenAddr()
 }
 
+// AuthEndpoint returns the URL of the JWT authenticated HTTP server.
+func (n *Node) AuthEndpoint() string {
+	return "http://" + n.httpAuth.listenAddr()
+}
+
 // WSEndpoint returns the current JSON-RPC over WebSocket endpoint.
 func (n *Node) WSEndpoint() string {
 	if n.http.wsAllowed() {
@@ -559,7 +701,10 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 	if n.config.DataDir == "" {
 		db = rawdb.NewMemoryDatabase()
 	} else {
-		db, err = rawdb.NewLevelDBDatabase(n.ResolvePath(name), cache, handles, namespace, readonly)
+		var kvdb ethdb.KeyValueStore
+		if kvdb, err = rawdb.NewKeyValueStore(n.config.DBEngine, n.ResolvePath(name), cache, handles, namespace, readonly); err == nil {
+			db = rawdb.NewDatabase(kvdb)
+		}
 	}
 
 	if err == nil {
@@ -571,7 +716,8 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
 // creates one if no previous can be found) from within the node's data directory,
 // also attaching a chain freezer to it that moves ancient chain data from the
-// database to immutable append-only files. If the node is an ephemeral one, a
+// database to immutable append-only files, or to an S3-compatible object store
+// if the freezer location is an s3:// URL. If the node is an ephemeral one, a
 // memory database is returned.
 func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string, readonly bool) (ethdb.Database, error) {
 	n.lock.Lock()
@@ -586,13 +732,25 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 		db = rawdb.NewMemoryDatabase()
 	} else {
 		root := n.ResolvePath(name)
+		var kvdb ethdb.KeyValueStore
+		if kvdb, err = rawdb.NewKeyValueStore(n.config.DBEngine, root, cache, handles, namespace, readonly); err != nil {
+			return nil, err
+		}
 		switch {
+		case remotefreezer.IsURL(freezer):
+			db, err = rawdb.NewDatabaseWithRemoteFreezer(kvdb, freezer, filepath.Join(root, "ancient-cache"), namespace, readonly)
 		case freezer == "":
 			freezer = filepath.Join(root, "ancient")
-		case !filepath.IsAbs(freezer):
-			freezer = n.ResolvePath(freezer)
+			fallthrough
+		default:
+			if !filepath.IsAbs(freezer) {
+				freezer = n.ResolvePath(freezer)
+			}
+			db, err = rawdb.NewDatabaseWithFreezer(kvdb, freezer, namespace, readonly)
+		}
+		if err != nil {
+			kvdb.Close()
 		}
-		db, err = rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace, readonly)
 	}
 
 	if err == nil {
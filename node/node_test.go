@@ -17,6 +17,7 @@
 package node
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,7 +28,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/crypto"
 	"github.com/acent/go-acent/ethdb"
 	"github.com/acent/go-acent/p2p"
@@ -264,6 +267,88 @@ func TestLifecycleLifeCycle(t *testing.T) {
 	}
 }
 
+// Tests that Lifecycles are started after the ones they require and stopped before
+// them, independent ones keeping their registration order.
+func TestLifecycleRequirements(t *testing.T) {
+	stack, _ := New(testNodeConfig())
+	defer stack.Close()
+
+	var started, stopped []string
+	newService := func(id string) Lifecycle {
+		return &InstrumentedService{
+			startHook: func() { started = append(started, id) },
+			stopHook:  func() { stopped = append(stopped, id) },
+		}
+	}
+	a, b, c, d := newService("A"), newService("B"), newService("C"), newService("D")
+	stack.RegisterLifecycle(a)
+	stack.RegisterLifecycle(b)
+	stack.RegisterLifecycle(c)
+	stack.RequireLifecycle(a, c)
+	stack.RequireLifecycle(c, d) // registered after declaring the requirement
+	stack.RegisterLifecycle(d)
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if want := []string{"B", "D", "C", "A"}; !reflect.DeepEqual(started, want) {
+		t.Fatalf("startup order mismatch: have %v, want %v", started, want)
+	}
+	infos := stack.Lifecycles()
+	if len(infos) != 4 {
+		t.Fatalf("lifecycle info count mismatch: have %d, want %d", len(infos), 4)
+	}
+	for i, info := range infos {
+		if info.Order != i {
+			t.Errorf("lifecycle %d: order mismatch: have %d, want %d", i, info.Order, i)
+		}
+	}
+	if want := []string{"*node.InstrumentedService"}; !reflect.DeepEqual(infos[3].Requires, want) {
+		t.Errorf("requirements mismatch: have %v, want %v", infos[3].Requires, want)
+	}
+	if err := stack.Close(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	if want := []string{"A", "C", "D", "B"}; !reflect.DeepEqual(stopped, want) {
+		t.Fatalf("shutdown order mismatch: have %v, want %v", stopped, want)
+	}
+}
+
+// Tests that a node with circular or unregistered Lifecycle requirements refuses
+// to start without starting any of them.
+func TestLifecycleRequirementsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		require func(stack *Node, a, b Lifecycle)
+	}{
+		{"circular", func(stack *Node, a, b Lifecycle) {
+			stack.RequireLifecycle(a, b)
+			stack.RequireLifecycle(b, a)
+		}},
+		{"unregistered", func(stack *Node, a, b Lifecycle) {
+			stack.RequireLifecycle(a, new(InstrumentedService))
+		}},
+	}
+	for _, tt := range tests {
+		stack, _ := New(testNodeConfig())
+
+		started := false
+		a := &InstrumentedService{startHook: func() { started = true }}
+		b := &InstrumentedService{startHook: func() { started = true }}
+		stack.RegisterLifecycle(a)
+		stack.RegisterLifecycle(b)
+		tt.require(stack, a, b)
+
+		if err := stack.Start(); err == nil {
+			t.Errorf("%s: node started", tt.name)
+		}
+		if started {
+			t.Errorf("%s: lifecycle started", tt.name)
+		}
+		stack.Close()
+	}
+}
+
 // Tests that if a Lifecycle fails to start, all others started before it will be
 // shut down.
 func TestLifecycleStartupError(t *testing.T) {
@@ -591,6 +676,72 @@ func (test rpcPrefixTest) check(t *testing.T, node *Node) {
 	}
 }
 
+// testAuthService is an API service only exposed on the authenticated endpoint.
+type testAuthService struct{}
+
+func (s *testAuthService) Ping() string { return "pong" }
+
+// rpcModules performs an rpc_modules request with the given extra headers and
+// returns the modules exposed on the endpoint.
+func rpcModules(t *testing.T, url string, extraHeaders ...string) map[string]string {
+	t.Helper()
+
+	resp := rpcRequest(t, url, extraHeaders...)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var result struct {
+		Result map[string]string `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return result.Result
+}
+
+// Tests that authenticated APIs are only exposed on the JWT authenticated
+// endpoint, and that requests to it must carry a valid token.
+func TestAuthenticatedAPIs(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	node, err := New(&Config{
+		DataDir:  datadir,
+		HTTPHost: "127.0.0.1",
+		AuthAddr: "127.0.0.1",
+		P2P:      p2p.Config{PrivateKey: testNodeKey},
+	})
+	if err != nil {
+		t.Fatalf("could not create a new node: %v", err)
+	}
+	node.RegisterAPIs([]rpc.API{{Namespace: "engine", Service: new(testAuthService), Authenticated: true}})
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	if _, ok := rpcModules(t, node.HTTPEndpoint())["engine"]; ok {
+		t.Error("authenticated API exposed on the HTTP endpoint")
+	}
+	// Requests to the authenticated endpoint must carry a token signed with the
+	// secret generated into the datadir
+	resp := rpcRequest(t, node.AuthEndpoint())
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	hex, err := ioutil.ReadFile(node.ResolvePath(datadirJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to read JWT secret: %v", err)
+	}
+	token := rpc.NewJWTToken(common.FromHex(string(hex)), time.Now())
+	if _, ok := rpcModules(t, node.AuthEndpoint(), "Authorization", "Bearer "+token)["engine"]; !ok {
+		t.Error("authenticated API missing on the authenticated endpoint")
+	}
+}
+
 func createNode(t *testing.T, httpPort, wsPort int) *Node {
 	conf := &Config{
 		HTTPHost: "127.0.0.1",
@@ -39,19 +39,35 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
+	prefix             string     // path prefix on which to mount http handler
+	apiKeys            *apiKeySet // API keys required on requests, nil if open
+	gateway            *gateway   // call limits of the gateway mode, nil if unlimited
+	jwtSecret          []byte     // secret authenticating the requests, nil if open
+	batchParallelism   int        // batch calls served concurrently, sequential if below 2
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
 	Origins []string
 	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	prefix  string     // path prefix on which to mount ws handler
+	apiKeys *apiKeySet // API keys required on requests, nil if open
+	gateway *gateway   // call limits of the gateway mode, nil if unlimited
+
+	batchParallelism int // batch calls served concurrently, sequential if below 2
 }
 
 type rpcHandler struct {
 	http.Handler
-	server *rpc.Server
+	servers []*rpc.Server  // RPC servers behind the handler, one per API key if enabled
+	keys    *apiKeyHandler // API key authentication of the handlers served along, nil if open
+}
+
+// stop shuts down the RPC servers behind the handler.
+func (h *rpcHandler) stop() {
+	for _, srv := range h.servers {
+		srv.Stop()
+	}
 }
 
 type httpServer struct {
@@ -198,6 +214,9 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// These are made available when RPC is enabled.
 		muxHandler, pattern := h.mux.Handler(r)
 		if pattern != "" {
+			if rpc.keys != nil && rpc.keys.authenticate(w, r) == nil {
+				return
+			}
 			muxHandler.ServeHTTP(w, r)
 			return
 		}
@@ -254,11 +273,11 @@ func (h *httpServer) doStop() {
 	wsHandler := h.httpHandler.Load().(*rpcHandler)
 	if httpHandler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		httpHandler.server.Stop()
+		httpHandler.stop()
 	}
 	if wsHandler != nil {
 		h.wsHandler.Store((*rpcHandler)(nil))
-		wsHandler.server.Stop()
+		wsHandler.stop()
 	}
 	h.server.Shutdown(context.Background())
 	h.listener.Close()
@@ -278,15 +297,41 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 		return fmt.Errorf("JSON-RPC over HTTP is already enabled")
 	}
 
-	// Create RPC server and handler.
-	srv := rpc.NewServer()
-	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
-		return err
+	// Create RPC server and handler, or one server per API key if enabled.
+	var (
+		handler http.Handler
+		servers []*rpc.Server
+		keys    *apiKeyHandler
+	)
+	if config.apiKeys != nil {
+		keyed, keyedServers, err := newAPIKeyHandler(config.apiKeys, apiKeyEndpointHTTP, apis, config.Modules, func(srv *rpc.Server) http.Handler {
+			return srv
+		})
+		if err != nil {
+			return err
+		}
+		handler, servers, keys = keyed, keyedServers, keyed
+	} else {
+		srv := rpc.NewServer()
+		if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
+			return err
+		}
+		handler, servers = srv, []*rpc.Server{srv}
+	}
+	for _, srv := range servers {
+		if config.gateway != nil {
+			srv.SetCallLimiter(config.gateway.limitCall)
+		}
+		srv.SetBatchParallelism(config.batchParallelism)
+	}
+	if config.jwtSecret != nil {
+		handler = rpc.NewJWTHandler(config.jwtSecret, handler)
 	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
-		server:  srv,
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts),
+		servers: servers,
+		keys:    keys,
 	})
 	return nil
 }
@@ -296,7 +341,7 @@ func (h *httpServer) disableRPC() bool {
 	handler := h.httpHandler.Load().(*rpcHandler)
 	if handler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		handler.server.Stop()
+		handler.stop()
 	}
 	return handler != nil
 }
@@ -310,15 +355,36 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 		return fmt.Errorf("JSON-RPC over WebSocket is already enabled")
 	}
 
-	// Create RPC server and handler.
-	srv := rpc.NewServer()
-	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
-		return err
+	// Create RPC server and handler, or one server per API key if enabled.
+	var (
+		handler http.Handler
+		servers []*rpc.Server
+	)
+	if config.apiKeys != nil {
+		keyed, keyedServers, err := newAPIKeyHandler(config.apiKeys, apiKeyEndpointWS, apis, config.Modules, func(srv *rpc.Server) http.Handler {
+			return srv.WebsocketHandler(config.Origins)
+		})
+		if err != nil {
+			return err
+		}
+		handler, servers = keyed, keyedServers
+	} else {
+		srv := rpc.NewServer()
+		if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
+			return err
+		}
+		handler, servers = srv.WebsocketHandler(config.Origins), []*rpc.Server{srv}
+	}
+	for _, srv := range servers {
+		if config.gateway != nil {
+			srv.SetCallLimiter(config.gateway.limitCall)
+		}
+		srv.SetBatchParallelism(config.batchParallelism)
 	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
-		server:  srv,
+		Handler: handler,
+		servers: servers,
 	})
 	return nil
 }
@@ -340,7 +406,7 @@ func (h *httpServer) disableWS() bool {
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil {
 		h.wsHandler.Store((*rpcHandler)(nil))
-		ws.server.Stop()
+		ws.stop()
 	}
 	return ws != nil
 }
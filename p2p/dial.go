@@ -78,6 +78,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errFiltered         = errors.New("rejected by peer filters")
 	errNoPort           = errors.New("node does not provide TCP port")
 )
 
@@ -134,6 +135,8 @@ type dialConfig struct {
 	maxDialPeers   int              // maximum number of dialed peers
 	maxActiveDials int              // maximum number of active dials
 	netRestrict    *netutil.Netlist // IP whitelist, disabled if nil
+	filters        *peerFilters     // Node allow and deny lists, disabled if nil
+	quic           bool             // Whether nodes with only a QUIC port can be dialed
 	resolver       nodeResolver
 	dialer         NodeDialer
 	log            log.Logger
@@ -389,7 +392,7 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if n.ID() == d.self {
 		return errSelf
 	}
-	if n.IP() != nil && n.TCP() == 0 {
+	if n.IP() != nil && n.TCP() == 0 && (!d.quic || n.QUIC() == 0) {
 		// This check can trigger if a non-TCP node is found
 		// by discovery. If there is no IP, the node is a static
 		// node and the actual endpoint will be resolved later in dialTask.
@@ -404,6 +407,10 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.netRestrict != nil && !d.netRestrict.Contains(n.IP()) {
 		return errNotWhitelisted
 	}
+	if n.IP() != nil && d.filters.check(n.ID(), n.IP()) != nil {
+		// Nodes without IP are resolved later, and checked after the handshake.
+		return errFiltered
+	}
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
@@ -161,6 +161,20 @@ func (t *UDPv4) Self() *enode.Node {
 	return t.localNode.Node()
 }
 
+// AllNodes returns all the nodes stored in the local table.
+func (t *UDPv4) AllNodes() []*enode.Node {
+	t.tab.mutex.Lock()
+	defer t.tab.mutex.Unlock()
+	nodes := make([]*enode.Node, 0)
+
+	for _, b := range &t.tab.buckets {
+		for _, n := range b.entries {
+			nodes = append(nodes, unwrapNode(n))
+		}
+	}
+	return nodes
+}
+
 // Close shuts down the socket and aborts any running queries.
 func (t *UDPv4) Close() {
 	t.closeOnce.Do(func() {
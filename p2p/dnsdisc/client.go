@@ -116,6 +116,22 @@ func (c *Client) SyncTree(url string) (*Tree, error) {
 	return t, nil
 }
 
+// PublishedSeq resolves the root of the tree at the given URL and returns its
+// sequence number. The signature of the root is verified.
+func (c *Client) PublishedSeq(url string) (uint, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return 0, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	root, err := c.resolveRoot(ctx, le)
+	if err != nil {
+		return 0, err
+	}
+	return root.seq, nil
+}
+
 // NewIterator creates an iterator that visits all nodes at the
 // given tree URLs.
 func (c *Client) NewIterator(urls ...string) (enode.Iterator, error) {
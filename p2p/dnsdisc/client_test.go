@@ -71,6 +71,24 @@ func TestClientSyncTree(t *testing.T) {
 	}
 }
 
+func TestClientPublishedSeq(t *testing.T) {
+	tree, url := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	c := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n")), Logger: testlog.Logger(t, log.LvlTrace)})
+
+	seq, err := c.PublishedSeq(url)
+	if err != nil {
+		t.Fatal("resolve error:", err)
+	}
+	if seq != tree.Seq() {
+		t.Errorf("wrong seq %d, want %d", seq, tree.Seq())
+	}
+	// The root must be signed by the key in the URL.
+	otherURL, _ := tree.Sign(testKey(nodesSeed2), "n")
+	if _, err := c.PublishedSeq(otherURL); err == nil {
+		t.Error("root with wrong signature accepted")
+	}
+}
+
 // In this test, syncing the tree fails because it contains an invalid ENR entry.
 func TestClientSyncTreeBadNode(t *testing.T) {
 	// var b strings.Builder
@@ -18,6 +18,7 @@ package enode
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -26,6 +27,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p/enr"
 	"github.com/acent/go-acent/p2p/netutil"
@@ -38,6 +40,22 @@ const (
 	iptrackContactWindow = 10 * time.Minute
 )
 
+// reservedKeys are the record entries maintained by the p2p stack itself, which
+// can't be registered as attributes.
+var reservedKeys = map[string]bool{
+	"id": true, "secp256k1": true,
+	"ip": true, "ip6": true,
+	"tcp": true, "tcp6": true,
+	"udp": true, "udp6": true,
+	"quic": true,
+}
+
+var (
+	errReservedKey   = errors.New("ENR key is reserved")
+	errKeyRegistered = errors.New("ENR key is registered by another owner")
+	errKeyMismatch   = errors.New("entry doesn't match the attribute key")
+)
+
 // LocalNode produces the signed node record of a local node, i.e. a node run in the
 // current process. Setting ENR entries via the Set method updates the record. A new version
 // of the record is signed on demand when the Node method is called.
@@ -51,8 +69,11 @@ type LocalNode struct {
 	mu        sync.Mutex
 	seq       uint64
 	entries   map[string]enr.Entry
+	attrs     map[string]string // owners of the registered attributes by key
 	endpoint4 lnEndpoint
 	endpoint6 lnEndpoint
+
+	feed event.Feed // new versions of the record
 }
 
 type lnEndpoint struct {
@@ -68,6 +89,7 @@ func NewLocalNode(db *DB, key *ecdsa.PrivateKey) *LocalNode {
 		db:      db,
 		key:     key,
 		entries: make(map[string]enr.Entry),
+		attrs:   make(map[string]string),
 		endpoint4: lnEndpoint{
 			track: netutil.NewIPTracker(iptrackWindow, iptrackContactWindow, iptrackMinStatements),
 		},
@@ -93,9 +115,21 @@ func (ln *LocalNode) Node() *Node {
 	}
 	// Record was invalidated, sign a new copy.
 	ln.mu.Lock()
-	defer ln.mu.Unlock()
-	ln.sign()
-	return ln.cur.Load().(*Node)
+	signed := ln.sign()
+	n = ln.cur.Load().(*Node)
+	ln.mu.Unlock()
+
+	if signed {
+		ln.feed.Send(n)
+	}
+	return n
+}
+
+// SubscribeRecords subscribes to the new versions of the local node record.
+// Changes of registered attributes are signed and sent right away, other
+// changes once the record is next requested.
+func (ln *LocalNode) SubscribeRecords(ch chan<- *Node) event.Subscription {
+	return ln.feed.Subscribe(ch)
 }
 
 // Seq returns the current sequence number of the local node record.
@@ -145,6 +179,86 @@ func (ln *LocalNode) delete(e enr.Entry) {
 	}
 }
 
+// Attribute is a custom entry of the local record registered by a service,
+// e.g. a capability advertisement of a subprotocol. Only the owner of an
+// attribute can change it through its handle.
+type Attribute struct {
+	ln    *LocalNode
+	key   string
+	owner string
+}
+
+// RegisterAttribute claims the key of the given entry for owner and puts the
+// entry into the local record. Registering a key again with the same owner
+// returns a handle to the existing attribute, updated with the entry.
+func (ln *LocalNode) RegisterAttribute(owner string, e enr.Entry) (*Attribute, error) {
+	key := e.ENRKey()
+	if reservedKeys[key] {
+		return nil, fmt.Errorf("%w: %q", errReservedKey, key)
+	}
+	ln.mu.Lock()
+	if cur, ok := ln.attrs[key]; ok && cur != owner {
+		ln.mu.Unlock()
+		return nil, fmt.Errorf("%w: %q owned by %q", errKeyRegistered, key, cur)
+	}
+	ln.attrs[key] = owner
+	ln.mu.Unlock()
+
+	a := &Attribute{ln: ln, key: key, owner: owner}
+	return a, a.Update(e)
+}
+
+// Attributes returns the owners of the registered attributes by key.
+func (ln *LocalNode) Attributes() map[string]string {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	owners := make(map[string]string, len(ln.attrs))
+	for key, owner := range ln.attrs {
+		owners[key] = owner
+	}
+	return owners
+}
+
+// Key returns the ENR key of the attribute.
+func (a *Attribute) Key() string {
+	return a.key
+}
+
+// Update replaces the value of the attribute. If it changed, a new version of
+// the record is signed and published to the record subscribers.
+func (a *Attribute) Update(e enr.Entry) error {
+	if e.ENRKey() != a.key {
+		return fmt.Errorf("%w: %q, want %q", errKeyMismatch, e.ENRKey(), a.key)
+	}
+	ln := a.ln
+	ln.mu.Lock()
+	if ln.attrs[a.key] != a.owner {
+		ln.mu.Unlock()
+		return fmt.Errorf("%w: %q", errKeyRegistered, a.key)
+	}
+	ln.set(e)
+	ln.mu.Unlock()
+
+	ln.Node() // sign and publish right away
+	return nil
+}
+
+// Unregister removes the attribute from the local record and releases its key.
+func (a *Attribute) Unregister() {
+	ln := a.ln
+	ln.mu.Lock()
+	if ln.attrs[a.key] != a.owner {
+		ln.mu.Unlock()
+		return
+	}
+	delete(ln.attrs, a.key)
+	ln.delete(enr.WithEntry(a.key, nil))
+	ln.mu.Unlock()
+
+	ln.Node() // sign and publish right away
+}
+
 func (ln *LocalNode) endpointForIP(ip net.IP) *lnEndpoint {
 	if ip.To4() != nil {
 		return &ln.endpoint4
@@ -262,9 +376,9 @@ func (ln *LocalNode) invalidate() {
 	ln.cur.Store((*Node)(nil))
 }
 
-func (ln *LocalNode) sign() {
+func (ln *LocalNode) sign() bool {
 	if n := ln.cur.Load().(*Node); n != nil {
-		return // no changes
+		return false // no changes
 	}
 
 	var r enr.Record
@@ -282,6 +396,7 @@ func (ln *LocalNode) sign() {
 	}
 	ln.cur.Store(n)
 	log.Info("New local node record", "seq", ln.seq, "id", n.ID(), "ip", n.IP(), "udp", n.UDP(), "tcp", n.TCP())
+	return true
 }
 
 func (ln *LocalNode) bumpSeq() {
@@ -17,6 +17,7 @@
 package enode
 
 import (
+	"errors"
 	"math/rand"
 	"net"
 	"testing"
@@ -120,3 +121,57 @@ func TestLocalNodeEndpoint(t *testing.T) {
 	assert.Equal(t, fallback.Port, ln.Node().UDP())
 	assert.Equal(t, uint64(4), ln.Node().Seq())
 }
+
+func TestLocalNodeAttributes(t *testing.T) {
+	ln, db := newLocalNodeForTesting()
+	defer db.Close()
+
+	records := make(chan *Node, 10)
+	sub := ln.SubscribeRecords(records)
+	defer sub.Unsubscribe()
+
+	// Registering an attribute signs and publishes a new record right away.
+	attr, err := ln.RegisterAttribute("svc", enr.WithEntry("svc", uint(1)))
+	if err != nil {
+		t.Fatal("can't register attribute:", err)
+	}
+	var x uint
+	if n := <-records; n.Seq() != ln.Seq() || n.Load(enr.WithEntry("svc", &x)) != nil || x != 1 {
+		t.Fatalf("wrong record published: seq %d, svc %d", n.Seq(), x)
+	}
+	// Unchanged values don't produce a new record.
+	seq := ln.Seq()
+	if err := attr.Update(enr.WithEntry("svc", uint(1))); err != nil {
+		t.Fatal("update failed:", err)
+	}
+	if err := attr.Update(enr.WithEntry("svc", uint(2))); err != nil {
+		t.Fatal("update failed:", err)
+	}
+	if n := <-records; n.Seq() != seq+1 || n.Load(enr.WithEntry("svc", &x)) != nil || x != 2 {
+		t.Fatalf("wrong record published: seq %d (want %d), svc %d", n.Seq(), seq+1, x)
+	}
+	if err := attr.Update(enr.WithEntry("other", uint(1))); !errors.Is(err, errKeyMismatch) {
+		t.Fatal("wrong error for update with a different key:", err)
+	}
+
+	// Keys are owned by a single service and reserved keys can't be claimed.
+	if _, err := ln.RegisterAttribute("other", enr.WithEntry("svc", uint(3))); !errors.Is(err, errKeyRegistered) {
+		t.Fatal("wrong error for key owned by another service:", err)
+	}
+	if _, err := ln.RegisterAttribute("svc", enr.TCP(30303)); !errors.Is(err, errReservedKey) {
+		t.Fatal("wrong error for reserved key:", err)
+	}
+	assert.Equal(t, map[string]string{"svc": "svc"}, ln.Attributes())
+
+	// After unregistering, the entry is gone and the key can be claimed again.
+	attr.Unregister()
+	if n := <-records; n.Load(enr.WithEntry("svc", &x)) == nil {
+		t.Fatal("attribute still in record after unregistering")
+	}
+	if err := attr.Update(enr.WithEntry("svc", uint(4))); !errors.Is(err, errKeyRegistered) {
+		t.Fatal("wrong error for update after unregistering:", err)
+	}
+	if _, err := ln.RegisterAttribute("other", enr.WithEntry("svc", uint(5))); err != nil {
+		t.Fatal("can't register released key:", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -73,6 +74,9 @@ type DB struct {
 	lvl    *leveldb.DB   // Interface to the database itself
 	runner sync.Once     // Ensures we can start at most one expirer
 	quit   chan struct{} // Channel to signal the expiring thread to stop
+
+	limit int        // Maximum number of nodes retained, zero if unlimited
+	lock  sync.Mutex // Lock protecting the limit and serializing pruning
 }
 
 // OpenDB opens a node database for storing and retrieving infos about known peers in the
@@ -280,6 +284,19 @@ func (db *DB) Resolve(n *Node) *Node {
 	return db.Node(n.ID())
 }
 
+// SetNodeLimit sets the maximum number of nodes retained in the database. Beyond
+// the limit, the least recently seen nodes are evicted during the periodic
+// cleanup. Zero or a negative value means unlimited.
+func (db *DB) SetNodeLimit(limit int) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if limit < 0 {
+		limit = 0
+	}
+	db.limit = limit
+}
+
 // DeleteNode deletes all information associated with a node.
 func (db *DB) DeleteNode(id ID) {
 	deleteRange(db.lvl, nodeKey(id))
@@ -314,7 +331,7 @@ func (db *DB) expirer() {
 	for {
 		select {
 		case <-tick.C:
-			db.expireNodes()
+			db.prune()
 		case <-db.quit:
 			return
 		}
@@ -360,6 +377,171 @@ func (db *DB) expireNodes() {
 	}
 }
 
+// prune drops the expired nodes, then evicts the least recently seen ones beyond
+// the node limit.
+func (db *DB) prune() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.expireNodes()
+	db.evictNodes()
+}
+
+// evictNodes deletes all information about the least recently seen nodes beyond
+// the node limit, returning the number of nodes evicted. The caller must hold
+// the lock.
+func (db *DB) evictNodes() int {
+	if db.limit == 0 {
+		return 0
+	}
+	nodes := db.summarizeNodes()
+	if len(nodes) <= db.limit {
+		return 0
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].lastSeen() < nodes[j].lastSeen()
+	})
+	evict := nodes[:len(nodes)-db.limit]
+	for _, node := range evict {
+		deleteRange(db.lvl, append([]byte(dbNodePrefix), node.id[:]...))
+	}
+	return len(evict)
+}
+
+// Compact drops the expired nodes and the ones beyond the node limit, then
+// compacts the underlying storage to reclaim the space of deleted entries.
+func (db *DB) Compact() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.expireNodes()
+	db.evictNodes()
+	return db.lvl.CompactRange(util.Range{})
+}
+
+// nodeSummary is the metadata of a node aggregated over all its endpoints.
+type nodeSummary struct {
+	id       ID
+	record   bool  // Whether the node record is stored
+	entries  int   // Number of database entries about the node
+	lastPing int64 // Unix time of the latest ping received, zero if never
+	lastPong int64 // Unix time of the latest pong received, zero if never
+	fails    int   // Fewest findnode failures of any endpoint, -1 if not tracked
+}
+
+// lastSeen returns the unix time the node was last heard from.
+func (n *nodeSummary) lastSeen() int64 {
+	if n.lastPing > n.lastPong {
+		return n.lastPing
+	}
+	return n.lastPong
+}
+
+// summarizeNodes iterates over the database, aggregating the metadata of every
+// node with any entries.
+func (db *DB) summarizeNodes() []*nodeSummary {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbNodePrefix)), nil)
+	defer it.Release()
+
+	var (
+		nodes []*nodeSummary
+		node  *nodeSummary
+	)
+	for it.Next() {
+		id, ip, field := splitNodeItemKey(it.Key())
+		if node == nil || node.id != id {
+			node = &nodeSummary{id: id, fails: -1}
+			nodes = append(nodes, node)
+		}
+		node.entries++
+
+		switch {
+		case ip == nil:
+			node.record = true
+		case field == dbNodePing:
+			if time, _ := binary.Varint(it.Value()); time > node.lastPing {
+				node.lastPing = time
+			}
+		case field == dbNodePong:
+			if time, _ := binary.Varint(it.Value()); time > node.lastPong {
+				node.lastPong = time
+			}
+		case field == dbNodeFindFails:
+			if fails, _ := binary.Varint(it.Value()); node.fails < 0 || int(fails) < node.fails {
+				node.fails = int(fails)
+			}
+		}
+	}
+	return nodes
+}
+
+// DBStats is a summary of the contents of the node database.
+type DBStats struct {
+	Nodes   int    `json:"nodes"`   // Number of nodes with any entries
+	Records int    `json:"records"` // Number of nodes with a stored record
+	Entries int    `json:"entries"` // Number of entries, including per endpoint metadata
+	Size    uint64 `json:"size"`    // Approximate disk usage of the entries in bytes
+	Limit   int    `json:"limit"`   // Maximum number of nodes retained, zero if unlimited
+
+	// Ages counts the nodes by the time since their last pong, in the buckets
+	// "<1h", "1h-6h", "6h-24h", ">24h" and "never".
+	Ages map[string]int `json:"ages"`
+
+	// Liveness counts the nodes by their liveness: "live" if recently bonded
+	// without findnode failures, "unreliable" if recently bonded with failures,
+	// "stale" if awaiting expiration and "unverified" if never bonded.
+	Liveness map[string]int `json:"liveness"`
+}
+
+// Stats iterates over the database, summarizing its contents.
+func (db *DB) Stats() DBStats {
+	db.lock.Lock()
+	limit := db.limit
+	db.lock.Unlock()
+
+	stats := DBStats{
+		Limit:    limit,
+		Ages:     make(map[string]int),
+		Liveness: make(map[string]int),
+	}
+	now := time.Now()
+	for _, node := range db.summarizeNodes() {
+		stats.Nodes++
+		stats.Entries += node.entries
+		if node.record {
+			stats.Records++
+		}
+		if node.lastPong == 0 {
+			stats.Ages["never"]++
+			stats.Liveness["unverified"]++
+			continue
+		}
+		age := now.Sub(time.Unix(node.lastPong, 0))
+		switch {
+		case age < time.Hour:
+			stats.Ages["<1h"]++
+		case age < 6*time.Hour:
+			stats.Ages["1h-6h"]++
+		case age < 24*time.Hour:
+			stats.Ages["6h-24h"]++
+		default:
+			stats.Ages[">24h"]++
+		}
+		switch {
+		case age >= dbNodeExpiration:
+			stats.Liveness["stale"]++
+		case node.fails > 0:
+			stats.Liveness["unreliable"]++
+		default:
+			stats.Liveness["live"]++
+		}
+	}
+	if sizes, err := db.lvl.SizeOf([]util.Range{*util.BytesPrefix([]byte(dbNodePrefix))}); err == nil {
+		stats.Size = uint64(sizes.Sum())
+	}
+	return stats
+}
+
 // LastPingReceived retrieves the time of the last ping packet received from
 // a remote node.
 func (db *DB) LastPingReceived(id ID, ip net.IP) time.Time {
@@ -473,3 +473,54 @@ func TestDBExpireV5(t *testing.T) {
 	db.UpdateFindFailsV5(ID{}, ip, 4)
 	db.expireNodes()
 }
+
+// This test checks that the database statistics summarize the nodes by age and
+// liveness, and that pruning evicts the least recently seen nodes beyond the
+// limit.
+func TestDBStatsAndEviction(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		ip   = net.IP{127, 0, 0, 1}
+		now  = time.Now()
+		ages = []time.Duration{10 * time.Minute, 2 * time.Hour, 12 * time.Hour, 30 * time.Hour, 0}
+	)
+	for i, age := range ages {
+		node := testNode(uint64(i+1), 1)
+		if err := db.UpdateNode(node); err != nil {
+			t.Fatalf("node %d: failed to insert: %v", i, err)
+		}
+		if age != 0 {
+			db.UpdateLastPongReceived(node.ID(), ip, now.Add(-age))
+		}
+	}
+	db.UpdateFindFails(testNode(2, 1).ID(), ip, 3)
+
+	stats := db.Stats()
+	if stats.Nodes != 5 || stats.Records != 5 || stats.Entries != 15 {
+		t.Errorf("counts mismatch: have %d nodes, %d records, %d entries, want 5, 5, 15", stats.Nodes, stats.Records, stats.Entries)
+	}
+	if want := map[string]int{"<1h": 1, "1h-6h": 1, "6h-24h": 1, ">24h": 1, "never": 1}; !reflect.DeepEqual(stats.Ages, want) {
+		t.Errorf("ages mismatch: have %v, want %v", stats.Ages, want)
+	}
+	if want := map[string]int{"live": 2, "unreliable": 1, "stale": 1, "unverified": 1}; !reflect.DeepEqual(stats.Liveness, want) {
+		t.Errorf("liveness mismatch: have %v, want %v", stats.Liveness, want)
+	}
+	// Prune down to two nodes, expecting the expired and least recent ones gone
+	db.SetNodeLimit(2)
+	db.prune()
+
+	for i := range ages {
+		id := testNode(uint64(i+1), 1).ID()
+		if present, want := db.Node(id) != nil, i < 2; present != want {
+			t.Errorf("node %d: presence mismatch: have %v, want %v", i, present, want)
+		}
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+	if stats := db.Stats(); stats.Nodes != 2 || stats.Limit != 2 {
+		t.Errorf("stats mismatch after pruning: have %d nodes, limit %d, want 2, 2", stats.Nodes, stats.Limit)
+	}
+}
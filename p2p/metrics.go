@@ -35,6 +35,15 @@ var (
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/dials", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter(egressMeterName, nil)
 	activePeerGauge     = metrics.NewRegisteredGauge("p2p/peers", nil)
+
+	// oversizedMsgMeter counts the messages rejected for exceeding the size limit
+	// of the connection or of their protocol.
+	oversizedMsgMeter = metrics.NewRegisteredMeter("p2p/ingress/oversized", nil)
+
+	// ingressMsgCounter and egressMsgCounter count the subprotocol messages
+	// received and sent, labeled by protocol (e.g. eth/66).
+	ingressMsgCounter = metrics.NewRegisteredLabeledCounter("p2p/messages/ingress", nil, "protocol")
+	egressMsgCounter  = metrics.NewRegisteredLabeledCounter("p2p/messages/egress", nil, "protocol")
 )
 
 // meteredConn is a wrapper around a net.Conn that meters both the
@@ -0,0 +1,204 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/log"
+)
+
+const (
+	mapTimeout          = 10 * time.Minute // Lifetime requested for mappings
+	mapRetryMin         = 5 * time.Second  // Delay before retrying a failed renewal
+	mapRetryMax         = 5 * time.Minute  // Maximum delay between retries
+	mapFallbackFailures = 3                // Consecutive failures before switching mechanism
+)
+
+// States of a port mapping.
+const (
+	MappingPending = "pending" // Not mapped yet
+	MappingActive  = "mapped"  // Mapped and renewed in time
+	MappingStale   = "stale"   // Renewal failing, the last lease hasn't expired yet
+	MappingFailed  = "failed"  // Not mapped, or the last lease has expired
+)
+
+// fallbacker is implemented by mechanisms able to switch to another
+// mechanism when the one in use keeps failing.
+type fallbacker interface {
+	fallback() bool
+}
+
+// MappingStatus is the health report of a port mapping.
+type MappingStatus struct {
+	Protocol     string     `json:"protocol"`
+	Name         string     `json:"name"`
+	ExternalPort int        `json:"externalPort"`
+	InternalPort int        `json:"internalPort"`
+	Mechanism    string     `json:"mechanism"`
+	State        string     `json:"state"`
+	ExternalIP   string     `json:"externalIP,omitempty"`
+	LastRenewal  *time.Time `json:"lastRenewal,omitempty"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+	Failures     int        `json:"failures"` // Consecutive failed renewals
+	Error        string     `json:"error,omitempty"`
+}
+
+// Mapping maintains a port mapping on a NAT mechanism. The mapping is renewed
+// halfway through its lifetime, failed renewals are retried with backoff and,
+// for auto-discovered mechanisms, repeated failures switch to another
+// mechanism.
+type Mapping struct {
+	iface            Interface
+	protocol, name   string
+	extport, intport int
+	clock            mclock.Clock
+	log              log.Logger
+
+	mu       sync.Mutex
+	state    string
+	extIP    net.IP
+	renewed  mclock.AbsTime // Time of the last successful renewal
+	expires  mclock.AbsTime // Expiry of the last lease, zero if never mapped
+	failures int
+	err      error
+}
+
+// NewMapping creates a mapping of the given ports on m. The mapping is only
+// added once Run is called.
+func NewMapping(m Interface, protocol string, extport, intport int, name string) *Mapping {
+	return &Mapping{
+		iface:    m,
+		protocol: protocol,
+		name:     name,
+		extport:  extport,
+		intport:  intport,
+		clock:    mclock.System{},
+		log:      log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m),
+		state:    MappingPending,
+	}
+}
+
+// Run adds the mapping and keeps it alive until quit is closed, deleting it
+// afterwards. This function is typically invoked in its own goroutine.
+func (m *Mapping) Run(quit <-chan struct{}) {
+	refresh := m.clock.NewTimer(m.renew())
+	defer func() {
+		refresh.Stop()
+		m.log.Debug("Deleting port mapping")
+		m.iface.DeleteMapping(m.protocol, m.extport, m.intport)
+	}()
+	for {
+		select {
+		case _, ok := <-quit:
+			if !ok {
+				return
+			}
+		case <-refresh.C():
+			refresh.Reset(m.renew())
+		}
+	}
+}
+
+// renew adds or refreshes the mapping, returning the delay until the next
+// attempt.
+func (m *Mapping) renew() time.Duration {
+	err := m.iface.AddMapping(m.protocol, m.extport, m.intport, m.name, mapTimeout)
+	if err == nil {
+		ip, ipErr := m.iface.ExternalIP()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.state != MappingActive {
+			m.log.Info("Mapped network port")
+		} else {
+			m.log.Trace("Refreshed port mapping")
+		}
+		if ipErr == nil {
+			if m.extIP != nil && !m.extIP.Equal(ip) {
+				m.log.Warn("External IP of port mapping changed", "old", m.extIP, "new", ip)
+			}
+			m.extIP = ip
+		}
+		now := m.clock.Now()
+		m.state, m.renewed, m.expires = MappingActive, now, now.Add(mapTimeout)
+		m.failures, m.err = 0, nil
+		return mapTimeout / 2
+	}
+
+	m.mu.Lock()
+	m.failures, m.err = m.failures+1, err
+	if m.expires != 0 && m.clock.Now() < m.expires {
+		m.state = MappingStale
+	} else {
+		m.state = MappingFailed
+	}
+	failures := m.failures
+	m.log.Debug("Couldn't add port mapping", "state", m.state, "failures", failures, "err", err)
+	m.mu.Unlock()
+
+	// Switch to another mechanism if this one keeps failing, retrying at once.
+	if fb, ok := m.iface.(fallbacker); ok && failures%mapFallbackFailures == 0 {
+		if fb.fallback() {
+			return 0
+		}
+	}
+	retry := mapRetryMin
+	for i := 1; i < failures && retry < mapRetryMax; i++ {
+		retry *= 2
+	}
+	if retry > mapRetryMax {
+		retry = mapRetryMax
+	}
+	return retry
+}
+
+// Status returns the current health of the mapping.
+func (m *Mapping) Status() MappingStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := MappingStatus{
+		Protocol:     m.protocol,
+		Name:         m.name,
+		ExternalPort: m.extport,
+		InternalPort: m.intport,
+		Mechanism:    m.iface.String(),
+		State:        m.state,
+		Failures:     m.failures,
+	}
+	if m.state == MappingStale && m.clock.Now() >= m.expires {
+		status.State = MappingFailed
+	}
+	if m.extIP != nil {
+		status.ExternalIP = m.extIP.String()
+	}
+	if m.expires != 0 {
+		now := m.clock.Now()
+		renewed := time.Now().Add(m.renewed.Sub(now))
+		expires := time.Now().Add(m.expires.Sub(now))
+		status.LastRenewal, status.Expiry = &renewed, &expires
+	}
+	if m.err != nil {
+		status.Error = m.err.Error()
+	}
+	return status
+}
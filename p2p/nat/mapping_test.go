@@ -0,0 +1,165 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+)
+
+// fakeNAT is a port mapping mechanism whose mappings can be made to fail.
+type fakeNAT struct {
+	name string
+
+	mu      sync.Mutex
+	fail    bool
+	ip      net.IP
+	adds    int
+	deletes int
+}
+
+func (n *fakeNAT) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.fail {
+		return errors.New("mapping failed")
+	}
+	n.adds++
+	return nil
+}
+
+func (n *fakeNAT) DeleteMapping(protocol string, extport, intport int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.deletes++
+	return nil
+}
+
+func (n *fakeNAT) ExternalIP() (net.IP, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ip, nil
+}
+
+func (n *fakeNAT) String() string { return n.name }
+
+func (n *fakeNAT) set(fail bool, ip net.IP) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.fail, n.ip = fail, ip
+}
+
+func (n *fakeNAT) counts() (adds, deletes int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.adds, n.deletes
+}
+
+// startMapping runs a mapping on the simulated clock until the returned
+// function is called.
+func startMapping(m Interface, clock *mclock.Simulated) (*Mapping, func()) {
+	mapping := NewMapping(m, "tcp", 30303, 30303, "test")
+	mapping.clock = clock
+
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		mapping.Run(quit)
+		close(done)
+	}()
+	clock.WaitForTimers(1)
+	return mapping, func() { close(quit); <-done }
+}
+
+// advance moves the clock forward and waits for the mapping to be attempted.
+func advance(clock *mclock.Simulated, d time.Duration) {
+	clock.Run(d)
+	clock.WaitForTimers(1)
+}
+
+func TestMappingRenewal(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		fake  = &fakeNAT{name: "fake", ip: net.IP{1, 2, 3, 4}}
+	)
+	mapping, stop := startMapping(fake, clock)
+
+	if status := mapping.Status(); status.State != MappingActive || status.ExternalIP != "1.2.3.4" {
+		t.Fatalf("wrong status after mapping: %+v", status)
+	}
+	// The mapping must be renewed halfway through its lifetime.
+	fake.set(false, net.IP{5, 6, 7, 8})
+	advance(clock, mapTimeout/2)
+	if adds, _ := fake.counts(); adds != 2 {
+		t.Fatalf("mapping not renewed: %d adds", adds)
+	}
+	if status := mapping.Status(); status.ExternalIP != "5.6.7.8" {
+		t.Fatalf("external IP change not detected: %+v", status)
+	}
+	// Failing renewals leave the mapping stale until the lease expires.
+	fake.set(true, nil)
+	advance(clock, mapTimeout/2)
+	if status := mapping.Status(); status.State != MappingStale || status.Failures != 1 || status.Error == "" {
+		t.Fatalf("wrong status after failed renewal: %+v", status)
+	}
+	advance(clock, mapTimeout/2+time.Second)
+	if status := mapping.Status(); status.State != MappingFailed {
+		t.Fatalf("wrong status after lease expiry: %+v", status)
+	}
+	// A successful retry recovers the mapping.
+	fake.set(false, nil)
+	advance(clock, mapRetryMax)
+	if status := mapping.Status(); status.State != MappingActive || status.Failures != 0 || status.Error != "" {
+		t.Fatalf("wrong status after recovery: %+v", status)
+	}
+
+	stop()
+	if _, deletes := fake.counts(); deletes != 1 {
+		t.Fatalf("mapping not deleted on stop: %d deletes", deletes)
+	}
+}
+
+func TestMappingFallback(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		bad   = &fakeNAT{name: "bad", fail: true}
+		good  = &fakeNAT{name: "good"}
+		ad    = startautodiscAll("fake", func() []Interface { return []Interface{bad, good} })
+	)
+	mapping, stop := startMapping(ad, clock)
+	defer stop()
+
+	// Retry with backoff until the failure limit is hit.
+	for i := 1; i < mapFallbackFailures; i++ {
+		if status := mapping.Status(); status.State != MappingFailed || status.Mechanism != "bad" {
+			t.Fatalf("wrong status after %d failures: %+v", i, status)
+		}
+		advance(clock, mapRetryMin<<uint(i-1))
+	}
+	// The next mechanism is then used right away.
+	advance(clock, 0)
+	if status := mapping.Status(); status.State != MappingActive || status.Mechanism != "good" {
+		t.Fatalf("wrong status after fallback: %+v", status)
+	}
+	if adds, _ := good.counts(); adds != 1 {
+		t.Fatalf("wrong number of mappings on fallback mechanism: %d", adds)
+	}
+}
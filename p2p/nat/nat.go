@@ -59,6 +59,8 @@ type Interface interface {
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "pcp"                uses PCP with an auto-detected gateway address
+//     "pcp:192.168.0.1"    uses PCP with the given gateway address
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
@@ -85,44 +87,17 @@ func Parse(spec string) (Interface, error) {
 		return UPnP(), nil
 	case "pmp", "natpmp", "nat-pmp":
 		return PMP(ip), nil
+	case "pcp":
+		return PCP(ip), nil
 	default:
 		return nil, fmt.Errorf("unknown mechanism %q", parts[0])
 	}
 }
 
-const (
-	mapTimeout = 10 * time.Minute
-)
-
 // Map adds a port mapping on m and keeps it alive until c is closed.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c <-chan struct{}, protocol string, extport, intport int, name string) {
-	log := log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m)
-	refresh := time.NewTimer(mapTimeout)
-	defer func() {
-		refresh.Stop()
-		log.Debug("Deleting port mapping")
-		m.DeleteMapping(protocol, extport, intport)
-	}()
-	if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
-		log.Debug("Couldn't add port mapping", "err", err)
-	} else {
-		log.Info("Mapped network port")
-	}
-	for {
-		select {
-		case _, ok := <-c:
-			if !ok {
-				return
-			}
-		case <-refresh.C:
-			log.Trace("Refreshing port mapping")
-			if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
-				log.Debug("Couldn't add port mapping", "err", err)
-			}
-			refresh.Reset(mapTimeout)
-		}
-	}
+	NewMapping(m, protocol, extport, intport, name).Run(c)
 }
 
 // ExtIP assumes that the local machine is reachable on the given
@@ -139,20 +114,34 @@ func (ExtIP) AddMapping(string, int, int, string, time.Duration) error { return
 func (ExtIP) DeleteMapping(string, int, int) error                     { return nil }
 
 // Any returns a port mapper that tries to discover any supported
-// mechanism on the local network.
+// mechanism on the local network. If several are found, UPnP is
+// preferred over PCP, which is preferred over NAT-PMP. The others
+// are kept as fallbacks if the mappings of the preferred one fail.
 func Any() Interface {
 	// TODO: attempt to discover whether the local machine has an
 	// Internet-class address. Return ExtIP in this case.
-	return startautodisc("UPnP or NAT-PMP", func() Interface {
-		found := make(chan Interface, 2)
-		go func() { found <- discoverUPnP() }()
-		go func() { found <- discoverPMP() }()
-		for i := 0; i < cap(found); i++ {
-			if c := <-found; c != nil {
-				return c
+	return startautodiscAll("UPnP, PCP or NAT-PMP", func() []Interface {
+		var (
+			mechs = []func() Interface{discoverUPnP, discoverPCP, discoverPMP}
+			found = make([]Interface, len(mechs))
+			wg    sync.WaitGroup
+		)
+		for i := range mechs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				found[i] = mechs[i]()
+			}(i)
+		}
+		wg.Wait()
+
+		var list []Interface
+		for _, c := range found {
+			if c != nil {
+				list = append(list, c)
 			}
 		}
-		return nil
+		return list
 	})
 }
 
@@ -172,6 +161,17 @@ func PMP(gateway net.IP) Interface {
 	return startautodisc("NAT-PMP", discoverPMP)
 }
 
+// PCP returns a port mapper that uses the Port Control Protocol. The
+// provided gateway address should be the IP of your router. If the
+// given gateway address is nil, PCP will attempt to auto-discover the
+// router.
+func PCP(gateway net.IP) Interface {
+	if gateway != nil {
+		return newPCP(gateway)
+	}
+	return startautodisc("PCP", discoverPCP)
+}
+
 // autodisc represents a port mapping mechanism that is still being
 // auto-discovered. Calls to the Interface methods on this type will
 // wait until the discovery is done and then call the method on the
@@ -182,56 +182,94 @@ func PMP(gateway net.IP) Interface {
 type autodisc struct {
 	what string // type of interface being autodiscovered
 	once sync.Once
-	doit func() Interface
+	doit func() []Interface
 
 	mu    sync.Mutex
-	found Interface
+	found []Interface // discovered mechanisms, the first one is in use
 }
 
 func startautodisc(what string, doit func() Interface) Interface {
+	return startautodiscAll(what, func() []Interface {
+		if c := doit(); c != nil {
+			return []Interface{c}
+		}
+		return nil
+	})
+}
+
+func startautodiscAll(what string, doit func() []Interface) Interface {
 	// TODO: monitor network configuration and rerun doit when it changes.
 	return &autodisc{what: what, doit: doit}
 }
 
 func (n *autodisc) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
-	if err := n.wait(); err != nil {
+	c, err := n.wait()
+	if err != nil {
 		return err
 	}
-	return n.found.AddMapping(protocol, extport, intport, name, lifetime)
+	return c.AddMapping(protocol, extport, intport, name, lifetime)
 }
 
 func (n *autodisc) DeleteMapping(protocol string, extport, intport int) error {
-	if err := n.wait(); err != nil {
+	c, err := n.wait()
+	if err != nil {
 		return err
 	}
-	return n.found.DeleteMapping(protocol, extport, intport)
+	return c.DeleteMapping(protocol, extport, intport)
 }
 
 func (n *autodisc) ExternalIP() (net.IP, error) {
-	if err := n.wait(); err != nil {
+	c, err := n.wait()
+	if err != nil {
 		return nil, err
 	}
-	return n.found.ExternalIP()
+	return c.ExternalIP()
 }
 
 func (n *autodisc) String() string {
 	n.mu.Lock()
 	defer n.mu.Unlock()
-	if n.found == nil {
+	if len(n.found) == 0 {
 		return n.what
 	}
-	return n.found.String()
+	return n.found[0].String()
 }
 
-// wait blocks until auto-discovery has been performed.
-func (n *autodisc) wait() error {
+// wait blocks until auto-discovery has been performed and returns
+// the mechanism in use.
+func (n *autodisc) wait() (Interface, error) {
 	n.once.Do(func() {
+		found := n.doit()
 		n.mu.Lock()
-		n.found = n.doit()
+		n.found = found
 		n.mu.Unlock()
 	})
-	if n.found == nil {
-		return fmt.Errorf("no %s router discovered", n.what)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.found) == 0 {
+		return nil, fmt.Errorf("no %s router discovered", n.what)
 	}
-	return nil
+	return n.found[0], nil
+}
+
+// fallback switches to the next discovered mechanism after the one in
+// use failed. Once all of them are exhausted, discovery is run again.
+// It reports whether there's a mechanism to use.
+func (n *autodisc) fallback() bool {
+	n.wait()
+
+	n.mu.Lock()
+	if len(n.found) > 1 {
+		log.Info("Falling back to next port mapping mechanism", "failed", n.found[0], "next", n.found[1])
+		n.found = n.found[1:]
+		n.mu.Unlock()
+		return true
+	}
+	n.mu.Unlock()
+
+	found := n.doit()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.found = found
+	return len(found) > 0
 }
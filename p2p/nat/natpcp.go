@@ -0,0 +1,240 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	natpmp "github.com/jackpal/go-nat-pmp"
+)
+
+// Port Control Protocol (RFC 6887) constants.
+const (
+	pcpPort           = 5351
+	pcpVersion        = 2
+	pcpOpAnnounce     = 0
+	pcpOpMap          = 1
+	pcpResponseBit    = 0x80
+	pcpHeaderSize     = 24
+	pcpMapPayloadSize = 36
+	pcpAttempts       = 3
+	pcpAttemptTimeout = 500 * time.Millisecond
+)
+
+var pcpResultCodes = map[byte]string{
+	1:  "UNSUPP_VERSION",
+	2:  "NOT_AUTHORIZED",
+	3:  "MALFORMED_REQUEST",
+	4:  "UNSUPP_OPCODE",
+	5:  "UNSUPP_OPTION",
+	6:  "MALFORMED_OPTION",
+	7:  "NETWORK_FAILURE",
+	8:  "NO_RESOURCES",
+	9:  "UNSUPP_PROTOCOL",
+	10: "USER_EX_QUOTA",
+	11: "CANNOT_PROVIDE_EXTERNAL",
+	12: "ADDRESS_MISMATCH",
+	13: "EXCESSIVE_REMOTE_PEERS",
+}
+
+var errPCPInvalidResponse = errors.New("invalid PCP response")
+
+// pcp implements the Port Control Protocol, the successor of NAT-PMP.
+type pcp struct {
+	gw   net.IP
+	port int // Server port of the gateway, pcpPort unless testing
+
+	mu     sync.Mutex
+	nonces map[pcpMappingKey][12]byte // Nonces of the active mappings, needed to renew or delete them
+	extIP  net.IP                     // External address assigned by the last mapping
+}
+
+type pcpMappingKey struct {
+	protocol byte
+	intport  int
+}
+
+func newPCP(gw net.IP) *pcp {
+	return &pcp{gw: gw, port: pcpPort, nonces: make(map[pcpMappingKey][12]byte)}
+}
+
+func (n *pcp) String() string {
+	return fmt.Sprintf("PCP(%v)", n.gw)
+}
+
+// ExternalIP returns the external address assigned to the last mapping. PCP
+// can't query it without creating a mapping, so before the first mapping it's
+// queried through NAT-PMP, which PCP servers are required to support as well.
+func (n *pcp) ExternalIP() (net.IP, error) {
+	n.mu.Lock()
+	ip := n.extIP
+	n.mu.Unlock()
+	if ip != nil {
+		return ip, nil
+	}
+	response, err := natpmp.NewClient(n.gw).GetExternalAddress()
+	if err != nil {
+		return nil, err
+	}
+	return response.ExternalIPAddress[:], nil
+}
+
+func (n *pcp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	if lifetime <= 0 {
+		return fmt.Errorf("lifetime must not be <= 0")
+	}
+	return n.mapPort(protocol, extport, intport, lifetime)
+}
+
+func (n *pcp) DeleteMapping(protocol string, extport, intport int) error {
+	return n.mapPort(protocol, 0, intport, 0)
+}
+
+// mapPort creates, renews or, with a zero lifetime, deletes a mapping.
+func (n *pcp) mapPort(protocol string, extport, intport int, lifetime time.Duration) error {
+	var proto byte
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		proto = 6
+	case "udp":
+		proto = 17
+	default:
+		return fmt.Errorf("unsupported protocol %q", protocol)
+	}
+	// Mappings are identified by their nonce, so reuse the one of the mapping
+	// being renewed or deleted.
+	key := pcpMappingKey{proto, intport}
+	n.mu.Lock()
+	nonce, ok := n.nonces[key]
+	n.mu.Unlock()
+	if !ok {
+		if lifetime == 0 {
+			return nil
+		}
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return err
+		}
+	}
+	payload := make([]byte, pcpMapPayloadSize)
+	copy(payload[0:12], nonce[:])
+	payload[12] = proto
+	binary.BigEndian.PutUint16(payload[16:18], uint16(intport))
+	binary.BigEndian.PutUint16(payload[18:20], uint16(extport))
+	copy(payload[20:36], net.IPv4zero.To16())
+
+	resp, err := n.request(pcpOpMap, uint32(lifetime/time.Second), payload)
+	if err != nil {
+		return err
+	}
+	if len(resp) < pcpMapPayloadSize || !bytes.Equal(resp[0:12], nonce[:]) {
+		return errPCPInvalidResponse
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if lifetime == 0 {
+		delete(n.nonces, key)
+		return nil
+	}
+	n.nonces[key] = nonce
+	if ip := net.IP(resp[20:36]); !ip.IsUnspecified() {
+		n.extIP = append(net.IP{}, ip...)
+	}
+	return nil
+}
+
+// request sends a request to the gateway, retrying a few times, and returns
+// the opcode specific payload of the successful response.
+func (n *pcp) request(op byte, lifetime uint32, payload []byte) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: n.gw, Port: n.port})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, pcpHeaderSize, pcpHeaderSize+len(payload))
+	req[0] = pcpVersion
+	req[1] = op
+	binary.BigEndian.PutUint32(req[4:8], lifetime)
+	copy(req[8:24], conn.LocalAddr().(*net.UDPAddr).IP.To16())
+	req = append(req, payload...)
+
+	buf := make([]byte, 1100) // Maximum PCP message size
+	for i := 0; i < pcpAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(pcpAttemptTimeout << i))
+		size, err := conn.Read(buf)
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		resp := buf[:size]
+		if len(resp) < pcpHeaderSize || resp[1] != op|pcpResponseBit {
+			return nil, errPCPInvalidResponse
+		}
+		if code := resp[3]; code != 0 {
+			if name, ok := pcpResultCodes[code]; ok {
+				return nil, fmt.Errorf("PCP error %s", name)
+			}
+			return nil, fmt.Errorf("PCP error %d", code)
+		}
+		if resp[0] != pcpVersion {
+			return nil, errPCPInvalidResponse
+		}
+		return resp[pcpHeaderSize:], nil
+	}
+	return nil, fmt.Errorf("no PCP response from %v", n.gw)
+}
+
+func discoverPCP() Interface {
+	// Announce to all potential gateways, returning the first that responds
+	gws := potentialGateways()
+	found := make(chan *pcp, len(gws))
+	for i := range gws {
+		c := newPCP(gws[i])
+		go func() {
+			if _, err := c.request(pcpOpAnnounce, 0, nil); err != nil {
+				found <- nil
+			} else {
+				found <- c
+			}
+		}()
+	}
+	timeout := time.NewTimer(1 * time.Second)
+	defer timeout.Stop()
+	for range gws {
+		select {
+		case c := <-found:
+			if c != nil {
+				return c
+			}
+		case <-timeout.C:
+			return nil
+		}
+	}
+	return nil
+}
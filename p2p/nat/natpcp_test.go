@@ -0,0 +1,114 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pcpRequest is a MAP request received by the fake PCP server.
+type pcpRequest struct {
+	lifetime uint32
+	nonce    []byte
+	protocol byte
+	intport  uint16
+}
+
+// startFakePCP runs a PCP server answering MAP requests with the given
+// result code and external address.
+func startFakePCP(t *testing.T, result byte, extIP net.IP) (*pcp, <-chan pcpRequest) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	requests := make(chan pcpRequest, 10)
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			size, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:size]
+			if size != pcpHeaderSize+pcpMapPayloadSize || req[0] != pcpVersion || req[1] != pcpOpMap {
+				t.Errorf("invalid request: %x", req)
+				continue
+			}
+			payload := req[pcpHeaderSize:]
+			requests <- pcpRequest{
+				lifetime: binary.BigEndian.Uint32(req[4:8]),
+				nonce:    append([]byte{}, payload[0:12]...),
+				protocol: payload[12],
+				intport:  binary.BigEndian.Uint16(payload[16:18]),
+			}
+			resp := make([]byte, pcpHeaderSize, pcpHeaderSize+pcpMapPayloadSize)
+			resp[0], resp[1], resp[3] = pcpVersion, pcpOpMap|pcpResponseBit, result
+			copy(resp[4:8], req[4:8])
+			resp = append(resp, payload...)
+			copy(resp[pcpHeaderSize+20:], extIP.To16())
+			conn.WriteToUDP(resp, addr)
+		}
+	}()
+	c := newPCP(net.IP{127, 0, 0, 1})
+	c.port = conn.LocalAddr().(*net.UDPAddr).Port
+	return c, requests
+}
+
+func TestPCPMapping(t *testing.T) {
+	c, requests := startFakePCP(t, 0, net.IP{203, 0, 113, 5})
+
+	if err := c.AddMapping("TCP", 30303, 30303, "test", 10*time.Minute); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	add := <-requests
+	if add.lifetime != 600 || add.protocol != 6 || add.intport != 30303 {
+		t.Fatalf("wrong add request: %+v", add)
+	}
+	ip, err := c.ExternalIP()
+	if err != nil || !ip.Equal(net.IP{203, 0, 113, 5}) {
+		t.Fatalf("wrong external IP %v (err %v)", ip, err)
+	}
+	// Renewals and deletion must refer to the mapping by its nonce.
+	if err := c.AddMapping("TCP", 30303, 30303, "test", 10*time.Minute); err != nil {
+		t.Fatalf("renewal failed: %v", err)
+	}
+	if renew := <-requests; !bytes.Equal(renew.nonce, add.nonce) {
+		t.Fatalf("renewal with different nonce %x, want %x", renew.nonce, add.nonce)
+	}
+	if err := c.DeleteMapping("TCP", 30303, 30303); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if del := <-requests; del.lifetime != 0 || !bytes.Equal(del.nonce, add.nonce) {
+		t.Fatalf("wrong delete request: %+v", del)
+	}
+}
+
+func TestPCPError(t *testing.T) {
+	c, _ := startFakePCP(t, 8, net.IPv4zero)
+
+	err := c.AddMapping("UDP", 30303, 30303, "test", 10*time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "NO_RESOURCES") {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
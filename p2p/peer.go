@@ -25,6 +25,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/acent/go-acent/common"
 	"github.com/acent/go-acent/common/mclock"
 	"github.com/acent/go-acent/event"
 	"github.com/acent/go-acent/log"
@@ -64,10 +65,84 @@ type protoHandshake struct {
 	ListenPort uint64
 	ID         []byte // secp256k1 public key
 
-	// Ignore additional fields (for forward compatibility).
+	// Ignore additional fields (for forward compatibility). Extensions of the
+	// handshake are carried here as keyed entries, see helloExtension.
 	Rest []rlp.RawValue `rlp:"tail"`
 }
 
+// Keys of the handshake extensions.
+const (
+	helloMaxMsgSize = "acent/maxmsgsize" // Maximum message size accepted by the sender
+	helloZstd       = "acent/zstd"       // Protocols the sender compresses with zstd
+)
+
+// helloExtension is a keyed entry in the tail of the protocol handshake. Entries
+// are found by their keys instead of their positions, so that trailing fields
+// sent by other clients are never mistaken for them.
+type helloExtension struct {
+	Key   string
+	Value rlp.RawValue
+}
+
+// extension decodes the value of the handshake extension with the given key into
+// val, returning whether the sender announced a valid one.
+func (h *protoHandshake) extension(key string, val interface{}) bool {
+	for _, raw := range h.Rest {
+		var ext helloExtension
+		if err := rlp.DecodeBytes(raw, &ext); err != nil || ext.Key != key {
+			continue
+		}
+		return rlp.DecodeBytes(ext.Value, val) == nil
+	}
+	return false
+}
+
+// setExtension announces the handshake extension with the given key, replacing
+// any earlier value of it.
+func (h *protoHandshake) setExtension(key string, val interface{}) {
+	value, _ := rlp.EncodeToBytes(val)
+	enc, _ := rlp.EncodeToBytes(&helloExtension{Key: key, Value: value})
+	for i, raw := range h.Rest {
+		var ext helloExtension
+		if err := rlp.DecodeBytes(raw, &ext); err == nil && ext.Key == key {
+			h.Rest[i] = enc
+			return
+		}
+	}
+	h.Rest = append(h.Rest, enc)
+}
+
+// maxMsgSize returns the maximum message size announced in the handshake, or
+// zero if the sender didn't announce any.
+func (h *protoHandshake) maxMsgSize() uint32 {
+	var size uint32
+	if !h.extension(helloMaxMsgSize, &size) {
+		return 0
+	}
+	return size
+}
+
+// setMaxMsgSize announces the maximum message size accepted by the sender.
+func (h *protoHandshake) setMaxMsgSize(size uint32) {
+	h.setExtension(helloMaxMsgSize, size)
+}
+
+// zstdProtocols returns the names of the protocols the sender compresses with
+// zstd, or nil if the sender didn't announce any.
+func (h *protoHandshake) zstdProtocols() []string {
+	var names []string
+	if !h.extension(helloZstd, &names) {
+		return nil
+	}
+	return names
+}
+
+// setZstdProtocols announces the names of the protocols the sender compresses
+// with zstd.
+func (h *protoHandshake) setZstdProtocols(names []string) {
+	h.setExtension(helloZstd, names)
+}
+
 // PeerEventType is the type of peer events emitted by a p2p.Server
 type PeerEventType string
 
@@ -116,6 +191,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// reputation receives the offences of the peer if set
+	reputation *reputation
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -191,6 +269,19 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Penalize reports a misbehaviour of the peer. Once the penalty of its offences
+// reaches the ban threshold of the server, the peer is disconnected and banned
+// for a while, unless it's trusted.
+func (p *Peer) Penalize(offence Offence) {
+	if p.reputation == nil {
+		return
+	}
+	if p.reputation.report(p.ID(), offence, !p.rw.is(trustedConn)) {
+		p.log.Info("Banning misbehaving peer", "offence", offence, "duration", common.PrettyDuration(p.reputation.banTime))
+		go p.Disconnect(DiscUselessPeer)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	id := p.ID()
@@ -204,6 +295,9 @@ func (p *Peer) Inbound() bool {
 
 func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
+	for _, proto := range protomap {
+		proto.remoteMaxMsgSize = conn.maxMsgSize
+	}
 	p := &Peer{
 		rw:       conn,
 		running:  protomap,
@@ -324,10 +418,15 @@ func (p *Peer) handle(msg Msg) error {
 		if err != nil {
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
+		if proto.MaxMsgSize > 0 && msg.Size > proto.MaxMsgSize {
+			oversizedMsgMeter.Mark(1)
+			return newPeerError(errMsgTooLarge, "%s/%d: %v > %v", proto.Name, proto.Version, msg.Size, proto.MaxMsgSize)
+		}
 		if metrics.Enabled {
 			m := fmt.Sprintf("%s/%s/%d/%#02x", ingressMeterName, proto.Name, proto.Version, msg.Code-proto.offset)
 			metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
 			metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
+			ingressMsgCounter.With(fmt.Sprintf("%s/%d", proto.Name, proto.Version)).Inc(1)
 		}
 		select {
 		case proto.in <- msg:
@@ -421,12 +520,18 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+
+	remoteMaxMsgSize uint32 // Maximum message size accepted by the remote peer, zero if unknown
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	if msg.Code >= rw.Length {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
+	// Don't send messages the remote peer announced it would reject
+	if rw.remoteMaxMsgSize > 0 && msg.Size > rw.remoteMaxMsgSize {
+		return newPeerError(errMsgTooLarge, "%v > %v remote limit", msg.Size, rw.remoteMaxMsgSize)
+	}
 	msg.meterCap = rw.cap()
 	msg.meterCode = msg.Code
 
@@ -471,6 +576,8 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+
+		Zstd []string `json:"zstd,omitempty"` // Protocols compressed with zstd
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -498,6 +605,7 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Network.Zstd = p.rw.zstd
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
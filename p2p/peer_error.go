@@ -24,11 +24,13 @@ import (
 const (
 	errInvalidMsgCode = iota
 	errInvalidMsg
+	errMsgTooLarge
 )
 
 var errorToString = map[int]string{
 	errInvalidMsgCode: "invalid message code",
 	errInvalidMsg:     "invalid message",
+	errMsgTooLarge:    "message too large",
 }
 
 type peerError struct {
@@ -109,7 +111,7 @@ func discReasonForError(err error) DiscReason {
 	peerError, ok := err.(*peerError)
 	if ok {
 		switch peerError.code {
-		case errInvalidMsgCode, errInvalidMsg:
+		case errInvalidMsgCode, errInvalidMsg, errMsgTooLarge:
 			return DiscProtocolError
 		default:
 			return DiscSubprotocolError
@@ -31,6 +31,7 @@ import (
 	"github.com/acent/go-acent/log"
 	"github.com/acent/go-acent/p2p/enode"
 	"github.com/acent/go-acent/p2p/enr"
+	"github.com/acent/go-acent/rlp"
 )
 
 var discard = Protocol{
@@ -168,6 +169,71 @@ func TestPeerProtoEncodeMsg(t *testing.T) {
 	}
 }
 
+func TestPeerProtoMaxMsgSize(t *testing.T) {
+	proto := Protocol{
+		Name:       "a",
+		Length:     2,
+		MaxMsgSize: 8,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 1, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	closer, rw, _, errc := testPeer([]Protocol{proto})
+	defer closer()
+
+	Send(rw, baseProtocolLength+1, []uint{1})
+	Send(rw, baseProtocolLength+1, []string{"oversized message"})
+
+	select {
+	case err := <-errc:
+		if perr, ok := err.(*peerError); !ok || perr.code != errMsgTooLarge {
+			t.Errorf("wrong error for oversized message: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("receive timeout")
+	}
+}
+
+func TestPeerProtoRemoteMaxMsgSize(t *testing.T) {
+	rw := &protoRW{Protocol: Protocol{Name: "a", Length: 1}, remoteMaxMsgSize: 4}
+	err := rw.WriteMsg(Msg{Code: 0, Size: 5})
+	if perr, ok := err.(*peerError); !ok || perr.code != errMsgTooLarge {
+		t.Errorf("wrong error for message above the remote limit: %v", err)
+	}
+}
+
+// Tests that handshake extensions are found by their keys, ignoring trailing
+// fields of other clients.
+func TestHandshakeExtensions(t *testing.T) {
+	foreign, _ := rlp.EncodeToBytes(uint32(16))
+	hs := &protoHandshake{Rest: []rlp.RawValue{foreign}}
+	if size := hs.maxMsgSize(); size != 0 {
+		t.Fatalf("foreign field read as max message size %d", size)
+	}
+	hs.setMaxMsgSize(1024)
+	hs.setMaxMsgSize(2048)
+	if len(hs.Rest) != 2 || !reflect.DeepEqual(hs.Rest[0], rlp.RawValue(foreign)) {
+		t.Fatalf("foreign field not kept: %x", hs.Rest)
+	}
+	if size := hs.maxMsgSize(); size != 2048 {
+		t.Fatalf("max message size mismatch: have %d, want %d", size, 2048)
+	}
+	// Extensions are independent of the order they are announced in
+	zstd := &protoHandshake{}
+	zstd.setZstdProtocols([]string{"eth"})
+	if names := zstd.zstdProtocols(); !reflect.DeepEqual(names, []string{"eth"}) || zstd.maxMsgSize() != 0 {
+		t.Fatalf("zstd protocols mismatch: have %v, max message size %d", names, zstd.maxMsgSize())
+	}
+	zstd.setMaxMsgSize(1024)
+	if names := zstd.zstdProtocols(); !reflect.DeepEqual(names, []string{"eth"}) || zstd.maxMsgSize() != 1024 {
+		t.Fatalf("extensions mismatch: have %v, max message size %d", names, zstd.maxMsgSize())
+	}
+}
+
 func TestPeerPing(t *testing.T) {
 	closer, rw, _, _ := testPeer(nil)
 	defer closer()
@@ -0,0 +1,190 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/netutil"
+)
+
+var (
+	errPeerDenied     = errors.New("contained in peer deny list")
+	errPeerNotAllowed = errors.New("not contained in peer allow list")
+)
+
+// peerList is a set of node IDs and IP networks, loaded from an allow or deny
+// list file.
+type peerList struct {
+	ids  map[enode.ID]struct{}
+	nets netutil.Netlist
+}
+
+// loadPeerList reads a peer list file. Every line holds a hex node ID, an enode
+// URL or record whose ID is listed, an IP address or a CIDR mask. Empty lines
+// and comments starting with # are ignored.
+func loadPeerList(file string) (*peerList, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &peerList{ids: make(map[enode.ID]struct{})}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := scanner.Text()
+		if i := strings.IndexByte(entry, '#'); i >= 0 {
+			entry = entry[:i]
+		}
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if err := list.add(entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// add parses an entry of a peer list file and adds it to the list.
+func (l *peerList) add(entry string) error {
+	if id, err := enode.ParseID(entry); err == nil {
+		l.ids[id] = struct{}{}
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(entry, "enode://") || strings.HasPrefix(entry, "enr:"):
+		node, err := enode.Parse(enode.ValidSchemes, entry)
+		if err != nil {
+			return err
+		}
+		l.ids[node.ID()] = struct{}{}
+	case strings.Contains(entry, "/"):
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return err
+		}
+		l.nets = append(l.nets, *network)
+	default:
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return fmt.Errorf("invalid entry %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		l.nets = append(l.nets, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nil
+}
+
+// contains reports whether the node ID or IP address is in the list.
+func (l *peerList) contains(id enode.ID, ip net.IP) bool {
+	if _, ok := l.ids[id]; ok {
+		return true
+	}
+	return ip != nil && l.nets.Contains(ip)
+}
+
+// len returns the number of entries in the list.
+func (l *peerList) len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.ids) + len(l.nets)
+}
+
+// peerFilters restricts the remote nodes the server connects to by the allow
+// and deny list files, which can be reloaded while running.
+type peerFilters struct {
+	allowFile string // Allow list file, no restriction if empty
+	denyFile  string // Deny list file, no restriction if empty
+
+	lock  sync.RWMutex
+	allow *peerList
+	deny  *peerList
+}
+
+// newPeerFilters loads the configured allow and deny list files.
+func newPeerFilters(allowFile, denyFile string) (*peerFilters, error) {
+	f := &peerFilters{allowFile: allowFile, denyFile: denyFile}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reload re-reads the allow and deny list files. The filters are only replaced
+// if both files are valid.
+func (f *peerFilters) reload() error {
+	var allow, deny *peerList
+	if f.allowFile != "" {
+		list, err := loadPeerList(f.allowFile)
+		if err != nil {
+			return fmt.Errorf("invalid peer allow list: %v", err)
+		}
+		allow = list
+	}
+	if f.denyFile != "" {
+		list, err := loadPeerList(f.denyFile)
+		if err != nil {
+			return fmt.Errorf("invalid peer deny list: %v", err)
+		}
+		deny = list
+	}
+	f.lock.Lock()
+	f.allow, f.deny = allow, deny
+	f.lock.Unlock()
+	return nil
+}
+
+// check returns an error if the node is denied, or is not allowed while an
+// allow list is configured. Denial takes precedence over allowance.
+func (f *peerFilters) check(id enode.ID, ip net.IP) error {
+	if f == nil {
+		return nil
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.deny != nil && f.deny.contains(id, ip) {
+		return errPeerDenied
+	}
+	if f.allow != nil && !f.allow.contains(id, ip) {
+		return errPeerNotAllowed
+	}
+	return nil
+}
+
+// sizes returns the number of entries in the allow and deny lists.
+func (f *peerFilters) sizes() (allow, deny int) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.allow.len(), f.deny.len()
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/internal/testlog"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p/enode"
+)
+
+func writePeerList(t *testing.T, file string, entries ...string) {
+	if err := ioutil.WriteFile(file, []byte(strings.Join(entries, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeerFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-peerfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		allowFile = filepath.Join(dir, "allow")
+		denyFile  = filepath.Join(dir, "deny")
+		byID      = randomID()
+		byURL     = enode.NewV4(&newkey().PublicKey, net.IP{10, 0, 0, 1}, 30303, 30303)
+		other     = randomID()
+	)
+	writePeerList(t, allowFile,
+		"# consortium members",
+		byID.String(),
+		byURL.URLv4()+"  # member with URL",
+		"",
+		"10.1.0.0/16",
+		"2001:db8::1",
+	)
+	writePeerList(t, denyFile, "10.1.2.0/24")
+
+	filters, err := newPeerFilters(allowFile, denyFile)
+	if err != nil {
+		t.Fatalf("failed to load filters: %v", err)
+	}
+	tests := []struct {
+		id   enode.ID
+		ip   net.IP
+		want error
+	}{
+		{byID, net.IP{192, 168, 0, 1}, nil},
+		{byURL.ID(), nil, nil},
+		{other, net.IP{10, 1, 0, 1}, nil},
+		{other, net.ParseIP("2001:db8::1"), nil},
+		{other, net.ParseIP("2001:db8::2"), errPeerNotAllowed},
+		{other, net.IP{10, 2, 0, 1}, errPeerNotAllowed},
+		{other, nil, errPeerNotAllowed},
+		{byID, net.IP{10, 1, 2, 3}, errPeerDenied},
+	}
+	for i, test := range tests {
+		if err := filters.check(test.id, test.ip); err != test.want {
+			t.Errorf("test %d: check error mismatch: have %v, want %v", i, err, test.want)
+		}
+	}
+	if allow, deny := filters.sizes(); allow != 4 || deny != 1 {
+		t.Errorf("list sizes mismatch: have %d/%d, want 4/1", allow, deny)
+	}
+
+	// Invalid lists are rejected on reload, keeping the previous filters.
+	writePeerList(t, denyFile, "10.1.2.0/24", "foo")
+	if err := filters.reload(); err == nil || !strings.Contains(err.Error(), "deny:2:") {
+		t.Fatalf("invalid deny list error mismatch: %v", err)
+	}
+	if err := filters.check(byID, net.IP{10, 1, 2, 3}); err != errPeerDenied {
+		t.Errorf("filters changed by failed reload: %v", err)
+	}
+	// Valid lists replace the filters.
+	writePeerList(t, denyFile)
+	os.Remove(allowFile)
+	if err := filters.reload(); err == nil {
+		t.Fatal("missing allow list accepted")
+	}
+	writePeerList(t, allowFile, other.String())
+	if err := filters.reload(); err != nil {
+		t.Fatalf("failed to reload filters: %v", err)
+	}
+	if err := filters.check(byID, net.IP{10, 1, 2, 3}); err != errPeerNotAllowed {
+		t.Errorf("check error mismatch after reload: have %v, want %v", err, errPeerNotAllowed)
+	}
+	if err := filters.check(other, nil); err != nil {
+		t.Errorf("check error mismatch after reload: have %v, want nil", err)
+	}
+	// Without lists, all nodes are accepted.
+	var none *peerFilters
+	if err := none.check(other, nil); err != nil {
+		t.Errorf("nil filters rejected node: %v", err)
+	}
+}
+
+// Tests that the peer filters are enforced at handshake time, and that reloading
+// them disconnects the peers no longer accepted.
+func TestServerPeerFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-peerfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv1 := &Server{Config: Config{
+		PrivateKey:  newkey(),
+		MaxPeers:    1,
+		NoDiscovery: true,
+		Logger:      testlog.Logger(t, log.LvlTrace).New("server", "1"),
+	}}
+	allowFile := filepath.Join(dir, "allow")
+	writePeerList(t, allowFile, enode.PubkeyToIDV4(&srv1.PrivateKey.PublicKey).String())
+	srv2 := &Server{Config: Config{
+		PrivateKey:    newkey(),
+		MaxPeers:      1,
+		NoDiscovery:   true,
+		NoDial:        true,
+		ListenAddr:    "127.0.0.1:0",
+		PeerAllowList: allowFile,
+		Logger:        testlog.Logger(t, log.LvlTrace).New("server", "2"),
+	}}
+	srv1.Start()
+	defer srv1.Stop()
+	if err := srv2.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv2.Stop()
+
+	if !syncAddPeer(srv1, srv2.Self()) {
+		t.Fatal("allowed peer not connected")
+	}
+	// Remove the peer from the allow list, it should be disconnected.
+	ch := make(chan *PeerEvent)
+	sub := srv1.SubscribeEvents(ch)
+	defer sub.Unsubscribe()
+
+	writePeerList(t, allowFile, "192.0.2.0/24")
+	if err := srv2.ReloadPeerFilters(); err != nil {
+		t.Fatalf("could not reload filters: %v", err)
+	}
+	timeout := time.After(2 * time.Second)
+	for dropped := false; !dropped; {
+		select {
+		case ev := <-ch:
+			dropped = ev.Type == PeerEventTypeDrop && ev.Peer == srv2.Self().ID()
+		case <-timeout:
+			t.Fatal("filtered peer not disconnected")
+		}
+	}
+	srv1.RemovePeer(srv2.Self())
+
+	// Reconnecting is rejected at handshake time.
+	if syncAddPeer(srv1, srv2.Self()) {
+		t.Fatal("filtered peer connected")
+	}
+}
+
+// Tests that servers with invalid peer lists refuse to start.
+func TestServerPeerFiltersInvalid(t *testing.T) {
+	srv := &Server{Config: Config{
+		PrivateKey:   newkey(),
+		NoDiscovery:  true,
+		PeerDenyList: filepath.Join(os.TempDir(), "p2p-peerfilter-missing"),
+		Logger:       testlog.Logger(t, log.LvlTrace),
+	}}
+	if err := srv.Start(); err == nil {
+		srv.Stop()
+		t.Fatal("server started with missing deny list")
+	}
+}
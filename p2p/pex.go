@@ -0,0 +1,380 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/enr"
+	"github.com/acent/go-acent/p2p/netutil"
+)
+
+// Peer exchange protocol constants.
+const (
+	pexProtocolName = "pex"
+	pexVersion      = 1
+	pexLength       = 3
+	pexMaxMsgSize   = 8 * 1024
+
+	pexRecordMsg   = 0x00 // Signed record of the sender
+	pexGetPeersMsg = 0x01 // Request for a sample of the peers of the receiver
+	pexPeersMsg    = 0x02 // Response to pexGetPeersMsg
+
+	pexMaxRecords      = 16               // Maximum number of records in a response
+	pexMinPeerAge      = time.Minute      // Minimum connection time of a peer before its record is shared
+	pexRequestInterval = 10 * time.Minute // Interval of requesting peers while below the peer limit
+	pexRequestTimeout  = 10 * time.Second // Time allowance for answering a request
+	pexServeInterval   = time.Minute      // Minimum interval between the requests served to a peer
+	pexQueueSize       = 256              // Maximum number of received nodes waiting to be dialed
+)
+
+var errPEXNotDialable = errors.New("record has no endpoint")
+
+// pexGetPeers is the payload of pexGetPeersMsg.
+type pexGetPeers struct {
+	ReqID uint64
+	Limit uint64
+}
+
+// pexPeers is the payload of pexPeersMsg.
+type pexPeers struct {
+	ReqID   uint64
+	Records []*enr.Record
+}
+
+// pex implements the peer exchange protocol, through which connected peers share
+// the signed records of their other peers. This speeds up bootstrapping on small
+// networks where DHT lookups are slow.
+type pex struct {
+	srv   *Server
+	queue *pexQueue
+
+	mu    sync.Mutex
+	peers map[enode.ID]*pexPeer
+}
+
+// pexPeer is the peer exchange state of a connected peer.
+type pexPeer struct {
+	peer      *Peer
+	rw        MsgReadWriter
+	connected mclock.AbsTime
+
+	// These fields are protected by the pex lock.
+	record     *enode.Node    // Signed record of the peer, nil if not received or not dialable
+	reqID      uint64         // ID of the pending request, zero if none
+	lastServed mclock.AbsTime // Time of the last request served, zero if none
+}
+
+func newPEX(srv *Server) *pex {
+	return &pex{
+		srv:   srv,
+		queue: newPEXQueue(),
+		peers: make(map[enode.ID]*pexPeer),
+	}
+}
+
+// protocol returns the peer exchange protocol, which feeds the received nodes
+// to the dialer.
+func (x *pex) protocol() Protocol {
+	return Protocol{
+		Name:           pexProtocolName,
+		Version:        pexVersion,
+		Length:         pexLength,
+		Run:            x.run,
+		DialCandidates: x.queue,
+		MaxMsgSize:     pexMaxMsgSize,
+	}
+}
+
+func (x *pex) run(p *Peer, rw MsgReadWriter) error {
+	pp := &pexPeer{peer: p, rw: rw, connected: x.srv.clock.Now()}
+	x.mu.Lock()
+	x.peers[p.ID()] = pp
+	x.mu.Unlock()
+	defer func() {
+		x.mu.Lock()
+		delete(x.peers, p.ID())
+		x.mu.Unlock()
+	}()
+
+	// Send our own record first, so that the peer can share it with others.
+	if err := Send(rw, pexRecordMsg, x.srv.localnode.Node().Record()); err != nil {
+		return err
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	go x.requestLoop(pp, quit)
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		err = x.handle(pp, msg)
+		msg.Discard()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle processes a single message of a peer.
+func (x *pex) handle(pp *pexPeer, msg Msg) error {
+	switch msg.Code {
+	case pexRecordMsg:
+		var r enr.Record
+		if err := msg.Decode(&r); err != nil {
+			return fmt.Errorf("invalid %s record: %v", pexProtocolName, err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil || n.ID() != pp.peer.ID() {
+			pp.peer.Log().Debug("Invalid peer exchange record", "err", err)
+			pp.peer.Penalize(OffenceInvalid)
+			return nil
+		}
+		if n.IP() != nil && n.TCP() != 0 {
+			x.mu.Lock()
+			pp.record = n
+			x.mu.Unlock()
+		}
+
+	case pexGetPeersMsg:
+		var req pexGetPeers
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("invalid %s request: %v", pexProtocolName, err)
+		}
+		now := x.srv.clock.Now()
+		x.mu.Lock()
+		limited := pp.lastServed != 0 && now < pp.lastServed.Add(pexServeInterval)
+		if !limited {
+			pp.lastServed = now
+		}
+		x.mu.Unlock()
+		if limited {
+			pp.peer.Penalize(OffenceUseless)
+			return nil
+		}
+		return Send(pp.rw, pexPeersMsg, &pexPeers{ReqID: req.ReqID, Records: x.sample(pp.peer.ID(), req.Limit)})
+
+	case pexPeersMsg:
+		var resp pexPeers
+		if err := msg.Decode(&resp); err != nil {
+			return fmt.Errorf("invalid %s response: %v", pexProtocolName, err)
+		}
+		x.mu.Lock()
+		requested := resp.ReqID != 0 && resp.ReqID == pp.reqID
+		if requested {
+			pp.reqID = 0
+		}
+		x.mu.Unlock()
+		if !requested {
+			pp.peer.Penalize(OffenceUseless)
+			return nil
+		}
+		nodes, err := x.validate(pp.peer, resp.Records)
+		if err != nil {
+			pp.peer.Log().Debug("Invalid peer exchange response", "err", err)
+			pp.peer.Penalize(OffenceInvalid)
+			return nil
+		}
+		for _, n := range nodes {
+			x.queue.add(n)
+		}
+
+	default:
+		return fmt.Errorf("invalid %s message code %d", pexProtocolName, msg.Code)
+	}
+	return nil
+}
+
+// validate checks the records received from a peer, returning the nodes worth
+// dialing.
+func (x *pex) validate(sender *Peer, records []*enr.Record) ([]*enode.Node, error) {
+	if len(records) > pexMaxRecords {
+		return nil, fmt.Errorf("too many records (%d)", len(records))
+	}
+	var senderIP net.IP
+	switch addr := sender.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		senderIP = addr.IP
+	case *net.UDPAddr:
+		senderIP = addr.IP
+	}
+	nodes := make([]*enode.Node, 0, len(records))
+	for _, r := range records {
+		n, err := enode.New(enode.ValidSchemes, r)
+		if err != nil {
+			return nil, err
+		}
+		if n.IP() == nil || n.TCP() == 0 {
+			return nil, errPEXNotDialable
+		}
+		if senderIP != nil {
+			if err := netutil.CheckRelayIP(senderIP, n.IP()); err != nil {
+				return nil, err
+			}
+		}
+		if n.ID() == x.srv.localnode.ID() || n.ID() == sender.ID() {
+			continue
+		}
+		if x.srv.NetRestrict != nil && !x.srv.NetRestrict.Contains(n.IP()) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// sample returns the records of a random selection of the peers connected for
+// a while, excluding the requester.
+func (x *pex) sample(exclude enode.ID, limit uint64) []*enr.Record {
+	if limit == 0 || limit > pexMaxRecords {
+		limit = pexMaxRecords
+	}
+	now := x.srv.clock.Now()
+
+	x.mu.Lock()
+	var good []*enode.Node
+	for id, pp := range x.peers {
+		if id != exclude && pp.record != nil && now >= pp.connected.Add(pexMinPeerAge) {
+			good = append(good, pp.record)
+		}
+	}
+	x.mu.Unlock()
+
+	rand.Shuffle(len(good), func(i, j int) { good[i], good[j] = good[j], good[i] })
+	if uint64(len(good)) > limit {
+		good = good[:limit]
+	}
+	records := make([]*enr.Record, len(good))
+	for i, n := range good {
+		records[i] = n.Record()
+	}
+	return records
+}
+
+// requestLoop periodically requests peers from a connected peer while the
+// server is below its peer limit.
+func (x *pex) requestLoop(pp *pexPeer, quit <-chan struct{}) {
+	next := x.srv.clock.NewTimer(0)
+	defer next.Stop()
+
+	for {
+		select {
+		case <-next.C():
+		case <-quit:
+			return
+		}
+		if x.srv.PeerCount() < x.srv.MaxPeers {
+			reqID := rand.Uint64() | 1 // Zero means no pending request
+			x.mu.Lock()
+			pp.reqID = reqID
+			x.mu.Unlock()
+			if err := Send(pp.rw, pexGetPeersMsg, &pexGetPeers{ReqID: reqID, Limit: pexMaxRecords}); err != nil {
+				return
+			}
+			timeout := x.srv.clock.NewTimer(pexRequestTimeout)
+			select {
+			case <-timeout.C():
+			case <-quit:
+				timeout.Stop()
+				return
+			}
+			x.mu.Lock()
+			expired := pp.reqID == reqID
+			if expired {
+				pp.reqID = 0
+			}
+			x.mu.Unlock()
+			if expired {
+				pp.peer.Penalize(OffenceTimeout)
+			}
+		}
+		next.Reset(pexRequestInterval)
+	}
+}
+
+// pexQueue is the iterator of the nodes received through peer exchange. When
+// full, the oldest nodes are dropped.
+type pexQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	nodes  []*enode.Node
+	cur    *enode.Node
+	closed bool
+}
+
+func newPEXQueue() *pexQueue {
+	q := new(pexQueue)
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// add queues a node to be returned by the iterator.
+func (q *pexQueue) add(n *enode.Node) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	if len(q.nodes) >= pexQueueSize {
+		q.nodes = q.nodes[1:]
+	}
+	q.nodes = append(q.nodes, n)
+	q.cond.Signal()
+}
+
+// Next waits for a node to be queued. It returns false when the iterator is closed.
+func (q *pexQueue) Next() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.nodes) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		q.cur = nil
+		return false
+	}
+	q.cur, q.nodes = q.nodes[0], q.nodes[1:]
+	return true
+}
+
+// Node returns the current node.
+func (q *pexQueue) Node() *enode.Node {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.cur
+}
+
+// Close ends the iterator.
+func (q *pexQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
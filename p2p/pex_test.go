@@ -0,0 +1,200 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/enr"
+	"github.com/acent/go-acent/rlp"
+)
+
+// pexTestPeer is a peer connected to the peer exchange under test.
+type pexTestPeer struct {
+	*pexPeer
+	key    *ecdsa.PrivateKey
+	remote *MsgPipeRW     // Remote end of the protocol connection
+	resps  chan *pexPeers // Responses received by the remote end
+}
+
+func newPEXTest(t *testing.T) (*pex, *mclock.Simulated) {
+	db, _ := enode.OpenDB("")
+	t.Cleanup(db.Close)
+	clock := new(mclock.Simulated)
+	clock.Run(time.Hour) // Avoid zero timestamps
+	srv := &Server{Config: Config{MaxPeers: 10, PrivateKey: newkey(), clock: clock}}
+	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
+	return newPEX(srv), clock
+}
+
+// pexTestRecord creates a signed record with the given endpoint.
+func pexTestRecord(key *ecdsa.PrivateKey, ip net.IP, port int) *enr.Record {
+	var r enr.Record
+	r.Set(enr.IP(ip))
+	r.Set(enr.TCP(port))
+	if err := enode.SignV4(&r, key); err != nil {
+		panic(err)
+	}
+	return &r
+}
+
+func (x *pex) addTestPeer(t *testing.T, ip net.IP) *pexTestPeer {
+	key := newkey()
+	local, remote := MsgPipe()
+	t.Cleanup(func() { remote.Close() })
+	fd, _ := net.Pipe()
+	p := &Peer{rw: &conn{fd: fd, node: enode.NewV4(&key.PublicKey, ip, 30303, 30303)}, log: log.Root()}
+	pp := &pexPeer{peer: p, rw: local, connected: x.srv.clock.Now()}
+
+	x.mu.Lock()
+	x.peers[p.ID()] = pp
+	x.mu.Unlock()
+
+	tp := &pexTestPeer{pp, key, remote, make(chan *pexPeers, 10)}
+	go func() {
+		for {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			var resp pexPeers
+			if msg.Code != pexPeersMsg || msg.Decode(&resp) != nil {
+				t.Errorf("invalid response: %v", msg)
+			}
+			tp.resps <- &resp
+		}
+	}()
+	return tp
+}
+
+// deliver makes the peer exchange handle a message of the peer.
+func (p *pexTestPeer) deliver(t *testing.T, x *pex, code uint64, data interface{}) {
+	size, r, err := rlp.EncodeToReader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.handle(p.pexPeer, Msg{Code: code, Size: uint32(size), Payload: r}); err != nil {
+		t.Fatalf("handling failed: %v", err)
+	}
+}
+
+// response returns the response received by the peer, or nil if there's none.
+func (p *pexTestPeer) response() *pexPeers {
+	select {
+	case resp := <-p.resps:
+		return resp
+	case <-time.After(50 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestPEXServe(t *testing.T) {
+	x, clock := newPEXTest(t)
+	defer x.queue.Close()
+
+	var (
+		requester = x.addTestPeer(t, net.IP{10, 0, 0, 1})
+		peer1     = x.addTestPeer(t, net.IP{10, 0, 0, 2})
+		peer2     = x.addTestPeer(t, net.IP{10, 0, 0, 3})
+		peer3     = x.addTestPeer(t, net.IP{10, 0, 0, 4})
+	)
+
+	// Only peers which sent their own valid record are shared.
+	peer1.deliver(t, x, pexRecordMsg, pexTestRecord(peer1.key, net.IP{10, 0, 0, 2}, 30303))
+	peer2.deliver(t, x, pexRecordMsg, pexTestRecord(peer2.key, net.IP{10, 0, 0, 3}, 30303))
+	peer3.deliver(t, x, pexRecordMsg, pexTestRecord(peer1.key, net.IP{10, 0, 0, 4}, 30303))
+	requester.deliver(t, x, pexRecordMsg, pexTestRecord(requester.key, net.IP{10, 0, 0, 1}, 30303))
+
+	// Peers aren't shared until they have been connected for a while.
+	requester.deliver(t, x, pexGetPeersMsg, &pexGetPeers{ReqID: 1})
+	if resp := requester.response(); resp == nil || resp.ReqID != 1 || len(resp.Records) != 0 {
+		t.Fatalf("wrong response for new peers: %+v", resp)
+	}
+	// Requests are rate limited.
+	requester.deliver(t, x, pexGetPeersMsg, &pexGetPeers{ReqID: 2})
+	if resp := requester.response(); resp != nil {
+		t.Fatalf("rate limited request answered: %+v", resp)
+	}
+	clock.Run(pexMinPeerAge)
+	requester.deliver(t, x, pexGetPeersMsg, &pexGetPeers{ReqID: 3})
+	resp := requester.response()
+	if resp == nil || len(resp.Records) != 2 {
+		t.Fatalf("wrong response: %+v", resp)
+	}
+	shared := make(map[enode.ID]bool)
+	for _, r := range resp.Records {
+		n, err := enode.New(enode.ValidSchemes, r)
+		if err != nil {
+			t.Fatalf("invalid record shared: %v", err)
+		}
+		shared[n.ID()] = true
+	}
+	if !shared[peer1.peer.ID()] || !shared[peer2.peer.ID()] {
+		t.Fatalf("wrong records shared: %v", shared)
+	}
+	// The limit of the request is obeyed.
+	clock.Run(pexServeInterval)
+	requester.deliver(t, x, pexGetPeersMsg, &pexGetPeers{ReqID: 4, Limit: 1})
+	if resp := requester.response(); resp == nil || len(resp.Records) != 1 {
+		t.Fatalf("wrong response with limit: %+v", resp)
+	}
+}
+
+func TestPEXReceive(t *testing.T) {
+	x, _ := newPEXTest(t)
+	defer x.queue.Close()
+
+	var (
+		server = x.addTestPeer(t, net.IP{10, 0, 0, 1})
+		good   = pexTestRecord(newkey(), net.IP{10, 0, 0, 2}, 30303)
+		local  = pexTestRecord(newkey(), net.IP{127, 0, 0, 1}, 30303)
+		bad    enr.Record
+	)
+	bad.Set(enr.IP(net.IP{10, 0, 0, 3})) // No TCP port, so not dialable
+	enode.SignV4(&bad, newkey())
+
+	// Unrequested responses are ignored.
+	server.deliver(t, x, pexPeersMsg, &pexPeers{ReqID: 1, Records: []*enr.Record{good}})
+	if len(x.queue.nodes) != 0 {
+		t.Fatal("unrequested records accepted")
+	}
+	// Responses with invalid records are dropped as a whole.
+	server.reqID = 2
+	server.deliver(t, x, pexPeersMsg, &pexPeers{ReqID: 2, Records: []*enr.Record{good, &bad}})
+	if len(x.queue.nodes) != 0 {
+		t.Fatal("response with invalid record accepted")
+	}
+	server.reqID = 3
+	server.deliver(t, x, pexPeersMsg, &pexPeers{ReqID: 3, Records: []*enr.Record{good}})
+	if !x.queue.Next() || !bytes.Equal(x.queue.Node().IP(), net.IP{10, 0, 0, 2}) {
+		t.Fatal("valid record not queued")
+	}
+	// Records aren't relayed from a LAN peer to a loopback address.
+	server.peer.rw.fd = &fakeAddrConn{remoteAddr: &net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 30303}}
+	server.reqID = 4
+	server.deliver(t, x, pexPeersMsg, &pexPeers{ReqID: 4, Records: []*enr.Record{local}})
+	if len(x.queue.nodes) != 0 {
+		t.Fatal("loopback record accepted from LAN peer")
+	}
+}
@@ -61,6 +61,11 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// MaxMsgSize is the maximum size of a message accepted by the protocol. Larger
+	// messages are rejected before reaching Run and the peer is disconnected. If
+	// zero, only the limit of the connection applies.
+	MaxMsgSize uint32
 }
 
 func (p Protocol) cap() Cap {
@@ -0,0 +1,86 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"context"
+	"net"
+
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/enr"
+)
+
+// QUICTransport carries RLPx sessions over QUIC, which survives the address
+// changes and aggressive NAT timeouts of mobile networks better than TCP. The
+// RLPx handshake and framing run unchanged on top of a single bidirectional
+// stream per session, so the transport only has to establish the streams.
+//
+// The p2p package only defines the interface, the server uses QUIC if one is
+// set in its configuration. Implementations should resume sessions with recently
+// seen nodes using 0-RTT, so that reconnects after a network change don't pay for
+// a full handshake.
+type QUICTransport interface {
+	// Listen starts accepting QUIC sessions on the given UDP address. The
+	// listener returns the first stream of every incoming session.
+	Listen(addr string) (net.Listener, error)
+
+	// Dial opens a QUIC session with the given UDP address and returns its
+	// first stream.
+	Dial(ctx context.Context, addr *net.UDPAddr) (net.Conn, error)
+}
+
+// setupQUICListening starts accepting RLPx sessions over QUIC and advertises
+// the listening port in the node record.
+func (srv *Server) setupQUICListening() error {
+	listener, err := srv.QUIC.Listen(srv.QUICListenAddr)
+	if err != nil {
+		return err
+	}
+	srv.quicListener = listener
+	srv.QUICListenAddr = listener.Addr().String()
+
+	// Update the local node record and map the UDP port if NAT is configured.
+	if udp, ok := listener.Addr().(*net.UDPAddr); ok {
+		srv.localnode.Set(enr.QUIC(udp.Port))
+		if !udp.IP.IsLoopback() && srv.NAT != nil {
+			srv.mapPort("udp", udp.Port, "acent quic")
+		}
+	}
+	srv.loopWG.Add(1)
+	go srv.listenLoop(listener)
+	return nil
+}
+
+// quicDialer dials nodes advertising a QUIC port over QUIC, falling back to
+// another dialer for the rest, or if the QUIC dial fails.
+type quicDialer struct {
+	quic     QUICTransport
+	fallback NodeDialer
+	log      log.Logger
+}
+
+func (d quicDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	if port := dest.QUIC(); port != 0 {
+		fd, err := d.quic.Dial(ctx, &net.UDPAddr{IP: dest.IP(), Port: port})
+		if err == nil || dest.TCP() == 0 {
+			return fd, err
+		}
+		d.log.Trace("QUIC dial failed, falling back to TCP", "id", dest.ID(), "err", err)
+	}
+	return d.fallback.Dial(ctx, dest)
+}
@@ -0,0 +1,199 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/internal/testlog"
+	"github.com/acent/go-acent/log"
+	"github.com/acent/go-acent/p2p/enode"
+	"github.com/acent/go-acent/p2p/enr"
+)
+
+// fakeQUIC is a QUICTransport carrying the sessions over loopback TCP, with
+// the listener posing as an UDP one.
+type fakeQUIC struct {
+	dials int32
+	fail  bool
+}
+
+type fakeQUICListener struct {
+	net.Listener
+}
+
+func (l fakeQUICListener) Addr() net.Addr {
+	addr := l.Listener.Addr().(*net.TCPAddr)
+	return &net.UDPAddr{IP: addr.IP, Port: addr.Port}
+}
+
+func (q *fakeQUIC) Listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return fakeQUICListener{l}, nil
+}
+
+func (q *fakeQUIC) Dial(ctx context.Context, addr *net.UDPAddr) (net.Conn, error) {
+	atomic.AddInt32(&q.dials, 1)
+	if q.fail {
+		return nil, errors.New("QUIC unreachable")
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", (&net.TCPAddr{IP: addr.IP, Port: addr.Port}).String())
+}
+
+func startQUICTestServer(t *testing.T, quic QUICTransport, remoteKey *ecdsa.PublicKey, pf func(*Peer)) *Server {
+	server := &Server{
+		Config: Config{
+			Name:           "test",
+			MaxPeers:       10,
+			ListenAddr:     "127.0.0.1:0",
+			QUIC:           quic,
+			QUICListenAddr: "127.0.0.1:0",
+			NoDiscovery:    true,
+			PrivateKey:     newkey(),
+			Logger:         testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: pf,
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport {
+			return newTestTransport(remoteKey, fd, dialDest)
+		},
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	return server
+}
+
+// quicTestNode creates a node record advertising the given QUIC and TCP ports.
+func quicTestNode(t *testing.T, key *ecdsa.PrivateKey, quic, tcp int) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.QUIC(quic))
+	if tcp != 0 {
+		r.Set(enr.TCP(tcp))
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestServerQUICListen(t *testing.T) {
+	connected := make(chan *Peer, 1)
+	quic := new(fakeQUIC)
+	srv := startQUICTestServer(t, quic, &newkey().PublicKey, func(p *Peer) { connected <- p })
+	defer srv.Stop()
+
+	addr := srv.quicListener.Addr().(*net.UDPAddr)
+	if port := srv.Self().QUIC(); port != addr.Port {
+		t.Fatalf("wrong QUIC port in node record: got %d, want %d", port, addr.Port)
+	}
+	conn, err := quic.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Error("server did not accept QUIC session within one second")
+	}
+}
+
+func TestServerQUICDial(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("could not setup listener: %v", err)
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		go func() {
+			if conn, err := listener.Accept(); err == nil {
+				defer conn.Close()
+				time.Sleep(time.Second)
+			}
+		}()
+
+		// The fake QUIC transport reaches the TCP listener, so the node is
+		// connected either over QUIC or the TCP fallback.
+		var (
+			remote    = newkey()
+			connected = make(chan *Peer, 1)
+			quic      = &fakeQUIC{fail: fail}
+			srv       = startQUICTestServer(t, quic, &remote.PublicKey, func(p *Peer) { connected <- p })
+		)
+		srv.AddPeer(quicTestNode(t, remote, port, port))
+
+		select {
+		case <-connected:
+			if dials := atomic.LoadInt32(&quic.dials); dials != 1 {
+				t.Errorf("fail=%v: wrong number of QUIC dials %d", fail, dials)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("fail=%v: server did not connect within one second", fail)
+		}
+		srv.Stop()
+		listener.Close()
+	}
+}
+
+func TestServerQUICOnlyDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not setup listener: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	// Nodes without a TCP port are dialed if the QUIC transport is set.
+	var (
+		remote    = newkey()
+		connected = make(chan *Peer, 1)
+		quic      = new(fakeQUIC)
+		srv       = startQUICTestServer(t, quic, &remote.PublicKey, func(p *Peer) { connected <- p })
+	)
+	defer srv.Stop()
+
+	srv.AddPeer(quicTestNode(t, remote, listener.Addr().(*net.TCPAddr).Port, 0))
+	select {
+	case <-connected:
+		if dials := atomic.LoadInt32(&quic.dials); dials != 1 {
+			t.Errorf("wrong number of QUIC dials %d", dials)
+		}
+	case <-time.After(time.Second):
+		t.Error("server did not connect to QUIC-only node within one second")
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/p2p/enode"
+)
+
+const (
+	defaultBanThreshold = 100       // Default penalty above which peers are banned
+	defaultBanDuration  = time.Hour // Default time a peer stays banned
+
+	// reputationHalfLife is the time it takes for the penalty of a peer to
+	// decay to half of its value.
+	reputationHalfLife = 10 * time.Minute
+
+	// reputationForgetPenalty is the decayed penalty below which the score of a
+	// peer is forgotten.
+	reputationForgetPenalty = 0.1
+
+	// reputationPruneInterval is the interval at which the scores of the nodes
+	// that are neither banned nor penalized anymore are forgotten.
+	reputationPruneInterval = time.Minute
+
+	// maxReputationEntries is the maximum number of nodes whose score is tracked.
+	maxReputationEntries = 4096
+)
+
+// Offence is a misbehaviour of a remote peer, reported by a protocol handler.
+type Offence int
+
+const (
+	OffenceUseless Offence = iota // Useless or unrequested message
+	OffenceTimeout                // Request not answered in time
+	OffenceInvalid                // Invalid data or breach of protocol
+)
+
+var offenceNames = [...]string{
+	OffenceUseless: "useless",
+	OffenceTimeout: "timeout",
+	OffenceInvalid: "invalid",
+}
+
+// offencePenalties are the penalties added to the score of a peer for each
+// offence.
+var offencePenalties = [...]float64{
+	OffenceUseless: 5,
+	OffenceTimeout: 20,
+	OffenceInvalid: 50,
+}
+
+func (o Offence) String() string {
+	return offenceNames[o]
+}
+
+// PeerScore is the reputation of a remote node, as reported by admin_peerScores.
+type PeerScore struct {
+	ID          enode.ID       `json:"id"`
+	Penalty     float64        `json:"penalty"`  // Decayed sum of the offence penalties
+	Offences    map[string]int `json:"offences"` // Number of offences by kind
+	BannedUntil *time.Time     `json:"bannedUntil,omitempty"`
+}
+
+// reputation tracks the misbehaviour of remote nodes, temporarily banning the
+// ones whose decayed penalty reaches the threshold.
+type reputation struct {
+	clock     mclock.Clock
+	threshold float64 // Penalty triggering a ban, non-positive if banning is disabled
+	banTime   time.Duration
+
+	entries map[enode.ID]*reputationEntry
+	pruned  mclock.AbsTime // Time the forgettable entries were last pruned
+	lock    sync.Mutex
+}
+
+// reputationEntry is the score of a single remote node.
+type reputationEntry struct {
+	penalty  float64
+	updated  mclock.AbsTime // Time the penalty was last decayed
+	offences map[Offence]int
+	banned   mclock.AbsTime // Expiry of the ban, zero if never banned
+}
+
+func newReputation(clock mclock.Clock, threshold float64, banTime time.Duration) *reputation {
+	if threshold == 0 {
+		threshold = defaultBanThreshold
+	}
+	if banTime == 0 {
+		banTime = defaultBanDuration
+	}
+	return &reputation{
+		clock:     clock,
+		threshold: threshold,
+		banTime:   banTime,
+		entries:   make(map[enode.ID]*reputationEntry),
+	}
+}
+
+// decay reduces the penalty of the entry according to the time passed since
+// its last update.
+func (e *reputationEntry) decay(now mclock.AbsTime) {
+	elapsed := time.Duration(now - e.updated)
+	e.penalty *= math.Pow(0.5, float64(elapsed)/float64(reputationHalfLife))
+	e.updated = now
+}
+
+// forgettable returns whether the node is neither banned nor penalized anymore.
+// The penalty must have been decayed.
+func (e *reputationEntry) forgettable(now mclock.AbsTime) bool {
+	return e.banned <= now && e.penalty < reputationForgetPenalty
+}
+
+// maybePrune forgets the forgettable entries if the prune interval passed.
+//
+// Note, this method assumes the lock is held!
+func (r *reputation) maybePrune(now mclock.AbsTime) {
+	if time.Duration(now-r.pruned) < reputationPruneInterval {
+		return
+	}
+	for id, entry := range r.entries {
+		entry.decay(now)
+		if entry.forgettable(now) {
+			delete(r.entries, id)
+		}
+	}
+	r.pruned = now
+}
+
+// makeRoom ensures a new node can be tracked, forgetting the least penalized
+// node that isn't banned if all slots are taken. The return value is false if
+// all tracked nodes are banned.
+//
+// Note, this method assumes the lock is held!
+func (r *reputation) makeRoom(now mclock.AbsTime) bool {
+	if len(r.entries) < maxReputationEntries {
+		return true
+	}
+	var (
+		evict   enode.ID
+		penalty = math.Inf(1)
+	)
+	for id, entry := range r.entries {
+		entry.decay(now)
+		if entry.banned <= now && entry.penalty < penalty {
+			evict, penalty = id, entry.penalty
+		}
+	}
+	if math.IsInf(penalty, 1) {
+		return false
+	}
+	delete(r.entries, evict)
+	return true
+}
+
+// report adds the penalty of the offence to the score of the node. If the
+// penalty reaches the ban threshold and the node may be banned, it's banned
+// and its penalty reset. The return value is whether the node got banned.
+func (r *reputation) report(id enode.ID, offence Offence, bannable bool) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.clock.Now()
+	r.maybePrune(now)
+
+	entry := r.entries[id]
+	if entry == nil {
+		if !r.makeRoom(now) {
+			return false
+		}
+		entry = &reputationEntry{updated: now, offences: make(map[Offence]int)}
+		r.entries[id] = entry
+	}
+	entry.decay(now)
+	entry.penalty += offencePenalties[offence]
+	entry.offences[offence]++
+
+	if !bannable || r.threshold <= 0 || entry.penalty < r.threshold {
+		return false
+	}
+	entry.penalty = 0
+	entry.banned = now.Add(r.banTime)
+	return true
+}
+
+// isBanned returns whether the node is currently banned.
+func (r *reputation) isBanned(id enode.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.clock.Now()
+	r.maybePrune(now)
+
+	entry := r.entries[id]
+	return entry != nil && entry.banned > now
+}
+
+// scores returns the scores of all the tracked nodes, forgetting the ones that
+// are neither banned nor penalized anymore.
+func (r *reputation) scores() []PeerScore {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now    = r.clock.Now()
+		wall   = time.Now()
+		scores = make([]PeerScore, 0, len(r.entries))
+	)
+	for id, entry := range r.entries {
+		entry.decay(now)
+		if entry.forgettable(now) {
+			delete(r.entries, id)
+			continue
+		}
+		score := PeerScore{ID: id, Penalty: entry.penalty, Offences: make(map[string]int)}
+		for offence, count := range entry.offences {
+			score.Offences[offence.String()] = count
+		}
+		if entry.banned > now {
+			until := wall.Add(time.Duration(entry.banned - now))
+			score.BannedUntil = &until
+		}
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return bytes.Compare(scores[i].ID[:], scores[j].ID[:]) < 0
+	})
+	return scores
+}
+
+// clear forgets the score of the node, lifting its ban. The return value is
+// whether the node was tracked.
+func (r *reputation) clear(id enode.ID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.entries[id]
+	delete(r.entries, id)
+	return ok
+}
+
+// clearAll forgets the scores of all nodes, lifting all bans.
+func (r *reputation) clearAll() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entries = make(map[enode.ID]*reputationEntry)
+}
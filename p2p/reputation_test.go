@@ -0,0 +1,149 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/acent/go-acent/common/mclock"
+	"github.com/acent/go-acent/p2p/enode"
+)
+
+func TestReputationBan(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		rep   = newReputation(clock, 100, time.Minute)
+		id    = enode.ID{1}
+	)
+	if rep.report(id, OffenceInvalid, true) {
+		t.Fatal("banned below the threshold")
+	}
+	if !rep.report(id, OffenceInvalid, true) {
+		t.Fatal("not banned at the threshold")
+	}
+	if !rep.isBanned(id) {
+		t.Fatal("isBanned false after ban")
+	}
+	scores := rep.scores()
+	if len(scores) != 1 || scores[0].BannedUntil == nil || scores[0].Offences["invalid"] != 2 {
+		t.Fatalf("wrong scores after ban: %+v", scores)
+	}
+	clock.Run(time.Minute)
+	if rep.isBanned(id) {
+		t.Fatal("still banned after the ban duration")
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		rep   = newReputation(clock, 100, time.Minute)
+		id    = enode.ID{1}
+	)
+	rep.report(id, OffenceInvalid, true)
+	clock.Run(reputationHalfLife)
+	if rep.report(id, OffenceInvalid, true) {
+		t.Fatal("banned although the penalty decayed")
+	}
+	if scores := rep.scores(); len(scores) != 1 || math.Abs(scores[0].Penalty-75) > 1e-9 {
+		t.Fatalf("wrong decayed penalty: %+v", scores)
+	}
+	// Fully decayed scores are forgotten.
+	clock.Run(20 * reputationHalfLife)
+	if scores := rep.scores(); len(scores) != 0 {
+		t.Fatalf("decayed score not forgotten: %+v", scores)
+	}
+}
+
+func TestReputationPrune(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		rep   = newReputation(clock, 100, time.Minute)
+	)
+	// Decayed entries are pruned on the next check after the prune interval.
+	rep.report(enode.ID{1}, OffenceUseless, true)
+	clock.Run(20 * reputationHalfLife)
+	rep.isBanned(enode.ID{2})
+	if len(rep.entries) != 0 {
+		t.Fatalf("decayed entry not pruned: %d entries", len(rep.entries))
+	}
+	// The number of tracked nodes is capped, evicting the least penalized ones
+	// that aren't banned.
+	rep.report(enode.ID{1}, OffenceInvalid, true)
+	rep.report(enode.ID{1}, OffenceInvalid, true)
+	rep.report(enode.ID{2}, OffenceInvalid, true)
+	for i := 0; i < maxReputationEntries; i++ {
+		rep.report(enode.ID{3, byte(i >> 8), byte(i)}, OffenceUseless, true)
+	}
+	if len(rep.entries) != maxReputationEntries {
+		t.Fatalf("wrong number of entries: have %d, want %d", len(rep.entries), maxReputationEntries)
+	}
+	if !rep.isBanned(enode.ID{1}) {
+		t.Fatal("banned node evicted")
+	}
+	if _, ok := rep.entries[enode.ID{2}]; !ok {
+		t.Fatal("most penalized node evicted")
+	}
+}
+
+func TestReputationNotBannable(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		rep   = newReputation(clock, 100, time.Minute)
+		id    = enode.ID{1}
+	)
+	for i := 0; i < 10; i++ {
+		if rep.report(id, OffenceInvalid, false) {
+			t.Fatal("non-bannable node banned")
+		}
+	}
+	if rep.isBanned(id) {
+		t.Fatal("non-bannable node banned")
+	}
+	// Banning is disabled with a negative threshold.
+	rep = newReputation(clock, -1, time.Minute)
+	for i := 0; i < 10; i++ {
+		if rep.report(id, OffenceInvalid, true) {
+			t.Fatal("node banned with banning disabled")
+		}
+	}
+}
+
+func TestReputationClear(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		rep   = newReputation(clock, 50, time.Minute)
+	)
+	rep.report(enode.ID{1}, OffenceInvalid, true)
+	rep.report(enode.ID{2}, OffenceTimeout, true)
+
+	if !rep.clear(enode.ID{1}) {
+		t.Fatal("clear returned false for tracked node")
+	}
+	if rep.isBanned(enode.ID{1}) {
+		t.Fatal("node still banned after clear")
+	}
+	if rep.clear(enode.ID{1}) {
+		t.Fatal("clear returned true for untracked node")
+	}
+	rep.clearAll()
+	if scores := rep.scores(); len(scores) != 0 {
+		t.Fatalf("scores left after clearAll: %+v", scores)
+	}
+}
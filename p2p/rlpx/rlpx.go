@@ -52,8 +52,16 @@ type Conn struct {
 	conn      net.Conn
 	handshake *handshakeState
 	snappy    bool
+	zstd      *zstdCodec // Compression of selected messages with zstd, nil if disabled
+	readLimit int        // Maximum size of accepted messages, zero means the frame limit
 }
 
+// MaxMessageSize is the maximum size of a message supported by the transport.
+const MaxMessageSize = 1<<24 - 1
+
+// ErrMessageTooLarge is returned by Read if a message exceeds the read limit.
+var ErrMessageTooLarge = errors.New("message exceeds read limit")
+
 type handshakeState struct {
 	enc cipher.Stream
 	dec cipher.Stream
@@ -79,6 +87,12 @@ func (c *Conn) SetSnappy(snappy bool) {
 	c.snappy = snappy
 }
 
+// SetReadLimit sets the maximum size of messages accepted by Read. Larger messages
+// are rejected with ErrMessageTooLarge. A zero limit only enforces the frame limit.
+func (c *Conn) SetReadLimit(limit int) {
+	c.readLimit = limit
+}
+
 // SetReadDeadline sets the deadline for all future read operations.
 func (c *Conn) SetReadDeadline(time time.Time) error {
 	return c.conn.SetReadDeadline(time)
@@ -110,9 +124,18 @@ func (c *Conn) Read() (code uint64, data []byte, wireSize int, err error) {
 	}
 	wireSize = len(data)
 
-	// If snappy is enabled, verify and decompress message.
-	if c.snappy {
-		var actualSize int
+	// If compression is enabled, verify and decompress message.
+	var (
+		useZstd    = c.zstd.compressed(code)
+		actualSize = len(data)
+	)
+	switch {
+	case useZstd:
+		actualSize, err = zstdDecodedLen(data)
+		if err != nil {
+			return code, nil, 0, err
+		}
+	case c.snappy:
 		actualSize, err = snappy.DecodedLen(data)
 		if err != nil {
 			return code, nil, 0, err
@@ -120,6 +143,15 @@ func (c *Conn) Read() (code uint64, data []byte, wireSize int, err error) {
 		if actualSize > maxUint24 {
 			return code, nil, 0, errPlainMessageTooLarge
 		}
+	}
+	// Reject messages above the read limit before decompressing them
+	if c.readLimit > 0 && actualSize > c.readLimit {
+		return code, nil, 0, fmt.Errorf("%w: %d > %d", ErrMessageTooLarge, actualSize, c.readLimit)
+	}
+	switch {
+	case useZstd:
+		data, err = zstdDecode(data, actualSize)
+	case c.snappy:
 		data, err = snappy.Decode(nil, data)
 	}
 	return code, data, wireSize, err
@@ -170,7 +202,7 @@ func (h *handshakeState) readFrame(conn io.Reader) ([]byte, error) {
 // Write writes a message to the connection.
 //
 // Write returns the written size of the message data. This may be less than or equal to
-// len(data) depending on whether snappy compression is enabled.
+// len(data) depending on whether snappy or zstd compression is enabled.
 func (c *Conn) Write(code uint64, data []byte) (uint32, error) {
 	if c.handshake == nil {
 		panic("can't WriteMsg before handshake")
@@ -178,7 +210,10 @@ func (c *Conn) Write(code uint64, data []byte) (uint32, error) {
 	if len(data) > maxUint24 {
 		return 0, errPlainMessageTooLarge
 	}
-	if c.snappy {
+	switch {
+	case c.zstd.compressed(code):
+		data = c.zstd.encode(data)
+	case c.snappy:
 		data = snappy.Encode(nil, data)
 	}
 
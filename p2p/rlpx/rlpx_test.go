@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -62,6 +63,80 @@ func TestReadWriteMsg(t *testing.T) {
 	checkMsgReadWrite(t, peer1, peer2, testCode, testData)
 }
 
+// This test checks that messages above the read limit are rejected.
+func TestReadLimit(t *testing.T) {
+	peer1, peer2 := createPeers(t)
+	defer peer1.Close()
+	defer peer2.Close()
+
+	peer1.SetReadLimit(4)
+	checkMsgReadWrite(t, peer1, peer2, 1, []byte("test"))
+	checkMsgReadLimit(t, peer1, peer2)
+
+	peer1.SetSnappy(true)
+	peer2.SetSnappy(true)
+	checkMsgReadWrite(t, peer1, peer2, 1, []byte("test"))
+	checkMsgReadLimit(t, peer1, peer2)
+}
+
+func TestZstd(t *testing.T) {
+	peer1, peer2 := createPeers(t)
+	defer peer1.Close()
+	defer peer2.Close()
+
+	// Compress the messages from code 16 with zstd, the others with snappy
+	match := func(code uint64) bool { return code >= 16 }
+	for _, peer := range []*Conn{peer1, peer2} {
+		peer.SetSnappy(true)
+		if err := peer.SetZstd(MaxZstdLevel, match); err != nil {
+			t.Fatalf("failed to enable zstd: %v", err)
+		}
+	}
+	checkMsgReadWrite(t, peer1, peer2, 1, []byte("test"))
+	checkMsgReadWrite(t, peer1, peer2, 16, []byte("test"))
+	checkMsgReadWrite(t, peer1, peer2, 17, []byte{})
+	checkMsgReadWrite(t, peer1, peer2, 17, bytes.Repeat([]byte("test"), 1024))
+
+	// Ensure compressible messages shrink on the wire
+	go peer2.Write(16, bytes.Repeat([]byte("test"), 1024))
+	if _, _, size, err := peer1.Read(); err != nil {
+		t.Fatal(err)
+	} else if size >= 1024 {
+		t.Errorf("zstd message not compressed: %d bytes on the wire", size)
+	}
+	// Ensure the read limit is enforced before decompression
+	peer1.SetReadLimit(4)
+	ch := make(chan error, 1)
+	go func() {
+		_, _, _, err := peer1.Read()
+		ch <- err
+	}()
+	if _, err := peer2.Write(16, []byte("tests")); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ch; !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("wrong error for zstd message above the limit: %v", err)
+	}
+	// Ensure invalid levels are rejected
+	if err := peer1.SetZstd(MaxZstdLevel+1, match); err == nil {
+		t.Errorf("invalid zstd level accepted")
+	}
+}
+
+func checkMsgReadLimit(t *testing.T, p1, p2 *Conn) {
+	ch := make(chan error, 1)
+	go func() {
+		_, _, _, err := p1.Read()
+		ch <- err
+	}()
+	if _, err := p2.Write(1, []byte("tests")); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ch; !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("wrong error for message above the limit: %v", err)
+	}
+}
+
 func checkMsgReadWrite(t *testing.T, p1, p2 *Conn, msgCode uint64, msgData []byte) {
 	// Set up the reader.
 	ch := make(chan message, 1)
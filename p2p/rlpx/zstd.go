@@ -0,0 +1,135 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlpx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// MinZstdLevel is the fastest supported zstd compression level.
+	MinZstdLevel = int(zstd.SpeedFastest)
+
+	// DefaultZstdLevel is the zstd compression level used if none is configured,
+	// roughly equivalent to the default level of the reference implementation.
+	DefaultZstdLevel = int(zstd.SpeedDefault)
+
+	// MaxZstdLevel is the best compressing supported zstd compression level.
+	MaxZstdLevel = int(zstd.SpeedBestCompression)
+)
+
+var errZstdSize = errors.New("zstd message size mismatch")
+
+var (
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder // Shared decoder, safe for concurrent use
+
+	zstdEncodersLock sync.Mutex
+	zstdEncoders     = make(map[int]*zstd.Encoder) // Shared encoders by level
+)
+
+// zstdCodec compresses the messages selected by their code with zstd.
+type zstdCodec struct {
+	match   func(code uint64) bool
+	encoder *zstd.Encoder
+}
+
+// SetZstd enables zstd compression at the given level for the messages selected
+// by the match function, which then aren't compressed with snappy. It is usually
+// called after the devp2p Hello message exchange, once both ends of the connection
+// agreed on the protocols to compress with zstd. A nil match function disables
+// zstd compression.
+func (c *Conn) SetZstd(level int, match func(code uint64) bool) error {
+	if match == nil {
+		c.zstd = nil
+		return nil
+	}
+	encoder, err := zstdEncoder(level)
+	if err != nil {
+		return err
+	}
+	c.zstd = &zstdCodec{match: match, encoder: encoder}
+	return nil
+}
+
+// compressed reports whether the message with the given code is compressed with
+// zstd.
+func (z *zstdCodec) compressed(code uint64) bool {
+	return z != nil && z.match(code)
+}
+
+// encode compresses a message.
+func (z *zstdCodec) encode(data []byte) []byte {
+	return z.encoder.EncodeAll(data, nil)
+}
+
+// zstdEncoder returns the shared zstd encoder of the given level, creating it
+// if needed.
+func zstdEncoder(level int) (*zstd.Encoder, error) {
+	if level < MinZstdLevel || level > MaxZstdLevel {
+		return nil, fmt.Errorf("invalid zstd level %d, want %d-%d", level, MinZstdLevel, MaxZstdLevel)
+	}
+	zstdEncodersLock.Lock()
+	defer zstdEncodersLock.Unlock()
+
+	if encoder := zstdEncoders[level]; encoder != nil {
+		return encoder, nil
+	}
+	// Encode every message into a single segment frame, which always carries
+	// the decompressed size, so it can be checked before decompression.
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevel(level)), zstd.WithSingleSegment(true), zstd.WithZeroFrames(true))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[level] = encoder
+	return encoder, nil
+}
+
+// zstdDecodedLen returns the size of the decompressed message.
+func zstdDecodedLen(data []byte) (int, error) {
+	var header zstd.Header
+	if err := header.Decode(data); err != nil {
+		return 0, err
+	}
+	if header.Skippable || !header.HasFCS {
+		return 0, errors.New("zstd message size unknown")
+	}
+	if header.FrameContentSize > uint64(maxUint24) {
+		return 0, errPlainMessageTooLarge
+	}
+	return int(header.FrameContentSize), nil
+}
+
+// zstdDecode decompresses a message of the given size, as returned by
+// zstdDecodedLen.
+func zstdDecode(data []byte, size int) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxUint24)))
+	})
+	decoded, err := zstdDecoder.DecodeAll(data, make([]byte, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) != size {
+		return nil, errZstdSize
+	}
+	return decoded, nil
+}
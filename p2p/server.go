@@ -39,6 +39,7 @@ import (
 	"github.com/acent/go-acent/p2p/enr"
 	"github.com/acent/go-acent/p2p/nat"
 	"github.com/acent/go-acent/p2p/netutil"
+	"github.com/acent/go-acent/p2p/rlpx"
 )
 
 const (
@@ -62,6 +63,9 @@ const (
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Default maximum number of nodes retained in the node database.
+	defaultNodeDatabaseLimit = 10000
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -85,6 +89,33 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// MaxMsgSize is the maximum size of a single message accepted from peers. It
+	// is announced to them during the handshake. Zero defaults to the transport
+	// limit of 16MB.
+	MaxMsgSize uint32 `toml:",omitempty"`
+
+	// ProtocolMsgLimits overrides the maximum message size of the protocols by
+	// their name.
+	ProtocolMsgLimits map[string]uint32 `toml:",omitempty"`
+
+	// ZstdProtocols lists the names of the protocols whose messages are compressed
+	// with zstd instead of snappy. Compression is only enabled for a protocol if
+	// the remote peer lists it too.
+	ZstdProtocols []string `toml:",omitempty"`
+
+	// ZstdLevel is the zstd compression level, from 1 (fastest) to 4 (best).
+	// Zero defaults to 2.
+	ZstdLevel int `toml:",omitempty"`
+
+	// PeerBanThreshold is the decayed penalty of reported offences at which a
+	// misbehaving peer is disconnected and temporarily banned. Zero defaults to
+	// 100, a negative value disables banning.
+	PeerBanThreshold float64 `toml:",omitempty"`
+
+	// PeerBanDuration is the time a misbehaving peer stays banned. Zero
+	// defaults to one hour.
+	PeerBanDuration time.Duration `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
@@ -93,6 +124,11 @@ type Config struct {
 	// protocol should be started or not.
 	DiscoveryV5 bool `toml:",omitempty"`
 
+	// PeerExchange enables the peer exchange protocol, through which connected
+	// peers share the records of their peers on request. It speeds up finding
+	// peers on small networks where DHT lookups are slow.
+	PeerExchange bool `toml:",omitempty"`
+
 	// Name sets the node name of this server.
 	// Use common.MakeName to create a name that follows existing conventions.
 	Name string `toml:"-"`
@@ -119,10 +155,24 @@ type Config struct {
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// PeerAllowList is the path of a file listing the node IDs and IP networks
+	// allowed as peers, one per line. If set, all other nodes are disconnected
+	// after the handshake, including trusted and static ones.
+	PeerAllowList string `toml:",omitempty"`
+
+	// PeerDenyList is the path of a file listing the node IDs and IP networks
+	// never accepted as peers, taking precedence over the allow list.
+	PeerDenyList string `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// NodeDatabaseLimit is the maximum number of nodes retained in the node
+	// database, beyond which the least recently seen ones are evicted. Zero
+	// defaults to 10000, a negative value disables the limit.
+	NodeDatabaseLimit int `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -145,6 +195,17 @@ type Config struct {
 	// is used to dial outbound peer connections.
 	Dialer NodeDialer `toml:"-"`
 
+	// QUIC is an optional transport carrying RLPx sessions over QUIC streams.
+	// If set, nodes advertising a QUIC port in their record are dialed over
+	// QUIC first, falling back to TCP, and nodes without a TCP port are dialed
+	// too. No implementation is bundled, it has to be provided by the embedder.
+	QUIC QUICTransport `toml:"-"`
+
+	// QUICListenAddr is the UDP address QUIC sessions are accepted on if the
+	// QUIC transport is set. Its port is advertised in the node record and must
+	// differ from the discovery port.
+	QUICListenAddr string `toml:",omitempty"`
+
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
@@ -173,6 +234,7 @@ type Server struct {
 	running bool
 
 	listener     net.Listener
+	quicListener net.Listener
 	ourHandshake *protoHandshake
 	loopWG       sync.WaitGroup // loop, listenLoop
 	peerFeed     event.Feed
@@ -185,6 +247,12 @@ type Server struct {
 	discmix   *enode.FairMix
 	dialsched *dialScheduler
 
+	reputation *reputation  // Offence scores and bans of remote nodes
+	filters    *peerFilters // Allow and deny lists of remote nodes
+
+	natLock     sync.Mutex     // protects natMappings
+	natMappings []*nat.Mapping // Port mappings maintained on srv.NAT
+
 	// Channels into the run loop.
 	quit                    chan struct{}
 	addtrusted              chan *enode.Node
@@ -226,6 +294,9 @@ type conn struct {
 	cont  chan error // The run loop uses cont to signal errors to SetupConn.
 	caps  []Cap      // valid after the protocol handshake
 	name  string     // valid after the protocol handshake
+
+	maxMsgSize uint32   // valid after the protocol handshake, zero if not announced
+	zstd       []string // protocols compressed with zstd by both ends, valid after the protocol handshake
 }
 
 type transport interface {
@@ -370,6 +441,80 @@ func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	}
 }
 
+// PeerScores returns the offence scores of the misbehaving remote nodes,
+// including the currently banned ones.
+func (srv *Server) PeerScores() []PeerScore {
+	if srv.reputation == nil {
+		return []PeerScore{}
+	}
+	return srv.reputation.scores()
+}
+
+// ClearPeerScore forgets the offence score of the given node, lifting its ban
+// if any. It returns whether the node had a score.
+func (srv *Server) ClearPeerScore(id enode.ID) bool {
+	if srv.reputation == nil {
+		return false
+	}
+	return srv.reputation.clear(id)
+}
+
+// ClearPeerScores forgets the offence scores of all nodes, lifting all bans.
+func (srv *Server) ClearPeerScores() {
+	if srv.reputation != nil {
+		srv.reputation.clearAll()
+	}
+}
+
+// ReloadPeerFilters re-reads the peer allow and deny list files, disconnecting
+// the peers no longer accepted by them.
+func (srv *Server) ReloadPeerFilters() error {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+	if !running {
+		return errServerStopped
+	}
+	if err := srv.filters.reload(); err != nil {
+		return err
+	}
+	allow, deny := srv.filters.sizes()
+	srv.log.Info("Reloaded peer filters", "allow", allow, "deny", deny)
+
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for id, p := range peers {
+			if err := srv.filters.check(id, p.Node().IP()); err != nil {
+				p.log.Debug("Disconnecting filtered peer", "err", err)
+				p.Disconnect(DiscUselessPeer)
+			}
+		}
+	})
+	return nil
+}
+
+// NodeDatabaseStats returns a summary of the contents of the node database.
+func (srv *Server) NodeDatabaseStats() (enode.DBStats, error) {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+	if !running {
+		return enode.DBStats{}, errServerStopped
+	}
+	return srv.nodedb.Stats(), nil
+}
+
+// CompactNodeDatabase drops the expired nodes and the ones beyond the retention
+// limit from the node database, and compacts its storage.
+func (srv *Server) CompactNodeDatabase() error {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+	if !running {
+		return errServerStopped
+	}
+	return srv.nodedb.Compact()
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -400,6 +545,9 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	if srv.quicListener != nil {
+		srv.quicListener.Close()
+	}
 	close(srv.quit)
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
@@ -455,6 +603,12 @@ func (srv *Server) Start() (err error) {
 	if srv.PrivateKey == nil {
 		return errors.New("Server.PrivateKey must be set to a non-nil key")
 	}
+	if srv.MaxMsgSize > rlpx.MaxMessageSize {
+		return fmt.Errorf("Server.MaxMsgSize %d exceeds the transport limit %d", srv.MaxMsgSize, rlpx.MaxMessageSize)
+	}
+	if srv.ZstdLevel != 0 && (srv.ZstdLevel < rlpx.MinZstdLevel || srv.ZstdLevel > rlpx.MaxZstdLevel) {
+		return fmt.Errorf("Server.ZstdLevel %d out of range %d-%d", srv.ZstdLevel, rlpx.MinZstdLevel, rlpx.MaxZstdLevel)
+	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
 	}
@@ -469,7 +623,15 @@ func (srv *Server) Start() (err error) {
 	srv.removetrusted = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.reputation = newReputation(srv.clock, srv.PeerBanThreshold, srv.PeerBanDuration)
+	if srv.filters, err = newPeerFilters(srv.PeerAllowList, srv.PeerDenyList); err != nil {
+		return err
+	}
 
+	if srv.PeerExchange {
+		// Copy the protocol list to avoid modifying the configured one
+		srv.Protocols = append(srv.Protocols[:len(srv.Protocols):len(srv.Protocols)], newPEX(srv).protocol())
+	}
 	if err := srv.setupLocalNode(); err != nil {
 		return err
 	}
@@ -478,6 +640,11 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 	}
+	if srv.QUIC != nil && srv.QUICListenAddr != "" {
+		if err := srv.setupQUICListening(); err != nil {
+			return err
+		}
+	}
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
@@ -497,18 +664,33 @@ func (srv *Server) setupLocalNode() error {
 	}
 	sort.Sort(capsByNameAndVersion(srv.ourHandshake.Caps))
 
+	maxMsgSize := srv.MaxMsgSize
+	if maxMsgSize == 0 {
+		maxMsgSize = rlpx.MaxMessageSize
+	}
+	srv.ourHandshake.setMaxMsgSize(maxMsgSize)
+	if len(srv.ZstdProtocols) > 0 {
+		srv.ourHandshake.setZstdProtocols(srv.ZstdProtocols)
+	}
+
 	// Create the local node.
 	db, err := enode.OpenDB(srv.Config.NodeDatabase)
 	if err != nil {
 		return err
 	}
+	limit := srv.NodeDatabaseLimit
+	if limit == 0 {
+		limit = defaultNodeDatabaseLimit
+	}
+	db.SetNodeLimit(limit)
 	srv.nodedb = db
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
-	// TODO: check conflicts
 	for _, p := range srv.Protocols {
 		for _, e := range p.Attributes {
-			srv.localnode.Set(e)
+			if _, err := srv.localnode.RegisterAttribute(p.Name, e); err != nil {
+				return fmt.Errorf("protocol %s: %v", p.Name, err)
+			}
 		}
 	}
 	switch srv.NAT.(type) {
@@ -561,11 +743,7 @@ func (srv *Server) setupDiscovery() error {
 	srv.log.Debug("UDP listener up", "addr", realaddr)
 	if srv.NAT != nil {
 		if !realaddr.IP.IsLoopback() {
-			srv.loopWG.Add(1)
-			go func() {
-				nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "acent discovery")
-				srv.loopWG.Done()
-			}()
+			srv.mapPort("udp", realaddr.Port, "acent discovery")
 		}
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
@@ -621,6 +799,8 @@ func (srv *Server) setupDialScheduler() {
 		maxActiveDials: srv.MaxPendingPeers,
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
+		filters:        srv.filters,
+		quic:           srv.QUIC != nil,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
 	}
@@ -630,6 +810,9 @@ func (srv *Server) setupDialScheduler() {
 	if config.dialer == nil {
 		config.dialer = tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 	}
+	if srv.QUIC != nil {
+		config.dialer = quicDialer{quic: srv.QUIC, fallback: config.dialer, log: srv.log}
+	}
 	srv.dialsched = newDialScheduler(config, srv.discmix, srv.SetupConn)
 	for _, n := range srv.StaticNodes {
 		srv.dialsched.addStatic(n)
@@ -668,19 +851,30 @@ func (srv *Server) setupListening() error {
 	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
 		srv.localnode.Set(enr.TCP(tcp.Port))
 		if !tcp.IP.IsLoopback() && srv.NAT != nil {
-			srv.loopWG.Add(1)
-			go func() {
-				nat.Map(srv.NAT, srv.quit, "tcp", tcp.Port, tcp.Port, "acent p2p")
-				srv.loopWG.Done()
-			}()
+			srv.mapPort("tcp", tcp.Port, "acent p2p")
 		}
 	}
 
 	srv.loopWG.Add(1)
-	go srv.listenLoop()
+	go srv.listenLoop(listener)
 	return nil
 }
 
+// mapPort maintains a mapping of the given local port on srv.NAT until the
+// server is stopped.
+func (srv *Server) mapPort(protocol string, port int, name string) {
+	m := nat.NewMapping(srv.NAT, protocol, port, port, name)
+	srv.natLock.Lock()
+	srv.natMappings = append(srv.natMappings, m)
+	srv.natLock.Unlock()
+
+	srv.loopWG.Add(1)
+	go func() {
+		m.Run(srv.quit)
+		srv.loopWG.Done()
+	}()
+}
+
 // doPeerOp runs fn on the main loop.
 func (srv *Server) doPeerOp(fn peerOpFunc) {
 	select {
@@ -810,6 +1004,10 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case srv.filters.check(c.node.ID(), c.node.IP()) != nil:
+		return DiscUselessPeer
+	case !c.is(trustedConn) && srv.reputation.isBanned(c.node.ID()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -827,8 +1025,8 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 
 // listenLoop runs in its own goroutine and accepts
 // inbound connections.
-func (srv *Server) listenLoop() {
-	srv.log.Debug("TCP listener up", "addr", srv.listener.Addr())
+func (srv *Server) listenLoop(listener net.Listener) {
+	srv.log.Debug("Listener up", "network", listener.Addr().Network(), "addr", listener.Addr())
 
 	// The slots channel limits accepts of new connections.
 	tokens := defaultMaxPendingPeers
@@ -859,7 +1057,7 @@ func (srv *Server) listenLoop() {
 			lastLog time.Time
 		)
 		for {
-			fd, err = srv.listener.Accept()
+			fd, err = listener.Accept()
 			if netutil.IsTemporaryError(err) {
 				if time.Since(lastLog) > 1*time.Second {
 					srv.log.Debug("Temporary read error", "err", err)
@@ -981,7 +1179,8 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		clog.Trace("Wrong devp2p handshake identity", "phsid", hex.EncodeToString(phs.ID))
 		return DiscUnexpectedIdentity
 	}
-	c.caps, c.name = phs.Caps, phs.Name
+	c.caps, c.name, c.maxMsgSize = phs.Caps, phs.Name, phs.maxMsgSize()
+	c.zstd = srv.negotiateZstd(phs.zstdProtocols())
 	err = srv.checkpoint(c, srv.checkpointAddPeer)
 	if err != nil {
 		clog.Trace("Rejected peer", "err", err)
@@ -991,6 +1190,46 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	return nil
 }
 
+// negotiateZstd returns the protocols both we and the remote peer compress with
+// zstd.
+func (srv *Server) negotiateZstd(theirs []string) []string {
+	var names []string
+	for _, name := range theirs {
+		for _, ours := range srv.ZstdProtocols {
+			if name == ours {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// enableZstd switches the transport of the peer to zstd compression for the
+// messages of the negotiated protocols the peer is running.
+func (srv *Server) enableZstd(p *Peer) {
+	t, ok := p.rw.transport.(zstdTransport)
+	if !ok || len(p.rw.zstd) == 0 {
+		return
+	}
+	var ranges [][2]uint64 // Message code ranges of the compressed protocols
+	for _, name := range p.rw.zstd {
+		if proto := p.running[name]; proto != nil {
+			ranges = append(ranges, [2]uint64{proto.offset, proto.offset + proto.Length})
+		}
+	}
+	if len(ranges) == 0 {
+		return
+	}
+	level := srv.ZstdLevel
+	if level == 0 {
+		level = rlpx.DefaultZstdLevel
+	}
+	if err := t.setZstd(level, ranges); err != nil {
+		p.log.Warn("Failed to enable zstd compression", "err", err)
+	}
+}
+
 func nodeFromConn(pubkey *ecdsa.PublicKey, conn net.Conn) *enode.Node {
 	var ip net.IP
 	var port int
@@ -1014,6 +1253,16 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.reputation = srv.reputation
+
+	// Apply the configured message size limits to the protocols of the peer,
+	// the configured protocols are owned by the caller and left untouched.
+	for _, proto := range p.running {
+		if limit, ok := srv.ProtocolMsgLimits[proto.Name]; ok {
+			proto.MaxMsgSize = limit
+		}
+	}
+	srv.enableZstd(p)
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -1068,6 +1317,7 @@ type NodeInfo struct {
 		Listener  int `json:"listener"`  // TCP listening port for RLPx
 	} `json:"ports"`
 	ListenAddr string                 `json:"listenAddr"`
+	NAT        []nat.MappingStatus    `json:"nat,omitempty"` // Health of the NAT port mappings
 	Protocols  map[string]interface{} `json:"protocols"`
 }
 
@@ -1087,6 +1337,12 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	info.Ports.Listener = node.TCP()
 	info.ENR = node.String()
 
+	srv.natLock.Lock()
+	for _, m := range srv.natMappings {
+		info.NAT = append(info.NAT, m.Status())
+	}
+	srv.natLock.Unlock()
+
 	// Gather all the running protocol infos (only once per protocol type)
 	for _, proto := range srv.Protocols {
 		if _, ok := info.Protocols[proto.Name]; !ok {
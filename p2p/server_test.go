@@ -17,6 +17,7 @@
 package p2p
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
 	"errors"
@@ -204,6 +205,77 @@ func TestServerDial(t *testing.T) {
 }
 
 // This test checks that RemovePeer disconnects the peer if it is connected.
+// This test checks that zstd compression is negotiated for the protocols both
+// servers enable it for, and that messages of all protocols get through.
+func TestServerZstd(t *testing.T) {
+	payload := bytes.Repeat([]byte("test"), 1024)
+	received := make(chan string, 2)
+
+	newServer := func(zstd []string, send bool) *Server {
+		var protocols []Protocol
+		for _, name := range []string{"a", "b"} {
+			name := name
+			protocols = append(protocols, Protocol{Name: name, Version: 1, Length: 1, Run: func(p *Peer, rw MsgReadWriter) error {
+				if send {
+					if err := Send(rw, 0, payload); err != nil {
+						return err
+					}
+				} else {
+					msg, err := rw.ReadMsg()
+					if err != nil {
+						return err
+					}
+					var data []byte
+					if err := msg.Decode(&data); err != nil {
+						return err
+					}
+					if bytes.Equal(data, payload) {
+						received <- name
+					}
+				}
+				_, err := rw.ReadMsg() // Wait until the peer disconnects
+				return err
+			}})
+		}
+		srv := &Server{Config: Config{
+			Name:          "test",
+			MaxPeers:      10,
+			ListenAddr:    "127.0.0.1:0",
+			NoDiscovery:   true,
+			PrivateKey:    newkey(),
+			Protocols:     protocols,
+			ZstdProtocols: zstd,
+			Logger:        testlog.Logger(t, log.LvlTrace),
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("could not start server: %v", err)
+		}
+		return srv
+	}
+	srv1 := newServer([]string{"a", "b"}, true)
+	defer srv1.Stop()
+	srv2 := newServer([]string{"a"}, false)
+	defer srv2.Stop()
+
+	srv2.AddPeer(srv1.Self())
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d not received", i)
+		}
+	}
+	for _, srv := range []*Server{srv1, srv2} {
+		infos := srv.PeersInfo()
+		if len(infos) != 1 {
+			t.Fatalf("peer count mismatch: have %d, want 1", len(infos))
+		}
+		if zstd := infos[0].Network.Zstd; !reflect.DeepEqual(zstd, []string{"a"}) {
+			t.Errorf("zstd protocols mismatch: have %v, want [a]", zstd)
+		}
+	}
+}
+
 func TestServerRemovePeerDisconnect(t *testing.T) {
 	srv1 := &Server{Config: Config{
 		PrivateKey:  newkey(),
@@ -300,6 +372,59 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+// This test checks that the configured protocol message limits are applied to
+// the peers without modifying the protocols of the caller.
+func TestServerProtocolMsgLimits(t *testing.T) {
+	remote := newkey()
+	protos := []Protocol{{
+		Name:    "a",
+		Version: 1,
+		Length:  1,
+		Run:     func(*Peer, MsgReadWriter) error { return nil },
+	}}
+	limits := make(chan uint32, 1)
+	srv := &Server{
+		Config: Config{
+			PrivateKey:        newkey(),
+			MaxPeers:          10,
+			NoDial:            true,
+			NoDiscovery:       true,
+			Protocols:         protos,
+			ProtocolMsgLimits: map[string]uint32{"a": 16},
+			Logger:            testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: func(p *Peer) {
+			limits <- p.running["a"].MaxMsgSize
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	if protos[0].MaxMsgSize != 0 {
+		t.Fatalf("configured protocol modified: have limit %d", protos[0].MaxMsgSize)
+	}
+	fd, _ := net.Pipe()
+	tx := newTestTransport(&remote.PublicKey, fd, nil)
+	node := enode.SignNull(new(enr.Record), randomID())
+	c := &conn{fd: fd, transport: tx, flags: inboundConn, node: node, caps: []Cap{{"a", 1}}, cont: make(chan error)}
+	if err := srv.checkpoint(c, srv.checkpointAddPeer); err != nil {
+		t.Fatalf("could not add conn: %v", err)
+	}
+	select {
+	case limit := <-limits:
+		if limit != 16 {
+			t.Errorf("wrong peer protocol limit: have %d, want %d", limit, 16)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("peer not launched")
+	}
+	if protos[0].MaxMsgSize != 0 {
+		t.Errorf("configured protocol modified: have limit %d", protos[0].MaxMsgSize)
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
@@ -19,6 +19,7 @@ package p2p
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -61,6 +62,9 @@ func (t *rlpxTransport) ReadMsg() (Msg, error) {
 	var msg Msg
 	t.conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
 	code, data, wireSize, err := t.conn.Read()
+	if errors.Is(err, rlpx.ErrMessageTooLarge) {
+		oversizedMsgMeter.Mark(1)
+	}
 	if err == nil {
 		msg = Msg{
 			ReceivedAt: time.Now(),
@@ -96,6 +100,7 @@ func (t *rlpxTransport) WriteMsg(msg Msg) error {
 		m := fmt.Sprintf("%s/%s/%d/%#02x", egressMeterName, msg.meterCap.Name, msg.meterCap.Version, msg.meterCode)
 		metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
 		metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
+		egressMsgCounter.With(msg.meterCap.String()).Inc(1)
 	}
 	return nil
 }
@@ -143,9 +148,35 @@ func (t *rlpxTransport) doProtoHandshake(our *protoHandshake) (their *protoHands
 	// If the protocol version supports Snappy encoding, upgrade immediately
 	t.conn.SetSnappy(their.Version >= snappyProtocolVersion)
 
+	// Enforce the message size limit we announced from now on
+	t.conn.SetReadLimit(int(our.maxMsgSize()))
+
 	return their, nil
 }
 
+// zstdTransport is implemented by transports supporting zstd compression.
+type zstdTransport interface {
+	// setZstd compresses the messages with codes in the given half-open ranges
+	// with zstd at the given level.
+	setZstd(level int, ranges [][2]uint64) error
+}
+
+func (t *rlpxTransport) setZstd(level int, ranges [][2]uint64) error {
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+
+	return t.conn.SetZstd(level, func(code uint64) bool {
+		for _, r := range ranges {
+			if code >= r[0] && code < r[1] {
+				return true
+			}
+		}
+		return false
+	})
+}
+
 func readProtocolHandshake(rw MsgReader) (*protoHandshake, error) {
 	msg, err := rw.ReadMsg()
 	if err != nil {
@@ -39,6 +39,7 @@ func TestProtocolHandshake(t *testing.T) {
 
 		wg sync.WaitGroup
 	)
+	hs0.setMaxMsgSize(1024)
 
 	fd0, fd1, err := pipes.TCPPipe()
 	if err != nil {
@@ -91,7 +92,9 @@ func TestProtocolHandshake(t *testing.T) {
 			t.Errorf("listen side proto handshake error: %v", err)
 			return
 		}
-		phs.Rest = nil
+		if size := phs.maxMsgSize(); size != 1024 {
+			t.Errorf("listen side max message size mismatch: got %d, want %d", size, 1024)
+		}
 		if !reflect.DeepEqual(phs, hs0) {
 			t.Errorf("listen side proto handshake mismatch:\ngot: %s\nwant: %s\n", spew.Sdump(phs), spew.Sdump(hs0))
 			return
@@ -244,16 +244,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, new(EthashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Acent core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, nil, new(EthashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -329,9 +329,190 @@ type ChainConfig struct {
 	YoloV3Block *big.Int `json:"yoloV3Block,omitempty"` // YOLO v3: Gas repricings TODO @holiman add EIP references
 	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
 
+	BLS12381Block *big.Int `json:"bls12381Block,omitempty"` // EIP-2537 BLS12-381 precompiles switch block (nil = no fork, 0 = already activated)
+	SidecarBlock  *big.Int `json:"sidecarBlock,omitempty"`  // Experimental sidecar transactions switch block (nil = no fork, 0 = already activated)
+
+	// EVMForks are data driven instruction set changes layered on top of the
+	// built-in forks above, applied in order once their block is reached.
+	EVMForks []*EVMFork `json:"evmForks,omitempty"`
+
+	// EVMLimits overrides the interpreter limits of the chain from genesis on
+	// (nil = protocol defaults). Only meant for private networks.
+	EVMLimits *EVMLimits `json:"evmLimits,omitempty"`
+
+	// Rewards replaces the proof-of-work issuance of the chain with a custom
+	// schedule (nil = the reward of the active protocol fork).
+	Rewards *RewardSchedule `json:"rewards,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+
+	// overridden holds the original blocks of the forks rescheduled at startup,
+	// keyed by fork name. It is never persisted.
+	overridden map[string]*big.Int
+}
+
+// EVMFork is a declarative modification of the EVM instruction set, allowing
+// hard forks which only enable, disable or reprice opcodes to be scheduled
+// through the chain configuration instead of code changes.
+type EVMFork struct {
+	Name    string            `json:"name"`              // Human readable fork name, used in logs and errors
+	Block   *big.Int          `json:"block"`             // Fork switch block (nil = no fork, 0 = already activated)
+	EIPs    []int             `json:"eips,omitempty"`    // Predefined EIPs to enable (e.g. 2929)
+	Disable []string          `json:"disable,omitempty"` // Opcodes to remove from the instruction set
+	Reprice map[string]uint64 `json:"reprice,omitempty"` // Opcodes to assign a new constant gas cost to
+}
+
+// sameRules reports whether two forks modify the instruction set identically.
+func (f *EVMFork) sameRules(other *EVMFork) bool {
+	if len(f.EIPs) != len(other.EIPs) || len(f.Disable) != len(other.Disable) || len(f.Reprice) != len(other.Reprice) {
+		return false
+	}
+	for i, eip := range f.EIPs {
+		if other.EIPs[i] != eip {
+			return false
+		}
+	}
+	for i, op := range f.Disable {
+		if other.Disable[i] != op {
+			return false
+		}
+	}
+	for op, gas := range f.Reprice {
+		if have, ok := other.Reprice[op]; !ok || have != gas {
+			return false
+		}
+	}
+	return true
+}
+
+// EVMLimits are the resource limits of the EVM interpreter. Zero fields fall back
+// to the protocol defaults.
+type EVMLimits struct {
+	MaxCallDepth uint64 `json:"maxCallDepth,omitempty"` // Maximum depth of the call/create stack (0 = CallCreateDepth)
+	MaxCodeSize  uint64 `json:"maxCodeSize,omitempty"`  // Maximum bytecode size of a deployed contract (0 = MaxCodeSize)
+	MaxMemory    uint64 `json:"maxMemory,omitempty"`    // Maximum memory a call frame may expand to in bytes (0 = limited by gas only)
+
+	// CallGasDivisor is the N of the all but one N-th gas forwarding rule: calls
+	// and creates may forward at most the available gas minus its N-th part to
+	// the callee (0 = CallGasDivisor). Large values forward practically all gas.
+	CallGasDivisor uint64 `json:"callGasDivisor,omitempty"`
+}
+
+// effective returns the limits in force, substituting the protocol defaults for
+// unset fields.
+func (l *EVMLimits) effective() EVMLimits {
+	limits := EVMLimits{MaxCallDepth: CallCreateDepth, MaxCodeSize: MaxCodeSize, CallGasDivisor: CallGasDivisor}
+	if l == nil {
+		return limits
+	}
+	if l.MaxCallDepth != 0 {
+		limits.MaxCallDepth = l.MaxCallDepth
+	}
+	if l.MaxCodeSize != 0 {
+		limits.MaxCodeSize = l.MaxCodeSize
+	}
+	if l.CallGasDivisor != 0 {
+		limits.CallGasDivisor = l.CallGasDivisor
+	}
+	limits.MaxMemory = l.MaxMemory
+	return limits
+}
+
+// equal reports whether two sets of limits are in effect identical, treating nil
+// and unset fields as the protocol defaults.
+func (l *EVMLimits) equal(other *EVMLimits) bool {
+	return l.effective() == other.effective()
+}
+
+// RewardSchedule is the issuance of a proof-of-work chain. The block reward is
+// set by the last step activated, and reduced by a percentage every era counted
+// from genesis. Uncle miners earn (uncle + 8 - block) / UncleDivisor of the block
+// reward, while the block miner earns 1 / NephewDivisor of it per included uncle.
+type RewardSchedule struct {
+	Steps         []*RewardStep `json:"steps"`                   // Block reward changes in activation order, the first one at genesis
+	EraLength     uint64        `json:"eraLength,omitempty"`     // Number of blocks per era (0 = no reductions)
+	EraReduction  uint64        `json:"eraReduction,omitempty"`  // Percentage the block reward is reduced by each era (50 = halving)
+	UncleDivisor  uint64        `json:"uncleDivisor,omitempty"`  // Divisor of the uncle rewards (0 = 8)
+	NephewDivisor uint64        `json:"nephewDivisor,omitempty"` // Divisor of the bonus for including an uncle (0 = 32)
+}
+
+// RewardStep is a change of the block reward at a given block.
+type RewardStep struct {
+	Block  *big.Int `json:"block"`  // Block the reward is effective from
+	Reward *big.Int `json:"reward"` // Block reward in wei
+}
+
+// divisors returns the uncle and nephew reward divisors, substituting the
+// protocol defaults for unset ones.
+func (s *RewardSchedule) divisors() (uint64, uint64) {
+	uncle, nephew := uint64(8), uint64(32)
+	if s != nil && s.UncleDivisor != 0 {
+		uncle = s.UncleDivisor
+	}
+	if s != nil && s.NephewDivisor != 0 {
+		nephew = s.NephewDivisor
+	}
+	return uncle, nephew
+}
+
+// eraStart returns the first block whose reward is reduced by the eras of the
+// schedule, or nil if the reward is never reduced.
+func (s *RewardSchedule) eraStart() *big.Int {
+	if s == nil || s.EraLength == 0 || s.EraReduction == 0 {
+		return nil
+	}
+	return new(big.Int).SetUint64(s.EraLength)
+}
+
+// checkCompatible returns an error if the schedule can't be replaced by the new
+// one because the rewards of blocks up to head would change. The error points at
+// the first block affected, so changes applying from block 1 on, which can't be
+// undone by a rewind, are reported with RewindTo 0.
+func (s *RewardSchedule) checkCompatible(news *RewardSchedule, head *big.Int) *ConfigCompatError {
+	if (s == nil) != (news == nil) {
+		if isForked(common.Big1, head) {
+			return newCompatError("reward schedule", common.Big1, common.Big1)
+		}
+		return nil
+	}
+	if s == nil {
+		return nil
+	}
+	oldStart, newStart := s.eraStart(), news.eraStart()
+	if isForkIncompatible(oldStart, newStart, head) || (isForked(oldStart, head) && s.EraReduction != news.EraReduction) {
+		return newCompatError("reward eras", oldStart, newStart)
+	}
+	oldUncle, oldNephew := s.divisors()
+	newUncle, newNephew := news.divisors()
+	if (oldUncle != newUncle || oldNephew != newNephew) && isForked(common.Big1, head) {
+		return newCompatError("uncle reward divisors", common.Big1, common.Big1)
+	}
+	for i := 0; i < len(s.Steps) || i < len(news.Steps); i++ {
+		var oldStep, newStep *RewardStep
+		if i < len(s.Steps) {
+			oldStep = s.Steps[i]
+		}
+		if i < len(news.Steps) {
+			newStep = news.Steps[i]
+		}
+		switch {
+		case oldStep == nil:
+			if isForked(newStep.Block, head) {
+				return newCompatError("reward step block", nil, newStep.Block)
+			}
+		case newStep == nil:
+			if isForked(oldStep.Block, head) {
+				return newCompatError("reward step block", oldStep.Block, nil)
+			}
+		case isForkIncompatible(oldStep.Block, newStep.Block, head):
+			return newCompatError("reward step block", oldStep.Block, newStep.Block)
+		case isForked(oldStep.Block, head) && oldStep.Reward.Cmp(newStep.Reward) != 0:
+			return newCompatError("reward step amount", oldStep.Block, newStep.Block)
+		}
+	}
+	return nil
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -364,7 +545,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, YOLO v3: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, YOLO v3: %v, BLS12-381: %v, Sidecar: %v, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -379,6 +560,8 @@ func (c *ChainConfig) String() string {
 		c.MuirGlacierBlock,
 		c.BerlinBlock,
 		c.YoloV3Block,
+		c.BLS12381Block,
+		c.SidecarBlock,
 		engine,
 	)
 }
@@ -445,6 +628,76 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// IsBLS12381 returns whether num is either equal to the BLS12-381 precompile fork
+// block or greater.
+func (c *ChainConfig) IsBLS12381(num *big.Int) bool {
+	return isForked(c.BLS12381Block, num)
+}
+
+// IsSidecar returns whether num is either equal to the experimental sidecar
+// transactions fork block or greater.
+func (c *ChainConfig) IsSidecar(num *big.Int) bool {
+	return isForked(c.SidecarBlock, num)
+}
+
+// ActiveEVMForks returns the configured instruction set forks activated at num.
+func (c *ChainConfig) ActiveEVMForks(num *big.Int) []*EVMFork {
+	var active []*EVMFork
+	for _, fork := range c.EVMForks {
+		if isForked(fork.Block, num) {
+			active = append(active, fork)
+		}
+	}
+	return active
+}
+
+// BlockReward returns the reward in wei for mining the block of the given number,
+// excluding the bonuses for the included uncles.
+func (c *ChainConfig) BlockReward(num *big.Int) *big.Int {
+	if c.Rewards == nil {
+		switch {
+		case c.IsConstantinople(num):
+			return new(big.Int).Set(ConstantinopleBlockReward)
+		case c.IsByzantium(num):
+			return new(big.Int).Set(ByzantiumBlockReward)
+		default:
+			return new(big.Int).Set(FrontierBlockReward)
+		}
+	}
+	reward := new(big.Int)
+	for _, step := range c.Rewards.Steps {
+		if !isForked(step.Block, num) {
+			break
+		}
+		reward.Set(step.Reward)
+	}
+	if c.Rewards.EraLength > 0 && c.Rewards.EraReduction > 0 {
+		var (
+			keep = big.NewInt(int64(100 - c.Rewards.EraReduction))
+			eras = num.Uint64() / c.Rewards.EraLength
+		)
+		for i := uint64(0); i < eras && reward.Sign() > 0; i++ {
+			reward.Mul(reward, keep)
+			reward.Div(reward, big.NewInt(100))
+		}
+	}
+	return reward
+}
+
+// UncleRewards returns the reward in wei of an uncle miner and the bonus of the
+// block miner for including the uncle, given the reward of the including block.
+func (c *ChainConfig) UncleRewards(num, uncle, blockReward *big.Int) (*big.Int, *big.Int) {
+	uncleDivisor, nephewDivisor := c.Rewards.divisors()
+
+	reward := new(big.Int).Add(uncle, big.NewInt(8))
+	reward.Sub(reward, num)
+	reward.Mul(reward, blockReward)
+	reward.Div(reward, new(big.Int).SetUint64(uncleDivisor))
+
+	bonus := new(big.Int).Div(blockReward, new(big.Int).SetUint64(nephewDivisor))
+	return reward, bonus
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -464,44 +717,11 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 }
 
 // CheckConfigForkOrder checks that we don't "skip" any forks, geth isn't pluggable enough
-// to guarantee that forks can be implemented in a different order than on official networks
+// to guarantee that forks can be implemented in a different order than on official networks.
+// All ordering problems are reported as ConfigErrors, use Validate for a complete check.
 func (c *ChainConfig) CheckConfigForkOrder() error {
-	type fork struct {
-		name     string
-		block    *big.Int
-		optional bool // if true, the fork may be nil and next fork is still allowed
-	}
-	var lastFork fork
-	for _, cur := range []fork{
-		{name: "homesteadBlock", block: c.HomesteadBlock},
-		{name: "daoForkBlock", block: c.DAOForkBlock, optional: true},
-		{name: "eip150Block", block: c.EIP150Block},
-		{name: "eip155Block", block: c.EIP155Block},
-		{name: "eip158Block", block: c.EIP158Block},
-		{name: "byzantiumBlock", block: c.ByzantiumBlock},
-		{name: "constantinopleBlock", block: c.ConstantinopleBlock},
-		{name: "petersburgBlock", block: c.PetersburgBlock},
-		{name: "istanbulBlock", block: c.IstanbulBlock},
-		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
-		{name: "berlinBlock", block: c.BerlinBlock},
-	} {
-		if lastFork.name != "" {
-			// Next one must be higher number
-			if lastFork.block == nil && cur.block != nil {
-				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v",
-					lastFork.name, cur.name, cur.block)
-			}
-			if lastFork.block != nil && cur.block != nil {
-				if lastFork.block.Cmp(cur.block) > 0 {
-					return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
-						lastFork.name, lastFork.block, cur.name, cur.block)
-				}
-			}
-		}
-		// If it was optional and not set, then ignore it
-		if !cur.optional || cur.block != nil {
-			lastFork = cur
-		}
+	if errs := c.checkForkOrder(); len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
@@ -556,6 +776,38 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.BLS12381Block, newcfg.BLS12381Block, head) {
+		return newCompatError("BLS12-381 fork block", c.BLS12381Block, newcfg.BLS12381Block)
+	}
+	if isForkIncompatible(c.SidecarBlock, newcfg.SidecarBlock, head) {
+		return newCompatError("sidecar fork block", c.SidecarBlock, newcfg.SidecarBlock)
+	}
+	if err := c.Rewards.checkCompatible(newcfg.Rewards, head); err != nil {
+		return err
+	}
+	for i := 0; i < len(c.EVMForks) || i < len(newcfg.EVMForks); i++ {
+		var oldFork, newFork *EVMFork
+		if i < len(c.EVMForks) {
+			oldFork = c.EVMForks[i]
+		}
+		if i < len(newcfg.EVMForks) {
+			newFork = newcfg.EVMForks[i]
+		}
+		switch {
+		case oldFork == nil:
+			if isForked(newFork.Block, head) {
+				return newCompatError(fmt.Sprintf("evm fork %q block", newFork.Name), nil, newFork.Block)
+			}
+		case newFork == nil:
+			if isForked(oldFork.Block, head) {
+				return newCompatError(fmt.Sprintf("evm fork %q block", oldFork.Name), oldFork.Block, nil)
+			}
+		case isForkIncompatible(oldFork.Block, newFork.Block, head):
+			return newCompatError(fmt.Sprintf("evm fork %q block", newFork.Name), oldFork.Block, newFork.Block)
+		case isForked(oldFork.Block, head) && !oldFork.sameRules(newFork):
+			return newCompatError(fmt.Sprintf("evm fork %q rules", newFork.Name), oldFork.Block, newFork.Block)
+		}
+	}
 	return nil
 }
 
@@ -623,7 +875,15 @@ type Rules struct {
 	ChainID                                                 *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
-	IsBerlin                                                bool
+	IsBerlin, IsBLS12381, IsSidecar                         bool
+
+	EVMForks []*EVMFork // Instruction set forks active at the block, in activation order
+
+	MaxCallDepth int    // Maximum depth of the call/create stack
+	MaxCodeSize  int    // Maximum bytecode size of a deployed contract
+	MaxMemory    uint64 // Maximum memory size of a call frame (0 = limited by gas only)
+
+	CallGasDivisor uint64 // Divisor of the gas retained by the caller of a call/create
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -632,6 +892,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
+	limits := c.EVMLimits.effective()
 	return Rules{
 		ChainID:          new(big.Int).Set(chainID),
 		IsHomestead:      c.IsHomestead(num),
@@ -643,5 +904,12 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
 		IsBerlin:         c.IsBerlin(num),
+		IsBLS12381:       c.IsBLS12381(num),
+		IsSidecar:        c.IsSidecar(num),
+		EVMForks:         c.ActiveEVMForks(num),
+		MaxCallDepth:     int(limits.MaxCallDepth),
+		MaxCodeSize:      int(limits.MaxCodeSize),
+		MaxMemory:        limits.MaxMemory,
+		CallGasDivisor:   limits.CallGasDivisor,
 	}
 }
@@ -0,0 +1,152 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ConfigErrForkOverride is reported for fork overrides which name an unknown
+// fork or reschedule one that already activated.
+const ConfigErrForkOverride ConfigErrorCode = "fork-override"
+
+// ForkOverrides reschedules forks of a chain config at startup, keyed by the fork
+// name used by the --override.<fork> flags (e.g. "berlin").
+type ForkOverrides map[string]*big.Int
+
+// ScheduledFork is a block based fork in the effective schedule of a chain.
+type ScheduledFork struct {
+	Name     string   // Name of the fork, as used by the override flags
+	Block    *big.Int // Effective switch block (nil = not scheduled)
+	Original *big.Int // Bundled switch block if overridden (nil = not scheduled)
+
+	Overridden bool // Whether the fork was rescheduled at startup
+}
+
+// overrideName converts the JSON name of a fork switch into its override name.
+func overrideName(field string) string {
+	return strings.ToLower(strings.TrimSuffix(field, "Block"))
+}
+
+// OverridableForks returns the names of the forks which can be rescheduled, in
+// activation order.
+func OverridableForks() []string {
+	var names []string
+	for _, fork := range new(ChainConfig).forks() {
+		names = append(names, overrideName(fork.name))
+	}
+	return names
+}
+
+// ApplyOverrides returns a copy of the config with the given forks rescheduled,
+// leaving the receiver untouched. Unknown fork names are reported as ConfigErrors,
+// the fork order of the result is left for Validate to check.
+func (c *ChainConfig) ApplyOverrides(overrides ForkOverrides) (*ChainConfig, error) {
+	if len(overrides) == 0 {
+		return c, nil
+	}
+	cpy := *c
+	cpy.overridden = make(map[string]*big.Int)
+	for name, block := range c.overridden {
+		cpy.overridden[name] = block
+	}
+	forks := make(map[string]configFork)
+	for _, fork := range cpy.forks() {
+		forks[overrideName(fork.name)] = fork
+	}
+	var errs ConfigErrors
+	for _, name := range sortedOverrides(overrides) {
+		fork, ok := forks[name]
+		if !ok {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrForkOverride,
+				Field:      name,
+				Message:    "unknown fork",
+				Suggestion: "use one of " + strings.Join(OverridableForks(), ", "),
+			})
+			continue
+		}
+		if _, ok := cpy.overridden[name]; !ok {
+			cpy.overridden[name] = fork.block
+		}
+		*fork.field = overrides[name]
+	}
+	if len(errs) > 0 {
+		return c, errs
+	}
+	return &cpy, nil
+}
+
+// CheckOverrides verifies that the fork overrides applied to the config don't
+// reschedule any fork which already activated on a chain with the given head,
+// compared to the stored config of that chain.
+func (c *ChainConfig) CheckOverrides(stored *ChainConfig, height uint64) error {
+	var (
+		head = new(big.Int).SetUint64(height)
+		errs ConfigErrors
+	)
+	old := make(map[string]*big.Int)
+	for _, fork := range stored.forks() {
+		old[overrideName(fork.name)] = fork.block
+	}
+	for _, fork := range c.forks() {
+		name := overrideName(fork.name)
+		if _, ok := c.overridden[name]; !ok || configNumEqual(old[name], fork.block) {
+			continue
+		}
+		if isForked(old[name], head) || isForked(fork.block, head) {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrForkOverride,
+				Field:      name,
+				Message:    fmt.Sprintf("rescheduled from %v to %v, but chain is already at block %d", old[name], fork.block, height),
+				Suggestion: fmt.Sprintf("only reschedule forks beyond block %d, or remove the override", height),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ForkSchedule returns the effective block based fork schedule of the config in
+// activation order, marking the forks which were rescheduled at startup.
+func (c *ChainConfig) ForkSchedule() []ScheduledFork {
+	var schedule []ScheduledFork
+	for _, fork := range c.forks() {
+		name := overrideName(fork.name)
+		entry := ScheduledFork{Name: name, Block: fork.block, Original: fork.block}
+		if original, ok := c.overridden[name]; ok {
+			entry.Original, entry.Overridden = original, true
+		}
+		schedule = append(schedule, entry)
+	}
+	return schedule
+}
+
+// sortedOverrides returns the fork names of the overrides in a stable order.
+func sortedOverrides(overrides ForkOverrides) []string {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	base := &ChainConfig{
+		ChainID:             big.NewInt(1337),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: big.NewInt(0),
+		PetersburgBlock:     big.NewInt(0),
+		IstanbulBlock:       big.NewInt(10),
+		BerlinBlock:         big.NewInt(20),
+	}
+	// Unknown forks are rejected without touching the config
+	if _, err := base.ApplyOverrides(ForkOverrides{"london": big.NewInt(30)}); err == nil {
+		t.Fatal("unknown fork override accepted")
+	} else if errs := err.(ConfigErrors); errs[0].Code != ConfigErrForkOverride {
+		t.Fatalf("wrong error code: have %v, want %v", errs[0].Code, ConfigErrForkOverride)
+	}
+	// Overrides are applied to a copy and reported in the schedule
+	cfg, err := base.ApplyOverrides(ForkOverrides{"berlin": big.NewInt(15), "bls12381": big.NewInt(25)})
+	if err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if base.BerlinBlock.Uint64() != 20 || base.BLS12381Block != nil {
+		t.Fatalf("original config modified: berlin %v, bls12381 %v", base.BerlinBlock, base.BLS12381Block)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("overridden config invalid: %v", err)
+	}
+	for _, fork := range cfg.ForkSchedule() {
+		switch fork.Name {
+		case "berlin":
+			if !fork.Overridden || fork.Block.Uint64() != 15 || fork.Original.Uint64() != 20 {
+				t.Errorf("berlin: wrong schedule entry %+v", fork)
+			}
+		case "bls12381":
+			if !fork.Overridden || fork.Block.Uint64() != 25 || fork.Original != nil {
+				t.Errorf("bls12381: wrong schedule entry %+v", fork)
+			}
+		default:
+			if fork.Overridden {
+				t.Errorf("%s: unexpectedly overridden", fork.Name)
+			}
+		}
+	}
+	// Misordering overrides are caught by the validation
+	cfg, err = base.ApplyOverrides(ForkOverrides{"berlin": big.NewInt(5)})
+	if err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("misordered override passed validation")
+	}
+	// Forks may only be rescheduled ahead of the chain head
+	cfg, _ = base.ApplyOverrides(ForkOverrides{"berlin": big.NewInt(30)})
+	if err := cfg.CheckOverrides(base, 19); err != nil {
+		t.Errorf("pending fork override rejected: %v", err)
+	}
+	if err := cfg.CheckOverrides(base, 20); err == nil {
+		t.Error("activated fork override accepted")
+	}
+	cfg, _ = base.ApplyOverrides(ForkOverrides{"berlin": big.NewInt(12)})
+	if err := cfg.CheckOverrides(base, 12); err == nil {
+		t.Error("override to a passed block accepted")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/acent/go-acent/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -87,6 +89,56 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{EVMLimits: &EVMLimits{}},
+			head:    10,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}}},
+			new:     &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}, {Block: big.NewInt(20), Reward: big.NewInt(1)}}}},
+			head:    10,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}, {Block: big.NewInt(5), Reward: big.NewInt(3)}}}},
+			new:    &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}, {Block: big.NewInt(5), Reward: big.NewInt(1)}}}},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         "reward step amount",
+				StoredConfig: big.NewInt(5),
+				NewConfig:    big.NewInt(5),
+				RewindTo:     4,
+			},
+		},
+		{
+			stored:  &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 50}},
+			new:     &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 20, EraReduction: 50}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 50}},
+			new:    &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 20}},
+			head:   30,
+			wantErr: &ConfigCompatError{
+				What:         "reward eras",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}}},
+			new:    &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 20, EraReduction: 50}},
+			head:   30,
+			wantErr: &ConfigCompatError{
+				What:      "reward eras",
+				NewConfig: big.NewInt(20),
+				RewindTo:  19,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +148,113 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+// Tests that settings applying from genesis on can only be changed as long as
+// the chain has no blocks beyond genesis.
+func TestCheckGenesisRules(t *testing.T) {
+	tests := []struct {
+		stored, new *ChainConfig
+		head        uint64
+		wantCode    ConfigErrorCode
+	}{
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{EVMLimits: &EVMLimits{}},
+			head:   10,
+		},
+		{
+			stored: &ChainConfig{EVMLimits: &EVMLimits{MaxCallDepth: 2048}},
+			new:    &ChainConfig{EVMLimits: &EVMLimits{MaxCallDepth: 4096}},
+			head:   0,
+		},
+		{
+			stored:   &ChainConfig{EVMLimits: &EVMLimits{MaxCallDepth: 2048}},
+			new:      &ChainConfig{EVMLimits: &EVMLimits{MaxCallDepth: 4096}},
+			head:     1,
+			wantCode: ConfigErrEVMLimits,
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{EVMLimits: &EVMLimits{CallGasDivisor: CallGasDivisor}},
+			head:   10,
+		},
+		{
+			stored:   &ChainConfig{EVMLimits: &EVMLimits{CallGasDivisor: 32}},
+			new:      &ChainConfig{EVMLimits: &EVMLimits{CallGasDivisor: 2}},
+			head:     5,
+			wantCode: ConfigErrEVMLimits,
+		},
+		{
+			stored:   &ChainConfig{},
+			new:      &ChainConfig{EVMLimits: &EVMLimits{MaxCodeSize: 49152}},
+			head:     10,
+			wantCode: ConfigErrEVMLimits,
+		},
+		{
+			stored:   &ChainConfig{},
+			new:      &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}}},
+			head:     1,
+			wantCode: ConfigErrRewards,
+		},
+		{
+			stored:   &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}}},
+			new:      &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, UncleDivisor: 16}},
+			head:     5,
+			wantCode: ConfigErrRewards,
+		},
+		{
+			stored:   &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}}},
+			new:      &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(3)}}}},
+			head:     5,
+			wantCode: ConfigErrRewards,
+		},
+		{
+			// Era changes from block 10 on are fixed by a rewind
+			stored: &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 50}},
+			new:    &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 20}},
+			head:   30,
+		},
+		{
+			// The lowest conflict reaches back to block 1, past the era change
+			stored:   &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(5)}}, EraLength: 10, EraReduction: 50}},
+			new:      &ChainConfig{Rewards: &RewardSchedule{Steps: []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(3)}}, EraLength: 10, EraReduction: 20}},
+			head:     30,
+			wantCode: ConfigErrRewards,
+		},
+	}
+	for i, test := range tests {
+		err := test.new.CheckGenesisRules(test.stored, test.head)
+		if test.wantCode == "" {
+			if err != nil {
+				t.Errorf("test %d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if errs, ok := err.(ConfigErrors); !ok || errs[0].Code != test.wantCode {
+			t.Errorf("test %d: error mismatch: have %v, want %s", i, err, test.wantCode)
+		}
+	}
+}
+
+func TestBlockReward(t *testing.T) {
+	config := &ChainConfig{Rewards: &RewardSchedule{
+		Steps:        []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(1000)}, {Block: big.NewInt(25), Reward: big.NewInt(600)}},
+		EraLength:    10,
+		EraReduction: 50,
+	}}
+	tests := []struct {
+		number uint64
+		reward int64
+	}{
+		{0, 1000}, {9, 1000}, {10, 500}, {24, 250}, {25, 150}, {30, 75}, {1000, 0},
+	}
+	for _, tt := range tests {
+		if reward := config.BlockReward(new(big.Int).SetUint64(tt.number)); reward.Cmp(big.NewInt(tt.reward)) != 0 {
+			t.Errorf("block %d: reward mismatch: have %v, want %v", tt.number, reward, tt.reward)
+		}
+	}
+	// Without a schedule, the reward of the active fork applies
+	if reward := TestChainConfig.BlockReward(common.Big1); reward.Cmp(ConstantinopleBlockReward) != 0 {
+		t.Errorf("default reward mismatch: have %v, want %v", reward, ConstantinopleBlockReward)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ConfigErrorCode identifies the kind of problem found in a chain configuration.
+type ConfigErrorCode string
+
+const (
+	// ConfigErrForkOrder is reported if a fork is scheduled before its predecessor.
+	ConfigErrForkOrder ConfigErrorCode = "fork-order"
+
+	// ConfigErrForkGap is reported if a fork is enabled while a mandatory
+	// predecessor isn't.
+	ConfigErrForkGap ConfigErrorCode = "fork-gap"
+
+	// ConfigErrChainID is reported if replay protection is enabled without a
+	// chain id to protect with.
+	ConfigErrChainID ConfigErrorCode = "chain-id"
+
+	// ConfigErrEngineConflict is reported if multiple consensus engines are
+	// configured at the same time.
+	ConfigErrEngineConflict ConfigErrorCode = "engine-conflict"
+
+	// ConfigErrEVMFork is reported for malformed data driven EVM forks.
+	ConfigErrEVMFork ConfigErrorCode = "evm-fork"
+
+	// ConfigErrEVMLimits is reported for EVM limits which are out of bounds or
+	// not allowed on the network.
+	ConfigErrEVMLimits ConfigErrorCode = "evm-limits"
+
+	// ConfigErrRewards is reported for malformed reward schedules, or ones which
+	// are not allowed on the network.
+	ConfigErrRewards ConfigErrorCode = "rewards"
+)
+
+const (
+	// maxCallDepthLimit is the highest call depth which can be configured. Each
+	// nested call consumes native stack, so the depth can't be raised unbounded.
+	maxCallDepthLimit = 16384
+
+	// maxCodeSizeLimit is the highest contract size which can be configured.
+	maxCodeSizeLimit = 16 * 1024 * 1024
+)
+
+// ConfigError is a single problem found in a chain configuration, along with a
+// hint on how to fix it.
+type ConfigError struct {
+	Code       ConfigErrorCode // Machine readable kind of the problem
+	Field      string          // JSON name of the offending field
+	Message    string          // Description of the problem
+	Suggestion string          // Proposed fix, if any
+}
+
+// Error implements error.
+func (err *ConfigError) Error() string {
+	msg := fmt.Sprintf("%s: %s [%s]", err.Field, err.Message, err.Code)
+	if err.Suggestion != "" {
+		msg += " (" + err.Suggestion + ")"
+	}
+	return msg
+}
+
+// ConfigErrors is the list of all problems found in a chain configuration.
+type ConfigErrors []*ConfigError
+
+// Error implements error.
+func (errs ConfigErrors) Error() string {
+	if len(errs) == 1 {
+		return "invalid chain config: " + errs[0].Error()
+	}
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("invalid chain config, %d problems:\n%s", len(errs), strings.Join(lines, "\n"))
+}
+
+// configFork is a fork switch of the chain config, in activation order.
+type configFork struct {
+	name     string
+	block    *big.Int
+	field    **big.Int // switch field of the config, used to reschedule the fork
+	optional bool      // if true, the fork may be nil and next fork is still allowed
+}
+
+// forks returns the block based forks of the config in their mandatory order.
+func (c *ChainConfig) forks() []configFork {
+	return []configFork{
+		{name: "homesteadBlock", block: c.HomesteadBlock, field: &c.HomesteadBlock},
+		{name: "daoForkBlock", block: c.DAOForkBlock, field: &c.DAOForkBlock, optional: true},
+		{name: "eip150Block", block: c.EIP150Block, field: &c.EIP150Block},
+		{name: "eip155Block", block: c.EIP155Block, field: &c.EIP155Block},
+		{name: "eip158Block", block: c.EIP158Block, field: &c.EIP158Block},
+		{name: "byzantiumBlock", block: c.ByzantiumBlock, field: &c.ByzantiumBlock},
+		{name: "constantinopleBlock", block: c.ConstantinopleBlock, field: &c.ConstantinopleBlock},
+		{name: "petersburgBlock", block: c.PetersburgBlock, field: &c.PetersburgBlock},
+		{name: "istanbulBlock", block: c.IstanbulBlock, field: &c.IstanbulBlock},
+		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, field: &c.MuirGlacierBlock, optional: true},
+		{name: "berlinBlock", block: c.BerlinBlock, field: &c.BerlinBlock},
+		{name: "bls12381Block", block: c.BLS12381Block, field: &c.BLS12381Block, optional: true},
+		{name: "sidecarBlock", block: c.SidecarBlock, field: &c.SidecarBlock, optional: true},
+	}
+}
+
+// checkForkOrder verifies that no forks are skipped and that they are scheduled
+// in ascending order, reporting every violation.
+func (c *ChainConfig) checkForkOrder() ConfigErrors {
+	var (
+		errs     ConfigErrors
+		lastFork configFork
+	)
+	for _, cur := range c.forks() {
+		if lastFork.name != "" {
+			// Next one must be higher number
+			if lastFork.block == nil && cur.block != nil {
+				errs = append(errs, &ConfigError{
+					Code:       ConfigErrForkGap,
+					Field:      cur.name,
+					Message:    fmt.Sprintf("enabled at %v, but %v is not enabled", cur.block, lastFork.name),
+					Suggestion: fmt.Sprintf("set %v to %v or lower, or remove %v", lastFork.name, cur.block, cur.name),
+				})
+			}
+			if lastFork.block != nil && cur.block != nil && lastFork.block.Cmp(cur.block) > 0 {
+				errs = append(errs, &ConfigError{
+					Code:       ConfigErrForkOrder,
+					Field:      cur.name,
+					Message:    fmt.Sprintf("enabled at %v, before %v at %v", cur.block, lastFork.name, lastFork.block),
+					Suggestion: fmt.Sprintf("set %v to %v or higher", cur.name, lastFork.block),
+				})
+			}
+		}
+		// If it was optional and not set, then ignore it
+		if !cur.optional || cur.block != nil {
+			lastFork = cur
+		}
+	}
+	// Instruction set forks are layered on top of each other, so they must be
+	// activated in the order they are declared
+	var last *EVMFork
+	for i, fork := range c.EVMForks {
+		field := fmt.Sprintf("evmForks[%d]", i)
+		if fork == nil {
+			errs = append(errs, &ConfigError{Code: ConfigErrEVMFork, Field: field, Message: "empty fork definition", Suggestion: "remove the entry"})
+			continue
+		}
+		if fork.Name == "" {
+			errs = append(errs, &ConfigError{Code: ConfigErrEVMFork, Field: field + ".name", Message: "missing fork name", Suggestion: "name the fork, it is used in logs and errors"})
+		}
+		if fork.Block == nil {
+			continue
+		}
+		if last != nil && last.Block.Cmp(fork.Block) > 0 {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrForkOrder,
+				Field:      field + ".block",
+				Message:    fmt.Sprintf("evm fork %q enabled at %v, before %q at %v", fork.Name, fork.Block, last.Name, last.Block),
+				Suggestion: "list the evm forks in activation order",
+			})
+		}
+		last = fork
+	}
+	return errs
+}
+
+// Validate checks the chain config for every misordered fork, inconsistent
+// setting and engine conflict, returning all problems found as ConfigErrors,
+// or nil if the config is valid.
+func (c *ChainConfig) Validate() error {
+	errs := c.checkForkOrder()
+	errs = append(errs, c.checkConsistency()...)
+	errs = append(errs, c.checkEVMLimits()...)
+	errs = append(errs, c.checkRewards()...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateStored checks the chain config of an existing chain like Validate, but
+// skips the consistency checks older versions didn't enforce, so that datadirs
+// created with such configs still start. Fork ordering and the safety bounds of
+// the EVM limits and reward schedule are always enforced.
+func (c *ChainConfig) ValidateStored() error {
+	errs := c.checkForkOrder()
+	errs = append(errs, c.checkEVMLimits()...)
+	errs = append(errs, c.checkRewards()...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkConsistency verifies that replay protection has a chain id to protect
+// with and that only one consensus engine is configured.
+func (c *ChainConfig) checkConsistency() ConfigErrors {
+	var errs ConfigErrors
+	if c.EIP155Block != nil && (c.ChainID == nil || c.ChainID.Sign() <= 0) {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrChainID,
+			Field:      "chainId",
+			Message:    fmt.Sprintf("replay protection enabled at %v without a positive chain id", c.EIP155Block),
+			Suggestion: "set chainId to a unique positive number",
+		})
+	}
+	if c.Ethash != nil && c.Clique != nil {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEngineConflict,
+			Field:      "clique",
+			Message:    "both ethash and clique consensus engines configured",
+			Suggestion: "remove ethash for a proof-of-authority network, or clique for a proof-of-work one",
+		})
+	}
+	return errs
+}
+
+// isPublic reports whether the config belongs to one of the public networks.
+func (c *ChainConfig) isPublic() bool {
+	for _, public := range []*ChainConfig{MainnetChainConfig, RopstenChainConfig, RinkebyChainConfig, GoerliChainConfig} {
+		if configNumEqual(c.ChainID, public.ChainID) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEVMLimits verifies that the interpreter limits are within safe bounds and
+// are only overridden on private networks.
+func (c *ChainConfig) checkEVMLimits() ConfigErrors {
+	if c.EVMLimits.equal(nil) {
+		return nil
+	}
+	var errs ConfigErrors
+	if c.isPublic() {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEVMLimits,
+			Field:      "evmLimits",
+			Message:    fmt.Sprintf("interpreter limits overridden on public network with chain id %v", c.ChainID),
+			Suggestion: "remove evmLimits, or use a private chain id",
+		})
+	}
+	if c.EVMLimits.MaxCallDepth > maxCallDepthLimit {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEVMLimits,
+			Field:      "evmLimits.maxCallDepth",
+			Message:    fmt.Sprintf("call depth %d exceeds the supported maximum", c.EVMLimits.MaxCallDepth),
+			Suggestion: fmt.Sprintf("set maxCallDepth to %d or lower", maxCallDepthLimit),
+		})
+	}
+	if c.EVMLimits.MaxCodeSize > maxCodeSizeLimit {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEVMLimits,
+			Field:      "evmLimits.maxCodeSize",
+			Message:    fmt.Sprintf("code size %d exceeds the supported maximum", c.EVMLimits.MaxCodeSize),
+			Suggestion: fmt.Sprintf("set maxCodeSize to %d or lower", maxCodeSizeLimit),
+		})
+	}
+	if c.EVMLimits.CallGasDivisor == 1 {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEVMLimits,
+			Field:      "evmLimits.callGasDivisor",
+			Message:    "call gas divisor 1 forwards no gas to callees",
+			Suggestion: fmt.Sprintf("set callGasDivisor to 2 or higher, or omit it to use %d", CallGasDivisor),
+		})
+	}
+	return errs
+}
+
+// CheckGenesisRules verifies that the settings applying to every block from
+// genesis on, the EVM limits and any reward change reaching back to block 1, are
+// unchanged compared to the stored config of a chain with the given head. Such
+// changes can't be fixed by rewinding to a fork block, so they are rejected
+// unless the chain has no blocks beyond genesis yet.
+func (c *ChainConfig) CheckGenesisRules(stored *ChainConfig, height uint64) error {
+	if height == 0 {
+		return nil
+	}
+	var errs ConfigErrors
+	if !c.EVMLimits.equal(stored.EVMLimits) {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrEVMLimits,
+			Field:      "evmLimits",
+			Message:    fmt.Sprintf("interpreter limits changed, but chain is already at block %d", height),
+			Suggestion: "restore the previous evmLimits, or resync the chain from genesis",
+		})
+	}
+	// Reward changes from a later block on are fixed by a rewind, look for the
+	// lowest conflict to see whether any reaches back to block 1
+	head := new(big.Int).SetUint64(height)
+	for {
+		err := stored.Rewards.checkCompatible(c.Rewards, head)
+		if err == nil {
+			break
+		}
+		if err.RewindTo == 0 {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrRewards,
+				Field:      "rewards",
+				Message:    fmt.Sprintf("%s changed from block 1 on, but chain is already at block %d", err.What, height),
+				Suggestion: "restore the previous rewards, or resync the chain from genesis",
+			})
+			break
+		}
+		head.SetUint64(err.RewindTo)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRewards verifies that the reward schedule is well formed, only replaces
+// the issuance of private proof-of-work networks and never rewards uncles above
+// the block they are included in.
+func (c *ChainConfig) checkRewards() ConfigErrors {
+	if c.Rewards == nil {
+		return nil
+	}
+	var errs ConfigErrors
+	if c.isPublic() {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards",
+			Message:    fmt.Sprintf("issuance overridden on public network with chain id %v", c.ChainID),
+			Suggestion: "remove rewards, or use a private chain id",
+		})
+	}
+	if c.Clique != nil {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards",
+			Message:    "reward schedule configured for clique, which doesn't issue rewards",
+			Suggestion: "remove rewards",
+		})
+	}
+	if len(c.Rewards.Steps) == 0 {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards.steps",
+			Message:    "no block reward defined",
+			Suggestion: "add a step with the reward from block 0",
+		})
+	}
+	var last *RewardStep
+	for i, step := range c.Rewards.Steps {
+		field := fmt.Sprintf("rewards.steps[%d]", i)
+		switch {
+		case step == nil || step.Block == nil:
+			errs = append(errs, &ConfigError{Code: ConfigErrRewards, Field: field + ".block", Message: "missing step block", Suggestion: "set the block the reward is effective from"})
+			continue
+		case step.Reward == nil || step.Reward.Sign() < 0:
+			errs = append(errs, &ConfigError{Code: ConfigErrRewards, Field: field + ".reward", Message: "missing or negative reward", Suggestion: "set the block reward in wei, 0 to stop issuance"})
+		}
+		if last == nil && step.Block.Sign() != 0 {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrRewards,
+				Field:      field + ".block",
+				Message:    fmt.Sprintf("first reward effective from %v, leaving earlier blocks unrewarded", step.Block),
+				Suggestion: "set the block of the first step to 0",
+			})
+		}
+		if last != nil && last.Block.Cmp(step.Block) >= 0 {
+			errs = append(errs, &ConfigError{
+				Code:       ConfigErrRewards,
+				Field:      field + ".block",
+				Message:    fmt.Sprintf("reward effective from %v, not after the previous step at %v", step.Block, last.Block),
+				Suggestion: "list the steps in ascending block order",
+			})
+		}
+		last = step
+	}
+	if c.Rewards.EraReduction > 100 {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards.eraReduction",
+			Message:    fmt.Sprintf("reduction of %d%% per era exceeds the reward", c.Rewards.EraReduction),
+			Suggestion: "set eraReduction to 100 or lower",
+		})
+	}
+	if c.Rewards.EraReduction > 0 && c.Rewards.EraLength == 0 {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards.eraLength",
+			Message:    "era reduction configured without eras",
+			Suggestion: "set eraLength to the number of blocks per era, or remove eraReduction",
+		})
+	}
+	if c.Rewards.UncleDivisor != 0 && c.Rewards.UncleDivisor < 8 {
+		errs = append(errs, &ConfigError{
+			Code:       ConfigErrRewards,
+			Field:      "rewards.uncleDivisor",
+			Message:    fmt.Sprintf("uncle divisor %d rewards uncles as much as the block or more", c.Rewards.UncleDivisor),
+			Suggestion: "set uncleDivisor to 8 or higher, or omit it to use 8",
+		})
+	}
+	return errs
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		config *ChainConfig
+		codes  []ConfigErrorCode
+		fields []string
+	}{
+		{config: MainnetChainConfig},
+		{config: AllEthashProtocolChanges},
+		{config: AllCliqueProtocolChanges},
+		// Every misordering should be reported, not just the first
+		{
+			config: &ChainConfig{
+				ChainID:        big.NewInt(1),
+				HomesteadBlock: big.NewInt(10),
+				EIP150Block:    big.NewInt(5),
+				EIP155Block:    big.NewInt(20),
+				EIP158Block:    big.NewInt(15),
+			},
+			codes:  []ConfigErrorCode{ConfigErrForkOrder, ConfigErrForkOrder},
+			fields: []string{"eip150Block", "eip158Block"},
+		},
+		// Skipped forks, missing chain id and engine conflicts
+		{
+			config: &ChainConfig{
+				HomesteadBlock: big.NewInt(0),
+				EIP155Block:    big.NewInt(0),
+				Ethash:         new(EthashConfig),
+				Clique:         &CliqueConfig{Period: 15, Epoch: 30000},
+			},
+			codes:  []ConfigErrorCode{ConfigErrForkGap, ConfigErrChainID, ConfigErrEngineConflict},
+			fields: []string{"eip155Block", "chainId", "clique"},
+		},
+		// Misordered and unnamed evm forks
+		{
+			config: &ChainConfig{
+				EVMForks: []*EVMFork{{Name: "b", Block: big.NewInt(20)}, {Block: big.NewInt(10)}},
+			},
+			codes:  []ConfigErrorCode{ConfigErrEVMFork, ConfigErrForkOrder},
+			fields: []string{"evmForks[1].name", "evmForks[1].block"},
+		},
+		// Interpreter limits on a private network, out of bounds and on a public one
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1337), EVMLimits: &EVMLimits{MaxCallDepth: 4096, MaxMemory: 1 << 20}},
+		},
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1337), EVMLimits: &EVMLimits{MaxCallDepth: 1 << 20, MaxCodeSize: 1 << 30}},
+			codes:  []ConfigErrorCode{ConfigErrEVMLimits, ConfigErrEVMLimits},
+			fields: []string{"evmLimits.maxCallDepth", "evmLimits.maxCodeSize"},
+		},
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1337), EVMLimits: &EVMLimits{CallGasDivisor: 1}},
+			codes:  []ConfigErrorCode{ConfigErrEVMLimits},
+			fields: []string{"evmLimits.callGasDivisor"},
+		},
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1), EVMLimits: &EVMLimits{MaxCodeSize: 49152}},
+			codes:  []ConfigErrorCode{ConfigErrEVMLimits},
+			fields: []string{"evmLimits"},
+		},
+		// Reward schedules on a private network, malformed and on a public one
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1337), Rewards: &RewardSchedule{
+				Steps:        []*RewardStep{{Block: big.NewInt(0), Reward: big.NewInt(1e18)}, {Block: big.NewInt(100), Reward: big.NewInt(0)}},
+				EraLength:    10,
+				EraReduction: 50,
+			}},
+		},
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1337), Rewards: &RewardSchedule{
+				Steps:        []*RewardStep{{Block: big.NewInt(10), Reward: big.NewInt(1e18)}, {Block: big.NewInt(10), Reward: big.NewInt(-1)}},
+				EraReduction: 101,
+				UncleDivisor: 4,
+			}},
+			codes:  []ConfigErrorCode{ConfigErrRewards, ConfigErrRewards, ConfigErrRewards, ConfigErrRewards, ConfigErrRewards, ConfigErrRewards},
+			fields: []string{"rewards.steps[0].block", "rewards.steps[1].reward", "rewards.steps[1].block", "rewards.eraReduction", "rewards.eraLength", "rewards.uncleDivisor"},
+		},
+		{
+			config: &ChainConfig{ChainID: big.NewInt(1), Rewards: &RewardSchedule{}},
+			codes:  []ConfigErrorCode{ConfigErrRewards, ConfigErrRewards},
+			fields: []string{"rewards", "rewards.steps"},
+		},
+	}
+	for i, tt := range tests {
+		err := tt.config.Validate()
+		if len(tt.codes) == 0 {
+			if err != nil {
+				t.Errorf("test %d: valid config rejected: %v", i, err)
+			}
+			continue
+		}
+		errs, ok := err.(ConfigErrors)
+		if !ok {
+			t.Errorf("test %d: unexpected error type: %T", i, err)
+			continue
+		}
+		var (
+			codes  []ConfigErrorCode
+			fields []string
+		)
+		for _, err := range errs {
+			codes = append(codes, err.Code)
+			fields = append(fields, err.Field)
+			if err.Suggestion == "" {
+				t.Errorf("test %d: no suggestion for %v", i, err)
+			}
+		}
+		if !reflect.DeepEqual(codes, tt.codes) || !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("test %d: problems mismatch: have %v %v, want %v %v", i, codes, fields, tt.codes, tt.fields)
+		}
+	}
+}
+
+// Tests that stored configs skip the consistency checks older versions didn't
+// enforce, but are still checked for fork ordering and safety bounds.
+func TestConfigValidationStored(t *testing.T) {
+	legacy := &ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(0), EIP155Block: big.NewInt(0)}
+	if err := legacy.ValidateStored(); err != nil {
+		t.Errorf("legacy config rejected: %v", err)
+	}
+	if err := legacy.Validate(); err == nil {
+		t.Error("legacy config accepted as fresh config")
+	}
+	unsafe := &ChainConfig{ChainID: big.NewInt(1337), EVMLimits: &EVMLimits{MaxCallDepth: 1 << 20}}
+	if errs, ok := unsafe.ValidateStored().(ConfigErrors); !ok || errs[0].Code != ConfigErrEVMLimits {
+		t.Errorf("out of bounds limits accepted: %v", errs)
+	}
+	misordered := &ChainConfig{HomesteadBlock: big.NewInt(10), EIP150Block: big.NewInt(5)}
+	if errs, ok := misordered.ValidateStored().(ConfigErrors); !ok || errs[0].Code != ConfigErrForkOrder {
+		t.Errorf("misordered forks accepted: %v", errs)
+	}
+}
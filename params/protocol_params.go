@@ -62,6 +62,7 @@ const (
 
 	CreateDataGas         uint64 = 200   //
 	CallCreateDepth       uint64 = 1024  // Maximum depth of call/create stack.
+	CallGasDivisor        uint64 = 64    // Divisor of the gas retained by the caller of a call/create (EIP-150).
 	ExpGas                uint64 = 10    // Once per EXP instruction
 	LogGas                uint64 = 375   // Per LOG* operation.
 	CopyGas               uint64 = 3     //
@@ -110,6 +111,11 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	// Limits of the sidecar data committed to by experimental sidecar transactions.
+	MaxSidecarBlobSize   = 128 * 1024 // Maximum size of a sidecar blob
+	MaxTxSidecarBlobs    = 4          // Maximum number of blobs of a sidecar transaction
+	MaxBlockSidecarBlobs = 16         // Maximum number of blobs committed to by the transactions of a block
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas        uint64 = 3000 // Elliptic curve sender recovery gas price
@@ -147,4 +153,8 @@ var (
 	GenesisDifficulty      = big.NewInt(131072) // Difficulty of the Genesis block.
 	MinimumDifficulty      = big.NewInt(131072) // The minimum that the difficulty may ever be.
 	DurationLimit          = big.NewInt(13)     // The decision boundary on the blocktime duration used to determine whether difficulty should go up or not.
+
+	FrontierBlockReward       = big.NewInt(5e+18) // Block reward in wei for successfully mining a block
+	ByzantiumBlockReward      = big.NewInt(3e+18) // Block reward in wei for successfully mining a block upward from Byzantium
+	ConstantinopleBlockReward = big.NewInt(2e+18) // Block reward in wei for successfully mining a block upward from Constantinople
 )
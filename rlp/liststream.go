@@ -0,0 +1,129 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errListSizeMismatch = errors.New("rlp: written list content does not match the announced size")
+
+// ListWriter encodes a list element by element, writing every element to the
+// underlying writer as soon as it's encoded. This allows encoding lists too large
+// to be held in memory, like a whole exported chain. As the list header leads the
+// content, the total size of the encoded elements must be known upfront.
+type ListWriter struct {
+	w       io.Writer
+	size    uint64 // content size announced in the list header
+	written uint64 // content size written so far
+}
+
+// NewListWriter writes the header of a list of the given content size to w and
+// returns a writer for the list elements. The content size is the sum of the
+// encoded sizes of all elements.
+func NewListWriter(w io.Writer, size uint64) (*ListWriter, error) {
+	var head [9]byte
+	if _, err := w.Write(head[:puthead(head[:], 0xC0, 0xF7, size)]); err != nil {
+		return nil, err
+	}
+	return &ListWriter{w: w, size: size}, nil
+}
+
+// Encode encodes val as the next element of the list.
+func (lw *ListWriter) Encode(val interface{}) error {
+	eb := encbufPool.Get().(*encbuf)
+	defer encbufPool.Put(eb)
+	eb.reset()
+	if err := eb.encode(val); err != nil {
+		return err
+	}
+	if lw.written+uint64(eb.size()) > lw.size {
+		return errListSizeMismatch
+	}
+	lw.written += uint64(eb.size())
+	return eb.toWriter(lw.w)
+}
+
+// WriteRaw writes already encoded elements to the list.
+func (lw *ListWriter) WriteRaw(b []byte) error {
+	if lw.written+uint64(len(b)) > lw.size {
+		return errListSizeMismatch
+	}
+	lw.written += uint64(len(b))
+	_, err := lw.w.Write(b)
+	return err
+}
+
+// Close checks that the written elements add up to the announced content size.
+// It doesn't close the underlying writer.
+func (lw *ListWriter) Close() error {
+	if lw.written != lw.size {
+		return fmt.Errorf("%w: wrote %d bytes, announced %d", errListSizeMismatch, lw.written, lw.size)
+	}
+	return nil
+}
+
+// ListReader decodes a list element by element from a stream, without reading
+// the whole list into memory.
+type ListReader struct {
+	s    *Stream
+	size uint64
+	done bool
+}
+
+// NewListReader reads the header of a list from r and returns a reader for its
+// elements. The size of the list isn't limited.
+func NewListReader(r io.Reader) (*ListReader, error) {
+	s := NewStream(r, 0)
+	size, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return &ListReader{s: s, size: size}, nil
+}
+
+// Size returns the content size of the list, as announced by its header.
+func (lr *ListReader) Size() uint64 {
+	return lr.size
+}
+
+// Decode decodes the next element of the list into val. It returns io.EOF when
+// all elements have been read.
+func (lr *ListReader) Decode(val interface{}) error {
+	if lr.done {
+		return io.EOF
+	}
+	err := lr.s.Decode(val)
+	if err == EOL {
+		if err = lr.s.ListEnd(); err == nil {
+			lr.done, err = true, io.EOF
+		}
+	}
+	return err
+}
+
+// Raw reads the encoding of the next element of the list. It returns io.EOF when
+// all elements have been read.
+func (lr *ListReader) Raw() ([]byte, error) {
+	var raw RawValue
+	if err := lr.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
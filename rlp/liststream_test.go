@@ -0,0 +1,118 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rlp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestListWriter(t *testing.T) {
+	var (
+		elems []string
+		size  uint64
+	)
+	for i := 0; i < 1000; i++ {
+		elem := fmt.Sprintf("element %d", i)
+		elems = append(elems, elem)
+		size += uint64(len(elem) + 1)
+	}
+	var buf bytes.Buffer
+	lw, err := NewListWriter(&buf, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range elems {
+		if i%2 == 0 {
+			err = lw.Encode(elem)
+		} else {
+			err = lw.WriteRaw(append([]byte{0x80 + byte(len(elem))}, elem...))
+		}
+		if err != nil {
+			t.Fatalf("element %d: write error: %v", i, err)
+		}
+	}
+	if err := lw.Close(); err != nil {
+		t.Fatal("close error:", err)
+	}
+	want, _ := EncodeToBytes(elems)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("encoding mismatch:\nhave %x\nwant %x", buf.Bytes(), want)
+	}
+}
+
+func TestListWriterSizeMismatch(t *testing.T) {
+	lw, _ := NewListWriter(new(bytes.Buffer), 6)
+	if err := lw.Encode("abc"); err != nil {
+		t.Fatal("write error:", err)
+	}
+	if err := lw.Encode("abc"); err == nil {
+		t.Fatal("wrote beyond the announced size")
+	}
+	if err := lw.Close(); err == nil {
+		t.Fatal("closed short list")
+	}
+}
+
+func TestListReader(t *testing.T) {
+	elems := make([]uint64, 1000)
+	for i := range elems {
+		elems[i] = uint64(i * i)
+	}
+	enc, _ := EncodeToBytes(elems)
+
+	// Read from a plain reader too, which the stream has to buffer itself
+	readers := []io.Reader{
+		bytes.NewReader(enc),
+		io.MultiReader(bytes.NewReader(enc)),
+	}
+	for i, r := range readers {
+		lr, err := NewListReader(r)
+		if err != nil {
+			t.Fatalf("reader %d: open error: %v", i, err)
+		}
+		if lr.Size() != uint64(len(enc)-3) {
+			t.Errorf("reader %d: size mismatch: have %d, want %d", i, lr.Size(), len(enc)-3)
+		}
+		var have []uint64
+		for {
+			var elem uint64
+			if err := lr.Decode(&elem); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("reader %d: element %d: decode error: %v", i, len(have), err)
+			}
+			have = append(have, elem)
+		}
+		if len(have) != len(elems) {
+			t.Fatalf("reader %d: element count mismatch: have %d, want %d", i, len(have), len(elems))
+		}
+		for j := range elems {
+			if have[j] != elems[j] {
+				t.Fatalf("reader %d: element %d mismatch: have %d, want %d", i, j, have[j], elems[j])
+			}
+		}
+		if _, err := lr.Raw(); err != io.EOF {
+			t.Errorf("reader %d: read past the end: %v", i, err)
+		}
+	}
+	if _, err := NewListReader(bytes.NewReader([]byte{0x83, 'a', 'b', 'c'})); err != ErrExpectedList {
+		t.Errorf("non-list error mismatch: have %v, want %v", err, ErrExpectedList)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jwtExpiryTimeout is the maximum allowed difference between the issuance time
+// of a token and the local clock, limiting the window for replaying it.
+const jwtExpiryTimeout = 60 * time.Second
+
+var (
+	errJWTMissing   = errors.New("missing token")
+	errJWTMalformed = errors.New("malformed token")
+	errJWTAlgorithm = errors.New("unsupported token algorithm")
+	errJWTSignature = errors.New("invalid token signature")
+	errJWTIssuedAt  = errors.New("missing token issuance time")
+	errJWTStale     = errors.New("stale token")
+)
+
+// jwtHeader is the JOSE header of a JSON web token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// jwtClaims are the claims of a JSON web token checked by the server.
+type jwtClaims struct {
+	IssuedAt *int64 `json:"iat"`
+}
+
+// jwtHandler authenticates requests by a JSON web token before passing them on.
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// NewJWTHandler creates an HTTP handler which only passes on the requests carrying
+// a JSON web token in their Authorization header, signed with the given secret
+// (HS256) and issued at most a minute off the local clock.
+func NewJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, next: next}
+}
+
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, errJWTMissing.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := VerifyJWT(h.secret, strings.TrimPrefix(auth, "Bearer ")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// NewJWTToken creates a JSON web token signed with the given secret (HS256),
+// issued at the given time. It is meant for clients of authenticated endpoints.
+func NewJWTToken(secret []byte, issued time.Time) string {
+	header, _ := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	iat := issued.Unix()
+	claims, _ := json.Marshal(jwtClaims{IssuedAt: &iat})
+
+	payload := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(jwtSign(secret, payload))
+}
+
+// VerifyJWT checks that a JSON web token is signed with the given secret (HS256)
+// and was issued at most a minute off the local clock.
+func VerifyJWT(secret []byte, token string) error {
+	return verifyJWT(secret, token, time.Now())
+}
+
+// verifyJWT checks the signature and the issuance time of a JSON web token.
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errJWTMalformed
+	}
+	var header jwtHeader
+	if err := jwtDecode(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return errJWTAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errJWTMalformed
+	}
+	if !hmac.Equal(sig, jwtSign(secret, parts[0]+"."+parts[1])) {
+		return errJWTSignature
+	}
+	var claims jwtClaims
+	if err := jwtDecode(parts[1], &claims); err != nil {
+		return err
+	}
+	if claims.IssuedAt == nil {
+		return errJWTIssuedAt
+	}
+	if diff := now.Sub(time.Unix(*claims.IssuedAt, 0)); diff > jwtExpiryTimeout || diff < -jwtExpiryTimeout {
+		return errJWTStale
+	}
+	return nil
+}
+
+// jwtDecode decodes a base64url encoded JSON segment of a token.
+func jwtDecode(segment string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
+
+// jwtSign computes the HS256 signature of the signed part of a token.
+func jwtSign(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTVerification(t *testing.T) {
+	var (
+		secret = []byte("0123456789abcdef0123456789abcdef")
+		now    = time.Now()
+		valid  = NewJWTToken(secret, now)
+	)
+	// Swap the algorithm of a valid token for "none"
+	parts := strings.Split(valid, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	unsigned := strings.Join(parts, ".")
+
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{valid, nil},
+		{NewJWTToken(secret, now.Add(-jwtExpiryTimeout+time.Second)), nil},
+		{NewJWTToken(secret, now.Add(jwtExpiryTimeout-time.Second)), nil},
+		{NewJWTToken(secret, now.Add(-jwtExpiryTimeout-time.Second)), errJWTStale},
+		{NewJWTToken(secret, now.Add(jwtExpiryTimeout+time.Second)), errJWTStale},
+		{NewJWTToken([]byte("wrong secret"), now), errJWTSignature},
+		{unsigned, errJWTAlgorithm},
+		{"not.a.token", errJWTMalformed},
+		{"token", errJWTMalformed},
+	}
+	for i, test := range tests {
+		if err := verifyJWT(secret, test.token, now); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func TestJWTHandler(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	handler := NewJWTHandler(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, auth := range []string{"", "Basic dXNlcjpwYXNz", "Bearer " + NewJWTToken([]byte("wrong"), time.Now())} {
+		req := httptest.NewRequest("POST", "http://url.com", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		confirmStatusCode(t, rec.Code, http.StatusUnauthorized)
+	}
+	req := httptest.NewRequest("POST", "http://url.com", nil)
+	req.Header.Set("Authorization", "Bearer "+NewJWTToken(secret, time.Now()))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	confirmStatusCode(t, rec.Code, http.StatusOK)
+}
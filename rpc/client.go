@@ -74,6 +74,8 @@ type BatchElem struct {
 
 // Client represents a connection to an RPC server.
 type Client struct {
+	callTimeout int64 // Default deadline of calls without one (atomic, keep 64-bit aligned)
+
 	idgen    func() ID // for subscriptions
 	isHTTP   bool
 	services *serviceRegistry
@@ -273,6 +275,42 @@ func (c *Client) SetHeader(key, value string) {
 	conn.mu.Unlock()
 }
 
+// SetHeaderFunc installs a callback which is invoked before every HTTP request
+// to add dynamic headers, such as short lived access tokens. Headers set by the
+// callback override the ones set via SetHeader. If the callback fails, the
+// request is aborted with its error.
+//
+// This method only works for clients using HTTP, it doesn't have any effect for
+// clients using another transport.
+func (c *Client) SetHeaderFunc(fn HeaderFunc) {
+	if !c.isHTTP {
+		return
+	}
+	conn := c.writeConn.(*httpConn)
+	conn.mu.Lock()
+	conn.headerFn = fn
+	conn.mu.Unlock()
+}
+
+// SetCallTimeout sets the default deadline applied to calls whose context does
+// not carry one already. A zero timeout disables the default deadline.
+func (c *Client) SetCallTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&c.callTimeout, int64(timeout))
+}
+
+// withCallTimeout derives a context bounded by the client's default call timeout
+// if the given context has no deadline of its own.
+func (c *Client) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(atomic.LoadInt64(&c.callTimeout))
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // Call performs a JSON-RPC call with the given arguments and unmarshals into
 // result if no error occurred.
 //
@@ -292,6 +330,9 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, method str
 	if result != nil && reflect.TypeOf(result).Kind() != reflect.Ptr {
 		return fmt.Errorf("call result parameter must be pointer or nil interface: %v", result)
 	}
+	ctx, cancel := c.withCallTimeout(ctx)
+	defer cancel()
+
 	msg, err := c.newMessage(method, args...)
 	if err != nil {
 		return err
@@ -342,6 +383,9 @@ func (c *Client) BatchCall(b []BatchElem) error {
 //
 // Note that batch calls may not be executed atomically on the server side.
 func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
+	ctx, cancel := c.withCallTimeout(ctx)
+	defer cancel()
+
 	msgs := make([]*jsonrpcMessage, len(b))
 	op := &requestOp{
 		ids:  make([]json.RawMessage, len(b)),
@@ -23,11 +23,20 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/acent/go-acent/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer creates the spans of the served calls. It does nothing unless tracing
+// is set up by the application.
+var tracer = otel.Tracer("github.com/acent/go-acent/rpc")
+
 // handler handles JSON-RPC messages. There is one handler per connection. Note that
 // handler is not safe for concurrent use. Message handling never blocks indefinitely
 // because RPCs are processed on background goroutines launched by handler.
@@ -61,6 +70,7 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	batchSlots     chan struct{} // bounds the batch calls served concurrently, nil if sequential
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -88,6 +98,9 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	if n := reg.batchParallelism(); n > 1 {
+		h.batchSlots = make(chan struct{}, n)
+	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -114,10 +127,15 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
-		for _, msg := range calls {
-			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+		var answers []*jsonrpcMessage
+		if h.batchSlots != nil && !hasSubscriptions(calls) {
+			answers = h.handleCallsParallel(cp, calls)
+		} else {
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			for _, msg := range calls {
+				if answer := h.handleCallMsg(cp, msg); answer != nil {
+					answers = append(answers, answer)
+				}
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -130,6 +148,50 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	})
 }
 
+// handleCallsParallel serves the calls of a batch concurrently, bounded by the batch
+// parallelism of the connection, and returns their answers in request order.
+func (h *handler) handleCallsParallel(cp *callProc, calls []*jsonrpcMessage) []*jsonrpcMessage {
+	var (
+		results = make([]*jsonrpcMessage, len(calls))
+		wg      sync.WaitGroup
+	)
+	for i, msg := range calls {
+		select {
+		case h.batchSlots <- struct{}{}:
+		case <-cp.ctx.Done():
+			// The connection is closing, nobody will read the answers.
+			wg.Wait()
+			return nil
+		}
+		wg.Add(1)
+		go func(i int, msg *jsonrpcMessage) {
+			defer wg.Done()
+			defer func() { <-h.batchSlots }()
+			results[i] = h.handleCallMsg(cp, msg)
+		}(i, msg)
+	}
+	wg.Wait()
+
+	answers := make([]*jsonrpcMessage, 0, len(calls))
+	for _, answer := range results {
+		if answer != nil {
+			answers = append(answers, answer)
+		}
+	}
+	return answers
+}
+
+// hasSubscriptions reports whether any of the given calls creates or cancels a
+// subscription. Such batches are served sequentially.
+func hasSubscriptions(calls []*jsonrpcMessage) bool {
+	for _, msg := range calls {
+		if msg.isSubscribe() || msg.isUnsubscribe() {
+			return true
+		}
+	}
+	return false
+}
+
 // handleMsg handles a single message.
 func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	if ok := h.handleImmediate(msg); ok {
@@ -329,12 +391,29 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
+	if callb != h.unsubscribeCb {
+		if err := h.reg.checkCall(msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
+		done, err := h.reg.limitCall(h.conn.remoteAddr(), msg.Method)
+		if err != nil {
+			return msg.errorResponse(err)
+		}
+		defer done()
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
+	ctx, span := tracer.Start(cp.ctx, msg.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemKey.String("jsonrpc"), semconv.RPCMethodKey.String(msg.Method)))
+	answer := h.runMethod(ctx, msg, callb, args)
+	if answer.Error != nil {
+		span.SetStatus(codes.Error, answer.Error.Message)
+	}
+	span.End()
+	atomic.AddUint64(&servedCalls, 1)
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -347,6 +426,7 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 		}
 		rpcServingTimer.UpdateSince(start)
 		newRPCServingTimer(msg.Method, answer.Error == nil).UpdateSince(start)
+		rpcLatencyHistogram.With(msg.Method).Observe(time.Since(start).Seconds())
 	}
 	return answer
 }
@@ -367,6 +447,9 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	if callb == nil {
 		return msg.errorResponse(&subscriptionNotFoundError{namespace, name})
 	}
+	if err := h.reg.checkCall(msg.Method); err != nil {
+		return msg.errorResponse(err)
+	}
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
@@ -29,6 +29,9 @@ import (
 	"net/url"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
@@ -39,13 +42,18 @@ const (
 // https://www.jsonrpc.org/historical/json-rpc-over-http.html#id13
 var acceptedContentTypes = []string{contentType, "application/json-rpc", "application/jsonrequest"}
 
+// HeaderFunc is a callback to add dynamic headers to outgoing HTTP requests or
+// websocket handshakes, e.g. to attach freshly minted access tokens.
+type HeaderFunc func(ctx context.Context, header http.Header) error
+
 type httpConn struct {
 	client    *http.Client
 	url       string
 	closeOnce sync.Once
 	closeCh   chan interface{}
-	mu        sync.Mutex // protects headers
+	mu        sync.Mutex // protects headers and headerFn
 	headers   http.Header
+	headerFn  HeaderFunc
 }
 
 // httpConn is treated specially by Client.
@@ -186,8 +194,17 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	// set headers
 	hc.mu.Lock()
 	req.Header = hc.headers.Clone()
+	headerFn := hc.headerFn
 	hc.mu.Unlock()
 
+	if headerFn != nil {
+		if err := headerFn(ctx, req.Header); err != nil {
+			return nil, err
+		}
+	}
+	// propagate the trace of the caller, if any
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	// do request
 	resp, err := hc.client.Do(req)
 	if err != nil {
@@ -247,6 +264,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		ctx = context.WithValue(ctx, "Origin", origin)
 	}
+	// Continue the trace of the caller, if any
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
 
 	w.Header().Set("content-type", contentType)
 	codec := newHTTPServerConn(r, w)
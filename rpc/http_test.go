@@ -17,10 +17,17 @@
 package rpc
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func confirmStatusCode(t *testing.T, got, want int) {
@@ -123,3 +130,58 @@ func TestHTTPRespBodyUnlimited(t *testing.T) {
 		t.Fatalf("response has wrong length %d, want %d", len(r), respLength)
 	}
 }
+
+// This checks that the trace context of the client is propagated over HTTP, and
+// that the server records a child span for every served call.
+func TestHTTPTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	s := newTestServer()
+	defer s.Stop()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+	if err := c.CallContext(ctx, nil, "test_noArgsRets"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CallContext(ctx, nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	root.End()
+
+	var served []*sdktrace.SpanSnapshot
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "root" {
+			served = append(served, span)
+		}
+	}
+	if len(served) != 2 {
+		t.Fatalf("wrong number of server spans: got %d, want 2", len(served))
+	}
+	for i, want := range []string{"test_noArgsRets", "test_returnError"} {
+		span := served[i]
+		if span.Name != want {
+			t.Errorf("span %d: wrong name %q, want %q", i, span.Name, want)
+		}
+		if span.Parent.TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("span %d: trace context not propagated", i)
+		}
+	}
+	if served[0].StatusCode == codes.Error {
+		t.Errorf("successful call marked as failed")
+	}
+	if served[1].StatusCode != codes.Error {
+		t.Errorf("failed call not marked as failed")
+	}
+}
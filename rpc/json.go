@@ -27,6 +27,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/acent/go-acent"
 )
 
 const (
@@ -119,6 +121,12 @@ func errorMessage(err error) *jsonrpcMessage {
 	if ok {
 		msg.Error.Data = de.ErrorData()
 	}
+	// Typed API errors keep their code and data when wrapped in context
+	var apiErr *acent.Error
+	if errors.As(err, &apiErr) {
+		msg.Error.Code = apiErr.ErrorCode()
+		msg.Error.Data = apiErr.ErrorData()
+	}
 	return msg
 }
 
@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/acent/go-acent/metrics"
 )
@@ -27,8 +28,22 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	// rpcLatencyHistogram tracks the serving time of the RPC calls, labeled by
+	// method. Only registered methods are tracked to bound the label values.
+	rpcLatencyHistogram = metrics.NewRegisteredLabeledBucketHistogram("rpc/latency/seconds", nil, metrics.DefaultBuckets, "method")
 )
 
+// servedCalls is the number of method calls served by all the servers of the
+// process, counted regardless of whether metrics are enabled.
+var servedCalls uint64
+
+// ServedCalls returns the number of method calls served by all the RPC servers
+// of the process, allowing services to detect RPC activity.
+func ServedCalls() uint64 {
+	return atomic.LoadUint64(&servedCalls)
+}
+
 func newRPCServingTimer(method string, valid bool) metrics.Timer {
 	flag := "success"
 	if !valid {
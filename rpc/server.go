@@ -66,6 +66,36 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// CallFilter is consulted before serving a method call. Returning an error rejects the
+// call, the error being sent back to the caller as the response.
+type CallFilter func(method string) error
+
+// SetCallFilter installs a filter consulted before serving every call of a registered
+// method or subscription. Calls to unknown methods and unsubscriptions are not filtered.
+func (s *Server) SetCallFilter(filter CallFilter) {
+	s.services.setFilter(filter)
+}
+
+// CallLimiter is consulted before serving a method call from the given remote address.
+// Returning an error rejects the call, the error being sent back to the caller as the
+// response. Otherwise the returned function is called once the call has been served.
+type CallLimiter func(remote, method string) (done func(), err error)
+
+// SetCallLimiter installs a limiter consulted before serving every call of a registered
+// method, after the call filter. Subscriptions and calls to unknown methods are not
+// limited.
+func (s *Server) SetCallLimiter(limiter CallLimiter) {
+	s.services.setLimiter(limiter)
+}
+
+// SetBatchParallelism sets the maximum number of calls served concurrently for the
+// batches of a single connection. Calls of a batch are served one after the other
+// if n is below 2, which is the default. Responses are always sent in the order of
+// the batched requests. Batches containing subscriptions are served sequentially.
+func (s *Server) SetBatchParallelism(n int) {
+	s.services.setBatchParallelism(n)
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
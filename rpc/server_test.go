@@ -19,11 +19,17 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -150,3 +156,155 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+func TestServerCallFilter(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	var filtered []string
+	server.SetCallFilter(func(method string) error {
+		filtered = append(filtered, method)
+		if method == "test_echo" || method == "nftest_subscribe" {
+			return testError{}
+		}
+		return nil
+	})
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Rejected calls and subscriptions must return the filter error
+	var resp echoResult
+	err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"})
+	if re, ok := err.(Error); !ok || re.ErrorCode() != 444 {
+		t.Fatalf("filtered call error mismatch: have %v, want %v", err, testError{})
+	}
+	_, err = client.Subscribe(context.Background(), "nftest", make(chan int), "someSubscription", 1, 1)
+	if re, ok := err.(Error); !ok || re.ErrorCode() != 444 {
+		t.Fatalf("filtered subscription error mismatch: have %v, want %v", err, testError{})
+	}
+	// Accepted calls must go through, unknown methods must bypass the filter
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("unfiltered call failed: %v", err)
+	}
+	if err := client.Call(nil, "test_unknown"); err == nil {
+		t.Fatalf("unknown method call succeeded")
+	}
+	want := []string{"test_echo", "nftest_subscribe", "test_noArgsRets"}
+	if !reflect.DeepEqual(filtered, want) {
+		t.Fatalf("filtered methods mismatch: have %v, want %v", filtered, want)
+	}
+}
+
+func TestServerCallLimiter(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	var (
+		limited []string
+		done    int
+	)
+	server.SetCallLimiter(func(remote, method string) (func(), error) {
+		limited = append(limited, method)
+		if method == "test_echo" {
+			return nil, testError{}
+		}
+		return func() { done++ }, nil
+	})
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Rejected calls must return the limiter error
+	var resp echoResult
+	err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"})
+	if re, ok := err.(Error); !ok || re.ErrorCode() != 444 {
+		t.Fatalf("limited call error mismatch: have %v, want %v", err, testError{})
+	}
+	// Accepted calls must go through and be released, unknown methods must bypass the limiter
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("unlimited call failed: %v", err)
+	}
+	if err := client.Call(nil, "test_unknown"); err == nil {
+		t.Fatalf("unknown method call succeeded")
+	}
+	want := []string{"test_echo", "test_noArgsRets"}
+	if !reflect.DeepEqual(limited, want) {
+		t.Fatalf("limited methods mismatch: have %v, want %v", limited, want)
+	}
+	if done != 1 {
+		t.Fatalf("released calls mismatch: have %d, want %d", done, 1)
+	}
+}
+
+func TestServedCalls(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	before := ServedCalls()
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if after := ServedCalls(); after <= before {
+		t.Fatalf("served call not counted: have %d, want > %d", after, before)
+	}
+}
+
+func TestServerBatchParallelism(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchParallelism(3)
+
+	var (
+		lock     sync.Mutex
+		inflight int
+		peak     int
+	)
+	server.SetCallLimiter(func(remote, method string) (func(), error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if inflight++; inflight > peak {
+			peak = inflight
+		}
+		return func() {
+			lock.Lock()
+			inflight--
+			lock.Unlock()
+		}, nil
+	})
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+	go server.ServeCodec(NewCodec(p1), 0)
+	p2.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// The earlier calls sleep longer, so they finish last if served concurrently
+	var batch []string
+	for i := 0; i < 6; i++ {
+		batch = append(batch, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"test_sleep","params":[%d]}`, i, (6-i)*int(20*time.Millisecond)))
+	}
+	start := time.Now()
+	if _, err := p2.Write([]byte("[" + strings.Join(batch, ",") + "]")); err != nil {
+		t.Fatal("write error:", err)
+	}
+	var resps []jsonrpcMessage
+	if err := json.NewDecoder(p2).Decode(&resps); err != nil {
+		t.Fatal("read error:", err)
+	}
+	elapsed := time.Since(start)
+
+	if len(resps) != len(batch) {
+		t.Fatalf("response count mismatch: have %d, want %d", len(resps), len(batch))
+	}
+	for i, resp := range resps {
+		if string(resp.ID) != strconv.Itoa(i) || resp.Error != nil {
+			t.Fatalf("response %d mismatch: id %s, error %v", i, resp.ID, resp.Error)
+		}
+	}
+	if peak != 3 {
+		t.Fatalf("concurrent calls mismatch: have %d, want %d", peak, 3)
+	}
+	if sequential := 21 * 20 * time.Millisecond; elapsed >= sequential {
+		t.Fatalf("batch not served concurrently: took %v, sequential %v", elapsed, sequential)
+	}
+}
@@ -39,6 +39,9 @@ var (
 type serviceRegistry struct {
 	mu       sync.Mutex
 	services map[string]service
+	filter   CallFilter
+	limiter  CallLimiter
+	parallel int // number of batch calls served concurrently per connection
 }
 
 // service represents a registered object.
@@ -103,6 +106,59 @@ func (r *serviceRegistry) callback(method string) *callback {
 	return r.services[elem[0]].callbacks[elem[1]]
 }
 
+// setFilter installs the filter consulted before serving method calls.
+func (r *serviceRegistry) setFilter(filter CallFilter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filter = filter
+}
+
+// setLimiter installs the limiter consulted before serving method calls.
+func (r *serviceRegistry) setLimiter(limiter CallLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiter = limiter
+}
+
+// setBatchParallelism sets the number of calls of a batch served concurrently.
+func (r *serviceRegistry) setBatchParallelism(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parallel = n
+}
+
+// batchParallelism returns the number of calls of a batch served concurrently.
+func (r *serviceRegistry) batchParallelism() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.parallel
+}
+
+// limitCall runs the given method call through the installed limiter, if any,
+// returning the function to call once the call has been served.
+func (r *serviceRegistry) limitCall(remote, method string) (func(), error) {
+	r.mu.Lock()
+	limiter := r.limiter
+	r.mu.Unlock()
+
+	if limiter == nil {
+		return func() {}, nil
+	}
+	return limiter(remote, method)
+}
+
+// checkCall runs the given method call through the installed filter, if any.
+func (r *serviceRegistry) checkCall(method string) error {
+	r.mu.Lock()
+	filter := r.filter
+	r.mu.Unlock()
+
+	if filter == nil {
+		return nil
+	}
+	return filter(method)
+}
+
 // subscription returns a subscription callback in the given service.
 func (r *serviceRegistry) subscription(service, name string) *callback {
 	r.mu.Lock()
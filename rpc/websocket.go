@@ -183,11 +183,25 @@ func parseOriginURL(origin string) (string, string, string, error) {
 // DialWebsocketWithDialer creates a new RPC client that communicates with a JSON-RPC server
 // that is listening on the given endpoint using the provided dialer.
 func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, dialer websocket.Dialer) (*Client, error) {
-	endpoint, header, err := wsClientHeaders(endpoint, origin)
+	return DialWebsocketWithHeaders(ctx, endpoint, origin, dialer, nil)
+}
+
+// DialWebsocketWithHeaders creates a new RPC client that communicates with a JSON-RPC
+// server that is listening on the given endpoint using the provided dialer. The header
+// callback, if non-nil, is invoked on every (re)connection to add custom headers to the
+// websocket handshake.
+func DialWebsocketWithHeaders(ctx context.Context, endpoint, origin string, dialer websocket.Dialer, headerFn HeaderFunc) (*Client, error) {
+	endpoint, baseHeader, err := wsClientHeaders(endpoint, origin)
 	if err != nil {
 		return nil, err
 	}
 	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		header := baseHeader.Clone()
+		if headerFn != nil {
+			if err := headerFn(ctx, header); err != nil {
+				return nil, err
+			}
+		}
 		conn, resp, err := dialer.DialContext(ctx, endpoint, header)
 		if err != nil {
 			hErr := wsHandshakeError{err: err}
@@ -206,12 +220,17 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
-	dialer := websocket.Dialer{
+	return DialWebsocketWithDialer(ctx, endpoint, origin, DefaultWebsocketDialer())
+}
+
+// DefaultWebsocketDialer returns the dialer used by DialWebsocket, to be used as
+// the base of customized dialers.
+func DefaultWebsocketDialer() websocket.Dialer {
+	return websocket.Dialer{
 		ReadBufferSize:  wsReadBuffer,
 		WriteBufferSize: wsWriteBuffer,
 		WriteBufferPool: wsBufferPool,
 	}
-	return DialWebsocketWithDialer(ctx, endpoint, origin, dialer)
 }
 
 func wsClientHeaders(endpoint, origin string) (string, http.Header, error) {
@@ -246,6 +265,7 @@ func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
 		conn:      conn,
 		pingReset: make(chan struct{}, 1),
 	}
+	wc.jsonCodec.remote = conn.RemoteAddr().String()
 	wc.wg.Add(1)
 	go wc.pingLoop()
 	return wc
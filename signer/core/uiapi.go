@@ -66,6 +66,7 @@ type rawWallet struct {
 	URL      string             `json:"url"`
 	Status   string             `json:"status"`
 	Failure  string             `json:"failure,omitempty"`
+	Opened   bool               `json:"opened"`
 	Accounts []accounts.Account `json:"accounts,omitempty"`
 }
 
@@ -74,16 +75,15 @@ type rawWallet struct {
 // {"jsonrpc":"2.0","method":"clef_listWallets","params":[], "id":5}
 func (s *UIServerAPI) ListWallets() []rawWallet {
 	wallets := make([]rawWallet, 0) // return [] instead of nil if empty
-	for _, wallet := range s.am.Wallets() {
-		status, failure := wallet.Status()
-
+	for _, wallet := range s.am.WalletStatuses() {
 		raw := rawWallet{
-			URL:      wallet.URL().String(),
-			Status:   status,
-			Accounts: wallet.Accounts(),
+			URL:      wallet.URL.String(),
+			Status:   wallet.Status,
+			Opened:   wallet.Opened,
+			Accounts: wallet.Accounts,
 		}
-		if failure != nil {
-			raw.Failure = failure.Error()
+		if wallet.Failure != nil {
+			raw.Failure = wallet.Failure.Error()
 		}
 		wallets = append(wallets, raw)
 	}
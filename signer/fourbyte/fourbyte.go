@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 )
 
 // Database is a 4byte database with the possibility of maintaining an immutable
@@ -35,6 +36,9 @@ type Database struct {
 	embedded   map[string]string
 	custom     map[string]string
 	customPath string
+
+	imported map[string]*importedSet // User supplied selector databases keyed by path
+	lock     sync.RWMutex            // Lock protecting the custom and imported sets
 }
 
 // newEmpty exists for testing purposes.
@@ -42,6 +46,7 @@ func newEmpty() *Database {
 	return &Database{
 		embedded: make(map[string]string),
 		custom:   make(map[string]string),
+		imported: make(map[string]*importedSet),
 	}
 }
 
@@ -74,7 +79,7 @@ func NewFromFile(path string) (*Database, error) {
 // file) as well as a custom database. The latter will be used to write new
 // values into if they are submitted via the API.
 func NewWithFile(path string) (*Database, error) {
-	db := &Database{make(map[string]string), make(map[string]string), path}
+	db := newEmpty()
 	db.customPath = path
 
 	blob, err := Asset("4byte.json")
@@ -98,6 +103,9 @@ func NewWithFile(path string) (*Database, error) {
 
 // Size returns the number of 4byte entries in the embedded and custom datasets.
 func (db *Database) Size() (int, int) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
 	return len(db.embedded), len(db.custom)
 }
 
@@ -112,9 +120,15 @@ func (db *Database) Selector(id []byte) (string, error) {
 	if selector, exists := db.embedded[sig]; exists {
 		return selector, nil
 	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
 	if selector, exists := db.custom[sig]; exists {
 		return selector, nil
 	}
+	if selector, exists := db.importedSelector(sig); exists {
+		return selector, nil
+	}
 	return "", fmt.Errorf("signature %v not found", sig)
 }
 
@@ -132,6 +146,9 @@ func (db *Database) AddSelector(selector string, data []byte) error {
 		return nil
 	}
 	// Inject the custom selector into the database and persist if needed
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
 	db.custom[hex.EncodeToString(data[:4])] = selector
 	if db.customPath == "" {
 		return nil
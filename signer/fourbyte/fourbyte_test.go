@@ -19,8 +19,11 @@ package fourbyte
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/acent/go-acent/accounts/abi"
 	"github.com/acent/go-acent/common"
@@ -89,3 +92,50 @@ func TestCustomDatabase(t *testing.T) {
 		t.Fatalf("Failed to find a match for persisted abi signature: %v", err)
 	}
 }
+
+// Tests that imported 4byte datasets are verified, looked up and refreshed.
+func TestImportedDatabase(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "signer-4byte-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	filename := filepath.Join(tmpdir, "4byte_import.json")
+
+	// Import a database with one valid and one spoofed entry
+	blob := `{"0xA52C101E": "send(uint256)", "deadbeef": "transfer(address,uint256)"}`
+	if err := ioutil.WriteFile(filename, []byte(blob), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db := newEmpty()
+	loaded, skipped, err := db.Import(filename)
+	if err != nil {
+		t.Fatalf("Failed to import database: %v", err)
+	}
+	if loaded != 1 || skipped != 1 {
+		t.Fatalf("Import count mismatch: have %d/%d, want %d/%d", loaded, skipped, 1, 1)
+	}
+	if selector, err := db.Selector(common.Hex2Bytes("a52c101edeadbeef")); err != nil || selector != "send(uint256)" {
+		t.Fatalf("Imported selector mismatch: have %q (%v), want %q", selector, err, "send(uint256)")
+	}
+	if _, err := db.Selector(common.Hex2Bytes("deadbeef")); err == nil {
+		t.Fatalf("Spoofed selector should not be found")
+	}
+	// Replace the database contents and ensure a refresh picks them up
+	blob = `{"a9059cbb": "transfer(address,uint256)"}`
+	if err := ioutil.WriteFile(filename, []byte(blob), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filename, future, future); err != nil {
+		t.Fatal(err)
+	}
+	db.Refresh()
+
+	if _, err := db.Selector(common.Hex2Bytes("a52c101e")); err == nil {
+		t.Fatalf("Stale selector should have been dropped")
+	}
+	if _, err := db.Selector(common.Hex2Bytes("a9059cbb")); err != nil {
+		t.Fatalf("Failed to find refreshed selector: %v", err)
+	}
+}
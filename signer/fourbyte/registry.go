@@ -0,0 +1,185 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package fourbyte
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acent/go-acent/crypto"
+	"github.com/acent/go-acent/log"
+)
+
+// importedSet is a read-only selector database imported from a user supplied
+// file, tracked alongside its modification time to support refreshing.
+type importedSet struct {
+	modtime   time.Time
+	selectors map[string]string
+}
+
+// Import loads a user supplied selector database (in the same id -> signature
+// JSON format as the embedded one) and makes its entries available to lookups.
+// Importing a path that was already imported replaces the previous contents.
+//
+// Every entry is verified against the keccak hash of its signature, so that a
+// tampered database cannot make clef show misleading method names. Invalid
+// entries are dropped and reported in the returned skip count.
+func (db *Database) Import(path string) (int, int, error) {
+	set, skipped, err := loadImport(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.imported[path] = set
+	return len(set.selectors), skipped, nil
+}
+
+// Imports returns the paths of all imported selector databases.
+func (db *Database) Imports() []string {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	paths := make([]string, 0, len(db.imported))
+	for path := range db.imported {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Refresh reloads all imported selector databases that were modified on disk
+// since they were last loaded. Databases that fail to load retain their old
+// contents, and the failure is logged.
+func (db *Database) Refresh() {
+	for _, path := range db.Imports() {
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Warn("Failed to stat 4byte import", "path", path, "err", err)
+			continue
+		}
+		db.lock.RLock()
+		set := db.imported[path]
+		db.lock.RUnlock()
+
+		if set != nil && !stat.ModTime().After(set.modtime) {
+			continue
+		}
+		loaded, skipped, err := db.Import(path)
+		if err != nil {
+			log.Warn("Failed to refresh 4byte import", "path", path, "err", err)
+			continue
+		}
+		log.Info("Refreshed 4byte import", "path", path, "selectors", loaded, "skipped", skipped)
+	}
+}
+
+// WatchImports periodically refreshes the imported selector databases until
+// the returned stop function is called.
+func (db *Database) WatchImports(interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				db.Refresh()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() { close(quit) }
+}
+
+// importedSelector looks up a 4byte id in the imported databases, checked in
+// path order to keep the result deterministic. Callers must hold db.lock.
+func (db *Database) importedSelector(id string) (string, bool) {
+	if len(db.imported) == 0 {
+		return "", false
+	}
+	paths := make([]string, 0, len(db.imported))
+	for path := range db.imported {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if selector, exists := db.imported[path].selectors[id]; exists {
+			return selector, true
+		}
+	}
+	return "", false
+}
+
+// loadImport reads and verifies a selector database from disk.
+func loadImport(path string) (*importedSet, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	var raw map[string]string
+	if err := json.NewDecoder(file).Decode(&raw); err != nil {
+		return nil, 0, fmt.Errorf("invalid 4byte database %s: %v", path, err)
+	}
+	set := &importedSet{
+		modtime:   stat.ModTime(),
+		selectors: make(map[string]string, len(raw)),
+	}
+	var skipped int
+	for id, selector := range raw {
+		id = strings.ToLower(strings.TrimPrefix(id, "0x"))
+		if err := verifyEntry(id, selector); err != nil {
+			log.Debug("Skipping invalid 4byte entry", "path", path, "id", id, "selector", selector, "err", err)
+			skipped++
+			continue
+		}
+		set.selectors[id] = selector
+	}
+	return set, skipped, nil
+}
+
+// verifyEntry checks that a database entry is a well formed method signature
+// whose hash matches the 4byte id it's stored under.
+func verifyEntry(id string, selector string) error {
+	want, err := hex.DecodeString(id)
+	if err != nil || len(want) != 4 {
+		return fmt.Errorf("invalid 4byte id %q", id)
+	}
+	if _, err := parseSelector(selector); err != nil {
+		return err
+	}
+	if have := crypto.Keccak256([]byte(selector))[:4]; !bytes.Equal(have, want) {
+		return fmt.Errorf("selector hash mismatch: have %x, want %x", have, want)
+	}
+	return nil
+}
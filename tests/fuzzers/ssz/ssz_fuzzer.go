@@ -0,0 +1,70 @@
+// Copyright 2021 The go-acent Authors
+// This file is part of the go-acent library.
+//
+// The go-acent library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-acent library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-acent library. If not, see <http://www.gnu.org/licenses/>.
+
+package ssz
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/acent/go-acent/core/types"
+)
+
+// Fuzz decodes the input as an SSZ header and transaction, checking that whatever
+// decodes successfully re-encodes to the same bytes and to the same object as its
+// RLP round-trip.
+func Fuzz(input []byte) int {
+	if len(input) == 0 {
+		return 0
+	}
+	var score int
+
+	var header types.Header
+	if err := header.UnmarshalSSZ(input); err == nil {
+		output, err := header.MarshalSSZ()
+		if err != nil {
+			panic(fmt.Sprintf("failed to re-encode header: %v", err))
+		}
+		if !bytes.Equal(input, output) {
+			panic(fmt.Sprintf("header encode-decode is not equal\ninput : %x\noutput: %x", input, output))
+		}
+		score = 1
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalSSZ(input); err == nil {
+		output, err := tx.MarshalSSZ()
+		if err != nil {
+			panic(fmt.Sprintf("failed to re-encode transaction: %v", err))
+		}
+		if !bytes.Equal(input, output) {
+			panic(fmt.Sprintf("transaction encode-decode is not equal\ninput : %x\noutput: %x", input, output))
+		}
+		// The SSZ and RLP encodings must describe the same transaction
+		enc, err := tx.MarshalBinary()
+		if err != nil {
+			panic(fmt.Sprintf("failed to encode transaction in RLP: %v", err))
+		}
+		var dec types.Transaction
+		if err := dec.UnmarshalBinary(enc); err != nil {
+			panic(fmt.Sprintf("failed to decode transaction from RLP: %v", err))
+		}
+		if dec.Hash() != tx.Hash() {
+			panic(fmt.Sprintf("transaction hash mismatch after RLP round-trip: %x != %x", dec.Hash(), tx.Hash()))
+		}
+		score = 1
+	}
+	return score
+}